@@ -22,6 +22,10 @@ server-url
 server-version
 {{- end }}
 
+{{- define "csp-adapter.complianceSetting" -}}
+csp-adapter-compliance
+{{- end }}
+
 {{- define "csp-adapter.csp" -}}
 {{- if .Values.aws -}}
     {{- if .Values.aws.enabled -}}
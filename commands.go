@@ -0,0 +1,724 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/rancher/csp-adapter/pkg/audit"
+	"github.com/rancher/csp-adapter/pkg/clients/aws"
+	"github.com/rancher/csp-adapter/pkg/clients/k8s"
+	"github.com/rancher/csp-adapter/pkg/iam"
+	"github.com/rancher/csp-adapter/pkg/manager"
+	"github.com/rancher/csp-adapter/pkg/migrate"
+	"github.com/rancher/csp-adapter/pkg/onboarding"
+	"github.com/rancher/csp-adapter/pkg/settings"
+	"github.com/rancher/csp-adapter/pkg/setup"
+	"github.com/rancher/csp-adapter/pkg/signing"
+	"github.com/rancher/csp-adapter/pkg/state"
+	"github.com/rancher/csp-adapter/pkg/usage"
+	"github.com/rancher/wrangler/pkg/kubeconfig"
+)
+
+const (
+	adapterNamespace      = "cattle-csp-adapter-system"
+	adapterServiceAccount = "rancher-csp-adapter"
+	adapterCacheSecret    = "csp-adapter-cache"
+	statePassphraseEnv    = "CSP_ADAPTER_STATE_PASSPHRASE"
+)
+
+// runCommand runs the one-off command name with the provided args
+func runCommand(name string, args []string) error {
+	switch name {
+	case "init":
+		return runInit(args)
+	case "bootstrap":
+		return runBootstrap(args)
+	case "iam-policy":
+		return runIAMPolicy(args)
+	case "true-up":
+		return runTrueUp(args)
+	case "verify-report":
+		return runVerifyReport(args)
+	case "verify-audit-log":
+		return runVerifyAuditLog(args)
+	case "state":
+		return runState(args)
+	case "config":
+		return runConfig(args)
+	case "migrate":
+		return runMigrate(args)
+	case "verify-onboarding":
+		return runVerifyOnboarding(args)
+	case "checkin":
+		return runCheckIn(args)
+	default:
+		return fmt.Errorf("unknown command %q, available commands: init, bootstrap, iam-policy, true-up, verify-report, verify-audit-log, state, config, migrate, verify-onboarding, checkin", name)
+	}
+}
+
+// runInit walks through the configuration of a first install: it detects the cloud from the default credential chain,
+// validates the credentials, finds the rancher license received by the account and proposes values for it, which are
+// reviewed interactively unless --non-interactive is set, then writes them as helm values
+func runInit(args []string) error {
+	fs := flag.NewFlagSet("init", flag.ContinueOnError)
+	cloud := fs.String("cloud", "", "cloud the license was purchased in, detected from the available credentials if empty (supported: aws)")
+	roleName := fs.String("role-name", "rancher-csp-adapter", "iam role used by the adapter, i.e. as created by bootstrap")
+	output := fs.String("output", "csp-adapter-values.yaml", "file the helm values are written to")
+	force := fs.Bool("force", false, "overwrite --output if it exists")
+	nonInteractive := fs.Bool("non-interactive", false, "accept the proposed values without prompting")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if !*force {
+		if _, err := os.Stat(*output); err == nil {
+			return fmt.Errorf("%s already exists, pass --force to overwrite it", *output)
+		}
+	}
+
+	ctx := context.Background()
+	if *cloud == "" {
+		detected, err := detectCloud(ctx)
+		if err != nil {
+			return err
+		}
+		*cloud = detected
+		fmt.Printf("detected %s credentials\n", *cloud)
+	}
+	if *cloud != awsCSP {
+		return fmt.Errorf("unsupported cloud %q, supported: %s", *cloud, awsCSP)
+	}
+	// validates the credentials by finding the account number
+	client, err := aws.NewClient(ctx, aws.ClientOptions{})
+	if err != nil {
+		return fmt.Errorf("unable to use the aws credentials: %v", err)
+	}
+	products, err := client.ListProducts(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to list the licenses received by account %s: %v", client.AccountNumber(), err)
+	}
+	proposal, err := setup.Propose(client.AccountNumber(), *roleName, products)
+	if err != nil {
+		return err
+	}
+	if *nonInteractive {
+		fmt.Printf("found %s license %s (sku %s) in account %s\n", proposal.Product, proposal.LicenseArn, proposal.SKU, proposal.AccountNumber)
+	} else if err := setup.Review(os.Stdin, os.Stdout, &proposal); err != nil {
+		return err
+	}
+	values, err := proposal.Values()
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(*output, values, 0644); err != nil {
+		return err
+	}
+	fmt.Printf("wrote helm values to %s, pass them to the chart's install with -f %s\n", *output, *output)
+	return nil
+}
+
+// detectCloud returns the cloud whose credentials are available. Only aws is supported
+func detectCloud(ctx context.Context) (string, error) {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err == nil {
+		_, err = cfg.Credentials.Retrieve(ctx)
+	}
+	if err != nil {
+		return "", fmt.Errorf("no aws credentials found, configure the aws credential chain or pass --cloud: %v", err)
+	}
+	return awsCSP, nil
+}
+
+// runBootstrap creates the iam policy and role used by the adapter. It uses the default aws credential chain, which
+// must be allowed to manage iam
+func runBootstrap(args []string) error {
+	fs := flag.NewFlagSet("bootstrap", flag.ContinueOnError)
+	opts := iam.BootstrapOptions{}
+	fs.StringVar(&opts.OIDCIssuer, "oidc-issuer", "", "issuer url of the EKS cluster's oidc provider (aws eks describe-cluster --query cluster.identity.oidc.issuer)")
+	fs.StringVar(&opts.RoleName, "role-name", "rancher-csp-adapter", "name of the iam role to create")
+	fs.StringVar(&opts.PolicyName, "policy-name", "rancher-csp-adapter", "name of the iam policy to create")
+	fs.StringVar(&opts.Namespace, "namespace", adapterNamespace, "namespace the adapter is installed in")
+	fs.StringVar(&opts.ServiceAccount, "service-account", adapterServiceAccount, "service account used by the adapter")
+	fs.StringVar(&opts.WriteRoleName, "write-role-name", "", "name of a second iam role to create for checkouts, check-ins and extensions, leaving the adapter's role read-only")
+	addFeatureFlags(fs, &opts.Features)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if opts.OIDCIssuer == "" {
+		return errors.New("--oidc-issuer is required")
+	}
+
+	ctx := context.Background()
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return err
+	}
+	identity, err := sts.NewFromConfig(cfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return fmt.Errorf("unable to determine account number: %v", err)
+	}
+	opts.AccountNumber = *identity.Account
+	if opts.Features.Partition == "" {
+		// china and govcloud accounts build their arns in partitions of their own
+		if identity.Arn == nil {
+			return errors.New("unable to determine partition, the caller identity has no arn")
+		}
+		callerARN, err := arn.Parse(*identity.Arn)
+		if err != nil {
+			return fmt.Errorf("unable to determine partition: %v", err)
+		}
+		opts.Features.Partition = callerARN.Partition
+	}
+
+	res, err := iam.NewBootstrapper(cfg).Bootstrap(ctx, opts)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("policy arn: %s\nrole arn: %s\n", res.PolicyARN, res.RoleARN)
+	if res.WriteRoleARN != "" {
+		fmt.Printf("write policy arn: %s\nwrite role arn: %s\n", res.WritePolicyARN, res.WriteRoleARN)
+	}
+	fmt.Printf("\nhelm values:\naws:\n  enabled: true\n  accountNumber: %q\n  roleName: %q\n", opts.AccountNumber, opts.RoleName)
+	if opts.WriteRoleName != "" {
+		fmt.Printf("  writeRoleName: %q\n", opts.WriteRoleName)
+	}
+	return nil
+}
+
+// runIAMPolicy prints the least privileged iam policy for the selected features, for security teams which create the
+// adapter's role themselves. With --write it prints the policy of the write role instead
+func runIAMPolicy(args []string) error {
+	fs := flag.NewFlagSet("iam-policy", flag.ContinueOnError)
+	var features iam.Features
+	addFeatureFlags(fs, &features)
+	fs.StringVar(&features.WriteRoleARN, "write-role-arn", "", "role assumed for checkouts, check-ins and extensions, the printed policy only reads and assumes it")
+	write := fs.Bool("write", false, "print the policy of the write role, see --write-role-arn")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	policy := iam.PolicyFor(features)
+	if *write {
+		policy = iam.WritePolicy()
+	}
+	document, err := json.MarshalIndent(policy, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(document))
+	return nil
+}
+
+// runTrueUp produces a report of the usage history recorded by the adapter for a period, for true-up reviews. It reads
+// the history from the cluster of the current kubeconfig. The report is written to stdout, or to every --output with
+// the format of that output. With --signing-keys, a signed attestation is written next to every output
+func runTrueUp(args []string) error {
+	fs := flag.NewFlagSet("true-up", flag.ContinueOnError)
+	kubeconfigPath := fs.String("kubeconfig", os.Getenv("KUBECONFIG"), "kubeconfig of the cluster rancher is installed in")
+	fromValue := fs.String("from", "", "first day of the period, i.e. 2022-01-01")
+	toValue := fs.String("to", "", "last day of the period (inclusive), i.e. 2022-03-31")
+	timezone := fs.String("timezone", os.Getenv(reportingTimezoneEnv), "timezone the days of the period start in and times are rendered in, i.e. America/New_York (default UTC)")
+	format := fs.String("format", usage.FormatCSV, "report format, one of "+strings.Join(usage.Formats(), ", "))
+	var outputs reportOutputs
+	fs.Var(&outputs, "output", "write the report to a file instead of stdout, as format=path (i.e. html=q1.html), can be repeated")
+	signingKeys := fs.String("signing-keys", "", "directory holding the versioned signing keys (i.e. v1, v2), to attest each output with the newest one")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var keyring *signing.Keyring
+	if *signingKeys != "" {
+		if len(outputs) == 0 {
+			return errors.New("--signing-keys requires --output, attestations are written next to the outputs")
+		}
+		var err error
+		if keyring, err = signing.NewDirectory(*signingKeys).Keyring(); err != nil {
+			return err
+		}
+	}
+	location := time.UTC
+	if *timezone != "" {
+		var err error
+		if location, err = time.LoadLocation(*timezone); err != nil {
+			return fmt.Errorf("invalid --timezone %q: %v", *timezone, err)
+		}
+	}
+	from, err := time.ParseInLocation(dateLayout, *fromValue, location)
+	if err != nil {
+		return fmt.Errorf("--from must be a date like 2022-01-01: %v", err)
+	}
+	to, err := time.ParseInLocation(dateLayout, *toValue, location)
+	if err != nil {
+		return fmt.Errorf("--to must be a date like 2022-03-31: %v", err)
+	}
+	// include all hours of the last day
+	to = to.AddDate(0, 0, 1)
+	if !from.Before(to) {
+		return errors.New("--from must not be after --to")
+	}
+
+	cfg, err := kubeconfig.GetNonInteractiveClientConfig(*kubeconfigPath).ClientConfig()
+	if err != nil {
+		return err
+	}
+	clients, err := k8s.NewUsageHistoryReader(cfg)
+	if err != nil {
+		return err
+	}
+	records, err := usage.Load(clients, from, to)
+	if err != nil {
+		return err
+	}
+	report := usage.NewReport(from, to, records).In(location)
+	if len(outputs) == 0 {
+		return report.Write(os.Stdout, *format)
+	}
+	for _, output := range outputs {
+		if err := output.write(report, keyring); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runVerifyReport checks a report written by true-up against its attestation, using the key the attestation names.
+// Reports signed before a key rotation verify as long as the key they were signed with is still configured
+func runVerifyReport(args []string) error {
+	fs := flag.NewFlagSet("verify-report", flag.ContinueOnError)
+	path := fs.String("file", "", "report to verify")
+	attestationPath := fs.String("attestation", "", "attestation of the report (default <file>"+attestationSuffix+")")
+	signingKeys := fs.String("signing-keys", "", "directory holding the versioned signing keys (i.e. v1, v2)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *path == "" || *signingKeys == "" {
+		return errors.New("--file and --signing-keys are required")
+	}
+	if *attestationPath == "" {
+		*attestationPath = *path + attestationSuffix
+	}
+	keyring, err := signing.NewDirectory(*signingKeys).Keyring()
+	if err != nil {
+		return err
+	}
+	content, err := os.ReadFile(*path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(*attestationPath)
+	if err != nil {
+		return err
+	}
+	var attestation signing.Attestation
+	if err := json.Unmarshal(data, &attestation); err != nil {
+		return fmt.Errorf("unable to read attestation %s: %w", *attestationPath, err)
+	}
+	if err := signing.VerifyAttestation(keyring, attestation, content); err != nil {
+		return err
+	}
+	fmt.Printf("%s was signed with key %s at %s\n", *path, attestation.KeyID, attestation.SignedAt.Format(time.RFC3339))
+	return nil
+}
+
+// runVerifyAuditLog checks that the entries of an audit log weren't altered, removed or inserted since they were written,
+// by verifying the hash chain of its entries
+func runVerifyAuditLog(args []string) error {
+	fs := flag.NewFlagSet("verify-audit-log", flag.ContinueOnError)
+	path := fs.String("file", "", "audit log to verify")
+	previous := fs.String("previous-hash", "", "hash of the last entry of the previous log, if the log was rotated")
+	signingKeys := fs.String("signing-keys", "", "directory holding the versioned signing keys (i.e. v1, v2), required for logs hashed with them")
+	anchor := fs.String("anchor", "", "hash reported by an earlier verification, which the log must still hold")
+	allowRestarts := fs.Bool("allow-restarts", false, "accept entries starting a new chain, as written to stdout by restarted adapters")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *path == "" {
+		return errors.New("--file is required")
+	}
+	opts := audit.VerifyOptions{AllowRestarts: *allowRestarts, Anchor: *anchor}
+	if *signingKeys != "" {
+		keyring, err := signing.NewDirectory(*signingKeys).Keyring()
+		if err != nil {
+			return err
+		}
+		opts.Keyring = keyring
+	}
+	file, err := os.Open(*path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	result, err := audit.VerifyChain(file, opts)
+	if err != nil {
+		return err
+	}
+	if result.Entries == 0 {
+		return fmt.Errorf("%s holds no chained entries", *path)
+	}
+	if *previous != "" && result.StartsAfter != *previous {
+		return fmt.Errorf("%s doesn't continue the previous log, its chain starts after %s", *path, result.StartsAfter)
+	}
+	if *anchor != "" && !result.Anchored {
+		return fmt.Errorf("%s doesn't hold the entry with hash %s anymore, entries were removed from its end", *path, *anchor)
+	}
+	fmt.Printf("%d entries of %s are intact, the last entry's hash is %s\n", result.Entries, *path, result.Last)
+	if result.Keyed == 0 {
+		fmt.Println("the entries aren't hashed with signing keys, anyone who can write the log could have rebuilt the chain")
+	}
+	if result.Unchained > 0 {
+		fmt.Printf("%d entries written before the log was chained can't be verified\n", result.Unchained)
+	}
+	if result.Restarts > 0 {
+		fmt.Printf("the chain was restarted %d time(s), which is expected for logs written to stdout by restarted adapters\n", result.Restarts)
+	}
+	if result.StartsAfter != "" && *previous == "" {
+		fmt.Printf("the chain continues a previous log ending with hash %s, verify it with --previous-hash\n", result.StartsAfter)
+	}
+	return nil
+}
+
+// runConfig renders the effective configuration merged from config files, showing which layer set each setting, so that
+// overlays can be reviewed before they're rolled out
+func runConfig(args []string) error {
+	if len(args) == 0 || args[0] != "render" {
+		return errors.New("usage: csp-adapter config render --file <path> [--file <path>...] [--environment <name>]")
+	}
+	fs := flag.NewFlagSet("config render", flag.ContinueOnError)
+	var files stringList
+	fs.Var(&files, "file", "config file or directory of config files, repeated in increasing precedence (default $"+configFilesEnv+")")
+	environment := fs.String("environment", os.Getenv(configEnvironmentEnv), "environment whose overlays are applied")
+	withEnv := fs.Bool("with-env", false, "let the current environment variables override the files like the adapter does, unless $"+configOverrideEnvEnv+" is true")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		files = splitEnvList(os.Getenv(configFilesEnv))
+	}
+	if len(files) == 0 {
+		return errors.New("--file is required")
+	}
+	var lookupEnv func(string) (string, bool)
+	if *withEnv {
+		lookupEnv = configLookupEnv()
+	}
+	cfg, err := settings.Load(files, *environment, lookupEnv)
+	if err != nil {
+		return err
+	}
+	rendered, err := cfg.Render()
+	if err != nil {
+		return err
+	}
+	fmt.Print(string(rendered))
+	return nil
+}
+
+// stringList is a flag which can be repeated
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// runState exports the adapter's persisted state to an encrypted file, or imports it from one, in the cluster of the
+// current kubeconfig. The passphrase is read from CSP_ADAPTER_STATE_PASSPHRASE so that it doesn't end up in the shell
+// history
+func runState(args []string) error {
+	if len(args) == 0 || (args[0] != "export" && args[0] != "import") {
+		return errors.New("usage: csp-adapter state export|import --file <path>")
+	}
+	fs := flag.NewFlagSet("state "+args[0], flag.ContinueOnError)
+	kubeconfigPath := fs.String("kubeconfig", os.Getenv("KUBECONFIG"), "kubeconfig of the cluster rancher is installed in")
+	path := fs.String("file", "", "file the state is exported to or imported from")
+	cacheSecret := fs.String("cache-secret", adapterCacheSecret, "name of the adapter's cache secret")
+	force := fs.Bool("force", false, "import even though the cluster already holds a checkout, which is then left to expire")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if *path == "" {
+		return errors.New("--file is required")
+	}
+	passphrase := os.Getenv(statePassphraseEnv)
+	if passphrase == "" {
+		return fmt.Errorf("%s must be set to the passphrase the state is encrypted with", statePassphraseEnv)
+	}
+
+	cfg, err := kubeconfig.GetNonInteractiveClientConfig(*kubeconfigPath).ClientConfig()
+	if err != nil {
+		return err
+	}
+	clients, err := k8s.NewStateClients(cfg, *cacheSecret)
+	if err != nil {
+		return err
+	}
+	if args[0] == "export" {
+		exported, err := state.Export(clients)
+		if err != nil {
+			return err
+		}
+		file, err := os.OpenFile(*path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+		if err := state.Write(file, exported, passphrase); err != nil {
+			file.Close()
+			return fmt.Errorf("unable to write state to %s: %w", *path, err)
+		}
+		fmt.Printf("exported the cache and %d months of usage history to %s\n", len(exported.UsageHistory), *path)
+		return file.Close()
+	}
+	file, err := os.Open(*path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	imported, err := state.Read(file, passphrase)
+	if err != nil {
+		return err
+	}
+	if err := state.Import(clients, imported, *force); errors.Is(err, state.ErrStateExists) {
+		return fmt.Errorf("%w, pass --force to replace it", err)
+	} else if err != nil {
+		return err
+	}
+	fmt.Printf("imported the cache and %d months of usage history from %s\n", len(imported.UsageHistory), *path)
+	return nil
+}
+
+// runMigrate moves the checkout of an older adapter release, or of another tool which checked out the license, to the
+// adapter in the cluster of the current kubeconfig. The plan is printed and confirmed before anything is changed
+func runMigrate(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	kubeconfigPath := fs.String("kubeconfig", os.Getenv("KUBECONFIG"), "kubeconfig of the cluster rancher is installed in")
+	fromSecret := fs.String("from-secret", "", "cache secret of the older adapter release, as namespace/name")
+	fromFile := fs.String("from-file", "", "inventory of the checkouts of another tool, as json")
+	cacheSecret := fs.String("cache-secret", adapterCacheSecret, "name of the adapter's cache secret")
+	licenses := fs.Int("licenses", 0, "licenses to check out, the licenses held by the legacy checkouts if 0")
+	force := fs.Bool("force", false, "migrate even though the adapter already records a checkout, which is then left to expire")
+	dryRun := fs.Bool("dry-run", false, "print the plan without changing anything")
+	yes := fs.Bool("yes", false, "run the plan without asking for confirmation")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if (*fromSecret == "") == (*fromFile == "") {
+		return errors.New("one of --from-secret and --from-file is required")
+	}
+
+	cfg, err := kubeconfig.GetNonInteractiveClientConfig(*kubeconfigPath).ClientConfig()
+	if err != nil {
+		return err
+	}
+	source := migrate.FromFile(*fromFile)
+	if *fromSecret != "" {
+		parts := strings.SplitN(*fromSecret, "/", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("--from-secret must be namespace/name, got %q", *fromSecret)
+		}
+		store, err := k8s.NewSecretStore(cfg, parts[0], parts[1])
+		if err != nil {
+			return err
+		}
+		source = migrate.FromSecret(store)
+	}
+	target, err := k8s.NewStateClients(cfg, *cacheSecret)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	client, err := aws.NewClient(ctx, aws.ClientOptions{})
+	if err != nil {
+		return fmt.Errorf("unable to use the aws credentials: %v", err)
+	}
+	plan, err := migrate.NewPlan(ctx, client, source, target, migrate.Options{Licenses: *licenses, Force: *force})
+	if errors.Is(err, migrate.ErrCheckoutExists) {
+		return fmt.Errorf("%w, pass --force to replace it", err)
+	} else if err != nil {
+		return err
+	}
+	plan.Describe(os.Stdout)
+	if *dryRun {
+		return nil
+	}
+	if !*yes {
+		confirmed, err := confirm(os.Stdin, os.Stdout, "run the migration?")
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			fmt.Println("nothing was migrated")
+			return nil
+		}
+	}
+	if err := migrate.Run(ctx, client, source, target, plan, os.Stdout); err != nil {
+		return err
+	}
+	fmt.Println("migrated, the adapter adopts the checkout on its next compliance check")
+	return nil
+}
+
+// runCheckIn checks in the checkout recorded in the adapter's cache secret along with its queued check-ins. It's run by
+// the chart's uninstall hook with the adapter's service account, so that uninstalling returns the licenses right away
+func runCheckIn(args []string) error {
+	fs := flag.NewFlagSet("checkin", flag.ContinueOnError)
+	kubeconfigPath := fs.String("kubeconfig", os.Getenv("KUBECONFIG"), "kubeconfig of the cluster rancher is installed in, the in-cluster config if empty")
+	cacheSecret := fs.String("cache-secret", adapterCacheSecret, "name of the adapter's cache secret")
+	stopDeployment := fs.String("stop-deployment", "", "name of the adapter's deployment, which is scaled to 0 replicas "+
+		"before checking in so that it can't check out again")
+	stopTimeout := fs.Duration("stop-timeout", 2*time.Minute, "time to wait for the adapter's pods to stop")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := kubeconfig.GetNonInteractiveClientConfig(*kubeconfigPath).ClientConfig()
+	if err != nil {
+		return err
+	}
+	cache, err := k8s.NewStateClients(cfg, *cacheSecret)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	if *stopDeployment != "" {
+		stopCtx, cancel := context.WithTimeout(ctx, *stopTimeout)
+		err := cache.StopDeployment(stopCtx, *stopDeployment)
+		cancel()
+		if err != nil {
+			return err
+		}
+	}
+	// the same options as the adapter's, so that tokens of licenses homed in another region can be checked in
+	clientOpts, err := clientOptionsFromEnv()
+	if err != nil {
+		return err
+	}
+	client, err := aws.NewClient(ctx, clientOpts)
+	if err != nil {
+		return fmt.Errorf("unable to use the aws credentials: %v", err)
+	}
+	checkedIn, err := manager.CheckInCached(ctx, client, cache)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("checked in %d token(s)\n", checkedIn)
+	return nil
+}
+
+// runVerifyOnboarding walks the chain the adapter relies on with the default aws credential chain: the credentials, the
+// acceptance of the license grant, the entitlements it grants and a checkout and check-in of 1 entitlement, and prints
+// a pass/fail report of each step which can be pasted into a support ticket
+func runVerifyOnboarding(args []string) error {
+	fs := flag.NewFlagSet("verify-onboarding", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print the report as json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	ctx := context.Background()
+	report, err := onboarding.Verify(ctx, fmt.Sprintf("%s (%s)", Version, GitCommit), func(ctx context.Context) (aws.Client, error) {
+		return aws.NewClient(ctx, aws.ClientOptions{})
+	})
+	write := report.Write
+	if *asJSON {
+		write = report.WriteJSON
+	}
+	if writeErr := write(os.Stdout); writeErr != nil && err == nil {
+		err = writeErr
+	}
+	if err != nil {
+		return err
+	}
+	if !report.Passed() {
+		return errors.New("onboarding verification failed")
+	}
+	return nil
+}
+
+// confirm asks question on out, returning whether it was answered with yes on in
+func confirm(in io.Reader, out io.Writer, question string) (bool, error) {
+	fmt.Fprintf(out, "%s [y/N]: ", question)
+	scanner := bufio.NewScanner(in)
+	if !scanner.Scan() {
+		return false, scanner.Err()
+	}
+	answer := strings.ToLower(strings.TrimSpace(scanner.Text()))
+	return answer == "y" || answer == "yes", nil
+}
+
+// reportOutput is a file a report is written to in a format
+type reportOutput struct {
+	renderer usage.Renderer
+	path     string
+}
+
+// attestationSuffix is appended to the path of a report for the path of its attestation
+const attestationSuffix = ".attestation.json"
+
+// write writes report to the output. If keyring isn't nil, the report is attested with its active key, which is
+// recorded in the attestation
+func (o reportOutput) write(report usage.Report, keyring *signing.Keyring) error {
+	var buf bytes.Buffer
+	if err := o.renderer.Render(&buf, report); err != nil {
+		return fmt.Errorf("unable to write report to %s: %w", o.path, err)
+	}
+	if err := os.WriteFile(o.path, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	if keyring == nil {
+		return nil
+	}
+	attestation, err := json.MarshalIndent(signing.Attest(keyring, filepath.Base(o.path), buf.Bytes(), time.Now()), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(o.path+attestationSuffix, attestation, 0644); err != nil {
+		return err
+	}
+	fmt.Printf("signed %s with key %s\n", o.path, keyring.ActiveKeyID())
+	return nil
+}
+
+// reportOutputs collects the repeated --output flags of the true-up command
+type reportOutputs []reportOutput
+
+func (o *reportOutputs) String() string {
+	return ""
+}
+
+func (o *reportOutputs) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return fmt.Errorf("output must be format=path, got %q", value)
+	}
+	format, path := parts[0], parts[1]
+	renderer, err := usage.RendererFor(format)
+	if err != nil {
+		return err
+	}
+	*o = append(*o, reportOutput{renderer: renderer, path: path})
+	return nil
+}
+
+const dateLayout = "2006-01-02"
+
+// addFeatureFlags registers flags selecting the optional integrations which need additional iam permissions
+func addFeatureFlags(fs *flag.FlagSet, features *iam.Features) {
+	fs.BoolVar(&features.LicenseTags, "license-tags", false, "grant permissions to read license tags, to pin the license by tags")
+	fs.BoolVar(&features.UserSubscriptions, "user-subscriptions", false, "grant permissions to subscribe rancher users to products licensed per user")
+	fs.StringVar(&features.S3Bucket, "s3-bucket", "", "grant permissions to export reports to this S3 bucket")
+	fs.StringVar(&features.SecretARN, "secret-arn", "", "grant permissions to read notification credentials from this Secrets Manager secret")
+	fs.StringVar(&features.Partition, "partition", "", "aws partition of the account, i.e. aws-cn or aws-us-gov (default the partition of the credentials for bootstrap, "+iam.DefaultPartition+" otherwise)")
+}
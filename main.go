@@ -1,14 +1,43 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	// embedded so that schedule timezones resolve in images without zoneinfo
+	_ "time/tzdata"
 
+	"github.com/rancher/csp-adapter/pkg/audit"
 	"github.com/rancher/csp-adapter/pkg/clients/aws"
 	"github.com/rancher/csp-adapter/pkg/clients/k8s"
+	"github.com/rancher/csp-adapter/pkg/clients/plugin"
+	"github.com/rancher/csp-adapter/pkg/costs"
+	"github.com/rancher/csp-adapter/pkg/events"
+	"github.com/rancher/csp-adapter/pkg/identity"
+	"github.com/rancher/csp-adapter/pkg/jobs"
+	"github.com/rancher/csp-adapter/pkg/localcache"
 	"github.com/rancher/csp-adapter/pkg/manager"
 	"github.com/rancher/csp-adapter/pkg/metrics"
+	"github.com/rancher/csp-adapter/pkg/profiling"
+	"github.com/rancher/csp-adapter/pkg/reports"
+	"github.com/rancher/csp-adapter/pkg/schedule"
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/rancher/csp-adapter/pkg/secrets"
+	"github.com/rancher/csp-adapter/pkg/server"
+	"github.com/rancher/csp-adapter/pkg/settings"
+	"github.com/rancher/csp-adapter/pkg/signing"
+	"github.com/rancher/csp-adapter/pkg/slo"
+	"github.com/rancher/csp-adapter/pkg/supervisor"
+	"github.com/rancher/csp-adapter/pkg/usage"
 	"github.com/rancher/wrangler/pkg/k8scheck"
 	"github.com/rancher/wrangler/pkg/ratelimit"
 	"github.com/rancher/wrangler/pkg/signals"
@@ -22,17 +51,165 @@ var (
 )
 
 func main() {
-	if err := run(); err != nil {
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		// a non-flag argument selects a one-off command instead of running the adapter
+		if err := runCommand(os.Args[1], os.Args[2:]); err != nil {
+			logrus.Fatalf("csp-adapter %s failed with error: %v", os.Args[1], err)
+		}
+		return
+	}
+	if err := applyConfigFiles(); err != nil {
+		logrus.Fatalf("csp-adapter failed to load its config files: %v", err)
+	}
+	var opts runOptions
+	fs := flag.NewFlagSet("csp-adapter", flag.ExitOnError)
+	fs.BoolVar(&opts.mockCSP, "mock-csp", os.Getenv(mockCSPEnv) == "true", "use a synthetic license instead of a cloud provider, for demos and development")
+	fs.IntVar(&opts.mockEntitlements, "mock-entitlements", defaultMockEntitlements, "entitlements initially granted by the synthetic license")
+	_ = fs.Parse(os.Args[1:])
+	if err := run(opts); err != nil {
 		logrus.Fatalf("csp-adapter failed to run with error: %v", err)
 	}
 }
 
+// runOptions configures how the adapter runs, set from flags
+type runOptions struct {
+	mockCSP          bool
+	mockEntitlements int
+}
+
 const (
-	debugEnv = "CATTLE_DEBUG"
-	awsCSP   = "aws"
+	debugEnv               = "CATTLE_DEBUG"
+	configFilesEnv         = "CONFIG_FILES"
+	configEnvironmentEnv   = "CONFIG_ENVIRONMENT"
+	configOverrideEnvEnv   = "CONFIG_FILES_OVERRIDE_ENV"
+	statusAddressEnv       = "STATUS_ADDRESS"
+	statusTLSCertEnv       = "STATUS_TLS_CERT_FILE"
+	statusTLSKeyEnv        = "STATUS_TLS_KEY_FILE"
+	statusClientCAEnv      = "STATUS_CLIENT_CA_FILE"
+	statusTokenAuthEnv     = "STATUS_TOKEN_AUTH"
+	statusAllowedUsersEnv  = "STATUS_ALLOWED_USERS"
+	statusAllowedGroupsEnv = "STATUS_ALLOWED_GROUPS"
+	statusTrustedProxyEnv  = "STATUS_TRUSTED_PROXIES"
+	statusProxyUserEnv     = "STATUS_PROXY_USER_HEADER"
+	statusPageURLEnv       = "STATUS_PAGE_URL"
+	statusPageIssuerEnv    = "STATUS_PAGE_OIDC_ISSUER_URL"
+	statusPageClientIDEnv  = "STATUS_PAGE_OIDC_CLIENT_ID"
+	statusPageSecretEnv    = "STATUS_PAGE_OIDC_CLIENT_SECRET"
+	statusPageUsersEnv     = "STATUS_PAGE_ALLOWED_USERS"
+	statusPageGroupsEnv    = "STATUS_PAGE_ALLOWED_GROUPS"
+	statusPageUserClaimEnv = "STATUS_PAGE_USERNAME_CLAIM"
+	statusPageGrpClaimEnv  = "STATUS_PAGE_GROUPS_CLAIM"
+	awsAutoSwitchRegionEnv = "AWS_AUTO_SWITCH_REGION"
+	awsBeneficiaryEnv      = "AWS_CHECKOUT_BENEFICIARY"
+	awsWriteRoleARNEnv     = "AWS_WRITE_ROLE_ARN"
+	awsRecordCassetteEnv   = "AWS_RECORD_CASSETTE"
+	awsLicenseTagsEnv      = "AWS_LICENSE_TAGS"
+	awsDualStackEnv        = "AWS_DUAL_STACK"
+	awsSTSRegionEnv        = "AWS_STS_REGION"
+	awsDimensionAliasesEnv = "AWS_DIMENSION_ALIASES"
+	mockCSPEnv             = "MOCK_CSP"
+	providerPluginEnv      = "PROVIDER_PLUGIN"
+	licenseFileEnv         = "LICENSE_FILE"
+	licensePublicKeyEnv    = "LICENSE_PUBLIC_KEY"
+	clusterSummariesEnv    = "PUBLISH_CLUSTER_SUMMARIES"
+	clusterConditionEnv    = "PUBLISH_CLUSTER_CONDITION"
+	minimumLicensesEnv     = "MINIMUM_LICENSES"
+	declaredNodesEnv       = "DECLARED_NODES"
+	nodeCountFailuresEnv   = "NODE_COUNT_FAILURE_THRESHOLD"
+	checkBudgetEnv         = "CHECK_BUDGET_SECONDS"
+	auditLogEnv            = "AUDIT_LOG"
+	auditWebhookEnv        = "AUDIT_WEBHOOK_URL"
+	auditWebhookAuthEnv    = "AUDIT_WEBHOOK_AUTHORIZATION"
+	signingKeysDirEnv      = "SIGNING_KEYS_DIR"
+	reportWebhookEnv       = "REPORT_WEBHOOK_URL"
+	reportWebhookAuthEnv   = "REPORT_WEBHOOK_AUTHORIZATION"
+	reportS3BucketEnv      = "REPORT_S3_BUCKET"
+	reportS3KeyEnv         = "REPORT_S3_KEY"
+	reportWebhookFormatEnv = "REPORT_WEBHOOK_FORMAT"
+	reportS3FormatEnv      = "REPORT_S3_FORMAT"
+	eventsNATSURLEnv       = "EVENTS_NATS_URL"
+	eventsKafkaBrokersEnv  = "EVENTS_KAFKA_BROKERS"
+	eventsTopicEnv         = "EVENTS_TOPIC"
+	eventsBufferEnv        = "EVENTS_BUFFER_SIZE"
+	eventsTLSEnv           = "EVENTS_TLS"
+	eventsTLSDirEnv        = "EVENTS_TLS_DIR"
+	eventsNATSCredsEnv     = "EVENTS_NATS_CREDENTIALS"
+	eventsKafkaSASLEnv     = "EVENTS_KAFKA_SASL_MECHANISM"
+	eventsKafkaUserEnv     = "EVENTS_KAFKA_USERNAME"
+	eventsKafkaPasswordEnv = "EVENTS_KAFKA_PASSWORD"
+	nodeCountSourceEnv     = "NODE_COUNT_SOURCE"
+	nodeCountValidationEnv = "NODE_COUNT_VALIDATION_SOURCE"
+	nodeCountDivergenceEnv = "NODE_COUNT_DIVERGENCE_THRESHOLD"
+	nodeCountParallelEnv   = "NODE_COUNT_PARALLELISM"
+	nodeCountTimeoutEnv    = "NODE_COUNT_CLUSTER_TIMEOUT_SECONDS"
+	nodeCountPushTTLEnv    = "NODE_COUNT_PUSH_TTL_SECONDS"
+	scheduleEnv            = "RECONCILE_SCHEDULE"
+	scheduleTimezoneEnv    = "RECONCILE_SCHEDULE_TIMEZONE"
+	reportingTimezoneEnv   = "REPORTING_TIMEZONE"
+	strictTimeoutEnv       = "STRICT_UNVERIFIED_TIMEOUT_SECONDS"
+	maxTokenExtensionsEnv  = "TOKEN_MAX_EXTENSIONS"
+	tokenLimitWarningEnv   = "TOKEN_LIMIT_WARNING_EXTENSIONS"
+	overAllocationEnv      = "OVER_ALLOCATION_MODE"
+	overRetentionEnv       = "OVER_ALLOCATION_RETENTION_MINUTES"
+	scaleDownConfirmEnv    = "SCALE_DOWN_CONFIRMATION_MINUTES"
+	zeroNodeModeEnv        = "ZERO_NODE_MODE"
+	zeroNodeGraceEnv       = "ZERO_NODE_GRACE_MINUTES"
+	usageVerificationEnv   = "USAGE_VERIFICATION_WINDOW_SECONDS"
+	environmentsEnv        = "CLASSIFY_ENVIRONMENTS"
+	nonProductionRatioEnv  = "NON_PRODUCTION_RATIO"
+	shadowPlannerEnv       = "SHADOW_PLANNER"
+	hourlyRetentionEnv     = "USAGE_HOURLY_RETENTION_DAYS"
+	dailyRetentionEnv      = "USAGE_DAILY_RETENTION_DAYS"
+	subscriptionProductEnv = "USER_SUBSCRIPTION_PRODUCT"
+	subscriptionDirEnv     = "USER_SUBSCRIPTION_DIRECTORY_ID"
+	subscriptionDomainEnv  = "USER_SUBSCRIPTION_DOMAIN"
+	userCountProviderEnv   = "USER_COUNT_PROVIDER"
+	userCountGroupsEnv     = "USER_COUNT_GROUPS"
+	userExcludeUsersEnv    = "USER_COUNT_EXCLUDE_USERNAMES"
+	userExcludeGroupsEnv   = "USER_COUNT_EXCLUDE_GROUPS"
+	sloTargetEnv           = "SLO_TARGET"
+	sloWindowEnv           = "SLO_WINDOW_HOURS"
+	localCachePathEnv      = "LOCAL_CACHE_PATH"
+	profilingEnv           = "PROFILING_ENABLED"
+	statusMetricsAuthEnv   = "STATUS_METRICS_AUTH"
+	snapshotDirEnv         = "PROFILE_SNAPSHOT_DIR"
+	snapshotGrowthEnv      = "PROFILE_SNAPSHOT_GROWTH_PERCENT"
+	maxSamplesEnv          = "MAX_RETAINED_SAMPLES"
+	jobRetentionEnv        = "JOB_RETENTION"
+	maintenanceEnv         = "MAINTENANCE_WINDOWS"
+	awsCSP                 = "aws"
+
+	// listens on every IPv4 and IPv6 address of the pod, so that it's reachable in dual-stack and IPv6-only clusters
+	defaultStatusAddress    = ":8080"
+	defaultMockEntitlements = 5
+	// by default the checkout is renewed through 2 failed node counts, about a minute of rancher metrics being unavailable
+	defaultNodeCountFailures = 3
+	// checks aren't limited by default, a budget shorter than a slow node count only turns it into a failed one
+	defaultCheckBudget = 0
+	// cross-validated node counts may differ by a few nodes joining or leaving in between counting the sources
+	defaultNodeCountDivergence = 5
+	// tokens are extended about once an hour, so they're rotated about once a day
+	defaultMaxTokenExtensions = 24
+	// rotations are announced about 6 hours ahead, leaving room for a change window within the business day
+	defaultTokenLimitWarning = 6
+	// licenses retained after scaling down cover nodes which come back within the hour, i.e. after a rolling upgrade
+	defaultOverRetention = 60
+	// scale-downs are confirmed over 10 compliance checks before licenses are checked in
+	defaultScaleDownConfirmation = 5
+	// a checkout kept after every cluster was deleted covers clusters which are rebuilt within the hour
+	defaultZeroNodeGrace = 60
+	// License Manager usually reports a checkout's usage within seconds, two minutes leave room for its slower days
+	defaultUsageVerification = 120
+	// hourly usage records cover the true-up reviews of the last quarter, daily records two years of trends
+	defaultHourlyRetention = 90
+	defaultDailyRetention  = 730
+	// events are published under the csp-adapter subjects, or to the csp-adapter topic
+	defaultEventsTopic = "csp-adapter"
+	// a broker outage of a few hours is buffered before events are dropped
+	defaultEventsBuffer = 1000
 )
 
-func run() error {
+func run(opts runOptions) error {
 	if os.Getenv(debugEnv) == "true" {
 		logrus.SetLevel(logrus.DebugLevel)
 	}
@@ -55,7 +232,29 @@ func run() error {
 		return err
 	}
 
-	awsClient, err := aws.NewClient(ctx)
+	var awsClient aws.Client
+	var mock *aws.SyntheticClient
+	var subscriptions aws.SubscriptionClient
+	var clientOpts aws.ClientOptions
+	if opts.mockCSP {
+		logrus.Warnf("running with a synthetic license, compliance reported by the adapter does not reflect any real license")
+		mock = aws.NewSyntheticClient(opts.mockEntitlements)
+		awsClient = mock
+	} else if path := os.Getenv(providerPluginEnv); path != "" {
+		logrus.Infof("using license provider plugin %s", path)
+		awsClient, err = plugin.NewClient(ctx, path)
+	} else if path := os.Getenv(licenseFileEnv); path != "" {
+		logrus.Infof("using the license file %s instead of AWS License Manager, checkouts are only accounted for by the adapter", path)
+		awsClient, err = offlineClientFromEnv(path)
+	} else {
+		clientOpts, err = clientOptionsFromEnv()
+		if err == nil {
+			awsClient, err = aws.NewClient(ctx, clientOpts)
+			if err == nil {
+				subscriptions, err = subscriptionsFromEnv(ctx, clientOpts)
+			}
+		}
+	}
 	if err != nil {
 		registerErr := registerStartupError(k8sClients, createCSPInfo(awsCSP, "unknown"), err)
 		if registerErr != nil {
@@ -64,6 +263,46 @@ func run() error {
 		return fmt.Errorf("failed to start, unable to start aws client: %v", err)
 	}
 
+	tracker, err := sloTrackerFromEnv()
+	if err != nil {
+		return err
+	}
+	metrics.Register(tracker)
+	awsClient = slo.NewClient(awsClient, tracker)
+
+	// costs of the integrations' AWS resources are estimated from the requests their clients make
+	estimator := costs.NewEstimator(costs.DefaultPrices)
+	auditSink, closeAudit, err := auditSinkFromEnv(ctx, clientOpts, estimator)
+	if err != nil {
+		return err
+	}
+	defer closeAudit()
+	firehose, err := firehoseFromEnv(ctx, clientOpts, estimator)
+	if err != nil {
+		return err
+	}
+	if firehose != nil {
+		supervisor.Go(ctx, "event firehose", firehose.Run)
+		// closed outside of the supervised Run, so that a restarted Run still has a publisher
+		defer firehose.Close()
+		// license activity reaches the firehose through the audit client
+		if auditSink != nil {
+			auditSink = audit.MultiSink{auditSink, firehose}
+		} else {
+			auditSink = firehose
+		}
+	}
+	if auditSink != nil {
+		awsClient = audit.NewClient(awsClient, auditSink)
+	}
+	reportSinks, err := reportDispatcherFromEnv(ctx, clientOpts, estimator)
+	if err != nil {
+		return err
+	}
+	if reportSinks != nil {
+		reportSinks.Start(ctx)
+	}
+
 	hostname, err := k8sClients.GetRancherHostname()
 	if err != nil {
 		registerErr := registerStartupError(k8sClients, createCSPInfo(awsCSP, awsClient.AccountNumber()), err)
@@ -73,21 +312,908 @@ func run() error {
 		return fmt.Errorf("failed to start, unable to get hostname: %v", err)
 	}
 
-	m := manager.NewAWS(awsClient, k8sClients, metrics.NewScraper(hostname, cfg))
+	minimumLicenses, err := intFromEnv(minimumLicensesEnv, 0)
+	if err != nil {
+		return err
+	}
+	declaredNodes, err := intFromEnv(declaredNodesEnv, 0)
+	if err != nil {
+		return err
+	}
+	nodeCountFailures, err := intFromEnv(nodeCountFailuresEnv, defaultNodeCountFailures)
+	if err != nil {
+		return err
+	}
+	checkBudget, err := intFromEnv(checkBudgetEnv, defaultCheckBudget)
+	if err != nil {
+		return err
+	}
+	// per-cluster state is kept in memory unless a local cache is configured
+	var nodeCountCache metrics.NodeCountCache
+	var summaryCache manager.SummaryCache
+	var usageCache *localcache.SQLite
+	if path := os.Getenv(localCachePathEnv); path != "" {
+		localCache, err := localcache.Open(path)
+		if err != nil {
+			return err
+		}
+		defer localCache.Close()
+		logrus.Infof("caching per-cluster state and usage samples in %s", path)
+		nodeCountCache, summaryCache, usageCache = localCache, localCache, localCache
+	}
+	scraper, err := scraperFromEnv(k8sClients, hostname, cfg, nodeCountCache)
+	if err != nil {
+		return err
+	}
+	pushTTL, err := intFromEnv(nodeCountPushTTLEnv, 0)
+	if err != nil {
+		return err
+	}
+	// pushed counts are used while fresh, the configured source is the fallback
+	var pushed *metrics.PushScraper
+	if pushTTL > 0 {
+		pushed = metrics.NewPushScraper(scraper, time.Duration(pushTTL)*time.Second)
+		scraper = pushed
+	}
+	maintenance, err := maintenanceFromEnv()
+	if err != nil {
+		return err
+	}
+	sched, err := scheduleFromEnv()
+	if err != nil {
+		return err
+	}
+	location, err := reportingLocation()
+	if err != nil {
+		return err
+	}
+	strictTimeout, err := intFromEnv(strictTimeoutEnv, 0)
+	if err != nil {
+		return err
+	}
+	maxTokenExtensions, err := intFromEnv(maxTokenExtensionsEnv, defaultMaxTokenExtensions)
+	if err != nil {
+		return err
+	}
+	tokenLimitWarning, err := intFromEnv(tokenLimitWarningEnv, defaultTokenLimitWarning)
+	if err != nil {
+		return err
+	}
+	overAllocationMode := os.Getenv(overAllocationEnv)
+	switch overAllocationMode {
+	case "", sdk.OverAllocationCheckIn, sdk.OverAllocationRetain, sdk.OverAllocationExpire:
+	default:
+		return fmt.Errorf("invalid %s %q, must be one of %s, %s or %s", overAllocationEnv, overAllocationMode,
+			sdk.OverAllocationCheckIn, sdk.OverAllocationRetain, sdk.OverAllocationExpire)
+	}
+	overAllocationRetention, err := intFromEnv(overRetentionEnv, defaultOverRetention)
+	if err != nil {
+		return err
+	}
+	scaleDownConfirmation, err := intFromEnv(scaleDownConfirmEnv, defaultScaleDownConfirmation)
+	if err != nil {
+		return err
+	}
+	zeroNodeMode := os.Getenv(zeroNodeModeEnv)
+	switch zeroNodeMode {
+	case "", sdk.ZeroNodesScaleDown, sdk.ZeroNodesHoldMinimum, sdk.ZeroNodesCheckIn, sdk.ZeroNodesGrace:
+	default:
+		return fmt.Errorf("invalid %s %q, must be one of %s, %s, %s or %s", zeroNodeModeEnv, zeroNodeMode,
+			sdk.ZeroNodesScaleDown, sdk.ZeroNodesHoldMinimum, sdk.ZeroNodesCheckIn, sdk.ZeroNodesGrace)
+	}
+	zeroNodeGrace, err := intFromEnv(zeroNodeGraceEnv, defaultZeroNodeGrace)
+	if err != nil {
+		return err
+	}
+	usageVerificationWindow, err := intFromEnv(usageVerificationEnv, defaultUsageVerification)
+	if err != nil {
+		return err
+	}
+	nonProductionRatio := 1.0
+	if ratio := os.Getenv(nonProductionRatioEnv); ratio != "" {
+		nonProductionRatio, err = strconv.ParseFloat(ratio, 64)
+		if err != nil || nonProductionRatio < 0 || nonProductionRatio > 1 {
+			return fmt.Errorf("%s must be a ratio between 0 and 1, got %q", nonProductionRatioEnv, ratio)
+		}
+	}
+	maxSamples, err := intFromEnv(maxSamplesEnv, manager.DefaultMaxSamples)
+	if err != nil {
+		return err
+	}
+	if maxSamples < 1 {
+		return fmt.Errorf("%s must be at least 1, got %d", maxSamplesEnv, maxSamples)
+	}
+	hourlyRetention, err := intFromEnv(hourlyRetentionEnv, defaultHourlyRetention)
+	if err != nil {
+		return err
+	}
+	dailyRetention, err := intFromEnv(dailyRetentionEnv, defaultDailyRetention)
+	if err != nil {
+		return err
+	}
+	if hourlyRetention == 0 || dailyRetention < hourlyRetention {
+		return fmt.Errorf("%s must be at least 1 and %s at least %s, got %d and %d", hourlyRetentionEnv, dailyRetentionEnv,
+			hourlyRetentionEnv, hourlyRetention, dailyRetention)
+	}
+	usageRetention := usage.Retention{
+		Hourly: time.Duration(hourlyRetention) * 24 * time.Hour,
+		Daily:  time.Duration(dailyRetention) * 24 * time.Hour,
+	}
+	var shadow manager.Planner
+	if name := os.Getenv(shadowPlannerEnv); name != "" {
+		var ok bool
+		if shadow, ok = manager.Planners[name]; !ok {
+			return fmt.Errorf("invalid %s %q, unknown planner", shadowPlannerEnv, name)
+		}
+	}
+	// outputs which can't be written while the kubernetes api is briefly unavailable are retried in the background
+	outputs := k8s.NewBufferedClient(k8sClients, k8s.DefaultBufferOptions)
+	var usageStore usage.Store
+	if usageCache != nil {
+		usageStore = usageCache.UsageHistory(outputs)
+	}
+	supervisor.Go(ctx, "output writer", outputs.Run)
+	// the pod name, which survives container restarts but differs between the pods of a rolling update or a second release
+	instanceID, _ := os.Hostname()
+	m := manager.NewAWS(awsClient, outputs, scraper, manager.Options{
+		PublishClusterSummaries:   os.Getenv(clusterSummariesEnv) == "true",
+		Summaries:                 summaryCache,
+		UsageStore:                usageStore,
+		PublishClusterCondition:   os.Getenv(clusterConditionEnv) == "true",
+		MinimumLicenses:           minimumLicenses,
+		DeclaredNodes:             declaredNodes,
+		NodeCountFailureThreshold: nodeCountFailures,
+		CheckBudget:               time.Duration(checkBudget) * time.Second,
+		Schedule:                  sched,
+		Location:                  location,
+		StrictTimeout:             time.Duration(strictTimeout) * time.Second,
+		MaxTokenExtensions:        maxTokenExtensions,
+		TokenLimitWarning:         tokenLimitWarning,
+		OverAllocationMode:        overAllocationMode,
+		OverAllocationRetention:   time.Duration(overAllocationRetention) * time.Minute,
+		ScaleDownConfirmation:     time.Duration(scaleDownConfirmation) * time.Minute,
+		ZeroNodeMode:              zeroNodeMode,
+		ZeroNodeGracePeriod:       time.Duration(zeroNodeGrace) * time.Minute,
+		UsageVerificationWindow:   time.Duration(usageVerificationWindow) * time.Second,
+		ClassifyEnvironments:      os.Getenv(environmentsEnv) == "true",
+		NonProductionRatio:        nonProductionRatio,
+		Shadow:                    shadow,
+		UsageRetention:            usageRetention,
+		MaxSamples:                maxSamples,
+		Maintenance:               maintenance,
+		Subscriptions:             subscriptions,
+		UserCounter:               userCounterFromEnv(outputs),
+		SLO:                       tracker,
+		InstanceID:                instanceID,
+		Audit:                     auditSink,
+		Events:                    firehose,
+		Reports:                   reportSinks,
+		Costs:                     estimator,
+		// self subject access reviews are allowed for every service account, so the check needs no permissions itself
+		Permissions: k8s.AccessChecker{
+			Reviews: k8sClients.AccessReviews,
+			Required: k8s.RequiredPermissions(k8s.PermissionOptions{
+				ClusterSummaries: os.Getenv(clusterSummariesEnv) == "true",
+				ClusterCondition: os.Getenv(clusterConditionEnv) == "true",
+				Users:            subscriptions != nil,
+				TokenReviews:     os.Getenv(statusTokenAuthEnv) == "true",
+			}),
+		},
+	})
 
 	errs := make(chan error, 1)
 	m.Start(ctx, errs)
+	supervisor.Go(ctx, "force reconcile watch", func(ctx context.Context) {
+		k8sClients.WatchForceReconcile(ctx, func(string) { m.TriggerCheck() })
+	})
+	supervisor.Go(ctx, "pause watch", func(ctx context.Context) {
+		k8sClients.WatchPause(ctx, func(reason string) {
+			m.Pause(k8s.PauseAnnotation+" annotation", reason)
+		}, func() {
+			m.Resume(k8s.PauseAnnotation + " annotation")
+		})
+	})
 	go func() {
 		for err := range errs {
 			logrus.Errorf("aws manager error: %v", err)
 		}
 	}()
 
+	jobOpts := jobs.DefaultOptions
+	if jobOpts.Retention, err = intFromEnv(jobRetentionEnv, jobs.DefaultOptions.Retention); err != nil {
+		return err
+	}
+	jobRunner := jobs.NewRunner(jobOpts)
+	supervisor.Go(ctx, "job runner", jobRunner.Run)
+
+	serverOpts, err := serverOptionsFromEnv(k8sClients)
+	if err != nil {
+		return err
+	}
+	if serverOpts.StatusPage, err = statusPageFromEnv(ctx, clientOpts, estimator); err != nil {
+		return err
+	}
+	serverOpts.Jobs = jobRunner
+	serverOpts.Operations = m
+	serverOpts.Pauser = m
+	serverOpts.SyntheticTests = m
+	serverOpts.Maintenance = m
+	serverOpts.Catalog = m
+	serverOpts.Entitlements = m
+	serverOpts.Inventory = m
+	if pushed != nil {
+		serverOpts.NodeCounts = pushed
+	}
+	snapshots, err := snapshotWatcherFromEnv()
+	if err != nil {
+		return err
+	}
+	if snapshots != nil {
+		supervisor.Go(ctx, "profile snapshots", snapshots.Run)
+		serverOpts.Snapshots = snapshots
+	}
+	if mock != nil {
+		serverOpts.Mock = mock
+	}
+	serverErrs := make(chan error, 1)
+	server.New(serverOpts, m).Start(ctx, serverErrs)
+	go func() {
+		for err := range serverErrs {
+			logrus.Errorf("status server error: %v", err)
+		}
+	}()
+
 	<-ctx.Done()
 
 	return nil
 }
 
+// applyConfigFiles loads the config files listed in CONFIG_FILES with the overlays of CONFIG_ENVIRONMENT, and sets the
+// settings which aren't already set in the environment, so that the rest of the adapter reads them like any other env.
+// If CONFIG_FILES_OVERRIDE_ENV is true, every setting of the files is set, overriding the environment
+func applyConfigFiles() error {
+	paths := splitEnvList(os.Getenv(configFilesEnv))
+	if len(paths) == 0 {
+		return nil
+	}
+	cfg, err := settings.Load(paths, os.Getenv(configEnvironmentEnv), configLookupEnv())
+	if err != nil {
+		return err
+	}
+	logrus.Infof("loaded %d setting(s) from %s for environment %q", len(cfg.Settings()), strings.Join(paths, ", "), os.Getenv(configEnvironmentEnv))
+	return cfg.Apply(os.Setenv)
+}
+
+// configLookupEnv returns the lookup of the environment variables which override the config files, nil if the files
+// override the environment (CONFIG_FILES_OVERRIDE_ENV), i.e. the settings the chart sets from its values
+func configLookupEnv() func(string) (string, bool) {
+	if os.Getenv(configOverrideEnvEnv) == "true" {
+		return nil
+	}
+	return os.LookupEnv
+}
+
+// serverOptionsFromEnv configures the listen address, tls, trusted proxies and authentication of the status server from
+// the env
+func serverOptionsFromEnv(clients *k8s.Clients) (server.Options, error) {
+	proxies, err := server.ParseTrustedProxies(splitEnvList(os.Getenv(statusTrustedProxyEnv)))
+	if err != nil {
+		return server.Options{}, fmt.Errorf("invalid %s: %w", statusTrustedProxyEnv, err)
+	}
+	opts := server.Options{
+		Addrs:          splitEnvList(os.Getenv(statusAddressEnv)),
+		TLSCertFile:    os.Getenv(statusTLSCertEnv),
+		TLSKeyFile:     os.Getenv(statusTLSKeyEnv),
+		ClientCAFile:   os.Getenv(statusClientCAEnv),
+		TrustedProxies: proxies,
+		Profiling:      os.Getenv(profilingEnv) == "true",
+		// metrics stay readable by unauthenticated scrapers unless requested
+		AuthenticateMetrics: os.Getenv(statusMetricsAuthEnv) == "true",
+	}
+	if len(opts.Addrs) == 0 {
+		opts.Addrs = []string{defaultStatusAddress}
+	}
+	var authenticators server.AnyAuthenticator
+	if opts.ClientCAFile != "" {
+		authenticators = append(authenticators, server.ClientCertAuthenticator{})
+	}
+	if os.Getenv(statusTokenAuthEnv) == "true" {
+		authenticators = append(authenticators, &server.TokenReviewAuthenticator{
+			TokenReviews:  clients.TokenReviews,
+			AllowedUsers:  splitEnvList(os.Getenv(statusAllowedUsersEnv)),
+			AllowedGroups: splitEnvList(os.Getenv(statusAllowedGroupsEnv)),
+		})
+	}
+	if header := os.Getenv(statusProxyUserEnv); header != "" {
+		if len(proxies) == 0 {
+			return server.Options{}, fmt.Errorf("%s requires %s to be set", statusProxyUserEnv, statusTrustedProxyEnv)
+		}
+		authenticators = append(authenticators, server.ProxyHeaderAuthenticator{Header: header})
+	}
+	if len(authenticators) > 0 {
+		opts.Authenticator = authenticators
+	}
+	return opts, nil
+}
+
+// statusPageFromEnv configures the html status page served to users signed in with the OpenID Connect provider at
+// STATUS_PAGE_OIDC_ISSUER_URL. The client secret is a secret reference (see secrets.Parse). Sessions are signed with a
+// key generated on startup, so users sign in again after the adapter restarted. Returns nil if STATUS_PAGE_URL isn't set
+func statusPageFromEnv(ctx context.Context, clientOpts aws.ClientOptions, estimator *costs.Estimator) (*server.StatusPageOptions, error) {
+	pageURL := os.Getenv(statusPageURLEnv)
+	if pageURL == "" {
+		return nil, nil
+	}
+	var clientSecret secrets.Provider
+	if ref := os.Getenv(statusPageSecretEnv); ref != "" {
+		var err error
+		if clientSecret, err = secrets.Parse(ref, secretsClientFactory(ctx, clientOpts, estimator)); err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", statusPageSecretEnv, err)
+		}
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("unable to generate the status page's session key: %v", err)
+	}
+	opts := &server.StatusPageOptions{
+		IssuerURL:     os.Getenv(statusPageIssuerEnv),
+		ClientID:      os.Getenv(statusPageClientIDEnv),
+		ClientSecret:  clientSecret,
+		URL:           pageURL,
+		AllowedUsers:  splitEnvList(os.Getenv(statusPageUsersEnv)),
+		AllowedGroups: splitEnvList(os.Getenv(statusPageGroupsEnv)),
+		UsernameClaim: os.Getenv(statusPageUserClaimEnv),
+		GroupsClaim:   os.Getenv(statusPageGrpClaimEnv),
+		SessionKey:    key,
+	}
+	if err := opts.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid status page settings: %v", err)
+	}
+	logrus.Infof("serving the status page on %s%s to users signed in with %s", strings.TrimSuffix(pageURL, "/"), "/ui/status", opts.IssuerURL)
+	return opts, nil
+}
+
+// offlineClientFromEnv returns the client of the license file at path. LICENSE_PUBLIC_KEY is the PEM encoded key the
+// signed license artifact in the file is verified with, without it the file is trusted as it is. Consumption tokens
+// are signed with the keys in SIGNING_KEYS_DIR if it's set, so that they're held again after a restart
+func offlineClientFromEnv(path string) (aws.Client, error) {
+	opts := aws.OfflineOptions{DimensionAliases: splitEnvList(os.Getenv(awsDimensionAliasesEnv))}
+	if key := os.Getenv(licensePublicKeyEnv); key != "" {
+		publicKey, err := aws.ParsePublicKey([]byte(key))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", licensePublicKeyEnv, err)
+		}
+		opts.PublicKey = publicKey
+	} else {
+		logrus.Warnf("%s isn't set, the license file is trusted without verifying its signature", licensePublicKeyEnv)
+	}
+	if dir := os.Getenv(signingKeysDirEnv); dir != "" {
+		opts.TokenKeys = signing.NewDirectory(dir)
+	}
+	client, err := aws.NewOfflineClient(path, opts)
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// auditSinkFromEnv configures where audit events for license activity are sent. AUDIT_LOG is either stdout or the path
+// of a file events are appended to, chained with hashes so that the log can be verified. A file continues the chain of
+// its last entry. The chain is hashed with the keys in SIGNING_KEYS_DIR if it's set. The webhook url and authorization are secret references (see secrets.Parse), so that
+// they can be kept in an external secret store. Webhook requests are signed with the keys in SIGNING_KEYS_DIR if it's
+// set. Returns nil if auditing isn't enabled. The returned function closes the audit log file, it's returned even if
+// there is none
+func auditSinkFromEnv(ctx context.Context, clientOpts aws.ClientOptions, estimator *costs.Estimator) (audit.Sink, func() error, error) {
+	var sinks audit.MultiSink
+	closeSink := func() error { return nil }
+	var keys signing.Source
+	if dir := os.Getenv(signingKeysDirEnv); dir != "" {
+		keys = signing.NewDirectory(dir)
+	}
+	newChainSink := func(sink audit.Sink, last string) *audit.ChainSink {
+		chain := audit.NewChainSink(sink, last)
+		if keys != nil {
+			chain.WithKeys(keys)
+		}
+		return chain
+	}
+	switch path := os.Getenv(auditLogEnv); path {
+	case "":
+	case "stdout":
+		sinks = append(sinks, newChainSink(audit.NewWriterSink(os.Stdout), ""))
+	default:
+		last, err := audit.LastHash(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to continue the audit log chain: %v", err)
+		}
+		file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to open audit log: %v", err)
+		}
+		closeSink = file.Close
+		sinks = append(sinks, newChainSink(audit.NewWriterSink(file), last))
+	}
+	if ref := os.Getenv(auditWebhookEnv); ref != "" {
+		newClient := secretsClientFactory(ctx, clientOpts, estimator)
+		url, err := secrets.Parse(ref, newClient)
+		if err != nil {
+			closeSink()
+			return nil, nil, fmt.Errorf("invalid %s: %v", auditWebhookEnv, err)
+		}
+		authorization, err := webhookAuthorizationFromEnv(auditWebhookAuthEnv, newClient)
+		if err != nil {
+			closeSink()
+			return nil, nil, err
+		}
+		webhook := audit.NewWebhookSink(url, authorization)
+		if keys != nil {
+			webhook.WithSigning(keys)
+		}
+		sinks = append(sinks, webhook)
+	}
+	if len(sinks) == 0 {
+		return nil, closeSink, nil
+	}
+	return sinks, closeSink, nil
+}
+
+// webhookAuthorizationFromEnv returns the Authorization header value of a webhook, read from the secret reference (see
+// secrets.Parse) in env. Returns nil if env isn't set, the webhook is then called without one
+func webhookAuthorizationFromEnv(env string, newClient func() (aws.SecretsClient, error)) (secrets.Provider, error) {
+	ref := os.Getenv(env)
+	if ref == "" {
+		return nil, nil
+	}
+	authorization, err := secrets.Parse(ref, newClient)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", env, err)
+	}
+	return authorization, nil
+}
+
+// secretsClientFactory returns the function creating the secrets manager client of secret references, whose requests
+// are counted in estimator
+func secretsClientFactory(ctx context.Context, clientOpts aws.ClientOptions, estimator *costs.Estimator) func() (aws.SecretsClient, error) {
+	return func() (aws.SecretsClient, error) {
+		client, err := aws.NewSecretsClient(ctx, clientOpts)
+		if err != nil {
+			return nil, err
+		}
+		return costs.NewSecretsClient(client, estimator), nil
+	}
+}
+
+// defaultReportS3Key is the object the compliance report is stored as in REPORT_S3_BUCKET, without the extension of
+// its format
+const defaultReportS3Key = "rancher-csp-adapter/compliance-report"
+
+// reportDispatcherFromEnv configures the sinks the compliance report is written to besides the configmap: the webhook
+// at REPORT_WEBHOOK_URL and the bucket REPORT_S3_BUCKET, in the formats REPORT_WEBHOOK_FORMAT and REPORT_S3_FORMAT
+// (json by default). Like the audit webhook, the webhook url and authorization are secret references and requests are
+// signed with the keys in SIGNING_KEYS_DIR if it's set. Returns nil if neither is set
+func reportDispatcherFromEnv(ctx context.Context, clientOpts aws.ClientOptions, estimator *costs.Estimator) (*reports.Dispatcher, error) {
+	var sinks []reports.Sink
+	if ref := os.Getenv(reportWebhookEnv); ref != "" {
+		newClient := secretsClientFactory(ctx, clientOpts, estimator)
+		url, err := secrets.Parse(ref, newClient)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", reportWebhookEnv, err)
+		}
+		authorization, err := webhookAuthorizationFromEnv(reportWebhookAuthEnv, newClient)
+		if err != nil {
+			return nil, err
+		}
+		renderer, err := reports.RendererFor(os.Getenv(reportWebhookFormatEnv))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", reportWebhookFormatEnv, err)
+		}
+		webhook := reports.NewWebhookSink(url, authorization).WithRenderer(renderer)
+		if dir := os.Getenv(signingKeysDirEnv); dir != "" {
+			webhook.WithSigning(signing.NewDirectory(dir))
+		}
+		sinks = append(sinks, webhook)
+	}
+	if bucket := os.Getenv(reportS3BucketEnv); bucket != "" {
+		format := os.Getenv(reportS3FormatEnv)
+		renderer, err := reports.RendererFor(format)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %v", reportS3FormatEnv, err)
+		}
+		key := os.Getenv(reportS3KeyEnv)
+		if key == "" {
+			if format == "" {
+				format = reports.FormatJSON
+			}
+			key = defaultReportS3Key + "." + format
+		}
+		client, err := aws.NewBucketClient(ctx, clientOpts, bucket)
+		if err != nil {
+			return nil, fmt.Errorf("unable to create the client of report bucket %s: %v", bucket, err)
+		}
+		sinks = append(sinks, reports.NewS3Sink(costs.NewBucketClient(client, estimator), key).WithRenderer(renderer))
+	}
+	if len(sinks) == 0 {
+		return nil, nil
+	}
+	return reports.NewDispatcher(reports.DefaultOptions, sinks...), nil
+}
+
+// firehoseFromEnv configures the event firehose, publishing every event of the adapter to the NATS servers at
+// EVENTS_NATS_URL or to the Kafka brokers in EVENTS_KAFKA_BROKERS. The NATS url, NATS credentials and Kafka password are
+// secret references (see secrets.Parse), since they hold credentials. Returns nil if neither is set
+func firehoseFromEnv(ctx context.Context, clientOpts aws.ClientOptions, estimator *costs.Estimator) (*events.Firehose, error) {
+	natsRef, brokers := os.Getenv(eventsNATSURLEnv), splitEnvList(os.Getenv(eventsKafkaBrokersEnv))
+	if natsRef == "" && len(brokers) == 0 {
+		return nil, nil
+	}
+	if natsRef != "" && len(brokers) > 0 {
+		return nil, fmt.Errorf("only one of %s and %s can be set", eventsNATSURLEnv, eventsKafkaBrokersEnv)
+	}
+	topic := os.Getenv(eventsTopicEnv)
+	if topic == "" {
+		topic = defaultEventsTopic
+	}
+	buffer, err := intFromEnv(eventsBufferEnv, defaultEventsBuffer)
+	if err != nil {
+		return nil, err
+	}
+	if buffer < 1 {
+		return nil, fmt.Errorf("%s must be at least 1, got %d", eventsBufferEnv, buffer)
+	}
+	tlsConfig, err := eventsTLSFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	newSecretsClient := secretsClientFactory(ctx, clientOpts, estimator)
+	if len(brokers) > 0 {
+		opts := events.KafkaOptions{TLS: tlsConfig}
+		if mechanism := os.Getenv(eventsKafkaSASLEnv); mechanism != "" {
+			password, err := secretFromEnv(ctx, eventsKafkaPasswordEnv, newSecretsClient)
+			if err != nil {
+				return nil, err
+			}
+			if opts.SASL, err = events.KafkaSASL(mechanism, os.Getenv(eventsKafkaUserEnv), password); err != nil {
+				return nil, fmt.Errorf("invalid %s: %v", eventsKafkaSASLEnv, err)
+			}
+		}
+		logrus.Infof("publishing events to the %s topic of kafka brokers %s", topic, strings.Join(brokers, ", "))
+		return events.NewFirehose(events.NewKafkaPublisher(brokers, topic, opts), buffer), nil
+	}
+	url, err := secretFromEnv(ctx, eventsNATSURLEnv, newSecretsClient)
+	if err != nil {
+		return nil, err
+	}
+	opts := events.NATSOptions{TLS: tlsConfig}
+	if os.Getenv(eventsNATSCredsEnv) != "" {
+		creds, err := secretFromEnv(ctx, eventsNATSCredsEnv, newSecretsClient)
+		if err != nil {
+			return nil, err
+		}
+		opts.Credentials = []byte(creds)
+	}
+	publisher, err := events.NewNATSPublisher(url, topic, opts)
+	if err != nil {
+		return nil, err
+	}
+	logrus.Infof("publishing events to NATS subjects %s.>", topic)
+	return events.NewFirehose(publisher, buffer), nil
+}
+
+// secretFromEnv resolves the secret reference in env, an empty value if env isn't set
+func secretFromEnv(ctx context.Context, env string, newClient func() (aws.SecretsClient, error)) (string, error) {
+	if os.Getenv(env) == "" {
+		return "", nil
+	}
+	ref, err := secrets.Parse(os.Getenv(env), newClient)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s: %v", env, err)
+	}
+	value, err := ref.Value(ctx)
+	if err != nil {
+		return "", fmt.Errorf("unable to resolve %s: %v", env, err)
+	}
+	return value, nil
+}
+
+// eventsTLSFromEnv returns the tls config of the connection to the event broker, nil unless EVENTS_TLS is true or
+// EVENTS_TLS_DIR is set. The broker's certificate is verified with the system roots (which include the chart's
+// additionalTrustedCAs), or with ca.crt in EVENTS_TLS_DIR if it exists. The adapter authenticates with tls.crt and
+// tls.key in EVENTS_TLS_DIR if they exist
+func eventsTLSFromEnv() (*tls.Config, error) {
+	dir := os.Getenv(eventsTLSDirEnv)
+	if os.Getenv(eventsTLSEnv) != "true" && dir == "" {
+		return nil, nil
+	}
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if dir == "" {
+		return config, nil
+	}
+	caPEM, err := os.ReadFile(filepath.Join(dir, "ca.crt"))
+	switch {
+	case err == nil:
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in %s", filepath.Join(dir, "ca.crt"))
+		}
+	case !os.IsNotExist(err):
+		return nil, fmt.Errorf("unable to read the event broker ca: %v", err)
+	}
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	if _, err := os.Stat(certFile); err == nil {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load the event broker client certificate: %v", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// scraperFromEnv returns the scraper for the configured node count source: rancher's metrics (the default), or the
+// rancher api, counting each downstream cluster separately. If a validation source is configured, both are counted
+// every time and compared
+func scraperFromEnv(k8sClients *k8s.Clients, hostname string, cfg *rest.Config, cache metrics.NodeCountCache) (metrics.Scraper, error) {
+	source := os.Getenv(nodeCountSourceEnv)
+	if source == "" {
+		source = "metrics"
+	}
+	scraper, err := scraperForSource(nodeCountSourceEnv, source, k8sClients, hostname, cfg, cache)
+	if err != nil {
+		return nil, err
+	}
+	validationSource := os.Getenv(nodeCountValidationEnv)
+	if validationSource == "" {
+		return scraper, nil
+	}
+	if validationSource == source {
+		return nil, fmt.Errorf("%s must differ from %s %q", nodeCountValidationEnv, nodeCountSourceEnv, source)
+	}
+	validation, err := scraperForSource(nodeCountValidationEnv, validationSource, k8sClients, hostname, cfg, cache)
+	if err != nil {
+		return nil, err
+	}
+	threshold, err := intFromEnv(nodeCountDivergenceEnv, defaultNodeCountDivergence)
+	if err != nil {
+		return nil, err
+	}
+	logrus.Infof("cross-validating node counts from %s with %s", source, validationSource)
+	return metrics.NewCrossValidatingScraper(
+		metrics.Source{Name: source, Scraper: scraper},
+		metrics.Source{Name: validationSource, Scraper: validation},
+		threshold,
+	), nil
+}
+
+// scraperForSource returns the scraper for the named node count source, configured by env
+func scraperForSource(env, source string, k8sClients *k8s.Clients, hostname string, cfg *rest.Config, cache metrics.NodeCountCache) (metrics.Scraper, error) {
+	switch source {
+	case "metrics":
+		return metrics.NewScraper(hostname, cfg), nil
+	case "clusters":
+		parallelism, err := intFromEnv(nodeCountParallelEnv, metrics.DefaultClusterScraperOptions.Parallelism)
+		if err != nil {
+			return nil, err
+		}
+		timeout, err := intFromEnv(nodeCountTimeoutEnv, int(metrics.DefaultClusterScraperOptions.Timeout/time.Second))
+		if err != nil {
+			return nil, err
+		}
+		return metrics.NewClusterScraper(k8sClients, metrics.ClusterScraperOptions{
+			Parallelism: parallelism,
+			Timeout:     time.Duration(timeout) * time.Second,
+			Cache:       cache,
+		}), nil
+	default:
+		return nil, fmt.Errorf("invalid %s %q, must be metrics or clusters", env, source)
+	}
+}
+
+// subscriptionsFromEnv returns the client for the user subscriptions of the configured product licensed per user, nil
+// if user subscriptions aren't enabled
+func subscriptionsFromEnv(ctx context.Context, clientOpts aws.ClientOptions) (aws.SubscriptionClient, error) {
+	product := os.Getenv(subscriptionProductEnv)
+	if product == "" {
+		return nil, nil
+	}
+	directoryID := os.Getenv(subscriptionDirEnv)
+	if directoryID == "" {
+		return nil, fmt.Errorf("%s must be set to subscribe users to %s", subscriptionDirEnv, product)
+	}
+	logrus.Infof("subscribing rancher users to %s through directory %s", product, directoryID)
+	return aws.NewSubscriptionClient(ctx, aws.SubscriptionOptions{
+		Product:     product,
+		DirectoryID: directoryID,
+		Domain:      os.Getenv(subscriptionDomainEnv),
+	}, clientOpts)
+}
+
+// userCounterFromEnv returns the counter of the users subscribed to products licensed per user. By default every
+// enabled user logging in through USER_COUNT_PROVIDER (or the auth providers enabled in rancher if unset) is counted,
+// or only the members of USER_COUNT_GROUPS if set.
+// Group principal ids contain commas, so group lists are separated by semicolons
+func userCounterFromEnv(client k8s.Client) identity.UserCounter {
+	provider := os.Getenv(userCountProviderEnv)
+	var counter identity.UserCounter = identity.RancherUsers{Client: client, Provider: provider}
+	if groups := splitGroupList(os.Getenv(userCountGroupsEnv)); len(groups) > 0 {
+		counter = identity.GroupMembers{Client: client, Provider: provider, Groups: groups}
+	}
+	return identity.NewCounter(identity.Exclusions{
+		Usernames: splitEnvList(os.Getenv(userExcludeUsersEnv)),
+		Groups:    splitGroupList(os.Getenv(userExcludeGroupsEnv)),
+	}, counter)
+}
+
+// splitGroupList splits a semicolon separated list of group principal ids, dropping empty entries
+func splitGroupList(value string) []string {
+	var groups []string
+	for _, group := range strings.Split(value, ";") {
+		if group = strings.TrimSpace(group); group != "" {
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
+
+// sloTrackerFromEnv returns the tracker of License Manager operations, against SLO_TARGET (i.e. 0.99) over the last
+// SLO_WINDOW_HOURS
+func sloTrackerFromEnv() (*slo.Tracker, error) {
+	opts := slo.DefaultOptions
+	if target := os.Getenv(sloTargetEnv); target != "" {
+		parsed, err := strconv.ParseFloat(target, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a ratio, got %q", sloTargetEnv, target)
+		}
+		opts.Target = parsed
+	}
+	window, err := intFromEnv(sloWindowEnv, int(opts.BudgetWindow/time.Hour))
+	if err != nil {
+		return nil, err
+	}
+	opts.BudgetWindow = time.Duration(window) * time.Hour
+	return slo.NewTracker(opts)
+}
+
+// snapshotWatcherFromEnv returns the watcher capturing profiles in PROFILE_SNAPSHOT_DIR once the heap grew by
+// PROFILE_SNAPSHOT_GROWTH_PERCENT, nil if no directory is set
+func snapshotWatcherFromEnv() (*profiling.Watcher, error) {
+	dir := os.Getenv(snapshotDirEnv)
+	if dir == "" {
+		return nil, nil
+	}
+	opts := profiling.DefaultOptions
+	opts.Dir = dir
+	growth, err := intFromEnv(snapshotGrowthEnv, int((opts.Growth-1)*100))
+	if err != nil {
+		return nil, err
+	}
+	opts.Growth = 1 + float64(growth)/100
+	return profiling.NewWatcher(opts)
+}
+
+// scheduleFromEnv returns the schedule which full compliance checks run on, nil if they run on every interval
+func scheduleFromEnv() (*schedule.Schedule, error) {
+	expr := os.Getenv(scheduleEnv)
+	if expr == "" {
+		return nil, nil
+	}
+	location, err := reportingLocation()
+	if err != nil {
+		return nil, err
+	}
+	if tz := os.Getenv(scheduleTimezoneEnv); tz != "" {
+		if location, err = time.LoadLocation(tz); err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", scheduleTimezoneEnv, tz, err)
+		}
+	}
+	sched, err := schedule.Parse(expr, location)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", scheduleEnv, err)
+	}
+	logrus.Infof("full compliance checks run on schedule %q (%s), checkouts are renewed in between", expr, location)
+	return sched, nil
+}
+
+// maintenanceFromEnv parses the comma separated start/end pairs of RFC3339 times in MAINTENANCE_WINDOWS, i.e.
+// 2026-11-03T02:00:00Z/2026-11-03T06:00:00Z. Windows which already ended are ignored
+func maintenanceFromEnv() ([]sdk.MaintenanceWindow, error) {
+	var windows []sdk.MaintenanceWindow
+	for _, value := range splitEnvList(os.Getenv(maintenanceEnv)) {
+		parts := strings.SplitN(value, "/", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("%s must be a list of start/end pairs, got %q", maintenanceEnv, value)
+		}
+		start, err := time.Parse(time.RFC3339, strings.TrimSpace(parts[0]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s start %q: %w", maintenanceEnv, parts[0], err)
+		}
+		end, err := time.Parse(time.RFC3339, strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s end %q: %w", maintenanceEnv, parts[1], err)
+		}
+		if !end.After(start) {
+			return nil, fmt.Errorf("%s window %q must end after it starts", maintenanceEnv, value)
+		}
+		if end.After(time.Now()) {
+			windows = append(windows, sdk.MaintenanceWindow{Start: start, End: end})
+		}
+	}
+	return windows, nil
+}
+
+// reportingLocation returns the timezone of the customer's business day, which schedules, report boundaries and the
+// times in messages are rendered in. UTC unless REPORTING_TIMEZONE is set
+func reportingLocation() (*time.Location, error) {
+	tz := os.Getenv(reportingTimezoneEnv)
+	if tz == "" {
+		return time.UTC, nil
+	}
+	location, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %q: %w", reportingTimezoneEnv, tz, err)
+	}
+	return location, nil
+}
+
+// intFromEnv parses the non-negative integer in env, returning defaultValue if env is unset
+func intFromEnv(env string, defaultValue int) (int, error) {
+	value := os.Getenv(env)
+	if value == "" {
+		return defaultValue, nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative integer, got %q", env, value)
+	}
+	return parsed, nil
+}
+
+// clientOptionsFromEnv returns the options of the aws client configured by the env, which the chart sets for the
+// adapter and for the jobs running its commands
+func clientOptionsFromEnv() (aws.ClientOptions, error) {
+	licenseTags, err := tagsFromEnv(awsLicenseTagsEnv)
+	if err != nil {
+		return aws.ClientOptions{}, err
+	}
+	return aws.ClientOptions{
+		AutoSwitchRegion: os.Getenv(awsAutoSwitchRegionEnv) == "true",
+		Beneficiary:      os.Getenv(awsBeneficiaryEnv),
+		WriteRoleARN:     os.Getenv(awsWriteRoleARNEnv),
+		RecordCassette:   os.Getenv(awsRecordCassetteEnv),
+		LicenseTags:      licenseTags,
+		DualStack:        os.Getenv(awsDualStackEnv) == "true",
+		STSRegion:        os.Getenv(awsSTSRegionEnv),
+		DimensionAliases: splitEnvList(os.Getenv(awsDimensionAliasesEnv)),
+	}, nil
+}
+
+// tagsFromEnv parses the comma separated key=value pairs in env, returning nil if env is unset
+func tagsFromEnv(env string) (map[string]string, error) {
+	pairs := splitEnvList(os.Getenv(env))
+	if len(pairs) == 0 {
+		return nil, nil
+	}
+	tags := map[string]string{}
+	for _, pair := range pairs {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("%s must be a list of key=value pairs, got %q", env, pair)
+		}
+		tags[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return tags, nil
+}
+
+// splitEnvList splits a comma separated env value, dropping empty entries
+func splitEnvList(value string) []string {
+	var values []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
 // createCSPInfo creates a manager.CSPInfo from a provided csp name and account number
 func createCSPInfo(csp, acctNumber string) manager.CSPInfo {
 	return manager.CSPInfo{
@@ -103,6 +1229,7 @@ func registerStartupError(clients *k8s.Clients, cspInfo manager.CSPInfo, startup
 	defaultConfig := manager.GetDefaultSupportConfig(clients)
 	defaultConfig.Compliance = manager.ComplianceInfo{
 		Status:  manager.StatusNotInCompliance,
+		Reason:  sdk.ReasonError,
 		Message: fmt.Sprintf("CSP adapter unable to start due to error: %v", startupErr),
 	}
 	defaultConfig.CSP = cspInfo
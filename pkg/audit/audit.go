@@ -0,0 +1,230 @@
+// Package audit emits structured events for license activity, so that checkouts, check-ins and extensions appear in
+// the same audit pipeline (i.e. a SIEM) as other administrative actions
+package audit
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rancher/csp-adapter/pkg/secrets"
+	"github.com/rancher/csp-adapter/pkg/signing"
+	"github.com/sirupsen/logrus"
+)
+
+// Actions which are audited
+const (
+	ActionCheckout = "checkout"
+	ActionCheckIn  = "checkin"
+	ActionExtend   = "extend"
+	// ActionRecover is the recovery of a checkout which succeeded but wasn't recorded, i.e. because the adapter crashed
+	ActionRecover = "recover"
+	// ActionSwitchover is the move of the checkout to the grant which replaced its license when it was renewed
+	ActionSwitchover = "switchover"
+)
+
+// Decisions taken when recovering a checkout
+const (
+	// DecisionAdopted means that the recovered checkout is held by the adapter from now on
+	DecisionAdopted = "adopted"
+	// DecisionCheckedIn means that the recovered checkout was checked in, since the adapter already holds another
+	DecisionCheckedIn = "checkedIn"
+	// DecisionDiscarded means that the checkout never took effect, so there was nothing to recover
+	DecisionDiscarded = "discarded"
+)
+
+// Outcomes of an audited action
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// source identifies the adapter as the origin of events in a shared audit pipeline
+const source = "rancher-csp-adapter"
+
+// Event describes a single license action taken by the adapter
+type Event struct {
+	AuditID   string    `json:"auditID"`
+	Timestamp time.Time `json:"timestamp"`
+	Source    string    `json:"source"`
+	Account   string    `json:"account"`
+	// Action is one of the Action constants
+	Action     string `json:"action"`
+	LicenseArn string `json:"licenseArn,omitempty"`
+	// PreviousLicenseArn is the license the checkout was moved from, only set for switchovers
+	PreviousLicenseArn string `json:"previousLicenseArn,omitempty"`
+	// Entitlements is the number of entitlements checked out, only set for checkouts
+	Entitlements int `json:"entitlements,omitempty"`
+	// TokenID identifies the consumption token the action applies to without revealing it, so that a checkout can be
+	// correlated with the extensions and check-in of the same token
+	TokenID string `json:"tokenID,omitempty"`
+	// Outcome is one of the Outcome constants
+	Outcome string `json:"outcome"`
+	Error   string `json:"error,omitempty"`
+	// Decision is what was done with a recovered checkout, only set for recoveries
+	Decision string `json:"decision,omitempty"`
+	// PreviousHash and Hash chain the events of an audit log, see ChainSink. They're empty for events which aren't
+	// written to a log
+	PreviousHash string `json:"previousHash,omitempty"`
+	Hash         string `json:"hash,omitempty"`
+	// KeyID is the signing key Hash is an hmac of, empty for plain hashes
+	KeyID string `json:"keyID,omitempty"`
+}
+
+// NewEvent returns an event for action taken in account, which failed with err if it's not nil
+func NewEvent(account, action string, err error) Event {
+	event := Event{
+		AuditID:   uuid.New().String(),
+		Timestamp: time.Now().UTC(),
+		Source:    source,
+		Account:   account,
+		Action:    action,
+		Outcome:   OutcomeSuccess,
+	}
+	if err != nil {
+		event.Outcome = OutcomeFailure
+		event.Error = err.Error()
+	}
+	return event
+}
+
+// TokenID returns a stable identifier for a consumption token which can't be used in place of the token
+func TokenID(consumptionToken string) string {
+	sum := sha256.Sum256([]byte(consumptionToken))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Sink receives audit events. Emit must not block for long, since events are emitted during compliance checks
+type Sink interface {
+	Emit(ctx context.Context, event Event) error
+}
+
+// WriterSink writes each event as a line of json, the format expected by log shippers tailing audit log files
+type WriterSink struct {
+	lock sync.Mutex
+	w    io.Writer
+}
+
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: w}
+}
+
+func (s *WriterSink) Emit(ctx context.Context, event Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	_, err = s.w.Write(append(line, '\n'))
+	return err
+}
+
+// webhookTimeout limits how long a compliance check can be held up by a slow webhook
+const webhookTimeout = 5 * time.Second
+
+// SignatureHeader holds the signature of the body of signed webhook requests, formatted by signing.Signature.String
+const SignatureHeader = "X-CSP-Adapter-Signature"
+
+// WebhookSink posts each event as json to a url, i.e. the http input of a SIEM. The url and authorization are resolved
+// for every event, so that rotated credentials are used without restarting the adapter
+type WebhookSink struct {
+	url           secrets.Provider
+	authorization secrets.Provider
+	keys          signing.Source
+	cli           *http.Client
+}
+
+// NewWebhookSink returns a sink posting to url. authorization is the value of the Authorization header sent with each
+// event (i.e. "Bearer <token>" or "Splunk <token>"), nil if the url authenticates the adapter itself
+func NewWebhookSink(url, authorization secrets.Provider) *WebhookSink {
+	return &WebhookSink{
+		url:           url,
+		authorization: authorization,
+		cli:           &http.Client{Timeout: webhookTimeout},
+	}
+}
+
+// WithSigning signs the body of each event with the active key of keys, so that the receiver can verify that events
+// were sent by the adapter
+func (s *WebhookSink) WithSigning(keys signing.Source) *WebhookSink {
+	s.keys = keys
+	return s
+}
+
+func (s *WebhookSink) Emit(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	url, err := s.url.Value(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to resolve audit webhook url: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.authorization != nil {
+		authorization, err := s.authorization.Value(ctx)
+		if err != nil {
+			return fmt.Errorf("unable to resolve audit webhook authorization: %v", err)
+		}
+		req.Header.Set("Authorization", authorization)
+	}
+	if s.keys != nil {
+		keyring, err := s.keys.Keyring()
+		if err != nil {
+			return fmt.Errorf("unable to sign audit event: %v", err)
+		}
+		req.Header.Set(SignatureHeader, keyring.Sign(body).String())
+	}
+	res, err := s.cli.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusUnauthorized || res.StatusCode == http.StatusForbidden || res.StatusCode == http.StatusNotFound {
+		// the credentials were likely rotated since they were last read, the next event reads them again
+		secrets.Invalidate(s.url)
+		if s.authorization != nil {
+			secrets.Invalidate(s.authorization)
+		}
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("audit webhook responded with %v", res.StatusCode)
+	}
+	return nil
+}
+
+// MultiSink emits events to every one of its sinks
+type MultiSink []Sink
+
+func (m MultiSink) Emit(ctx context.Context, event Event) error {
+	var failed []error
+	for _, sink := range m {
+		if err := sink.Emit(ctx, event); err != nil {
+			failed = append(failed, err)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("unable to emit audit event to %d sink(s): %v", len(failed), failed)
+	}
+	return nil
+}
+
+// Emit sends event to sink, logging failures rather than failing the audited action
+func Emit(ctx context.Context, sink Sink, event Event) {
+	if err := sink.Emit(ctx, event); err != nil {
+		logrus.Warnf("[audit] unable to emit %s event %s: %v", event.Action, event.AuditID, err)
+	}
+}
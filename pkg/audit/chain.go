@@ -0,0 +1,247 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/rancher/csp-adapter/pkg/signing"
+)
+
+// hashField is the json field holding the hash of an entry, which is left out of the content it's computed over
+const hashField = "hash"
+
+// maxEntrySize bounds the lines read from an audit log, events are well below it
+const maxEntrySize = 1024 * 1024
+
+// ChainSink chains the events it emits with hashes: each event records the hash of the event emitted before it and a
+// hash of its own content, which covers the previous hash. Altering, removing or inserting an entry of the audit log
+// therefore breaks the chain at that entry, which VerifyChain detects. Plain hashes can be recomputed by anyone who
+// can write the log, events are hashed with an hmac of the signing keys instead if the sink has any
+type ChainSink struct {
+	lock sync.Mutex
+	sink Sink
+	keys signing.Source
+	// last is the hash of the last event which was emitted
+	last string
+}
+
+// NewChainSink returns a sink chaining events to sink, continuing the chain after the entry whose hash is last. Empty
+// starts a new chain
+func NewChainSink(sink Sink, last string) *ChainSink {
+	return &ChainSink{sink: sink, last: last}
+}
+
+// WithKeys hashes events with an hmac of the active signing key, so that the chain can't be rebuilt without the keys
+func (s *ChainSink) WithKeys(keys signing.Source) *ChainSink {
+	s.keys = keys
+	return s
+}
+
+func (s *ChainSink) Emit(ctx context.Context, event Event) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var keyring *signing.Keyring
+	if s.keys != nil {
+		var err error
+		if keyring, err = s.keys.Keyring(); err != nil {
+			return err
+		}
+		event.KeyID = keyring.ActiveKeyID()
+	}
+	event.PreviousHash = s.last
+	event.Hash = ""
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	content, err := entryContent(data)
+	if err != nil {
+		return err
+	}
+	hash := contentHash(content)
+	if keyring != nil {
+		hash = keyring.Sign(content).Value
+	}
+	event.Hash = hash
+	if err := s.sink.Emit(ctx, event); err != nil {
+		// the event wasn't written, the next one continues the chain after the last event which was
+		return err
+	}
+	s.last = hash
+	return nil
+}
+
+// entryContent returns the content the hash of the json entry of an event is computed over: its canonical form without
+// the hash field. The canonical form orders fields by name, so that the hash doesn't depend on the version of the
+// adapter which verifies it
+func entryContent(entry []byte) ([]byte, error) {
+	fields, err := decodeEntry(entry)
+	if err != nil {
+		return nil, err
+	}
+	delete(fields, hashField)
+	return json.Marshal(fields)
+}
+
+// contentHash returns the plain hash of the content of an entry which isn't keyed
+func contentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+func decodeEntry(entry []byte) (map[string]interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(entry))
+	// numbers are kept as written, rather than converted to floats and back
+	decoder.UseNumber()
+	var fields map[string]interface{}
+	if err := decoder.Decode(&fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// LastHash returns the hash of the last entry of the audit log at path, so that the chain is continued when the adapter
+// restarts. Returns an empty hash if the log doesn't exist yet or its last entry isn't chained
+func LastHash(path string) (string, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	var last []byte
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, maxEntrySize)
+	for scanner.Scan() {
+		if line := bytes.TrimSpace(scanner.Bytes()); len(line) > 0 {
+			last = append(last[:0], line...)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	if last == nil {
+		return "", nil
+	}
+	var event Event
+	if err := json.Unmarshal(last, &event); err != nil {
+		return "", fmt.Errorf("unable to read the last entry of audit log %s: %v", path, err)
+	}
+	return event.Hash, nil
+}
+
+// ChainResult describes a verified audit log
+type ChainResult struct {
+	// Entries is the number of chained entries which were verified
+	Entries int
+	// Unchained is the number of entries before the first chained entry, written before the log was chained
+	Unchained int
+	// StartsAfter is the previous hash of the first chained entry, empty if the chain starts in this log. It's the hash
+	// of the last entry of the previous log when logs are rotated
+	StartsAfter string
+	// Restarts is the number of chained entries after the first which start a new chain
+	Restarts int
+	// Keyed is the number of entries hashed with a signing key
+	Keyed int
+	// Anchored is true if the log holds the entry whose hash is VerifyOptions.Anchor
+	Anchored bool
+	// Last is the hash of the last entry
+	Last string
+}
+
+// VerifyOptions configure how an audit log is verified
+type VerifyOptions struct {
+	// Keyring verifies entries hashed with signing keys. Logs with keyed entries can't be verified without it, and
+	// once it's set, chained entries which aren't keyed are rejected as the chain could have been rebuilt
+	Keyring *signing.Keyring
+	// AllowRestarts accepts entries which start a new chain. Logs written to stdout restart the chain whenever the
+	// adapter starts, while log files continue it, so a restart in a file means entries before it were removed
+	AllowRestarts bool
+	// Anchor is the hash of an entry recorded outside of the log, i.e. by an earlier verification. The chain only
+	// shows entries which were removed from the end of the log if it no longer holds the anchor
+	Anchor string
+}
+
+// ChainError describes where the chain of an audit log is broken
+type ChainError struct {
+	// Line is the line of the first entry which doesn't verify
+	Line   int
+	Reason string
+}
+
+func (e *ChainError) Error() string {
+	return fmt.Sprintf("audit log chain is broken at line %d: %s", e.Line, e.Reason)
+}
+
+// VerifyChain verifies the chain of the audit log read from r, returning a ChainError for the first entry which was
+// altered, removed or inserted
+func VerifyChain(r io.Reader, opts VerifyOptions) (ChainResult, error) {
+	var result ChainResult
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxEntrySize)
+	line := 0
+	for scanner.Scan() {
+		line++
+		entry := bytes.TrimSpace(scanner.Bytes())
+		if len(entry) == 0 {
+			continue
+		}
+		var event Event
+		if err := json.Unmarshal(entry, &event); err != nil {
+			return result, &ChainError{Line: line, Reason: fmt.Sprintf("not an audit event: %v", err)}
+		}
+		if event.Hash == "" {
+			if result.Entries > 0 {
+				return result, &ChainError{Line: line, Reason: "the entry isn't chained, it was inserted"}
+			}
+			result.Unchained++
+			continue
+		}
+		content, err := entryContent(entry)
+		if err != nil {
+			return result, &ChainError{Line: line, Reason: err.Error()}
+		}
+		switch {
+		case event.KeyID != "":
+			if opts.Keyring == nil {
+				return result, &ChainError{Line: line, Reason: fmt.Sprintf("the entry is hashed with signing key %s, it can only be verified with the signing keys", event.KeyID)}
+			}
+			if err := opts.Keyring.Verify(content, signing.Signature{KeyID: event.KeyID, Value: event.Hash}); err != nil {
+				return result, &ChainError{Line: line, Reason: fmt.Sprintf("the entry doesn't match its hash, it was altered: %v", err)}
+			}
+			result.Keyed++
+		case opts.Keyring != nil:
+			return result, &ChainError{Line: line, Reason: "the entry isn't hashed with a signing key, the chain was rebuilt"}
+		case contentHash(content) != event.Hash:
+			return result, &ChainError{Line: line, Reason: "the entry doesn't match its hash, it was altered"}
+		}
+		if result.Entries == 0 {
+			result.StartsAfter = event.PreviousHash
+		} else if event.PreviousHash == "" {
+			if !opts.AllowRestarts {
+				return result, &ChainError{Line: line, Reason: "the entry starts a new chain, entries before it were removed"}
+			}
+			// logs written to stdout start a new chain whenever the adapter starts
+			result.Restarts++
+		} else if event.PreviousHash != result.Last {
+			return result, &ChainError{Line: line, Reason: "the entry doesn't follow the previous entry, entries were removed or reordered"}
+		}
+		result.Entries++
+		result.Last = event.Hash
+		if opts.Anchor != "" && event.Hash == opts.Anchor {
+			result.Anchored = true
+		}
+	}
+	return result, scanner.Err()
+}
@@ -0,0 +1,171 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// chainedLog returns an audit log of n chained events, preceded by an event written before the log was chained
+func chainedLog(t *testing.T, n int) []string {
+	return keyedLog(t, n, nil)
+}
+
+// keyedLog returns an audit log of n events chained with an hmac of keys, plain hashes if keys is nil
+func keyedLog(t *testing.T, n int, keys staticKeys) []string {
+	var buf bytes.Buffer
+	writer := NewWriterSink(&buf)
+	require.NoError(t, writer.Emit(context.Background(), NewEvent("123456789012", ActionCheckout, nil)))
+	sink := NewChainSink(writer, "")
+	if keys != nil {
+		sink.WithKeys(keys)
+	}
+	for i := 0; i < n; i++ {
+		event := NewEvent("123456789012", ActionExtend, nil)
+		event.TokenID = TokenID("token")
+		require.NoError(t, sink.Emit(context.Background(), event))
+	}
+	return strings.Split(strings.TrimSpace(buf.String()), "\n")
+}
+
+func TestVerifyChain(t *testing.T) {
+	tests := []struct {
+		name         string
+		tamper       func(lines []string) []string
+		expectedLine int
+	}{
+		{
+			name:   "intact",
+			tamper: func(lines []string) []string { return lines },
+		},
+		{
+			name: "altered",
+			tamper: func(lines []string) []string {
+				lines[2] = strings.Replace(lines[2], `"outcome":"success"`, `"outcome":"failure"`, 1)
+				return lines
+			},
+			expectedLine: 3,
+		},
+		{
+			name: "removed",
+			tamper: func(lines []string) []string {
+				return append(lines[:2], lines[3:]...)
+			},
+			expectedLine: 3,
+		},
+		{
+			name: "inserted",
+			tamper: func(lines []string) []string {
+				return append(lines[:2], append([]string{lines[0]}, lines[2:]...)...)
+			},
+			expectedLine: 3,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			lines := test.tamper(chainedLog(t, 3))
+			result, err := VerifyChain(strings.NewReader(strings.Join(lines, "\n")), VerifyOptions{})
+			if test.expectedLine == 0 {
+				require.NoError(t, err)
+				assert.Equal(t, 3, result.Entries)
+				assert.Equal(t, 1, result.Unchained)
+				assert.Empty(t, result.StartsAfter)
+				return
+			}
+			var chainErr *ChainError
+			require.True(t, errors.As(err, &chainErr), "expected a ChainError, got %v", err)
+			assert.Equal(t, test.expectedLine, chainErr.Line)
+		})
+	}
+}
+
+func TestLastHash(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	last, err := LastHash(path)
+	require.NoError(t, err)
+	assert.Empty(t, last, "a log which doesn't exist starts a new chain")
+
+	lines := chainedLog(t, 2)
+	require.NoError(t, os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0600))
+	last, err = LastHash(path)
+	require.NoError(t, err)
+	assert.NotEmpty(t, last)
+
+	// a restarted adapter continues the chain
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	require.NoError(t, err)
+	sink := NewChainSink(NewWriterSink(file), last)
+	require.NoError(t, sink.Emit(context.Background(), NewEvent("123456789012", ActionCheckIn, nil)))
+	require.NoError(t, file.Close())
+	file, err = os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+	result, err := VerifyChain(file, VerifyOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 3, result.Entries)
+}
+
+func TestVerifyRestartedChain(t *testing.T) {
+	// an adapter logging to stdout starts a new chain when it restarts
+	lines := append(chainedLog(t, 2), chainedLog(t, 2)[1:]...)
+	result, err := VerifyChain(strings.NewReader(strings.Join(lines, "\n")), VerifyOptions{AllowRestarts: true})
+	require.NoError(t, err)
+	assert.Equal(t, 4, result.Entries)
+	assert.Equal(t, 1, result.Restarts)
+
+	// a log file continues its chain, a restart means the entries before it were removed
+	_, err = VerifyChain(strings.NewReader(strings.Join(lines, "\n")), VerifyOptions{})
+	var chainErr *ChainError
+	require.True(t, errors.As(err, &chainErr), "expected a ChainError, got %v", err)
+	assert.Equal(t, 4, chainErr.Line)
+}
+
+func TestVerifyKeyedChain(t *testing.T) {
+	keys := staticKeys{"v1": []byte("old"), "v2": []byte("new")}
+	keyring, err := keys.Keyring()
+	require.NoError(t, err)
+	lines := keyedLog(t, 3, keys)
+
+	result, err := VerifyChain(strings.NewReader(strings.Join(lines, "\n")), VerifyOptions{Keyring: keyring})
+	require.NoError(t, err)
+	assert.Equal(t, 3, result.Keyed)
+	assert.Contains(t, lines[1], `"keyID":"v2"`, "entries should be hashed with the active key")
+
+	_, err = VerifyChain(strings.NewReader(strings.Join(lines, "\n")), VerifyOptions{})
+	assert.Error(t, err, "keyed entries can't be verified without the keys")
+
+	other, err := staticKeys{"v2": []byte("forged")}.Keyring()
+	require.NoError(t, err)
+	_, err = VerifyChain(strings.NewReader(strings.Join(lines, "\n")), VerifyOptions{Keyring: other})
+	assert.Error(t, err, "entries hashed with another key should be rejected")
+
+	// removing entries and rebuilding the chain with plain hashes is detected once the keys are given
+	_, err = VerifyChain(strings.NewReader(strings.Join(chainedLog(t, 2), "\n")), VerifyOptions{Keyring: keyring})
+	var chainErr *ChainError
+	require.True(t, errors.As(err, &chainErr), "expected a ChainError, got %v", err)
+	assert.Equal(t, 2, chainErr.Line)
+}
+
+func TestVerifyAnchoredChain(t *testing.T) {
+	lines := chainedLog(t, 4)
+	// an earlier verification, before the last entry was written
+	result, err := VerifyChain(strings.NewReader(strings.Join(lines[:4], "\n")), VerifyOptions{})
+	require.NoError(t, err)
+	anchor := result.Last
+
+	result, err = VerifyChain(strings.NewReader(strings.Join(lines, "\n")), VerifyOptions{Anchor: anchor})
+	require.NoError(t, err)
+	assert.True(t, result.Anchored)
+
+	result, err = VerifyChain(strings.NewReader(strings.Join(lines[:3], "\n")), VerifyOptions{Anchor: anchor})
+	require.NoError(t, err, "a truncated log is still a valid chain")
+	assert.False(t, result.Anchored, "removing the anchored entry from the end should be detected")
+}
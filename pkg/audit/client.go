@@ -0,0 +1,62 @@
+package audit
+
+import (
+	"context"
+
+	lm "github.com/aws/aws-sdk-go-v2/service/licensemanager"
+	"github.com/aws/aws-sdk-go-v2/service/licensemanager/types"
+	"github.com/rancher/csp-adapter/pkg/clients/aws"
+)
+
+// client wraps an aws.Client, emitting an event for each checkout, check-in and extension
+type client struct {
+	aws.Client
+	sink Sink
+}
+
+// NewClient returns an aws.Client which audits the license actions of c to sink
+func NewClient(c aws.Client, sink Sink) aws.Client {
+	return &client{
+		Client: c,
+		sink:   sink,
+	}
+}
+
+func (c *client) CheckoutRancherLicense(ctx context.Context, l types.GrantedLicense, entitlementAmt int) (*lm.CheckoutLicenseOutput, error) {
+	res, err := c.Client.CheckoutRancherLicense(ctx, l, entitlementAmt)
+	event := c.newEvent(ActionCheckout, err)
+	event.Entitlements = entitlementAmt
+	if l.LicenseArn != nil {
+		event.LicenseArn = *l.LicenseArn
+	}
+	if res != nil && res.LicenseConsumptionToken != nil {
+		event.TokenID = TokenID(*res.LicenseConsumptionToken)
+	}
+	Emit(ctx, c.sink, event)
+	return res, err
+}
+
+func (c *client) CheckInRancherLicense(ctx context.Context, consumptionToken string) (*lm.CheckInLicenseOutput, error) {
+	res, err := c.Client.CheckInRancherLicense(ctx, consumptionToken)
+	event := c.newEvent(ActionCheckIn, err)
+	event.TokenID = TokenID(consumptionToken)
+	Emit(ctx, c.sink, event)
+	return res, err
+}
+
+// CheckInRancherLicenses checks in each token with CheckInRancherLicense, so that an event is emitted for each
+func (c *client) CheckInRancherLicenses(ctx context.Context, tokens []string) map[string]error {
+	return aws.CheckInConcurrently(ctx, c, tokens, aws.DefaultCheckInParallelism)
+}
+
+func (c *client) ExtendRancherLicenseConsumptionToken(ctx context.Context, consumptionToken string) (*lm.ExtendLicenseConsumptionOutput, error) {
+	res, err := c.Client.ExtendRancherLicenseConsumptionToken(ctx, consumptionToken)
+	event := c.newEvent(ActionExtend, err)
+	event.TokenID = TokenID(consumptionToken)
+	Emit(ctx, c.sink, event)
+	return res, err
+}
+
+func (c *client) newEvent(action string, err error) Event {
+	return NewEvent(c.AccountNumber(), action, err)
+}
@@ -0,0 +1,175 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rancher/csp-adapter/pkg/mocks"
+	"github.com/rancher/csp-adapter/pkg/secrets"
+	"github.com/rancher/csp-adapter/pkg/signing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient(t *testing.T) {
+	var buf bytes.Buffer
+	mockAWSClient := mocks.NewMockAWSClient(5)
+	c := NewClient(mockAWSClient, NewWriterSink(&buf))
+	ctx := context.Background()
+
+	license, err := c.GetRancherLicense(ctx)
+	assert.NoError(t, err)
+	res, err := c.CheckoutRancherLicense(ctx, *license, 2)
+	assert.NoError(t, err)
+	_, err = c.ExtendRancherLicenseConsumptionToken(ctx, *res.LicenseConsumptionToken)
+	assert.NoError(t, err)
+	_, err = c.CheckInRancherLicense(ctx, *res.LicenseConsumptionToken)
+	assert.NoError(t, err)
+	// checking in again fails, since the token was already checked in
+	_, err = c.CheckInRancherLicense(ctx, *res.LicenseConsumptionToken)
+	assert.Error(t, err)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 4, "expected one event per license action")
+	var events []Event
+	for _, line := range lines {
+		var event Event
+		assert.NoError(t, json.Unmarshal([]byte(line), &event))
+		events = append(events, event)
+	}
+	expected := []struct {
+		action  string
+		outcome string
+	}{
+		{ActionCheckout, OutcomeSuccess},
+		{ActionExtend, OutcomeSuccess},
+		{ActionCheckIn, OutcomeSuccess},
+		{ActionCheckIn, OutcomeFailure},
+	}
+	for i, e := range expected {
+		assert.Equal(t, e.action, events[i].Action)
+		assert.Equal(t, e.outcome, events[i].Outcome)
+		assert.Equal(t, mockAWSClient.AccountNumber(), events[i].Account)
+		assert.NotEmpty(t, events[i].AuditID)
+		// all actions applied to the same token
+		assert.Equal(t, events[0].TokenID, events[i].TokenID)
+		assert.NotEqual(t, *res.LicenseConsumptionToken, events[i].TokenID, "consumption token must not be revealed")
+	}
+	assert.Equal(t, 2, events[0].Entitlements)
+	assert.NotEmpty(t, events[3].Error)
+}
+
+func TestClientCheckInRancherLicenses(t *testing.T) {
+	var buf bytes.Buffer
+	c := NewClient(mocks.NewMockAWSClient(5), NewWriterSink(&buf))
+	ctx := context.Background()
+	license, err := c.GetRancherLicense(ctx)
+	assert.NoError(t, err)
+	var tokens []string
+	for i := 0; i < 2; i++ {
+		res, err := c.CheckoutRancherLicense(ctx, *license, 1)
+		assert.NoError(t, err)
+		tokens = append(tokens, *res.LicenseConsumptionToken)
+	}
+	buf.Reset()
+
+	for token, err := range c.CheckInRancherLicenses(ctx, tokens) {
+		assert.NoError(t, err, token)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 2, "expected an event for each token checked in")
+	for _, line := range lines {
+		var event Event
+		assert.NoError(t, json.Unmarshal([]byte(line), &event))
+		assert.Equal(t, ActionCheckIn, event.Action)
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+	var received Event
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	event := Event{AuditID: "1", Action: ActionCheckIn, Outcome: OutcomeSuccess}
+	assert.NoError(t, NewWebhookSink(secrets.Literal(server.URL), nil).Emit(context.Background(), event))
+	assert.Equal(t, event, received)
+	assert.Empty(t, authorization)
+
+	assert.NoError(t, NewWebhookSink(secrets.Literal(server.URL), secrets.Literal("Bearer abc")).Emit(context.Background(), event))
+	assert.Equal(t, "Bearer abc", authorization)
+
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	assert.Error(t, NewWebhookSink(secrets.Literal(server.URL), nil).Emit(context.Background(), event))
+}
+
+type rotatingSecret struct {
+	values      []string
+	invalidated int
+}
+
+func (r *rotatingSecret) Value(ctx context.Context) (string, error) {
+	return r.values[r.invalidated], nil
+}
+
+func (r *rotatingSecret) Invalidate() {
+	r.invalidated++
+}
+
+func TestWebhookSinkRotation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer new" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	token := &rotatingSecret{values: []string{"Bearer old", "Bearer new"}}
+	sink := NewWebhookSink(secrets.Literal(server.URL), token)
+	event := Event{AuditID: "1", Action: ActionCheckIn, Outcome: OutcomeSuccess}
+	assert.Error(t, sink.Emit(context.Background(), event))
+	assert.NoError(t, sink.Emit(context.Background(), event), "the rotated token should be read after it was rejected")
+}
+
+type staticKeys map[string][]byte
+
+func (s staticKeys) Keyring() (*signing.Keyring, error) {
+	return signing.NewKeyring(s)
+}
+
+func TestWebhookSinkSigning(t *testing.T) {
+	var body []byte
+	var header string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Get(SignatureHeader)
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	keys := staticKeys{"v1": []byte("old"), "v2": []byte("new")}
+	event := Event{AuditID: "1", Action: ActionCheckIn, Outcome: OutcomeSuccess}
+	assert.NoError(t, NewWebhookSink(secrets.Literal(server.URL), nil).WithSigning(keys).Emit(context.Background(), event))
+	sig, err := signing.ParseSignature(header)
+	assert.NoError(t, err)
+	assert.Equal(t, "v2", sig.KeyID, "expected events to be signed with the newest key")
+	keyring, _ := keys.Keyring()
+	assert.NoError(t, keyring.Verify(body, sig))
+
+	header = ""
+	assert.NoError(t, NewWebhookSink(secrets.Literal(server.URL), nil).Emit(context.Background(), event))
+	assert.Empty(t, header, "expected unsigned events without keys")
+}
@@ -0,0 +1,355 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sync"
+
+	lm "github.com/aws/aws-sdk-go-v2/service/licensemanager"
+	"github.com/sirupsen/logrus"
+)
+
+// Cassettes hold License Manager interactions recorded against a real account, so that they can be replayed in tests
+// without credentials. Interactions are replayed in the order they were recorded for each operation, inputs aren't
+// matched. A cassette file holds the json of a Cassette naming the region, followed by the json of each Interaction in
+// the order they happened, so that the recording is appended to instead of rewritten on every call
+
+const (
+	operationListReceivedLicenses     = "ListReceivedLicenses"
+	operationCheckoutLicense          = "CheckoutLicense"
+	operationCheckInLicense           = "CheckInLicense"
+	operationExtendLicenseConsumption = "ExtendLicenseConsumption"
+	operationGetLicenseUsage          = "GetLicenseUsage"
+	operationListTagsForResource      = "ListTagsForResource"
+
+	// redactedAccountNumber replaces account numbers in recorded interactions
+	redactedAccountNumber = "000000000000"
+)
+
+// Cassette is a recording of License Manager interactions. Recorded files only hold the region in it, see NewReplayClient
+type Cassette struct {
+	Region       string        `json:"region"`
+	Interactions []Interaction `json:"interactions,omitempty"`
+}
+
+// Interaction is a single recorded License Manager call
+type Interaction struct {
+	Operation string          `json:"operation"`
+	Input     json.RawMessage `json:"input"`
+	Output    json.RawMessage `json:"output,omitempty"`
+	Error     string          `json:"error,omitempty"`
+}
+
+var (
+	accountNumberPattern = regexp.MustCompile(`\b\d{12}\b`)
+	// redactedFields hold secrets or customer identifiers. Their values are replaced by placeholders which are
+	// consistent within a cassette, so that a token returned by a checkout still matches the token later checked in
+	redactedFields = map[string]bool{
+		"LicenseConsumptionToken": true,
+		"ClientToken":             true,
+		"Beneficiary":             true,
+		"NodeId":                  true,
+	}
+)
+
+// redactor replaces sensitive values in recorded interactions
+type redactor struct {
+	placeholders map[string]string
+}
+
+// redact returns the json of v with account numbers and the values of redactedFields replaced
+func (r *redactor) redact(v interface{}) (json.RawMessage, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var generic interface{}
+	if err := json.Unmarshal(raw, &generic); err != nil {
+		return nil, err
+	}
+	return json.Marshal(r.redactValue("", generic))
+}
+
+func (r *redactor) redactValue(field string, v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for k, child := range value {
+			value[k] = r.redactValue(k, child)
+		}
+		return value
+	case []interface{}:
+		for i, child := range value {
+			value[i] = r.redactValue(field, child)
+		}
+		return value
+	case string:
+		if redactedFields[field] && value != "" {
+			return r.placeholder(value)
+		}
+		return accountNumberPattern.ReplaceAllString(value, redactedAccountNumber)
+	}
+	return v
+}
+
+func (r *redactor) placeholder(value string) string {
+	if r.placeholders == nil {
+		r.placeholders = map[string]string{}
+	}
+	if p, ok := r.placeholders[value]; ok {
+		return p
+	}
+	p := fmt.Sprintf("redacted-%d", len(r.placeholders)+1)
+	r.placeholders[value] = p
+	return p
+}
+
+// tape appends recorded interactions to a cassette file. It's shared by the recorders of all license manager clients
+// used by the adapter, so that a single cassette holds every interaction in the order it happened
+type tape struct {
+	path   string
+	region string
+
+	lock     sync.Mutex
+	file     *os.File
+	redactor redactor
+}
+
+func newTape(region, path string) *tape {
+	return &tape{
+		path:   path,
+		region: region,
+	}
+}
+
+// record appends an interaction to the cassette file, so that the recording survives the adapter being stopped. The
+// file is created, replacing an earlier recording, when the first interaction is recorded
+func (t *tape) record(operation string, input, output interface{}, callErr error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	interaction := Interaction{Operation: operation}
+	var err error
+	if interaction.Input, err = t.redactor.redact(input); err != nil {
+		logrus.Warnf("unable to record %s input: %v", operation, err)
+		return
+	}
+	if callErr != nil {
+		interaction.Error = accountNumberPattern.ReplaceAllString(callErr.Error(), redactedAccountNumber)
+	} else if interaction.Output, err = t.redactor.redact(output); err != nil {
+		logrus.Warnf("unable to record %s output: %v", operation, err)
+		return
+	}
+	if t.file == nil {
+		if t.file, err = t.create(); err != nil {
+			logrus.Warnf("unable to create cassette %s: %v", t.path, err)
+			return
+		}
+	}
+	data, err := json.Marshal(interaction)
+	if err != nil {
+		logrus.Warnf("unable to marshal %s interaction: %v", operation, err)
+		return
+	}
+	if _, err := t.file.Write(append(data, '\n')); err != nil {
+		logrus.Warnf("unable to save cassette to %s: %v", t.path, err)
+	}
+}
+
+// create creates the cassette file, starting with the region it's recorded in
+func (t *tape) create() (*os.File, error) {
+	header, err := json.Marshal(Cassette{Region: t.region})
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(t.path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := file.Write(append(header, '\n')); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+// recorder passes calls through to a license manager client, recording every interaction on a tape
+type recorder struct {
+	lm   licenseManagerClient
+	tape *tape
+}
+
+// recording wraps every license manager client created by newLM in a recorder, so that the clients of every region
+// are recorded on the tape
+func recording(t *tape, newLM licenseManagersFunc) licenseManagersFunc {
+	return func(region string) (read, write licenseManagerClient) {
+		read, write = newLM(region)
+		read = &recorder{lm: read, tape: t}
+		if write != nil {
+			write = &recorder{lm: write, tape: t}
+		}
+		return read, write
+	}
+}
+
+func (r *recorder) ListReceivedLicenses(ctx context.Context, params *lm.ListReceivedLicensesInput, optFns ...func(*lm.Options)) (*lm.ListReceivedLicensesOutput, error) {
+	out, err := r.lm.ListReceivedLicenses(ctx, params, optFns...)
+	r.tape.record(operationListReceivedLicenses, params, out, err)
+	return out, err
+}
+
+func (r *recorder) CheckoutLicense(ctx context.Context, params *lm.CheckoutLicenseInput, optFns ...func(*lm.Options)) (*lm.CheckoutLicenseOutput, error) {
+	out, err := r.lm.CheckoutLicense(ctx, params, optFns...)
+	r.tape.record(operationCheckoutLicense, params, out, err)
+	return out, err
+}
+
+func (r *recorder) CheckInLicense(ctx context.Context, params *lm.CheckInLicenseInput, optFns ...func(*lm.Options)) (*lm.CheckInLicenseOutput, error) {
+	out, err := r.lm.CheckInLicense(ctx, params, optFns...)
+	r.tape.record(operationCheckInLicense, params, out, err)
+	return out, err
+}
+
+func (r *recorder) ExtendLicenseConsumption(ctx context.Context, params *lm.ExtendLicenseConsumptionInput, optFns ...func(*lm.Options)) (*lm.ExtendLicenseConsumptionOutput, error) {
+	out, err := r.lm.ExtendLicenseConsumption(ctx, params, optFns...)
+	r.tape.record(operationExtendLicenseConsumption, params, out, err)
+	return out, err
+}
+
+func (r *recorder) GetLicenseUsage(ctx context.Context, params *lm.GetLicenseUsageInput, optFns ...func(*lm.Options)) (*lm.GetLicenseUsageOutput, error) {
+	out, err := r.lm.GetLicenseUsage(ctx, params, optFns...)
+	r.tape.record(operationGetLicenseUsage, params, out, err)
+	return out, err
+}
+
+func (r *recorder) ListTagsForResource(ctx context.Context, params *lm.ListTagsForResourceInput, optFns ...func(*lm.Options)) (*lm.ListTagsForResourceOutput, error) {
+	out, err := r.lm.ListTagsForResource(ctx, params, optFns...)
+	r.tape.record(operationListTagsForResource, params, out, err)
+	return out, err
+}
+
+// ErrCassetteExhausted is returned when a replayed operation is called more often than it was recorded
+var ErrCassetteExhausted = errors.New("no recorded interaction left for operation")
+
+// replayer is a license manager client answering calls from a cassette
+type replayer struct {
+	lock         sync.Mutex
+	interactions map[string][]Interaction
+}
+
+func newReplayer(cassette Cassette) *replayer {
+	r := &replayer{interactions: map[string][]Interaction{}}
+	for _, interaction := range cassette.Interactions {
+		r.interactions[interaction.Operation] = append(r.interactions[interaction.Operation], interaction)
+	}
+	return r
+}
+
+// next decodes the output of the next recorded interaction for operation into out, returning the recorded error
+// instead if the call failed
+func (r *replayer) next(operation string, out interface{}) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	interactions := r.interactions[operation]
+	if len(interactions) == 0 {
+		return fmt.Errorf("%s: %w", operation, ErrCassetteExhausted)
+	}
+	interaction := interactions[0]
+	r.interactions[operation] = interactions[1:]
+	if interaction.Error != "" {
+		return errors.New(interaction.Error)
+	}
+	return json.Unmarshal(interaction.Output, out)
+}
+
+func (r *replayer) ListReceivedLicenses(ctx context.Context, params *lm.ListReceivedLicensesInput, optFns ...func(*lm.Options)) (*lm.ListReceivedLicensesOutput, error) {
+	var out lm.ListReceivedLicensesOutput
+	if err := r.next(operationListReceivedLicenses, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (r *replayer) CheckoutLicense(ctx context.Context, params *lm.CheckoutLicenseInput, optFns ...func(*lm.Options)) (*lm.CheckoutLicenseOutput, error) {
+	var out lm.CheckoutLicenseOutput
+	if err := r.next(operationCheckoutLicense, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (r *replayer) CheckInLicense(ctx context.Context, params *lm.CheckInLicenseInput, optFns ...func(*lm.Options)) (*lm.CheckInLicenseOutput, error) {
+	var out lm.CheckInLicenseOutput
+	if err := r.next(operationCheckInLicense, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (r *replayer) ExtendLicenseConsumption(ctx context.Context, params *lm.ExtendLicenseConsumptionInput, optFns ...func(*lm.Options)) (*lm.ExtendLicenseConsumptionOutput, error) {
+	var out lm.ExtendLicenseConsumptionOutput
+	if err := r.next(operationExtendLicenseConsumption, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (r *replayer) GetLicenseUsage(ctx context.Context, params *lm.GetLicenseUsageInput, optFns ...func(*lm.Options)) (*lm.GetLicenseUsageOutput, error) {
+	var out lm.GetLicenseUsageOutput
+	if err := r.next(operationGetLicenseUsage, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (r *replayer) ListTagsForResource(ctx context.Context, params *lm.ListTagsForResourceInput, optFns ...func(*lm.Options)) (*lm.ListTagsForResourceOutput, error) {
+	var out lm.ListTagsForResourceOutput
+	if err := r.next(operationListTagsForResource, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// NewReplayClient returns a Client answering License Manager calls from the cassette at path, as recorded with
+// ClientOptions.RecordCassette. Cassettes written as a single Cassette holding its interactions are read as well. The
+// client reports the redacted account number
+func NewReplayClient(path string, opts ClientOptions) (Client, error) {
+	cassette, err := readCassette(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse cassette %s: %w", path, err)
+	}
+	return &client{
+		acctNum: redactedAccountNumber,
+		region:  cassette.Region,
+		opts:    opts,
+		lm:      newReplayer(cassette),
+	}, nil
+}
+
+// readCassette reads the cassette at path along with the interactions appended to it
+func readCassette(path string) (Cassette, error) {
+	var cassette Cassette
+	file, err := os.Open(path)
+	if err != nil {
+		return cassette, err
+	}
+	defer file.Close()
+	decoder := json.NewDecoder(file)
+	if err := decoder.Decode(&cassette); err != nil {
+		return cassette, err
+	}
+	for {
+		var interaction Interaction
+		err := decoder.Decode(&interaction)
+		if errors.Is(err, io.EOF) {
+			return cassette, nil
+		}
+		if err != nil {
+			return cassette, err
+		}
+		cassette.Interactions = append(cassette.Interactions, interaction)
+	}
+}
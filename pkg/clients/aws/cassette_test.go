@@ -0,0 +1,137 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	lm "github.com/aws/aws-sdk-go-v2/service/licensemanager"
+	"github.com/aws/aws-sdk-go-v2/service/licensemanager/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	cassettePath := filepath.Join(t.TempDir(), "cassette.json")
+	mockLMClient := mockLicenseManagerClient{}
+	mockLMClient.Clear()
+	mockLMClient.AddLicenseForSku(rancherProductSKUNonEmea, fakeAccountNum, true)
+	newLM := recording(newTape("us-east-1", cassettePath), func(region string) (licenseManagerClient, licenseManagerClient) {
+		return failingExtendClient{&mockLMClient}, nil
+	})
+	recorded := &client{
+		acctNum: fakeAccountNum,
+		opts:    ClientOptions{Beneficiary: "cost-center-1234"},
+		newLM:   newLM,
+	}
+	recorded.lm, recorded.lmWrite = newLM("us-east-1")
+
+	ctx := context.Background()
+	license, err := recorded.GetRancherLicense(ctx)
+	assert.NoError(t, err)
+	fingerprint := "aws:294406891311:AWS/Marketplace:issuer-fingerprint"
+	license.Issuer = &types.IssuerDetails{KeyFingerprint: &fingerprint}
+	checkout, err := recorded.CheckoutRancherLicense(ctx, *license, 2)
+	assert.NoError(t, err)
+	_, err = recorded.ExtendRancherLicenseConsumptionToken(ctx, *checkout.LicenseConsumptionToken)
+	recordedErr := err
+	assert.Error(t, recordedErr)
+
+	data, err := ioutil.ReadFile(cassettePath)
+	assert.NoError(t, err)
+	cassette := string(data)
+	for _, secret := range []string{fakeAccountNum, *checkout.LicenseConsumptionToken, "cost-center-1234"} {
+		assert.NotContainsf(t, cassette, secret, "expected %s to be redacted", secret)
+	}
+
+	replay, err := NewReplayClient(cassettePath, ClientOptions{})
+	assert.NoError(t, err)
+	replayedLicense, err := replay.GetRancherLicense(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, strings.ReplaceAll(*license.LicenseArn, fakeAccountNum, redactedAccountNumber), *replayedLicense.LicenseArn)
+	replayedCheckout, err := replay.CheckoutRancherLicense(ctx, *license, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, *checkout.Expiration, *replayedCheckout.Expiration)
+	assert.NotEqual(t, *checkout.LicenseConsumptionToken, *replayedCheckout.LicenseConsumptionToken)
+	_, err = replay.ExtendRancherLicenseConsumptionToken(ctx, *replayedCheckout.LicenseConsumptionToken)
+	assert.EqualError(t, err, strings.ReplaceAll(recordedErr.Error(), fakeAccountNum, redactedAccountNumber), "recorded error should be replayed")
+
+	_, err = replay.CheckoutRancherLicense(ctx, *license, 2)
+	assert.True(t, errors.Is(err, ErrCassetteExhausted), "expected calls beyond the recording to fail, got %v", err)
+}
+
+// failingExtendClient fails every extension, to record an error
+type failingExtendClient struct {
+	*mockLicenseManagerClient
+}
+
+func (f failingExtendClient) ExtendLicenseConsumption(ctx context.Context, params *lm.ExtendLicenseConsumptionInput, optFns ...func(*lm.Options)) (*lm.ExtendLicenseConsumptionOutput, error) {
+	return nil, fmt.Errorf("token expired for account %s", fakeAccountNum)
+}
+
+func TestRecordSwitchedRegion(t *testing.T) {
+	cassettePath := filepath.Join(t.TempDir(), "cassette.json")
+	mockLMClient := mockLicenseManagerClient{}
+	mockLMClient.Clear()
+	mockLMClient.AddLicenseForSku(rancherProductSKUNonEmea, fakeAccountNum, true)
+	license := mockLMClient.licenses[rancherProductSKUNonEmea]
+	homeRegion := "us-west-2"
+	license.HomeRegion = &homeRegion
+	mockLMClient.licenses[rancherProductSKUNonEmea] = license
+	var regions []string
+	newLM := recording(newTape("us-east-1", cassettePath), func(region string) (licenseManagerClient, licenseManagerClient) {
+		regions = append(regions, region)
+		return &mockLMClient, &mockLMClient
+	})
+	c := &client{
+		acctNum: fakeAccountNum,
+		region:  "us-east-1",
+		opts:    ClientOptions{AutoSwitchRegion: true},
+		sts:     &mockSTSClient{accountNumber: fakeAccountNum},
+		newLM:   newLM,
+	}
+	c.lm, c.lmWrite = newLM(c.region)
+
+	ctx := context.Background()
+	_, err := c.GetRancherLicense(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"us-east-1", "us-west-2"}, regions)
+	assert.NoError(t, c.CheckServiceHealth(ctx))
+
+	cassette, err := readCassette(cassettePath)
+	assert.NoError(t, err)
+	assert.Equal(t, "us-east-1", cassette.Region)
+	var operations []string
+	for _, interaction := range cassette.Interactions {
+		operations = append(operations, interaction.Operation)
+	}
+	assert.Equal(t, []string{operationListReceivedLicenses, operationListReceivedLicenses}, operations,
+		"calls in the license's region should be recorded")
+}
+
+func TestReadCassette(t *testing.T) {
+	dir := t.TempDir()
+	interactions := []Interaction{
+		{Operation: operationCheckoutLicense, Input: json.RawMessage(`{}`), Output: json.RawMessage(`{}`)},
+		{Operation: operationCheckInLicense, Input: json.RawMessage(`{}`), Error: "throttled"},
+	}
+	single, err := json.MarshalIndent(Cassette{Region: "us-east-1", Interactions: interactions}, "", "  ")
+	assert.NoError(t, err)
+	singlePath := filepath.Join(dir, "single.json")
+	assert.NoError(t, ioutil.WriteFile(singlePath, single, 0600))
+
+	appendedPath := filepath.Join(dir, "appended.json")
+	tape := newTape("us-east-1", appendedPath)
+	tape.record(operationCheckoutLicense, struct{}{}, struct{}{}, nil)
+	tape.record(operationCheckInLicense, struct{}{}, nil, errors.New("throttled"))
+
+	for _, path := range []string{singlePath, appendedPath} {
+		cassette, err := readCassette(path)
+		assert.NoError(t, err)
+		assert.Equal(t, Cassette{Region: "us-east-1", Interactions: interactions}, cassette, path)
+	}
+}
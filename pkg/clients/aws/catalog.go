@@ -0,0 +1,68 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/licensemanager/types"
+	"github.com/rancher/csp-adapter/pkg/sdk"
+)
+
+// knownProduct is a rancher product which can be purchased through the marketplace
+type knownProduct struct {
+	name string
+	sku  string
+}
+
+// knownProducts are listed in the order GetRancherLicense looks for them, so the first one found is the one used
+var knownProducts = []knownProduct{
+	{name: "Rancher", sku: rancherProductSKUNonEmea},
+	{name: "Rancher (EMEA)", sku: rancherProductSKUEmea},
+}
+
+func (c *client) ListProducts(ctx context.Context) ([]sdk.Product, error) {
+	licenses := map[string]*types.GrantedLicense{}
+	for _, product := range knownProducts {
+		license, err := c.findLicense(ctx, product.sku)
+		if err != nil {
+			return nil, fmt.Errorf("unable to list license for product %s: %w", product.sku, err)
+		}
+		licenses[product.sku] = license
+	}
+	return describeProducts(licenses), nil
+}
+
+// describeProducts produces the catalog of known products from the licenses found for their skus
+func describeProducts(licenses map[string]*types.GrantedLicense) []sdk.Product {
+	products := make([]sdk.Product, 0, len(knownProducts))
+	matched := false
+	for _, known := range knownProducts {
+		product := sdk.Product{
+			Name: known.name,
+			SKU:  known.sku,
+		}
+		if license := licenses[known.sku]; license != nil {
+			product.Found = true
+			product.Status = string(license.Status)
+			// licenses which don't report a status are treated as usable, like they are for compliance checks
+			product.Active = license.Status == "" || license.Status == types.LicenseStatusAvailable
+			product.Matched = !matched
+			matched = true
+			if license.LicenseArn != nil {
+				product.LicenseArn = *license.LicenseArn
+			}
+			for _, entitlement := range license.Entitlements {
+				dimension := sdk.ProductDimension{Unit: string(entitlement.Unit)}
+				if entitlement.Name != nil {
+					dimension.Name = *entitlement.Name
+				}
+				if entitlement.MaxCount != nil {
+					dimension.MaxCount = *entitlement.MaxCount
+				}
+				product.Dimensions = append(product.Dimensions, dimension)
+			}
+		}
+		products = append(products, product)
+	}
+	return products
+}
@@ -0,0 +1,75 @@
+package aws
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/licensemanager/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListProducts(t *testing.T) {
+	tests := []struct {
+		name            string
+		skus            []string
+		status          types.LicenseStatus
+		expectedFound   []bool
+		expectedMatched []bool
+		expectedActive  []bool
+	}{
+		{
+			name:            "no license",
+			expectedFound:   []bool{false, false},
+			expectedMatched: []bool{false, false},
+			expectedActive:  []bool{false, false},
+		},
+		{
+			name:            "emea license",
+			skus:            []string{rancherProductSKUEmea},
+			status:          types.LicenseStatusAvailable,
+			expectedFound:   []bool{false, true},
+			expectedMatched: []bool{false, true},
+			expectedActive:  []bool{false, true},
+		},
+		{
+			name:            "both licenses, non-emea is used",
+			skus:            []string{rancherProductSKUNonEmea, rancherProductSKUEmea},
+			status:          types.LicenseStatusAvailable,
+			expectedFound:   []bool{true, true},
+			expectedMatched: []bool{true, false},
+			expectedActive:  []bool{true, true},
+		},
+		{
+			name:            "expired license",
+			skus:            []string{rancherProductSKUNonEmea},
+			status:          types.LicenseStatusExpired,
+			expectedFound:   []bool{true, false},
+			expectedMatched: []bool{true, false},
+			expectedActive:  []bool{false, false},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			mockLMClient := mockLicenseManagerClient{}
+			mockLMClient.Clear()
+			for _, sku := range test.skus {
+				mockLMClient.AddLicenseForSku(sku, fakeAccountNum, true)
+				license := mockLMClient.licenses[sku]
+				license.Status = test.status
+				mockLMClient.licenses[sku] = license
+			}
+			client := &client{acctNum: fakeAccountNum, lm: &mockLMClient}
+
+			products, err := client.ListProducts(context.Background())
+			assert.NoError(t, err)
+			assert.Len(t, products, len(knownProducts))
+			for i, product := range products {
+				assert.Equal(t, knownProducts[i].sku, product.SKU)
+				assert.Equalf(t, test.expectedFound[i], product.Found, "unexpected found for %s", product.Name)
+				assert.Equalf(t, test.expectedMatched[i], product.Matched, "unexpected matched for %s", product.Name)
+				assert.Equalf(t, test.expectedActive[i], product.Active, "unexpected active for %s", product.Name)
+			}
+		})
+	}
+}
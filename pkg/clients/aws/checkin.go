@@ -0,0 +1,46 @@
+package aws
+
+import (
+	"context"
+	"sync"
+)
+
+// DefaultCheckInParallelism is how many check-ins CheckInConcurrently makes at once by default. License Manager
+// throttles writes of an account at a few per second, more concurrent check-ins would only be throttled
+const DefaultCheckInParallelism = 4
+
+// CheckInConcurrently checks in each of tokens with client, at most parallelism at a time, and returns the outcome of
+// each token's check-in: nil if it succeeded, else its error. A failed check-in doesn't stop the others. Each token is
+// checked in with its own CheckInRancherLicense call, so that implementations of Client.CheckInRancherLicenses which
+// wrap another client (audit, slo) see every check-in by passing themselves. Duplicate tokens are checked in once
+func CheckInConcurrently(ctx context.Context, client Client, tokens []string, parallelism int) map[string]error {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	results := make(map[string]error, len(tokens))
+	seen := map[string]bool{}
+	var (
+		lock sync.Mutex
+		wg   sync.WaitGroup
+	)
+	slots := make(chan struct{}, parallelism)
+	for _, token := range tokens {
+		if seen[token] {
+			continue
+		}
+		seen[token] = true
+		token := token
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			_, err := client.CheckInRancherLicense(ctx, token)
+			lock.Lock()
+			defer lock.Unlock()
+			results[token] = err
+		}()
+	}
+	wg.Wait()
+	return results
+}
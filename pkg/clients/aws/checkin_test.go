@@ -0,0 +1,56 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	lm "github.com/aws/aws-sdk-go-v2/service/licensemanager"
+	"github.com/stretchr/testify/assert"
+)
+
+// checkInRecorder checks in tokens, failing those in failing, and records how many check-ins ran at once
+type checkInRecorder struct {
+	Client
+	failing map[string]bool
+
+	lock       sync.Mutex
+	running    int
+	maxRunning int
+	calls      map[string]int
+}
+
+func (r *checkInRecorder) CheckInRancherLicense(ctx context.Context, consumptionToken string) (*lm.CheckInLicenseOutput, error) {
+	r.lock.Lock()
+	r.running++
+	if r.running > r.maxRunning {
+		r.maxRunning = r.running
+	}
+	r.calls[consumptionToken]++
+	r.lock.Unlock()
+	time.Sleep(10 * time.Millisecond)
+	r.lock.Lock()
+	r.running--
+	r.lock.Unlock()
+	if r.failing[consumptionToken] {
+		return nil, errors.New("throttled")
+	}
+	return &lm.CheckInLicenseOutput{}, nil
+}
+
+func TestCheckInConcurrently(t *testing.T) {
+	client := &checkInRecorder{failing: map[string]bool{"token-3": true}, calls: map[string]int{}}
+	tokens := []string{"token-1", "token-2", "token-3", "token-4", "token-5", "token-1"}
+
+	results := CheckInConcurrently(context.Background(), client, tokens, 2)
+	assert.Len(t, results, 5)
+	for _, token := range []string{"token-1", "token-2", "token-4", "token-5"} {
+		assert.NoError(t, results[token], token)
+	}
+	assert.EqualError(t, results["token-3"], "throttled", "a failed check-in doesn't stop the others")
+	assert.Equal(t, 1, client.calls["token-1"], "duplicates are checked in once")
+	assert.LessOrEqual(t, client.maxRunning, 2)
+	assert.Empty(t, CheckInConcurrently(context.Background(), client, nil, 2))
+}
@@ -3,31 +3,56 @@ package aws
 
 import (
 	"context"
-	"errors"
 	"fmt"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
 	lm "github.com/aws/aws-sdk-go-v2/service/licensemanager"
 	"github.com/aws/aws-sdk-go-v2/service/licensemanager/types"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
 	"github.com/google/uuid"
+	"github.com/rancher/csp-adapter/pkg/entitlement"
+	"github.com/rancher/csp-adapter/pkg/sdk"
 	"github.com/sirupsen/logrus"
 )
 
+// Client is the adapter's contract with AWS License Manager. mocks.AWSClientMock implements it for unit tests
 type Client interface {
 	// AccountNumber gets the account number for the AWS account this client will issue calls to
 	AccountNumber() string
+	// AccountAlias gets the alias of the AWS account, empty if it has none or it couldn't be read
+	AccountAlias() string
+	// RefreshAccountNumber looks up the account number and alias again, i.e. after the credentials changed, returning
+	// the account number. The previous ones are kept if it fails
+	RefreshAccountNumber(ctx context.Context) (string, error)
 	// GetRancherLicense returns the license which is for the rancher product sku
 	GetRancherLicense(ctx context.Context) (*types.GrantedLicense, error)
 	// CheckoutRancherLicense checks out the license for entitlementAmt entitlements to RKE_NODE_SUPP
 	CheckoutRancherLicense(ctx context.Context, l types.GrantedLicense, entitlementAmt int) (*lm.CheckoutLicenseOutput, error)
 	// CheckInRancherLicense checks in a license using the provided consumptionToken
 	CheckInRancherLicense(ctx context.Context, consumptionToken string) (*lm.CheckInLicenseOutput, error)
+	// CheckInRancherLicenses checks in each of tokens concurrently and returns the outcome of each token's check-in,
+	// nil if it succeeded. See CheckInConcurrently
+	CheckInRancherLicenses(ctx context.Context, tokens []string) map[string]error
 	// ExtendRancherLicenseConsumptionToken extends the Expiry time of the provided consumptionToken
 	ExtendRancherLicenseConsumptionToken(ctx context.Context, consumptionToken string) (*lm.ExtendLicenseConsumptionOutput, error)
 	// GetNumberOfAvailableEntitlements gets the number of RKE_NODE_SUPP entitlements available on license
 	GetNumberOfAvailableEntitlements(ctx context.Context, license types.GrantedLicense) (int, error)
+	// GetEntitlementUsage gets the number of RKE_NODE_SUPP entitlements granted and consumed on license, by any consumer
+	GetEntitlementUsage(ctx context.Context, license types.GrantedLicense) (EntitlementUsage, error)
+	// CheckServiceHealth issues a cheap read call to License Manager, returning an error if it's unavailable
+	CheckServiceHealth(ctx context.Context) error
+	// ListProducts returns every known rancher product sku, describing the license received for it if there is one
+	ListProducts(ctx context.Context) ([]sdk.Product, error)
+	// ValidateLicense returns an error describing why l can't be checked out, if it can't. See ValidateLicense
+	ValidateLicense(l types.GrantedLicense) error
 }
 type licenseManagerClient interface {
 	ListReceivedLicenses(ctx context.Context, params *lm.ListReceivedLicensesInput, optFns ...func(*lm.Options)) (*lm.ListReceivedLicensesOutput, error)
@@ -35,60 +60,185 @@ type licenseManagerClient interface {
 	CheckInLicense(ctx context.Context, params *lm.CheckInLicenseInput, optFns ...func(*lm.Options)) (*lm.CheckInLicenseOutput, error)
 	ExtendLicenseConsumption(ctx context.Context, params *lm.ExtendLicenseConsumptionInput, optFns ...func(*lm.Options)) (*lm.ExtendLicenseConsumptionOutput, error)
 	GetLicenseUsage(ctx context.Context, params *lm.GetLicenseUsageInput, optFns ...func(*lm.Options)) (*lm.GetLicenseUsageOutput, error)
+	ListTagsForResource(ctx context.Context, params *lm.ListTagsForResourceInput, optFns ...func(*lm.Options)) (*lm.ListTagsForResourceOutput, error)
+}
+
+type iamClient interface {
+	ListAccountAliases(ctx context.Context, params *iam.ListAccountAliasesInput, optFns ...func(*iam.Options)) (*iam.ListAccountAliasesOutput, error)
 }
 
 type stsClient interface {
 	GetCallerIdentity(ctx context.Context, params *sts.GetCallerIdentityInput, optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error)
 }
 
+// ClientOptions configures optional behavior of the client
+type ClientOptions struct {
+	// AutoSwitchRegion makes the client issue license manager calls in the home region of the rancher license when it
+	// differs from the configured region, instead of failing with a RegionMismatchError
+	AutoSwitchRegion bool
+	// Beneficiary, if set, is recorded as the beneficiary of every checkout so that consumption can be attributed to
+	// an internal cost center or team in License Manager's usage records
+	Beneficiary string
+	// WriteRoleARN, if set, is assumed for checkouts, check-ins and extensions only. The adapter's own identity is then
+	// only used for read calls and can be limited to read-only permissions
+	WriteRoleARN string
+	// RecordCassette, if set, is the path of a file which every License Manager interaction is recorded to, with
+	// account numbers and tokens redacted. The cassette can be replayed in tests with NewReplayClient
+	RecordCassette string
+	// LicenseTags, if set, pins the adapter to the received license whose tags include every key and value, for
+	// accounts which received several grants for the same product sku (i.e. test and production agreements)
+	LicenseTags map[string]string
+	// DualStack makes the client call the dual-stack (IPv4 and IPv6) endpoints of License Manager and STS, which are
+	// required in IPv6-only clusters since the default endpoints are only reachable over IPv4
+	DualStack bool
+	// STSRegion, if set, makes the client call the regional sts endpoint of this region only. Otherwise the endpoint of
+	// the configured region is called, falling back to regional endpoints if it fails (i.e. the global endpoint in
+	// networks which only allow regional ones)
+	STSRegion string
+	// DimensionAliases are other names of the RKE_NODE_SUPP entitlement dimension, i.e. RANCHER_NODE after it was
+	// renamed. While they are set, licenses granting the dimension under any of the names can be checked out, and
+	// usage is counted under all of them, so that the adapter keeps working through the transition to a new name
+	DimensionAliases []string
+}
+
 type client struct {
-	acctNum string
-	sts     stsClient
-	lm      licenseManagerClient
+	// acctLock guards the account number and alias, which are looked up on first use and cached. It isn't held while
+	// they are looked up
+	acctLock  sync.Mutex
+	acctNum   string
+	acctAlias string
+	// acctFailedAt is when looking up the account number last failed, it isn't looked up again for accountRetryInterval
+	acctFailedAt time.Time
+	// acctLookingUp is true while the account is looked up on first use, so that concurrent callers don't look it up too
+	acctLookingUp bool
+
+	// regionLock guards region, lm and lmWrite, which are replaced when switching to the home region of the license
+	// while other calls are in flight
+	regionLock sync.RWMutex
+	region     string
+	opts       ClientOptions
+	sts        stsClient
+	// stsFallbacks are tried in turn when the caller identity can't be read from sts
+	stsFallbacks []regionalSTS
+	iam          iamClient
+	lm           licenseManagerClient
+	// lmWrite issues the calls changing checkouts, when they are made with separate credentials. lm is used if nil
+	lmWrite licenseManagerClient
+	// newLM creates the license manager clients for the given region, used by NewClient and when switching regions
+	newLM licenseManagersFunc
+
+	// fingerprints caches the issuer key fingerprint of each license by arn
+	fingerprintLock sync.Mutex
+	fingerprints    map[string]string
+
+	// selected is the arn of the license returned by GetRancherLicense last, which is kept until it's replaced or
+	// expiring
+	selectLock sync.Mutex
+	selected   string
 }
 
-func NewClient(ctx context.Context) (Client, error) {
-	cfg, err := config.LoadDefaultConfig(ctx)
+func NewClient(ctx context.Context, opts ClientOptions) (Client, error) {
+	cfg, err := loadConfig(ctx, opts)
 	if err != nil {
 		return nil, err
 	}
 
-	logrus.Debugf("aws config region: %+v", cfg.Region)
+	logrus.Debugf("aws config region: %+v, dual-stack endpoints: %t", cfg.Region, opts.DualStack)
+	if !currentAPI.known {
+		logrus.Warnf("License Manager api version %s is unknown, requests are made like those of version %s", lm.ServiceAPIVersion,
+			currentAPI.revision.version)
+	}
 
 	c := &client{
-		sts: sts.NewFromConfig(cfg),
-		lm:  lm.NewFromConfig(cfg),
+		region:       cfg.Region,
+		opts:         opts,
+		sts:          newSTSClient(cfg, opts),
+		stsFallbacks: stsFallbacks(cfg, opts),
 	}
+	c.newLM = newLicenseManagers(cfg, opts)
+	c.lm, c.lmWrite = c.newLM(cfg.Region)
 
-	acctNum, err := c.getAccountNumber(ctx)
-	if err != nil {
-		return nil, err
+	c.iam = iam.NewFromConfig(cfg, func(o *iam.Options) {
+		// iam has no dual-stack endpoint
+		o.EndpointOptions.UseDualStackEndpoint = aws.DualStackEndpointStateDisabled
+	})
+
+	// sts being briefly unavailable doesn't fail the startup, the account number is looked up again when it's used
+	if _, err := c.RefreshAccountNumber(ctx); err != nil {
+		logrus.Warnf("unable to look up the aws account number, will retry when it's used: %v", err)
 	}
 
-	c.acctNum = acctNum
+	return c, nil
+}
 
-	logrus.Debugf("account number: %s", acctNum)
+// licenseManagersFunc creates the license manager clients for region. write issues the calls changing checkouts, it's
+// nil unless they're made with separate credentials
+type licenseManagersFunc func(region string) (read, write licenseManagerClient)
+
+// newLicenseManagers returns the licenseManagersFunc creating the clients of every region from cfg, assuming the write
+// role and recording interactions as configured by opts
+func newLicenseManagers(cfg aws.Config, opts ClientOptions) licenseManagersFunc {
+	var writeCfg *aws.Config
+	if opts.WriteRoleARN != "" {
+		logrus.Infof("using role %s for license checkouts", opts.WriteRoleARN)
+		assumed := cfg.Copy()
+		assumed.Credentials = aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(newSTSClient(cfg, opts), opts.WriteRoleARN))
+		writeCfg = &assumed
+	}
+	newLM := func(region string) (read, write licenseManagerClient) {
+		inRegion := func(o *lm.Options) {
+			o.Region = region
+		}
+		read = lm.NewFromConfig(cfg, inRegion)
+		if writeCfg != nil {
+			write = lm.NewFromConfig(*writeCfg, inRegion)
+		}
+		return read, write
+	}
+	if opts.RecordCassette != "" {
+		logrus.Warnf("recording license manager interactions to %s", opts.RecordCassette)
+		return recording(newTape(cfg.Region, opts.RecordCassette), newLM)
+	}
+	return newLM
+}
 
-	return c, nil
+// loadConfig loads the aws config from the environment, applying the endpoint options of opts
+func loadConfig(ctx context.Context, opts ClientOptions) (aws.Config, error) {
+	var loadOpts []func(*config.LoadOptions) error
+	if opts.DualStack {
+		loadOpts = append(loadOpts, config.WithUseDualStackEndpoint(aws.DualStackEndpointStateEnabled))
+	}
+	return config.LoadDefaultConfig(ctx, loadOpts...)
 }
 
+// AccountNumber returns the account number, looking it up from sts if it isn't known yet. It's empty while sts can't be
+// reached, see RefreshAccountNumber
 func (c *client) AccountNumber() string {
-	return c.acctNum // set in constructor
+	acctNum, _ := c.account()
+	return acctNum
+}
+
+func (c *client) AccountAlias() string {
+	_, alias := c.account()
+	return alias
 }
 
-// getAccountNumber returns the account number of the account to which the associated IAM user belongs.
-func (c *client) getAccountNumber(ctx context.Context) (string, error) {
-	var in sts.GetCallerIdentityInput
-	out, err := c.sts.GetCallerIdentity(ctx, &in) // no permissions required to make this call
+// getAccountAlias returns the alias of the account, which humans recognize more easily than its number. The alias
+// is informational, so it's empty rather than an error if it can't be read, i.e. without iam:ListAccountAliases
+func (c *client) getAccountAlias(ctx context.Context, acctNum string) string {
+	if c.iam == nil {
+		return ""
+	}
+	out, err := c.iam.ListAccountAliases(ctx, &iam.ListAccountAliasesInput{})
 	if err != nil {
-		return "", err
+		logrus.Warnf("unable to read the alias of account %s, only its number is reported: %v", acctNum, err)
+		return ""
 	}
-
-	if out.Account == nil || len(*out.Account) == 0 {
-		return "", errors.New("account number empty in aws sts response")
+	// an account has at most one alias
+	if len(out.AccountAliases) == 0 {
+		return ""
 	}
-
-	return *out.Account, nil
+	return out.AccountAliases[0]
 }
 
 var (
@@ -96,6 +246,10 @@ var (
 	rancherProductSKUNonEmea       = "0b87d4fa-d1fe-41d8-830b-67d4ec381549"
 	rancherProductSKUEmea          = "a303097d-1dc2-4548-8ea6-f46bb9842e21"
 	maxResults               int32 = 1
+	// licensesPerPage is the number of received licenses listed per call, every page of licenses is listed
+	licensesPerPage int32 = 100
+	// maxLicensePages bounds the pages of received licenses listed for a sku, in case a next token is always returned
+	maxLicensePages = 20
 )
 
 func (c *client) GetRancherLicense(ctx context.Context) (*types.GrantedLicense, error) {
@@ -104,127 +258,471 @@ func (c *client) GetRancherLicense(ctx context.Context) (*types.GrantedLicense,
 		// if we could not get the original license, attempt to retrieve the license for Emea countries
 		license, newErr := c.getLicenseForProductID(ctx, rancherProductSKUEmea)
 		if newErr != nil {
-			return nil, fmt.Errorf("unable to get license for non-emea: %s, unable to get license for emea: %s", err.Error(), newErr.Error())
+			return nil, fmt.Errorf("unable to get license for non-emea: %s, unable to get license for emea: %s (licenses are only listed in the region they are homed in, client region is %q)",
+				err.Error(), newErr.Error(), c.currentRegion())
 		}
-		return license, nil
+		return license, c.checkLicenseRegion(ctx, license)
 	}
-	return license, nil
+	return license, c.checkLicenseRegion(ctx, license)
+}
+
+// RegionMismatchError is returned when the rancher license is homed in a different region than the one the client is
+// configured for. License manager calls for the license will fail until the region is corrected
+type RegionMismatchError struct {
+	LicenseRegion string
+	ClientRegion  string
+}
+
+func (e *RegionMismatchError) Error() string {
+	return fmt.Sprintf("rancher license is homed in region %s but the adapter is configured for region %s, set the region to %s",
+		e.LicenseRegion, e.ClientRegion, e.LicenseRegion)
+}
+
+// checkLicenseRegion verifies that license is homed in the client's region. If it isn't, the client either switches to
+// the license's region (if configured to) or returns a RegionMismatchError
+func (c *client) checkLicenseRegion(ctx context.Context, license *types.GrantedLicense) error {
+	switched, err := c.switchLicenseRegion(license)
+	if switched {
+		// looked up without holding the regionLock, so that calls in flight aren't held up by sts
+		c.refreshAccount(ctx)
+	}
+	return err
+}
+
+// switchLicenseRegion switches the client to the region license is homed in if it differs and the client is configured
+// to, returning whether it switched
+func (c *client) switchLicenseRegion(license *types.GrantedLicense) (bool, error) {
+	licenseRegion := getLicenseRegion(license)
+	c.regionLock.Lock()
+	defer c.regionLock.Unlock()
+	if licenseRegion == "" || c.region == "" || licenseRegion == c.region {
+		// the region can't be determined for every license, assume that it's correct
+		return false, nil
+	}
+	if !c.opts.AutoSwitchRegion || c.newLM == nil {
+		return false, &RegionMismatchError{LicenseRegion: licenseRegion, ClientRegion: c.region}
+	}
+	logrus.Warnf("rancher license is homed in region %s, switching license manager calls from region %s", licenseRegion, c.region)
+	c.lm, c.lmWrite = c.newLM(licenseRegion)
+	c.region = licenseRegion
+	return true, nil
+}
+
+// currentRegion returns the region which license manager calls are issued in
+func (c *client) currentRegion() string {
+	c.regionLock.RLock()
+	defer c.regionLock.RUnlock()
+	return c.region
+}
+
+// getLicenseRegion returns the home region of license, falling back to the region in its arn
+// (arn:aws:license-manager:<region>:<account>:license:<id>)
+func getLicenseRegion(license *types.GrantedLicense) string {
+	if license.HomeRegion != nil && *license.HomeRegion != "" {
+		return *license.HomeRegion
+	}
+	if license.LicenseArn == nil {
+		return ""
+	}
+	arnParts := strings.Split(*license.LicenseArn, ":")
+	if len(arnParts) < 4 {
+		return ""
+	}
+	return arnParts[3]
 }
 
 func (c *client) getLicenseForProductID(ctx context.Context, productID string) (*types.GrantedLicense, error) {
-	// per aws engineering, there should only ever be at most one license for a given product sku.
-	input := &lm.ListReceivedLicensesInput{
-		Filters: []types.Filter{
-			{
-				Name:   &productSKUField,
-				Values: []string{productID},
-			},
-		},
-		MaxResults: &maxResults,
-	}
-
-	res, err := c.lm.ListReceivedLicenses(ctx, input)
+	license, err := c.findLicense(ctx, productID)
 	if err != nil {
 		return nil, err
 	}
-
-	if len(res.Licenses) == 0 {
+	if license == nil {
+		if len(c.opts.LicenseTags) > 0 {
+			return nil, fmt.Errorf("unable to find license for product id %s with tags %s", productID, formatTags(c.opts.LicenseTags))
+		}
 		return nil, fmt.Errorf("unable to find license for product id %s", productID)
 	}
+	return license, nil
+}
 
-	license := &res.Licenses[0]
+// findLicense returns the license received for productID, or nil if there is none. If the client is pinned by
+// LicenseTags, it's the first license received for productID whose tags match
+func (c *client) findLicense(ctx context.Context, productID string) (*types.GrantedLicense, error) {
+	licenses, err := c.listLicenses(ctx, productID)
+	if err != nil {
+		return nil, err
+	}
+
+	license, err := c.selectLicense(ctx, licenses)
+	if err != nil || license == nil {
+		return nil, err
+	}
 	if license.ProductSKU == nil {
 		// we expect this value to be set, but given that the value is a pointer we can't be sure
 		license.ProductSKU = &productID
 	}
+	c.cacheFingerprint(license)
 
 	return license, nil
 }
 
+// listLicenses returns every license received for productID. Per aws engineering, there should only ever be at most
+// one license for a given product sku, unless several agreements were made for it or it was renewed, but the filter
+// isn't guaranteed to fill a page, so every page is listed
+func (c *client) listLicenses(ctx context.Context, productID string) ([]types.GrantedLicense, error) {
+	input := currentAPI.listInput(productID, licensesPerPage)
+	var licenses []types.GrantedLicense
+	for page := 1; ; page++ {
+		res, err := c.reader().ListReceivedLicenses(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		licenses = append(licenses, res.Licenses...)
+		if aws.ToString(res.NextToken) == "" {
+			return licenses, nil
+		}
+		if page == maxLicensePages {
+			logrus.Warnf("listed %d pages of licenses received for product id %s, ignoring the remaining pages", page, productID)
+			return licenses, nil
+		}
+		input.NextToken = res.NextToken
+	}
+}
+
+// selectLicense returns the successor among licenses whose tags match the client's LicenseTags, or nil if none do
+func (c *client) selectLicense(ctx context.Context, licenses []types.GrantedLicense) (*types.GrantedLicense, error) {
+	if len(c.opts.LicenseTags) == 0 {
+		return c.successorLicense(licenses), nil
+	}
+	var matching []types.GrantedLicense
+	for i := range licenses {
+		if licenses[i].LicenseArn == nil {
+			continue
+		}
+		res, err := c.reader().ListTagsForResource(ctx, currentAPI.tagsInput(licenses[i].LicenseArn))
+		if err != nil {
+			return nil, fmt.Errorf("unable to get tags of license %s: %w", *licenses[i].LicenseArn, err)
+		}
+		if tagsMatch(res.Tags, c.opts.LicenseTags) {
+			matching = append(matching, licenses[i])
+			continue
+		}
+		logrus.Debugf("skipping license %s, its tags don't match %s", *licenses[i].LicenseArn, formatTags(c.opts.LicenseTags))
+	}
+	return c.successorLicense(matching), nil
+}
+
+// successorLicense returns the license of licenses which checkouts are made from, see selectValidLicense. When a grant
+// is renewed, the successor is received with a new arn while the grant it replaces is still valid, and the checkout
+// moves to the successor once the replaced grant is expiring. Returns the first license if none can be checked out, so
+// that the reason is reported when it's validated, or nil if there are no licenses
+func (c *client) successorLicense(licenses []types.GrantedLicense) *types.GrantedLicense {
+	if len(licenses) == 0 {
+		return nil
+	}
+	for i := range licenses {
+		c.cacheFingerprint(&licenses[i])
+	}
+	c.selectLock.Lock()
+	defer c.selectLock.Unlock()
+	if i := selectValidLicense(licenses, c.selected, time.Now(), c.opts.DimensionAliases...); i >= 0 {
+		c.selected = aws.ToString(licenses[i].LicenseArn)
+		return &licenses[i]
+	}
+	return &licenses[0]
+}
+
+// tagsMatch returns whether tags include every key and value of want
+func tagsMatch(tags []types.Tag, want map[string]string) bool {
+	found := 0
+	for _, tag := range tags {
+		if tag.Key == nil || tag.Value == nil {
+			continue
+		}
+		if value, ok := want[*tag.Key]; ok && value == *tag.Value {
+			found++
+		}
+	}
+	return found == len(want)
+}
+
+// formatTags formats tags as sorted key=value pairs, for messages
+func formatTags(tags map[string]string) string {
+	pairs := make([]string, 0, len(tags))
+	for key, value := range tags {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
 var (
 	entitlementDimension = "RKE_NODE_SUPP"
 )
 
-const (
-	entitlementUnit = "Count"
-)
+type checkoutTokenKey struct{}
+
+// WithCheckoutToken returns a context which makes CheckoutRancherLicense use token as the idempotency token of the
+// checkout. Repeating a checkout with the same token returns the consumption token of the original checkout rather than
+// checking out more licenses, which recovers checkouts whose result was lost
+func WithCheckoutToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, checkoutTokenKey{}, token)
+}
+
+// CheckoutTokenFromContext returns the idempotency token set by WithCheckoutToken, or an empty string
+func CheckoutTokenFromContext(ctx context.Context) string {
+	token, _ := ctx.Value(checkoutTokenKey{}).(string)
+	return token
+}
 
 func (c *client) CheckoutRancherLicense(ctx context.Context, l types.GrantedLicense, entitlementAmt int) (*lm.CheckoutLicenseOutput, error) {
+	c.cacheFingerprint(&l)
 	if l.Issuer == nil || l.Issuer.KeyFingerprint == nil {
 		if l.LicenseArn == nil {
 			return nil, fmt.Errorf("license is missing arn and KeyFingerprint/Issuer")
 		}
-		return nil, fmt.Errorf("license %s must have a KeyFingerprint for checkout", *l.LicenseArn)
+		return nil, &IssuerError{LicenseArn: *l.LicenseArn}
 	}
 
-	token := uuid.New().String()
-	entitlementStr := fmt.Sprintf("%d", entitlementAmt)
-	res, err := c.lm.CheckoutLicense(ctx, &lm.CheckoutLicenseInput{
-		CheckoutType:   types.CheckoutTypeProvisional,
-		ClientToken:    &token,
+	token := CheckoutTokenFromContext(ctx)
+	if token == "" {
+		token = uuid.New().String()
+	}
+	// licenses are checked out under the name of the dimension they grant, which is only an alias during a transition
+	dimension := entitlementDimension
+	if _, granted, err := getMaxRKEEntitlements(l, c.opts.DimensionAliases...); err == nil {
+		dimension = granted
+	}
+	amount, err := entitlement.Licenses(entitlementAmt)
+	if err != nil {
+		return nil, err
+	}
+	input, err := currentAPI.checkoutInput(checkoutRequest{
 		ProductSKU:     l.ProductSKU,
 		KeyFingerprint: l.Issuer.KeyFingerprint,
-		Entitlements: []types.EntitlementData{
-			{
-				Name:  &entitlementDimension,
-				Unit:  entitlementUnit,
-				Value: &entitlementStr,
-			},
-		},
+		Dimension:      dimension,
+		Amount:         amount,
+		ClientToken:    token,
+		Beneficiary:    c.opts.Beneficiary,
 	})
 	if err != nil {
 		return nil, err
 	}
+	res, err := c.writer().CheckoutLicense(ctx, input)
+	if err != nil {
+		return nil, err
+	}
 
 	return res, nil
 }
 
 func (c *client) CheckInRancherLicense(ctx context.Context, consumptionToken string) (*lm.CheckInLicenseOutput, error) {
-	res, err := c.lm.CheckInLicense(ctx, &lm.CheckInLicenseInput{LicenseConsumptionToken: &consumptionToken})
+	res, err := c.writer().CheckInLicense(ctx, currentAPI.checkInInput(consumptionToken))
 	if err != nil {
 		return nil, err
 	}
 	return res, nil
 }
 
+func (c *client) CheckInRancherLicenses(ctx context.Context, tokens []string) map[string]error {
+	return CheckInConcurrently(ctx, c, tokens, DefaultCheckInParallelism)
+}
+
 func (c *client) ExtendRancherLicenseConsumptionToken(ctx context.Context, consumptionToken string) (*lm.ExtendLicenseConsumptionOutput, error) {
-	res, err := c.lm.ExtendLicenseConsumption(ctx, &lm.ExtendLicenseConsumptionInput{LicenseConsumptionToken: &consumptionToken})
+	res, err := c.writer().ExtendLicenseConsumption(ctx, currentAPI.extendInput(consumptionToken))
 	if err != nil {
 		return nil, err
 	}
 	return res, nil
 }
 
+// reader returns the license manager client used for read calls
+func (c *client) reader() licenseManagerClient {
+	c.regionLock.RLock()
+	defer c.regionLock.RUnlock()
+	return c.lm
+}
+
+// writer returns the license manager client used for calls which change checkouts
+func (c *client) writer() licenseManagerClient {
+	c.regionLock.RLock()
+	defer c.regionLock.RUnlock()
+	if c.lmWrite != nil {
+		return c.lmWrite
+	}
+	return c.lm
+}
+
+func (c *client) CheckServiceHealth(ctx context.Context) error {
+	_, err := c.reader().ListReceivedLicenses(ctx, currentAPI.listInput("", maxResults))
+	return err
+}
+
 func (c *client) GetNumberOfAvailableEntitlements(ctx context.Context, license types.GrantedLicense) (int, error) {
-	res, err := c.lm.GetLicenseUsage(ctx, &lm.GetLicenseUsageInput{LicenseArn: license.LicenseArn})
+	usage, err := c.GetEntitlementUsage(ctx, license)
 	if err != nil {
 		// this function can't guarantee availability, so return 0 and an err so the caller can sort this out
 		return 0, err
 	}
-	maxEntitlements, err := getMaxRKEEntitlements(license)
+	// this should be safe to do - we rely on licenseManager to control if we are/are not allowed to go over
+	return usage.Available(), nil
+}
+
+// EntitlementUsage is the consumption of the RKE_NODE_SUPP entitlements of a license. Consumed includes checkouts made
+// by the adapter as well as those made outside of it, i.e. manually with the aws cli, under any alias of the dimension
+type EntitlementUsage struct {
+	Max      int
+	Consumed int
+	// Dimension is the name of the dimension the license grants, RKE_NODE_SUPP or one of ClientOptions.DimensionAliases
+	Dimension string
+	// Dimensions is the usage of every dimension the license grants a maximum of, starting with Dimension. Empty if
+	// the client only reports Dimension
+	Dimensions []DimensionUsage
+}
+
+// Available returns the number of entitlements which can still be checked out, 0 if more were consumed than granted
+func (u EntitlementUsage) Available() int {
+	if u.Consumed >= u.Max {
+		return 0
+	}
+	return u.Max - u.Consumed
+}
+
+// DimensionUsage is the consumption of a single dimension of a license, in the unit the dimension is granted in
+type DimensionUsage struct {
+	Name     string
+	Max      int64
+	Consumed int64
+}
+
+// Available returns the amount of the dimension which can still be checked out, 0 if more was consumed than granted
+func (u DimensionUsage) Available() int64 {
+	if u.Consumed >= u.Max {
+		return 0
+	}
+	return u.Max - u.Consumed
+}
+
+func (c *client) GetEntitlementUsage(ctx context.Context, license types.GrantedLicense) (EntitlementUsage, error) {
+	res, err := c.reader().GetLicenseUsage(ctx, currentAPI.usageInput(license.LicenseArn))
+	if err != nil {
+		return EntitlementUsage{}, err
+	}
+	maxEntitlements, dimension, err := getMaxRKEEntitlements(license, c.opts.DimensionAliases...)
 	if err != nil {
 		// if we can't figure out how many RKE nodes we can support at max, we can't see how many we have left
-		return 0, err
+		return EntitlementUsage{}, err
 	}
-	total := 0
-	for _, usage := range res.LicenseUsage.EntitlementUsages {
-		if *usage.Name == entitlementDimension {
-			consumedValue, err := strconv.Atoi(*usage.ConsumedValue)
+	usage := EntitlementUsage{Max: maxEntitlements, Dimension: dimension}
+	names := dimensionNames(c.opts.DimensionAliases)
+	var consumed entitlement.Quantity
+	// other is the consumption of the dimensions besides RKE_NODE_SUPP, by name
+	other := map[string]int64{}
+	for _, entitlementUsage := range res.LicenseUsage.EntitlementUsages {
+		name := aws.ToString(entitlementUsage.Name)
+		if !names[name] {
+			// only exported, so a dimension in a unit the adapter doesn't know doesn't fail the usage
+			amount, err := strconv.ParseInt(strings.TrimSpace(aws.ToString(entitlementUsage.ConsumedValue)), 10, 64)
 			if err != nil {
-				return 0, err
+				logrus.Debugf("unable to read the usage of %s: %v", name, err)
+				continue
 			}
-			total += consumedValue
+			other[name] += amount
+			continue
+		}
+		// checkouts made under the previous name of a renamed dimension are still consumed until they're checked in
+		value, err := entitlement.Parse(aws.ToString(entitlementUsage.ConsumedValue), string(entitlementUsage.Unit))
+		if err != nil {
+			return EntitlementUsage{}, fmt.Errorf("unable to read the usage of %s: %w", name, err)
+		}
+		if consumed, err = consumed.Add(value); err != nil {
+			return EntitlementUsage{}, fmt.Errorf("unable to read the usage of %s: %w", name, err)
 		}
 	}
-	// this should be safe to do - we rely on licenseManager to control if we are/are not allowed to go over
-	return maxEntitlements - total, nil
+	if usage.Consumed, err = consumed.Int(); err != nil {
+		return EntitlementUsage{}, err
+	}
+	usage.Dimensions = []DimensionUsage{{Name: dimension, Max: int64(usage.Max), Consumed: int64(usage.Consumed)}}
+	for _, granted := range license.Entitlements {
+		name := aws.ToString(granted.Name)
+		// unlimited dimensions have no maximum to report
+		if names[name] || granted.MaxCount == nil {
+			continue
+		}
+		usage.Dimensions = append(usage.Dimensions, DimensionUsage{Name: name, Max: *granted.MaxCount, Consumed: other[name]})
+	}
+	return usage, nil
 }
 
-func getMaxRKEEntitlements(license types.GrantedLicense) (int, error) {
-	for _, entitlement := range license.Entitlements {
-		if *entitlement.Name == entitlementDimension {
-			return int(*entitlement.MaxCount), nil
+// getMaxRKEEntitlements returns the number of RKE_NODE_SUPP entitlements granted by license and the name of the
+// dimension they're granted under. If the license grants the dimension under several of its names, RKE_NODE_SUPP is
+// preferred over aliases, and aliases in their order, so that entitlements aren't counted twice
+func getMaxRKEEntitlements(license types.GrantedLicense, aliases ...string) (int, string, error) {
+	for _, dimension := range append([]string{entitlementDimension}, aliases...) {
+		for _, granted := range license.Entitlements {
+			if aws.ToString(granted.Name) != dimension {
+				continue
+			}
+			if granted.MaxCount == nil {
+				return 0, dimension, nil
+			}
+			unit, err := entitlement.ParseUnit(string(granted.Unit))
+			if err != nil {
+				return 0, dimension, err
+			}
+			maxCount, err := entitlement.New(*granted.MaxCount, unit)
+			if err != nil {
+				return 0, dimension, err
+			}
+			if unit != entitlement.Count {
+				return 0, dimension, fmt.Errorf("entitlement %s is granted in %s, only %s is supported", dimension, unit, entitlement.Count)
+			}
+			max, err := maxCount.Int()
+			return max, dimension, err
 		}
 	}
-	return 0, fmt.Errorf("entitlement %s not found on license for %s", entitlementDimension, *license.LicenseArn)
+	return 0, "", &EntitlementError{LicenseArn: aws.ToString(license.LicenseArn), Missing: true, Aliases: aliases}
+}
+
+// dimensionNames returns the set of names of the RKE_NODE_SUPP dimension
+func dimensionNames(aliases []string) map[string]bool {
+	names := map[string]bool{entitlementDimension: true}
+	for _, alias := range aliases {
+		names[alias] = true
+	}
+	return names
+}
+
+// EntitlementError is returned when a rancher license was granted, but it doesn't grant any RKE_NODE_SUPP
+// entitlements. This can only be fixed by the seller of the license
+type EntitlementError struct {
+	LicenseArn string
+	// Missing is true if the license has no RKE_NODE_SUPP entitlement, false if the entitlement has a MaxCount of 0
+	Missing bool
+	// Aliases are the other names of the dimension which were looked for
+	Aliases []string
+}
+
+func (e *EntitlementError) Error() string {
+	dimension := entitlementDimension
+	if len(e.Aliases) > 0 {
+		dimension = fmt.Sprintf("%s (or %s)", entitlementDimension, strings.Join(e.Aliases, ", "))
+	}
+	if e.Missing {
+		return fmt.Sprintf("entitlement %s not found on license for %s", dimension, e.LicenseArn)
+	}
+	return fmt.Sprintf("license %s grants 0 %s entitlements", e.LicenseArn, dimension)
+}
+
+// ValidateEntitlements returns an EntitlementError if license doesn't grant at least one RKE_NODE_SUPP entitlement,
+// under its own name or one of aliases
+func ValidateEntitlements(license types.GrantedLicense, aliases ...string) error {
+	maxEntitlements, _, err := getMaxRKEEntitlements(license, aliases...)
+	if err != nil {
+		return err
+	}
+	if maxEntitlements <= 0 {
+		return &EntitlementError{LicenseArn: aws.ToString(license.LicenseArn), Aliases: aliases}
+	}
+	return nil
 }
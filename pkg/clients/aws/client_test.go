@@ -2,8 +2,15 @@ package aws
 
 import (
 	"context"
+	"errors"
+	"net/http"
+	"path/filepath"
 	"testing"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	lm "github.com/aws/aws-sdk-go-v2/service/licensemanager"
+	"github.com/aws/aws-sdk-go-v2/service/licensemanager/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -94,3 +101,350 @@ func TestGetRancherLicense(t *testing.T) {
 		})
 	}
 }
+
+func TestGetRancherLicenseRegion(t *testing.T) {
+	tests := []struct {
+		name             string
+		licenseRegion    string
+		clientRegion     string
+		autoSwitch       bool
+		expectedRegion   string
+		expectedMismatch bool
+	}{
+		{
+			name:           "same region",
+			licenseRegion:  "us-east-1",
+			clientRegion:   "us-east-1",
+			expectedRegion: "us-east-1",
+		},
+		{
+			name:           "license region unknown",
+			licenseRegion:  "",
+			clientRegion:   "us-east-1",
+			expectedRegion: "us-east-1",
+		},
+		{
+			name:             "mismatch is reported",
+			licenseRegion:    "us-west-2",
+			clientRegion:     "us-east-1",
+			expectedRegion:   "us-east-1",
+			expectedMismatch: true,
+		},
+		{
+			name:           "mismatch with auto switch",
+			licenseRegion:  "us-west-2",
+			clientRegion:   "us-east-1",
+			autoSwitch:     true,
+			expectedRegion: "us-west-2",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			mockLMClient := mockLicenseManagerClient{}
+			mockLMClient.AddLicenseForSku(rancherProductSKUNonEmea, fakeAccountNum, true)
+			license := mockLMClient.licenses[rancherProductSKUNonEmea]
+			license.HomeRegion = &test.licenseRegion
+			mockLMClient.licenses[rancherProductSKUNonEmea] = license
+			var switchedTo string
+			client := &client{
+				acctNum: fakeAccountNum,
+				region:  test.clientRegion,
+				opts:    ClientOptions{AutoSwitchRegion: test.autoSwitch},
+				lm:      &mockLMClient,
+				sts:     &mockSTSClient{accountNumber: "210987654321"},
+				newLM: func(region string) (licenseManagerClient, licenseManagerClient) {
+					switchedTo = region
+					return &mockLMClient, nil
+				},
+			}
+
+			_, err := client.GetRancherLicense(context.Background())
+			var regionErr *RegionMismatchError
+			assert.Equal(t, test.expectedMismatch, errors.As(err, &regionErr), "unexpected region mismatch result, err: %v", err)
+			if !test.expectedMismatch {
+				assert.NoError(t, err, "no error was expected, but got an error")
+			}
+			assert.Equal(t, test.expectedRegion, client.region, "client is using the wrong region")
+			if test.autoSwitch {
+				assert.Equal(t, test.licenseRegion, switchedTo, "license manager client was not switched to license region")
+				assert.Equal(t, "210987654321", client.AccountNumber(), "the account number is looked up again after switching regions")
+			} else {
+				assert.Equal(t, fakeAccountNum, client.AccountNumber(), "the account number is kept unless the region was switched")
+			}
+		})
+	}
+}
+
+func TestGetAccountAlias(t *testing.T) {
+	tests := []struct {
+		name          string
+		iam           iamClient
+		expectedAlias string
+	}{
+		{
+			name:          "alias",
+			iam:           &mockIAMClient{aliases: []string{"rancher-prod"}},
+			expectedAlias: "rancher-prod",
+		},
+		{
+			name: "no alias",
+			iam:  &mockIAMClient{},
+		},
+		{
+			name: "not permitted",
+			iam:  &mockIAMClient{err: errors.New("AccessDenied: not authorized to perform iam:ListAccountAliases")},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			client := &client{acctNum: fakeAccountNum, iam: test.iam}
+			assert.Equal(t, test.expectedAlias, client.getAccountAlias(context.Background(), fakeAccountNum))
+		})
+	}
+}
+
+func TestValidateEntitlements(t *testing.T) {
+	licenseArn := "arn:aws:license-manager::123456789101:license:l-000000"
+	rkeEntitlement := entitlementDimension
+	otherEntitlement := "OTHER_DIMENSION"
+	zero, two := int64(0), int64(2)
+	tests := []struct {
+		name            string
+		entitlements    []types.Entitlement
+		aliases         []string
+		expectedErr     bool
+		expectedMissing bool
+	}{
+		{
+			name:         "entitlements granted",
+			entitlements: []types.Entitlement{{Name: &rkeEntitlement, MaxCount: &two}},
+		},
+		{
+			name:         "zero entitlements granted",
+			entitlements: []types.Entitlement{{Name: &rkeEntitlement, MaxCount: &zero}},
+			expectedErr:  true,
+		},
+		{
+			name:            "rke entitlement missing",
+			entitlements:    []types.Entitlement{{Name: &otherEntitlement, MaxCount: &two}},
+			expectedErr:     true,
+			expectedMissing: true,
+		},
+		{
+			name:         "entitlements granted under alias",
+			entitlements: []types.Entitlement{{Name: &otherEntitlement, MaxCount: &two}},
+			aliases:      []string{otherEntitlement},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			err := ValidateEntitlements(types.GrantedLicense{LicenseArn: &licenseArn, Entitlements: test.entitlements}, test.aliases...)
+			if !test.expectedErr {
+				assert.NoError(t, err, "no error was expected, but got an error")
+				return
+			}
+			var entitlementErr *EntitlementError
+			assert.True(t, errors.As(err, &entitlementErr), "expected an EntitlementError, got %v", err)
+			assert.Equal(t, test.expectedMissing, entitlementErr.Missing)
+		})
+	}
+}
+
+func TestDimensionAliases(t *testing.T) {
+	mockLMClient := mockLicenseManagerClient{}
+	mockLMClient.Clear()
+	mockLMClient.AddLicenseForSku(rancherProductSKUNonEmea, fakeAccountNum, true)
+	license := mockLMClient.licenses[rancherProductSKUNonEmea]
+	fingerprint := "aws:294406891311:AWS/Marketplace:issuer-fingerprint"
+	renamed, five := "RANCHER_NODE", int64(5)
+	storage, hundred := "STORAGE_GB", int64(100)
+	license.Issuer = &types.IssuerDetails{KeyFingerprint: &fingerprint}
+	license.Entitlements = []types.Entitlement{
+		{Name: &renamed, MaxCount: &five},
+		{Name: &storage, MaxCount: &hundred, Unit: types.EntitlementUnitGigabytes},
+	}
+	mockLMClient.licenses[rancherProductSKUNonEmea] = license
+	ctx := context.Background()
+
+	// checked out before the dimension was renamed
+	before := &client{acctNum: fakeAccountNum, lm: &mockLMClient}
+	_, err := before.CheckoutRancherLicense(ctx, license, 2)
+	assert.NoError(t, err)
+	_, err = before.GetEntitlementUsage(ctx, license)
+	assert.Error(t, err, "the renamed dimension shouldn't be recognized without an alias")
+
+	after := &client{acctNum: fakeAccountNum, lm: &mockLMClient, opts: ClientOptions{DimensionAliases: []string{renamed}}}
+	assert.NoError(t, after.ValidateLicense(license))
+	res, err := after.CheckoutRancherLicense(ctx, license, 1)
+	assert.NoError(t, err)
+	input := mockLMClient.checkedOutLicenses[*res.LicenseConsumptionToken].checkOutInput
+	assert.Equal(t, renamed, aws.ToString(input.Entitlements[0].Name), "licenses should be checked out under the dimension they grant")
+	usage, err := after.GetEntitlementUsage(ctx, license)
+	assert.NoError(t, err)
+	assert.Equal(t, EntitlementUsage{
+		Max:       5,
+		Consumed:  3,
+		Dimension: renamed,
+		Dimensions: []DimensionUsage{
+			{Name: renamed, Max: 5, Consumed: 3},
+			{Name: storage, Max: 100},
+		},
+	}, usage, "every dimension the license grants should be reported")
+}
+
+func TestCheckoutRancherLicenseBeneficiary(t *testing.T) {
+	tests := []struct {
+		name        string
+		beneficiary string
+	}{
+		{name: "no beneficiary", beneficiary: ""},
+		{name: "cost center beneficiary", beneficiary: "cost-center-1234"},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			mockLMClient := mockLicenseManagerClient{}
+			mockLMClient.Clear()
+			mockLMClient.AddLicenseForSku(rancherProductSKUNonEmea, fakeAccountNum, true)
+			license := mockLMClient.licenses[rancherProductSKUNonEmea]
+			fingerprint := "aws:294406891311:AWS/Marketplace:issuer-fingerprint"
+			license.Issuer = &types.IssuerDetails{KeyFingerprint: &fingerprint}
+			client := &client{
+				acctNum: fakeAccountNum,
+				opts:    ClientOptions{Beneficiary: test.beneficiary},
+				lm:      &mockLMClient,
+			}
+
+			res, err := client.CheckoutRancherLicense(context.Background(), license, 2)
+			assert.NoError(t, err)
+			input := mockLMClient.checkedOutLicenses[*res.LicenseConsumptionToken].checkOutInput
+			if test.beneficiary == "" {
+				assert.Nil(t, input.Beneficiary, "expected no beneficiary to be set")
+			} else {
+				assert.Equal(t, test.beneficiary, aws.ToString(input.Beneficiary))
+			}
+		})
+	}
+}
+
+func TestGetRancherLicenseTags(t *testing.T) {
+	mockLMClient := mockLicenseManagerClient{}
+	mockLMClient.Clear()
+	// an untagged grant for the same sku, i.e. a test agreement
+	mockLMClient.AddLicenseForSku(rancherProductSKUNonEmea, fakeAccountNum, true)
+	mockLMClient.AddTaggedLicense(rancherProductSKUNonEmea, fakeAccountNum, map[string]string{"environment": "staging"})
+	productionArn := mockLMClient.AddTaggedLicense(rancherProductSKUNonEmea, fakeAccountNum, map[string]string{"environment": "production", "team": "platform"})
+
+	tests := []struct {
+		name        string
+		tags        map[string]string
+		expectedArn string
+		errDesired  bool
+	}{
+		{name: "no tags picks first license", expectedArn: *mockLMClient.licenses[rancherProductSKUNonEmea].LicenseArn},
+		{name: "tags pin license", tags: map[string]string{"environment": "production"}, expectedArn: productionArn},
+		{name: "every tag must match", tags: map[string]string{"environment": "production", "team": "data"}, errDesired: true},
+		{name: "no matching license", tags: map[string]string{"environment": "dev"}, errDesired: true},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			client := &client{
+				acctNum: fakeAccountNum,
+				opts:    ClientOptions{LicenseTags: test.tags},
+				lm:      &mockLMClient,
+			}
+			license, err := client.GetRancherLicense(context.Background())
+			if test.errDesired {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedArn, aws.ToString(license.LicenseArn))
+		})
+	}
+}
+
+func TestGetRancherLicensePages(t *testing.T) {
+	defaultPerPage := licensesPerPage
+	licensesPerPage = 1
+	defer func() {
+		licensesPerPage = defaultPerPage
+	}()
+	mockLMClient := mockLicenseManagerClient{}
+	mockLMClient.Clear()
+	// grants from several sellers, the pinned license is only on the last page
+	mockLMClient.AddTaggedLicense(rancherProductSKUNonEmea, fakeAccountNum, map[string]string{"seller": "reseller-a"})
+	mockLMClient.AddTaggedLicense(rancherProductSKUNonEmea, fakeAccountNum, map[string]string{"seller": "reseller-b"})
+	pinnedArn := mockLMClient.AddTaggedLicense(rancherProductSKUNonEmea, fakeAccountNum, map[string]string{"seller": "suse"})
+	client := &client{
+		acctNum: fakeAccountNum,
+		opts:    ClientOptions{LicenseTags: map[string]string{"seller": "suse"}},
+		lm:      &mockLMClient,
+	}
+	license, err := client.GetRancherLicense(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, pinnedArn, aws.ToString(license.LicenseArn), "every page of licenses should be listed")
+}
+
+func TestSplitCredentials(t *testing.T) {
+	readClient := mockLicenseManagerClient{}
+	readClient.Clear()
+	readClient.AddLicenseForSku(rancherProductSKUNonEmea, fakeAccountNum, true)
+	writeClient := mockLicenseManagerClient{}
+	writeClient.Clear()
+	client := &client{
+		acctNum: fakeAccountNum,
+		lm:      &readClient,
+		lmWrite: &writeClient,
+		sts:     &mockSTSClient{accountNumber: fakeAccountNum},
+	}
+
+	license, err := client.GetRancherLicense(context.Background())
+	assert.NoError(t, err, "license should be read with the read credentials")
+	fingerprint := "aws:294406891311:AWS/Marketplace:issuer-fingerprint"
+	license.Issuer = &types.IssuerDetails{KeyFingerprint: &fingerprint}
+	_, err = client.CheckoutRancherLicense(context.Background(), *license, 1)
+	assert.NoError(t, err)
+	assert.Len(t, writeClient.checkedOutLicenses, 1, "checkout should use the write credentials")
+	assert.Empty(t, readClient.checkedOutLicenses, "checkout shouldn't use the read credentials")
+}
+
+// hostRecorder fails every request, recording the host it was sent to
+type hostRecorder struct {
+	hosts []string
+}
+
+func (h *hostRecorder) Do(req *http.Request) (*http.Response, error) {
+	h.hosts = append(h.hosts, req.URL.Host)
+	return nil, errors.New("not sending requests in tests")
+}
+
+func TestDualStackEndpoints(t *testing.T) {
+	t.Setenv("AWS_REGION", "us-east-1")
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+	tests := []struct {
+		dualStack bool
+		lmHost    string
+		stsHost   string
+	}{
+		{dualStack: false, lmHost: "license-manager.us-east-1.amazonaws.com", stsHost: "sts.us-east-1.amazonaws.com"},
+		{dualStack: true, lmHost: "license-manager.us-east-1.api.aws", stsHost: "sts.us-east-1.api.aws"},
+	}
+	for _, test := range tests {
+		cfg, err := loadConfig(context.Background(), ClientOptions{DualStack: test.dualStack})
+		assert.NoError(t, err)
+		recorder := &hostRecorder{}
+		cfg.HTTPClient = recorder
+		cfg.RetryMaxAttempts = 1
+		_, _ = lm.NewFromConfig(cfg).ListReceivedLicenses(context.Background(), &lm.ListReceivedLicensesInput{})
+		_, _ = sts.NewFromConfig(cfg).GetCallerIdentity(context.Background(), &sts.GetCallerIdentityInput{})
+		assert.Equal(t, []string{test.lmHost, test.stsHost}, recorder.hosts, "dual-stack: %t", test.dualStack)
+	}
+}
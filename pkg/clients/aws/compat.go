@@ -0,0 +1,161 @@
+package aws
+
+import (
+	"fmt"
+
+	lm "github.com/aws/aws-sdk-go-v2/service/licensemanager"
+	"github.com/aws/aws-sdk-go-v2/service/licensemanager/types"
+	"github.com/rancher/csp-adapter/pkg/entitlement"
+)
+
+// The License Manager api changes independently of the adapter, i.e. fields become required or checkout types are
+// deprecated. Every request is built by the compatibility layer below rather than at its call site, so that such a
+// change is handled by adding a revision to apiRevisions, detected from the sdk the adapter is built with, instead of
+// by changing every call in the same commit as the sdk bump which brings it in
+
+// apiRevision describes how requests are built for one version of the License Manager api
+type apiRevision struct {
+	// version is the api version, lm.ServiceAPIVersion of the sdks generated from it
+	version string
+	// checkoutTypes are the types of checkouts which return a consumption token, in order of preference. The first
+	// type known to the sdk is used, so that a deprecated type is replaced once the sdk drops it
+	checkoutTypes []types.CheckoutType
+	// checkout sets the fields this revision requires beyond those of the first revision, nil if there are none
+	checkout func(input *lm.CheckoutLicenseInput, req checkoutRequest)
+}
+
+// apiRevisions are the known versions of the api, oldest first
+var apiRevisions = []apiRevision{
+	{
+		version:       "2018-08-01",
+		checkoutTypes: []types.CheckoutType{types.CheckoutTypeProvisional},
+	},
+}
+
+// checkoutRequest is a checkout of the adapter, independent of the api version it's made with
+type checkoutRequest struct {
+	ProductSKU     *string
+	KeyFingerprint *string
+	// Dimension is the name the entitlements are checked out under
+	Dimension string
+	Amount    entitlement.Quantity
+	// ClientToken makes the checkout idempotent
+	ClientToken string
+	// Beneficiary is recorded as the beneficiary of the checkout if set
+	Beneficiary string
+}
+
+// licenseManagerAPI builds the requests of the api version of the sdk
+type licenseManagerAPI struct {
+	revision apiRevision
+	// known is false if the sdk's api version isn't among apiRevisions, requests are then built like those of the
+	// latest known revision
+	known bool
+	// checkoutTypes are the checkout types known to the sdk
+	checkoutTypes []types.CheckoutType
+}
+
+// currentAPI builds the requests of the sdk the adapter is built with
+var currentAPI = detectAPI(lm.ServiceAPIVersion, types.CheckoutType("").Values())
+
+// detectAPI returns the compatibility layer for the api version an sdk was generated from, and the checkout types it
+// knows
+func detectAPI(version string, checkoutTypes []types.CheckoutType) licenseManagerAPI {
+	api := licenseManagerAPI{
+		revision:      apiRevisions[len(apiRevisions)-1],
+		checkoutTypes: checkoutTypes,
+	}
+	for _, revision := range apiRevisions {
+		if revision.version == version {
+			api.revision = revision
+			api.known = true
+		}
+	}
+	return api
+}
+
+// CompatibilityError is returned when a request can't be made with the api version of the sdk, i.e. because the sdk no
+// longer knows a checkout type which returns a consumption token
+type CompatibilityError struct {
+	Version string
+	Call    string
+	Reason  string
+}
+
+func (e *CompatibilityError) Error() string {
+	return fmt.Sprintf("%s isn't supported by License Manager api version %s: %s", e.Call, e.Version, e.Reason)
+}
+
+// checkoutType returns the preferred checkout type of the revision which the sdk knows
+func (a licenseManagerAPI) checkoutType() (types.CheckoutType, error) {
+	for _, preferred := range a.revision.checkoutTypes {
+		for _, known := range a.checkoutTypes {
+			if preferred == known {
+				return preferred, nil
+			}
+		}
+	}
+	return "", &CompatibilityError{
+		Version: a.revision.version,
+		Call:    "CheckoutLicense",
+		Reason:  fmt.Sprintf("none of the checkout types %v are known to the sdk", a.revision.checkoutTypes),
+	}
+}
+
+func (a licenseManagerAPI) checkoutInput(req checkoutRequest) (*lm.CheckoutLicenseInput, error) {
+	checkoutType, err := a.checkoutType()
+	if err != nil {
+		return nil, err
+	}
+	amount := req.Amount.String()
+	input := &lm.CheckoutLicenseInput{
+		CheckoutType:   checkoutType,
+		ClientToken:    &req.ClientToken,
+		ProductSKU:     req.ProductSKU,
+		KeyFingerprint: req.KeyFingerprint,
+		Entitlements: []types.EntitlementData{
+			{
+				Name:  &req.Dimension,
+				Unit:  types.EntitlementDataUnit(req.Amount.Unit()),
+				Value: &amount,
+			},
+		},
+	}
+	if req.Beneficiary != "" {
+		input.Beneficiary = &req.Beneficiary
+	}
+	if a.revision.checkout != nil {
+		a.revision.checkout(input, req)
+	}
+	return input, nil
+}
+
+func (a licenseManagerAPI) checkInInput(consumptionToken string) *lm.CheckInLicenseInput {
+	return &lm.CheckInLicenseInput{LicenseConsumptionToken: &consumptionToken}
+}
+
+func (a licenseManagerAPI) extendInput(consumptionToken string) *lm.ExtendLicenseConsumptionInput {
+	return &lm.ExtendLicenseConsumptionInput{LicenseConsumptionToken: &consumptionToken}
+}
+
+func (a licenseManagerAPI) usageInput(licenseArn *string) *lm.GetLicenseUsageInput {
+	return &lm.GetLicenseUsageInput{LicenseArn: licenseArn}
+}
+
+func (a licenseManagerAPI) tagsInput(resourceArn *string) *lm.ListTagsForResourceInput {
+	return &lm.ListTagsForResourceInput{ResourceArn: resourceArn}
+}
+
+// listInput lists up to limit received licenses, only those for productSKU unless it's empty
+func (a licenseManagerAPI) listInput(productSKU string, limit int32) *lm.ListReceivedLicensesInput {
+	input := &lm.ListReceivedLicensesInput{MaxResults: &limit}
+	if productSKU != "" {
+		input.Filters = []types.Filter{
+			{
+				Name:   &productSKUField,
+				Values: []string{productSKU},
+			},
+		}
+	}
+	return input
+}
@@ -0,0 +1,105 @@
+package aws
+
+import (
+	"errors"
+	"testing"
+
+	lm "github.com/aws/aws-sdk-go-v2/service/licensemanager"
+	"github.com/aws/aws-sdk-go-v2/service/licensemanager/types"
+	"github.com/rancher/csp-adapter/pkg/entitlement"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectAPI(t *testing.T) {
+	assert.True(t, currentAPI.known, "the api version of the sdk the adapter is built with should be known")
+
+	api := detectAPI("2030-01-01", types.CheckoutType("").Values())
+	assert.False(t, api.known)
+	assert.Equal(t, apiRevisions[len(apiRevisions)-1].version, api.revision.version, "unknown versions are built like the latest known one")
+}
+
+func TestCheckoutInput(t *testing.T) {
+	sku, fingerprint := "sku", "fingerprint"
+	amount, err := entitlement.Licenses(3)
+	require.NoError(t, err)
+	req := checkoutRequest{
+		ProductSKU:     &sku,
+		KeyFingerprint: &fingerprint,
+		Dimension:      "RKE_NODE_SUPP",
+		Amount:         amount,
+		ClientToken:    "token",
+	}
+	tests := []struct {
+		name          string
+		revision      apiRevision
+		checkoutTypes []types.CheckoutType
+		expectedType  types.CheckoutType
+		expectedNode  string
+		wantErr       bool
+	}{
+		{
+			name:          "current revision",
+			revision:      apiRevisions[0],
+			checkoutTypes: types.CheckoutType("").Values(),
+			expectedType:  types.CheckoutTypeProvisional,
+		},
+		{
+			name: "deprecated checkout type dropped by the sdk",
+			revision: apiRevision{
+				version:       "2030-01-01",
+				checkoutTypes: []types.CheckoutType{types.CheckoutTypeProvisional, "METERED"},
+			},
+			checkoutTypes: []types.CheckoutType{"METERED", types.CheckoutTypePerpetual},
+			expectedType:  "METERED",
+		},
+		{
+			name:          "no checkout type known to the sdk",
+			revision:      apiRevisions[0],
+			checkoutTypes: []types.CheckoutType{types.CheckoutTypePerpetual},
+			wantErr:       true,
+		},
+		{
+			name: "newly required field",
+			revision: apiRevision{
+				version:       "2030-01-01",
+				checkoutTypes: []types.CheckoutType{types.CheckoutTypeProvisional},
+				checkout: func(input *lm.CheckoutLicenseInput, req checkoutRequest) {
+					node := "rancher"
+					input.NodeId = &node
+				},
+			},
+			checkoutTypes: types.CheckoutType("").Values(),
+			expectedType:  types.CheckoutTypeProvisional,
+			expectedNode:  "rancher",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			api := licenseManagerAPI{revision: test.revision, known: true, checkoutTypes: test.checkoutTypes}
+			input, err := api.checkoutInput(req)
+			if test.wantErr {
+				var compatErr *CompatibilityError
+				assert.True(t, errors.As(err, &compatErr))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedType, input.CheckoutType)
+			assert.Equal(t, "3", *input.Entitlements[0].Value)
+			assert.Nil(t, input.Beneficiary)
+			if test.expectedNode != "" {
+				assert.Equal(t, test.expectedNode, *input.NodeId)
+			}
+		})
+	}
+}
+
+func TestListInput(t *testing.T) {
+	assert.Empty(t, currentAPI.listInput("", 1).Filters)
+	input := currentAPI.listInput(rancherProductSKUEmea, 100)
+	assert.Equal(t, int32(100), *input.MaxResults)
+	if assert.Len(t, input.Filters, 1) {
+		assert.Equal(t, []string{rancherProductSKUEmea}, input.Filters[0].Values)
+	}
+}
@@ -0,0 +1,86 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// useDualStackProvider and useFIPSProvider are implemented by the config sources of an aws.Config which carry the
+// endpoint settings, i.e. the environment (AWS_USE_DUALSTACK_ENDPOINT, AWS_USE_FIPS_ENDPOINT) and the shared config
+type useDualStackProvider interface {
+	GetUseDualStackEndpoint(context.Context) (aws.DualStackEndpointState, bool, error)
+}
+
+type useFIPSProvider interface {
+	GetUseFIPSEndpoint(context.Context) (aws.FIPSEndpointState, bool, error)
+}
+
+// serviceEndpoint returns the endpoint of a service which is called without its sdk module in the region of cfg. It
+// uses the endpoint resolver of cfg if it resolves the service, and otherwise builds the endpoint from prefix, the
+// dual-stack and FIPS settings of cfg and the domain of the region's partition, as the sdk modules do
+func serviceEndpoint(ctx context.Context, cfg aws.Config, serviceID, prefix string) (string, error) {
+	if cfg.EndpointResolverWithOptions != nil {
+		endpoint, err := cfg.EndpointResolverWithOptions.ResolveEndpoint(serviceID, cfg.Region)
+		var notFound *aws.EndpointNotFoundError
+		if err == nil {
+			return endpoint.URL, nil
+		} else if !errors.As(err, &notFound) {
+			return "", fmt.Errorf("unable to resolve the %s endpoint: %w", serviceID, err)
+		}
+	}
+	dualStack, err := useDualStack(ctx, cfg.ConfigSources)
+	if err != nil {
+		return "", err
+	}
+	fips, err := useFIPS(ctx, cfg.ConfigSources)
+	if err != nil {
+		return "", err
+	}
+	if fips {
+		prefix += "-fips"
+	}
+	domain := "amazonaws.com"
+	switch {
+	case strings.HasPrefix(cfg.Region, "cn-") && dualStack:
+		domain = "api.amazonwebservices.com.cn"
+	case strings.HasPrefix(cfg.Region, "cn-"):
+		domain = "amazonaws.com.cn"
+	case strings.HasPrefix(cfg.Region, "us-isob-"):
+		domain = "sc2s.sgov.gov"
+	case strings.HasPrefix(cfg.Region, "us-iso-"):
+		domain = "c2s.ic.gov"
+	case dualStack:
+		domain = "api.aws"
+	}
+	return fmt.Sprintf("https://%s.%s.%s", prefix, cfg.Region, domain), nil
+}
+
+// useDualStack returns the dual-stack setting of the first config source which has one
+func useDualStack(ctx context.Context, sources []interface{}) (bool, error) {
+	for _, source := range sources {
+		if p, ok := source.(useDualStackProvider); ok {
+			state, found, err := p.GetUseDualStackEndpoint(ctx)
+			if err != nil || found {
+				return state == aws.DualStackEndpointStateEnabled, err
+			}
+		}
+	}
+	return false, nil
+}
+
+// useFIPS returns the FIPS setting of the first config source which has one
+func useFIPS(ctx context.Context, sources []interface{}) (bool, error) {
+	for _, source := range sources {
+		if p, ok := source.(useFIPSProvider); ok {
+			state, found, err := p.GetUseFIPSEndpoint(ctx)
+			if err != nil || found {
+				return state == aws.FIPSEndpointStateEnabled, err
+			}
+		}
+	}
+	return false, nil
+}
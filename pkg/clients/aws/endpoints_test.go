@@ -0,0 +1,76 @@
+package aws
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServiceEndpoint(t *testing.T) {
+	tests := []struct {
+		name     string
+		region   string
+		options  config.LoadOptions
+		resolver aws.EndpointResolverWithOptions
+		expected string
+	}{
+		{
+			name:     "default",
+			region:   "us-east-1",
+			expected: "https://license-manager-user-subscriptions.us-east-1.amazonaws.com",
+		},
+		{
+			name:     "china",
+			region:   "cn-north-1",
+			expected: "https://license-manager-user-subscriptions.cn-north-1.amazonaws.com.cn",
+		},
+		{
+			name:     "dual-stack",
+			region:   "eu-west-1",
+			options:  config.LoadOptions{UseDualStackEndpoint: aws.DualStackEndpointStateEnabled},
+			expected: "https://license-manager-user-subscriptions.eu-west-1.api.aws",
+		},
+		{
+			name:     "fips",
+			region:   "us-gov-west-1",
+			options:  config.LoadOptions{UseFIPSEndpoint: aws.FIPSEndpointStateEnabled},
+			expected: "https://license-manager-user-subscriptions-fips.us-gov-west-1.amazonaws.com",
+		},
+		{
+			name:   "resolver",
+			region: "us-east-1",
+			resolver: aws.EndpointResolverWithOptionsFunc(func(service, region string, _ ...interface{}) (aws.Endpoint, error) {
+				if service != subscriptionsServiceID {
+					return aws.Endpoint{}, &aws.EndpointNotFoundError{}
+				}
+				return aws.Endpoint{URL: "https://vpce.example.com"}, nil
+			}),
+			expected: "https://vpce.example.com",
+		},
+		{
+			name:   "resolver without the service",
+			region: "us-east-1",
+			resolver: aws.EndpointResolverWithOptionsFunc(func(service, region string, _ ...interface{}) (aws.Endpoint, error) {
+				return aws.Endpoint{}, &aws.EndpointNotFoundError{}
+			}),
+			expected: "https://license-manager-user-subscriptions.us-east-1.amazonaws.com",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			cfg := aws.Config{
+				Region:                      test.region,
+				EndpointResolverWithOptions: test.resolver,
+				ConfigSources:               []interface{}{test.options},
+			}
+			endpoint, err := serviceEndpoint(context.Background(), cfg, subscriptionsServiceID, subscriptionsService)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, endpoint)
+		})
+	}
+}
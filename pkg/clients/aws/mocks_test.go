@@ -3,8 +3,11 @@ package aws
 import (
 	"context"
 	"fmt"
+	"strconv"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
 	lm "github.com/aws/aws-sdk-go-v2/service/licensemanager"
 	"github.com/aws/aws-sdk-go-v2/service/licensemanager/types"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
@@ -16,10 +19,19 @@ type mockLicenseManagerClient struct {
 	licenses           map[string]types.GrantedLicense
 	checkedOutLicenses map[string]licenseInfo
 	licenseCounter     int
+	// taggedLicenses are received in addition to licenses for the same sku, tags holds their tags by arn
+	taggedLicenses map[string][]types.GrantedLicense
+	tags           map[string][]types.Tag
 }
 
 type mockSTSClient struct {
 	accountNumber string
+	err           error
+}
+
+type mockIAMClient struct {
+	aliases []string
+	err     error
 }
 
 type licenseInfo struct {
@@ -42,6 +54,25 @@ func (m *mockLicenseManagerClient) AddLicenseForSku(productSku string, accountNu
 	m.licenses[productSku] = license
 }
 
+// AddTaggedLicense adds another license for productSku with tags, as received for a separate agreement, returning its
+// arn
+func (m *mockLicenseManagerClient) AddTaggedLicense(productSku string, accountNumber string, tags map[string]string) string {
+	if m.taggedLicenses == nil {
+		m.taggedLicenses = map[string][]types.GrantedLicense{}
+		m.tags = map[string][]types.Tag{}
+	}
+	licenseArn := fmt.Sprintf("arn:aws:license-manager::%s:license:l-%06d", accountNumber, m.licenseCounter)
+	m.licenseCounter++
+	m.taggedLicenses[productSku] = append(m.taggedLicenses[productSku], types.GrantedLicense{
+		LicenseArn: &licenseArn,
+		ProductSKU: &productSku,
+	})
+	for key, value := range tags {
+		m.tags[licenseArn] = append(m.tags[licenseArn], types.Tag{Key: aws.String(key), Value: aws.String(value)})
+	}
+	return licenseArn
+}
+
 func (m *mockLicenseManagerClient) Clear() {
 	m.licenses = map[string]types.GrantedLicense{}
 	m.checkedOutLicenses = map[string]licenseInfo{}
@@ -60,9 +91,25 @@ func (m *mockLicenseManagerClient) ListReceivedLicenses(ctx context.Context, par
 		if license, ok := m.licenses[productID]; ok {
 			licenses = append(licenses, license)
 		}
+		licenses = append(licenses, m.taggedLicenses[productID]...)
+	}
+	// next tokens are the offset of the next page
+	offset := 0
+	if params.NextToken != nil {
+		offset, _ = strconv.Atoi(*params.NextToken)
+	}
+	if offset > len(licenses) {
+		offset = len(licenses)
+	}
+	licenses = licenses[offset:]
+	var nextToken *string
+	if params.MaxResults != nil && len(licenses) > int(*params.MaxResults) {
+		licenses = licenses[:*params.MaxResults]
+		nextToken = aws.String(strconv.Itoa(offset + int(*params.MaxResults)))
 	}
 	return &lm.ListReceivedLicensesOutput{
-		Licenses: licenses,
+		Licenses:  licenses,
+		NextToken: nextToken,
 	}, nil
 }
 func (m *mockLicenseManagerClient) CheckoutLicense(ctx context.Context, params *lm.CheckoutLicenseInput, optFns ...func(*lm.Options)) (*lm.CheckoutLicenseOutput, error) {
@@ -122,6 +169,20 @@ func (m *mockLicenseManagerClient) GetLicenseUsage(ctx context.Context, params *
 		LicenseUsage: &types.LicenseUsage{EntitlementUsages: entitlementUsage}}, nil
 }
 
+func (m *mockLicenseManagerClient) ListTagsForResource(ctx context.Context, params *lm.ListTagsForResourceInput, optFns ...func(*lm.Options)) (*lm.ListTagsForResourceOutput, error) {
+	return &lm.ListTagsForResourceOutput{Tags: m.tags[*params.ResourceArn]}, nil
+}
+
 func (m *mockSTSClient) GetCallerIdentity(ctx context.Context, params *sts.GetCallerIdentityInput, optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
 	return &sts.GetCallerIdentityOutput{Account: &m.accountNumber}, nil
 }
+
+func (m *mockIAMClient) ListAccountAliases(ctx context.Context, params *iam.ListAccountAliasesInput, optFns ...func(*iam.Options)) (*iam.ListAccountAliasesOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &iam.ListAccountAliasesOutput{AccountAliases: m.aliases}, nil
+}
@@ -0,0 +1,95 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/sirupsen/logrus"
+)
+
+// BucketClient writes objects to an S3 bucket
+type BucketClient interface {
+	// PutObject stores body as the object key, replacing it if it exists
+	PutObject(ctx context.Context, key, contentType string, body []byte) error
+	// Bucket returns the name of the bucket
+	Bucket() string
+}
+
+// bucketRegionHeader names the region of the bucket in the redirect s3 answers requests sent to another region with
+const bucketRegionHeader = "X-Amz-Bucket-Region"
+
+// s3Client is the part of the s3 client the bucket client uses
+type s3Client interface {
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+}
+
+type bucketClient struct {
+	bucket string
+	s3     s3Client
+
+	lock sync.Mutex
+	// region is the region requests are sent to, the region of the aws config until s3 redirects to the bucket's
+	region string
+}
+
+// NewBucketClient returns a client for bucket. Requests are sent to the region of the adapter's aws config, and to the
+// bucket's region once s3 redirected a request there
+func NewBucketClient(ctx context.Context, clientOpts ClientOptions, bucket string) (BucketClient, error) {
+	cfg, err := loadConfig(ctx, clientOpts)
+	if err != nil {
+		return nil, err
+	}
+	return &bucketClient{bucket: bucket, s3: s3.NewFromConfig(cfg), region: cfg.Region}, nil
+}
+
+func (c *bucketClient) Bucket() string {
+	return c.bucket
+}
+
+func (c *bucketClient) PutObject(ctx context.Context, key, contentType string, body []byte) error {
+	c.lock.Lock()
+	region := c.region
+	c.lock.Unlock()
+	err := c.putObject(ctx, region, key, contentType, body)
+	if redirect := redirectRegion(err); redirect != "" && redirect != region {
+		logrus.Infof("[s3] bucket %s is in region %s, writing to it there", c.bucket, redirect)
+		c.lock.Lock()
+		c.region = redirect
+		c.lock.Unlock()
+		err = c.putObject(ctx, redirect, key, contentType, body)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to put object %s to bucket %s: %w", key, c.bucket, err)
+	}
+	return nil
+}
+
+func (c *bucketClient) putObject(ctx context.Context, region, key, contentType string, body []byte) error {
+	_, err := c.s3.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(c.bucket),
+		Key:           aws.String(key),
+		ContentType:   aws.String(contentType),
+		ContentLength: int64(len(body)),
+		Body:          bytes.NewReader(body),
+	}, func(o *s3.Options) {
+		o.Region = region
+	})
+	return err
+}
+
+// redirectRegion returns the region of the bucket if err is the redirect s3 answers with when a request was sent to
+// another region than the bucket's, empty otherwise
+func redirectRegion(err error) string {
+	var responseErr *awshttp.ResponseError
+	if !errors.As(err, &responseErr) || responseErr.HTTPStatusCode() != http.StatusMovedPermanently {
+		return ""
+	}
+	return responseErr.Response.Header.Get(bucketRegionHeader)
+}
@@ -0,0 +1,70 @@
+package aws
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestBucketClient(endpoint, bucket string) *bucketClient {
+	client := s3.New(s3.Options{
+		Region:      "us-east-1",
+		Credentials: credentials.NewStaticCredentialsProvider("AKID", "SECRET", ""),
+		EndpointResolver: s3.EndpointResolverFunc(func(region string, _ s3.EndpointResolverOptions) (aws.Endpoint, error) {
+			return aws.Endpoint{URL: endpoint, SigningRegion: region, Source: aws.EndpointSourceCustom}, nil
+		}),
+		UsePathStyle: true,
+	})
+	return &bucketClient{bucket: bucket, s3: client, region: "us-east-1"}
+}
+
+func TestBucketClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPut, r.Method)
+		assert.Contains(t, r.Header.Get("Authorization"), "/us-east-1/s3/")
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		if r.URL.EscapedPath() != "/reports/reports/compliance%20report.json" {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`<Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>`))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	client := newTestBucketClient(server.URL, "reports")
+	ctx := context.Background()
+	assert.NoError(t, client.PutObject(ctx, "reports/compliance report.json", "application/json", []byte(`{}`)))
+	err := client.PutObject(ctx, "other.json", "application/json", []byte(`{}`))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "AccessDenied")
+}
+
+func TestBucketClientRedirect(t *testing.T) {
+	var regions []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		region := strings.Split(r.Header.Get("Authorization"), "/")[2]
+		regions = append(regions, region)
+		if region != "eu-west-1" {
+			w.Header().Set(bucketRegionHeader, "eu-west-1")
+			w.WriteHeader(http.StatusMovedPermanently)
+			_, _ = w.Write([]byte(`<Error><Code>PermanentRedirect</Code></Error>`))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	client := newTestBucketClient(server.URL, "reports.example.com")
+	ctx := context.Background()
+	require.NoError(t, client.PutObject(ctx, "report.json", "application/json", []byte(`{}`)))
+	require.NoError(t, client.PutObject(ctx, "report.json", "application/json", []byte(`{}`)))
+	assert.Equal(t, []string{"us-east-1", "eu-west-1", "eu-west-1"}, regions, "requests should follow the bucket to its region")
+	assert.Equal(t, "eu-west-1", client.region)
+}
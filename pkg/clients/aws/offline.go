@@ -0,0 +1,382 @@
+package aws
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	lm "github.com/aws/aws-sdk-go-v2/service/licensemanager"
+	"github.com/aws/aws-sdk-go-v2/service/licensemanager/types"
+	"github.com/google/uuid"
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/rancher/csp-adapter/pkg/signing"
+)
+
+const (
+	offlineTokenDuration = 1 * time.Hour
+	// offlineTokenPrefix starts the consumption tokens issued by the OfflineClient, which are
+	// offline:<amount>:<id>:<key id>:<hmac>
+	offlineTokenPrefix = "offline:"
+)
+
+// OfflineClient is a Client backed by a license file, for disconnected deployments (AWS Snow Family devices and
+// Outposts without a connection to their region) which can't reach License Manager. The file is read again for every
+// license lookup so that a renewed license can be mounted in place of the old one. Checkouts are accounted for by the
+// client itself, it assumes that the adapter is the only consumer of the license. Unless the client has a public key
+// the file is trusted input: whoever can write it decides how many entitlements the adapter may check out
+type OfflineClient struct {
+	path      string
+	aliases   []string
+	account   string
+	publicKey crypto.PublicKey
+	tokenKeys signing.Source
+
+	lock       sync.Mutex
+	checkedOut map[string]offlineCheckout
+	// clientTokens maps the idempotency tokens of checkouts to the consumption tokens they returned
+	clientTokens map[string]string
+}
+
+type offlineCheckout struct {
+	amount  int
+	expires time.Time
+}
+
+// OfflineOptions configure an OfflineClient
+type OfflineOptions struct {
+	// DimensionAliases are other names of the RKE_NODE_SUPP dimension, as in ClientOptions.DimensionAliases
+	DimensionAliases []string
+	// PublicKey verifies the signed license artifact the license file must then hold, see readLicenses. Unsigned
+	// license files are refused once it's set
+	PublicKey crypto.PublicKey
+	// TokenKeys sign the consumption tokens the client issues, so that tokens issued before the adapter restarted are
+	// recognized. A key generated at startup is used if it's nil, which refuses tokens issued before a restart
+	TokenKeys signing.Source
+}
+
+// NewOfflineClient returns an OfflineClient for the license file at path, which holds the json output of
+// `aws license-manager list-received-licenses` or `aws license-manager get-license`, a single license, or a signed
+// license artifact holding any of them
+func NewOfflineClient(path string, opts OfflineOptions) (*OfflineClient, error) {
+	c := &OfflineClient{
+		path:         path,
+		aliases:      opts.DimensionAliases,
+		publicKey:    opts.PublicKey,
+		tokenKeys:    opts.TokenKeys,
+		checkedOut:   map[string]offlineCheckout{},
+		clientTokens: map[string]string{},
+	}
+	if c.tokenKeys == nil {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		keyring, err := signing.NewKeyring(map[string][]byte{"v1": key})
+		if err != nil {
+			return nil, err
+		}
+		c.tokenKeys = staticKeyring{keyring}
+	}
+	license, err := c.GetRancherLicense(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	c.account = beneficiaryAccount(aws.ToString(license.Beneficiary))
+	return c, nil
+}
+
+// beneficiaryAccount returns the account number of the beneficiary of a license, which is either an account number or
+// the arn of a principal in the account (arn:aws:iam::<account>:root)
+func beneficiaryAccount(beneficiary string) string {
+	if parts := strings.Split(beneficiary, ":"); len(parts) >= 5 && parts[0] == "arn" {
+		return parts[4]
+	}
+	return beneficiary
+}
+
+func (c *OfflineClient) AccountNumber() string {
+	return c.account
+}
+
+func (c *OfflineClient) AccountAlias() string {
+	return ""
+}
+
+func (c *OfflineClient) RefreshAccountNumber(ctx context.Context) (string, error) {
+	return c.account, nil
+}
+
+// staticKeyring is a signing.Source which never rotates
+type staticKeyring struct {
+	keyring *signing.Keyring
+}
+
+func (s staticKeyring) Keyring() (*signing.Keyring, error) {
+	return s.keyring, nil
+}
+
+// readLicenses reads the licenses of the license file. A file holding a SignedToken, a JWS whose payload is the
+// exported license, is verified with the public key of the client and only its payload is read
+func (c *OfflineClient) readLicenses() ([]types.GrantedLicense, error) {
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read license file: %w", err)
+	}
+	var signed struct {
+		SignedToken string
+	}
+	if err := json.Unmarshal(data, &signed); err != nil {
+		return nil, fmt.Errorf("unable to parse license file %s: %w", c.path, err)
+	}
+	switch {
+	case signed.SignedToken != "" && c.publicKey == nil:
+		return nil, fmt.Errorf("license file %s is signed, the public key to verify it with isn't configured", c.path)
+	case signed.SignedToken != "":
+		if data, err = verifyJWS(signed.SignedToken, c.publicKey); err != nil {
+			return nil, fmt.Errorf("license file %s: %w", c.path, err)
+		}
+	case c.publicKey != nil:
+		return nil, fmt.Errorf("license file %s isn't signed, only signed licenses are accepted with a public key", c.path)
+	}
+	var exported struct {
+		Licenses []types.GrantedLicense
+		License  *types.GrantedLicense
+	}
+	if err := json.Unmarshal(data, &exported); err != nil {
+		return nil, fmt.Errorf("unable to parse license file %s: %w", c.path, err)
+	}
+	if exported.License != nil {
+		return []types.GrantedLicense{*exported.License}, nil
+	}
+	if exported.Licenses != nil {
+		return exported.Licenses, nil
+	}
+	var license types.GrantedLicense
+	if err := json.Unmarshal(data, &license); err != nil {
+		return nil, fmt.Errorf("unable to parse license file %s: %w", c.path, err)
+	}
+	return []types.GrantedLicense{license}, nil
+}
+
+func (c *OfflineClient) GetRancherLicense(ctx context.Context) (*types.GrantedLicense, error) {
+	licenses, err := c.readLicenses()
+	if err != nil {
+		return nil, err
+	}
+	for _, sku := range []string{rancherProductSKUNonEmea, rancherProductSKUEmea} {
+		var received []types.GrantedLicense
+		for _, license := range licenses {
+			if aws.ToString(license.ProductSKU) == sku {
+				received = append(received, license)
+			}
+		}
+		if len(received) == 0 {
+			continue
+		}
+		// licenses are selected as with License Manager, so that a renewal can be added to the file before the license
+		// it replaces expires
+		if i := selectValidLicense(received, "", time.Now(), c.aliases...); i >= 0 {
+			return &received[i], nil
+		}
+		return &received[0], nil
+	}
+	return nil, fmt.Errorf("license file %s has no license for product id %s or %s", c.path, rancherProductSKUNonEmea, rancherProductSKUEmea)
+}
+
+func (c *OfflineClient) CheckoutRancherLicense(ctx context.Context, l types.GrantedLicense, entitlementAmt int) (*lm.CheckoutLicenseOutput, error) {
+	if err := c.ValidateLicense(l); err != nil {
+		return nil, err
+	}
+	maxEntitlements, dimension, err := getMaxRKEEntitlements(l, c.aliases...)
+	if err != nil {
+		return nil, err
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	now := time.Now()
+	clientToken := CheckoutTokenFromContext(ctx)
+	token, repeated := c.clientTokens[clientToken]
+	if _, held := c.checkedOut[token]; !repeated || !held {
+		if consumed := c.consumed(now); consumed+entitlementAmt > maxEntitlements {
+			return nil, fmt.Errorf("unable to checkout %d entitlements, only %d available", entitlementAmt, maxEntitlements-consumed)
+		}
+		if token, err = c.issueToken(entitlementAmt); err != nil {
+			return nil, err
+		}
+		if clientToken != "" {
+			c.clientTokens[clientToken] = token
+		}
+	}
+	expiry := now.Add(offlineTokenDuration)
+	c.checkedOut[token] = offlineCheckout{amount: entitlementAmt, expires: expiry}
+	value, expiration := strconv.Itoa(entitlementAmt), expiry.Format(time.RFC3339)
+	return &lm.CheckoutLicenseOutput{
+		CheckoutType: types.CheckoutTypeProvisional,
+		EntitlementsAllowed: []types.EntitlementData{{
+			Name:  &dimension,
+			Value: &value,
+			Unit:  types.EntitlementDataUnitCount,
+		}},
+		Expiration:              &expiration,
+		LicenseArn:              l.LicenseArn,
+		LicenseConsumptionToken: &token,
+	}, nil
+}
+
+func (c *OfflineClient) CheckInRancherLicense(ctx context.Context, consumptionToken string) (*lm.CheckInLicenseOutput, error) {
+	if _, _, err := parseOfflineToken(consumptionToken); err != nil {
+		return nil, err
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	// tokens which aren't held anymore were released when they expired or the adapter restarted
+	delete(c.checkedOut, consumptionToken)
+	return &lm.CheckInLicenseOutput{}, nil
+}
+
+func (c *OfflineClient) CheckInRancherLicenses(ctx context.Context, tokens []string) map[string]error {
+	return CheckInConcurrently(ctx, c, tokens, DefaultCheckInParallelism)
+}
+
+func (c *OfflineClient) ExtendRancherLicenseConsumptionToken(ctx context.Context, consumptionToken string) (*lm.ExtendLicenseConsumptionOutput, error) {
+	amount, sig, err := parseOfflineToken(consumptionToken)
+	if err != nil {
+		return nil, err
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	now := time.Now()
+	checkout, held := c.checkedOut[consumptionToken]
+	if held && !now.Before(checkout.expires) {
+		return nil, fmt.Errorf("consumption token %s expired at %s", consumptionToken, checkout.expires.Format(time.RFC3339))
+	}
+	if !held {
+		// checkouts are only accounted for in memory, the token of a checkout made before the adapter restarted is held
+		// again when it's first extended, as long as the client issued it and its entitlements are still available
+		if err := c.verifyToken(consumptionToken, sig); err != nil {
+			return nil, err
+		}
+		license, err := c.GetRancherLicense(ctx)
+		if err != nil {
+			return nil, err
+		}
+		maxEntitlements, _, err := getMaxRKEEntitlements(*license, c.aliases...)
+		if err != nil {
+			return nil, err
+		}
+		if consumed := c.consumed(now); consumed+amount > maxEntitlements {
+			return nil, fmt.Errorf("unable to hold consumption token %s again, only %d entitlements available", consumptionToken, maxEntitlements-consumed)
+		}
+	}
+	expiry := now.Add(offlineTokenDuration)
+	c.checkedOut[consumptionToken] = offlineCheckout{amount: amount, expires: expiry}
+	expiration := expiry.Format(time.RFC3339)
+	return &lm.ExtendLicenseConsumptionOutput{
+		LicenseConsumptionToken: &consumptionToken,
+		Expiration:              &expiration,
+	}, nil
+}
+
+// issueToken returns a new consumption token for amount entitlements, signed with the token keys of the client
+func (c *OfflineClient) issueToken(amount int) (string, error) {
+	keyring, err := c.tokenKeys.Keyring()
+	if err != nil {
+		return "", err
+	}
+	token := fmt.Sprintf("%s%d:%s", offlineTokenPrefix, amount, uuid.New().String())
+	sig := keyring.Sign([]byte(token))
+	return fmt.Sprintf("%s:%s:%s", token, sig.KeyID, sig.Value), nil
+}
+
+// verifyToken returns an error unless sig, parsed from token, was made by the token keys of the client
+func (c *OfflineClient) verifyToken(token string, sig signing.Signature) error {
+	keyring, err := c.tokenKeys.Keyring()
+	if err != nil {
+		return err
+	}
+	unsigned := strings.TrimSuffix(token, ":"+sig.KeyID+":"+sig.Value)
+	if err := keyring.Verify([]byte(unsigned), sig); err != nil {
+		return fmt.Errorf("consumption token %s wasn't issued by this adapter: %w", token, err)
+	}
+	return nil
+}
+
+// parseOfflineToken returns the number of entitlements checked out by a consumption token of the OfflineClient and
+// the signature of the token
+func parseOfflineToken(token string) (int, signing.Signature, error) {
+	parts := strings.Split(strings.TrimPrefix(token, offlineTokenPrefix), ":")
+	if !strings.HasPrefix(token, offlineTokenPrefix) || len(parts) != 4 {
+		return 0, signing.Signature{}, fmt.Errorf("consumption token %s wasn't issued from a license file", token)
+	}
+	amount, err := strconv.Atoi(parts[0])
+	if err != nil || amount < 0 {
+		return 0, signing.Signature{}, fmt.Errorf("consumption token %s wasn't issued from a license file", token)
+	}
+	return amount, signing.Signature{KeyID: parts[2], Value: parts[3]}, nil
+}
+
+func (c *OfflineClient) GetNumberOfAvailableEntitlements(ctx context.Context, license types.GrantedLicense) (int, error) {
+	usage, err := c.GetEntitlementUsage(ctx, license)
+	if err != nil {
+		return 0, err
+	}
+	return usage.Available(), nil
+}
+
+func (c *OfflineClient) GetEntitlementUsage(ctx context.Context, license types.GrantedLicense) (EntitlementUsage, error) {
+	maxEntitlements, dimension, err := getMaxRKEEntitlements(license, c.aliases...)
+	if err != nil {
+		return EntitlementUsage{}, err
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return EntitlementUsage{Max: maxEntitlements, Dimension: dimension, Consumed: c.consumed(time.Now())}, nil
+}
+
+// CheckServiceHealth returns an error if the license file can't be read
+func (c *OfflineClient) CheckServiceHealth(ctx context.Context) error {
+	_, err := c.readLicenses()
+	return err
+}
+
+func (c *OfflineClient) ListProducts(ctx context.Context) ([]sdk.Product, error) {
+	licenses, err := c.readLicenses()
+	if err != nil {
+		return nil, err
+	}
+	received := map[string]*types.GrantedLicense{}
+	for i := range licenses {
+		sku := aws.ToString(licenses[i].ProductSKU)
+		if _, ok := received[sku]; !ok {
+			received[sku] = &licenses[i]
+		}
+	}
+	if license, err := c.GetRancherLicense(ctx); err == nil {
+		received[*license.ProductSKU] = license
+	}
+	return describeProducts(received), nil
+}
+
+func (c *OfflineClient) ValidateLicense(l types.GrantedLicense) error {
+	return ValidateLicense(l, time.Now(), c.aliases...)
+}
+
+// consumed returns the number of entitlements checked out by tokens which haven't expired at now. Expired tokens are
+// kept until they're checked in so that they can't be extended again. Callers must hold the lock
+func (c *OfflineClient) consumed(now time.Time) int {
+	total := 0
+	for _, checkout := range c.checkedOut {
+		if now.Before(checkout.expires) {
+			total += checkout.amount
+		}
+	}
+	return total
+}
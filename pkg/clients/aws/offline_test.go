@@ -0,0 +1,164 @@
+package aws
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/signing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const offlineLicenses = `{
+  "Licenses": [
+    {
+      "LicenseArn": "arn:aws:license-manager::294406891311:license:l-other",
+      "ProductSKU": "not-rancher",
+      "Status": "AVAILABLE"
+    },
+    {
+      "LicenseArn": "arn:aws:license-manager::294406891311:license:l-rancher",
+      "ProductSKU": "0b87d4fa-d1fe-41d8-830b-67d4ec381549",
+      "Beneficiary": "arn:aws:iam::123456789012:root",
+      "Status": "AVAILABLE",
+      "Issuer": {"Name": "SUSE", "KeyFingerprint": "aws:294406891311:AWS/Marketplace:issuer-fingerprint"},
+      "Entitlements": [{"Name": "RKE_NODE_SUPP", "MaxCount": 3, "Unit": "Count"}]
+    }
+  ]
+}`
+
+func writeLicenseFile(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "license.json")
+	require.NoError(t, os.WriteFile(path, []byte(contents), 0600))
+	return path
+}
+
+func TestOfflineClient(t *testing.T) {
+	ctx := context.Background()
+	path := writeLicenseFile(t, offlineLicenses)
+	keyring, err := signing.NewKeyring(map[string][]byte{"v1": []byte("token key")})
+	require.NoError(t, err)
+	opts := OfflineOptions{TokenKeys: staticKeyring{keyring}}
+	client, err := NewOfflineClient(path, opts)
+	require.NoError(t, err)
+	assert.Equal(t, "123456789012", client.AccountNumber())
+
+	license, err := client.GetRancherLicense(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "arn:aws:license-manager::294406891311:license:l-rancher", *license.LicenseArn)
+	assert.NoError(t, client.ValidateLicense(*license))
+
+	_, err = client.CheckoutRancherLicense(ctx, *license, 4)
+	assert.Error(t, err, "should not be able to checkout more than the license grants")
+	res, err := client.CheckoutRancherLicense(ctx, *license, 2)
+	require.NoError(t, err)
+	usage, err := client.GetEntitlementUsage(ctx, *license)
+	require.NoError(t, err)
+	assert.Equal(t, EntitlementUsage{Max: 3, Consumed: 2, Dimension: entitlementDimension}, usage)
+
+	other, err := NewOfflineClient(path, OfflineOptions{})
+	require.NoError(t, err)
+	_, err = other.ExtendRancherLicenseConsumptionToken(ctx, *res.LicenseConsumptionToken)
+	assert.Error(t, err, "tokens signed with other keys weren't issued by the client")
+	_, err = other.ExtendRancherLicenseConsumptionToken(ctx, "offline:3:forged:v1:00")
+	assert.Error(t, err)
+
+	// a restarted adapter holds the token of its checkout again once it extends it
+	restarted, err := NewOfflineClient(path, opts)
+	require.NoError(t, err)
+	_, err = restarted.ExtendRancherLicenseConsumptionToken(ctx, *res.LicenseConsumptionToken)
+	require.NoError(t, err)
+	available, err := restarted.GetNumberOfAvailableEntitlements(ctx, *license)
+	require.NoError(t, err)
+	assert.Equal(t, 1, available)
+	_, err = restarted.ExtendRancherLicenseConsumptionToken(ctx, "not-an-offline-token")
+	assert.Error(t, err)
+
+	_, err = restarted.CheckInRancherLicense(ctx, *res.LicenseConsumptionToken)
+	require.NoError(t, err)
+	available, _ = restarted.GetNumberOfAvailableEntitlements(ctx, *license)
+	assert.Equal(t, 3, available, "checked in entitlements should be available again")
+
+	// expired checkouts aren't consumed anymore
+	restarted.checkedOut["offline:3:expired"] = offlineCheckout{amount: 3, expires: time.Now().Add(-time.Minute)}
+	available, _ = restarted.GetNumberOfAvailableEntitlements(ctx, *license)
+	assert.Equal(t, 3, available)
+	_, err = restarted.ExtendRancherLicenseConsumptionToken(ctx, "offline:3:expired")
+	assert.Error(t, err, "expired tokens can't be extended")
+}
+
+func TestOfflineClientLicenseFile(t *testing.T) {
+	ctx := context.Background()
+	expired := `{"License": {"LicenseArn": "arn:aws:license-manager::294406891311:license:l-rancher",
+	  "ProductSKU": "a303097d-1dc2-4548-8ea6-f46bb9842e21", "Status": "AVAILABLE",
+	  "Issuer": {"KeyFingerprint": "aws:294406891311:AWS/Marketplace:issuer-fingerprint"},
+	  "Validity": {"Begin": "2020-01-01T00:00:00Z", "End": "2021-01-01T00:00:00Z"},
+	  "Entitlements": [{"Name": "RKE_NODE_SUPP", "MaxCount": 3, "Unit": "Count"}]}}`
+	client, err := NewOfflineClient(writeLicenseFile(t, expired), OfflineOptions{})
+	require.NoError(t, err, "the output of get-license should be accepted")
+	license, err := client.GetRancherLicense(ctx)
+	require.NoError(t, err)
+	var validityErr *ValidityError
+	assert.ErrorAs(t, client.ValidateLicense(*license), &validityErr, "the validity of the license is checked offline")
+	_, err = client.CheckoutRancherLicense(ctx, *license, 1)
+	assert.Error(t, err)
+
+	_, err = NewOfflineClient(writeLicenseFile(t, `{"Licenses": []}`), OfflineOptions{})
+	assert.Error(t, err, "a file without a rancher license should be refused")
+	_, err = NewOfflineClient(filepath.Join(t.TempDir(), "missing.json"), OfflineOptions{})
+	assert.Error(t, err)
+}
+
+// signLicense returns a license file holding payload signed with key as an ES256 JWS
+func signLicense(t *testing.T, key *ecdsa.PrivateKey, payload string) string {
+	t.Helper()
+	signed := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"ES256"}`)) + "." +
+		base64.RawURLEncoding.EncodeToString([]byte(payload))
+	digest := sha256.Sum256([]byte(signed))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	require.NoError(t, err)
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	data, err := json.Marshal(map[string]string{"SignedToken": signed + "." + base64.RawURLEncoding.EncodeToString(sig)})
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestOfflineClientSignedLicense(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	publicKey, err := ParsePublicKey(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	require.NoError(t, err)
+	signed := signLicense(t, key, offlineLicenses)
+
+	client, err := NewOfflineClient(writeLicenseFile(t, signed), OfflineOptions{PublicKey: publicKey})
+	require.NoError(t, err)
+	license, err := client.GetRancherLicense(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "arn:aws:license-manager::294406891311:license:l-rancher", *license.LicenseArn)
+
+	_, err = NewOfflineClient(writeLicenseFile(t, offlineLicenses), OfflineOptions{PublicKey: publicKey})
+	assert.Error(t, err, "unsigned license files should be refused once a public key is configured")
+	_, err = NewOfflineClient(writeLicenseFile(t, signed), OfflineOptions{})
+	assert.Error(t, err, "signed license files can't be read without the public key")
+
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	_, err = NewOfflineClient(writeLicenseFile(t, signLicense(t, other, offlineLicenses)), OfflineOptions{PublicKey: publicKey})
+	assert.Error(t, err, "licenses signed with another key should be refused")
+}
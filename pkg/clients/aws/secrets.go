@@ -0,0 +1,126 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+)
+
+// SecretsClient reads secrets from AWS Secrets Manager
+type SecretsClient interface {
+	// GetSecretValue returns the current value of the secret with id, an arn or name
+	GetSecretValue(ctx context.Context, id string) (string, error)
+}
+
+const secretsService = "secretsmanager"
+
+// like the user subscription api, secrets manager isn't part of the sdk modules the adapter uses, so its single call is
+// made directly
+type secretsClient struct {
+	endpoint    string
+	region      string
+	credentials aws.CredentialsProvider
+	signer      *v4.Signer
+	http        aws.HTTPClient
+}
+
+// NewSecretsClient returns a client for secrets manager in the region of the adapter's aws config. Secrets are read
+// with the adapter's own credentials, since they are never written
+func NewSecretsClient(ctx context.Context, clientOpts ClientOptions) (SecretsClient, error) {
+	cfg, err := loadConfig(ctx, clientOpts)
+	if err != nil {
+		return nil, err
+	}
+	return &secretsClient{
+		endpoint:    fmt.Sprintf("https://%s.%s.amazonaws.com", secretsService, cfg.Region),
+		region:      cfg.Region,
+		credentials: cfg.Credentials,
+		signer:      v4.NewSigner(),
+		http:        http.DefaultClient,
+	}, nil
+}
+
+// SecretsError is returned when secrets manager rejects a call
+type SecretsError struct {
+	SecretID   string
+	StatusCode int
+	Type       string
+	Message    string
+}
+
+func (e *SecretsError) Error() string {
+	return fmt.Sprintf("unable to get secret %s, status %d: %s: %s", e.SecretID, e.StatusCode, e.Type, e.Message)
+}
+
+type getSecretValueInput struct {
+	SecretID string `json:"SecretId"`
+}
+
+type getSecretValueOutput struct {
+	SecretString string `json:"SecretString"`
+	SecretBinary []byte `json:"SecretBinary"`
+}
+
+func (c *secretsClient) GetSecretValue(ctx context.Context, id string) (string, error) {
+	body, err := json.Marshal(getSecretValueInput{SecretID: id})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint+"/", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	creds, err := c.credentials.Retrieve(ctx)
+	if err != nil {
+		return "", fmt.Errorf("unable to get credentials for secret %s: %w", id, err)
+	}
+	payloadHash := sha256.Sum256(body)
+	if err := c.signer.SignHTTP(ctx, creds, req, hex.EncodeToString(payloadHash[:]), secretsService, c.region, time.Now()); err != nil {
+		return "", err
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("unable to get secret %s: %w", id, err)
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("unable to read secret %s: %w", id, err)
+	}
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		var apiErr struct {
+			Type    string `json:"__type"`
+			Message string `json:"Message"`
+		}
+		_ = json.Unmarshal(data, &apiErr)
+		return "", &SecretsError{
+			SecretID:   id,
+			StatusCode: resp.StatusCode,
+			Type:       apiErr.Type,
+			Message:    apiErr.Message,
+		}
+	}
+	var output getSecretValueOutput
+	if err := json.Unmarshal(data, &output); err != nil {
+		return "", err
+	}
+	if output.SecretString != "" {
+		return output.SecretString, nil
+	}
+	if len(output.SecretBinary) > 0 {
+		return string(output.SecretBinary), nil
+	}
+	return "", errors.New("secret " + id + " has no value")
+}
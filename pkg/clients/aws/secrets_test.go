@@ -0,0 +1,54 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecretsClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Contains(t, r.Header.Get("Authorization"), "/us-east-1/secretsmanager/")
+		assert.Equal(t, "secretsmanager.GetSecretValue", r.Header.Get("X-Amz-Target"))
+		var input getSecretValueInput
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&input))
+		switch input.SecretID {
+		case "webhook":
+			_, _ = w.Write([]byte(`{"SecretString":"https://siem.example.com/ingest"}`))
+		case "binary":
+			_, _ = w.Write([]byte(`{"SecretBinary":"dG9rZW4="}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"__type":"ResourceNotFoundException","Message":"Secrets Manager can't find the specified secret."}`))
+		}
+	}))
+	defer server.Close()
+	client := &secretsClient{
+		endpoint:    server.URL,
+		region:      "us-east-1",
+		credentials: credentials.NewStaticCredentialsProvider("AKID", "SECRET", ""),
+		signer:      v4.NewSigner(),
+		http:        http.DefaultClient,
+	}
+	ctx := context.Background()
+
+	value, err := client.GetSecretValue(ctx, "webhook")
+	require.NoError(t, err)
+	assert.Equal(t, "https://siem.example.com/ingest", value)
+
+	value, err = client.GetSecretValue(ctx, "binary")
+	require.NoError(t, err)
+	assert.Equal(t, "token", value)
+
+	_, err = client.GetSecretValue(ctx, "missing")
+	var secretsErr *SecretsError
+	require.ErrorAs(t, err, &secretsErr)
+	assert.Equal(t, "ResourceNotFoundException", secretsErr.Type)
+}
@@ -0,0 +1,87 @@
+package aws
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// ParsePublicKey parses the PEM encoded public key signed license files are verified with, an RSA or ECDSA P-256 key
+func ParsePublicKey(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("public key isn't PEM encoded")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse public key: %w", err)
+	}
+	switch key.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey:
+		return key, nil
+	}
+	return nil, fmt.Errorf("unsupported public key type %T, expected an RSA or ECDSA key", key)
+}
+
+// verifyJWS verifies the compact JWS token with key and returns its payload. Tokens are signed with RS256, PS256 or
+// ES256, which covers the RSA and ECC_NIST_P256 signing keys of KMS
+func verifyJWS(token string, key crypto.PublicKey) ([]byte, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("the signed token isn't a compact JWS")
+	}
+	header, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWS header: %w", err)
+	}
+	var alg struct {
+		Alg string `json:"alg"`
+	}
+	if err := json.Unmarshal(header, &alg); err != nil {
+		return nil, fmt.Errorf("invalid JWS header: %w", err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWS signature: %w", err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	invalid := errors.New("the signature of the signed token doesn't match the public key, it was modified or signed with another key")
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		switch alg.Alg {
+		case "RS256":
+			err = rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig)
+		case "PS256":
+			err = rsa.VerifyPSS(key, crypto.SHA256, digest[:], sig, nil)
+		default:
+			return nil, fmt.Errorf("unsupported JWS algorithm %q for an RSA key", alg.Alg)
+		}
+		if err != nil {
+			return nil, invalid
+		}
+	case *ecdsa.PublicKey:
+		if alg.Alg != "ES256" {
+			return nil, fmt.Errorf("unsupported JWS algorithm %q for an ECDSA key", alg.Alg)
+		}
+		// the signature is r and s, each padded to 32 bytes
+		if len(sig) != 64 || !ecdsa.Verify(key, digest[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])) {
+			return nil, invalid
+		}
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", key)
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWS payload: %w", err)
+	}
+	return payload, nil
+}
@@ -0,0 +1,157 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// fallbackSTSRegion is the region whose regional sts endpoint is tried when the default endpoint fails
+	fallbackSTSRegion = "us-east-1"
+	// accountRetryInterval is how long the account number isn't looked up again after looking it up failed, so that
+	// callers of AccountNumber don't wait for sts on every call while it's unavailable
+	accountRetryInterval = time.Minute
+	// accountLookupTimeout bounds looking up the account number for AccountNumber, which has no context of its own
+	accountLookupTimeout = 10 * time.Second
+)
+
+// regionalSTS is a client for the regional sts endpoint of region
+type regionalSTS struct {
+	region string
+	client stsClient
+}
+
+// newSTSClient returns the sts client used for the adapter's own calls. It calls the regional endpoint of
+// ClientOptions.STSRegion if it's set, the endpoint of the configured region otherwise
+func newSTSClient(cfg aws.Config, opts ClientOptions) *sts.Client {
+	if opts.STSRegion == "" {
+		return sts.NewFromConfig(cfg)
+	}
+	return sts.NewFromConfig(cfg, func(o *sts.Options) {
+		o.Region = opts.STSRegion
+	})
+}
+
+// stsFallbacks returns clients for the regional sts endpoints which are tried when the caller identity can't be read
+// from the default endpoint, i.e. since the global endpoint isn't reachable from restricted networks. None are tried
+// when an sts region is forced
+func stsFallbacks(cfg aws.Config, opts ClientOptions) []regionalSTS {
+	if opts.STSRegion != "" {
+		return nil
+	}
+	var fallbacks []regionalSTS
+	for _, region := range stsFallbackRegions(cfg.Region) {
+		region := region
+		fallbacks = append(fallbacks, regionalSTS{
+			region: region,
+			client: sts.NewFromConfig(cfg, func(o *sts.Options) {
+				o.Region = region
+			}),
+		})
+	}
+	return fallbacks
+}
+
+// stsFallbackRegions returns the regions whose regional endpoints are tried after the default endpoint for region
+// failed. The default is the regional endpoint of region if it's a real region, and the global endpoint (or no endpoint
+// at all) if it's aws-global or empty, so fallbackSTSRegion is tried unless it was the default
+func stsFallbackRegions(region string) []string {
+	if region == fallbackSTSRegion {
+		return nil
+	}
+	return []string{fallbackSTSRegion}
+}
+
+// refreshAccount looks up the account number and alias again after the region of the client changed, keeping the
+// previous ones if it fails
+func (c *client) refreshAccount(ctx context.Context) {
+	if _, err := c.RefreshAccountNumber(ctx); err != nil {
+		logrus.Warnf("unable to look up the aws account number again, keeping the previous one: %v", err)
+	}
+}
+
+// account returns the account number and alias, looking them up if they aren't known yet unless looking them up failed
+// within accountRetryInterval. Callers which find another caller looking them up get the unknown account rather than
+// waiting for sts
+func (c *client) account() (string, string) {
+	c.acctLock.Lock()
+	due := c.acctNum == "" && !c.acctLookingUp && time.Since(c.acctFailedAt) >= accountRetryInterval
+	if !due {
+		defer c.acctLock.Unlock()
+		return c.acctNum, c.acctAlias
+	}
+	c.acctLookingUp = true
+	c.acctLock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), accountLookupTimeout)
+	defer cancel()
+	if _, err := c.RefreshAccountNumber(ctx); err != nil {
+		logrus.Warnf("unable to look up the aws account number, will retry in %s: %v", accountRetryInterval, err)
+	}
+	c.acctLock.Lock()
+	defer c.acctLock.Unlock()
+	c.acctLookingUp = false
+	return c.acctNum, c.acctAlias
+}
+
+// RefreshAccountNumber looks up the account number and alias and caches them. The acctLock is only taken to cache them,
+// not while calling sts and iam
+func (c *client) RefreshAccountNumber(ctx context.Context) (string, error) {
+	acctNum, err := c.getAccountNumber(ctx)
+	if err != nil {
+		c.acctLock.Lock()
+		defer c.acctLock.Unlock()
+		c.acctFailedAt = time.Now()
+		return "", err
+	}
+	alias := c.getAccountAlias(ctx, acctNum)
+	c.acctLock.Lock()
+	defer c.acctLock.Unlock()
+	c.acctNum, c.acctAlias, c.acctFailedAt = acctNum, alias, time.Time{}
+	logrus.Debugf("account number: %s, alias: %q", acctNum, alias)
+	return acctNum, nil
+}
+
+// getAccountNumber returns the account number of the account to which the associated IAM user belongs. If it can't be
+// read from the default sts endpoint, the regional fallback endpoints are tried in turn
+func (c *client) getAccountNumber(ctx context.Context) (string, error) {
+	acctNum, err := callerAccount(ctx, c.sts)
+	if err == nil {
+		return acctNum, nil
+	}
+	failures := []error{err}
+	for _, fallback := range c.stsFallbacks {
+		acctNum, fallbackErr := callerAccount(ctx, fallback.client)
+		if fallbackErr == nil {
+			logrus.Warnf("unable to get caller identity from the default sts endpoint (%v), using the regional endpoint of %s instead. Force the sts region to %s to skip the default endpoint",
+				err, fallback.region, fallback.region)
+			return acctNum, nil
+		}
+		failures = append(failures, fmt.Errorf("%s: %v", fallback.region, fallbackErr))
+	}
+	if len(failures) == 1 {
+		return "", err
+	}
+	return "", fmt.Errorf("unable to get caller identity from any sts endpoint: %v", failures)
+}
+
+// callerAccount returns the account of the caller identity read from client
+func callerAccount(ctx context.Context, client stsClient) (string, error) {
+	var in sts.GetCallerIdentityInput
+	out, err := client.GetCallerIdentity(ctx, &in) // no permissions required to make this call
+	if err != nil {
+		return "", err
+	}
+
+	if out.Account == nil || len(*out.Account) == 0 {
+		return "", errors.New("account number empty in aws sts response")
+	}
+
+	return *out.Account, nil
+}
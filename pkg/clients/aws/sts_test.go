@@ -0,0 +1,150 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetAccountNumberFallback(t *testing.T) {
+	unreachable := &mockSTSClient{err: errors.New("dial tcp: i/o timeout")}
+	tests := []struct {
+		name      string
+		sts       stsClient
+		fallbacks []regionalSTS
+		expected  string
+		wantErr   bool
+	}{
+		{
+			name:     "default endpoint",
+			sts:      &mockSTSClient{accountNumber: fakeAccountNum},
+			expected: fakeAccountNum,
+		},
+		{
+			name:      "regional fallback",
+			sts:       unreachable,
+			fallbacks: []regionalSTS{{region: "us-east-1", client: &mockSTSClient{accountNumber: fakeAccountNum}}},
+			expected:  fakeAccountNum,
+		},
+		{
+			name:      "empty account falls back",
+			sts:       &mockSTSClient{},
+			fallbacks: []regionalSTS{{region: "us-east-1", client: &mockSTSClient{accountNumber: fakeAccountNum}}},
+			expected:  fakeAccountNum,
+		},
+		{
+			name:      "every endpoint fails",
+			sts:       unreachable,
+			fallbacks: []regionalSTS{{region: "us-east-1", client: unreachable}},
+			wantErr:   true,
+		},
+		{
+			name:    "forced region without fallbacks",
+			sts:     unreachable,
+			wantErr: true,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			c := &client{sts: test.sts, stsFallbacks: test.fallbacks}
+			acctNum, err := c.getAccountNumber(context.Background())
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, acctNum)
+		})
+	}
+}
+
+func TestLazyAccountNumber(t *testing.T) {
+	sts := &mockSTSClient{err: errors.New("dial tcp: i/o timeout")}
+	c := &client{sts: sts}
+	assert.Empty(t, c.AccountNumber(), "the account number is empty while sts is unavailable")
+
+	sts.err, sts.accountNumber = nil, fakeAccountNum
+	assert.Empty(t, c.AccountNumber(), "failed lookups aren't retried right away")
+	c.acctFailedAt = time.Now().Add(-accountRetryInterval)
+	assert.Equal(t, fakeAccountNum, c.AccountNumber(), "the lookup is retried once the retry interval passed")
+
+	sts.accountNumber = "210987654321"
+	assert.Equal(t, fakeAccountNum, c.AccountNumber(), "the account number is cached")
+	acctNum, err := c.RefreshAccountNumber(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "210987654321", acctNum, "a refresh looks up the account number again")
+
+	sts.err = errors.New("dial tcp: i/o timeout")
+	_, err = c.RefreshAccountNumber(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, "210987654321", c.AccountNumber(), "a failed refresh keeps the previous account number")
+}
+
+// blockingSTS answers once release is closed
+type blockingSTS struct {
+	mockSTSClient
+	release chan struct{}
+}
+
+func (b *blockingSTS) GetCallerIdentity(ctx context.Context, params *sts.GetCallerIdentityInput, optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error) {
+	<-b.release
+	return b.mockSTSClient.GetCallerIdentity(ctx, params, optFns...)
+}
+
+func TestAccountLookupDoesNotBlock(t *testing.T) {
+	blocking := &blockingSTS{mockSTSClient: mockSTSClient{accountNumber: fakeAccountNum}, release: make(chan struct{})}
+	c := &client{sts: blocking}
+	looked := make(chan string)
+	go func() {
+		looked <- c.AccountNumber()
+	}()
+	// the first caller looks the account up, others get the unknown account instead of waiting for it
+	assert.Eventually(t, func() bool {
+		c.acctLock.Lock()
+		defer c.acctLock.Unlock()
+		return c.acctLookingUp
+	}, time.Second, time.Millisecond)
+	assert.Empty(t, c.AccountNumber())
+	assert.Empty(t, c.AccountAlias())
+
+	close(blocking.release)
+	assert.Equal(t, fakeAccountNum, <-looked)
+	assert.Equal(t, fakeAccountNum, c.AccountNumber())
+}
+
+func TestSTSEndpoints(t *testing.T) {
+	t.Setenv("AWS_REGION", "eu-west-1")
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+	tests := []struct {
+		name  string
+		opts  ClientOptions
+		hosts []string
+	}{
+		{name: "default with fallback", hosts: []string{"sts.eu-west-1.amazonaws.com", "sts.us-east-1.amazonaws.com"}},
+		{name: "forced region", opts: ClientOptions{STSRegion: "eu-central-1"}, hosts: []string{"sts.eu-central-1.amazonaws.com"}},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			cfg, err := loadConfig(context.Background(), test.opts)
+			assert.NoError(t, err)
+			recorder := &hostRecorder{}
+			cfg.HTTPClient = recorder
+			cfg.RetryMaxAttempts = 1
+			c := &client{sts: newSTSClient(cfg, test.opts), stsFallbacks: stsFallbacks(cfg, test.opts)}
+			_, err = c.getAccountNumber(context.Background())
+			assert.Error(t, err)
+			assert.Equal(t, test.hosts, recorder.hosts)
+		})
+	}
+	assert.Empty(t, stsFallbackRegions("us-east-1"), "expected the default endpoint not to be repeated")
+}
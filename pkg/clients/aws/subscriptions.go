@@ -0,0 +1,246 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/sirupsen/logrus"
+)
+
+// SubscriptionClient manages the subscriptions of users to a product licensed per user rather than per node, through
+// License Manager user subscriptions
+type SubscriptionClient interface {
+	// Product returns the product which users are subscribed to
+	Product() string
+	// ListSubscribedUsers returns the usernames of the users with an active subscription to the product. Subscriptions
+	// which failed or ended aren't included
+	ListSubscribedUsers(ctx context.Context) ([]string, error)
+	// SubscribeUser subscribes the user with username to the product
+	SubscribeUser(ctx context.Context, username string) error
+	// UnsubscribeUser stops the subscription of the user with username to the product
+	UnsubscribeUser(ctx context.Context, username string) error
+}
+
+// SubscriptionOptions configures the product and directory which users are subscribed through
+type SubscriptionOptions struct {
+	// Product is the product licensed per user
+	Product string
+	// DirectoryID is the AWS Managed Microsoft AD directory which holds the subscribed users
+	DirectoryID string
+	// Domain is the domain of the users in the directory, optional if the directory has a single domain
+	Domain string
+}
+
+const (
+	subscriptionsService   = "license-manager-user-subscriptions"
+	subscriptionsServiceID = "License Manager User Subscriptions"
+	// subscriptionsPageSize is the number of subscriptions listed per request
+	subscriptionsPageSize = 100
+	// subscriptionsCallTimeout bounds each call, the http client of the aws config only bounds connecting
+	subscriptionsCallTimeout = 30 * time.Second
+	// subscriptionFailed is the status of a subscription which couldn't be started
+	subscriptionFailed = "FAILED"
+)
+
+// the user subscription api isn't part of the license manager sdk, its few calls are made directly. They use the
+// endpoint and http client the sdk modules would, see serviceEndpoint
+type subscriptionClient struct {
+	opts        SubscriptionOptions
+	endpoint    string
+	region      string
+	credentials aws.CredentialsProvider
+	signer      *v4.Signer
+	http        aws.HTTPClient
+}
+
+// NewSubscriptionClient returns a client for the user subscriptions of opts.Product, in the region of the adapter's aws
+// config. Subscriptions are started and stopped with clientOpts.WriteRoleARN if it's set
+func NewSubscriptionClient(ctx context.Context, opts SubscriptionOptions, clientOpts ClientOptions) (SubscriptionClient, error) {
+	cfg, err := loadConfig(ctx, clientOpts)
+	if err != nil {
+		return nil, err
+	}
+	credentials := cfg.Credentials
+	if clientOpts.WriteRoleARN != "" {
+		credentials = aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(newSTSClient(cfg, clientOpts), clientOpts.WriteRoleARN))
+	}
+	endpoint, err := serviceEndpoint(ctx, cfg, subscriptionsServiceID, subscriptionsService)
+	if err != nil {
+		return nil, err
+	}
+	return &subscriptionClient{
+		opts:        opts,
+		endpoint:    endpoint,
+		region:      cfg.Region,
+		credentials: credentials,
+		signer:      v4.NewSigner(),
+		http:        cfg.HTTPClient,
+	}, nil
+}
+
+// SubscriptionError is returned when the user subscription api rejects a call
+type SubscriptionError struct {
+	Operation  string
+	StatusCode int
+	Type       string
+	Message    string
+}
+
+func (e *SubscriptionError) Error() string {
+	return fmt.Sprintf("%s failed with status %d: %s: %s", e.Operation, e.StatusCode, e.Type, e.Message)
+}
+
+type identityProvider struct {
+	ActiveDirectoryIdentityProvider activeDirectoryIdentityProvider `json:"ActiveDirectoryIdentityProvider"`
+}
+
+type activeDirectoryIdentityProvider struct {
+	DirectoryID string `json:"DirectoryId"`
+}
+
+type productUserSummary struct {
+	Username string `json:"Username"`
+	Status   string `json:"Status"`
+	// SubscriptionEndDate is set once the subscription was stopped
+	SubscriptionEndDate string `json:"SubscriptionEndDate"`
+}
+
+// active returns true if the subscription was started and hasn't ended at now
+func (s productUserSummary) active(now time.Time) bool {
+	if s.Status == subscriptionFailed {
+		return false
+	}
+	if s.SubscriptionEndDate == "" {
+		return true
+	}
+	end, err := time.Parse(time.RFC3339, s.SubscriptionEndDate)
+	return err == nil && now.Before(end)
+}
+
+type listProductSubscriptionsInput struct {
+	Product          string           `json:"Product"`
+	IdentityProvider identityProvider `json:"IdentityProvider"`
+	MaxResults       int              `json:"MaxResults"`
+	NextToken        string           `json:"NextToken,omitempty"`
+}
+
+type listProductSubscriptionsOutput struct {
+	ProductUserSummaries []productUserSummary `json:"ProductUserSummaries"`
+	NextToken            string               `json:"NextToken"`
+}
+
+type productSubscriptionInput struct {
+	Product          string           `json:"Product"`
+	IdentityProvider identityProvider `json:"IdentityProvider"`
+	Username         string           `json:"Username"`
+	Domain           string           `json:"Domain,omitempty"`
+}
+
+func (c *subscriptionClient) Product() string {
+	return c.opts.Product
+}
+
+func (c *subscriptionClient) identityProvider() identityProvider {
+	return identityProvider{ActiveDirectoryIdentityProvider: activeDirectoryIdentityProvider{DirectoryID: c.opts.DirectoryID}}
+}
+
+func (c *subscriptionClient) ListSubscribedUsers(ctx context.Context) ([]string, error) {
+	var usernames []string
+	input := listProductSubscriptionsInput{
+		Product:          c.opts.Product,
+		IdentityProvider: c.identityProvider(),
+		MaxResults:       subscriptionsPageSize,
+	}
+	for {
+		var output listProductSubscriptionsOutput
+		if err := c.call(ctx, "ListProductSubscriptions", input, &output); err != nil {
+			return nil, err
+		}
+		now := time.Now()
+		for _, summary := range output.ProductUserSummaries {
+			if summary.active(now) {
+				usernames = append(usernames, summary.Username)
+			}
+		}
+		if output.NextToken == "" {
+			return usernames, nil
+		}
+		input.NextToken = output.NextToken
+	}
+}
+
+func (c *subscriptionClient) SubscribeUser(ctx context.Context, username string) error {
+	return c.call(ctx, "StartProductSubscription", c.subscriptionInput(username), nil)
+}
+
+func (c *subscriptionClient) UnsubscribeUser(ctx context.Context, username string) error {
+	return c.call(ctx, "StopProductSubscription", c.subscriptionInput(username), nil)
+}
+
+func (c *subscriptionClient) subscriptionInput(username string) productSubscriptionInput {
+	return productSubscriptionInput{
+		Product:          c.opts.Product,
+		IdentityProvider: c.identityProvider(),
+		Username:         username,
+		Domain:           c.opts.Domain,
+	}
+}
+
+// call makes a signed request for operation with input as the body, decoding the response into output if it's not nil
+func (c *subscriptionClient) call(ctx context.Context, operation string, input, output interface{}) error {
+	body, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, subscriptionsCallTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint+"/user/"+operation, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	creds, err := c.credentials.Retrieve(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to get credentials for %s: %w", operation, err)
+	}
+	payloadHash := sha256.Sum256(body)
+	if err := c.signer.SignHTTP(ctx, creds, req, hex.EncodeToString(payloadHash[:]), subscriptionsService, c.region, time.Now()); err != nil {
+		return err
+	}
+	logrus.Debugf("calling %s for product %s", operation, c.opts.Product)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s failed: %w", operation, err)
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("unable to read %s response: %w", operation, err)
+	}
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		_ = json.Unmarshal(data, &apiErr)
+		return &SubscriptionError{
+			Operation:  operation,
+			StatusCode: resp.StatusCode,
+			Type:       resp.Header.Get("X-Amzn-Errortype"),
+			Message:    apiErr.Message,
+		}
+	}
+	if output == nil {
+		return nil
+	}
+	return json.Unmarshal(data, output)
+}
@@ -0,0 +1,78 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSubscriptionClient(endpoint string) *subscriptionClient {
+	return &subscriptionClient{
+		opts:        SubscriptionOptions{Product: "office_professional_plus", DirectoryID: "d-1234567890", Domain: "corp.example.com"},
+		endpoint:    endpoint,
+		region:      "us-east-1",
+		credentials: credentials.NewStaticCredentialsProvider("AKID", "SECRET", ""),
+		signer:      v4.NewSigner(),
+		http:        http.DefaultClient,
+	}
+}
+
+func TestSubscriptionClient(t *testing.T) {
+	var requests []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256"), "requests should be signed")
+		assert.Contains(t, r.Header.Get("Authorization"), "/us-east-1/license-manager-user-subscriptions/")
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		body["path"] = r.URL.Path
+		requests = append(requests, body)
+		switch r.URL.Path {
+		case "/user/ListProductSubscriptions":
+			if body["NextToken"] == nil {
+				_, _ = w.Write([]byte(`{"ProductUserSummaries":[{"Username":"alice","Status":"SUCCESS"}],"NextToken":"page-2"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"ProductUserSummaries":[{"Username":"bob","Status":"SUCCESS"},` +
+				`{"Username":"carol","Status":"FAILED"},` +
+				`{"Username":"dave","Status":"SUCCESS","SubscriptionEndDate":"2020-01-01T00:00:00Z"}]}`))
+		case "/user/StartProductSubscription":
+			_, _ = w.Write([]byte(`{}`))
+		default:
+			w.Header().Set("X-Amzn-Errortype", "ResourceNotFoundException")
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"message":"user carol not found"}`))
+		}
+	}))
+	defer server.Close()
+	client := newTestSubscriptionClient(server.URL)
+	ctx := context.Background()
+
+	users, err := client.ListSubscribedUsers(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"alice", "bob"}, users, "failed and ended subscriptions aren't active")
+	assert.Equal(t, "page-2", requests[1]["NextToken"])
+	assert.Equal(t, map[string]interface{}{
+		"ActiveDirectoryIdentityProvider": map[string]interface{}{"DirectoryId": "d-1234567890"},
+	}, requests[0]["IdentityProvider"])
+
+	require.NoError(t, client.SubscribeUser(ctx, "carol"))
+	assert.Equal(t, "carol", requests[2]["Username"])
+	assert.Equal(t, "corp.example.com", requests[2]["Domain"])
+	assert.Equal(t, "office_professional_plus", requests[2]["Product"])
+
+	err = client.UnsubscribeUser(ctx, "carol")
+	var subscriptionErr *SubscriptionError
+	require.ErrorAs(t, err, &subscriptionErr)
+	assert.Equal(t, http.StatusNotFound, subscriptionErr.StatusCode)
+	assert.Equal(t, "ResourceNotFoundException", subscriptionErr.Type)
+	assert.Equal(t, "user carol not found", subscriptionErr.Message)
+	assert.Equal(t, "/user/StopProductSubscription", requests[3]["path"])
+}
@@ -0,0 +1,222 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	lm "github.com/aws/aws-sdk-go-v2/service/licensemanager"
+	"github.com/aws/aws-sdk-go-v2/service/licensemanager/types"
+	"github.com/google/uuid"
+	"github.com/rancher/csp-adapter/pkg/sdk"
+)
+
+// Operations of the SyntheticClient which failures can be injected into
+const (
+	OperationGetLicense = "license"
+	OperationCheckout   = "checkout"
+	OperationCheckIn    = "checkin"
+	OperationExtend     = "extend"
+	OperationUsage      = "usage"
+	OperationHealth     = "health"
+)
+
+const (
+	syntheticAccountNumber = "000000000000"
+	syntheticLicenseArn    = "arn:aws:license-manager::000000000000:license:l-synthetic"
+	syntheticFingerprint   = "aws:000000000000:synthetic:issuer-fingerprint"
+	syntheticTokenDuration = 1 * time.Hour
+)
+
+// SyntheticClient is a Client backed by an in-memory license, used to demo the adapter without an aws account. The
+// license and failures of individual operations can be adjusted while it's in use
+type SyntheticClient struct {
+	lock            sync.Mutex
+	maxEntitlements int
+	checkedOut      map[string]int
+	// clientTokens maps the idempotency tokens of checkouts to the consumption tokens they returned
+	clientTokens map[string]string
+	failures     map[string]error
+}
+
+func NewSyntheticClient(maxEntitlements int) *SyntheticClient {
+	return &SyntheticClient{
+		maxEntitlements: maxEntitlements,
+		checkedOut:      map[string]int{},
+		clientTokens:    map[string]string{},
+		failures:        map[string]error{},
+	}
+}
+
+// SetMaxEntitlements changes the number of entitlements granted by the synthetic license
+func (s *SyntheticClient) SetMaxEntitlements(maxEntitlements int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.maxEntitlements = maxEntitlements
+}
+
+// InjectFailure makes every future call of operation fail with message, until ClearFailures is called
+func (s *SyntheticClient) InjectFailure(operation, message string) error {
+	switch operation {
+	case OperationGetLicense, OperationCheckout, OperationCheckIn, OperationExtend, OperationUsage, OperationHealth:
+	default:
+		return fmt.Errorf("unknown operation %q", operation)
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.failures[operation] = errors.New(message)
+	return nil
+}
+
+// ClearFailures removes all injected failures
+func (s *SyntheticClient) ClearFailures() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.failures = map[string]error{}
+}
+
+func (s *SyntheticClient) AccountNumber() string {
+	return syntheticAccountNumber
+}
+
+func (s *SyntheticClient) AccountAlias() string {
+	return ""
+}
+
+func (s *SyntheticClient) RefreshAccountNumber(ctx context.Context) (string, error) {
+	return syntheticAccountNumber, nil
+}
+
+func (s *SyntheticClient) GetRancherLicense(ctx context.Context) (*types.GrantedLicense, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err := s.failures[OperationGetLicense]; err != nil {
+		return nil, err
+	}
+	arn, sku, name, fingerprint := syntheticLicenseArn, rancherProductSKUNonEmea, entitlementDimension, syntheticFingerprint
+	maxCount := int64(s.maxEntitlements)
+	return &types.GrantedLicense{
+		LicenseArn: &arn,
+		ProductSKU: &sku,
+		Status:     types.LicenseStatusAvailable,
+		Issuer:     &types.IssuerDetails{KeyFingerprint: &fingerprint},
+		Entitlements: []types.Entitlement{{
+			Name:     &name,
+			Unit:     types.EntitlementUnitCount,
+			MaxCount: &maxCount,
+		}},
+	}, nil
+}
+
+func (s *SyntheticClient) CheckoutRancherLicense(ctx context.Context, l types.GrantedLicense, entitlementAmt int) (*lm.CheckoutLicenseOutput, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err := s.failures[OperationCheckout]; err != nil {
+		return nil, err
+	}
+	clientToken := CheckoutTokenFromContext(ctx)
+	token, repeated := s.clientTokens[clientToken]
+	if _, held := s.checkedOut[token]; !repeated || !held {
+		if s.consumed()+entitlementAmt > s.maxEntitlements {
+			return nil, fmt.Errorf("unable to checkout %d entitlements, only %d available", entitlementAmt, s.maxEntitlements-s.consumed())
+		}
+		token = uuid.New().String()
+		s.checkedOut[token] = entitlementAmt
+		if clientToken != "" {
+			s.clientTokens[clientToken] = token
+		}
+	}
+	expiry := time.Now().Add(syntheticTokenDuration).Format(time.RFC3339)
+	name, value := entitlementDimension, strconv.Itoa(entitlementAmt)
+	return &lm.CheckoutLicenseOutput{
+		CheckoutType: types.CheckoutTypeProvisional,
+		EntitlementsAllowed: []types.EntitlementData{{
+			Name:  &name,
+			Value: &value,
+			Unit:  types.EntitlementDataUnitCount,
+		}},
+		Expiration:              &expiry,
+		LicenseArn:              l.LicenseArn,
+		LicenseConsumptionToken: &token,
+	}, nil
+}
+
+func (s *SyntheticClient) CheckInRancherLicense(ctx context.Context, consumptionToken string) (*lm.CheckInLicenseOutput, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err := s.failures[OperationCheckIn]; err != nil {
+		return nil, err
+	}
+	if _, ok := s.checkedOut[consumptionToken]; !ok {
+		return nil, fmt.Errorf("consumption token %s not found", consumptionToken)
+	}
+	delete(s.checkedOut, consumptionToken)
+	return &lm.CheckInLicenseOutput{}, nil
+}
+
+func (s *SyntheticClient) CheckInRancherLicenses(ctx context.Context, tokens []string) map[string]error {
+	return CheckInConcurrently(ctx, s, tokens, DefaultCheckInParallelism)
+}
+
+func (s *SyntheticClient) ExtendRancherLicenseConsumptionToken(ctx context.Context, consumptionToken string) (*lm.ExtendLicenseConsumptionOutput, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err := s.failures[OperationExtend]; err != nil {
+		return nil, err
+	}
+	if _, ok := s.checkedOut[consumptionToken]; !ok {
+		return nil, fmt.Errorf("consumption token %s not found", consumptionToken)
+	}
+	expiry := time.Now().Add(syntheticTokenDuration).Format(time.RFC3339)
+	return &lm.ExtendLicenseConsumptionOutput{
+		LicenseConsumptionToken: &consumptionToken,
+		Expiration:              &expiry,
+	}, nil
+}
+
+func (s *SyntheticClient) GetNumberOfAvailableEntitlements(ctx context.Context, license types.GrantedLicense) (int, error) {
+	usage, err := s.GetEntitlementUsage(ctx, license)
+	if err != nil {
+		return 0, err
+	}
+	return usage.Available(), nil
+}
+
+func (s *SyntheticClient) GetEntitlementUsage(ctx context.Context, license types.GrantedLicense) (EntitlementUsage, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if err := s.failures[OperationUsage]; err != nil {
+		return EntitlementUsage{}, err
+	}
+	return EntitlementUsage{Max: s.maxEntitlements, Consumed: s.consumed()}, nil
+}
+
+func (s *SyntheticClient) CheckServiceHealth(ctx context.Context) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.failures[OperationHealth]
+}
+
+func (s *SyntheticClient) ListProducts(ctx context.Context) ([]sdk.Product, error) {
+	license, err := s.GetRancherLicense(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return describeProducts(map[string]*types.GrantedLicense{*license.ProductSKU: license}), nil
+}
+
+func (s *SyntheticClient) ValidateLicense(l types.GrantedLicense) error {
+	return ValidateLicense(l, time.Now())
+}
+
+// consumed returns the number of checked out entitlements, callers must hold the lock
+func (s *SyntheticClient) consumed() int {
+	total := 0
+	for _, amount := range s.checkedOut {
+		total += amount
+	}
+	return total
+}
@@ -0,0 +1,42 @@
+package aws
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyntheticClient(t *testing.T) {
+	ctx := context.Background()
+	client := NewSyntheticClient(2)
+
+	license, err := client.GetRancherLicense(ctx)
+	assert.NoError(t, err, "expected no error getting synthetic license")
+	assert.NoError(t, ValidateEntitlements(*license), "synthetic license should be valid")
+
+	_, err = client.CheckoutRancherLicense(ctx, *license, 3)
+	assert.Error(t, err, "should not be able to checkout more than the max entitlements")
+	res, err := client.CheckoutRancherLicense(ctx, *license, 2)
+	assert.NoError(t, err, "expected no error checking out available entitlements")
+	available, err := client.GetNumberOfAvailableEntitlements(ctx, *license)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, available, "all entitlements should be consumed")
+
+	client.SetMaxEntitlements(4)
+	available, _ = client.GetNumberOfAvailableEntitlements(ctx, *license)
+	assert.Equal(t, 2, available, "raising max entitlements should make more available")
+
+	assert.Error(t, client.InjectFailure("not-an-operation", "failure"), "unknown operations should be rejected")
+	assert.NoError(t, client.InjectFailure(OperationExtend, "throttled"))
+	_, err = client.ExtendRancherLicenseConsumptionToken(ctx, *res.LicenseConsumptionToken)
+	assert.EqualError(t, err, "throttled")
+	client.ClearFailures()
+	_, err = client.ExtendRancherLicenseConsumptionToken(ctx, *res.LicenseConsumptionToken)
+	assert.NoError(t, err, "failures should be cleared")
+
+	_, err = client.CheckInRancherLicense(ctx, *res.LicenseConsumptionToken)
+	assert.NoError(t, err)
+	available, _ = client.GetNumberOfAvailableEntitlements(ctx, *license)
+	assert.Equal(t, 4, available, "checked in entitlements should be available again")
+}
@@ -0,0 +1,212 @@
+package aws
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/licensemanager/types"
+	"github.com/sirupsen/logrus"
+)
+
+// IssuerError is returned when a license doesn't identify the key fingerprint of its issuer, which is required to check
+// it out
+type IssuerError struct {
+	LicenseArn string
+}
+
+func (e *IssuerError) Error() string {
+	return fmt.Sprintf("license %s has no issuer key fingerprint, it can't be checked out", e.LicenseArn)
+}
+
+// LicenseStatusError is returned when a license can't be checked out because of its status, i.e. it expired or the
+// seller revoked it
+type LicenseStatusError struct {
+	LicenseArn string
+	Status     types.LicenseStatus
+}
+
+func (e *LicenseStatusError) Error() string {
+	return fmt.Sprintf("license %s has status %s, only %s licenses can be checked out", e.LicenseArn, e.Status, types.LicenseStatusAvailable)
+}
+
+// GrantStatusError is returned when the grant through which a license was received no longer allows its use, because
+// the seller disabled or revoked it
+type GrantStatusError struct {
+	LicenseArn string
+	Status     types.ReceivedStatus
+	// StatusReason is the explanation given for the status, if any
+	StatusReason string
+}
+
+func (e *GrantStatusError) Error() string {
+	msg := fmt.Sprintf("grant of license %s has status %s", e.LicenseArn, e.Status)
+	if e.StatusReason != "" {
+		msg = fmt.Sprintf("%s: %s", msg, e.StatusReason)
+	}
+	return msg
+}
+
+// Revoked returns true if the grant was revoked, false if it was only disabled and may be enabled again
+func (e *GrantStatusError) Revoked() bool {
+	return e.Status != types.ReceivedStatusDisabled
+}
+
+// unusableGrantStatuses are the statuses of grants which were disabled or revoked by the seller
+var unusableGrantStatuses = map[types.ReceivedStatus]bool{
+	types.ReceivedStatusDisabled: true,
+	types.ReceivedStatusDeleted:  true,
+	types.ReceivedStatusRejected: true,
+}
+
+// ValidityError is returned when a license is used outside of the period it is valid for
+type ValidityError struct {
+	LicenseArn string
+	Begin      time.Time
+	End        time.Time
+	// NotYetValid is true if the validity period hasn't begun yet, false if it has ended
+	NotYetValid bool
+}
+
+func (e *ValidityError) Error() string {
+	if e.NotYetValid {
+		return fmt.Sprintf("license %s is not valid before %s", e.LicenseArn, e.Begin.Format(time.RFC3339))
+	}
+	return fmt.Sprintf("license %s expired at %s", e.LicenseArn, e.End.Format(time.RFC3339))
+}
+
+// ValidateLicense returns an error describing why license can't be checked out at now: a GrantStatusError if its grant
+// was disabled or revoked, an IssuerError if the issuer's key fingerprint is unknown, a LicenseStatusError if it isn't
+// available, a ValidityError if now is outside of its validity period or an EntitlementError if it doesn't grant
+// RKE_NODE_SUPP entitlements, under its own name or one of aliases
+func ValidateLicense(license types.GrantedLicense, now time.Time, aliases ...string) error {
+	arn := aws.ToString(license.LicenseArn)
+	if metadata := license.ReceivedMetadata; metadata != nil && unusableGrantStatuses[metadata.ReceivedStatus] {
+		return &GrantStatusError{
+			LicenseArn:   arn,
+			Status:       metadata.ReceivedStatus,
+			StatusReason: aws.ToString(metadata.ReceivedStatusReason),
+		}
+	}
+	if license.Issuer == nil || aws.ToString(license.Issuer.KeyFingerprint) == "" {
+		return &IssuerError{LicenseArn: arn}
+	}
+	// licenses which don't report a status are treated as available
+	if license.Status != "" && license.Status != types.LicenseStatusAvailable {
+		return &LicenseStatusError{LicenseArn: arn, Status: license.Status}
+	}
+	if license.Validity != nil {
+		begin, end := parseLicenseTime(license.Validity.Begin), parseLicenseTime(license.Validity.End)
+		if !begin.IsZero() && now.Before(begin) {
+			return &ValidityError{LicenseArn: arn, Begin: begin, End: end, NotYetValid: true}
+		}
+		if !end.IsZero() && !now.Before(end) {
+			return &ValidityError{LicenseArn: arn, Begin: begin, End: end}
+		}
+	}
+	return ValidateEntitlements(license, aliases...)
+}
+
+// expiringWindow is how long before the end of its validity a license is expiring, and checkouts move to another license
+// which can be checked out
+const expiringWindow = 24 * time.Hour
+
+// selectValidLicense returns the index of the license of licenses which checkouts are made from at now, or -1 if none
+// of licenses can be checked out. The license with the arn selected, the one selected before, is kept as long as it can
+// be checked out and isn't expiring, so that checkouts only move to another license once it's replaced or about to
+// end. Otherwise the license which isn't expiring and whose validity ends first is used, licenses without an end
+// outlasting all others, so that concurrently valid grants are used one after the other rather than alternately. If all
+// of them are expiring the one which ends last is used. Ties go to the license whose arn sorts first, since License
+// Manager doesn't list them in a stable order
+func selectValidLicense(licenses []types.GrantedLicense, selected string, now time.Time, aliases ...string) int {
+	first, last := -1, -1
+	var firstEnd, lastEnd time.Time
+	for i, license := range licenses {
+		if ValidateLicense(license, now, aliases...) != nil {
+			continue
+		}
+		var end time.Time
+		if license.Validity != nil {
+			end = parseLicenseTime(license.Validity.End)
+		}
+		expiring := !end.IsZero() && end.Sub(now) <= expiringWindow
+		if !expiring && selected != "" && aws.ToString(license.LicenseArn) == selected {
+			return i
+		}
+		if expiring {
+			if last == -1 || endsBefore(lastEnd, end) || lastEnd.Equal(end) && arnBefore(license, licenses[last]) {
+				last, lastEnd = i, end
+			}
+			continue
+		}
+		if first == -1 || endsBefore(end, firstEnd) || end.Equal(firstEnd) && arnBefore(license, licenses[first]) {
+			first, firstEnd = i, end
+		}
+	}
+	if first != -1 {
+		return first
+	}
+	return last
+}
+
+// endsBefore returns whether a validity ending at a ends before one ending at b, zero ends never ending
+func endsBefore(a, b time.Time) bool {
+	return !a.IsZero() && (b.IsZero() || a.Before(b))
+}
+
+// arnBefore returns whether the arn of a sorts before the arn of b
+func arnBefore(a, b types.GrantedLicense) bool {
+	return aws.ToString(a.LicenseArn) < aws.ToString(b.LicenseArn)
+}
+
+func (c *client) ValidateLicense(license types.GrantedLicense) error {
+	c.cacheFingerprint(&license)
+	return ValidateLicense(license, time.Now(), c.opts.DimensionAliases...)
+}
+
+// cacheFingerprint remembers the issuer key fingerprint of license, or sets it from the cache if license doesn't include
+// it. The fingerprint is required to check out the license but isn't part of every response listing it
+func (c *client) cacheFingerprint(license *types.GrantedLicense) {
+	arn := aws.ToString(license.LicenseArn)
+	if arn == "" {
+		return
+	}
+	c.fingerprintLock.Lock()
+	defer c.fingerprintLock.Unlock()
+	if license.Issuer != nil && aws.ToString(license.Issuer.KeyFingerprint) != "" {
+		fingerprint := *license.Issuer.KeyFingerprint
+		if cached, ok := c.fingerprints[arn]; ok && cached != fingerprint {
+			logrus.Warnf("issuer key fingerprint of license %s changed from %s to %s", arn, cached, fingerprint)
+		}
+		if c.fingerprints == nil {
+			c.fingerprints = map[string]string{}
+		}
+		c.fingerprints[arn] = fingerprint
+		return
+	}
+	cached, ok := c.fingerprints[arn]
+	if !ok {
+		return
+	}
+	issuer := types.IssuerDetails{}
+	if license.Issuer != nil {
+		issuer = *license.Issuer
+	}
+	issuer.KeyFingerprint = &cached
+	license.Issuer = &issuer
+}
+
+// parseLicenseTime parses a timestamp of a license, returning the zero time if it's absent or can't be parsed
+func parseLicenseTime(timestamp *string) time.Time {
+	if aws.ToString(timestamp) == "" {
+		return time.Time{}
+	}
+	// like expiration timestamps, validity timestamps may lack the timezone
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05"} {
+		if parsed, err := time.Parse(layout, *timestamp); err == nil {
+			return parsed
+		}
+	}
+	logrus.Warnf("unable to parse license timestamp %s, ignoring it", *timestamp)
+	return time.Time{}
+}
@@ -0,0 +1,187 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/licensemanager/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateLicense(t *testing.T) {
+	now := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	rkeEntitlement := entitlementDimension
+	two := int64(2)
+	validLicense := func() types.GrantedLicense {
+		return types.GrantedLicense{
+			LicenseArn:   aws.String("arn:aws:license-manager::123456789101:license:l-000000"),
+			Status:       types.LicenseStatusAvailable,
+			Issuer:       &types.IssuerDetails{KeyFingerprint: aws.String("aws:294406891311:AWS/Marketplace:issuer-fingerprint")},
+			Entitlements: []types.Entitlement{{Name: &rkeEntitlement, MaxCount: &two}},
+			Validity:     &types.DatetimeRange{Begin: aws.String("2022-01-01T00:00:00Z"), End: aws.String("2023-01-01T00:00:00Z")},
+		}
+	}
+	tests := []struct {
+		name     string
+		modify   func(license *types.GrantedLicense)
+		expected interface{}
+	}{
+		{
+			name:   "valid license",
+			modify: func(license *types.GrantedLicense) {},
+		},
+		{
+			name:     "missing issuer",
+			modify:   func(license *types.GrantedLicense) { license.Issuer = nil },
+			expected: &IssuerError{},
+		},
+		{
+			name: "grant disabled",
+			modify: func(license *types.GrantedLicense) {
+				license.ReceivedMetadata = &types.ReceivedMetadata{ReceivedStatus: types.ReceivedStatusDisabled}
+			},
+			expected: &GrantStatusError{},
+		},
+		{
+			name: "grant active",
+			modify: func(license *types.GrantedLicense) {
+				license.ReceivedMetadata = &types.ReceivedMetadata{ReceivedStatus: types.ReceivedStatusActive}
+			},
+		},
+		{
+			name:     "deactivated",
+			modify:   func(license *types.GrantedLicense) { license.Status = types.LicenseStatusDeactivated },
+			expected: &LicenseStatusError{},
+		},
+		{
+			name:   "status not reported",
+			modify: func(license *types.GrantedLicense) { license.Status = "" },
+		},
+		{
+			name:     "not yet valid",
+			modify:   func(license *types.GrantedLicense) { license.Validity.Begin = aws.String("2022-07-01T00:00:00") },
+			expected: &ValidityError{NotYetValid: true},
+		},
+		{
+			name:     "validity ended",
+			modify:   func(license *types.GrantedLicense) { license.Validity.End = aws.String("2022-05-31T23:59:59Z") },
+			expected: &ValidityError{},
+		},
+		{
+			name:     "no entitlements",
+			modify:   func(license *types.GrantedLicense) { license.Entitlements = nil },
+			expected: &EntitlementError{},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			license := validLicense()
+			test.modify(&license)
+			err := ValidateLicense(license, now)
+			switch expected := test.expected.(type) {
+			case nil:
+				assert.NoError(t, err)
+			case *GrantStatusError:
+				assert.True(t, errors.As(err, &expected), "expected a GrantStatusError, got %v", err)
+			case *IssuerError:
+				assert.True(t, errors.As(err, &expected), "expected an IssuerError, got %v", err)
+			case *LicenseStatusError:
+				assert.True(t, errors.As(err, &expected), "expected a LicenseStatusError, got %v", err)
+			case *ValidityError:
+				var validityErr *ValidityError
+				assert.True(t, errors.As(err, &validityErr), "expected a ValidityError, got %v", err)
+				if validityErr != nil {
+					assert.Equal(t, expected.NotYetValid, validityErr.NotYetValid)
+				}
+			case *EntitlementError:
+				assert.True(t, errors.As(err, &expected), "expected an EntitlementError, got %v", err)
+			}
+		})
+	}
+}
+
+func TestSelectValidLicense(t *testing.T) {
+	now := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	rkeEntitlement := entitlementDimension
+	two := int64(2)
+	license := func(id, begin, end string) types.GrantedLicense {
+		validity := &types.DatetimeRange{Begin: aws.String(begin)}
+		if end != "" {
+			validity.End = aws.String(end)
+		}
+		return types.GrantedLicense{
+			LicenseArn:   aws.String("arn:aws:license-manager::123456789101:license:" + id),
+			Status:       types.LicenseStatusAvailable,
+			Issuer:       &types.IssuerDetails{KeyFingerprint: aws.String("aws:294406891311:AWS/Marketplace:issuer-fingerprint")},
+			Entitlements: []types.Entitlement{{Name: &rkeEntitlement, MaxCount: &two}},
+			Validity:     validity,
+		}
+	}
+	current := license("l-current", "2022-01-01T00:00:00Z", "2022-07-01T00:00:00Z")
+	renewed := license("l-renewed", "2022-05-01T00:00:00Z", "2023-07-01T00:00:00Z")
+	upcoming := license("l-upcoming", "2022-07-01T00:00:00Z", "2024-07-01T00:00:00Z")
+	expired := license("l-expired", "2021-01-01T00:00:00Z", "2022-01-01T00:00:00Z")
+	expiring := license("l-expiring", "2021-06-01T00:00:00Z", "2022-06-01T12:00:00Z")
+	lastDay := license("l-last-day", "2021-06-01T00:00:00Z", "2022-06-01T18:00:00Z")
+	perpetual := license("l-perpetual", "2022-01-01T00:00:00Z", "")
+	otherPerpetual := license("l-another-perpetual", "2022-01-01T00:00:00Z", "")
+	tests := []struct {
+		name     string
+		licenses []types.GrantedLicense
+		selected string
+		expected int
+	}{
+		{name: "no licenses", expected: -1},
+		{name: "single license", licenses: []types.GrantedLicense{current}, expected: 0},
+		{name: "renewed grant overlapping the current one", licenses: []types.GrantedLicense{current, renewed}, expected: 0},
+		{name: "renewed grant listed first", licenses: []types.GrantedLicense{renewed, current}, expected: 1},
+		{name: "current grant expiring", licenses: []types.GrantedLicense{expiring, renewed}, selected: *expiring.LicenseArn, expected: 1},
+		{name: "selected grant kept", licenses: []types.GrantedLicense{current, renewed}, selected: *renewed.LicenseArn, expected: 1},
+		{name: "successor not valid yet", licenses: []types.GrantedLicense{current, upcoming}, expected: 0},
+		{name: "license without end", licenses: []types.GrantedLicense{renewed, perpetual, current}, expected: 2},
+		{name: "concurrent grants without end", licenses: []types.GrantedLicense{perpetual, otherPerpetual}, expected: 1},
+		{name: "only expiring licenses", licenses: []types.GrantedLicense{lastDay, expiring}, selected: *expiring.LicenseArn, expected: 0},
+		{name: "only unusable licenses", licenses: []types.GrantedLicense{expired, upcoming}, expected: -1},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, selectValidLicense(test.licenses, test.selected, now))
+		})
+	}
+}
+
+func TestFingerprintCache(t *testing.T) {
+	mockLMClient := mockLicenseManagerClient{}
+	mockLMClient.Clear()
+	mockLMClient.AddLicenseForSku(rancherProductSKUNonEmea, fakeAccountNum, true)
+	license := mockLMClient.licenses[rancherProductSKUNonEmea]
+	fingerprint := "aws:294406891311:AWS/Marketplace:issuer-fingerprint"
+	license.Issuer = &types.IssuerDetails{KeyFingerprint: &fingerprint}
+	rkeEntitlement, two := entitlementDimension, int64(2)
+	license.Entitlements = []types.Entitlement{{Name: &rkeEntitlement, MaxCount: &two}}
+	mockLMClient.licenses[rancherProductSKUNonEmea] = license
+	client := &client{acctNum: fakeAccountNum, lm: &mockLMClient}
+
+	_, err := client.GetRancherLicense(context.Background())
+	assert.NoError(t, err)
+
+	// later listings which don't include the issuer still use the fingerprint seen before
+	license.Issuer = nil
+	mockLMClient.licenses[rancherProductSKUNonEmea] = license
+	listed, err := client.GetRancherLicense(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, fingerprint, aws.ToString(listed.Issuer.KeyFingerprint))
+	assert.NoError(t, client.ValidateLicense(license), "the cached fingerprint should be used for validation")
+	_, err = client.CheckoutRancherLicense(context.Background(), license, 1)
+	assert.NoError(t, err, "the cached fingerprint should be used for checkouts")
+
+	var issuerErr *IssuerError
+	otherLicense := license
+	otherLicense.LicenseArn = aws.String("arn:aws:license-manager::123456789101:license:l-other")
+	assert.True(t, errors.As(client.ValidateLicense(otherLicense), &issuerErr), "fingerprints are cached per license")
+}
@@ -0,0 +1,174 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	authorizationclient "k8s.io/client-go/kubernetes/typed/authorization/v1"
+)
+
+const managementGroup = "management.cattle.io"
+
+// Permission is an access to the kubernetes api the adapter needs. Namespace is empty for access to cluster scoped
+// resources or to all namespaces, Name is empty for access to every object of the resource
+type Permission struct {
+	Verb        string `json:"verb"`
+	Group       string `json:"group"`
+	Resource    string `json:"resource"`
+	Subresource string `json:"subresource,omitempty"`
+	Namespace   string `json:"namespace,omitempty"`
+	Name        string `json:"name,omitempty"`
+}
+
+// resource returns the resource as named in rbac rules, with its subresource
+func (p Permission) resource() string {
+	if p.Subresource != "" {
+		return p.Resource + "/" + p.Subresource
+	}
+	return p.Resource
+}
+
+func (p Permission) String() string {
+	resource := p.resource()
+	if p.Group != "" {
+		resource = resource + "." + p.Group
+	}
+	if p.Name != "" {
+		resource = resource + "/" + p.Name
+	}
+	if p.Namespace != "" {
+		return fmt.Sprintf("%s %s in namespace %s", p.Verb, resource, p.Namespace)
+	}
+	return fmt.Sprintf("%s %s", p.Verb, resource)
+}
+
+// Rule returns the rbac rule granting the permission, in the flow style of a rules list entry, and the kind of role it
+// has to be added to
+func (p Permission) Rule() (string, string) {
+	rule := fmt.Sprintf(`{apiGroups: [%q], resources: [%q]`, p.Group, p.resource())
+	if p.Name != "" {
+		rule += fmt.Sprintf(`, resourceNames: [%q]`, p.Name)
+	}
+	rule += fmt.Sprintf(`, verbs: [%q]}`, p.Verb)
+	if p.Namespace != "" {
+		return rule, fmt.Sprintf("Role in namespace %s", p.Namespace)
+	}
+	return rule, "ClusterRole"
+}
+
+// PermissionOptions selects the optional features whose permissions are required
+type PermissionOptions struct {
+	ClusterSummaries bool
+	ClusterCondition bool
+	// Users is set when rancher users are counted for products licensed per user
+	Users bool
+	// TokenReviews is set when callers of the status api are authenticated with kubernetes tokens
+	TokenReviews bool
+}
+
+// RequiredPermissions returns the permissions the adapter needs with opts, as granted by the chart. Must be called after
+// New read the names of the adapter's objects from the environment
+func RequiredPermissions(opts PermissionOptions) []Permission {
+	required := []Permission{
+		{Verb: "get", Group: managementGroup, Resource: "ranchermetrics"},
+		{Verb: "get", Group: managementGroup, Resource: "clusters"},
+		{Verb: "list", Group: managementGroup, Resource: "clusters"},
+		{Verb: "list", Group: managementGroup, Resource: "nodes"},
+		{Verb: "get", Group: managementGroup, Resource: "rancherusernotifications", Name: outputNotificationName},
+		{Verb: "update", Group: managementGroup, Resource: "rancherusernotifications", Name: outputNotificationName},
+		{Verb: "create", Group: managementGroup, Resource: "rancherusernotifications"},
+		{Verb: "get", Group: managementGroup, Resource: "settings", Name: hostnameSetting},
+		{Verb: "get", Group: managementGroup, Resource: "settings", Name: versionSetting},
+		{Verb: "get", Resource: "namespaces", Name: "kube-system"},
+		{Verb: "get", Resource: "secrets", Namespace: cspAdapterNamespace, Name: cacheName},
+		{Verb: "update", Resource: "secrets", Namespace: cspAdapterNamespace, Name: cacheName},
+		{Verb: "create", Resource: "secrets", Namespace: cspAdapterNamespace},
+		{Verb: "get", Resource: "configmaps", Namespace: cspAdapterNamespace, Name: outputConfigMapName},
+		{Verb: "update", Resource: "configmaps", Namespace: cspAdapterNamespace, Name: outputConfigMapName},
+		{Verb: "create", Resource: "configmaps", Namespace: cspAdapterNamespace},
+		// usage history, kept in a configmap per month
+		{Verb: "list", Resource: "configmaps", Namespace: cspAdapterNamespace},
+		{Verb: "delete", Resource: "configmaps", Namespace: cspAdapterNamespace},
+		// the adapter's custom resources
+		{Verb: "get", Group: AdapterConfigResource.Group, Resource: AdapterConfigResource.Resource},
+		{Verb: "get", Group: ExemptionResource.Group, Resource: ExemptionResource.Resource},
+		{Verb: "list", Group: ExemptionResource.Group, Resource: ExemptionResource.Resource},
+		{Verb: "get", Group: CheckoutRequestResource.Group, Resource: CheckoutRequestResource.Resource},
+		{Verb: "list", Group: CheckoutRequestResource.Group, Resource: CheckoutRequestResource.Resource},
+		{Verb: "delete", Group: CheckoutRequestResource.Group, Resource: CheckoutRequestResource.Resource},
+		{Verb: "update", Group: CheckoutRequestResource.Group, Resource: CheckoutRequestResource.Resource, Subresource: "status"},
+	}
+	if complianceSetting != "" {
+		required = append(required,
+			Permission{Verb: "get", Group: managementGroup, Resource: "settings", Name: complianceSetting},
+			Permission{Verb: "update", Group: managementGroup, Resource: "settings", Name: complianceSetting})
+	}
+	if name := os.Getenv(deploymentNameEnv); name != "" {
+		required = append(required, Permission{Verb: "watch", Group: "apps", Resource: "deployments", Namespace: cspAdapterNamespace, Name: name})
+	}
+	if opts.ClusterSummaries {
+		required = append(required,
+			Permission{Verb: "get", Resource: "configmaps", Name: clusterSummaryName},
+			Permission{Verb: "update", Resource: "configmaps", Name: clusterSummaryName},
+			Permission{Verb: "create", Resource: "configmaps"})
+	}
+	if opts.ClusterCondition {
+		required = append(required, Permission{Verb: "patch", Group: managementGroup, Resource: "clusters", Name: localClusterName})
+	}
+	if opts.Users {
+		required = append(required,
+			Permission{Verb: "list", Group: managementGroup, Resource: "users"},
+			Permission{Verb: "list", Group: managementGroup, Resource: "userattributes"},
+			Permission{Verb: "list", Group: managementGroup, Resource: "authconfigs"})
+	}
+	if opts.TokenReviews {
+		required = append(required, Permission{Verb: "create", Group: "authentication.k8s.io", Resource: "tokenreviews"})
+	}
+	return required
+}
+
+// AccessChecker checks whether the adapter was granted its Required permissions, with a SelfSubjectAccessReview for
+// each of them
+type AccessChecker struct {
+	Reviews  authorizationclient.SelfSubjectAccessReviewInterface
+	Required []Permission
+}
+
+// MissingPermissions returns the required permissions which the adapter wasn't granted
+func (a AccessChecker) MissingPermissions(ctx context.Context) ([]Permission, error) {
+	var missing []Permission
+	for _, permission := range a.Required {
+		review, err := a.Reviews.Create(ctx, &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Verb:        permission.Verb,
+					Group:       permission.Group,
+					Resource:    permission.Resource,
+					Subresource: permission.Subresource,
+					Namespace:   permission.Namespace,
+					Name:        permission.Name,
+				},
+			},
+		}, metav1.CreateOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("unable to review access to %s: %v", permission, err)
+		}
+		if !review.Status.Allowed {
+			missing = append(missing, permission)
+		}
+	}
+	return missing, nil
+}
+
+// DescribePermissions lists permissions for log messages
+func DescribePermissions(permissions []Permission) string {
+	described := make([]string, 0, len(permissions))
+	for _, permission := range permissions {
+		described = append(described, permission.String())
+	}
+	return strings.Join(described, ", ")
+}
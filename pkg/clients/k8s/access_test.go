@@ -0,0 +1,69 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestAccessChecker(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	clientset.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		attributes := review.Spec.ResourceAttributes
+		// the role lacks updating the compliance setting
+		review.Status.Allowed = !(attributes.Resource == "settings" && attributes.Verb == "update")
+		return true, review, nil
+	})
+	checker := AccessChecker{
+		Reviews: clientset.AuthorizationV1().SelfSubjectAccessReviews(),
+		Required: []Permission{
+			{Verb: "get", Group: managementGroup, Resource: "settings", Name: "csp-adapter-compliance"},
+			{Verb: "update", Group: managementGroup, Resource: "settings", Name: "csp-adapter-compliance"},
+			{Verb: "get", Resource: "secrets", Namespace: cspAdapterNamespace, Name: "csp-adapter-cache"},
+		},
+	}
+	missing, err := checker.MissingPermissions(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []Permission{{Verb: "update", Group: managementGroup, Resource: "settings", Name: "csp-adapter-compliance"}}, missing)
+
+	clientset.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("unavailable")
+	})
+	_, err = checker.MissingPermissions(context.Background())
+	assert.Error(t, err)
+}
+
+func TestPermissionRule(t *testing.T) {
+	rule, role := Permission{Verb: "update", Group: managementGroup, Resource: "settings", Name: "csp-adapter-compliance"}.Rule()
+	assert.Equal(t, `{apiGroups: ["management.cattle.io"], resources: ["settings"], resourceNames: ["csp-adapter-compliance"], verbs: ["update"]}`, rule)
+	assert.Equal(t, "ClusterRole", role)
+
+	rule, role = Permission{Verb: "list", Resource: "configmaps", Namespace: cspAdapterNamespace}.Rule()
+	assert.Equal(t, `{apiGroups: [""], resources: ["configmaps"], verbs: ["list"]}`, rule)
+	assert.Equal(t, "Role in namespace cattle-csp-adapter-system", role)
+
+	status := Permission{Verb: "update", Group: CheckoutRequestResource.Group, Resource: CheckoutRequestResource.Resource, Subresource: "status"}
+	rule, _ = status.Rule()
+	assert.Equal(t, `{apiGroups: ["csp-adapter.cattle.io"], resources: ["licensecheckoutrequests/status"], verbs: ["update"]}`, rule)
+	assert.Equal(t, "update licensecheckoutrequests/status.csp-adapter.cattle.io", status.String())
+}
+
+func TestRequiredPermissions(t *testing.T) {
+	base := RequiredPermissions(PermissionOptions{})
+	assert.NotContains(t, base, Permission{Verb: "patch", Group: managementGroup, Resource: "clusters", Name: localClusterName})
+	assert.Contains(t, base, Permission{Verb: "get", Group: managementGroup, Resource: "clusters"})
+	assert.Contains(t, base, Permission{Verb: "get", Group: "csp-adapter.cattle.io", Resource: "cspadapterconfigs"})
+	assert.Contains(t, base, Permission{Verb: "list", Group: "csp-adapter.cattle.io", Resource: "clusterlicenseexemptions"})
+	assert.Contains(t, base, Permission{Verb: "delete", Group: "csp-adapter.cattle.io", Resource: "licensecheckoutrequests"})
+	assert.Contains(t, base, Permission{Verb: "update", Group: "csp-adapter.cattle.io", Resource: "licensecheckoutrequests", Subresource: "status"})
+	withCondition := RequiredPermissions(PermissionOptions{ClusterCondition: true, TokenReviews: true})
+	assert.Contains(t, withCondition, Permission{Verb: "patch", Group: managementGroup, Resource: "clusters", Name: localClusterName})
+	assert.Contains(t, withCondition, Permission{Verb: "create", Group: "authentication.k8s.io", Resource: "tokenreviews"})
+}
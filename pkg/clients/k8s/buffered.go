@@ -0,0 +1,245 @@
+package k8s
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/metrics"
+	"github.com/sirupsen/logrus"
+	apierror "k8s.io/apimachinery/pkg/api/errors"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+)
+
+// BufferOptions configures how writes buffered by a BufferedClient are retried
+type BufferOptions struct {
+	// InitialBackoff is the time waited before the first retry of buffered writes
+	InitialBackoff time.Duration
+	// MaxBackoff is the longest time waited between retries, the backoff doubles after every failed retry
+	MaxBackoff time.Duration
+	// MaxPending is the number of writes which can be buffered, writes failing while the buffer is full return their
+	// error instead
+	MaxPending int
+}
+
+// DefaultBufferOptions are the options used by the adapter
+var DefaultBufferOptions = BufferOptions{
+	InitialBackoff: 1 * time.Second,
+	MaxBackoff:     1 * time.Minute,
+	MaxPending:     1000,
+}
+
+// BufferedClient wraps a Client, buffering writes of the adapter's outputs (the csp config, the user notification, the
+// compliance setting and condition and cluster summaries) which fail while the kubernetes api is briefly unavailable.
+// Buffered writes are retried with backoff by Run instead of failing the compliance check. Only the latest write of
+// each output is kept, since it replaces the earlier ones anyway. Writes of the same output are serialized, so that a
+// retried write never lands after a newer one
+type BufferedClient struct {
+	Client
+	opts BufferOptions
+
+	lock    sync.Mutex
+	pending map[string]*bufferedWrite
+	// outputs serializes the writes of each output, by key
+	outputs map[string]*sync.Mutex
+	// wake is signaled when a write is buffered while none were pending
+	wake chan struct{}
+}
+
+func NewBufferedClient(c Client, opts BufferOptions) *BufferedClient {
+	return &BufferedClient{
+		Client:  c,
+		opts:    opts,
+		pending: map[string]*bufferedWrite{},
+		outputs: map[string]*sync.Mutex{},
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// bufferedWrite is a write waiting to be retried
+type bufferedWrite struct {
+	fn func() error
+}
+
+const (
+	cspConfigWriteKey         = "csp-config"
+	notificationWriteKey      = "notification"
+	complianceSettingWriteKey = "compliance-setting"
+	clusterConditionWriteKey  = "cluster-condition"
+	clusterSummaryWritePrefix = "cluster-summary/"
+)
+
+func (b *BufferedClient) UpdateCSPConfigOutput(marshalledData []byte) error {
+	return b.write(cspConfigWriteKey, func() error {
+		return b.Client.UpdateCSPConfigOutput(marshalledData)
+	})
+}
+
+func (b *BufferedClient) UpdateUserNotification(isInCompliance bool, message string) error {
+	return b.write(notificationWriteKey, func() error {
+		return b.Client.UpdateUserNotification(isInCompliance, message)
+	})
+}
+
+func (b *BufferedClient) UpdateComplianceSetting(value string) error {
+	return b.write(complianceSettingWriteKey, func() error {
+		return b.Client.UpdateComplianceSetting(value)
+	})
+}
+
+func (b *BufferedClient) UpdateComplianceCondition(compliant bool, reason, message string) error {
+	return b.write(clusterConditionWriteKey, func() error {
+		return b.Client.UpdateComplianceCondition(compliant, reason, message)
+	})
+}
+
+func (b *BufferedClient) UpdateClusterSummary(clusterID string, marshalledData []byte) error {
+	return b.write(clusterSummaryWritePrefix+clusterID, func() error {
+		return b.Client.UpdateClusterSummary(clusterID, marshalledData)
+	})
+}
+
+// Pending returns the number of buffered writes
+func (b *BufferedClient) Pending() int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return len(b.pending)
+}
+
+// output returns the lock serializing the writes of the output key
+func (b *BufferedClient) output(key string) *sync.Mutex {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	output, ok := b.outputs[key]
+	if !ok {
+		output = &sync.Mutex{}
+		b.outputs[key] = output
+	}
+	return output
+}
+
+// write attempts fn, buffering it under key if it fails with a transient error. A successful write replaces any
+// buffered write for the same key
+func (b *BufferedClient) write(key string, fn func() error) error {
+	output := b.output(key)
+	output.Lock()
+	defer output.Unlock()
+	err := fn()
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if err == nil {
+		b.remove(key)
+		return nil
+	}
+	if !isTransient(err) {
+		return err
+	}
+	if _, ok := b.pending[key]; !ok && len(b.pending) >= b.opts.MaxPending {
+		return err
+	}
+	logrus.Warnf("[k8s] unable to write %s, will retry: %v", key, err)
+	b.pending[key] = &bufferedWrite{fn: fn}
+	metrics.PendingWrites.Set(float64(len(b.pending)))
+	select {
+	case b.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// remove drops the buffered write for key. Must be called while holding the lock
+func (b *BufferedClient) remove(key string) {
+	delete(b.pending, key)
+	metrics.PendingWrites.Set(float64(len(b.pending)))
+}
+
+// Run retries buffered writes until ctx is cancelled
+func (b *BufferedClient) Run(ctx context.Context) {
+	backoff := b.opts.InitialBackoff
+	for {
+		if b.Pending() == 0 {
+			backoff = b.opts.InitialBackoff
+			select {
+			case <-ctx.Done():
+				return
+			case <-b.wake:
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		if b.flush() {
+			backoff = b.opts.InitialBackoff
+			continue
+		}
+		backoff *= 2
+		if backoff > b.opts.MaxBackoff {
+			backoff = b.opts.MaxBackoff
+		}
+	}
+}
+
+// flush retries every buffered write, returning true if all of them succeeded
+func (b *BufferedClient) flush() bool {
+	b.lock.Lock()
+	pending := make(map[string]*bufferedWrite, len(b.pending))
+	for key, w := range b.pending {
+		pending[key] = w
+	}
+	b.lock.Unlock()
+
+	flushed := true
+	for key, w := range pending {
+		if !b.retry(key, w) {
+			flushed = false
+		}
+	}
+	return flushed
+}
+
+// retry retries the buffered write w of key, returning false if it failed again. w is skipped if a newer write of key
+// replaced it since it was buffered
+func (b *BufferedClient) retry(key string, w *bufferedWrite) bool {
+	output := b.output(key)
+	output.Lock()
+	defer output.Unlock()
+	b.lock.Lock()
+	current := b.pending[key]
+	b.lock.Unlock()
+	if current != w {
+		// written or buffered again meanwhile, a newer buffered write is retried by the next flush
+		return current == nil
+	}
+	err := w.fn()
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	switch {
+	case err == nil:
+		logrus.Infof("[k8s] wrote buffered %s", key)
+		b.remove(key)
+	case !isTransient(err):
+		logrus.Errorf("[k8s] dropping buffered write of %s: %v", key, err)
+		b.remove(key)
+	default:
+		logrus.Debugf("[k8s] retry of buffered write of %s failed: %v", key, err)
+		return false
+	}
+	return true
+}
+
+// isTransient returns whether err is likely caused by the kubernetes api being briefly unavailable or overloaded
+func isTransient(err error) bool {
+	if apierror.IsServerTimeout(err) || apierror.IsTimeout(err) || apierror.IsTooManyRequests(err) ||
+		apierror.IsServiceUnavailable(err) || apierror.IsInternalError(err) {
+		return true
+	}
+	if utilnet.IsConnectionRefused(err) || utilnet.IsConnectionReset(err) || utilnet.IsProbableEOF(err) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded)
+}
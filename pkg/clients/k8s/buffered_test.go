@@ -0,0 +1,131 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	apierror "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// flakyClient fails writes with err until it is cleared, recording the writes which succeeded
+type flakyClient struct {
+	Client
+
+	lock          sync.Mutex
+	err           error
+	configs       []string
+	notifications []string
+	// beforeConfig, if set, is called before each csp config is written
+	beforeConfig func(data string)
+}
+
+func (f *flakyClient) setErr(err error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.err = err
+}
+
+func (f *flakyClient) written() ([]string, []string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return append([]string(nil), f.configs...), append([]string(nil), f.notifications...)
+}
+
+func (f *flakyClient) UpdateCSPConfigOutput(marshalledData []byte) error {
+	if f.beforeConfig != nil {
+		f.beforeConfig(string(marshalledData))
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.err != nil {
+		return f.err
+	}
+	f.configs = append(f.configs, string(marshalledData))
+	return nil
+}
+
+func (f *flakyClient) UpdateUserNotification(isInCompliance bool, message string) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.err != nil {
+		return f.err
+	}
+	f.notifications = append(f.notifications, message)
+	return nil
+}
+
+func TestBufferedClient(t *testing.T) {
+	flaky := &flakyClient{}
+	buffered := NewBufferedClient(flaky, BufferOptions{InitialBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond, MaxPending: 10})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go buffered.Run(ctx)
+
+	flaky.setErr(apierror.NewServerTimeout(schema.GroupResource{Resource: "configmaps"}, "update", 1))
+	assert.NoError(t, buffered.UpdateCSPConfigOutput([]byte("first")), "transient errors should be buffered")
+	assert.NoError(t, buffered.UpdateCSPConfigOutput([]byte("second")))
+	assert.NoError(t, buffered.UpdateUserNotification(false, "not compliant"))
+	assert.Equal(t, 2, buffered.Pending(), "only the latest write of each output should be kept")
+
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, 2, buffered.Pending(), "writes should stay buffered while the api is unavailable")
+
+	flaky.setErr(nil)
+	assert.Eventually(t, func() bool { return buffered.Pending() == 0 }, time.Second, time.Millisecond)
+	configs, notifications := flaky.written()
+	assert.Equal(t, []string{"second"}, configs)
+	assert.Equal(t, []string{"not compliant"}, notifications)
+}
+
+func TestBufferedClientErrors(t *testing.T) {
+	flaky := &flakyClient{}
+	buffered := NewBufferedClient(flaky, BufferOptions{InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond, MaxPending: 1})
+
+	flaky.setErr(apierror.NewForbidden(schema.GroupResource{Resource: "configmaps"}, "csp-config", fmt.Errorf("denied")))
+	assert.Error(t, buffered.UpdateCSPConfigOutput([]byte("data")), "permanent errors should be returned")
+	assert.Equal(t, 0, buffered.Pending())
+
+	flaky.setErr(apierror.NewTooManyRequests("throttled", 1))
+	assert.NoError(t, buffered.UpdateCSPConfigOutput([]byte("data")))
+	assert.Error(t, buffered.UpdateUserNotification(false, "message"), "writes failing while the buffer is full should return their error")
+	assert.NoError(t, buffered.UpdateCSPConfigOutput([]byte("newer")), "a buffered output can always be replaced")
+
+	flaky.setErr(nil)
+	assert.NoError(t, buffered.UpdateCSPConfigOutput([]byte("latest")))
+	assert.Equal(t, 0, buffered.Pending(), "a successful write should replace the buffered one")
+}
+
+func TestBufferedClientRetryOrder(t *testing.T) {
+	flaky := &flakyClient{}
+	buffered := NewBufferedClient(flaky, DefaultBufferOptions)
+	flaky.setErr(apierror.NewServerTimeout(schema.GroupResource{Resource: "configmaps"}, "update", 1))
+	assert.NoError(t, buffered.UpdateCSPConfigOutput([]byte("older")))
+	flaky.setErr(nil)
+
+	retrying, release := make(chan struct{}), make(chan struct{})
+	flaky.beforeConfig = func(data string) {
+		if data == "older" {
+			close(retrying)
+			<-release
+		}
+	}
+	flushed := make(chan bool)
+	go func() { flushed <- buffered.flush() }()
+	<-retrying
+	written := make(chan error)
+	go func() { written <- buffered.UpdateCSPConfigOutput([]byte("newer")) }()
+	// the newer write waits for the retry of the older one instead of being overwritten by it
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	assert.True(t, <-flushed)
+	assert.NoError(t, <-written)
+
+	configs, _ := flaky.written()
+	assert.Equal(t, []string{"older", "newer"}, configs)
+	assert.Equal(t, 0, buffered.Pending())
+}
@@ -0,0 +1,140 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	apierror "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// CheckoutRequestResource is the LicenseCheckoutRequest custom resource installed by the chart, requesting a checkout
+// of a fixed number of entitlements for a fixed time, i.e. ahead of a planned burst of nodes
+var CheckoutRequestResource = schema.GroupVersionResource{
+	Group:    "csp-adapter.cattle.io",
+	Version:  "v1",
+	Resource: "licensecheckoutrequests",
+}
+
+// Phases of a LicenseCheckoutRequest
+const (
+	// CheckoutRequestPending requests weren't checked out yet, they're retried by every check
+	CheckoutRequestPending = "Pending"
+	// CheckoutRequestActive requests hold a checkout until they expire
+	CheckoutRequestActive = "Active"
+	// CheckoutRequestFailed requests can't be checked out, or their checkout was lost
+	CheckoutRequestFailed = "Failed"
+)
+
+// CheckoutRequest is a LicenseCheckoutRequest
+type CheckoutRequest struct {
+	Name string
+	UID  string
+	// Dimension is the entitlement dimension to check out, empty for the dimension the adapter checks out
+	Dimension string
+	Count     int
+	Duration  time.Duration
+	Reason    string
+	// SpecError describes why the spec can't be read, the request can't be checked out if it's set
+	SpecError string
+	Status    CheckoutRequestStatus
+}
+
+// CheckoutRequestStatus is the status of a LicenseCheckoutRequest, which holds the checkout made for it
+type CheckoutRequestStatus struct {
+	Phase            string
+	Message          string
+	ConsumptionToken string
+	// CheckedOutAt is when the checkout was made, Expires when it's checked in and the request is deleted
+	CheckedOutAt time.Time
+	Expires      time.Time
+	// TokenExpiry is when the consumption token expires unless it's extended
+	TokenExpiry time.Time
+}
+
+func (c *Clients) GetCheckoutRequests() ([]CheckoutRequest, error) {
+	list, err := c.Checkouts.List(context.Background(), metav1.ListOptions{})
+	if apierror.IsNotFound(err) {
+		// helm doesn't install the CRDs of a chart on upgrade, until they're applied nothing can be requested
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	requests := make([]CheckoutRequest, 0, len(list.Items))
+	for _, item := range list.Items {
+		requests = append(requests, checkoutRequestFromUnstructured(item))
+	}
+	return requests, nil
+}
+
+func (c *Clients) UpdateCheckoutRequestStatus(name string, status CheckoutRequestStatus) error {
+	obj, err := c.Checkouts.Get(context.Background(), name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	fields := map[string]interface{}{"phase": status.Phase}
+	for field, value := range map[string]string{"message": status.Message, "consumptionToken": status.ConsumptionToken} {
+		if value != "" {
+			fields[field] = value
+		}
+	}
+	for field, value := range map[string]time.Time{"checkedOutAt": status.CheckedOutAt, "expires": status.Expires, "tokenExpiry": status.TokenExpiry} {
+		if !value.IsZero() {
+			fields[field] = value.UTC().Format(time.RFC3339)
+		}
+	}
+	if err := unstructured.SetNestedMap(obj.Object, fields, "status"); err != nil {
+		return err
+	}
+	_, err = c.Checkouts.UpdateStatus(context.Background(), obj, metav1.UpdateOptions{})
+	return err
+}
+
+func (c *Clients) DeleteCheckoutRequest(name string) error {
+	err := c.Checkouts.Delete(context.Background(), name, metav1.DeleteOptions{})
+	if apierror.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// checkoutRequestFromUnstructured reads a LicenseCheckoutRequest. Unlike exemptions, a request whose spec is invalid
+// is returned with a SpecError so that the reason can be reported in its status
+func checkoutRequestFromUnstructured(obj unstructured.Unstructured) CheckoutRequest {
+	request := CheckoutRequest{Name: obj.GetName(), UID: string(obj.GetUID())}
+	request.Dimension, _, _ = unstructured.NestedString(obj.Object, "spec", "dimension")
+	request.Reason, _, _ = unstructured.NestedString(obj.Object, "spec", "reason")
+	request.Status = checkoutRequestStatusFromUnstructured(obj)
+	count, ok, err := unstructured.NestedInt64(obj.Object, "spec", "count")
+	if err != nil || !ok || count < 1 {
+		request.SpecError = "spec.count must be at least 1"
+		return request
+	}
+	request.Count = int(count)
+	duration, err := specString(obj, "duration")
+	if err != nil {
+		request.SpecError = err.Error()
+		return request
+	}
+	if request.Duration, err = time.ParseDuration(duration); err != nil || request.Duration <= 0 {
+		request.SpecError = fmt.Sprintf("spec.duration %q isn't a positive duration, i.e. 6h", duration)
+	}
+	return request
+}
+
+func checkoutRequestStatusFromUnstructured(obj unstructured.Unstructured) CheckoutRequestStatus {
+	var status CheckoutRequestStatus
+	status.Phase, _, _ = unstructured.NestedString(obj.Object, "status", "phase")
+	status.Message, _, _ = unstructured.NestedString(obj.Object, "status", "message")
+	status.ConsumptionToken, _, _ = unstructured.NestedString(obj.Object, "status", "consumptionToken")
+	for field, value := range map[string]*time.Time{"checkedOutAt": &status.CheckedOutAt, "expires": &status.Expires, "tokenExpiry": &status.TokenExpiry} {
+		if timestamp, _, _ := unstructured.NestedString(obj.Object, "status", field); timestamp != "" {
+			*value, _ = time.Parse(time.RFC3339, timestamp)
+		}
+	}
+	return status
+}
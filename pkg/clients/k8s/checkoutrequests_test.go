@@ -0,0 +1,59 @@
+package k8s
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic/fake"
+)
+
+func licenseCheckoutRequest(name string, spec map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "csp-adapter.cattle.io/v1",
+		"kind":       "LicenseCheckoutRequest",
+		"metadata":   map[string]interface{}{"name": name, "uid": name + "-uid"},
+		"spec":       spec,
+	}}
+}
+
+func TestCheckoutRequests(t *testing.T) {
+	client := fake.NewSimpleDynamicClient(runtime.NewScheme(), licenseCheckoutRequest("burst", map[string]interface{}{
+		"count":    int64(3),
+		"duration": "6h",
+		"reason":   "load test",
+	}))
+	clients := &Clients{Checkouts: client.Resource(CheckoutRequestResource)}
+	requests, err := clients.GetCheckoutRequests()
+	require.NoError(t, err)
+	assert.Equal(t, []CheckoutRequest{{Name: "burst", UID: "burst-uid", Count: 3, Duration: 6 * time.Hour, Reason: "load test"}}, requests)
+
+	expires := time.Date(2022, 3, 31, 6, 0, 0, 0, time.UTC)
+	status := CheckoutRequestStatus{
+		Phase:            CheckoutRequestActive,
+		ConsumptionToken: "token",
+		CheckedOutAt:     expires.Add(-6 * time.Hour),
+		Expires:          expires,
+		TokenExpiry:      expires.Add(-5 * time.Hour),
+	}
+	require.NoError(t, clients.UpdateCheckoutRequestStatus("burst", status))
+	requests, err = clients.GetCheckoutRequests()
+	require.NoError(t, err)
+	assert.Equal(t, status, requests[0].Status, "the status is read back")
+
+	require.NoError(t, clients.DeleteCheckoutRequest("burst"))
+	require.NoError(t, clients.DeleteCheckoutRequest("burst"), "deleting a deleted request succeeds")
+
+	for name, spec := range map[string]map[string]interface{}{
+		"no count":         {"duration": "6h"},
+		"no duration":      {"count": int64(3)},
+		"invalid duration": {"count": int64(3), "duration": "until friday"},
+		"negative":         {"count": int64(3), "duration": "-1h"},
+	} {
+		request := checkoutRequestFromUnstructured(*licenseCheckoutRequest("invalid", spec))
+		assert.NotEmpty(t, request.SpecError, name)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/rancher/lasso/pkg/controller"
 	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
@@ -14,22 +15,35 @@ import (
 	"github.com/rancher/wrangler/pkg/clients"
 	v1 "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
 	"github.com/rancher/wrangler/pkg/generic"
+	"github.com/sirupsen/logrus"
 	corev1 "k8s.io/api/core/v1"
 	apierror "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
+	appsclient "k8s.io/client-go/kubernetes/typed/apps/v1"
+	authclient "k8s.io/client-go/kubernetes/typed/authentication/v1"
+	authorizationclient "k8s.io/client-go/kubernetes/typed/authorization/v1"
 	"k8s.io/client-go/rest"
 )
 
 const (
-	cspAdapterNamespace = "cattle-csp-adapter-system"
-	cspAdapterSecret    = "K8S_CACHE_SECRET"
-	cspAdapterConfigMap = "K8S_OUTPUT_CONFIGMAP"
-	cspNotification     = "K8S_OUTPUT_NOTIFICATION"
-	hostnameSettingEnv  = "K8S_HOSTNAME_SETTING"
-	versionSettingEnv   = "K8S_RANCHER_VERSION_SETTING"
-	cspConfigKey        = "data"
-	cspComponentName    = "csp-adapter"
+	cspAdapterNamespace  = "cattle-csp-adapter-system"
+	cspAdapterSecret     = "K8S_CACHE_SECRET"
+	cspAdapterConfigMap  = "K8S_OUTPUT_CONFIGMAP"
+	cspNotification      = "K8S_OUTPUT_NOTIFICATION"
+	hostnameSettingEnv   = "K8S_HOSTNAME_SETTING"
+	versionSettingEnv    = "K8S_RANCHER_VERSION_SETTING"
+	complianceSettingEnv = "K8S_COMPLIANCE_SETTING"
+	cspConfigKey         = "data"
+	cspComponentName     = "csp-adapter"
+	// clusterSummaryName is the name of the configmap published to each downstream cluster's namespace
+	clusterSummaryName = "csp-adapter-cluster-summary"
+	// usageHistoryPrefix is followed by the month for the configmaps holding the usage history of each month
+	usageHistoryPrefix = "csp-adapter-usage-"
+	usageHistoryKey    = "usage"
+	// featuresName is the name of the configmap holding the values of feature flags, rendered from the chart's values
+	featuresName = "csp-adapter-features"
 )
 
 var (
@@ -38,8 +52,10 @@ var (
 	cacheName              string
 	hostnameSetting        string
 	versionSetting         string
+	complianceSetting      string
 )
 
+// Client is the adapter's contract with the cluster rancher runs in. mocks.K8sClientMock implements it for unit tests
 type Client interface {
 	// GetConsumptionTokenSecret retrieves the secret containing consumption token info from k8s
 	GetConsumptionTokenSecret() (*corev1.Secret, error)
@@ -49,17 +65,84 @@ type Client interface {
 	UpdateCSPConfigOutput(marshalledData []byte) error
 	// UpdateUserNotification creates/updates a RancherUserNotification based on isInCompliance and the provided message
 	UpdateUserNotification(isInCompliance bool, message string) error
+	// UpdateComplianceSetting stores value, a summary of the compliance state, in the rancher setting created by the
+	// chart for UI extensions
+	UpdateComplianceSetting(value string) error
+	// UpdateComplianceCondition sets the LicenseCompliantCondition of rancher's local cluster
+	UpdateComplianceCondition(compliant bool, reason, message string) error
 	// GetRancherHostname finds the hostname for the core rancher install from the settings.
 	GetRancherHostname() (string, error)
 	// GetRancherVersion finds the version of rancher from the settings
 	GetRancherVersion() (string, error)
+	// GetClusterUID returns an identifier of the cluster rancher is installed in, which changes when rancher is
+	// restored into a different cluster
+	GetClusterUID() (string, error)
+	// UpdateClusterSummary stores the summary for a downstream cluster as a configmap in the cluster's namespace
+	UpdateClusterSummary(clusterID string, marshalledData []byte) error
+	// GetUsageHistory returns the usage history stored for month (i.e. 2006-01), or nil if none is stored
+	GetUsageHistory(month string) ([]byte, error)
+	// UpdateUsageHistory stores the usage history of month
+	UpdateUsageHistory(month string, data []byte) error
+	// ListUsageHistory returns the months usage history is stored for
+	ListUsageHistory() ([]string, error)
+	// DeleteUsageHistory deletes the usage history stored for month
+	DeleteUsageHistory(month string) error
+	// GetFeatureFlags returns the values of feature flags set for this install, by flag name
+	GetFeatureFlags() (map[string]string, error)
+	// GetAdapterConfig returns the CSPAdapterConfig, nil if it doesn't exist
+	GetAdapterConfig() (*AdapterConfig, error)
+	// GetClusters returns the downstream clusters managed by rancher, by cluster id
+	GetClusters() (map[string]ClusterInfo, error)
+	// GetUsers returns the people who can log into rancher, excluding the users rancher creates for itself
+	GetUsers() ([]RancherUser, error)
+	// GetEnabledAuthProviders returns the names of the external auth providers enabled in rancher (i.e.
+	// activedirectory), sorted, without the local provider
+	GetEnabledAuthProviders() ([]string, error)
+	// GetNodeCreationTimes returns when each existing node of the downstream clusters was registered with rancher
+	GetNodeCreationTimes() ([]time.Time, error)
+	// GetExemptions returns the ClusterLicenseExemptions declared by admins, including expired ones
+	GetExemptions() ([]Exemption, error)
+	// GetCheckoutRequests returns the LicenseCheckoutRequests made by admins
+	GetCheckoutRequests() ([]CheckoutRequest, error)
+	// UpdateCheckoutRequestStatus replaces the status of the LicenseCheckoutRequest name
+	UpdateCheckoutRequestStatus(name string, status CheckoutRequestStatus) error
+	// DeleteCheckoutRequest deletes the LicenseCheckoutRequest name, succeeding if it doesn't exist
+	DeleteCheckoutRequest(name string) error
 }
 
+// ClusterInfo describes a downstream cluster managed by rancher
+type ClusterInfo struct {
+	Name              string
+	KubernetesVersion string
+	// Provider is the distribution or hosted service the cluster runs on as detected by rancher, i.e. rke2, k3s or eks
+	Provider string
+	// Environment is the value of the cluster's EnvironmentLabel, empty if it isn't labeled
+	Environment string
+}
+
+// EnvironmentLabel on a rancher cluster classifies it as sdk.EnvironmentProduction or sdk.EnvironmentNonProduction
+const EnvironmentLabel = "cattle.io/environment"
+
 type Clients struct {
-	ConfigMaps    v1.ConfigMapClient
-	Secrets       v1.SecretController
-	Notifications mgmtv3.RancherUserNotificationClient
-	Settings      mgmtv3.SettingClient
+	ConfigMaps     v1.ConfigMapClient
+	Namespaces     v1.NamespaceClient
+	Secrets        v1.SecretController
+	Notifications  mgmtv3.RancherUserNotificationClient
+	Settings       mgmtv3.SettingClient
+	Clusters       mgmtv3.ClusterClient
+	Nodes          mgmtv3.NodeClient
+	Users          mgmtv3.UserClient
+	UserAttributes mgmtv3.UserAttributeClient
+	AuthConfigs    mgmtv3.AuthConfigClient
+	TokenReviews   authclient.TokenReviewInterface
+	AccessReviews  authorizationclient.SelfSubjectAccessReviewInterface
+	Deployments    appsclient.DeploymentInterface
+	// Exemptions is the client of the ClusterLicenseExemptions, which are cluster scoped
+	Exemptions dynamic.NamespaceableResourceInterface
+	// Checkouts is the client of the LicenseCheckoutRequests, which are cluster scoped
+	Checkouts dynamic.NamespaceableResourceInterface
+	// Configs is the client of the CSPAdapterConfigs, which are cluster scoped
+	Configs dynamic.NamespaceableResourceInterface
 }
 
 func New(ctx context.Context, rest *rest.Config) (*Clients, error) {
@@ -95,12 +178,55 @@ func New(ctx context.Context, rest *rest.Config) (*Clients, error) {
 	if err != nil {
 		return nil, err
 	}
+	dynamicClient, err := dynamic.NewForConfig(rest)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Clients{
+		ConfigMaps:     clients.Core.ConfigMap(),
+		Namespaces:     clients.Core.Namespace(),
+		Secrets:        clients.Core.Secret(),
+		Notifications:  mgmt.Management().V3().RancherUserNotification(),
+		Settings:       mgmt.Management().V3().Setting(),
+		Clusters:       mgmt.Management().V3().Cluster(),
+		Nodes:          mgmt.Management().V3().Node(),
+		Users:          mgmt.Management().V3().User(),
+		UserAttributes: mgmt.Management().V3().UserAttribute(),
+		AuthConfigs:    mgmt.Management().V3().AuthConfig(),
+		TokenReviews:   clients.K8s.AuthenticationV1().TokenReviews(),
+		AccessReviews:  clients.K8s.AuthorizationV1().SelfSubjectAccessReviews(),
+		Deployments:    clients.K8s.AppsV1().Deployments(cspAdapterNamespace),
+		Exemptions:     dynamicClient.Resource(ExemptionResource),
+		Checkouts:      dynamicClient.Resource(CheckoutRequestResource),
+		Configs:        dynamicClient.Resource(AdapterConfigResource),
+	}, nil
+}
+
+// NewUsageHistoryReader returns clients which can only be used to read the usage history, for commands which run
+// outside of the adapter's pod
+func NewUsageHistoryReader(rest *rest.Config) (*Clients, error) {
+	clients, err := clients.NewFromConfig(rest, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Clients{
+		ConfigMaps: clients.Core.ConfigMap(),
+	}, nil
+}
 
+// NewStateClients returns clients which can only be used to export and import the adapter's state, for commands which
+// run outside of the adapter's pod. cacheSecret is the name of the adapter's cache secret
+func NewStateClients(rest *rest.Config, cacheSecret string) (*Clients, error) {
+	clients, err := clients.NewFromConfig(rest, nil)
+	if err != nil {
+		return nil, err
+	}
+	cacheName = cacheSecret
 	return &Clients{
-		ConfigMaps:    clients.Core.ConfigMap(),
-		Secrets:       clients.Core.Secret(),
-		Notifications: mgmt.Management().V3().RancherUserNotification(),
-		Settings:      mgmt.Management().V3().Setting(),
+		ConfigMaps:  clients.Core.ConfigMap(),
+		Secrets:     clients.Core.Secret(),
+		Deployments: clients.K8s.AppsV1().Deployments(cspAdapterNamespace),
 	}, nil
 }
 
@@ -113,6 +239,8 @@ func readConstantsFromEnv() error {
 	outputConfigMapName = os.Getenv(cspAdapterConfigMap)
 	hostnameSetting = os.Getenv(hostnameSettingEnv)
 	versionSetting = os.Getenv(versionSettingEnv)
+	// optional, the compliance state isn't written to a setting without it
+	complianceSetting = os.Getenv(complianceSettingEnv)
 	var missingEnvVars []string
 	if cacheName == "" {
 		missingEnvVars = append(missingEnvVars, cspAdapterSecret)
@@ -153,6 +281,10 @@ func (c *Clients) UpdateConsumptionTokenSecret(data map[string]string) error {
 		}
 		return err
 	}
+	if sameSecretData(secret.Data, data) {
+		skipUnchanged("cache secret")
+		return nil
+	}
 	secret = secret.DeepCopy()
 	secret.StringData = data
 	_, err = c.Secrets.Update(secret)
@@ -175,12 +307,130 @@ func (c *Clients) UpdateCSPConfigOutput(marshalledData []byte) error {
 		})
 		return err
 	}
+	if err != nil {
+		return err
+	}
+	if sameData(currentConfigMap.Data, data) {
+		skipUnchanged("csp config")
+		return nil
+	}
 	currentConfigMap = currentConfigMap.DeepCopy()
 	currentConfigMap.Data = data
 	_, err = c.ConfigMaps.Update(currentConfigMap)
 	return err
 }
 
+func (c *Clients) GetClusterUID() (string, error) {
+	// kube-system exists in every cluster and is never recreated, so its uid identifies the cluster
+	namespace, err := c.Namespaces.Get(metav1.NamespaceSystem, metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	return string(namespace.UID), nil
+}
+
+func (c *Clients) UpdateClusterSummary(clusterID string, marshalledData []byte) error {
+	// rancher creates a namespace named after each cluster in the local cluster, which the cluster's owners can access
+	data := map[string]string{
+		cspConfigKey: string(marshalledData),
+	}
+	current, err := c.ConfigMaps.Get(clusterID, clusterSummaryName, metav1.GetOptions{})
+	if apierror.IsNotFound(err) {
+		_, err = c.ConfigMaps.Create(&corev1.ConfigMap{
+			Data: data,
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      clusterSummaryName,
+				Namespace: clusterID,
+			},
+		})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if sameData(current.Data, data) {
+		skipUnchanged("summary of cluster " + clusterID)
+		return nil
+	}
+	current = current.DeepCopy()
+	current.Data = data
+	_, err = c.ConfigMaps.Update(current)
+	return err
+}
+
+func (c *Clients) GetUsageHistory(month string) ([]byte, error) {
+	configMap, err := c.ConfigMaps.Get(cspAdapterNamespace, usageHistoryPrefix+month, metav1.GetOptions{})
+	if apierror.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []byte(configMap.Data[usageHistoryKey]), nil
+}
+
+func (c *Clients) UpdateUsageHistory(month string, data []byte) error {
+	configMapData := map[string]string{
+		usageHistoryKey: string(data),
+	}
+	current, err := c.ConfigMaps.Get(cspAdapterNamespace, usageHistoryPrefix+month, metav1.GetOptions{})
+	if apierror.IsNotFound(err) {
+		_, err = c.ConfigMaps.Create(&corev1.ConfigMap{
+			Data: configMapData,
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      usageHistoryPrefix + month,
+				Namespace: cspAdapterNamespace,
+			},
+		})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if sameData(current.Data, configMapData) {
+		skipUnchanged("usage history of " + month)
+		return nil
+	}
+	current = current.DeepCopy()
+	current.Data = configMapData
+	_, err = c.ConfigMaps.Update(current)
+	return err
+}
+
+func (c *Clients) ListUsageHistory() ([]string, error) {
+	list, err := c.ConfigMaps.List(cspAdapterNamespace, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var months []string
+	for _, configMap := range list.Items {
+		if strings.HasPrefix(configMap.Name, usageHistoryPrefix) {
+			months = append(months, strings.TrimPrefix(configMap.Name, usageHistoryPrefix))
+		}
+	}
+	return months, nil
+}
+
+func (c *Clients) DeleteUsageHistory(month string) error {
+	err := c.ConfigMaps.Delete(cspAdapterNamespace, usageHistoryPrefix+month, &metav1.DeleteOptions{})
+	if apierror.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+func (c *Clients) GetFeatureFlags() (map[string]string, error) {
+	configMap, err := c.ConfigMaps.Get(cspAdapterNamespace, featuresName, metav1.GetOptions{})
+	if apierror.IsNotFound(err) {
+		// no flags set, all use their defaults
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return configMap.Data, nil
+}
+
 func (c *Clients) UpdateUserNotification(isInCompliance bool, message string) error {
 	if isInCompliance {
 		// if we are in compliance, remove any existing notification
@@ -204,6 +454,10 @@ func (c *Clients) UpdateUserNotification(isInCompliance bool, message string) er
 			}
 			return err
 		}
+		if current.Message == message && current.ComponentName == cspComponentName {
+			skipUnchanged("notification")
+			return nil
+		}
 		// update all relevant fields - also updating component name to future-proof against changes made to this field
 		current = current.DeepCopy()
 		current.Message = message
@@ -216,6 +470,30 @@ func (c *Clients) UpdateUserNotification(isInCompliance bool, message string) er
 	return nil
 }
 
+func (c *Clients) UpdateComplianceSetting(value string) error {
+	if complianceSetting == "" {
+		return nil
+	}
+	current, err := c.Settings.Get(complianceSetting, metav1.GetOptions{})
+	if apierror.IsNotFound(err) {
+		// the adapter isn't allowed to create settings, the chart creates this one
+		logrus.Warnf("[k8s] setting %s doesn't exist, reinstall the chart to publish the compliance state to it", complianceSetting)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if sameValue(current.Value, value) {
+		// unchanged, don't wake up everything watching settings
+		skipUnchanged("compliance setting")
+		return nil
+	}
+	current = current.DeepCopy()
+	current.Value = value
+	_, err = c.Settings.Update(current)
+	return err
+}
+
 func (c *Clients) GetRancherHostname() (string, error) {
 	setting, err := c.Settings.Get(hostnameSetting, metav1.GetOptions{})
 	if err != nil {
@@ -233,3 +511,23 @@ func (c *Clients) GetRancherVersion() (string, error) {
 	}
 	return setting.Value, nil
 }
+
+func (c *Clients) GetClusters() (map[string]ClusterInfo, error) {
+	list, err := c.Clusters.List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	clusters := map[string]ClusterInfo{}
+	for _, cluster := range list.Items {
+		info := ClusterInfo{Name: cluster.Spec.DisplayName, Provider: cluster.Status.Provider, Environment: cluster.Labels[EnvironmentLabel]}
+		if info.Provider == "" {
+			// imported clusters of an undetected distribution only have the driver they were registered with
+			info.Provider = cluster.Status.Driver
+		}
+		if cluster.Status.Version != nil {
+			info.KubernetesVersion = cluster.Status.Version.GitVersion
+		}
+		clusters[cluster.Name] = info
+	}
+	return clusters, nil
+}
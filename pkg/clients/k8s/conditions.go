@@ -0,0 +1,89 @@
+package k8s
+
+import (
+	"encoding/json"
+	"time"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	// LicenseCompliantCondition is the condition of rancher's local cluster reporting whether rancher is licensed, so
+	// that alerts on cluster conditions surface compliance problems
+	LicenseCompliantCondition v3.ClusterConditionType = "LicenseCompliant"
+	// localClusterName is the name of the cluster rancher is installed in
+	localClusterName = "local"
+)
+
+func (c *Clients) UpdateComplianceCondition(compliant bool, reason, message string) error {
+	status := corev1.ConditionFalse
+	if compliant {
+		status = corev1.ConditionTrue
+	}
+	desired := v3.ClusterCondition{
+		Type:    LicenseCompliantCondition,
+		Status:  status,
+		Reason:  reason,
+		Message: message,
+	}
+	// only the conditions are patched, so that fields rancher changes in the meantime aren't written back. A merge
+	// patch replaces the whole list, the resource version makes it conflict, and be retried, if rancher changed the
+	// conditions since they were read
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cluster, err := c.Clusters.Get(localClusterName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		conditions, changed := setCondition(cluster.Status.Conditions, desired, time.Now())
+		if !changed {
+			skipUnchanged("local cluster condition")
+			return nil
+		}
+		patch, err := conditionsPatch(cluster.ResourceVersion, conditions)
+		if err != nil {
+			return err
+		}
+		_, err = c.Clusters.Patch(localClusterName, types.MergePatchType, patch)
+		return err
+	})
+}
+
+// conditionsPatch returns the merge patch setting the conditions of a cluster at resourceVersion
+func conditionsPatch(resourceVersion string, conditions []v3.ClusterCondition) ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{"resourceVersion": resourceVersion},
+		"status":   map[string]interface{}{"conditions": conditions},
+	})
+}
+
+// setCondition returns conditions with the condition of desired's type replaced by desired, and whether that changed
+// it. The transition time is kept unless the status changed
+func setCondition(conditions []v3.ClusterCondition, desired v3.ClusterCondition, now time.Time) ([]v3.ClusterCondition, bool) {
+	timestamp := now.UTC().Format(time.RFC3339)
+	desired.LastUpdateTime = timestamp
+	desired.LastTransitionTime = timestamp
+	updated := make([]v3.ClusterCondition, 0, len(conditions)+1)
+	found := false
+	for _, condition := range conditions {
+		if condition.Type != desired.Type {
+			updated = append(updated, condition)
+			continue
+		}
+		if condition.Status == desired.Status && condition.Reason == desired.Reason && condition.Message == desired.Message {
+			return conditions, false
+		}
+		if condition.Status == desired.Status {
+			desired.LastTransitionTime = condition.LastTransitionTime
+		}
+		updated = append(updated, desired)
+		found = true
+	}
+	if !found {
+		updated = append(updated, desired)
+	}
+	return updated, true
+}
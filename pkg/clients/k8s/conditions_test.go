@@ -0,0 +1,79 @@
+package k8s
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	v3 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestSetCondition(t *testing.T) {
+	before := "2022-01-01T00:00:00Z"
+	now := time.Date(2022, 1, 2, 0, 0, 0, 0, time.UTC)
+	nowStamp := now.Format(time.RFC3339)
+	ready := v3.ClusterCondition{Type: "Ready", Status: corev1.ConditionTrue, LastUpdateTime: before, LastTransitionTime: before}
+	compliant := v3.ClusterCondition{Type: LicenseCompliantCondition, Status: corev1.ConditionTrue, Reason: "Licensed",
+		Message: "3 licenses", LastUpdateTime: before, LastTransitionTime: before}
+	tests := []struct {
+		name       string
+		conditions []v3.ClusterCondition
+		desired    v3.ClusterCondition
+		expected   []v3.ClusterCondition
+		changed    bool
+	}{
+		{
+			name:       "added",
+			conditions: []v3.ClusterCondition{ready},
+			desired:    v3.ClusterCondition{Type: LicenseCompliantCondition, Status: corev1.ConditionTrue, Reason: "Licensed", Message: "3 licenses"},
+			expected: []v3.ClusterCondition{ready, {Type: LicenseCompliantCondition, Status: corev1.ConditionTrue, Reason: "Licensed",
+				Message: "3 licenses", LastUpdateTime: nowStamp, LastTransitionTime: nowStamp}},
+			changed: true,
+		},
+		{
+			name:       "unchanged",
+			conditions: []v3.ClusterCondition{ready, compliant},
+			desired:    v3.ClusterCondition{Type: LicenseCompliantCondition, Status: corev1.ConditionTrue, Reason: "Licensed", Message: "3 licenses"},
+			expected:   []v3.ClusterCondition{ready, compliant},
+		},
+		{
+			name:       "message changed keeps transition time",
+			conditions: []v3.ClusterCondition{compliant, ready},
+			desired:    v3.ClusterCondition{Type: LicenseCompliantCondition, Status: corev1.ConditionTrue, Reason: "Licensed", Message: "4 licenses"},
+			expected: []v3.ClusterCondition{{Type: LicenseCompliantCondition, Status: corev1.ConditionTrue, Reason: "Licensed",
+				Message: "4 licenses", LastUpdateTime: nowStamp, LastTransitionTime: before}, ready},
+			changed: true,
+		},
+		{
+			name:       "status changed",
+			conditions: []v3.ClusterCondition{ready, compliant},
+			desired:    v3.ClusterCondition{Type: LicenseCompliantCondition, Status: corev1.ConditionFalse, Reason: "InsufficientLicenses", Message: "3 licenses"},
+			expected: []v3.ClusterCondition{ready, {Type: LicenseCompliantCondition, Status: corev1.ConditionFalse, Reason: "InsufficientLicenses",
+				Message: "3 licenses", LastUpdateTime: nowStamp, LastTransitionTime: nowStamp}},
+			changed: true,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			conditions, changed := setCondition(test.conditions, test.desired, now)
+			assert.Equal(t, test.changed, changed)
+			assert.Equal(t, test.expected, conditions)
+		})
+	}
+}
+
+func TestConditionsPatch(t *testing.T) {
+	conditions := []v3.ClusterCondition{{Type: LicenseCompliantCondition, Status: corev1.ConditionTrue, Reason: "Licensed"}}
+	patch, err := conditionsPatch("42", conditions)
+	require.NoError(t, err)
+	var fields map[string]map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(patch, &fields))
+	assert.Len(t, fields, 2, "only the metadata and status should be patched")
+	assert.Equal(t, map[string]json.RawMessage{"resourceVersion": json.RawMessage(`"42"`)}, fields["metadata"])
+	assert.Len(t, fields["status"], 1, "only the conditions of the status should be patched")
+	assert.Contains(t, fields["status"], "conditions")
+}
@@ -0,0 +1,75 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+
+	apierror "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// AdapterConfigResource is the CSPAdapterConfig custom resource installed by the chart, configuring the adapter at
+// runtime. Only the object named AdapterConfigName is read
+var AdapterConfigResource = schema.GroupVersionResource{
+	Group:    "csp-adapter.cattle.io",
+	Version:  "v1",
+	Resource: "cspadapterconfigs",
+}
+
+// AdapterConfigName is the name of the CSPAdapterConfig read by the adapter
+const AdapterConfigName = "csp-adapter"
+
+// AdapterConfig is the spec of the CSPAdapterConfig
+type AdapterConfig struct {
+	// Features are the values of feature flags by flag name, which take precedence over the chart's features configmap
+	Features map[string]string
+	// Paused pauses checkout adjustments while it's set, PauseReason is reported as the reason
+	Paused      bool
+	PauseReason string
+	// DeclaredNodes is the node count the checkout converges to, taking precedence over the chart's. Nil if the config
+	// doesn't declare one, zero to follow the counted nodes
+	DeclaredNodes *int
+}
+
+func (c *Clients) GetAdapterConfig() (*AdapterConfig, error) {
+	obj, err := c.Configs.Get(context.Background(), AdapterConfigName, metav1.GetOptions{})
+	if apierror.IsNotFound(err) {
+		// the config isn't created by the chart, and its CRD isn't installed on upgrade until it's applied
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return adapterConfigFromUnstructured(*obj)
+}
+
+// adapterConfigFromUnstructured reads the spec of a CSPAdapterConfig. Feature flags are returned as strings, the
+// values of flags which aren't booleans are ignored by the feature set
+func adapterConfigFromUnstructured(obj unstructured.Unstructured) (*AdapterConfig, error) {
+	config := &AdapterConfig{}
+	features, _, err := unstructured.NestedMap(obj.Object, "spec", "features")
+	if err != nil {
+		return nil, fmt.Errorf("invalid csp adapter config %s: %w", obj.GetName(), err)
+	}
+	if config.Paused, _, err = unstructured.NestedBool(obj.Object, "spec", "paused"); err != nil {
+		return nil, fmt.Errorf("invalid csp adapter config %s: %w", obj.GetName(), err)
+	}
+	config.PauseReason, _, _ = unstructured.NestedString(obj.Object, "spec", "pauseReason")
+	declaredNodes, found, err := unstructured.NestedInt64(obj.Object, "spec", "declaredNodes")
+	if err != nil {
+		return nil, fmt.Errorf("invalid csp adapter config %s: %w", obj.GetName(), err)
+	}
+	if found {
+		nodes := int(declaredNodes)
+		config.DeclaredNodes = &nodes
+	}
+	if len(features) > 0 {
+		config.Features = map[string]string{}
+		for name, value := range features {
+			config.Features[name] = fmt.Sprint(value)
+		}
+	}
+	return config, nil
+}
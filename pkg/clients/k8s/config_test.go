@@ -0,0 +1,47 @@
+package k8s
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic/fake"
+)
+
+func adapterConfig(name string, spec map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "csp-adapter.cattle.io/v1",
+		"kind":       "CSPAdapterConfig",
+		"metadata":   map[string]interface{}{"name": name},
+		"spec":       spec,
+	}}
+}
+
+func TestGetAdapterConfig(t *testing.T) {
+	client := fake.NewSimpleDynamicClient(runtime.NewScheme())
+	clients := &Clients{Configs: client.Resource(AdapterConfigResource)}
+	config, err := clients.GetAdapterConfig()
+	require.NoError(t, err)
+	assert.Nil(t, config, "a missing config isn't an error")
+
+	client = fake.NewSimpleDynamicClient(runtime.NewScheme(),
+		adapterConfig("other", map[string]interface{}{"features": map[string]interface{}{"usage-history": true}}),
+		adapterConfig(AdapterConfigName, map[string]interface{}{"features": map[string]interface{}{"anomaly-detection": false}}))
+	clients = &Clients{Configs: client.Resource(AdapterConfigResource)}
+	config, err = clients.GetAdapterConfig()
+	require.NoError(t, err)
+	assert.Equal(t, &AdapterConfig{Features: map[string]string{"anomaly-detection": "false"}}, config)
+
+	paused, err := adapterConfigFromUnstructured(*adapterConfig(AdapterConfigName, map[string]interface{}{"paused": true, "pauseReason": "INC-123"}))
+	require.NoError(t, err)
+	assert.Equal(t, &AdapterConfig{Paused: true, PauseReason: "INC-123"}, paused)
+	_, err = adapterConfigFromUnstructured(*adapterConfig(AdapterConfigName, map[string]interface{}{"paused": "yes"}))
+	assert.Error(t, err)
+
+	declared, err := adapterConfigFromUnstructured(*adapterConfig(AdapterConfigName, map[string]interface{}{"declaredNodes": int64(0)}))
+	require.NoError(t, err)
+	require.NotNil(t, declared.DeclaredNodes, "declaring 0 nodes should be told apart from not declaring any")
+	assert.Equal(t, 0, *declared.DeclaredNodes)
+}
@@ -0,0 +1,87 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	apierror "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ExemptionResource is the ClusterLicenseExemption custom resource installed by the chart, declaring a downstream
+// cluster exempt from entitlement counting
+var ExemptionResource = schema.GroupVersionResource{
+	Group:    "csp-adapter.cattle.io",
+	Version:  "v1",
+	Resource: "clusterlicenseexemptions",
+}
+
+// Exemption is a ClusterLicenseExemption
+type Exemption struct {
+	Name      string
+	ClusterID string
+	Reason    string
+	// Expires is when the cluster's nodes are counted again
+	Expires time.Time
+}
+
+// Active returns whether the exemption applies at now
+func (e Exemption) Active(now time.Time) bool {
+	return now.Before(e.Expires)
+}
+
+func (c *Clients) GetExemptions() ([]Exemption, error) {
+	list, err := c.Exemptions.List(context.Background(), metav1.ListOptions{})
+	if apierror.IsNotFound(err) {
+		// helm doesn't install the CRDs of a chart on upgrade, until they're applied no cluster is exempt
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var exemptions []Exemption
+	for _, item := range list.Items {
+		exemption, err := exemptionFromUnstructured(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cluster license exemption %s: %w", item.GetName(), err)
+		}
+		exemptions = append(exemptions, exemption)
+	}
+	return exemptions, nil
+}
+
+// exemptionFromUnstructured reads the spec of a ClusterLicenseExemption. The schema of the CRD requires each field, an
+// exemption missing one was created before the CRD was upgraded and is rejected
+func exemptionFromUnstructured(obj unstructured.Unstructured) (Exemption, error) {
+	exemption := Exemption{Name: obj.GetName()}
+	var err error
+	if exemption.ClusterID, err = specString(obj, "clusterID"); err != nil {
+		return Exemption{}, err
+	}
+	if exemption.Reason, err = specString(obj, "reason"); err != nil {
+		return Exemption{}, err
+	}
+	expires, err := specString(obj, "expires")
+	if err != nil {
+		return Exemption{}, err
+	}
+	if exemption.Expires, err = time.Parse(time.RFC3339, expires); err != nil {
+		return Exemption{}, fmt.Errorf("expires isn't a date-time: %w", err)
+	}
+	return exemption, nil
+}
+
+// specString returns the string field of the spec of obj, an error if it isn't set
+func specString(obj unstructured.Unstructured, field string) (string, error) {
+	value, ok, err := unstructured.NestedString(obj.Object, "spec", field)
+	if err != nil {
+		return "", err
+	}
+	if !ok || value == "" {
+		return "", fmt.Errorf("spec.%s isn't set", field)
+	}
+	return value, nil
+}
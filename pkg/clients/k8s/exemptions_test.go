@@ -0,0 +1,45 @@
+package k8s
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic/fake"
+)
+
+func exemption(name string, spec map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "csp-adapter.cattle.io/v1",
+		"kind":       "ClusterLicenseExemption",
+		"metadata":   map[string]interface{}{"name": name},
+		"spec":       spec,
+	}}
+}
+
+func TestGetExemptions(t *testing.T) {
+	client := fake.NewSimpleDynamicClient(runtime.NewScheme(), exemption("migrating", map[string]interface{}{
+		"clusterID": "c-1",
+		"reason":    "migrating",
+		"expires":   "2022-03-31T00:00:00Z",
+	}))
+	clients := &Clients{Exemptions: client.Resource(ExemptionResource)}
+	exemptions, err := clients.GetExemptions()
+	require.NoError(t, err)
+	expires := time.Date(2022, 3, 31, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, []Exemption{{Name: "migrating", ClusterID: "c-1", Reason: "migrating", Expires: expires}}, exemptions)
+	assert.True(t, exemptions[0].Active(expires.Add(-time.Second)))
+	assert.False(t, exemptions[0].Active(expires), "exemptions expire at their expiry")
+
+	for name, spec := range map[string]map[string]interface{}{
+		"no reason":    {"clusterID": "c-1", "expires": "2022-03-31T00:00:00Z"},
+		"no cluster":   {"reason": "migrating", "expires": "2022-03-31T00:00:00Z"},
+		"invalid date": {"clusterID": "c-1", "reason": "migrating", "expires": "end of march"},
+	} {
+		_, err := exemptionFromUnstructured(*exemption("invalid", spec))
+		assert.Error(t, err, name)
+	}
+}
@@ -0,0 +1,67 @@
+package k8s
+
+import (
+	"context"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// localClusterID is the id of the cluster rancher is installed in, whose nodes aren't counted
+const localClusterID = "local"
+
+// ListClusterIDs returns the ids of all downstream clusters managed by rancher
+func (c *Clients) ListClusterIDs(ctx context.Context) ([]string, error) {
+	list, err := c.Clusters.List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, cluster := range list.Items {
+		if cluster.Name != localClusterID {
+			ids = append(ids, cluster.Name)
+		}
+	}
+	return ids, nil
+}
+
+// CountNodes returns the number of nodes rancher manages for the cluster with the given id. Rancher keeps a node
+// object for every node of a downstream cluster in the cluster's namespace
+func (c *Clients) CountNodes(ctx context.Context, clusterID string) (int, error) {
+	type result struct {
+		nodes int
+		err   error
+	}
+	// the generated clients don't accept a context, the call is abandoned instead once ctx is done
+	done := make(chan result, 1)
+	go func() {
+		list, err := c.Nodes.List(clusterID, metav1.ListOptions{})
+		if err != nil {
+			done <- result{err: err}
+			return
+		}
+		done <- result{nodes: len(list.Items)}
+	}()
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	case r := <-done:
+		return r.nodes, r.err
+	}
+}
+
+// GetNodeCreationTimes returns when each node of the downstream clusters was registered with rancher. Rancher doesn't
+// keep removed nodes, so only the nodes which still exist are included
+func (c *Clients) GetNodeCreationTimes() ([]time.Time, error) {
+	list, err := c.Nodes.List(metav1.NamespaceAll, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var created []time.Time
+	for _, node := range list.Items {
+		if node.Namespace != localClusterID {
+			created = append(created, node.CreationTimestamp.Time)
+		}
+	}
+	return created, nil
+}
@@ -0,0 +1,105 @@
+package k8s
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/watch"
+	appsclient "k8s.io/client-go/kubernetes/typed/apps/v1"
+)
+
+const (
+	// ForceReconcileAnnotation on the adapter's deployment triggers an immediate compliance check each time its value
+	// changes (i.e. set to the current timestamp by a GitOps pipeline after buying entitlements)
+	ForceReconcileAnnotation = "cattle.io/force-reconcile"
+	// PauseAnnotation on the adapter's deployment pauses checkout adjustments while it's set, its value is reported as
+	// the reason. Removing it resumes them
+	PauseAnnotation   = "cattle.io/pause-adjustments"
+	deploymentNameEnv = "K8S_DEPLOYMENT_NAME"
+	// watchRetryInterval is waited before re-establishing a watch which failed
+	watchRetryInterval = 5 * time.Second
+)
+
+// WatchForceReconcile calls trigger each time the ForceReconcileAnnotation of the adapter's deployment changes, until
+// ctx is cancelled. The value found when the watch starts doesn't trigger, since the adapter checks compliance on
+// startup anyway. Does nothing if the deployment's name isn't configured
+func (c *Clients) WatchForceReconcile(ctx context.Context, trigger func(value string)) {
+	name := os.Getenv(deploymentNameEnv)
+	if name == "" {
+		logrus.Debugf("%s is not set, not watching for %s", deploymentNameEnv, ForceReconcileAnnotation)
+		return
+	}
+	watchForceReconcile(ctx, c.Deployments, name, trigger)
+}
+
+// WatchPause calls pause with the value of the PauseAnnotation of the adapter's deployment when it's found set,
+// including when the watch starts, and resume when it's removed, until ctx is cancelled. Does nothing if the
+// deployment's name isn't configured
+func (c *Clients) WatchPause(ctx context.Context, pause func(reason string), resume func()) {
+	name := os.Getenv(deploymentNameEnv)
+	if name == "" {
+		logrus.Debugf("%s is not set, not watching for %s", deploymentNameEnv, PauseAnnotation)
+		return
+	}
+	watchPause(ctx, c.Deployments, name, pause, resume)
+}
+
+func watchForceReconcile(ctx context.Context, deployments appsclient.DeploymentInterface, name string, trigger func(value string)) {
+	watchAnnotation(ctx, deployments, name, ForceReconcileAnnotation, func(value string, initial bool) {
+		if !initial && value != "" {
+			logrus.Infof("%s changed to %q, triggering compliance check", ForceReconcileAnnotation, value)
+			trigger(value)
+		}
+	})
+}
+
+func watchPause(ctx context.Context, deployments appsclient.DeploymentInterface, name string, pause func(reason string), resume func()) {
+	watchAnnotation(ctx, deployments, name, PauseAnnotation, func(value string, initial bool) {
+		switch {
+		case value != "":
+			pause(value)
+		case !initial:
+			resume()
+		}
+	})
+}
+
+// watchAnnotation calls changed with the value of annotation on the named deployment when the watch first sees it, with
+// initial set, and each time the value changes afterwards, until ctx is cancelled. A removed annotation has an empty
+// value
+func watchAnnotation(ctx context.Context, deployments appsclient.DeploymentInterface, name, annotation string, changed func(value string, initial bool)) {
+	var last *string
+	for ctx.Err() == nil {
+		w, err := deployments.Watch(ctx, metav1.ListOptions{
+			FieldSelector: fields.OneTermEqualSelector("metadata.name", name).String(),
+		})
+		if err != nil {
+			logrus.Warnf("unable to watch deployment %s for %s: %v", name, annotation, err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(watchRetryInterval):
+			}
+			continue
+		}
+		for event := range w.ResultChan() {
+			if event.Type != watch.Added && event.Type != watch.Modified {
+				continue
+			}
+			deployment, ok := event.Object.(*appsv1.Deployment)
+			if !ok {
+				continue
+			}
+			value := deployment.Annotations[annotation]
+			if last == nil || *last != value {
+				changed(value, last == nil)
+			}
+			last = &value
+		}
+		w.Stop()
+	}
+}
@@ -0,0 +1,107 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func deploymentWithAnnotation(value string) *appsv1.Deployment {
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "rancher-csp-adapter",
+			Namespace: cspAdapterNamespace,
+		},
+	}
+	if value != "" {
+		deployment.Annotations = map[string]string{ForceReconcileAnnotation: value}
+	}
+	return deployment
+}
+
+func TestWatchForceReconcile(t *testing.T) {
+	fakeWatcher := watch.NewFake()
+	clientset := fake.NewSimpleClientset()
+	clientset.PrependWatchReactor("deployments", k8stesting.DefaultWatchReactor(fakeWatcher, nil))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	triggered := make(chan string, 10)
+	go watchForceReconcile(ctx, clientset.AppsV1().Deployments(cspAdapterNamespace), "rancher-csp-adapter", func(value string) {
+		triggered <- value
+	})
+
+	// the value present when the watch starts is only recorded
+	fakeWatcher.Add(deploymentWithAnnotation("2022-01-01T00:00:00Z"))
+	// changes to other fields don't trigger
+	fakeWatcher.Modify(deploymentWithAnnotation("2022-01-01T00:00:00Z"))
+	fakeWatcher.Modify(deploymentWithAnnotation("2022-01-02T00:00:00Z"))
+	// removing the annotation doesn't trigger
+	fakeWatcher.Modify(deploymentWithAnnotation(""))
+	fakeWatcher.Modify(deploymentWithAnnotation("2022-01-03T00:00:00Z"))
+
+	for _, expected := range []string{"2022-01-02T00:00:00Z", "2022-01-03T00:00:00Z"} {
+		select {
+		case value := <-triggered:
+			assert.Equal(t, expected, value)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected trigger for %s", expected)
+		}
+	}
+	select {
+	case value := <-triggered:
+		t.Fatalf("unexpected trigger for %s", value)
+	default:
+	}
+}
+
+func TestWatchPause(t *testing.T) {
+	fakeWatcher := watch.NewFake()
+	clientset := fake.NewSimpleClientset()
+	clientset.PrependWatchReactor("deployments", k8stesting.DefaultWatchReactor(fakeWatcher, nil))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changes := make(chan string, 10)
+	go watchPause(ctx, clientset.AppsV1().Deployments(cspAdapterNamespace), "rancher-csp-adapter", func(reason string) {
+		changes <- "pause " + reason
+	}, func() {
+		changes <- "resume"
+	})
+
+	paused := func(reason string) *appsv1.Deployment {
+		deployment := deploymentWithAnnotation("")
+		if reason != "" {
+			deployment.Annotations = map[string]string{PauseAnnotation: reason}
+		}
+		return deployment
+	}
+	// an annotation present when the watch starts pauses
+	fakeWatcher.Add(paused("incident 1"))
+	fakeWatcher.Modify(paused("incident 1"))
+	fakeWatcher.Modify(paused(""))
+	// other annotations don't pause or resume
+	fakeWatcher.Modify(deploymentWithAnnotation("2022-01-01T00:00:00Z"))
+	fakeWatcher.Modify(paused("incident 2"))
+
+	for _, expected := range []string{"pause incident 1", "resume", "pause incident 2"} {
+		select {
+		case change := <-changes:
+			assert.Equal(t, expected, change)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected %s", expected)
+		}
+	}
+	select {
+	case change := <-changes:
+		t.Fatalf("unexpected %s", change)
+	default:
+	}
+}
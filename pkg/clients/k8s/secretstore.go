@@ -0,0 +1,49 @@
+package k8s
+
+import (
+	"fmt"
+
+	"github.com/rancher/wrangler/pkg/clients"
+	v1 "github.com/rancher/wrangler/pkg/generated/controllers/core/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+)
+
+// SecretStore reads and writes the data of a single existing secret, i.e. the cache secret of an older adapter release
+// installed in another namespace or under another name
+type SecretStore struct {
+	secrets   v1.SecretClient
+	namespace string
+	name      string
+}
+
+// NewSecretStore returns a store of the secret name in namespace
+func NewSecretStore(rest *rest.Config, namespace, name string) (*SecretStore, error) {
+	clients, err := clients.NewFromConfig(rest, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &SecretStore{secrets: clients.Core.Secret(), namespace: namespace, name: name}, nil
+}
+
+func (s *SecretStore) String() string {
+	return fmt.Sprintf("secret %s/%s", s.namespace, s.name)
+}
+
+// Get returns the secret
+func (s *SecretStore) Get() (*corev1.Secret, error) {
+	return s.secrets.Get(s.namespace, s.name, metav1.GetOptions{})
+}
+
+// Update writes data to the secret, keeping the keys data doesn't hold
+func (s *SecretStore) Update(data map[string]string) error {
+	secret, err := s.Get()
+	if err != nil {
+		return err
+	}
+	secret = secret.DeepCopy()
+	secret.StringData = data
+	_, err = s.secrets.Update(secret)
+	return err
+}
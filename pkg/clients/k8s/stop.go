@@ -0,0 +1,43 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	appsclient "k8s.io/client-go/kubernetes/typed/apps/v1"
+)
+
+// stopPollInterval is waited between checks whether the pods of a stopped deployment are gone
+var stopPollInterval = 2 * time.Second
+
+// StopDeployment scales the adapter's deployment name to 0 replicas and waits until its pods are gone, so that no
+// compliance check runs while its state is changed from outside of the adapter. Gives up once ctx is done
+func (c *Clients) StopDeployment(ctx context.Context, name string) error {
+	return stopDeployment(ctx, c.Deployments, name)
+}
+
+func stopDeployment(ctx context.Context, deployments appsclient.DeploymentInterface, name string) error {
+	_, err := deployments.Patch(ctx, name, types.MergePatchType, []byte(`{"spec":{"replicas":0}}`), metav1.PatchOptions{})
+	if err != nil {
+		return fmt.Errorf("unable to scale deployment %s to 0 replicas: %w", name, err)
+	}
+	logrus.Infof("scaled deployment %s to 0 replicas, waiting for its pods to stop", name)
+	for {
+		deployment, err := deployments.Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("unable to get deployment %s: %w", name, err)
+		}
+		if deployment.Status.Replicas == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%d pod(s) of deployment %s are still running: %w", deployment.Status.Replicas, name, ctx.Err())
+		case <-time.After(stopPollInterval):
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package k8s
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestStopDeployment(t *testing.T) {
+	stopPollInterval = 10 * time.Millisecond
+	replicas := int32(1)
+	clientset := fake.NewSimpleClientset(&appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "rancher-csp-adapter", Namespace: cspAdapterNamespace},
+		Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+		Status:     appsv1.DeploymentStatus{Replicas: 1},
+	})
+	deployments := clientset.AppsV1().Deployments(cspAdapterNamespace)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.Error(t, stopDeployment(ctx, deployments, "rancher-csp-adapter"), "the pod is still running")
+	deployment, err := deployments.Get(context.Background(), "rancher-csp-adapter", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, int32(0), *deployment.Spec.Replicas)
+
+	deployment.Status.Replicas = 0
+	_, err = deployments.UpdateStatus(context.Background(), deployment, metav1.UpdateOptions{})
+	require.NoError(t, err)
+	assert.NoError(t, stopDeployment(context.Background(), deployments, "rancher-csp-adapter"))
+	assert.Error(t, stopDeployment(context.Background(), deployments, "missing"))
+}
@@ -0,0 +1,57 @@
+package k8s
+
+import (
+	"encoding/json"
+	"reflect"
+
+	"github.com/rancher/csp-adapter/pkg/metrics"
+	"github.com/sirupsen/logrus"
+)
+
+// The adapter rewrites its outputs on every compliance check, which runs every 30 seconds by default. Writes of
+// content an object already holds are skipped, so that they don't churn etcd or wake up everything watching the object
+
+// sameData returns whether current holds the same values as desired
+func sameData(current, desired map[string]string) bool {
+	if len(current) != len(desired) {
+		return false
+	}
+	for key, value := range desired {
+		currentValue, ok := current[key]
+		if !ok || !sameValue(currentValue, value) {
+			return false
+		}
+	}
+	return true
+}
+
+// sameSecretData returns whether current holds the same values as desired. Only the keys of desired are compared,
+// since updating the secret's string data keeps its other keys
+func sameSecretData(current map[string][]byte, desired map[string]string) bool {
+	for key, value := range desired {
+		currentValue, ok := current[key]
+		if !ok || string(currentValue) != value {
+			return false
+		}
+	}
+	return true
+}
+
+// sameValue returns whether current and desired are the same. Json values are compared by their content, so that
+// values written with a different formatting or order of keys, i.e. by an older version, aren't rewritten
+func sameValue(current, desired string) bool {
+	if current == desired {
+		return true
+	}
+	var currentJSON, desiredJSON interface{}
+	if json.Unmarshal([]byte(current), &currentJSON) != nil || json.Unmarshal([]byte(desired), &desiredJSON) != nil {
+		return false
+	}
+	return reflect.DeepEqual(currentJSON, desiredJSON)
+}
+
+// skipUnchanged records that the write of object was skipped since it already held the content
+func skipUnchanged(object string) {
+	logrus.Tracef("[k8s] %s is unchanged, not writing it", object)
+	metrics.UnchangedWrites.Inc()
+}
@@ -0,0 +1,42 @@
+package k8s
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSameValue(t *testing.T) {
+	tests := []struct {
+		name     string
+		current  string
+		desired  string
+		expected bool
+	}{
+		{name: "equal", current: "message", desired: "message", expected: true},
+		{name: "different", current: "message", desired: "other message"},
+		{name: "json with reordered keys", current: `{"status":"Compliant","message":"ok"}`, desired: `{"message":"ok","status":"Compliant"}`, expected: true},
+		{name: "json with different formatting", current: "{\n  \"nodes\": 3\n}", desired: `{"nodes":3}`, expected: true},
+		{name: "json with different content", current: `{"nodes":3}`, desired: `{"nodes":4}`},
+		{name: "json and non-json", current: `{"nodes":3}`, desired: "nodes: 3"},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, sameValue(test.current, test.desired))
+		})
+	}
+}
+
+func TestSameData(t *testing.T) {
+	assert.True(t, sameData(map[string]string{"data": `{"a":1,"b":2}`}, map[string]string{"data": `{"b":2,"a":1}`}))
+	assert.False(t, sameData(map[string]string{"data": "a"}, map[string]string{"data": "a", "other": "b"}))
+	assert.False(t, sameData(map[string]string{"data": "a", "other": "b"}, map[string]string{"data": "a"}))
+	assert.False(t, sameData(nil, map[string]string{"data": "a"}))
+
+	// keys which aren't written are kept by the secret, so they don't count as a change
+	current := map[string][]byte{"consumptionToken": []byte("token"), "pendingCheckout": []byte("id")}
+	assert.True(t, sameSecretData(current, map[string]string{"consumptionToken": "token"}))
+	assert.False(t, sameSecretData(current, map[string]string{"consumptionToken": "other"}))
+	assert.False(t, sameSecretData(current, map[string]string{"expiry": ""}))
+}
@@ -0,0 +1,98 @@
+package k8s
+
+import (
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// systemPrincipalPrefix prefixes the principals of users which rancher creates for itself, i.e. for cluster agents
+	systemPrincipalPrefix = "system://"
+	// usernameAttribute is the key of a user's username in the attributes rancher stores per auth provider
+	usernameAttribute = "username"
+	// localAuthProvider is the auth config of rancher's own users, which is always enabled
+	localAuthProvider = "local"
+)
+
+// RancherUser is a person who can log into rancher
+type RancherUser struct {
+	ID string
+	// Username is set for local users
+	Username string
+	Enabled  bool
+	// ProviderUsernames are the usernames of the user in external auth providers (i.e. activedirectory), by provider
+	ProviderUsernames map[string]string
+	// Groups are the principal ids of the groups the user was a member of at their last login or refresh, across auth
+	// providers (i.e. activedirectory_group://CN=admins,DC=example,DC=com)
+	Groups []string
+}
+
+func (c *Clients) GetUsers() ([]RancherUser, error) {
+	users, err := c.Users.List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	attributes, err := c.UserAttributes.List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	usernames := map[string]map[string]string{}
+	groups := map[string][]string{}
+	for _, attribute := range attributes.Items {
+		for _, principals := range attribute.GroupPrincipals {
+			for _, principal := range principals.Items {
+				groups[attribute.Name] = append(groups[attribute.Name], principal.Name)
+			}
+		}
+		for provider, extra := range attribute.ExtraByProvider {
+			if len(extra[usernameAttribute]) == 0 {
+				continue
+			}
+			if usernames[attribute.Name] == nil {
+				usernames[attribute.Name] = map[string]string{}
+			}
+			usernames[attribute.Name][provider] = extra[usernameAttribute][0]
+		}
+	}
+	var rancherUsers []RancherUser
+	for _, user := range users.Items {
+		if isSystemUser(user.PrincipalIDs) {
+			continue
+		}
+		rancherUsers = append(rancherUsers, RancherUser{
+			ID:                user.Name,
+			Username:          user.Username,
+			Enabled:           user.Enabled == nil || *user.Enabled,
+			ProviderUsernames: usernames[user.Name],
+			Groups:            groups[user.Name],
+		})
+	}
+	return rancherUsers, nil
+}
+
+func (c *Clients) GetEnabledAuthProviders() ([]string, error) {
+	configs, err := c.AuthConfigs.List(metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var providers []string
+	for _, config := range configs.Items {
+		if config.Enabled && config.Name != localAuthProvider {
+			providers = append(providers, config.Name)
+		}
+	}
+	sort.Strings(providers)
+	return providers, nil
+}
+
+// isSystemUser returns whether a user with principals was created by rancher rather than a person
+func isSystemUser(principals []string) bool {
+	for _, principal := range principals {
+		if strings.HasPrefix(principal, systemPrincipalPrefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,266 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+
+	lm "github.com/aws/aws-sdk-go-v2/service/licensemanager"
+	"github.com/aws/aws-sdk-go-v2/service/licensemanager/types"
+	"github.com/hashicorp/go-hclog"
+	goplugin "github.com/hashicorp/go-plugin"
+	"github.com/rancher/csp-adapter/pkg/clients/aws"
+	"github.com/rancher/csp-adapter/pkg/clients/plugin/proto"
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/sirupsen/logrus"
+)
+
+// client is an aws.Client calling a plugin
+type client struct {
+	provider proto.ProviderClient
+
+	acctLock      sync.RWMutex
+	accountNumber string
+	accountAlias  string
+}
+
+// NewClient starts the plugin binary at path and returns a client calling it. The plugin is stopped when ctx is
+// cancelled, calls fail once it exited
+func NewClient(ctx context.Context, path string) (aws.Client, error) {
+	logs := pluginLog(path)
+	pluginClient := goplugin.NewClient(&goplugin.ClientConfig{
+		HandshakeConfig:  Handshake,
+		Plugins:          plugins(nil),
+		Cmd:              exec.Command(path),
+		AllowedProtocols: []goplugin.Protocol{goplugin.ProtocolGRPC},
+		Stderr:           logs,
+		SyncStderr:       logs,
+		Logger: hclog.New(&hclog.LoggerOptions{
+			Name:   "plugin",
+			Level:  hclog.Warn,
+			Output: logrus.StandardLogger().WriterLevel(logrus.WarnLevel),
+		}),
+	})
+	conn, err := pluginClient.Client()
+	if err != nil {
+		pluginClient.Kill()
+		return nil, fmt.Errorf("unable to start plugin %s: %w", path, err)
+	}
+	provider, err := conn.Dispense(pluginName)
+	if err != nil {
+		pluginClient.Kill()
+		return nil, fmt.Errorf("plugin %s: %w", path, err)
+	}
+	go func() {
+		<-ctx.Done()
+		pluginClient.Kill()
+	}()
+	c := provider.(*client)
+	logrus.Infof("[plugin] started %s for account %s", path, c.accountNumber)
+	return c, nil
+}
+
+// newClient returns a client calling provider, after reading the account it issues calls to
+func newClient(ctx context.Context, provider proto.ProviderClient) (*client, error) {
+	account, err := provider.Account(ctx, &proto.Empty{})
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the plugin's account: %w", err)
+	}
+	return &client{provider: provider, accountNumber: account.Number, accountAlias: account.Alias}, nil
+}
+
+// pluginLog logs every line the plugin at path writes to stderr
+type pluginLog string
+
+func (p pluginLog) Write(b []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimRight(string(b), "\n"), "\n") {
+		if line != "" {
+			logrus.Infof("[plugin] %s: %s", string(p), line)
+		}
+	}
+	return len(b), nil
+}
+
+// callError returns the error of a call which failed before the plugin could answer. Calls which gave up because ctx
+// is done return the error of ctx
+func callError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return fmt.Errorf("unable to call plugin: %w", err)
+}
+
+func (c *client) AccountNumber() string {
+	c.acctLock.RLock()
+	defer c.acctLock.RUnlock()
+	return c.accountNumber
+}
+
+func (c *client) AccountAlias() string {
+	c.acctLock.RLock()
+	defer c.acctLock.RUnlock()
+	return c.accountAlias
+}
+
+func (c *client) RefreshAccountNumber(ctx context.Context) (string, error) {
+	resp, err := c.provider.RefreshAccount(ctx, &proto.Empty{})
+	if err != nil {
+		return "", callError(ctx, err)
+	}
+	if err := errorFrom(resp.Error); err != nil {
+		return "", err
+	}
+	c.acctLock.Lock()
+	defer c.acctLock.Unlock()
+	c.accountNumber, c.accountAlias = resp.Number, resp.Alias
+	return resp.Number, nil
+}
+
+func (c *client) GetRancherLicense(ctx context.Context) (*types.GrantedLicense, error) {
+	resp, err := c.provider.GetRancherLicense(ctx, &proto.Empty{})
+	if err != nil {
+		return nil, callError(ctx, err)
+	}
+	var license *types.GrantedLicense
+	if err := decode(resp.License, &license); err != nil {
+		return nil, err
+	}
+	return license, errorFrom(resp.Error)
+}
+
+func (c *client) CheckoutRancherLicense(ctx context.Context, l types.GrantedLicense, entitlementAmt int) (*lm.CheckoutLicenseOutput, error) {
+	license, err := json.Marshal(l)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.provider.CheckoutRancherLicense(ctx, &proto.CheckoutRequest{
+		License:        license,
+		EntitlementAmt: int64(entitlementAmt),
+		CheckoutToken:  aws.CheckoutTokenFromContext(ctx),
+	})
+	if err != nil {
+		return nil, callError(ctx, err)
+	}
+	var output *lm.CheckoutLicenseOutput
+	if err := decode(resp.Output, &output); err != nil {
+		return nil, err
+	}
+	return output, errorFrom(resp.Error)
+}
+
+func (c *client) CheckInRancherLicense(ctx context.Context, consumptionToken string) (*lm.CheckInLicenseOutput, error) {
+	resp, err := c.provider.CheckInRancherLicense(ctx, &proto.TokenRequest{ConsumptionToken: consumptionToken})
+	if err != nil {
+		return nil, callError(ctx, err)
+	}
+	var output *lm.CheckInLicenseOutput
+	if err := decode(resp.Output, &output); err != nil {
+		return nil, err
+	}
+	return output, errorFrom(resp.Error)
+}
+
+func (c *client) CheckInRancherLicenses(ctx context.Context, tokens []string) map[string]error {
+	return aws.CheckInConcurrently(ctx, c, tokens, aws.DefaultCheckInParallelism)
+}
+
+func (c *client) ExtendRancherLicenseConsumptionToken(ctx context.Context, consumptionToken string) (*lm.ExtendLicenseConsumptionOutput, error) {
+	resp, err := c.provider.ExtendRancherLicenseConsumptionToken(ctx, &proto.TokenRequest{ConsumptionToken: consumptionToken})
+	if err != nil {
+		return nil, callError(ctx, err)
+	}
+	var output *lm.ExtendLicenseConsumptionOutput
+	if err := decode(resp.Output, &output); err != nil {
+		return nil, err
+	}
+	return output, errorFrom(resp.Error)
+}
+
+// licenseRequest returns the request of a call about license
+func licenseRequest(license types.GrantedLicense) (*proto.LicenseRequest, error) {
+	data, err := json.Marshal(license)
+	if err != nil {
+		return nil, err
+	}
+	return &proto.LicenseRequest{License: data}, nil
+}
+
+func (c *client) GetNumberOfAvailableEntitlements(ctx context.Context, license types.GrantedLicense) (int, error) {
+	req, err := licenseRequest(license)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := c.provider.GetNumberOfAvailableEntitlements(ctx, req)
+	if err != nil {
+		return 0, callError(ctx, err)
+	}
+	return int(resp.Count), errorFrom(resp.Error)
+}
+
+func (c *client) GetEntitlementUsage(ctx context.Context, license types.GrantedLicense) (aws.EntitlementUsage, error) {
+	req, err := licenseRequest(license)
+	if err != nil {
+		return aws.EntitlementUsage{}, err
+	}
+	resp, err := c.provider.GetEntitlementUsage(ctx, req)
+	if err != nil {
+		return aws.EntitlementUsage{}, callError(ctx, err)
+	}
+	usage := aws.EntitlementUsage{Max: int(resp.Max), Consumed: int(resp.Consumed), Dimension: resp.Dimension}
+	for _, dimension := range resp.Dimensions {
+		usage.Dimensions = append(usage.Dimensions, aws.DimensionUsage{Name: dimension.Name, Max: dimension.Max, Consumed: dimension.Consumed})
+	}
+	return usage, errorFrom(resp.Error)
+}
+
+func (c *client) CheckServiceHealth(ctx context.Context) error {
+	resp, err := c.provider.CheckServiceHealth(ctx, &proto.Empty{})
+	if err != nil {
+		return callError(ctx, err)
+	}
+	return errorFrom(resp.Error)
+}
+
+func (c *client) ListProducts(ctx context.Context) ([]sdk.Product, error) {
+	resp, err := c.provider.ListProducts(ctx, &proto.Empty{})
+	if err != nil {
+		return nil, callError(ctx, err)
+	}
+	var products []sdk.Product
+	for _, msg := range resp.Products {
+		product := sdk.Product{
+			Name:       msg.Name,
+			SKU:        msg.Sku,
+			Found:      msg.Found,
+			Active:     msg.Active,
+			Matched:    msg.Matched,
+			LicenseArn: msg.LicenseArn,
+			Status:     msg.Status,
+		}
+		for _, dimension := range msg.Dimensions {
+			product.Dimensions = append(product.Dimensions, sdk.ProductDimension{
+				Name:     dimension.Name,
+				Unit:     dimension.Unit,
+				MaxCount: dimension.MaxCount,
+			})
+		}
+		products = append(products, product)
+	}
+	return products, errorFrom(resp.Error)
+}
+
+func (c *client) ValidateLicense(l types.GrantedLicense) error {
+	ctx := context.Background()
+	req, err := licenseRequest(l)
+	if err != nil {
+		return err
+	}
+	resp, err := c.provider.ValidateLicense(ctx, req)
+	if err != nil {
+		return callError(ctx, err)
+	}
+	return errorFrom(resp.Error)
+}
@@ -0,0 +1,137 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/licensemanager/types"
+	goplugin "github.com/hashicorp/go-plugin"
+	"github.com/rancher/csp-adapter/pkg/clients/aws"
+	"github.com/rancher/csp-adapter/pkg/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testPluginEnv makes the test binary serve a synthetic client as a plugin built against the protocol version it's set to
+const testPluginEnv = "CSP_ADAPTER_TEST_PLUGIN"
+
+func TestMain(m *testing.M) {
+	if version := os.Getenv(testPluginEnv); version != "" {
+		handshake := Handshake
+		protocolVersion, _ := strconv.Atoi(version)
+		handshake.ProtocolVersion = uint(protocolVersion)
+		serve(handshake, aws.NewSyntheticClient(5))
+		return
+	}
+	os.Exit(m.Run())
+}
+
+// connect returns a client calling provider as a plugin would serve it
+func connect(t *testing.T, provider aws.Client) *client {
+	conn, server := goplugin.TestPluginGRPCConn(t, plugins(provider))
+	t.Cleanup(func() {
+		conn.Close()
+		server.Stop()
+	})
+	c, err := conn.Dispense(pluginName)
+	require.NoError(t, err)
+	return c.(*client)
+}
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	provider := aws.NewSyntheticClient(5)
+	c := connect(t, provider)
+
+	assert.Equal(t, provider.AccountNumber(), c.AccountNumber(), "account number should be exchanged in the handshake")
+	number, err := c.RefreshAccountNumber(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, provider.AccountNumber(), number)
+
+	license, err := c.GetRancherLicense(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), *license.Entitlements[0].MaxCount)
+
+	checkout, err := c.CheckoutRancherLicense(aws.WithCheckoutToken(ctx, "token"), *license, 3)
+	require.NoError(t, err)
+	repeated, err := c.CheckoutRancherLicense(aws.WithCheckoutToken(ctx, "token"), *license, 3)
+	require.NoError(t, err, "a repeated checkout should be recognized by its checkout token")
+	assert.Equal(t, *checkout.LicenseConsumptionToken, *repeated.LicenseConsumptionToken)
+
+	usage, err := c.GetEntitlementUsage(ctx, *license)
+	require.NoError(t, err)
+	assert.Equal(t, aws.EntitlementUsage{Max: 5, Consumed: 3}, usage)
+
+	_, err = c.CheckoutRancherLicense(ctx, *license, 3)
+	assert.EqualError(t, err, "unable to checkout 3 entitlements, only 2 available", "errors should keep their message")
+
+	_, err = c.ExtendRancherLicenseConsumptionToken(ctx, *checkout.LicenseConsumptionToken)
+	assert.NoError(t, err)
+	_, err = c.CheckInRancherLicense(ctx, *checkout.LicenseConsumptionToken)
+	assert.NoError(t, err)
+	available, err := c.GetNumberOfAvailableEntitlements(ctx, *license)
+	require.NoError(t, err)
+	assert.Equal(t, 5, available)
+
+	products, err := c.ListProducts(ctx)
+	require.NoError(t, err)
+	expected, _ := provider.ListProducts(ctx)
+	assert.Equal(t, expected, products)
+	assert.NoError(t, c.CheckServiceHealth(ctx))
+}
+
+func TestClientErrorTypes(t *testing.T) {
+	c := connect(t, aws.NewSyntheticClient(5))
+	license, err := c.GetRancherLicense(context.Background())
+	require.NoError(t, err)
+	assert.NoError(t, c.ValidateLicense(*license))
+
+	license.Status = types.LicenseStatusSuspended
+	var statusErr *aws.LicenseStatusError
+	require.True(t, errors.As(c.ValidateLicense(*license), &statusErr), "license status errors should keep their type")
+	assert.Equal(t, types.LicenseStatusSuspended, statusErr.Status)
+}
+
+func TestClientContext(t *testing.T) {
+	provider := &mocks.AWSClientMock{
+		AccountNumberFunc: func() string { return "" },
+		AccountAliasFunc:  func() string { return "" },
+		CheckServiceHealthFunc: func(ctx context.Context) error {
+			if _, ok := ctx.Deadline(); !ok {
+				return errors.New("no deadline")
+			}
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	}
+	c := connect(t, provider)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, c.CheckServiceHealth(ctx), context.DeadlineExceeded, "calls should give up once their context is done")
+	// the plugin should give up as well, so the connection is free for the next call
+	assert.EqualError(t, c.CheckServiceHealth(context.Background()), "no deadline")
+}
+
+func TestNewClient(t *testing.T) {
+	t.Setenv(testPluginEnv, strconv.Itoa(ProtocolVersion))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c, err := NewClient(ctx, os.Args[0])
+	require.NoError(t, err)
+	assert.Equal(t, aws.NewSyntheticClient(5).AccountNumber(), c.AccountNumber())
+	assert.NoError(t, c.CheckServiceHealth(ctx))
+}
+
+func TestClientProtocolVersion(t *testing.T) {
+	t.Setenv(testPluginEnv, strconv.Itoa(ProtocolVersion+1))
+
+	_, err := NewClient(context.Background(), os.Args[0])
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Incompatible API version with plugin", "plugins built against another protocol version should be refused")
+}
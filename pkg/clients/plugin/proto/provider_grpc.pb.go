@@ -0,0 +1,479 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: provider.proto
+
+package proto
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Provider_Account_FullMethodName                              = "/csp_adapter.plugin.Provider/Account"
+	Provider_RefreshAccount_FullMethodName                       = "/csp_adapter.plugin.Provider/RefreshAccount"
+	Provider_GetRancherLicense_FullMethodName                    = "/csp_adapter.plugin.Provider/GetRancherLicense"
+	Provider_CheckoutRancherLicense_FullMethodName               = "/csp_adapter.plugin.Provider/CheckoutRancherLicense"
+	Provider_CheckInRancherLicense_FullMethodName                = "/csp_adapter.plugin.Provider/CheckInRancherLicense"
+	Provider_ExtendRancherLicenseConsumptionToken_FullMethodName = "/csp_adapter.plugin.Provider/ExtendRancherLicenseConsumptionToken"
+	Provider_GetNumberOfAvailableEntitlements_FullMethodName     = "/csp_adapter.plugin.Provider/GetNumberOfAvailableEntitlements"
+	Provider_GetEntitlementUsage_FullMethodName                  = "/csp_adapter.plugin.Provider/GetEntitlementUsage"
+	Provider_CheckServiceHealth_FullMethodName                   = "/csp_adapter.plugin.Provider/CheckServiceHealth"
+	Provider_ListProducts_FullMethodName                         = "/csp_adapter.plugin.Provider/ListProducts"
+	Provider_ValidateLicense_FullMethodName                      = "/csp_adapter.plugin.Provider/ValidateLicense"
+)
+
+// ProviderClient is the client API for Provider service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ProviderClient interface {
+	Account(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*AccountResponse, error)
+	RefreshAccount(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*AccountResponse, error)
+	GetRancherLicense(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*LicenseResponse, error)
+	CheckoutRancherLicense(ctx context.Context, in *CheckoutRequest, opts ...grpc.CallOption) (*OutputResponse, error)
+	CheckInRancherLicense(ctx context.Context, in *TokenRequest, opts ...grpc.CallOption) (*OutputResponse, error)
+	ExtendRancherLicenseConsumptionToken(ctx context.Context, in *TokenRequest, opts ...grpc.CallOption) (*OutputResponse, error)
+	GetNumberOfAvailableEntitlements(ctx context.Context, in *LicenseRequest, opts ...grpc.CallOption) (*CountResponse, error)
+	GetEntitlementUsage(ctx context.Context, in *LicenseRequest, opts ...grpc.CallOption) (*UsageResponse, error)
+	CheckServiceHealth(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*ErrorResponse, error)
+	ListProducts(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*ProductsResponse, error)
+	ValidateLicense(ctx context.Context, in *LicenseRequest, opts ...grpc.CallOption) (*ErrorResponse, error)
+}
+
+type providerClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewProviderClient(cc grpc.ClientConnInterface) ProviderClient {
+	return &providerClient{cc}
+}
+
+func (c *providerClient) Account(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*AccountResponse, error) {
+	out := new(AccountResponse)
+	err := c.cc.Invoke(ctx, Provider_Account_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *providerClient) RefreshAccount(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*AccountResponse, error) {
+	out := new(AccountResponse)
+	err := c.cc.Invoke(ctx, Provider_RefreshAccount_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *providerClient) GetRancherLicense(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*LicenseResponse, error) {
+	out := new(LicenseResponse)
+	err := c.cc.Invoke(ctx, Provider_GetRancherLicense_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *providerClient) CheckoutRancherLicense(ctx context.Context, in *CheckoutRequest, opts ...grpc.CallOption) (*OutputResponse, error) {
+	out := new(OutputResponse)
+	err := c.cc.Invoke(ctx, Provider_CheckoutRancherLicense_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *providerClient) CheckInRancherLicense(ctx context.Context, in *TokenRequest, opts ...grpc.CallOption) (*OutputResponse, error) {
+	out := new(OutputResponse)
+	err := c.cc.Invoke(ctx, Provider_CheckInRancherLicense_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *providerClient) ExtendRancherLicenseConsumptionToken(ctx context.Context, in *TokenRequest, opts ...grpc.CallOption) (*OutputResponse, error) {
+	out := new(OutputResponse)
+	err := c.cc.Invoke(ctx, Provider_ExtendRancherLicenseConsumptionToken_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *providerClient) GetNumberOfAvailableEntitlements(ctx context.Context, in *LicenseRequest, opts ...grpc.CallOption) (*CountResponse, error) {
+	out := new(CountResponse)
+	err := c.cc.Invoke(ctx, Provider_GetNumberOfAvailableEntitlements_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *providerClient) GetEntitlementUsage(ctx context.Context, in *LicenseRequest, opts ...grpc.CallOption) (*UsageResponse, error) {
+	out := new(UsageResponse)
+	err := c.cc.Invoke(ctx, Provider_GetEntitlementUsage_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *providerClient) CheckServiceHealth(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*ErrorResponse, error) {
+	out := new(ErrorResponse)
+	err := c.cc.Invoke(ctx, Provider_CheckServiceHealth_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *providerClient) ListProducts(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*ProductsResponse, error) {
+	out := new(ProductsResponse)
+	err := c.cc.Invoke(ctx, Provider_ListProducts_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *providerClient) ValidateLicense(ctx context.Context, in *LicenseRequest, opts ...grpc.CallOption) (*ErrorResponse, error) {
+	out := new(ErrorResponse)
+	err := c.cc.Invoke(ctx, Provider_ValidateLicense_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ProviderServer is the server API for Provider service.
+// All implementations must embed UnimplementedProviderServer
+// for forward compatibility
+type ProviderServer interface {
+	Account(context.Context, *Empty) (*AccountResponse, error)
+	RefreshAccount(context.Context, *Empty) (*AccountResponse, error)
+	GetRancherLicense(context.Context, *Empty) (*LicenseResponse, error)
+	CheckoutRancherLicense(context.Context, *CheckoutRequest) (*OutputResponse, error)
+	CheckInRancherLicense(context.Context, *TokenRequest) (*OutputResponse, error)
+	ExtendRancherLicenseConsumptionToken(context.Context, *TokenRequest) (*OutputResponse, error)
+	GetNumberOfAvailableEntitlements(context.Context, *LicenseRequest) (*CountResponse, error)
+	GetEntitlementUsage(context.Context, *LicenseRequest) (*UsageResponse, error)
+	CheckServiceHealth(context.Context, *Empty) (*ErrorResponse, error)
+	ListProducts(context.Context, *Empty) (*ProductsResponse, error)
+	ValidateLicense(context.Context, *LicenseRequest) (*ErrorResponse, error)
+	mustEmbedUnimplementedProviderServer()
+}
+
+// UnimplementedProviderServer must be embedded to have forward compatible implementations.
+type UnimplementedProviderServer struct {
+}
+
+func (UnimplementedProviderServer) Account(context.Context, *Empty) (*AccountResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Account not implemented")
+}
+func (UnimplementedProviderServer) RefreshAccount(context.Context, *Empty) (*AccountResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RefreshAccount not implemented")
+}
+func (UnimplementedProviderServer) GetRancherLicense(context.Context, *Empty) (*LicenseResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRancherLicense not implemented")
+}
+func (UnimplementedProviderServer) CheckoutRancherLicense(context.Context, *CheckoutRequest) (*OutputResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CheckoutRancherLicense not implemented")
+}
+func (UnimplementedProviderServer) CheckInRancherLicense(context.Context, *TokenRequest) (*OutputResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CheckInRancherLicense not implemented")
+}
+func (UnimplementedProviderServer) ExtendRancherLicenseConsumptionToken(context.Context, *TokenRequest) (*OutputResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ExtendRancherLicenseConsumptionToken not implemented")
+}
+func (UnimplementedProviderServer) GetNumberOfAvailableEntitlements(context.Context, *LicenseRequest) (*CountResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetNumberOfAvailableEntitlements not implemented")
+}
+func (UnimplementedProviderServer) GetEntitlementUsage(context.Context, *LicenseRequest) (*UsageResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetEntitlementUsage not implemented")
+}
+func (UnimplementedProviderServer) CheckServiceHealth(context.Context, *Empty) (*ErrorResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CheckServiceHealth not implemented")
+}
+func (UnimplementedProviderServer) ListProducts(context.Context, *Empty) (*ProductsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListProducts not implemented")
+}
+func (UnimplementedProviderServer) ValidateLicense(context.Context, *LicenseRequest) (*ErrorResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ValidateLicense not implemented")
+}
+func (UnimplementedProviderServer) mustEmbedUnimplementedProviderServer() {}
+
+// UnsafeProviderServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ProviderServer will
+// result in compilation errors.
+type UnsafeProviderServer interface {
+	mustEmbedUnimplementedProviderServer()
+}
+
+func RegisterProviderServer(s grpc.ServiceRegistrar, srv ProviderServer) {
+	s.RegisterService(&Provider_ServiceDesc, srv)
+}
+
+func _Provider_Account_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProviderServer).Account(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Provider_Account_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProviderServer).Account(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Provider_RefreshAccount_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProviderServer).RefreshAccount(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Provider_RefreshAccount_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProviderServer).RefreshAccount(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Provider_GetRancherLicense_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProviderServer).GetRancherLicense(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Provider_GetRancherLicense_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProviderServer).GetRancherLicense(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Provider_CheckoutRancherLicense_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CheckoutRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProviderServer).CheckoutRancherLicense(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Provider_CheckoutRancherLicense_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProviderServer).CheckoutRancherLicense(ctx, req.(*CheckoutRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Provider_CheckInRancherLicense_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TokenRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProviderServer).CheckInRancherLicense(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Provider_CheckInRancherLicense_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProviderServer).CheckInRancherLicense(ctx, req.(*TokenRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Provider_ExtendRancherLicenseConsumptionToken_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TokenRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProviderServer).ExtendRancherLicenseConsumptionToken(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Provider_ExtendRancherLicenseConsumptionToken_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProviderServer).ExtendRancherLicenseConsumptionToken(ctx, req.(*TokenRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Provider_GetNumberOfAvailableEntitlements_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LicenseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProviderServer).GetNumberOfAvailableEntitlements(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Provider_GetNumberOfAvailableEntitlements_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProviderServer).GetNumberOfAvailableEntitlements(ctx, req.(*LicenseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Provider_GetEntitlementUsage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LicenseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProviderServer).GetEntitlementUsage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Provider_GetEntitlementUsage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProviderServer).GetEntitlementUsage(ctx, req.(*LicenseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Provider_CheckServiceHealth_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProviderServer).CheckServiceHealth(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Provider_CheckServiceHealth_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProviderServer).CheckServiceHealth(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Provider_ListProducts_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProviderServer).ListProducts(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Provider_ListProducts_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProviderServer).ListProducts(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Provider_ValidateLicense_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LicenseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ProviderServer).ValidateLicense(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Provider_ValidateLicense_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ProviderServer).ValidateLicense(ctx, req.(*LicenseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Provider_ServiceDesc is the grpc.ServiceDesc for Provider service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Provider_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "csp_adapter.plugin.Provider",
+	HandlerType: (*ProviderServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Account",
+			Handler:    _Provider_Account_Handler,
+		},
+		{
+			MethodName: "RefreshAccount",
+			Handler:    _Provider_RefreshAccount_Handler,
+		},
+		{
+			MethodName: "GetRancherLicense",
+			Handler:    _Provider_GetRancherLicense_Handler,
+		},
+		{
+			MethodName: "CheckoutRancherLicense",
+			Handler:    _Provider_CheckoutRancherLicense_Handler,
+		},
+		{
+			MethodName: "CheckInRancherLicense",
+			Handler:    _Provider_CheckInRancherLicense_Handler,
+		},
+		{
+			MethodName: "ExtendRancherLicenseConsumptionToken",
+			Handler:    _Provider_ExtendRancherLicenseConsumptionToken_Handler,
+		},
+		{
+			MethodName: "GetNumberOfAvailableEntitlements",
+			Handler:    _Provider_GetNumberOfAvailableEntitlements_Handler,
+		},
+		{
+			MethodName: "GetEntitlementUsage",
+			Handler:    _Provider_GetEntitlementUsage_Handler,
+		},
+		{
+			MethodName: "CheckServiceHealth",
+			Handler:    _Provider_CheckServiceHealth_Handler,
+		},
+		{
+			MethodName: "ListProducts",
+			Handler:    _Provider_ListProducts_Handler,
+		},
+		{
+			MethodName: "ValidateLicense",
+			Handler:    _Provider_ValidateLicense_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "provider.proto",
+}
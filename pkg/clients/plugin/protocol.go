@@ -0,0 +1,127 @@
+// Package plugin loads out-of-tree license providers from external plugin binaries, so that marketplace integrations
+// can be added without forking the adapter. A plugin implements aws.Client and calls Serve from its main function; the
+// adapter starts the binary with go-plugin and calls it over gRPC, see proto/provider.proto for the service. Plugins log
+// to stderr, which the adapter forwards to its own log
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/service/licensemanager/types"
+	goplugin "github.com/hashicorp/go-plugin"
+	"github.com/rancher/csp-adapter/pkg/clients/aws"
+	"github.com/rancher/csp-adapter/pkg/clients/plugin/proto"
+	"google.golang.org/grpc"
+)
+
+const (
+	// ProtocolVersion is incremented whenever the Provider service changes incompatibly. Plugins built against another
+	// version are refused
+	ProtocolVersion = 3
+
+	// pluginName is the name the provider is dispensed under
+	pluginName = "provider"
+)
+
+// Handshake is checked by go-plugin when the adapter starts a plugin. The cookie makes a plugin binary run directly
+// explain that it isn't meant to be rather than waiting for the adapter
+var Handshake = goplugin.HandshakeConfig{
+	ProtocolVersion:  ProtocolVersion,
+	MagicCookieKey:   "CSP_ADAPTER_PLUGIN",
+	MagicCookieValue: "b5c1d58f-2f5e-4c4b-9a56-3b1b6a9d2c7e",
+}
+
+// providerPlugin is the go-plugin plugin serving provider in the plugin and returning a client calling it in the adapter
+type providerPlugin struct {
+	goplugin.NetRPCUnsupportedPlugin
+	provider aws.Client
+}
+
+// plugins returns the plugins served by a plugin binary serving provider, provider is nil in the adapter
+func plugins(provider aws.Client) goplugin.PluginSet {
+	return goplugin.PluginSet{pluginName: &providerPlugin{provider: provider}}
+}
+
+func (p *providerPlugin) GRPCServer(_ *goplugin.GRPCBroker, s *grpc.Server) error {
+	proto.RegisterProviderServer(s, &server{provider: p.provider})
+	return nil
+}
+
+func (p *providerPlugin) GRPCClient(ctx context.Context, _ *goplugin.GRPCBroker, conn *grpc.ClientConn) (interface{}, error) {
+	return newClient(ctx, proto.NewProviderClient(conn))
+}
+
+// newError returns the error the provider returned as it is sent to the adapter, nil if the provider returned none
+func newError(err error) *proto.Error {
+	if err == nil {
+		return nil
+	}
+	callErr := &proto.Error{Message: err.Error(), DeadlineExceeded: errors.Is(err, context.DeadlineExceeded)}
+	var entitlementErr *aws.EntitlementError
+	var grantStatusErr *aws.GrantStatusError
+	var licenseStatusErr *aws.LicenseStatusError
+	switch {
+	case errors.As(err, &entitlementErr):
+		callErr.Entitlement = &proto.EntitlementError{
+			LicenseArn: entitlementErr.LicenseArn,
+			Missing:    entitlementErr.Missing,
+			Aliases:    entitlementErr.Aliases,
+		}
+	case errors.As(err, &grantStatusErr):
+		callErr.GrantStatus = &proto.GrantStatusError{
+			LicenseArn:   grantStatusErr.LicenseArn,
+			Status:       string(grantStatusErr.Status),
+			StatusReason: grantStatusErr.StatusReason,
+		}
+	case errors.As(err, &licenseStatusErr):
+		callErr.LicenseStatus = &proto.LicenseStatusError{
+			LicenseArn: licenseStatusErr.LicenseArn,
+			Status:     string(licenseStatusErr.Status),
+		}
+	}
+	return callErr
+}
+
+// errorFrom returns the error the plugin returned, nil if it returned none
+func errorFrom(e *proto.Error) error {
+	switch {
+	case e == nil:
+		return nil
+	case e.Entitlement != nil:
+		return &aws.EntitlementError{LicenseArn: e.Entitlement.LicenseArn, Missing: e.Entitlement.Missing, Aliases: e.Entitlement.Aliases}
+	case e.GrantStatus != nil:
+		return &aws.GrantStatusError{
+			LicenseArn:   e.GrantStatus.LicenseArn,
+			Status:       types.ReceivedStatus(e.GrantStatus.Status),
+			StatusReason: e.GrantStatus.StatusReason,
+		}
+	case e.LicenseStatus != nil:
+		return &aws.LicenseStatusError{LicenseArn: e.LicenseStatus.LicenseArn, Status: types.LicenseStatus(e.LicenseStatus.Status)}
+	case e.DeadlineExceeded:
+		return deadlineError(e.Message)
+	default:
+		return errors.New(e.Message)
+	}
+}
+
+// deadlineError is a call which ran out of time in the plugin, it keeps the plugin's message and matches
+// context.DeadlineExceeded
+type deadlineError string
+
+func (e deadlineError) Error() string {
+	return string(e)
+}
+
+func (e deadlineError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// decode decodes the JSON encoding of a License Manager type into v, leaving v unchanged if data is empty
+func decode(data []byte, v interface{}) error {
+	if len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, v)
+}
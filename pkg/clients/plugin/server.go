@@ -0,0 +1,152 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/aws/aws-sdk-go-v2/service/licensemanager/types"
+	goplugin "github.com/hashicorp/go-plugin"
+	"github.com/rancher/csp-adapter/pkg/clients/aws"
+	"github.com/rancher/csp-adapter/pkg/clients/plugin/proto"
+	"github.com/rancher/csp-adapter/pkg/sdk"
+)
+
+// Serve serves provider to the adapter which started the plugin, until the adapter stops it. Plugins call it from their
+// main function. Exits if the binary wasn't started by the adapter
+func Serve(provider aws.Client) {
+	serve(Handshake, provider)
+}
+
+func serve(handshake goplugin.HandshakeConfig, provider aws.Client) {
+	goplugin.Serve(&goplugin.ServeConfig{
+		HandshakeConfig: handshake,
+		Plugins:         plugins(provider),
+		GRPCServer:      goplugin.DefaultGRPCServer,
+	})
+}
+
+// server serves a provider as the Provider service
+type server struct {
+	proto.UnimplementedProviderServer
+	provider aws.Client
+}
+
+func (s *server) Account(context.Context, *proto.Empty) (*proto.AccountResponse, error) {
+	return &proto.AccountResponse{Number: s.provider.AccountNumber(), Alias: s.provider.AccountAlias()}, nil
+}
+
+func (s *server) RefreshAccount(ctx context.Context, _ *proto.Empty) (*proto.AccountResponse, error) {
+	number, err := s.provider.RefreshAccountNumber(ctx)
+	if err != nil {
+		return &proto.AccountResponse{Error: newError(err)}, nil
+	}
+	return &proto.AccountResponse{Number: number, Alias: s.provider.AccountAlias()}, nil
+}
+
+func (s *server) GetRancherLicense(ctx context.Context, _ *proto.Empty) (*proto.LicenseResponse, error) {
+	license, err := s.provider.GetRancherLicense(ctx)
+	data, encodeErr := json.Marshal(license)
+	if encodeErr != nil {
+		return nil, encodeErr
+	}
+	return &proto.LicenseResponse{License: data, Error: newError(err)}, nil
+}
+
+func (s *server) CheckoutRancherLicense(ctx context.Context, req *proto.CheckoutRequest) (*proto.OutputResponse, error) {
+	var license types.GrantedLicense
+	if err := decode(req.License, &license); err != nil {
+		return nil, err
+	}
+	if req.CheckoutToken != "" {
+		ctx = aws.WithCheckoutToken(ctx, req.CheckoutToken)
+	}
+	output, err := s.provider.CheckoutRancherLicense(ctx, license, int(req.EntitlementAmt))
+	return outputResponse(output, err)
+}
+
+func (s *server) CheckInRancherLicense(ctx context.Context, req *proto.TokenRequest) (*proto.OutputResponse, error) {
+	output, err := s.provider.CheckInRancherLicense(ctx, req.ConsumptionToken)
+	return outputResponse(output, err)
+}
+
+func (s *server) ExtendRancherLicenseConsumptionToken(ctx context.Context, req *proto.TokenRequest) (*proto.OutputResponse, error) {
+	output, err := s.provider.ExtendRancherLicenseConsumptionToken(ctx, req.ConsumptionToken)
+	return outputResponse(output, err)
+}
+
+// outputResponse returns the response carrying the output and error of a License Manager call
+func outputResponse(output interface{}, err error) (*proto.OutputResponse, error) {
+	data, encodeErr := json.Marshal(output)
+	if encodeErr != nil {
+		return nil, encodeErr
+	}
+	return &proto.OutputResponse{Output: data, Error: newError(err)}, nil
+}
+
+func (s *server) GetNumberOfAvailableEntitlements(ctx context.Context, req *proto.LicenseRequest) (*proto.CountResponse, error) {
+	var license types.GrantedLicense
+	if err := decode(req.License, &license); err != nil {
+		return nil, err
+	}
+	count, err := s.provider.GetNumberOfAvailableEntitlements(ctx, license)
+	return &proto.CountResponse{Count: int64(count), Error: newError(err)}, nil
+}
+
+func (s *server) GetEntitlementUsage(ctx context.Context, req *proto.LicenseRequest) (*proto.UsageResponse, error) {
+	var license types.GrantedLicense
+	if err := decode(req.License, &license); err != nil {
+		return nil, err
+	}
+	usage, err := s.provider.GetEntitlementUsage(ctx, license)
+	resp := &proto.UsageResponse{
+		Max:       int64(usage.Max),
+		Consumed:  int64(usage.Consumed),
+		Dimension: usage.Dimension,
+		Error:     newError(err),
+	}
+	for _, dimension := range usage.Dimensions {
+		resp.Dimensions = append(resp.Dimensions, &proto.DimensionUsage{Name: dimension.Name, Max: dimension.Max, Consumed: dimension.Consumed})
+	}
+	return resp, nil
+}
+
+func (s *server) CheckServiceHealth(ctx context.Context, _ *proto.Empty) (*proto.ErrorResponse, error) {
+	return &proto.ErrorResponse{Error: newError(s.provider.CheckServiceHealth(ctx))}, nil
+}
+
+func (s *server) ListProducts(ctx context.Context, _ *proto.Empty) (*proto.ProductsResponse, error) {
+	products, err := s.provider.ListProducts(ctx)
+	resp := &proto.ProductsResponse{Error: newError(err)}
+	for _, product := range products {
+		resp.Products = append(resp.Products, productMessage(product))
+	}
+	return resp, nil
+}
+
+func productMessage(product sdk.Product) *proto.Product {
+	msg := &proto.Product{
+		Name:       product.Name,
+		Sku:        product.SKU,
+		Found:      product.Found,
+		Active:     product.Active,
+		Matched:    product.Matched,
+		LicenseArn: product.LicenseArn,
+		Status:     product.Status,
+	}
+	for _, dimension := range product.Dimensions {
+		msg.Dimensions = append(msg.Dimensions, &proto.ProductDimension{
+			Name:     dimension.Name,
+			Unit:     dimension.Unit,
+			MaxCount: dimension.MaxCount,
+		})
+	}
+	return msg
+}
+
+func (s *server) ValidateLicense(_ context.Context, req *proto.LicenseRequest) (*proto.ErrorResponse, error) {
+	var license types.GrantedLicense
+	if err := decode(req.License, &license); err != nil {
+		return nil, err
+	}
+	return &proto.ErrorResponse{Error: newError(s.provider.ValidateLicense(license))}, nil
+}
@@ -0,0 +1,184 @@
+// Package costs estimates the monthly AWS cost of the adapter's integrations, so that operators can budget for them.
+// License Manager calls are free, but the resources some integrations depend on aren't: secrets referenced in AWS
+// Secrets Manager are billed per secret and per request, and compliance reports written to S3 per request. Requests are
+// counted as the adapter makes them and extrapolated to a month
+package costs
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/clients/aws"
+	"github.com/rancher/csp-adapter/pkg/sdk"
+)
+
+// Prices are the list prices the estimate is based on, in USD
+type Prices struct {
+	// SecretsManagerSecret is the monthly price of a secret stored in Secrets Manager
+	SecretsManagerSecret float64
+	// SecretsManagerRequest is the price of a Secrets Manager api call
+	SecretsManagerRequest float64
+	// S3Put is the price of a PUT request to S3 Standard
+	S3Put float64
+}
+
+// DefaultPrices are the prices in us-east-1. Prices in other regions are close enough for budgeting
+var DefaultPrices = Prices{
+	SecretsManagerSecret:  0.40,
+	SecretsManagerRequest: 0.05 / 10000,
+	S3Put:                 0.005 / 1000,
+}
+
+// Services and operations which are counted
+const (
+	ServiceSecretsManager = "secretsmanager"
+	ServiceS3             = "s3"
+
+	OperationGetSecretValue = "GetSecretValue"
+	OperationPutObject      = "PutObject"
+)
+
+const (
+	// month is the period estimates are extrapolated to
+	month = 30 * 24 * time.Hour
+	// minSpan is the shortest period requests are extrapolated from, so that the requests made while the adapter
+	// starts aren't taken for its steady rate
+	minSpan = time.Hour
+)
+
+type operation struct {
+	service string
+	name    string
+}
+
+// Estimator counts the billable requests of the adapter and the resources it uses
+type Estimator struct {
+	prices  Prices
+	now     func() time.Time
+	started time.Time
+
+	lock  sync.Mutex
+	calls map[operation]int
+	// secrets are the ids of the secrets read from Secrets Manager
+	secrets map[string]bool
+}
+
+func NewEstimator(prices Prices) *Estimator {
+	return &Estimator{
+		prices:  prices,
+		now:     time.Now,
+		started: time.Now(),
+		calls:   map[operation]int{},
+		secrets: map[string]bool{},
+	}
+}
+
+// record counts a request of operation to service
+func (e *Estimator) record(service, name string) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.calls[operation{service: service, name: name}]++
+}
+
+// useSecret records that the secret with id is read from Secrets Manager, which is billed per month however often
+// it's read
+func (e *Estimator) useSecret(id string) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.secrets[id] = true
+}
+
+// price returns the price of a request of operation
+func (e *Estimator) price(op operation) float64 {
+	switch op {
+	case operation{service: ServiceSecretsManager, name: OperationGetSecretValue}:
+		return e.prices.SecretsManagerRequest
+	case operation{service: ServiceS3, name: OperationPutObject}:
+		return e.prices.S3Put
+	}
+	return 0
+}
+
+// Estimate returns the estimated monthly cost of the requests counted so far and the resources in use
+func (e *Estimator) Estimate() sdk.CostEstimate {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	span := e.now().Sub(e.started)
+	if span < minSpan {
+		span = minSpan
+	}
+	estimate := sdk.CostEstimate{Since: e.started}
+	for op, calls := range e.calls {
+		monthlyCalls := int(math.Round(float64(calls) * float64(month) / float64(span)))
+		cost := sdk.OperationCost{
+			Service:      op.service,
+			Operation:    op.name,
+			Calls:        calls,
+			MonthlyCalls: monthlyCalls,
+			MonthlyUSD:   roundCents(float64(monthlyCalls) * e.price(op)),
+		}
+		estimate.Operations = append(estimate.Operations, cost)
+		estimate.MonthlyUSD += cost.MonthlyUSD
+	}
+	sort.Slice(estimate.Operations, func(i, j int) bool {
+		a, b := estimate.Operations[i], estimate.Operations[j]
+		if a.Service != b.Service {
+			return a.Service < b.Service
+		}
+		return a.Operation < b.Operation
+	})
+	for id := range e.secrets {
+		estimate.Resources = append(estimate.Resources, sdk.ResourceCost{
+			Service:    ServiceSecretsManager,
+			Resource:   id,
+			MonthlyUSD: e.prices.SecretsManagerSecret,
+		})
+		estimate.MonthlyUSD += e.prices.SecretsManagerSecret
+	}
+	sort.Slice(estimate.Resources, func(i, j int) bool {
+		return estimate.Resources[i].Resource < estimate.Resources[j].Resource
+	})
+	estimate.MonthlyUSD = roundCents(estimate.MonthlyUSD)
+	return estimate
+}
+
+// roundCents rounds usd to a tenth of a cent, below which estimates aren't meaningful
+func roundCents(usd float64) float64 {
+	return math.Round(usd*1000) / 1000
+}
+
+// secretsClient counts the requests of the wrapped client
+type secretsClient struct {
+	aws.SecretsClient
+	estimator *Estimator
+}
+
+// NewSecretsClient wraps client, counting its requests and the secrets it reads in estimator
+func NewSecretsClient(client aws.SecretsClient, estimator *Estimator) aws.SecretsClient {
+	return &secretsClient{SecretsClient: client, estimator: estimator}
+}
+
+func (c *secretsClient) GetSecretValue(ctx context.Context, id string) (string, error) {
+	c.estimator.record(ServiceSecretsManager, OperationGetSecretValue)
+	c.estimator.useSecret(id)
+	return c.SecretsClient.GetSecretValue(ctx, id)
+}
+
+// bucketClient counts the requests of the wrapped client
+type bucketClient struct {
+	aws.BucketClient
+	estimator *Estimator
+}
+
+// NewBucketClient wraps client, counting its requests in estimator
+func NewBucketClient(client aws.BucketClient, estimator *Estimator) aws.BucketClient {
+	return &bucketClient{BucketClient: client, estimator: estimator}
+}
+
+func (c *bucketClient) PutObject(ctx context.Context, key, contentType string, body []byte) error {
+	c.estimator.record(ServiceS3, OperationPutObject)
+	return c.BucketClient.PutObject(ctx, key, contentType, body)
+}
@@ -0,0 +1,64 @@
+package costs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSecrets struct{}
+
+func (fakeSecrets) GetSecretValue(ctx context.Context, id string) (string, error) {
+	return "value", nil
+}
+
+type fakeBucket struct{}
+
+func (fakeBucket) PutObject(ctx context.Context, key, contentType string, body []byte) error {
+	return nil
+}
+
+func (fakeBucket) Bucket() string {
+	return "reports"
+}
+
+func TestEstimate(t *testing.T) {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+	estimator := NewEstimator(Prices{SecretsManagerSecret: 0.4, SecretsManagerRequest: 0.001, S3Put: 0.01})
+	estimator.now, estimator.started = func() time.Time { return now }, start
+
+	assert.Equal(t, sdk.CostEstimate{Since: start}, estimator.Estimate(), "nothing is billed without integrations")
+
+	ctx := context.Background()
+	secrets := NewSecretsClient(fakeSecrets{}, estimator)
+	bucket := NewBucketClient(fakeBucket{}, estimator)
+	for i := 0; i < 2; i++ {
+		_, err := secrets.GetSecretValue(ctx, "rancher/webhook")
+		require.NoError(t, err)
+	}
+	_, err := secrets.GetSecretValue(ctx, "rancher/nats")
+	require.NoError(t, err)
+	require.NoError(t, bucket.PutObject(ctx, "report.json", "application/json", nil))
+	assert.Equal(t, "reports", bucket.Bucket())
+
+	now = start.Add(6 * time.Hour)
+	estimate := estimator.Estimate()
+	assert.Equal(t, []sdk.OperationCost{
+		// 1 request every 6 hours is 120 a month
+		{Service: ServiceS3, Operation: OperationPutObject, Calls: 1, MonthlyCalls: 120, MonthlyUSD: 1.2},
+		{Service: ServiceSecretsManager, Operation: OperationGetSecretValue, Calls: 3, MonthlyCalls: 360, MonthlyUSD: 0.36},
+	}, estimate.Operations)
+	assert.Equal(t, []sdk.ResourceCost{
+		{Service: ServiceSecretsManager, Resource: "rancher/nats", MonthlyUSD: 0.4},
+		{Service: ServiceSecretsManager, Resource: "rancher/webhook", MonthlyUSD: 0.4},
+	}, estimate.Resources, "each secret is billed once however often it's read")
+	assert.Equal(t, 2.36, estimate.MonthlyUSD)
+
+	now = start.Add(time.Minute)
+	assert.Equal(t, 720, estimator.Estimate().Operations[0].MonthlyCalls, "requests are extrapolated from at least an hour")
+}
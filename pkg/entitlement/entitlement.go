@@ -0,0 +1,210 @@
+// Package entitlement does the arithmetic of License Manager entitlements: quantities carry their unit, so that
+// entitlements of different units can't be mixed up, and every operation checks for overflow rather than wrapping
+// around, since values parsed from License Manager or configuration are only trusted as far as they're validated
+package entitlement
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Unit is the unit entitlements are granted and consumed in, as named by License Manager
+type Unit string
+
+const (
+	// Count entitlements are licenses, each covering NodesPerLicense nodes
+	Count Unit = "Count"
+	// VCPU entitlements each cover a single vCPU
+	VCPU Unit = "vCPU"
+)
+
+// NodesPerLicense is the number of nodes covered by a single Count entitlement
+const NodesPerLicense = 20
+
+// ErrOverflow is returned by operations whose result doesn't fit a Quantity
+var ErrOverflow = errors.New("entitlement quantity overflows")
+
+// ParseUnit returns the unit named by name, ignoring case. An empty name is Count, the unit of entitlements which don't
+// name one
+func ParseUnit(name string) (Unit, error) {
+	switch {
+	case name == "" || strings.EqualFold(name, string(Count)):
+		return Count, nil
+	case strings.EqualFold(name, string(VCPU)):
+		return VCPU, nil
+	default:
+		return "", fmt.Errorf("unknown entitlement unit %q, must be %s or %s", name, Count, VCPU)
+	}
+}
+
+// Quantity is a non-negative amount of entitlements of a unit. The zero value is 0 Count entitlements
+type Quantity struct {
+	amount int64
+	unit   Unit
+}
+
+// New returns amount entitlements of unit
+func New(amount int64, unit Unit) (Quantity, error) {
+	if amount < 0 {
+		return Quantity{}, fmt.Errorf("entitlement quantity can't be negative, got %d %s", amount, unit)
+	}
+	if _, err := ParseUnit(string(unit)); err != nil {
+		return Quantity{}, err
+	}
+	return Quantity{amount: amount, unit: unit}, nil
+}
+
+// Licenses returns n Count entitlements
+func Licenses(n int) (Quantity, error) {
+	return New(int64(n), Count)
+}
+
+// Parse parses a quantity as formatted by License Manager, i.e. the consumed value of an entitlement, in the unit named
+// by unit
+func Parse(value, unit string) (Quantity, error) {
+	u, err := ParseUnit(unit)
+	if err != nil {
+		return Quantity{}, err
+	}
+	amount, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		return Quantity{}, fmt.Errorf("%q: %w", value, ErrOverflow)
+	}
+	if err != nil {
+		return Quantity{}, fmt.Errorf("invalid entitlement quantity %q: %v", value, err)
+	}
+	return New(amount, u)
+}
+
+// Amount returns the number of entitlements
+func (q Quantity) Amount() int64 {
+	return q.amount
+}
+
+// Unit returns the unit of the entitlements
+func (q Quantity) Unit() Unit {
+	if q.unit == "" {
+		return Count
+	}
+	return q.unit
+}
+
+// Int returns the number of entitlements as an int, for the callers which count licenses in ints. Fails if the amount
+// doesn't fit, i.e. on 32 bit platforms
+func (q Quantity) Int() (int, error) {
+	if q.amount > math.MaxInt {
+		return 0, fmt.Errorf("%s: %w", q, ErrOverflow)
+	}
+	return int(q.amount), nil
+}
+
+// String formats the amount as License Manager expects it in checkouts, without the unit
+func (q Quantity) String() string {
+	return strconv.FormatInt(q.amount, 10)
+}
+
+// sameUnit returns an error if q and o are of different units
+func (q Quantity) sameUnit(o Quantity) error {
+	if q.Unit() != o.Unit() {
+		return fmt.Errorf("can't combine %s entitlements with %s entitlements", q.Unit(), o.Unit())
+	}
+	return nil
+}
+
+// Add returns q + o
+func (q Quantity) Add(o Quantity) (Quantity, error) {
+	if err := q.sameUnit(o); err != nil {
+		return Quantity{}, err
+	}
+	if q.amount > math.MaxInt64-o.amount {
+		return Quantity{}, ErrOverflow
+	}
+	return Quantity{amount: q.amount + o.amount, unit: q.Unit()}, nil
+}
+
+// Remaining returns q - o, or 0 if o exceeds q, i.e. the entitlements still available when more were consumed than
+// granted
+func (q Quantity) Remaining(o Quantity) (Quantity, error) {
+	if err := q.sameUnit(o); err != nil {
+		return Quantity{}, err
+	}
+	if o.amount >= q.amount {
+		return Quantity{unit: q.Unit()}, nil
+	}
+	return Quantity{amount: q.amount - o.amount, unit: q.Unit()}, nil
+}
+
+// Min returns the smaller of q and o
+func (q Quantity) Min(o Quantity) (Quantity, error) {
+	if err := q.sameUnit(o); err != nil {
+		return Quantity{}, err
+	}
+	if o.amount < q.amount {
+		return o, nil
+	}
+	return q, nil
+}
+
+// mul returns a * b, failing instead of overflowing. Both must be non-negative
+func mul(a, b int64) (int64, error) {
+	if a != 0 && b > math.MaxInt64/a {
+		return 0, ErrOverflow
+	}
+	return a * b, nil
+}
+
+// ceilDiv returns a / b rounded up. a must be non-negative and b positive
+func ceilDiv(a, b int64) int64 {
+	quotient := a / b
+	if a%b != 0 {
+		quotient++
+	}
+	return quotient
+}
+
+// ForNodes returns the Count entitlements required to cover nodes, rounded up to whole licenses
+func ForNodes(nodes int) (Quantity, error) {
+	if nodes < 0 {
+		return Quantity{}, fmt.Errorf("node count can't be negative, got %d", nodes)
+	}
+	return Quantity{amount: ceilDiv(int64(nodes), NodesPerLicense), unit: Count}, nil
+}
+
+// Nodes returns the number of nodes covered by q, which must be Count entitlements
+func (q Quantity) Nodes() (int64, error) {
+	if q.Unit() != Count {
+		return 0, fmt.Errorf("%s entitlements don't cover a number of nodes", q.Unit())
+	}
+	return mul(q.amount, NodesPerLicense)
+}
+
+// Convert returns the entitlements of unit to which cover the same nodes as q, for nodes of vcpusPerNode vCPUs each.
+// Conversions to a coarser unit round up, so that the converted entitlements always cover as much as q
+func (q Quantity) Convert(to Unit, vcpusPerNode int64) (Quantity, error) {
+	if _, err := ParseUnit(string(to)); err != nil {
+		return Quantity{}, err
+	}
+	if q.Unit() == to {
+		return q, nil
+	}
+	if vcpusPerNode <= 0 {
+		return Quantity{}, fmt.Errorf("converting %s to %s entitlements requires the vCPUs per node, got %d", q.Unit(), to, vcpusPerNode)
+	}
+	vcpusPerLicense, err := mul(NodesPerLicense, vcpusPerNode)
+	if err != nil {
+		return Quantity{}, err
+	}
+	switch to {
+	case VCPU:
+		vcpus, err := mul(q.amount, vcpusPerLicense)
+		if err != nil {
+			return Quantity{}, err
+		}
+		return Quantity{amount: vcpus, unit: VCPU}, nil
+	default:
+		return Quantity{amount: ceilDiv(q.amount, vcpusPerLicense), unit: Count}, nil
+	}
+}
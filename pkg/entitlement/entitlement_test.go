@@ -0,0 +1,258 @@
+package entitlement
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mustNew(t *testing.T, amount int64, unit Unit) Quantity {
+	t.Helper()
+	q, err := New(amount, unit)
+	require.NoError(t, err)
+	return q
+}
+
+func TestParseUnit(t *testing.T) {
+	tests := []struct {
+		name    string
+		unit    string
+		want    Unit
+		wantErr bool
+	}{
+		{name: "count", unit: "Count", want: Count},
+		{name: "no unit is count", unit: "", want: Count},
+		{name: "vcpu", unit: "vCPU", want: VCPU},
+		{name: "case is ignored", unit: "VCPU", want: VCPU},
+		{name: "unknown", unit: "Gigabytes", wantErr: true},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			unit, err := ParseUnit(test.unit)
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.want, unit)
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(-1, Count)
+	assert.Error(t, err, "negative quantities are invalid")
+	_, err = New(1, "Bytes")
+	assert.Error(t, err, "unknown units are invalid")
+
+	var zero Quantity
+	assert.Equal(t, Count, zero.Unit(), "the zero value is of Count")
+	assert.Equal(t, int64(0), zero.Amount())
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name         string
+		value        string
+		unit         string
+		want         Quantity
+		wantErr      bool
+		wantOverflow bool
+	}{
+		{name: "count", value: "12", unit: "Count", want: Quantity{amount: 12, unit: Count}},
+		{name: "surrounding whitespace", value: " 12 ", unit: "Count", want: Quantity{amount: 12, unit: Count}},
+		{name: "vcpu", value: "64", unit: "vCPU", want: Quantity{amount: 64, unit: VCPU}},
+		{name: "not a number", value: "twelve", unit: "Count", wantErr: true},
+		{name: "fraction", value: "1.5", unit: "Count", wantErr: true},
+		{name: "negative", value: "-1", unit: "Count", wantErr: true},
+		{name: "unknown unit", value: "1", unit: "Bytes", wantErr: true},
+		{name: "overflow", value: "9223372036854775808", unit: "Count", wantErr: true, wantOverflow: true},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			q, err := Parse(test.value, test.unit)
+			if test.wantErr {
+				require.Error(t, err)
+				assert.Equal(t, test.wantOverflow, errors.Is(err, ErrOverflow))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.want, q)
+		})
+	}
+}
+
+func TestAdd(t *testing.T) {
+	sum, err := mustNew(t, 2, Count).Add(mustNew(t, 3, Count))
+	require.NoError(t, err)
+	assert.Equal(t, mustNew(t, 5, Count), sum)
+
+	sum, err = Quantity{}.Add(mustNew(t, 3, Count))
+	require.NoError(t, err)
+	assert.Equal(t, mustNew(t, 3, Count), sum, "the zero value can be summed into")
+
+	_, err = mustNew(t, math.MaxInt64, Count).Add(mustNew(t, 1, Count))
+	assert.True(t, errors.Is(err, ErrOverflow))
+
+	_, err = mustNew(t, 1, Count).Add(mustNew(t, 1, VCPU))
+	assert.Error(t, err, "units can't be mixed")
+}
+
+func TestRemaining(t *testing.T) {
+	remaining, err := mustNew(t, 5, Count).Remaining(mustNew(t, 2, Count))
+	require.NoError(t, err)
+	assert.Equal(t, mustNew(t, 3, Count), remaining)
+
+	remaining, err = mustNew(t, 2, Count).Remaining(mustNew(t, 5, Count))
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), remaining.Amount(), "over-consumption leaves nothing rather than a negative quantity")
+
+	_, err = mustNew(t, 5, VCPU).Remaining(mustNew(t, 2, Count))
+	assert.Error(t, err)
+}
+
+func TestMin(t *testing.T) {
+	min, err := mustNew(t, 5, Count).Min(mustNew(t, 2, Count))
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), min.Amount())
+
+	min, err = mustNew(t, 2, Count).Min(mustNew(t, 5, Count))
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), min.Amount())
+
+	_, err = mustNew(t, 5, VCPU).Min(mustNew(t, 2, Count))
+	assert.Error(t, err)
+}
+
+func TestForNodes(t *testing.T) {
+	tests := []struct {
+		nodes int
+		want  int64
+	}{
+		{nodes: 0, want: 0},
+		{nodes: 1, want: 1},
+		{nodes: 20, want: 1},
+		{nodes: 21, want: 2},
+		{nodes: 40, want: 2},
+		{nodes: math.MaxInt32, want: math.MaxInt32/NodesPerLicense + 1},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(strconv.Itoa(test.nodes), func(t *testing.T) {
+			q, err := ForNodes(test.nodes)
+			require.NoError(t, err)
+			assert.Equal(t, mustNew(t, test.want, Count), q)
+		})
+	}
+
+	_, err := ForNodes(-1)
+	assert.Error(t, err)
+}
+
+func TestNodes(t *testing.T) {
+	nodes, err := mustNew(t, 3, Count).Nodes()
+	require.NoError(t, err)
+	assert.Equal(t, int64(60), nodes)
+
+	_, err = mustNew(t, math.MaxInt64/NodesPerLicense+1, Count).Nodes()
+	assert.True(t, errors.Is(err, ErrOverflow))
+
+	_, err = mustNew(t, 3, VCPU).Nodes()
+	assert.Error(t, err, "vCPU entitlements don't count nodes")
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		name         string
+		from         Quantity
+		to           Unit
+		vcpusPerNode int64
+		want         Quantity
+		wantErr      bool
+		wantOverflow bool
+	}{
+		{
+			name: "same unit",
+			from: Quantity{amount: 3, unit: Count},
+			to:   Count,
+			want: Quantity{amount: 3, unit: Count},
+		},
+		{
+			name:         "licenses to vcpus",
+			from:         Quantity{amount: 3, unit: Count},
+			to:           VCPU,
+			vcpusPerNode: 4,
+			want:         Quantity{amount: 240, unit: VCPU},
+		},
+		{
+			name:         "vcpus to whole licenses",
+			from:         Quantity{amount: 160, unit: VCPU},
+			to:           Count,
+			vcpusPerNode: 4,
+			want:         Quantity{amount: 2, unit: Count},
+		},
+		{
+			name:         "vcpus to licenses rounds up",
+			from:         Quantity{amount: 161, unit: VCPU},
+			to:           Count,
+			vcpusPerNode: 4,
+			want:         Quantity{amount: 3, unit: Count},
+		},
+		{
+			name:         "no vcpus per node",
+			from:         Quantity{amount: 3, unit: Count},
+			to:           VCPU,
+			vcpusPerNode: 0,
+			wantErr:      true,
+		},
+		{
+			name:         "unknown unit",
+			from:         Quantity{amount: 3, unit: Count},
+			to:           "Bytes",
+			vcpusPerNode: 4,
+			wantErr:      true,
+		},
+		{
+			name:         "vcpus overflow",
+			from:         Quantity{amount: math.MaxInt64 / 40, unit: Count},
+			to:           VCPU,
+			vcpusPerNode: 4,
+			wantErr:      true,
+			wantOverflow: true,
+		},
+		{
+			name:         "vcpus per license overflow",
+			from:         Quantity{amount: 1, unit: VCPU},
+			to:           Count,
+			vcpusPerNode: math.MaxInt64 / 10,
+			wantErr:      true,
+			wantOverflow: true,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			q, err := test.from.Convert(test.to, test.vcpusPerNode)
+			if test.wantErr {
+				require.Error(t, err)
+				assert.Equal(t, test.wantOverflow, errors.Is(err, ErrOverflow))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.want, q)
+		})
+	}
+}
+
+func TestInt(t *testing.T) {
+	n, err := mustNew(t, 42, Count).Int()
+	require.NoError(t, err)
+	assert.Equal(t, 42, n)
+	assert.Equal(t, "42", mustNew(t, 42, Count).String())
+}
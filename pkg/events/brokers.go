@@ -0,0 +1,138 @@
+package events
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nkeys"
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+)
+
+// NATSOptions secures the connection to NATS. Users and passwords or tokens can be set in the url instead
+type NATSOptions struct {
+	// TLS, if set, is used to connect to the servers, which must then offer tls
+	TLS *tls.Config
+	// Credentials is the content of a NATS user credentials file (a user jwt and its nkey seed), for servers which
+	// authenticate users with decentralized jwts
+	Credentials []byte
+}
+
+// NATSPublisher publishes each event to the subject <prefix>.<type>, so that subscribers can pick event types with
+// subject wildcards, i.e. csp-adapter.license.>
+type NATSPublisher struct {
+	conn   *nats.Conn
+	prefix string
+}
+
+// NewNATSPublisher connects to the NATS servers at url. A server which can't be reached yet is retried in the
+// background, events published meanwhile are buffered by the client
+func NewNATSPublisher(url, prefix string, opts NATSOptions) (*NATSPublisher, error) {
+	options := []nats.Option{
+		nats.Name(source),
+		nats.RetryOnFailedConnect(true),
+		nats.MaxReconnects(-1),
+	}
+	if opts.TLS != nil {
+		options = append(options, nats.Secure(opts.TLS))
+	}
+	if len(opts.Credentials) > 0 {
+		jwt, err := nkeys.ParseDecoratedJWT(opts.Credentials)
+		if err != nil {
+			return nil, fmt.Errorf("invalid NATS credentials: %v", err)
+		}
+		seed, err := nkeys.ParseDecoratedUserNKey(opts.Credentials)
+		if err != nil {
+			return nil, fmt.Errorf("invalid NATS credentials: %v", err)
+		}
+		options = append(options, nats.UserJWT(
+			func() (string, error) { return jwt, nil },
+			func(nonce []byte) ([]byte, error) { return seed.Sign(nonce) }))
+	}
+	conn, err := nats.Connect(url, options...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to NATS: %v", err)
+	}
+	return &NATSPublisher{conn: conn, prefix: prefix}, nil
+}
+
+func (p *NATSPublisher) Publish(ctx context.Context, event Event, payload []byte) error {
+	return p.conn.Publish(p.prefix+"."+event.Type, payload)
+}
+
+// Close sends the events buffered by the client before closing the connection
+func (p *NATSPublisher) Close() error {
+	return p.conn.Drain()
+}
+
+// KafkaPublisher publishes every event to a single topic, keyed by event type so that the events of a type stay in order
+// on one partition. The type is also set as the "type" header, for consumers filtering without parsing events
+type KafkaPublisher struct {
+	writer *kafka.Writer
+}
+
+// KafkaOptions secures the connection to the Kafka brokers
+type KafkaOptions struct {
+	// TLS, if set, is used to connect to the brokers, which must then offer tls
+	TLS *tls.Config
+	// SASL authenticates the adapter to the brokers, see KafkaSASL. Nil for none
+	SASL sasl.Mechanism
+}
+
+// Kafka SASL mechanisms supported by KafkaSASL
+const (
+	SASLPlain       = "plain"
+	SASLScramSHA256 = "scram-sha-256"
+	SASLScramSHA512 = "scram-sha-512"
+)
+
+// KafkaSASL returns the SASL mechanism named mechanism (one of the SASL constants) authenticating as username. Plain
+// sends the password as is, so it should only be used over tls
+func KafkaSASL(mechanism, username, password string) (sasl.Mechanism, error) {
+	switch strings.ToLower(mechanism) {
+	case SASLPlain:
+		return plain.Mechanism{Username: username, Password: password}, nil
+	case SASLScramSHA256:
+		return scram.Mechanism(scram.SHA256, username, password)
+	case SASLScramSHA512:
+		return scram.Mechanism(scram.SHA512, username, password)
+	}
+	return nil, fmt.Errorf("unsupported SASL mechanism %q, expected %s, %s or %s", mechanism, SASLPlain, SASLScramSHA256,
+		SASLScramSHA512)
+}
+
+// NewKafkaPublisher returns a publisher writing to topic on the Kafka cluster of brokers. The topic must exist unless the
+// cluster creates topics automatically
+func NewKafkaPublisher(brokers []string, topic string, opts KafkaOptions) *KafkaPublisher {
+	writer := &kafka.Writer{
+		Addr:     kafka.TCP(brokers...),
+		Topic:    topic,
+		Balancer: &kafka.Hash{},
+		// events are written one at a time, waiting for a batch to fill up only delays them
+		BatchTimeout: 10 * time.Millisecond,
+		RequiredAcks: kafka.RequireAll,
+	}
+	if opts.TLS != nil || opts.SASL != nil {
+		writer.Transport = &kafka.Transport{TLS: opts.TLS, SASL: opts.SASL}
+	}
+	return &KafkaPublisher{writer: writer}
+}
+
+func (p *KafkaPublisher) Publish(ctx context.Context, event Event, payload []byte) error {
+	return p.writer.WriteMessages(ctx, kafka.Message{
+		Key:     []byte(event.Type),
+		Value:   payload,
+		Headers: []kafka.Header{{Key: "type", Value: []byte(event.Type)}},
+		Time:    event.Time,
+	})
+}
+
+func (p *KafkaPublisher) Close() error {
+	return p.writer.Close()
+}
@@ -0,0 +1,213 @@
+// Package events publishes every event of the adapter (license activity, compliance and config changes) to a message
+// broker, for customers who centralize platform events in NATS or Kafka. Events are published asynchronously, so that a
+// slow or unavailable broker never holds up a compliance check. The schema of events is documented in the README and
+// only changes in a compatible way unless SchemaVersion changes
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rancher/csp-adapter/pkg/audit"
+	"github.com/rancher/csp-adapter/pkg/metrics"
+	"github.com/sirupsen/logrus"
+)
+
+// SchemaVersion is the version of the event envelope and of the data of each type
+const SchemaVersion = "1"
+
+// Types of events. License events carry an audit.Event as data, compliance changes a ComplianceChange and config
+// changes a ConfigChange
+const (
+	TypeCheckout   = "license.checkout"
+	TypeCheckIn    = "license.checkin"
+	TypeRenewal    = "license.renewal"
+	TypeRecovery   = "license.recovery"
+	TypeSwitchover = "license.switchover"
+	// TypeComplianceChanged is published when the status or reason of the compliance of the install changes
+	TypeComplianceChanged = "compliance.changed"
+	// TypeConfigChanged is published when the configuration is changed at runtime, i.e. a feature flag is toggled
+	TypeConfigChanged = "config.changed"
+)
+
+// licenseTypes maps the actions of audit events to their event types
+var licenseTypes = map[string]string{
+	audit.ActionCheckout:   TypeCheckout,
+	audit.ActionCheckIn:    TypeCheckIn,
+	audit.ActionExtend:     TypeRenewal,
+	audit.ActionRecover:    TypeRecovery,
+	audit.ActionSwitchover: TypeSwitchover,
+}
+
+// Kinds of config changes
+const (
+	// ConfigFeatureFlag is a feature flag which was toggled, Name is the flag
+	ConfigFeatureFlag = "featureFlag"
+	// ConfigCheckoutAdjustments is the pause or resumption of checkout adjustments
+	ConfigCheckoutAdjustments = "checkoutAdjustments"
+)
+
+// source identifies the adapter as the origin of events on a shared broker
+const source = "rancher-csp-adapter"
+
+// Event is the envelope of every event published to the firehose
+type Event struct {
+	ID            string    `json:"id"`
+	Type          string    `json:"type"`
+	SchemaVersion string    `json:"schemaVersion"`
+	Source        string    `json:"source"`
+	Account       string    `json:"account,omitempty"`
+	Time          time.Time `json:"time"`
+	// Data is specific to the type of the event
+	Data interface{} `json:"data"`
+}
+
+// ComplianceChange is the data of compliance.changed events. The previous status and reason are empty for the first
+// compliance check after the adapter started
+type ComplianceChange struct {
+	Status         string `json:"status"`
+	Reason         string `json:"reason"`
+	Message        string `json:"message,omitempty"`
+	PreviousStatus string `json:"previousStatus,omitempty"`
+	PreviousReason string `json:"previousReason,omitempty"`
+}
+
+// ConfigChange is the data of config.changed events
+type ConfigChange struct {
+	// Kind is one of the Config constants
+	Kind     string `json:"kind"`
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Previous string `json:"previous,omitempty"`
+	// By and Reason are set for changes made by an operator through the status api
+	By     string `json:"by,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// Publisher sends events to a broker
+type Publisher interface {
+	// Publish sends event, marshalled as payload. It may block until the broker acknowledged it
+	Publish(ctx context.Context, event Event, payload []byte) error
+	Close() error
+}
+
+// publishTimeout limits how long a single event is retried by the broker's client before it's counted as failed
+const publishTimeout = 10 * time.Second
+
+// closeTimeout limits how long Close waits for the queued events to be published
+const closeTimeout = 30 * time.Second
+
+// Firehose queues events and publishes them in order with a Publisher. Events published while the queue is full are
+// dropped rather than blocking the caller. A nil Firehose drops every event, so that callers needn't check whether the
+// firehose is enabled
+type Firehose struct {
+	publisher Publisher
+	queue     chan Event
+	now       func() time.Time
+
+	// stopped is closed when Run returns because its context is done
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+// NewFirehose returns a firehose publishing with publisher, queueing up to buffer events while the broker is slow
+func NewFirehose(publisher Publisher, buffer int) *Firehose {
+	return &Firehose{
+		publisher: publisher,
+		queue:     make(chan Event, buffer),
+		now:       time.Now,
+		stopped:   make(chan struct{}),
+	}
+}
+
+// Publish queues an event of eventType with data for account
+func (f *Firehose) Publish(eventType, account string, data interface{}) {
+	if f == nil {
+		return
+	}
+	event := Event{
+		ID:            uuid.New().String(),
+		Type:          eventType,
+		SchemaVersion: SchemaVersion,
+		Source:        source,
+		Account:       account,
+		Time:          f.now().UTC(),
+		Data:          data,
+	}
+	select {
+	case f.queue <- event:
+	default:
+		logrus.Warnf("[events] dropping %s event %s, the broker isn't keeping up", event.Type, event.ID)
+		metrics.FirehoseEvents.WithLabelValues("dropped").Inc()
+	}
+}
+
+// Emit publishes license events, so that the firehose receives license activity from the audit client. It implements
+// audit.Sink
+func (f *Firehose) Emit(ctx context.Context, event audit.Event) error {
+	eventType, ok := licenseTypes[event.Action]
+	if !ok {
+		logrus.Debugf("[events] not publishing audit event %s of unknown action %s", event.AuditID, event.Action)
+		return nil
+	}
+	f.Publish(eventType, event.Account, event)
+	return nil
+}
+
+// Run publishes queued events until ctx is done, then publishes the events still queued. The publisher is left open,
+// so that Run can be restarted after a panic; Close closes it
+func (f *Firehose) Run(ctx context.Context) {
+	defer func() {
+		if ctx.Err() != nil {
+			f.stopOnce.Do(func() { close(f.stopped) })
+		}
+	}()
+	for {
+		select {
+		case event := <-f.queue:
+			f.send(ctx, event)
+		case <-ctx.Done():
+			for {
+				select {
+				case event := <-f.queue:
+					// ctx is done, the remaining events get a context of their own
+					f.send(context.Background(), event)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// Close closes the publisher once Run published the events still queued after its context is done, waiting for it
+// at most closeTimeout
+func (f *Firehose) Close() error {
+	select {
+	case <-f.stopped:
+	case <-time.After(closeTimeout):
+		logrus.Warnf("[events] closing the event publisher with %d events still queued", len(f.queue))
+	}
+	return f.publisher.Close()
+}
+
+// send publishes a single event, logging failures
+func (f *Firehose) send(ctx context.Context, event Event) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		logrus.Warnf("[events] unable to marshal %s event %s: %v", event.Type, event.ID, err)
+		metrics.FirehoseEvents.WithLabelValues("failed").Inc()
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, publishTimeout)
+	defer cancel()
+	if err := f.publisher.Publish(ctx, event, payload); err != nil {
+		logrus.Warnf("[events] unable to publish %s event %s: %v", event.Type, event.ID, err)
+		metrics.FirehoseEvents.WithLabelValues("failed").Inc()
+		return
+	}
+	metrics.FirehoseEvents.WithLabelValues("published").Inc()
+}
@@ -0,0 +1,95 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/rancher/csp-adapter/pkg/audit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recorder records the payloads of published events, failing those of the types in fail
+type recorder struct {
+	payloads [][]byte
+	fail     map[string]bool
+	closed   bool
+}
+
+func (r *recorder) Publish(ctx context.Context, event Event, payload []byte) error {
+	if r.fail[event.Type] {
+		return errors.New("broker unavailable")
+	}
+	r.payloads = append(r.payloads, payload)
+	return nil
+}
+
+func (r *recorder) Close() error {
+	r.closed = true
+	return nil
+}
+
+func TestFirehose(t *testing.T) {
+	rec := &recorder{fail: map[string]bool{TypeCheckIn: true}}
+	firehose := NewFirehose(rec, 3)
+
+	checkout := audit.NewEvent("123456789012", audit.ActionCheckout, nil)
+	checkout.Entitlements = 2
+	require.NoError(t, firehose.Emit(context.Background(), checkout))
+	// failed check-ins are logged, they don't stop the events after them
+	require.NoError(t, firehose.Emit(context.Background(), audit.NewEvent("123456789012", audit.ActionCheckIn, nil)))
+	firehose.Publish(TypeComplianceChanged, "123456789012", ComplianceChange{Status: "Compliant", Reason: "Licensed"})
+	// the queue is full
+	firehose.Publish(TypeConfigChanged, "123456789012", ConfigChange{Kind: ConfigFeatureFlag, Name: "usage-history", Value: "false"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	// queued events are published before Run returns, the publisher is only closed by Close
+	firehose.Run(ctx)
+	assert.False(t, rec.closed)
+	require.NoError(t, firehose.Close())
+	assert.True(t, rec.closed)
+	require.Len(t, rec.payloads, 2)
+
+	var event struct {
+		Event
+		Data json.RawMessage `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rec.payloads[0], &event))
+	assert.Equal(t, TypeCheckout, event.Type)
+	assert.Equal(t, SchemaVersion, event.SchemaVersion)
+	assert.Equal(t, "123456789012", event.Account)
+	assert.NotEmpty(t, event.ID)
+	var data audit.Event
+	require.NoError(t, json.Unmarshal(event.Data, &data))
+	assert.Equal(t, checkout.AuditID, data.AuditID)
+	assert.Equal(t, 2, data.Entitlements)
+
+	require.NoError(t, json.Unmarshal(rec.payloads[1], &event))
+	assert.Equal(t, TypeComplianceChanged, event.Type)
+	var change ComplianceChange
+	require.NoError(t, json.Unmarshal(event.Data, &change))
+	assert.Equal(t, "Licensed", change.Reason)
+}
+
+func TestNilFirehose(t *testing.T) {
+	var firehose *Firehose
+	firehose.Publish(TypeConfigChanged, "123456789012", ConfigChange{})
+}
+
+func TestKafkaSASL(t *testing.T) {
+	for _, mechanism := range []string{SASLPlain, SASLScramSHA256, "SCRAM-SHA-512"} {
+		m, err := KafkaSASL(mechanism, "adapter", "s3cret")
+		require.NoError(t, err, mechanism)
+		assert.NotNil(t, m)
+	}
+	_, err := KafkaSASL("gssapi", "adapter", "s3cret")
+	assert.Error(t, err)
+}
+
+func TestNATSPublisherCredentials(t *testing.T) {
+	_, err := NewNATSPublisher("nats://127.0.0.1:4222", "csp-adapter", NATSOptions{Credentials: []byte("not a credentials file")})
+	assert.Error(t, err, "invalid credentials are refused before connecting")
+}
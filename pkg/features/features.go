@@ -0,0 +1,126 @@
+// Package features holds the feature flags of the adapter. Flags are defined in code with a safe default, so that new
+// behaviors can ship disabled and be enabled per install without a restart. An install sets flags in two sources: the
+// chart's features configmap and the CSPAdapterConfig, which takes precedence (see Set.Update)
+package features
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Flag is a behavior of the adapter which can be toggled at runtime
+type Flag struct {
+	Name        string
+	Description string
+	// Default is used until the flag is set, and when it is set to a value which can't be parsed
+	Default bool
+}
+
+var registry = map[string]Flag{}
+
+// register adds f to the known flags, flags must be registered when the package is initialized
+func register(f Flag) Flag {
+	if _, ok := registry[f.Name]; ok {
+		panic("feature flag " + f.Name + " registered twice")
+	}
+	registry[f.Name] = f
+	return f
+}
+
+var (
+	// UsageHistory persists hourly usage records, which true-up reports are generated from
+	UsageHistory = register(Flag{
+		Name:        "usage-history",
+		Description: "persist hourly usage records for true-up reports",
+		Default:     true,
+	})
+	// AnomalyDetection raises warnings for unusual usage patterns
+	AnomalyDetection = register(Flag{
+		Name:        "anomaly-detection",
+		Description: "warn about node count surges and repeated checkouts",
+		Default:     true,
+	})
+)
+
+// Flags returns every known flag, ordered by name
+func Flags() []Flag {
+	flags := make([]Flag, 0, len(registry))
+	for _, f := range registry {
+		flags = append(flags, f)
+	}
+	sort.Slice(flags, func(i, j int) bool {
+		return flags[i].Name < flags[j].Name
+	})
+	return flags
+}
+
+// Set holds the current value of every flag. The zero value and a nil Set report the defaults
+type Set struct {
+	lock   sync.RWMutex
+	values map[string]bool
+}
+
+func NewSet() *Set {
+	return &Set{}
+}
+
+// Enabled returns whether f is currently enabled
+func (s *Set) Enabled(f Flag) bool {
+	if s == nil {
+		return f.Default
+	}
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return valueOf(s.values, f)
+}
+
+// Update replaces the values of all flags with the ones in sources, by flag name. Sources are given in increasing
+// precedence: a flag takes its value from the last source setting it, and falls back to the sources before when that
+// value isn't a boolean. Flags which no source sets return to their default. Unknown flags and values which aren't
+// booleans are logged and ignored
+func (s *Set) Update(sources ...map[string]string) {
+	values := map[string]bool{}
+	for _, data := range sources {
+		for name, raw := range data {
+			f, ok := registry[name]
+			if !ok {
+				logrus.Warnf("[features] ignoring unknown feature flag %q", name)
+				continue
+			}
+			value, err := strconv.ParseBool(raw)
+			if err != nil {
+				logrus.Warnf("[features] ignoring value %q of feature flag %s, using %t", raw, name, valueOf(values, f))
+				continue
+			}
+			values[name] = value
+		}
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, f := range Flags() {
+		if valueOf(s.values, f) != valueOf(values, f) {
+			logrus.Infof("[features] feature flag %s is now %t", f.Name, valueOf(values, f))
+		}
+	}
+	s.values = values
+}
+
+// All returns the current value of every flag, by name
+func (s *Set) All() map[string]bool {
+	all := map[string]bool{}
+	for _, f := range Flags() {
+		all[f.Name] = s.Enabled(f)
+	}
+	return all
+}
+
+// valueOf returns the value of f in values, or its default if it isn't set
+func valueOf(values map[string]bool, f Flag) bool {
+	if value, ok := values[f.Name]; ok {
+		return value
+	}
+	return f.Default
+}
@@ -0,0 +1,39 @@
+package features
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSet(t *testing.T) {
+	var unset *Set
+	assert.True(t, unset.Enabled(UsageHistory), "a nil set should report defaults")
+
+	s := NewSet()
+	assert.Equal(t, UsageHistory.Default, s.Enabled(UsageHistory))
+
+	s.Update(map[string]string{
+		UsageHistory.Name:     "false",
+		AnomalyDetection.Name: "not a bool",
+		"unknown":             "true",
+	})
+	assert.False(t, s.Enabled(UsageHistory))
+	assert.Equal(t, AnomalyDetection.Default, s.Enabled(AnomalyDetection), "invalid values should fall back to the default")
+	assert.NotContains(t, s.All(), "unknown")
+
+	s.Update(nil)
+	assert.Equal(t, UsageHistory.Default, s.Enabled(UsageHistory), "removed flags should return to their default")
+}
+
+func TestSetPrecedence(t *testing.T) {
+	s := NewSet()
+	configMap := map[string]string{UsageHistory.Name: "false", AnomalyDetection.Name: "false"}
+	config := map[string]string{AnomalyDetection.Name: "true", UsageHistory.Name: "maybe"}
+	s.Update(configMap, config)
+	assert.True(t, s.Enabled(AnomalyDetection), "later sources take precedence")
+	assert.False(t, s.Enabled(UsageHistory), "invalid values fall back to the sources before")
+
+	s.Update(configMap, nil)
+	assert.False(t, s.Enabled(AnomalyDetection), "flags removed from the config fall back to the configmap")
+}
@@ -0,0 +1,246 @@
+package iam
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsiam "github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/sirupsen/logrus"
+)
+
+type iamClient interface {
+	CreatePolicy(ctx context.Context, params *awsiam.CreatePolicyInput, optFns ...func(*awsiam.Options)) (*awsiam.CreatePolicyOutput, error)
+	CreateRole(ctx context.Context, params *awsiam.CreateRoleInput, optFns ...func(*awsiam.Options)) (*awsiam.CreateRoleOutput, error)
+	GetRole(ctx context.Context, params *awsiam.GetRoleInput, optFns ...func(*awsiam.Options)) (*awsiam.GetRoleOutput, error)
+	UpdateAssumeRolePolicy(ctx context.Context, params *awsiam.UpdateAssumeRolePolicyInput, optFns ...func(*awsiam.Options)) (*awsiam.UpdateAssumeRolePolicyOutput, error)
+	AttachRolePolicy(ctx context.Context, params *awsiam.AttachRolePolicyInput, optFns ...func(*awsiam.Options)) (*awsiam.AttachRolePolicyOutput, error)
+	GetPolicy(ctx context.Context, params *awsiam.GetPolicyInput, optFns ...func(*awsiam.Options)) (*awsiam.GetPolicyOutput, error)
+	GetPolicyVersion(ctx context.Context, params *awsiam.GetPolicyVersionInput, optFns ...func(*awsiam.Options)) (*awsiam.GetPolicyVersionOutput, error)
+	ListPolicyVersions(ctx context.Context, params *awsiam.ListPolicyVersionsInput, optFns ...func(*awsiam.Options)) (*awsiam.ListPolicyVersionsOutput, error)
+	CreatePolicyVersion(ctx context.Context, params *awsiam.CreatePolicyVersionInput, optFns ...func(*awsiam.Options)) (*awsiam.CreatePolicyVersionOutput, error)
+	DeletePolicyVersion(ctx context.Context, params *awsiam.DeletePolicyVersionInput, optFns ...func(*awsiam.Options)) (*awsiam.DeletePolicyVersionOutput, error)
+}
+
+// maxPolicyVersions is the number of versions iam keeps of a managed policy, the oldest one has to be deleted before
+// another can be created
+const maxPolicyVersions = 5
+
+// BootstrapOptions describes the iam resources to create
+type BootstrapOptions struct {
+	AccountNumber  string
+	OIDCIssuer     string
+	RoleName       string
+	PolicyName     string
+	Namespace      string
+	ServiceAccount string
+	// WriteRoleName, if set, is the role created for checkouts, check-ins and extensions, trusting the adapter's role.
+	// Its policy is named after PolicyName with a -write suffix
+	WriteRoleName string
+	// Features selects the optional permissions granted by the created policy. Its Partition is the partition the
+	// resources are created in
+	Features Features
+}
+
+// BootstrapResult contains the arns of the iam resources used by the adapter
+type BootstrapResult struct {
+	PolicyARN string
+	RoleARN   string
+	// WritePolicyARN and WriteRoleARN are set if a write role was created
+	WritePolicyARN string
+	WriteRoleARN   string
+}
+
+// Bootstrapper creates the iam policy and role which the adapter needs. It requires credentials which are allowed to
+// manage iam, and should not be run with the adapter's own credentials
+type Bootstrapper struct {
+	iam iamClient
+}
+
+func NewBootstrapper(cfg aws.Config) *Bootstrapper {
+	return &Bootstrapper{
+		iam: awsiam.NewFromConfig(cfg),
+	}
+}
+
+// Bootstrap creates the adapter's policy and role (trusting the service account through IRSA) and attaches the policy
+// to the role. If opts.WriteRoleName is set, the write role is created the same way, trusting the adapter's role, and
+// the adapter's policy only grants read access and permission to assume it. Resources which already exist are reused,
+// and the policies and the roles' trust policies are updated to match opts
+func (b *Bootstrapper) Bootstrap(ctx context.Context, opts BootstrapOptions) (*BootstrapResult, error) {
+	features := opts.Features
+	features.Partition = features.partition()
+	if opts.WriteRoleName != "" {
+		features.WriteRoleARN = RoleARN(features.Partition, opts.AccountNumber, opts.WriteRoleName)
+	}
+	trust := TrustPolicy(features.Partition, opts.AccountNumber, opts.OIDCIssuer, opts.Namespace, opts.ServiceAccount)
+	policyARN, roleARN, err := b.ensureRoleWithPolicy(ctx, PolicyARN(features.Partition, opts.AccountNumber, opts.PolicyName),
+		opts.RoleName, opts.PolicyName, PolicyFor(features), trust)
+	if err != nil {
+		return nil, err
+	}
+	res := &BootstrapResult{
+		PolicyARN: policyARN,
+		RoleARN:   roleARN,
+	}
+	if opts.WriteRoleName == "" {
+		return res, nil
+	}
+	writePolicyName := opts.PolicyName + "-write"
+	res.WritePolicyARN, res.WriteRoleARN, err = b.ensureRoleWithPolicy(ctx, PolicyARN(features.Partition, opts.AccountNumber, writePolicyName),
+		opts.WriteRoleName, writePolicyName, WritePolicy(), WriteTrustPolicy(roleARN))
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// ensureRoleWithPolicy creates the policy, whose arn is existingARN if it already exists, and the role trusting
+// principals through trust, and attaches the policy to the role. Returns the arns of the policy and the role
+func (b *Bootstrapper) ensureRoleWithPolicy(ctx context.Context, existingARN, roleName, policyName string, policy, trust PolicyDocument) (string, string, error) {
+	policyARN, err := b.ensurePolicy(ctx, existingARN, policyName, policy)
+	if err != nil {
+		return "", "", fmt.Errorf("unable to create policy %s: %v", policyName, err)
+	}
+	roleARN, err := b.ensureRole(ctx, roleName, trust)
+	if err != nil {
+		return "", "", fmt.Errorf("unable to create role %s: %v", roleName, err)
+	}
+	_, err = b.iam.AttachRolePolicy(ctx, &awsiam.AttachRolePolicyInput{
+		PolicyArn: &policyARN,
+		RoleName:  &roleName,
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("unable to attach policy %s to role %s: %v", policyARN, roleName, err)
+	}
+	return policyARN, roleARN, nil
+}
+
+func (b *Bootstrapper) ensurePolicy(ctx context.Context, existingARN, policyName string, policy PolicyDocument) (string, error) {
+	document, err := marshalPolicy(policy)
+	if err != nil {
+		return "", err
+	}
+	res, err := b.iam.CreatePolicy(ctx, &awsiam.CreatePolicyInput{
+		PolicyName:     &policyName,
+		PolicyDocument: &document,
+		Description:    aws.String("Permissions required by the rancher csp adapter"),
+	})
+	if err != nil {
+		var exists *types.EntityAlreadyExistsException
+		if errors.As(err, &exists) {
+			return existingARN, b.updatePolicy(ctx, existingARN, document)
+		}
+		return "", err
+	}
+	return *res.Policy.Arn, nil
+}
+
+// updatePolicy sets document as the default version of the existing policy, unless it's the default already. The
+// oldest version which isn't the default is deleted if the policy has as many versions as iam keeps
+func (b *Bootstrapper) updatePolicy(ctx context.Context, policyARN, document string) error {
+	policy, err := b.iam.GetPolicy(ctx, &awsiam.GetPolicyInput{PolicyArn: &policyARN})
+	if err != nil {
+		return err
+	}
+	current, err := b.iam.GetPolicyVersion(ctx, &awsiam.GetPolicyVersionInput{
+		PolicyArn: &policyARN,
+		VersionId: policy.Policy.DefaultVersionId,
+	})
+	if err != nil {
+		return err
+	}
+	if samePolicy(aws.ToString(current.PolicyVersion.Document), document) {
+		logrus.Infof("policy %s already exists and is up to date", policyARN)
+		return nil
+	}
+	versions, err := b.iam.ListPolicyVersions(ctx, &awsiam.ListPolicyVersionsInput{PolicyArn: &policyARN})
+	if err != nil {
+		return err
+	}
+	if len(versions.Versions) >= maxPolicyVersions {
+		sort.Slice(versions.Versions, func(i, j int) bool {
+			return aws.ToTime(versions.Versions[i].CreateDate).Before(aws.ToTime(versions.Versions[j].CreateDate))
+		})
+		for _, version := range versions.Versions {
+			if version.IsDefaultVersion {
+				continue
+			}
+			logrus.Infof("deleting version %s of policy %s, which has %d versions", aws.ToString(version.VersionId), policyARN, len(versions.Versions))
+			_, err = b.iam.DeletePolicyVersion(ctx, &awsiam.DeletePolicyVersionInput{
+				PolicyArn: &policyARN,
+				VersionId: version.VersionId,
+			})
+			if err != nil {
+				return err
+			}
+			break
+		}
+	}
+	logrus.Warnf("policy %s already exists, its permissions will be updated", policyARN)
+	_, err = b.iam.CreatePolicyVersion(ctx, &awsiam.CreatePolicyVersionInput{
+		PolicyArn:      &policyARN,
+		PolicyDocument: &document,
+		SetAsDefault:   true,
+	})
+	return err
+}
+
+// samePolicy returns whether the policy documents grant the same permissions. iam returns documents url encoded (RFC
+// 3986, so a + is a plus rather than a space) and may reformat them, so they're compared after decoding
+func samePolicy(current, document string) bool {
+	if decoded, err := url.PathUnescape(current); err == nil {
+		current = decoded
+	}
+	var a, b PolicyDocument
+	if json.Unmarshal([]byte(current), &a) != nil || json.Unmarshal([]byte(document), &b) != nil {
+		return false
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func (b *Bootstrapper) ensureRole(ctx context.Context, roleName string, trust PolicyDocument) (string, error) {
+	document, err := marshalPolicy(trust)
+	if err != nil {
+		return "", err
+	}
+	res, err := b.iam.CreateRole(ctx, &awsiam.CreateRoleInput{
+		RoleName:                 &roleName,
+		AssumeRolePolicyDocument: &document,
+		Description:              aws.String("Role assumed by the rancher csp adapter"),
+	})
+	if err == nil {
+		return *res.Role.Arn, nil
+	}
+	var exists *types.EntityAlreadyExistsException
+	if !errors.As(err, &exists) {
+		return "", err
+	}
+	logrus.Warnf("role %s already exists, its trust policy will be updated", roleName)
+	_, err = b.iam.UpdateAssumeRolePolicy(ctx, &awsiam.UpdateAssumeRolePolicyInput{
+		RoleName:       &roleName,
+		PolicyDocument: &document,
+	})
+	if err != nil {
+		return "", err
+	}
+	existing, err := b.iam.GetRole(ctx, &awsiam.GetRoleInput{RoleName: &roleName})
+	if err != nil {
+		return "", err
+	}
+	return *existing.Role.Arn, nil
+}
+
+func marshalPolicy(policy PolicyDocument) (string, error) {
+	document, err := json.Marshal(policy)
+	if err != nil {
+		return "", fmt.Errorf("unable to marshal policy: %v", err)
+	}
+	return string(document), nil
+}
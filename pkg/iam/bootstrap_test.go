@@ -0,0 +1,321 @@
+package iam
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsiam "github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const fakeAccountNum = "123456789101"
+
+type mockIAMClient struct {
+	policies       map[string]string
+	versions       map[string][]types.PolicyVersion
+	roles          map[string]string
+	attached       map[string][]string
+	trustPolicyUpd int
+}
+
+func newMockIAMClient() *mockIAMClient {
+	return &mockIAMClient{
+		policies: map[string]string{},
+		versions: map[string][]types.PolicyVersion{},
+		roles:    map[string]string{},
+		attached: map[string][]string{},
+	}
+}
+
+func (m *mockIAMClient) CreatePolicy(ctx context.Context, params *awsiam.CreatePolicyInput, optFns ...func(*awsiam.Options)) (*awsiam.CreatePolicyOutput, error) {
+	if _, ok := m.policies[*params.PolicyName]; ok {
+		return nil, &types.EntityAlreadyExistsException{}
+	}
+	m.policies[*params.PolicyName] = *params.PolicyDocument
+	arn := PolicyARN(DefaultPartition, fakeAccountNum, *params.PolicyName)
+	m.addVersion(arn, *params.PolicyDocument)
+	return &awsiam.CreatePolicyOutput{Policy: &types.Policy{Arn: &arn}}, nil
+}
+
+func (m *mockIAMClient) CreateRole(ctx context.Context, params *awsiam.CreateRoleInput, optFns ...func(*awsiam.Options)) (*awsiam.CreateRoleOutput, error) {
+	if _, ok := m.roles[*params.RoleName]; ok {
+		return nil, &types.EntityAlreadyExistsException{}
+	}
+	m.roles[*params.RoleName] = *params.AssumeRolePolicyDocument
+	return &awsiam.CreateRoleOutput{Role: m.role(*params.RoleName)}, nil
+}
+
+func (m *mockIAMClient) GetRole(ctx context.Context, params *awsiam.GetRoleInput, optFns ...func(*awsiam.Options)) (*awsiam.GetRoleOutput, error) {
+	if _, ok := m.roles[*params.RoleName]; !ok {
+		return nil, &types.NoSuchEntityException{}
+	}
+	return &awsiam.GetRoleOutput{Role: m.role(*params.RoleName)}, nil
+}
+
+func (m *mockIAMClient) UpdateAssumeRolePolicy(ctx context.Context, params *awsiam.UpdateAssumeRolePolicyInput, optFns ...func(*awsiam.Options)) (*awsiam.UpdateAssumeRolePolicyOutput, error) {
+	m.roles[*params.RoleName] = *params.PolicyDocument
+	m.trustPolicyUpd++
+	return &awsiam.UpdateAssumeRolePolicyOutput{}, nil
+}
+
+func (m *mockIAMClient) AttachRolePolicy(ctx context.Context, params *awsiam.AttachRolePolicyInput, optFns ...func(*awsiam.Options)) (*awsiam.AttachRolePolicyOutput, error) {
+	m.attached[*params.RoleName] = append(m.attached[*params.RoleName], *params.PolicyArn)
+	return &awsiam.AttachRolePolicyOutput{}, nil
+}
+
+func (m *mockIAMClient) GetPolicy(ctx context.Context, params *awsiam.GetPolicyInput, optFns ...func(*awsiam.Options)) (*awsiam.GetPolicyOutput, error) {
+	for _, version := range m.versions[*params.PolicyArn] {
+		if version.IsDefaultVersion {
+			return &awsiam.GetPolicyOutput{Policy: &types.Policy{Arn: params.PolicyArn, DefaultVersionId: version.VersionId}}, nil
+		}
+	}
+	return nil, &types.NoSuchEntityException{}
+}
+
+func (m *mockIAMClient) GetPolicyVersion(ctx context.Context, params *awsiam.GetPolicyVersionInput, optFns ...func(*awsiam.Options)) (*awsiam.GetPolicyVersionOutput, error) {
+	for _, version := range m.versions[*params.PolicyArn] {
+		if *version.VersionId == *params.VersionId {
+			version := version
+			return &awsiam.GetPolicyVersionOutput{PolicyVersion: &version}, nil
+		}
+	}
+	return nil, &types.NoSuchEntityException{}
+}
+
+func (m *mockIAMClient) ListPolicyVersions(ctx context.Context, params *awsiam.ListPolicyVersionsInput, optFns ...func(*awsiam.Options)) (*awsiam.ListPolicyVersionsOutput, error) {
+	// versions are listed newest first, as by iam
+	var versions []types.PolicyVersion
+	for i := len(m.versions[*params.PolicyArn]) - 1; i >= 0; i-- {
+		versions = append(versions, m.versions[*params.PolicyArn][i])
+	}
+	return &awsiam.ListPolicyVersionsOutput{Versions: versions}, nil
+}
+
+func (m *mockIAMClient) CreatePolicyVersion(ctx context.Context, params *awsiam.CreatePolicyVersionInput, optFns ...func(*awsiam.Options)) (*awsiam.CreatePolicyVersionOutput, error) {
+	if len(m.versions[*params.PolicyArn]) >= maxPolicyVersions {
+		return nil, &types.LimitExceededException{}
+	}
+	if !params.SetAsDefault {
+		return nil, fmt.Errorf("versions are expected to be set as the default")
+	}
+	m.addVersion(*params.PolicyArn, *params.PolicyDocument)
+	return &awsiam.CreatePolicyVersionOutput{}, nil
+}
+
+func (m *mockIAMClient) DeletePolicyVersion(ctx context.Context, params *awsiam.DeletePolicyVersionInput, optFns ...func(*awsiam.Options)) (*awsiam.DeletePolicyVersionOutput, error) {
+	versions := m.versions[*params.PolicyArn]
+	for i, version := range versions {
+		if *version.VersionId == *params.VersionId {
+			if version.IsDefaultVersion {
+				return nil, &types.DeleteConflictException{}
+			}
+			m.versions[*params.PolicyArn] = append(versions[:i:i], versions[i+1:]...)
+			return &awsiam.DeletePolicyVersionOutput{}, nil
+		}
+	}
+	return nil, &types.NoSuchEntityException{}
+}
+
+// addVersion adds a version of the policy with the document, as the default version. Documents are url encoded, as
+// they're returned by iam
+func (m *mockIAMClient) addVersion(policyARN, document string) {
+	versions := m.versions[policyARN]
+	for i := range versions {
+		versions[i].IsDefaultVersion = false
+	}
+	id := "v1"
+	if len(versions) > 0 {
+		var last int
+		fmt.Sscanf(*versions[len(versions)-1].VersionId, "v%d", &last)
+		id = fmt.Sprintf("v%d", last+1)
+	}
+	m.versions[policyARN] = append(versions, types.PolicyVersion{
+		VersionId:        aws.String(id),
+		Document:         aws.String(url.PathEscape(document)),
+		IsDefaultVersion: true,
+		CreateDate:       aws.Time(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(len(versions)) * time.Hour)),
+	})
+}
+
+// versionIDs returns the ids of the versions of the policy, and the id of its default version
+func (m *mockIAMClient) versionIDs(policyARN string) ([]string, string) {
+	var ids []string
+	var defaultID string
+	for _, version := range m.versions[policyARN] {
+		ids = append(ids, *version.VersionId)
+		if version.IsDefaultVersion {
+			defaultID = *version.VersionId
+		}
+	}
+	return ids, defaultID
+}
+
+func (m *mockIAMClient) role(name string) *types.Role {
+	arn := fmt.Sprintf("arn:aws:iam::%s:role/%s", fakeAccountNum, name)
+	return &types.Role{Arn: &arn, RoleName: &name}
+}
+
+func TestBootstrap(t *testing.T) {
+	opts := BootstrapOptions{
+		AccountNumber:  fakeAccountNum,
+		OIDCIssuer:     "https://oidc.eks.us-east-1.amazonaws.com/id/EXAMPLE",
+		RoleName:       "rancher-csp-adapter",
+		PolicyName:     "rancher-csp-adapter",
+		Namespace:      "cattle-csp-adapter-system",
+		ServiceAccount: "rancher-csp-adapter",
+	}
+	tests := []struct {
+		name           string
+		existingRole   bool
+		existingPolicy bool
+	}{
+		{
+			name: "nothing exists",
+		},
+		{
+			name:           "role and policy exist",
+			existingRole:   true,
+			existingPolicy: true,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			mockIAM := newMockIAMClient()
+			if test.existingPolicy {
+				mockIAM.policies[opts.PolicyName] = "{}"
+				mockIAM.addVersion(PolicyARN(DefaultPartition, fakeAccountNum, opts.PolicyName), "{}")
+			}
+			if test.existingRole {
+				mockIAM.roles[opts.RoleName] = "{}"
+			}
+			b := &Bootstrapper{iam: mockIAM}
+			res, err := b.Bootstrap(context.Background(), opts)
+			assert.NoError(t, err, "expected no error but there was an error")
+			assert.Equal(t, PolicyARN(DefaultPartition, fakeAccountNum, opts.PolicyName), res.PolicyARN)
+			assert.Equal(t, "arn:aws:iam::123456789101:role/rancher-csp-adapter", res.RoleARN)
+			assert.Equal(t, []string{res.PolicyARN}, mockIAM.attached[opts.RoleName], "policy should be attached to the role")
+			_, defaultID := mockIAM.versionIDs(res.PolicyARN)
+			current, err := mockIAM.GetPolicyVersion(context.Background(), &awsiam.GetPolicyVersionInput{PolicyArn: &res.PolicyARN, VersionId: &defaultID})
+			assert.NoError(t, err)
+			document, _ := marshalPolicy(AdapterPolicy())
+			assert.True(t, samePolicy(*current.PolicyVersion.Document, document), "the policy should grant the adapter's permissions")
+			if test.existingRole {
+				assert.Equal(t, 1, mockIAM.trustPolicyUpd, "trust policy of existing role should be updated")
+			}
+
+			var trust PolicyDocument
+			assert.NoError(t, json.Unmarshal([]byte(mockIAM.roles[opts.RoleName]), &trust))
+			assert.Equal(t, "arn:aws:iam::123456789101:oidc-provider/oidc.eks.us-east-1.amazonaws.com/id/EXAMPLE", trust.Statement[0].Principal["Federated"])
+			assert.Equal(t, "system:serviceaccount:cattle-csp-adapter-system:rancher-csp-adapter",
+				trust.Statement[0].Condition["StringEquals"]["oidc.eks.us-east-1.amazonaws.com/id/EXAMPLE:sub"])
+		})
+	}
+}
+
+func TestBootstrapUpdatesPolicy(t *testing.T) {
+	opts := BootstrapOptions{
+		AccountNumber: fakeAccountNum,
+		RoleName:      "rancher-csp-adapter",
+		PolicyName:    "rancher-csp-adapter",
+	}
+	policyARN := PolicyARN(DefaultPartition, fakeAccountNum, opts.PolicyName)
+	mockIAM := newMockIAMClient()
+	mockIAM.policies[opts.PolicyName] = "{}"
+	for i := 0; i < maxPolicyVersions; i++ {
+		mockIAM.addVersion(policyARN, "{}")
+	}
+	b := &Bootstrapper{iam: mockIAM}
+
+	_, err := b.Bootstrap(context.Background(), opts)
+	assert.NoError(t, err)
+	ids, defaultID := mockIAM.versionIDs(policyARN)
+	assert.Equal(t, []string{"v2", "v3", "v4", "v5", "v6"}, ids, "the oldest version should be deleted to make room for the new one")
+	assert.Equal(t, "v6", defaultID)
+
+	_, err = b.Bootstrap(context.Background(), opts)
+	assert.NoError(t, err)
+	ids, defaultID = mockIAM.versionIDs(policyARN)
+	assert.Len(t, ids, maxPolicyVersions, "an up to date policy shouldn't be versioned")
+	assert.Equal(t, "v6", defaultID)
+
+	opts.Features.LicenseTags = true
+	_, err = b.Bootstrap(context.Background(), opts)
+	assert.NoError(t, err)
+	ids, defaultID = mockIAM.versionIDs(policyARN)
+	assert.Equal(t, []string{"v3", "v4", "v5", "v6", "v7"}, ids)
+	assert.Equal(t, "v7", defaultID)
+}
+
+func TestBootstrapWriteRole(t *testing.T) {
+	opts := BootstrapOptions{
+		AccountNumber:  fakeAccountNum,
+		OIDCIssuer:     "https://oidc.eks.us-east-1.amazonaws.com/id/EXAMPLE",
+		RoleName:       "rancher-csp-adapter",
+		PolicyName:     "rancher-csp-adapter",
+		Namespace:      "cattle-csp-adapter-system",
+		ServiceAccount: "rancher-csp-adapter",
+		WriteRoleName:  "rancher-csp-adapter-write",
+	}
+	mockIAM := newMockIAMClient()
+	b := &Bootstrapper{iam: mockIAM}
+	res, err := b.Bootstrap(context.Background(), opts)
+	assert.NoError(t, err)
+	assert.Equal(t, "arn:aws:iam::123456789101:role/rancher-csp-adapter-write", res.WriteRoleARN)
+	assert.Equal(t, PolicyARN(DefaultPartition, fakeAccountNum, "rancher-csp-adapter-write"), res.WritePolicyARN)
+	assert.Equal(t, []string{res.WritePolicyARN}, mockIAM.attached[opts.WriteRoleName], "write policy should be attached to the write role")
+
+	var policy, writePolicy, writeTrust PolicyDocument
+	assert.NoError(t, json.Unmarshal([]byte(mockIAM.policies[opts.PolicyName]), &policy))
+	assert.NoError(t, json.Unmarshal([]byte(mockIAM.policies["rancher-csp-adapter-write"]), &writePolicy))
+	assert.NoError(t, json.Unmarshal([]byte(mockIAM.roles[opts.WriteRoleName]), &writeTrust))
+	assert.Equal(t, PolicyFor(Features{WriteRoleARN: res.WriteRoleARN}), policy, "the adapter should only read and assume the write role")
+	assert.Equal(t, WritePolicy(), writePolicy)
+	assert.Equal(t, res.RoleARN, writeTrust.Statement[0].Principal["AWS"], "the write role should trust the adapter's role")
+}
+
+func TestSamePolicy(t *testing.T) {
+	document := `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":["s3:PutObject"],"Resource":"arn:aws-cn:s3:::a+b/*"}]}`
+	// iam encodes documents like a path, a + stays a plus
+	assert.True(t, samePolicy(url.PathEscape(document), document))
+	assert.True(t, samePolicy(`{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"s3:PutObject","Resource":"arn:aws-cn:s3:::a%2Bb/*"}]}`, document),
+		"a single action may be a string")
+	assert.False(t, samePolicy(url.PathEscape(strings.Replace(document, "a+b", "a b", 1)), document))
+}
+
+func TestBootstrapPartition(t *testing.T) {
+	opts := BootstrapOptions{
+		AccountNumber: fakeAccountNum,
+		OIDCIssuer:    "https://oidc.eks.cn-north-1.amazonaws.com.cn/id/EXAMPLE",
+		RoleName:      "rancher-csp-adapter",
+		PolicyName:    "rancher-csp-adapter",
+		WriteRoleName: "rancher-csp-adapter-write",
+		Features:      Features{S3Bucket: "reports", Partition: "aws-cn"},
+	}
+	mockIAM := newMockIAMClient()
+	mockIAM.policies[opts.PolicyName] = "{}"
+	mockIAM.addVersion(PolicyARN("aws-cn", fakeAccountNum, opts.PolicyName), "{}")
+	res, err := (&Bootstrapper{iam: mockIAM}).Bootstrap(context.Background(), opts)
+	require.NoError(t, err)
+	assert.Equal(t, "arn:aws-cn:iam::123456789101:policy/rancher-csp-adapter", res.PolicyARN, "existing policies are found in the partition")
+
+	var policy, trust PolicyDocument
+	_, defaultID := mockIAM.versionIDs(res.PolicyARN)
+	current, err := mockIAM.GetPolicyVersion(context.Background(), &awsiam.GetPolicyVersionInput{PolicyArn: &res.PolicyARN, VersionId: &defaultID})
+	require.NoError(t, err)
+	document, err := url.PathUnescape(*current.PolicyVersion.Document)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal([]byte(document), &policy))
+	require.NoError(t, json.Unmarshal([]byte(mockIAM.roles[opts.RoleName]), &trust))
+	assert.Equal(t, "arn:aws-cn:iam::123456789101:role/rancher-csp-adapter-write", policy.Statement[1].Resource)
+	assert.Equal(t, "arn:aws-cn:s3:::reports/*", policy.Statement[2].Resource)
+	assert.Equal(t, "arn:aws-cn:iam::123456789101:oidc-provider/oidc.eks.cn-north-1.amazonaws.com.cn/id/EXAMPLE", trust.Statement[0].Principal["Federated"])
+}
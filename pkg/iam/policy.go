@@ -0,0 +1,225 @@
+// Package iam describes and creates the aws iam resources (policy, role and IRSA trust) which the adapter requires
+package iam
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	policyVersion = "2012-10-17"
+	effectAllow   = "Allow"
+	// stsAudience is the audience of the service account tokens projected by EKS for IRSA
+	stsAudience = "sts.amazonaws.com"
+	// DefaultPartition is the partition of the commercial aws regions, used when no other one is set
+	DefaultPartition = "aws"
+)
+
+// PolicyDocument is an iam policy document, as accepted by CreatePolicy and CreateRole
+type PolicyDocument struct {
+	Version   string      `json:"Version"`
+	Statement []Statement `json:"Statement"`
+}
+
+// Statement is a single statement of a PolicyDocument
+type Statement struct {
+	Sid       string                       `json:"Sid,omitempty"`
+	Effect    string                       `json:"Effect"`
+	Principal map[string]string            `json:"Principal,omitempty"`
+	Action    Actions                      `json:"Action"`
+	Resource  string                       `json:"Resource,omitempty"`
+	Condition map[string]map[string]string `json:"Condition,omitempty"`
+}
+
+// Actions are the actions of a statement. iam accepts a single action as a string rather than a list, documents
+// written that way are read as a list of one
+type Actions []string
+
+func (a *Actions) UnmarshalJSON(data []byte) error {
+	var action string
+	if err := json.Unmarshal(data, &action); err == nil {
+		*a = Actions{action}
+		return nil
+	}
+	var actions []string
+	if err := json.Unmarshal(data, &actions); err != nil {
+		return err
+	}
+	*a = actions
+	return nil
+}
+
+// Features describes the optional integrations which require iam permissions beyond license management. Empty values
+// disable the integration, so the zero value describes the minimal policy
+type Features struct {
+	// LicenseTags allows the adapter to read the tags of received licenses, to pick the license pinned by tags
+	LicenseTags bool
+	// UserSubscriptions allows the adapter to subscribe rancher users to products licensed per user
+	UserSubscriptions bool
+	// S3Bucket is the bucket which compliance reports are exported to
+	S3Bucket string
+	// SecretARN is the Secrets Manager secret (or a wildcard arn of several) holding notification credentials
+	SecretARN string
+	// WriteRoleARN is the role assumed for checkouts, check-ins and extensions, see WritePolicy. When set the adapter's
+	// own policy only grants read access to License Manager and permission to assume the role
+	WriteRoleARN string
+	// Partition is the aws partition of the account (i.e. aws-cn or aws-us-gov) which arns are built in,
+	// DefaultPartition if empty
+	Partition string
+}
+
+// partition returns the partition arns are built in
+func (f Features) partition() string {
+	if f.Partition == "" {
+		return DefaultPartition
+	}
+	return f.Partition
+}
+
+// AdapterPolicy returns the permissions policy the adapter's role needs to manage rancher licenses
+func AdapterPolicy() PolicyDocument {
+	return PolicyFor(Features{})
+}
+
+// checkoutActions are the license manager actions changing checkouts, granted to the write role if there is one
+var checkoutActions = []string{
+	"license-manager:CheckoutLicense",
+	"license-manager:ExtendLicenseConsumption",
+	"license-manager:CheckInLicense",
+}
+
+// PolicyFor returns the least privileged permissions policy for the adapter with features enabled. Resources are scoped
+// to the configured integration wherever the service supports it
+func PolicyFor(features Features) PolicyDocument {
+	licenseActions := []string{
+		"license-manager:ListReceivedLicenses",
+		"license-manager:GetLicense",
+		"license-manager:GetLicenseUsage",
+		// the account alias is reported next to the account number
+		"iam:ListAccountAliases",
+	}
+	if features.WriteRoleARN == "" {
+		licenseActions = append(licenseActions, checkoutActions...)
+	}
+	if features.LicenseTags {
+		licenseActions = append(licenseActions, "license-manager:ListTagsForResource")
+	}
+	statements := []Statement{
+		{
+			Sid:      "RancherLicenseManagement",
+			Effect:   effectAllow,
+			Action:   licenseActions,
+			Resource: "*",
+		},
+	}
+	if features.WriteRoleARN != "" {
+		statements = append(statements, Statement{
+			Sid:      "AssumeWriteRole",
+			Effect:   effectAllow,
+			Action:   []string{"sts:AssumeRole"},
+			Resource: features.WriteRoleARN,
+		})
+	}
+	if features.UserSubscriptions {
+		statements = append(statements, Statement{
+			Sid:    "RancherUserSubscriptions",
+			Effect: effectAllow,
+			Action: []string{
+				"license-manager-user-subscriptions:ListProductSubscriptions",
+				"license-manager-user-subscriptions:StartProductSubscription",
+				"license-manager-user-subscriptions:StopProductSubscription",
+			},
+			Resource: "*",
+		})
+	}
+	if features.S3Bucket != "" {
+		statements = append(statements, Statement{
+			Sid:      "ExportReports",
+			Effect:   effectAllow,
+			Action:   []string{"s3:PutObject"},
+			Resource: fmt.Sprintf("arn:%s:s3:::%s/*", features.partition(), features.S3Bucket),
+		})
+	}
+	if features.SecretARN != "" {
+		statements = append(statements, Statement{
+			Sid:      "ReadNotificationSecrets",
+			Effect:   effectAllow,
+			Action:   []string{"secretsmanager:GetSecretValue"},
+			Resource: features.SecretARN,
+		})
+	}
+	return PolicyDocument{
+		Version:   policyVersion,
+		Statement: statements,
+	}
+}
+
+// WritePolicy returns the permissions policy of the write role, which is assumed by the adapter for the calls changing
+// checkouts only
+func WritePolicy() PolicyDocument {
+	return PolicyDocument{
+		Version: policyVersion,
+		Statement: []Statement{
+			{
+				Sid:      "RancherLicenseCheckouts",
+				Effect:   effectAllow,
+				Action:   checkoutActions,
+				Resource: "*",
+			},
+		},
+	}
+}
+
+// WriteTrustPolicy returns the trust policy allowing the adapter's role, adapterRoleARN, to assume the write role
+func WriteTrustPolicy(adapterRoleARN string) PolicyDocument {
+	return PolicyDocument{
+		Version: policyVersion,
+		Statement: []Statement{
+			{
+				Effect:    effectAllow,
+				Principal: map[string]string{"AWS": adapterRoleARN},
+				Action:    []string{"sts:AssumeRole"},
+			},
+		},
+	}
+}
+
+// TrustPolicy returns the trust policy allowing the adapter's service account to assume the role through IRSA.
+// oidcIssuer is the issuer url of the EKS cluster's oidc provider, with or without the https:// prefix
+func TrustPolicy(partition, accountNumber, oidcIssuer, namespace, serviceAccount string) PolicyDocument {
+	issuer := strings.TrimPrefix(oidcIssuer, "https://")
+	return PolicyDocument{
+		Version: policyVersion,
+		Statement: []Statement{
+			{
+				Effect: effectAllow,
+				Principal: map[string]string{
+					"Federated": OIDCProviderARN(partition, accountNumber, issuer),
+				},
+				Action: []string{"sts:AssumeRoleWithWebIdentity"},
+				Condition: map[string]map[string]string{
+					"StringEquals": {
+						issuer + ":sub": fmt.Sprintf("system:serviceaccount:%s:%s", namespace, serviceAccount),
+						issuer + ":aud": stsAudience,
+					},
+				},
+			},
+		},
+	}
+}
+
+// OIDCProviderARN returns the arn of the iam oidc provider registered for oidcIssuer in partition
+func OIDCProviderARN(partition, accountNumber, oidcIssuer string) string {
+	return fmt.Sprintf("arn:%s:iam::%s:oidc-provider/%s", partition, accountNumber, strings.TrimPrefix(oidcIssuer, "https://"))
+}
+
+// RoleARN returns the arn of the role named roleName in partition
+func RoleARN(partition, accountNumber, roleName string) string {
+	return fmt.Sprintf("arn:%s:iam::%s:role/%s", partition, accountNumber, roleName)
+}
+
+// PolicyARN returns the arn of the customer managed policy named policyName in partition
+func PolicyARN(partition, accountNumber, policyName string) string {
+	return fmt.Sprintf("arn:%s:iam::%s:policy/%s", partition, accountNumber, policyName)
+}
@@ -0,0 +1,84 @@
+package iam
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicyFor(t *testing.T) {
+	tests := []struct {
+		name                string
+		features            Features
+		expectedSids        []string
+		expectedResources   []string
+		extraLicenseActions bool
+	}{
+		{
+			name:              "no features",
+			features:          Features{},
+			expectedSids:      []string{"RancherLicenseManagement"},
+			expectedResources: []string{"*"},
+		},
+		{
+			name:                "license features only change license actions",
+			features:            Features{LicenseTags: true},
+			expectedSids:        []string{"RancherLicenseManagement"},
+			expectedResources:   []string{"*"},
+			extraLicenseActions: true,
+		},
+		{
+			name:              "user subscriptions",
+			features:          Features{UserSubscriptions: true},
+			expectedSids:      []string{"RancherLicenseManagement", "RancherUserSubscriptions"},
+			expectedResources: []string{"*", "*"},
+		},
+		{
+			name: "all features scope resources",
+			features: Features{
+				S3Bucket:  "reports",
+				SecretARN: "arn:aws:secretsmanager:us-east-1:123456789101:secret:rancher/*",
+			},
+			expectedSids: []string{"RancherLicenseManagement", "ExportReports", "ReadNotificationSecrets"},
+			expectedResources: []string{
+				"*",
+				"arn:aws:s3:::reports/*",
+				"arn:aws:secretsmanager:us-east-1:123456789101:secret:rancher/*",
+			},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			policy := PolicyFor(test.features)
+			var sids, resources []string
+			for _, statement := range policy.Statement {
+				sids = append(sids, statement.Sid)
+				resources = append(resources, statement.Resource)
+			}
+			assert.Equal(t, test.expectedSids, sids)
+			assert.Equal(t, test.expectedResources, resources)
+			if test.extraLicenseActions {
+				assert.Contains(t, policy.Statement[0].Action, "license-manager:ListTagsForResource")
+			} else {
+				assert.NotContains(t, policy.Statement[0].Action, "license-manager:ListTagsForResource")
+			}
+		})
+	}
+}
+
+func TestPolicyForWriteRole(t *testing.T) {
+	writeRoleARN := RoleARN(DefaultPartition, "123456789101", "rancher-csp-adapter-write")
+	policy := PolicyFor(Features{WriteRoleARN: writeRoleARN})
+	assert.Len(t, policy.Statement, 2)
+	for _, action := range checkoutActions {
+		assert.NotContains(t, policy.Statement[0].Action, action, "checkouts should only be granted to the write role")
+		assert.Contains(t, WritePolicy().Statement[0].Action, action)
+	}
+	assert.Equal(t, Statement{Sid: "AssumeWriteRole", Effect: effectAllow, Action: []string{"sts:AssumeRole"}, Resource: writeRoleARN},
+		policy.Statement[1])
+
+	for _, action := range checkoutActions {
+		assert.Contains(t, PolicyFor(Features{}).Statement[0].Action, action, "checkouts should be granted without a write role")
+	}
+}
@@ -0,0 +1,190 @@
+// Package identity counts the people using rancher, for products which are licensed per user rather than per node
+package identity
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/rancher/csp-adapter/pkg/clients/k8s"
+	"github.com/sirupsen/logrus"
+)
+
+// LocalProvider identifies users by their rancher username rather than a username in an external auth provider
+const LocalProvider = "local"
+
+// User is a person counted towards a per-user license
+type User struct {
+	// ID is the id of the rancher user
+	ID string
+	// Username identifies the user in the auth provider the counter was configured for
+	Username string
+	// Groups are the principal ids of the groups the user is a member of
+	Groups []string
+}
+
+// UserCounter returns the users who consume a seat of a per-user license
+type UserCounter interface {
+	CountUsers(ctx context.Context) ([]User, error)
+}
+
+// RancherUsers counts every enabled rancher user with a username in Provider
+type RancherUsers struct {
+	Client k8s.Client
+	// Provider is the auth provider the users log in through (i.e. activedirectory), or LocalProvider. If empty, the
+	// users of the external auth providers enabled in rancher are counted, which are none if only local users can log in
+	Provider string
+}
+
+func (r RancherUsers) CountUsers(ctx context.Context) ([]User, error) {
+	return providerUsers(r.Client, r.Provider, func(k8s.RancherUser) bool { return true })
+}
+
+// GroupMembers counts the enabled rancher users with a username in Provider who are members of any of Groups. Rancher
+// refreshes group membership from the provider, so this covers active directory and ldap groups as well as the group
+// claims of oidc providers, i.e. activedirectory_group://CN=rancher,OU=groups,DC=example,DC=com or
+// genericoidc_group://rancher-users
+type GroupMembers struct {
+	Client   k8s.Client
+	Provider string
+	Groups   []string
+}
+
+func (g GroupMembers) CountUsers(ctx context.Context) ([]User, error) {
+	return providerUsers(g.Client, g.Provider, func(user k8s.RancherUser) bool {
+		return memberOfAny(user.Groups, g.Groups)
+	})
+}
+
+// providerUsers returns the enabled rancher users with a username in provider which match include
+func providerUsers(client k8s.Client, provider string, include func(k8s.RancherUser) bool) ([]User, error) {
+	providers, err := activeProviders(client, provider)
+	if err != nil {
+		return nil, err
+	}
+	rancherUsers, err := client.GetUsers()
+	if err != nil {
+		return nil, err
+	}
+	var users []User
+	for _, rancherUser := range rancherUsers {
+		username := providerUsername(rancherUser, providers)
+		if !rancherUser.Enabled || username == "" || !include(rancherUser) {
+			continue
+		}
+		users = append(users, User{
+			ID:       rancherUser.ID,
+			Username: username,
+			Groups:   rancherUser.Groups,
+		})
+	}
+	return users, nil
+}
+
+// activeProviders returns the auth providers whose users are counted for the configured provider. Rancher rejects
+// logins through disabled providers, so an empty provider counts the users of the enabled ones, the same people
+// rancher lets in. A configured provider which isn't enabled is still counted, since its users keep their usernames
+func activeProviders(client k8s.Client, provider string) ([]string, error) {
+	if provider == LocalProvider {
+		return []string{LocalProvider}, nil
+	}
+	enabled, err := client.GetEnabledAuthProviders()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get rancher's auth providers: %w", err)
+	}
+	if provider == "" {
+		return enabled, nil
+	}
+	for _, name := range enabled {
+		if name == provider {
+			return []string{provider}, nil
+		}
+	}
+	logrus.Warnf("[identity] counting users of auth provider %s, which isn't enabled in rancher (enabled: %v)", provider, enabled)
+	return []string{provider}, nil
+}
+
+// providerUsername returns the username of user in the first of providers it has one in
+func providerUsername(user k8s.RancherUser, providers []string) string {
+	for _, provider := range providers {
+		username := user.ProviderUsernames[provider]
+		if provider == LocalProvider {
+			username = user.Username
+		}
+		if username != "" {
+			return username
+		}
+	}
+	return ""
+}
+
+// Exclusions are users who never consume a seat, i.e. service accounts and break-glass admins
+type Exclusions struct {
+	// Usernames are glob patterns (as matched by path.Match) of excluded usernames, matched case insensitively
+	Usernames []string
+	// Groups are the principal ids of groups whose members are excluded
+	Groups []string
+}
+
+func (e Exclusions) excludes(user User) bool {
+	username := strings.ToLower(user.Username)
+	for _, pattern := range e.Usernames {
+		if matched, _ := path.Match(strings.ToLower(pattern), username); matched {
+			return true
+		}
+	}
+	return memberOfAny(user.Groups, e.Groups)
+}
+
+// deduplicated combines the users of several counters, counting each person once
+type deduplicated struct {
+	counters   []UserCounter
+	exclusions Exclusions
+}
+
+// NewCounter returns a counter of the users counted by any of counters, without the users matching exclusions. Users
+// are counted once even if several counters include them, or if they have several rancher users with the same
+// username (usernames are case insensitive in the supported providers)
+func NewCounter(exclusions Exclusions, counters ...UserCounter) UserCounter {
+	return &deduplicated{counters: counters, exclusions: exclusions}
+}
+
+func (d *deduplicated) CountUsers(ctx context.Context) ([]User, error) {
+	seenIDs := map[string]bool{}
+	seenUsernames := map[string]bool{}
+	var users []User
+	for _, counter := range d.counters {
+		counted, err := counter.CountUsers(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, user := range counted {
+			username := strings.ToLower(user.Username)
+			if seenIDs[user.ID] || seenUsernames[username] || d.exclusions.excludes(user) {
+				continue
+			}
+			seenIDs[user.ID] = true
+			seenUsernames[username] = true
+			users = append(users, user)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Username < users[j].Username
+	})
+	return users, nil
+}
+
+// memberOfAny returns whether any of userGroups is in groups. Principal ids are matched case insensitively, since
+// ldap distinguished names are
+func memberOfAny(userGroups, groups []string) bool {
+	for _, userGroup := range userGroups {
+		for _, group := range groups {
+			if strings.EqualFold(userGroup, group) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,138 @@
+package identity
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rancher/csp-adapter/pkg/clients/k8s"
+	"github.com/rancher/csp-adapter/pkg/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	engineering = "activedirectory_group://CN=engineering,OU=groups,DC=example,DC=com"
+	serviceAccs = "activedirectory_group://CN=service-accounts,OU=groups,DC=example,DC=com"
+	oidcGroup   = "genericoidc_group://rancher-users"
+)
+
+func usernames(users []User) []string {
+	var names []string
+	for _, user := range users {
+		names = append(names, user.Username)
+	}
+	return names
+}
+
+func TestCountUsers(t *testing.T) {
+	client := mocks.NewMockK8sClient(nil)
+	client.Users = []k8s.RancherUser{
+		{ID: "u-1", Enabled: true, ProviderUsernames: map[string]string{"activedirectory": "alice"}, Groups: []string{engineering}},
+		{ID: "u-2", Enabled: true, ProviderUsernames: map[string]string{"activedirectory": "bob"}},
+		// same person logging in through a second rancher user
+		{ID: "u-3", Enabled: true, ProviderUsernames: map[string]string{"activedirectory": "Alice"}},
+		{ID: "u-4", Enabled: false, ProviderUsernames: map[string]string{"activedirectory": "carol"}, Groups: []string{engineering}},
+		{ID: "u-5", Enabled: true, ProviderUsernames: map[string]string{"activedirectory": "svc-backup"}, Groups: []string{engineering, serviceAccs}},
+		{ID: "u-6", Enabled: true, ProviderUsernames: map[string]string{"genericoidc": "dave"}, Groups: []string{oidcGroup}},
+		{ID: "u-7", Enabled: true, Username: "admin"},
+	}
+	tests := []struct {
+		name       string
+		counter    UserCounter
+		exclusions Exclusions
+		expected   []string
+	}{
+		{
+			name:     "rancher users of a provider",
+			counter:  RancherUsers{Client: client, Provider: "activedirectory"},
+			expected: []string{"alice", "bob", "svc-backup"},
+		},
+		{
+			name:     "local users",
+			counter:  RancherUsers{Client: client, Provider: LocalProvider},
+			expected: []string{"admin"},
+		},
+		{
+			name:     "active directory group members",
+			counter:  GroupMembers{Client: client, Provider: "activedirectory", Groups: []string{"activedirectory_group://cn=engineering,ou=groups,dc=example,dc=com"}},
+			expected: []string{"alice", "svc-backup"},
+		},
+		{
+			name:     "oidc group claims",
+			counter:  GroupMembers{Client: client, Provider: "genericoidc", Groups: []string{oidcGroup}},
+			expected: []string{"dave"},
+		},
+		{
+			name:       "excluded usernames",
+			counter:    RancherUsers{Client: client, Provider: "activedirectory"},
+			exclusions: Exclusions{Usernames: []string{"SVC-*"}},
+			expected:   []string{"alice", "bob"},
+		},
+		{
+			name:       "excluded groups",
+			counter:    RancherUsers{Client: client, Provider: "activedirectory"},
+			exclusions: Exclusions{Groups: []string{serviceAccs}},
+			expected:   []string{"alice", "bob"},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			users, err := NewCounter(test.exclusions, test.counter).CountUsers(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, usernames(users))
+		})
+	}
+}
+
+func TestCountActiveProviderUsers(t *testing.T) {
+	client := mocks.NewMockK8sClient(nil)
+	client.Users = []k8s.RancherUser{
+		{ID: "u-1", Enabled: true, ProviderUsernames: map[string]string{"activedirectory": "alice"}},
+		{ID: "u-2", Enabled: true, ProviderUsernames: map[string]string{"genericoidc": "dave"}},
+		{ID: "u-3", Enabled: true, Username: "admin"},
+	}
+	tests := []struct {
+		name      string
+		providers []string
+		expected  []string
+	}{
+		{
+			name:      "enabled provider",
+			providers: []string{"genericoidc"},
+			expected:  []string{"dave"},
+		},
+		{
+			name:      "several enabled providers",
+			providers: []string{"activedirectory", "genericoidc"},
+			expected:  []string{"alice", "dave"},
+		},
+		{
+			name: "only local users",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			client.AuthProviders = test.providers
+			users, err := RancherUsers{Client: client}.CountUsers(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, usernames(users))
+		})
+	}
+}
+
+func TestCountUsersAcrossCounters(t *testing.T) {
+	client := mocks.NewMockK8sClient(nil)
+	client.Users = []k8s.RancherUser{
+		{ID: "u-1", Enabled: true, ProviderUsernames: map[string]string{"activedirectory": "alice"}, Groups: []string{engineering}},
+		{ID: "u-2", Enabled: true, ProviderUsernames: map[string]string{"activedirectory": "bob"}},
+	}
+	counter := NewCounter(Exclusions{},
+		GroupMembers{Client: client, Provider: "activedirectory", Groups: []string{engineering}},
+		RancherUsers{Client: client, Provider: "activedirectory"},
+	)
+	users, err := counter.CountUsers(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"alice", "bob"}, usernames(users), "users counted by several counters are counted once")
+}
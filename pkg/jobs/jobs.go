@@ -0,0 +1,245 @@
+// Package jobs runs long operations started through the admin api in the background, so that handlers can return a job
+// id immediately instead of blocking until the operation finishes
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/metrics"
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/sirupsen/logrus"
+)
+
+// Func is an operation run by a job. It should report progress using progress and stop when ctx is cancelled
+type Func func(ctx context.Context, progress func(message string)) error
+
+// ErrQueueFull is returned by Submit when too many jobs are waiting to run
+var ErrQueueFull = errors.New("too many jobs are queued, try again later")
+
+// Options configures how many jobs run and how failed jobs are retried
+type Options struct {
+	// Workers is the number of jobs run concurrently
+	Workers int
+	// QueueSize is the number of jobs which can wait for a worker before Submit refuses new jobs
+	QueueSize int
+	// MaxAttempts is the number of times a failing job is started before it is marked as failed
+	MaxAttempts int
+	// RetryDelay is the time waited before a failed attempt is retried
+	RetryDelay time.Duration
+	// Retention is the number of finished jobs kept so that their outcome can still be retrieved
+	Retention int
+}
+
+// DefaultOptions are the options used by the adapter
+var DefaultOptions = Options{
+	Workers:     1,
+	QueueSize:   10,
+	MaxAttempts: 3,
+	RetryDelay:  10 * time.Second,
+	Retention:   50,
+}
+
+type job struct {
+	sdk.Job
+	fn Func
+}
+
+// Runner queues submitted jobs and runs them on a fixed number of workers
+type Runner struct {
+	opts  Options
+	queue chan *job
+
+	lock sync.RWMutex
+	jobs map[string]*job
+}
+
+func NewRunner(opts Options) *Runner {
+	if opts.Workers < 1 {
+		opts.Workers = 1
+	}
+	if opts.MaxAttempts < 1 {
+		opts.MaxAttempts = 1
+	}
+	return &Runner{
+		opts:  opts,
+		queue: make(chan *job, opts.QueueSize),
+		jobs:  map[string]*job{},
+	}
+}
+
+// Run starts the workers, which run queued jobs until ctx is cancelled
+func (r *Runner) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < r.opts.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case j := <-r.queue:
+					r.run(ctx, j)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// call runs an attempt of j. A panic fails the attempt like an error, so that a faulty job doesn't take down the
+// adapter
+func (r *Runner) call(ctx context.Context, j *job) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			logrus.Errorf("[jobs] %s job %s panicked: %v\n%s", j.Kind, j.ID, p, debug.Stack())
+			err = fmt.Errorf("job panicked: %v", p)
+		}
+	}()
+	return j.fn(ctx, func(message string) {
+		r.update(j, func(job *sdk.Job) { job.Progress = message })
+	})
+}
+
+// Submit queues fn to be run as a job of the given kind, returning the job as it was queued. requestedBy describes who
+// started the job, so that manual operations can be traced back to a person
+func (r *Runner) Submit(kind, requestedBy string, fn Func) (sdk.Job, error) {
+	id, err := newID()
+	if err != nil {
+		return sdk.Job{}, fmt.Errorf("unable to generate job id: %w", err)
+	}
+	j := &job{
+		Job: sdk.Job{
+			ID:          id,
+			Kind:        kind,
+			RequestedBy: requestedBy,
+			State:       sdk.JobStatePending,
+			CreatedAt:   time.Now(),
+		},
+		fn: fn,
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	select {
+	case r.queue <- j:
+	default:
+		return sdk.Job{}, ErrQueueFull
+	}
+	r.jobs[id] = j
+	r.prune()
+	logrus.Infof("[jobs] %s started %s job %s", requestedBy, kind, id)
+	return j.Job, nil
+}
+
+// Get returns the job with the given id, if it is still known
+func (r *Runner) Get(id string) (sdk.Job, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	j, ok := r.jobs[id]
+	if !ok {
+		return sdk.Job{}, false
+	}
+	return j.Job, true
+}
+
+// List returns all known jobs, most recently created first
+func (r *Runner) List() []sdk.Job {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	jobs := make([]sdk.Job, 0, len(r.jobs))
+	for _, j := range r.jobs {
+		jobs = append(jobs, j.Job)
+	}
+	sort.Slice(jobs, func(i, k int) bool {
+		return jobs[i].CreatedAt.After(jobs[k].CreatedAt)
+	})
+	return jobs
+}
+
+// run runs j, retrying it until it succeeds, runs out of attempts or ctx is cancelled
+func (r *Runner) run(ctx context.Context, j *job) {
+	for {
+		r.update(j, func(job *sdk.Job) {
+			job.State = sdk.JobStateRunning
+			job.Attempts++
+			if job.StartedAt == nil {
+				job.StartedAt = sdk.OptionalTime(time.Now())
+			}
+		})
+		err := r.call(ctx, j)
+		if err == nil {
+			r.update(j, func(job *sdk.Job) {
+				job.State = sdk.JobStateSucceeded
+				job.Error = ""
+				job.FinishedAt = sdk.OptionalTime(time.Now())
+			})
+			return
+		}
+		logrus.Warnf("[jobs] %s job %s failed attempt %d: %v", j.Kind, j.ID, j.Attempts, err)
+		retry := j.Attempts < r.opts.MaxAttempts && ctx.Err() == nil
+		r.update(j, func(job *sdk.Job) {
+			job.Error = err.Error()
+			if retry {
+				job.State = sdk.JobStatePending
+				return
+			}
+			job.State = sdk.JobStateFailed
+			job.FinishedAt = sdk.OptionalTime(time.Now())
+		})
+		if !retry {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			r.update(j, func(job *sdk.Job) {
+				job.State = sdk.JobStateFailed
+				job.FinishedAt = sdk.OptionalTime(time.Now())
+			})
+			return
+		case <-time.After(r.opts.RetryDelay):
+		}
+	}
+}
+
+// update applies change to j while holding the lock, so that readers never see a partially updated job
+func (r *Runner) update(j *job, change func(job *sdk.Job)) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	change(&j.Job)
+}
+
+// prune drops the oldest finished jobs beyond the configured retention. Must be called while holding the lock
+func (r *Runner) prune() {
+	var finished []*job
+	for _, j := range r.jobs {
+		if j.Done() {
+			finished = append(finished, j)
+		}
+	}
+	drop := metrics.Retain("finished_jobs", len(finished), r.opts.Retention)
+	if drop == 0 {
+		return
+	}
+	sort.Slice(finished, func(i, k int) bool {
+		return finished[i].FinishedAt.Before(*finished[k].FinishedAt)
+	})
+	for _, j := range finished[:drop] {
+		delete(r.jobs, j.ID)
+	}
+}
+
+func newID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
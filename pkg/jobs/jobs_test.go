@@ -0,0 +1,99 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunner(t *testing.T) {
+	tests := []struct {
+		name             string
+		failures         int
+		maxAttempts      int
+		expectedState    string
+		expectedAttempts int
+	}{
+		{name: "succeeds", failures: 0, maxAttempts: 3, expectedState: sdk.JobStateSucceeded, expectedAttempts: 1},
+		{name: "succeeds after retry", failures: 2, maxAttempts: 3, expectedState: sdk.JobStateSucceeded, expectedAttempts: 3},
+		{name: "out of attempts", failures: 3, maxAttempts: 3, expectedState: sdk.JobStateFailed, expectedAttempts: 3},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			runner := NewRunner(Options{Workers: 1, QueueSize: 1, MaxAttempts: test.maxAttempts, Retention: 1})
+			go runner.Run(ctx)
+
+			attempts := 0
+			job, err := runner.Submit("test", "tester", func(ctx context.Context, progress func(message string)) error {
+				attempts++
+				progress(fmt.Sprintf("attempt %d", attempts))
+				if attempts <= test.failures {
+					return fmt.Errorf("failure %d", attempts)
+				}
+				return nil
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, sdk.JobStatePending, job.State)
+
+			assert.Eventually(t, func() bool {
+				job, _ = runner.Get(job.ID)
+				return job.Done()
+			}, 5*time.Second, 10*time.Millisecond)
+			assert.Equal(t, test.expectedState, job.State)
+			assert.Equal(t, test.expectedAttempts, job.Attempts)
+			assert.Equal(t, fmt.Sprintf("attempt %d", test.expectedAttempts), job.Progress)
+			if test.expectedState == sdk.JobStateFailed {
+				assert.NotEmpty(t, job.Error)
+			} else {
+				assert.Empty(t, job.Error)
+			}
+		})
+	}
+}
+
+func TestRunnerQueueFull(t *testing.T) {
+	// workers aren't started, so submitted jobs stay queued
+	runner := NewRunner(Options{QueueSize: 1})
+	noop := func(ctx context.Context, progress func(message string)) error { return nil }
+	_, err := runner.Submit("test", "tester", noop)
+	assert.NoError(t, err)
+	_, err = runner.Submit("test", "tester", noop)
+	assert.ErrorIs(t, err, ErrQueueFull)
+	assert.Len(t, runner.List(), 1)
+}
+
+func TestRunnerRecoversPanics(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	runner := NewRunner(Options{Workers: 1, QueueSize: 1, MaxAttempts: 1, Retention: 2})
+	go runner.Run(ctx)
+
+	failed, err := runner.Submit("test", "tester", func(ctx context.Context, progress func(message string)) error {
+		panic("exporter bug")
+	})
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		failed, _ = runner.Get(failed.ID)
+		return failed.Done()
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, sdk.JobStateFailed, failed.State)
+	assert.Equal(t, "job panicked: exporter bug", failed.Error)
+
+	// the worker survived the panic
+	succeeded, err := runner.Submit("test", "tester", func(ctx context.Context, progress func(message string)) error {
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		succeeded, _ = runner.Get(succeeded.ID)
+		return succeeded.Done()
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, sdk.JobStateSucceeded, succeeded.State)
+}
@@ -0,0 +1,258 @@
+// Package localcache keeps the per-cluster state and usage samples of large fleets (i.e. managed service providers with
+// thousands of clusters) in an embedded SQLite database instead of in memory. The database is a cache, it may be deleted at any
+// time and is rebuilt by the following compliance checks
+package localcache
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/rancher/csp-adapter/pkg/usage"
+	// registers the pure go sqlite driver, the adapter is built without cgo
+	_ "modernc.org/sqlite"
+)
+
+// schemaVersion is stored as the database's user_version. Databases of another version are recreated, since they only
+// hold cached state
+const schemaVersion = 2
+
+var schema = []string{
+	`CREATE TABLE node_counts (
+		cluster_id TEXT PRIMARY KEY,
+		nodes      INTEGER NOT NULL,
+		counted_at INTEGER NOT NULL
+	)`,
+	`CREATE INDEX node_counts_counted_at ON node_counts (counted_at)`,
+	`CREATE TABLE cluster_summaries (
+		cluster_id   TEXT PRIMARY KEY,
+		summary      TEXT NOT NULL,
+		published_at INTEGER NOT NULL
+	)`,
+	`CREATE INDEX cluster_summaries_published_at ON cluster_summaries (published_at)`,
+	`CREATE TABLE usage_history (
+		month TEXT PRIMARY KEY,
+		data  BLOB NOT NULL
+	)`,
+}
+
+// SQLite is a cache of the node count of each cluster, the summary last published to it and the months of usage
+// samples. It implements metrics.NodeCountCache and manager.SummaryCache, see UsageHistory for the usage samples
+type SQLite struct {
+	db  *sql.DB
+	now func() time.Time
+}
+
+// Open opens the cache at path, creating it if it doesn't exist. ":memory:" opens a cache which isn't persisted
+func Open(path string) (*SQLite, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	// sqlite serializes writes, a single connection avoids failing them with SQLITE_BUSY
+	db.SetMaxOpenConns(1)
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("unable to create the local cache at %s: %w", path, err)
+	}
+	return &SQLite{db: db, now: time.Now}, nil
+}
+
+// migrate creates the schema, dropping the tables of another schema version
+func migrate(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		return err
+	}
+	if version == schemaVersion {
+		return nil
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, table := range []string{"node_counts", "cluster_summaries", "usage_history"} {
+		if _, err := tx.Exec(`DROP TABLE IF EXISTS ` + table); err != nil {
+			return err
+		}
+	}
+	for _, statement := range schema {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, schemaVersion)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Close closes the database
+func (c *SQLite) Close() error {
+	return c.db.Close()
+}
+
+func (c *SQLite) LastNodeCount(clusterID string) (int, bool, error) {
+	var nodes int
+	err := c.db.QueryRow(`SELECT nodes FROM node_counts WHERE cluster_id = ?`, clusterID).Scan(&nodes)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return nodes, true, nil
+}
+
+func (c *SQLite) ReplaceNodeCounts(counts map[string]int) error {
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	countedAt := c.now().UnixNano()
+	upsert, err := tx.Prepare(`INSERT INTO node_counts (cluster_id, nodes, counted_at) VALUES (?, ?, ?)
+		ON CONFLICT (cluster_id) DO UPDATE SET nodes = excluded.nodes, counted_at = excluded.counted_at`)
+	if err != nil {
+		return err
+	}
+	defer upsert.Close()
+	for clusterID, nodes := range counts {
+		if _, err := upsert.Exec(clusterID, nodes, countedAt); err != nil {
+			return err
+		}
+	}
+	// every cluster in counts was just written, the others were removed
+	if _, err := tx.Exec(`DELETE FROM node_counts WHERE counted_at < ?`, countedAt); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (c *SQLite) PublishedSummary(clusterID string) (sdk.ClusterSummary, time.Time, bool, error) {
+	var data string
+	var publishedAt int64
+	err := c.db.QueryRow(`SELECT summary, published_at FROM cluster_summaries WHERE cluster_id = ?`, clusterID).Scan(&data, &publishedAt)
+	if err == sql.ErrNoRows {
+		return sdk.ClusterSummary{}, time.Time{}, false, nil
+	}
+	if err != nil {
+		return sdk.ClusterSummary{}, time.Time{}, false, err
+	}
+	var summary sdk.ClusterSummary
+	if err := json.Unmarshal([]byte(data), &summary); err != nil {
+		return sdk.ClusterSummary{}, time.Time{}, false, err
+	}
+	return summary, time.Unix(0, publishedAt), true, nil
+}
+
+func (c *SQLite) RecordSummary(summary sdk.ClusterSummary, at time.Time) error {
+	data, err := json.Marshal(summary)
+	if err != nil {
+		return err
+	}
+	_, err = c.db.Exec(`INSERT INTO cluster_summaries (cluster_id, summary, published_at) VALUES (?, ?, ?)
+		ON CONFLICT (cluster_id) DO UPDATE SET summary = excluded.summary, published_at = excluded.published_at`,
+		summary.ClusterID, string(data), at.UnixNano())
+	return err
+}
+
+func (c *SQLite) RetainSummaries(clusters map[string]int) error {
+	rows, err := c.db.Query(`SELECT cluster_id FROM cluster_summaries`)
+	if err != nil {
+		return err
+	}
+	var removed []string
+	for rows.Next() {
+		var clusterID string
+		if err := rows.Scan(&clusterID); err != nil {
+			rows.Close()
+			return err
+		}
+		if _, ok := clusters[clusterID]; !ok {
+			removed = append(removed, clusterID)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(removed) == 0 {
+		return nil
+	}
+	tx, err := c.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, clusterID := range removed {
+		if _, err := tx.Exec(`DELETE FROM cluster_summaries WHERE cluster_id = ?`, clusterID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// UsageHistory returns store with the months of usage samples it holds cached, so that recording a sample doesn't
+// read the month back from store every time. Writes go to store first, the cache only holds what store accepted
+func (c *SQLite) UsageHistory(store usage.Store) usage.Store {
+	return &usageHistory{cache: c, store: store}
+}
+
+// usageHistory is a write-through cache of a usage.Store
+type usageHistory struct {
+	cache *SQLite
+	store usage.Store
+}
+
+func (u *usageHistory) GetUsageHistory(month string) ([]byte, error) {
+	var data []byte
+	err := u.cache.db.QueryRow(`SELECT data FROM usage_history WHERE month = ?`, month).Scan(&data)
+	if err == nil {
+		return data, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+	data, err = u.store.GetUsageHistory(month)
+	if err != nil || data == nil {
+		return data, err
+	}
+	return data, u.cacheMonth(month, data)
+}
+
+func (u *usageHistory) UpdateUsageHistory(month string, data []byte) error {
+	if err := u.store.UpdateUsageHistory(month, data); err != nil {
+		// the store may or may not have been updated, the next read gets the month from it again
+		if forgetErr := u.forget(month); forgetErr != nil {
+			return fmt.Errorf("%w, and unable to drop the cached month: %v", err, forgetErr)
+		}
+		return err
+	}
+	return u.cacheMonth(month, data)
+}
+
+func (u *usageHistory) ListUsageHistory() ([]string, error) {
+	return u.store.ListUsageHistory()
+}
+
+func (u *usageHistory) DeleteUsageHistory(month string) error {
+	if err := u.forget(month); err != nil {
+		return err
+	}
+	return u.store.DeleteUsageHistory(month)
+}
+
+func (u *usageHistory) cacheMonth(month string, data []byte) error {
+	_, err := u.cache.db.Exec(`INSERT INTO usage_history (month, data) VALUES (?, ?)
+		ON CONFLICT (month) DO UPDATE SET data = excluded.data`, month, data)
+	return err
+}
+
+func (u *usageHistory) forget(month string) error {
+	_, err := u.cache.db.Exec(`DELETE FROM usage_history WHERE month = ?`, month)
+	return err
+}
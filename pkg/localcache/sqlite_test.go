@@ -0,0 +1,142 @@
+package localcache
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNodeCounts(t *testing.T) {
+	cache, err := Open(":memory:")
+	require.NoError(t, err)
+	defer cache.Close()
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time { return now }
+
+	_, ok, err := cache.LastNodeCount("c-abcde")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, cache.ReplaceNodeCounts(map[string]int{"c-abcde": 10, "c-fghij": 20}))
+	nodes, ok, err := cache.LastNodeCount("c-fghij")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 20, nodes)
+
+	now = now.Add(time.Minute)
+	require.NoError(t, cache.ReplaceNodeCounts(map[string]int{"c-abcde": 15}))
+	nodes, ok, err = cache.LastNodeCount("c-abcde")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 15, nodes)
+	_, ok, err = cache.LastNodeCount("c-fghij")
+	require.NoError(t, err)
+	assert.False(t, ok, "removed clusters should be forgotten")
+}
+
+func TestSummaries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.db")
+	cache, err := Open(path)
+	require.NoError(t, err)
+	publishedAt := time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+	summary := sdk.ClusterSummary{ClusterID: "c-abcde", Nodes: 10, TotalNodes: 30, Environment: sdk.EnvironmentProduction}
+	require.NoError(t, cache.RecordSummary(summary, publishedAt))
+	require.NoError(t, cache.RecordSummary(sdk.ClusterSummary{ClusterID: "c-fghij", Nodes: 20}, publishedAt))
+	require.NoError(t, cache.Close())
+
+	// the cache is kept across restarts
+	cache, err = Open(path)
+	require.NoError(t, err)
+	defer cache.Close()
+	cached, at, ok, err := cache.PublishedSummary("c-abcde")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, summary, cached)
+	assert.True(t, publishedAt.Equal(at))
+
+	require.NoError(t, cache.RetainSummaries(map[string]int{"c-abcde": 10}))
+	_, _, ok, err = cache.PublishedSummary("c-fghij")
+	require.NoError(t, err)
+	assert.False(t, ok, "removed clusters should be forgotten")
+	_, _, ok, err = cache.PublishedSummary("c-abcde")
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestSchemaVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.db")
+	cache, err := Open(path)
+	require.NoError(t, err)
+	require.NoError(t, cache.ReplaceNodeCounts(map[string]int{"c-abcde": 10}))
+	_, err = cache.db.Exec(`PRAGMA user_version = 0`)
+	require.NoError(t, err)
+	require.NoError(t, cache.Close())
+
+	// a cache of another schema version is recreated
+	cache, err = Open(path)
+	require.NoError(t, err)
+	defer cache.Close()
+	_, ok, err := cache.LastNodeCount("c-abcde")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+// countingStore is a usage store which counts its reads
+type countingStore struct {
+	months map[string][]byte
+	reads  int
+}
+
+func (s *countingStore) GetUsageHistory(month string) ([]byte, error) {
+	s.reads++
+	return s.months[month], nil
+}
+
+func (s *countingStore) UpdateUsageHistory(month string, data []byte) error {
+	s.months[month] = data
+	return nil
+}
+
+func (s *countingStore) ListUsageHistory() ([]string, error) {
+	var months []string
+	for month := range s.months {
+		months = append(months, month)
+	}
+	return months, nil
+}
+
+func (s *countingStore) DeleteUsageHistory(month string) error {
+	delete(s.months, month)
+	return nil
+}
+
+func TestUsageHistory(t *testing.T) {
+	cache, err := Open(":memory:")
+	require.NoError(t, err)
+	defer cache.Close()
+	store := &countingStore{months: map[string][]byte{"2022-01": []byte(`[]`)}}
+	history := cache.UsageHistory(store)
+
+	for i := 0; i < 3; i++ {
+		data, err := history.GetUsageHistory("2022-01")
+		require.NoError(t, err)
+		assert.Equal(t, `[]`, string(data))
+	}
+	assert.Equal(t, 1, store.reads, "the month should be read from the store once")
+
+	require.NoError(t, history.UpdateUsageHistory("2022-02", []byte(`[{}]`)))
+	data, err := history.GetUsageHistory("2022-02")
+	require.NoError(t, err)
+	assert.Equal(t, `[{}]`, string(data))
+	assert.Equal(t, `[{}]`, string(store.months["2022-02"]), "writes should go through to the store")
+	assert.Equal(t, 1, store.reads)
+
+	require.NoError(t, history.DeleteUsageHistory("2022-02"))
+	data, err = history.GetUsageHistory("2022-02")
+	require.NoError(t, err)
+	assert.Nil(t, data)
+}
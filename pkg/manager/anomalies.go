@@ -0,0 +1,107 @@
+package manager
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/metrics"
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// anomalyWindow is the period of usage history which anomalies are detected in
+	anomalyWindow = 1 * time.Hour
+	// surgeMinimumNodes is the node count below which growth isn't considered a surge, so that small clusters adding a
+	// few nodes don't raise warnings
+	surgeMinimumNodes = nodesPerLicense
+	// churnCheckouts is the number of checkouts within the window which is considered churn. Every checkout after the
+	// first also checks in the previous licenses
+	churnCheckouts = 4
+)
+
+type usageSample struct {
+	nodes      int
+	observedAt time.Time
+}
+
+// usageHistory holds the samples and checkouts observed within the anomaly window, and the anomalies raised for them
+type usageHistory struct {
+	samples   []usageSample
+	checkouts []time.Time
+	active    map[string]sdk.Anomaly
+}
+
+// detectAnomalies adds the usage observed by a compliance check to the history and raises warnings for anomalies which
+// started with this observation. Must be called while holding the checkLock
+func (m *AWS) detectAnomalies(nodes int, checkedOut bool, now time.Time) {
+	h := &m.history
+	h.samples = append(pruneSamples(h.samples, now), usageSample{nodes: nodes, observedAt: now})
+	h.samples = h.samples[metrics.Retain(retainedUsageSamples, len(h.samples), m.maxSamples()):]
+	h.checkouts = pruneTimes(h.checkouts, now)
+	if checkedOut {
+		h.checkouts = append(h.checkouts, now)
+	}
+	h.checkouts = h.checkouts[metrics.Retain(retainedCheckouts, len(h.checkouts), m.maxSamples()):]
+
+	detected := map[string]sdk.Anomaly{}
+	lowest := h.samples[0]
+	for _, sample := range h.samples {
+		if sample.nodes < lowest.nodes {
+			lowest = sample
+		}
+	}
+	if lowest.nodes > 0 && nodes >= surgeMinimumNodes && nodes >= 2*lowest.nodes {
+		detected[sdk.AnomalyNodeSurge] = sdk.Anomaly{
+			Type: sdk.AnomalyNodeSurge,
+			Message: fmt.Sprintf("node count grew from %d to %d since %s, check for runaway autoscalers",
+				lowest.nodes, nodes, lowest.observedAt.Format(time.RFC3339)),
+		}
+	}
+	if len(h.checkouts) >= churnCheckouts {
+		detected[sdk.AnomalyCheckoutChurn] = sdk.Anomaly{
+			Type: sdk.AnomalyCheckoutChurn,
+			Message: fmt.Sprintf("licenses were checked out %d times within %s, node counts may be fluctuating",
+				len(h.checkouts), anomalyWindow),
+		}
+	}
+
+	for anomalyType, anomaly := range detected {
+		if previous, ok := h.active[anomalyType]; ok {
+			// still the same anomaly, only warn when it starts
+			anomaly.DetectedAt = previous.DetectedAt
+		} else {
+			anomaly.DetectedAt = now
+			logrus.Warnf("[manager] usage anomaly detected: %s", anomaly.Message)
+			metrics.UsageAnomalies.WithLabelValues(anomalyType).Inc()
+		}
+		detected[anomalyType] = anomaly
+	}
+	h.active = detected
+
+	anomalies := make([]sdk.Anomaly, 0, len(detected))
+	for _, anomalyType := range []string{sdk.AnomalyNodeSurge, sdk.AnomalyCheckoutChurn} {
+		if anomaly, ok := detected[anomalyType]; ok {
+			anomalies = append(anomalies, anomaly)
+		}
+	}
+	m.statusLock.Lock()
+	defer m.statusLock.Unlock()
+	m.status.Anomalies = anomalies
+}
+
+// pruneSamples drops samples which are older than the anomaly window
+func pruneSamples(samples []usageSample, now time.Time) []usageSample {
+	for len(samples) > 0 && now.Sub(samples[0].observedAt) > anomalyWindow {
+		samples = samples[1:]
+	}
+	return samples
+}
+
+// pruneTimes drops times which are older than the anomaly window
+func pruneTimes(times []time.Time, now time.Time) []time.Time {
+	for len(times) > 0 && now.Sub(times[0]) > anomalyWindow {
+		times = times[1:]
+	}
+	return times
+}
@@ -0,0 +1,134 @@
+package manager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/clients/k8s"
+	"github.com/rancher/csp-adapter/pkg/features"
+	"github.com/rancher/csp-adapter/pkg/mocks"
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectAnomalies(t *testing.T) {
+	type observation struct {
+		after      time.Duration
+		nodes      int
+		checkedOut bool
+	}
+	tests := []struct {
+		name              string
+		observations      []observation
+		expectedAnomalies []string
+	}{
+		{
+			name: "steady usage",
+			observations: []observation{
+				{after: 0, nodes: 30, checkedOut: true},
+				{after: 10 * time.Minute, nodes: 32},
+				{after: 20 * time.Minute, nodes: 35},
+			},
+		},
+		{
+			name: "node count doubles within the window",
+			observations: []observation{
+				{after: 0, nodes: 30, checkedOut: true},
+				{after: 30 * time.Minute, nodes: 45, checkedOut: true},
+				{after: 50 * time.Minute, nodes: 60},
+			},
+			expectedAnomalies: []string{sdk.AnomalyNodeSurge},
+		},
+		{
+			name: "node count doubles slower than the window",
+			observations: []observation{
+				{after: 0, nodes: 30, checkedOut: true},
+				{after: 50 * time.Minute, nodes: 45, checkedOut: true},
+				{after: 100 * time.Minute, nodes: 60, checkedOut: true},
+			},
+		},
+		{
+			name: "small clusters growing",
+			observations: []observation{
+				{after: 0, nodes: 3, checkedOut: true},
+				{after: 10 * time.Minute, nodes: 9},
+			},
+		},
+		{
+			name: "repeated checkouts",
+			observations: []observation{
+				{after: 0, nodes: 19, checkedOut: true},
+				{after: 10 * time.Minute, nodes: 21, checkedOut: true},
+				{after: 20 * time.Minute, nodes: 19, checkedOut: true},
+				{after: 30 * time.Minute, nodes: 21, checkedOut: true},
+			},
+			expectedAnomalies: []string{sdk.AnomalyCheckoutChurn},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			mockAWS := NewAWS(mocks.NewMockAWSClient(5), mocks.NewMockK8sClient(nil), mocks.NewMockScraper(0), Options{})
+			start := time.Now()
+			for _, o := range test.observations {
+				mockAWS.detectAnomalies(o.nodes, o.checkedOut, start.Add(o.after))
+			}
+			var anomalies []string
+			for _, anomaly := range mockAWS.Status().Anomalies {
+				anomalies = append(anomalies, anomaly.Type)
+			}
+			assert.Equal(t, test.expectedAnomalies, anomalies)
+		})
+	}
+}
+
+func TestDetectAnomaliesKeepsDetectionTime(t *testing.T) {
+	mockAWS := NewAWS(mocks.NewMockAWSClient(5), mocks.NewMockK8sClient(nil), mocks.NewMockScraper(0), Options{})
+	start := time.Now()
+	mockAWS.detectAnomalies(20, false, start)
+	mockAWS.detectAnomalies(40, false, start.Add(10*time.Minute))
+	mockAWS.detectAnomalies(45, false, start.Add(20*time.Minute))
+	anomalies := mockAWS.Status().Anomalies
+	assert.Len(t, anomalies, 1)
+	assert.Equal(t, start.Add(10*time.Minute), anomalies[0].DetectedAt, "ongoing anomaly should keep the time it was first detected")
+
+	// the surge is over once the lowest sample leaves the window
+	mockAWS.detectAnomalies(45, false, start.Add(90*time.Minute))
+	assert.Empty(t, mockAWS.Status().Anomalies)
+}
+
+func TestAnomalyDetectionFlag(t *testing.T) {
+	mockK8s := mocks.NewMockK8sClient(nil)
+	mockK8s.FeatureFlags = map[string]string{features.AnomalyDetection.Name: "false"}
+	mockScraper := mocks.NewMockScraper(20)
+	mockAWS := NewAWS(mocks.NewMockAWSClient(10), mockK8s, mockScraper, Options{})
+	ctx := context.Background()
+	assert.NoError(t, mockAWS.runComplianceCheck(ctx))
+	mockScraper.Nodes = 60
+	assert.NoError(t, mockAWS.runComplianceCheck(ctx))
+	status := mockAWS.Status()
+	assert.Empty(t, status.Anomalies, "no anomalies should be detected while the flag is disabled")
+	assert.Equal(t, false, status.Features[features.AnomalyDetection.Name])
+
+	delete(mockK8s.FeatureFlags, features.AnomalyDetection.Name)
+	assert.NoError(t, mockAWS.runComplianceCheck(ctx))
+	mockScraper.Nodes = 120
+	assert.NoError(t, mockAWS.runComplianceCheck(ctx))
+	assert.NotEmpty(t, mockAWS.Status().Anomalies, "flags should be refreshed on every check")
+}
+
+func TestAdapterConfigFeatureFlags(t *testing.T) {
+	mockK8s := mocks.NewMockK8sClient(nil)
+	mockK8s.FeatureFlags = map[string]string{features.AnomalyDetection.Name: "true", features.UsageHistory.Name: "false"}
+	mockK8s.AdapterConfig = &k8s.AdapterConfig{Features: map[string]string{features.AnomalyDetection.Name: "false"}}
+	mockAWS := NewAWS(mocks.NewMockAWSClient(10), mockK8s, mocks.NewMockScraper(20), Options{})
+	assert.NoError(t, mockAWS.runComplianceCheck(context.Background()))
+	status := mockAWS.Status()
+	assert.Equal(t, false, status.Features[features.AnomalyDetection.Name], "the config should take precedence over the configmap")
+	assert.Equal(t, false, status.Features[features.UsageHistory.Name], "flags which aren't in the config should be read from the configmap")
+
+	mockK8s.AdapterConfig = nil
+	assert.NoError(t, mockAWS.runComplianceCheck(context.Background()))
+	assert.Equal(t, true, mockAWS.Status().Features[features.AnomalyDetection.Name])
+}
@@ -3,14 +3,25 @@ package manager
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"math"
+	"sort"
 	"strconv"
+	"sync"
 	"time"
 
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/licensemanager/types"
 	"github.com/rancher/csp-adapter/pkg/clients/aws"
 	"github.com/rancher/csp-adapter/pkg/clients/k8s"
+	"github.com/rancher/csp-adapter/pkg/entitlement"
+	"github.com/rancher/csp-adapter/pkg/events"
+	"github.com/rancher/csp-adapter/pkg/features"
 	"github.com/rancher/csp-adapter/pkg/metrics"
+	"github.com/rancher/csp-adapter/pkg/reports"
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/rancher/csp-adapter/pkg/supervisor"
+	"github.com/rancher/csp-adapter/pkg/usage"
 	"github.com/sirupsen/logrus"
 )
 
@@ -19,29 +30,169 @@ type AWS struct {
 	aws     aws.Client
 	k8s     k8s.Client
 	scraper metrics.Scraper
+	opts    Options
+
+	// features are refreshed at the start of every compliance check
+	features *features.Set
+	// trigger requests a compliance check ahead of schedule
+	trigger chan struct{}
+
+	// checkLock serializes compliance checks and operations changing the checked out licenses
+	checkLock sync.Mutex
+	// history is the recent usage that anomalies are detected in, guarded by the checkLock
+	history usageHistory
+	// usage persists the usage observed by compliance checks for true-up reports, guarded by the checkLock
+	usage *usage.Recorder
+	// nodeCountFailures is the number of consecutive checks which couldn't count nodes, guarded by the checkLock
+	nodeCountFailures int
+	// usageBackfilled is true once gaps in the usage history from before the adapter started were backfilled, guarded
+	// by the checkLock
+	usageBackfilled bool
+	// timer times the phases of the running compliance check, guarded by the checkLock
+	timer *phaseTimer
+	// forecast projects the exhaustion of entitlements from their consumption, guarded by the checkLock
+	forecast utilizationForecast
+	// config is the CSPAdapterConfig read by the last check, nil if there is none, guarded by the checkLock
+	config *k8s.AdapterConfig
+	// externalLicenses is the number of licenses checked out outside of the adapter, guarded by the checkLock
+	externalLicenses int
+	// checkoutRequests are the LicenseCheckoutRequests as of the last check and requestedLicenses the licenses held by
+	// their checkouts, guarded by the checkLock
+	checkoutRequests  []sdk.CheckoutRequest
+	requestedLicenses int
+	// licenseUnusable is true while the license can't be used because of its status, guarded by the checkLock
+	licenseUnusable bool
+	// verified is true once the running compliance check verified the availability of entitlements, and
+	// unverifiedSince is when checks started failing to, both guarded by the checkLock
+	verified        bool
+	unverifiedSince time.Time
+	// instanceID identifies this adapter process in the checkout info it writes, empty disables detecting duplicate
+	// instances. duplicateOf is another instance found writing the checkout info since duplicateSince, both guarded by
+	// the checkLock
+	instanceID     string
+	duplicateOf    string
+	duplicateSince time.Time
+	// owner is true while this instance manages the checkout info rather than backing off for another instance, so
+	// that it writes the instance heartbeat. Guarded by the instanceLock rather than the checkLock, since heartbeats are
+	// written while checks run
+	instanceLock sync.Mutex
+	owner        bool
+	// overAllocatedSince is when the adapter started holding more licenses than required, guarded by the checkLock
+	overAllocatedSince time.Time
+	// tokenLimitWarned is the consumption token whose approaching extension limit was warned about, guarded by the
+	// checkLock
+	tokenLimitWarned string
+	// zeroNodesSince is when checks started to license no nodes, guarded by the checkLock
+	zeroNodesSince time.Time
+	// scaleDownSince is when the scale-down waiting for confirmation was first seen, guarded by the checkLock
+	scaleDownSince time.Time
+	// summaries are the cluster summaries last published to each cluster, guarded by the checkLock
+	summaries SummaryCache
+
+	// catalog and entitlements are read through caches for api callers, so that they're served while License Manager
+	// is slow
+	catalog      *readThrough
+	entitlements *readThrough
+
+	statusLock sync.RWMutex
+	status     sdk.Status
+	clusterUID string
+	// inventory is what the current checkout was based on, nil until the first compliance check
+	inventory *sdk.Inventory
+	// verifying is the token of the checkout whose usage is being verified, so that a later checkout supersedes it.
+	// Guarded by the statusLock
+	verifying string
+	// maintenance are the declared AWS maintenance windows, earliest first, guarded by the statusLock
+	maintenance []sdk.MaintenanceWindow
+}
+
+func NewAWS(a aws.Client, k k8s.Client, s metrics.Scraper, opts Options) *AWS {
+	instanceID := opts.InstanceID
+	if instanceID == "" {
+		instanceID = newInstanceID()
+	}
+	var usageStore usage.Store = k
+	if opts.UsageStore != nil {
+		usageStore = opts.UsageStore
+	}
+	m := &AWS{
+		aws:        a,
+		k8s:        k,
+		scraper:    s,
+		opts:       opts,
+		features:   features.NewSet(),
+		trigger:    make(chan struct{}, 1),
+		usage:      usage.NewRecorder(usageStore, opts.UsageRetention),
+		instanceID: instanceID,
+		status: sdk.Status{
+			CSP:          awsSupportConfigCSP,
+			Account:      a.AccountNumber(),
+			AccountAlias: a.AccountAlias(),
+			Instance:     instanceID,
+			Compliance: sdk.ComplianceStatus{
+				Status: sdk.ComplianceStatusUnknown,
+			},
+			Sources: append([]sdk.DataSourceStatus(nil), statusSources...),
+		},
+	}
+	m.maintenance = append([]sdk.MaintenanceWindow(nil), opts.Maintenance...)
+	sort.SliceStable(m.maintenance, func(i, k int) bool { return m.maintenance[i].Start.Before(m.maintenance[k].Start) })
+	m.catalog = newReadThrough("product catalog", m.fetchProducts)
+	m.entitlements = newReadThrough("entitlement usage", m.fetchEntitlements)
+	return m
 }
 
-func NewAWS(a aws.Client, k k8s.Client, s metrics.Scraper) *AWS {
-	return &AWS{
-		aws:     a,
-		k8s:     k,
-		scraper: s,
+// Status returns the outcome of the most recent compliance check
+func (m *AWS) Status() sdk.Status {
+	m.statusLock.RLock()
+	status := m.status
+	status.Maintenance = m.maintenanceStatus(time.Now())
+	m.statusLock.RUnlock()
+	if m.opts.SLO != nil {
+		slo := m.opts.SLO.Status(time.Now())
+		status.SLO = &slo
 	}
+	if m.opts.Reports != nil {
+		status.Reports = m.opts.Reports.Status()
+	}
+	if m.opts.Costs != nil {
+		estimate := m.opts.Costs.Estimate()
+		status.Costs = &estimate
+	}
+	status.Degraded = degraded(status.Sources)
+	return status
 }
 
+// Start runs the compliance checks, which also renew the checkout, and the background probes in supervised goroutines,
+// so that a panic in one of them doesn't stop the others
 func (m *AWS) Start(ctx context.Context, errs chan<- error) {
-	go m.start(ctx, errs)
+	supervisor.Go(ctx, "service health probe", m.probeServiceHealth)
+	if m.opts.Permissions != nil {
+		supervisor.Go(ctx, "permission check", m.checkPermissionsPeriodically)
+	}
+	supervisor.Go(ctx, "instance heartbeat", m.writeHeartbeats)
+	supervisor.Go(ctx, "compliance check", func(ctx context.Context) { m.start(ctx, errs) })
 }
 
 const (
 	managerInterval = 30 * time.Second
-	nodesPerLicense = 20
+	// renewalMargin is how long before its expiry a checkout is extended
+	renewalMargin   = 5 * managerInterval
+	nodesPerLicense = entitlement.NodesPerLicense
 	// same as RFC3339 from time.time without the Z7:00 indicating timezone. Some AWS timestamps have this format
 	rfc3339NoTZ = "2006-01-02T15:04:05"
 	// keys for the consumption token secret's data. Can't do a straight marshal because we need all values to be strings
 	tokenKey     = "consumptionToken"
 	nodeKey      = "entitledNodes"
 	expiryKey    = "expiry"
+	clusterKey   = "clusterUID"
+	extensionKey = "extensions"
+	instanceKey  = "instanceID"
+	heartbeatKey = "instanceHeartbeat"
+	pendingKey   = "pendingCheckout"
+	pendingNodes = "pendingEntitledNodes"
+	licenseKey   = "licenseArn"
+	checkInKey   = "pendingCheckIns"
 	statusPrefix = "AWS Marketplace Adapter:"
 )
 
@@ -49,21 +200,119 @@ type licenseCheckoutInfo struct {
 	ConsumptionToken string
 	EntitledLicenses int
 	Expiry           time.Time
+	// ClusterUID identifies the cluster which created this info, used to detect state restored from a backup
+	ClusterUID string
+	// Extensions is the number of times ConsumptionToken was extended, which License Manager limits
+	Extensions int
+	// Instance is the adapter instance which last wrote this info at Heartbeat, used to detect duplicate instances
+	Instance  string
+	Heartbeat time.Time
+	// PendingCheckout is the idempotency token of a checkout of PendingLicenses which was started but whose result
+	// wasn't recorded yet. Set while the checkout is in flight, so that it can be recovered after a crash
+	PendingCheckout string
+	PendingLicenses int
+	// LicenseArn is the license ConsumptionToken was checked out from, used to detect that the license was renewed
+	// under a new arn. Empty for info saved by older versions
+	LicenseArn string
+	// PendingCheckIns are tokens no longer held which couldn't be checked in, retried by every check
+	PendingCheckIns []pendingCheckIn
 }
 
 func (m *AWS) start(ctx context.Context, errs chan<- error) {
-	for range ticker(ctx, managerInterval) {
-		err := m.runComplianceCheck(ctx)
+	tick := ticker(ctx, managerInterval)
+	// lastFull is when the last full compliance check ran, used to decide when the next is due on a schedule
+	var lastFull time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			logrus.Infof("[manager] exiting")
+			return
+		case now := <-tick:
+			if !m.fullCheckDue(lastFull, now) {
+				renewed, err := m.renewWithinBudget(ctx)
+				if err != nil {
+					m.reportCheckError(ctx, err, errs)
+				}
+				if renewed || err != nil {
+					continue
+				}
+				logrus.Infof("[manager] no checkout to renew, running compliance check outside of the schedule")
+			}
+		case <-m.trigger:
+			logrus.Infof("[manager] running triggered compliance check")
+		}
+		lastFull = time.Now()
+		err := m.checkCompliance(ctx)
 		if err != nil {
-			updError := m.updateAdapterOutput(false, fmt.Sprintf("unable to run compliance check with error: %v", err),
-				fmt.Sprintf("%s Unable to run the adapter, please check the adapter logs", statusPrefix))
-			if updError != nil {
+			m.reportCheckError(ctx, err, errs)
+		}
+		if m.opts.Subscriptions != nil {
+			if err := m.syncSubscriptions(ctx, time.Now()); err != nil {
 				errs <- err
 			}
-			errs <- err
 		}
 	}
-	logrus.Infof("[manager] exiting")
+}
+
+// TriggerCheck requests a compliance check ahead of schedule. Requests made while one is already pending are merged
+func (m *AWS) TriggerCheck() {
+	select {
+	case m.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// reportCheckError reports an error which prevented the compliance check as non-compliance, distinguishing failures
+// caused by License Manager being unavailable from other errors
+func (m *AWS) reportCheckError(ctx context.Context, err error, errs chan<- error) {
+	reason, notification := describeError(err, m.location())
+	if health := m.checkServiceHealth(ctx); !health.Up && reason == sdk.ReasonError {
+		// the failure is likely caused by the outage rather than a shortfall of licenses
+		reason = sdk.ReasonServiceUnavailable
+		notification = fmt.Sprintf("%s AWS License Manager is currently unavailable, compliance will be checked again once it recovers", statusPrefix)
+	}
+	updError := m.updateAdapterOutput(false, reason, fmt.Sprintf("unable to run compliance check with error: %v", err), notification)
+	if updError != nil {
+		errs <- updError
+	}
+	errs <- err
+}
+
+// licensesFor returns the licenses covering nodes, rounded up to whole licenses. Node counts are never negative, and the
+// licenses for any int of nodes fit an int
+func licensesFor(nodes int) int {
+	licenses, err := entitlement.ForNodes(nodes)
+	if err != nil {
+		return 0
+	}
+	required, _ := licenses.Int()
+	return required
+}
+
+// requiredLicenses returns the number of licenses required for nodes, keeping the contractual minimum checked out
+// even if fewer nodes are in use. Without nodes, the zero node mode decides
+func (m *AWS) requiredLicenses(nodes int) int {
+	required := licensesFor(nodes)
+	if nodes == 0 {
+		switch m.zeroNodeMode() {
+		case sdk.ZeroNodesCheckIn:
+			return 0
+		case sdk.ZeroNodesHoldMinimum:
+			required = 1
+		}
+	}
+	if required < m.opts.MinimumLicenses {
+		logrus.Debugf("%d licenses required for %d nodes, using minimum of %d licenses", required, nodes, m.opts.MinimumLicenses)
+		required = m.opts.MinimumLicenses
+	}
+	return required
+}
+
+// checkCompliance runs a compliance check, waiting for any other check or operation to finish first
+func (m *AWS) checkCompliance(ctx context.Context) error {
+	m.checkLock.Lock()
+	defer m.checkLock.Unlock()
+	return m.failClosed(m.runComplianceCheck(ctx), time.Now())
 }
 
 // runComplianceCheck compares the number of nodes registered with rancher with the number of entitlements currently
@@ -71,14 +320,45 @@ func (m *AWS) start(ctx context.Context, errs chan<- error) {
 // to check out the right amount. If we are and our tokens are about to expire, it extends the checkout period. If
 // any part of this fatally fails, the process will return an error
 func (m *AWS) runComplianceCheck(ctx context.Context) error {
-	license, err := m.aws.GetRancherLicense(ctx)
+	m.timer = newPhaseTimer(time.Now)
+	defer m.recordTimings(m.timer)
+	// failed checks spend the error budget too, so the condition is updated however the check ends
+	defer func() { m.reportErrorBudget(time.Now()) }()
+	budget := newCheckBudget(m.opts.CheckBudget, time.Now)
+	m.verified = false
+	m.refreshConfig()
+	m.timer.begin(phaseLicense)
+	licenseCtx, cancelLicense := budget.begin(ctx, phaseLicense)
+	defer cancelLicense()
+	license, err := m.aws.GetRancherLicense(licenseCtx)
+	m.recordSource(sdk.SourceLicenseManager, err, time.Now())
 	if err != nil {
-		return fmt.Errorf("unable to get rancher license, err: %v", err)
+		return fmt.Errorf("unable to get rancher license, err: %w", err)
+	}
+	if err := m.aws.ValidateLicense(*license); err != nil {
+		// no amount of checking in/out will fix this, the license itself needs to be corrected
+		m.releaseUnusableLicense(licenseCtx, err)
+		return fmt.Errorf("rancher license can't be used: %w", err)
+	}
+	if m.licenseUnusable {
+		logrus.Infof("[manager] rancher license can be used again")
+		m.licenseUnusable = false
 	}
-	nodeCounts, err := m.scraper.ScrapeAndParse()
+	m.timer.end()
+	m.timer.begin(phaseCount)
+	countCtx, cancelCount := budget.begin(ctx, phaseCount)
+	defer cancelCount()
+	nodeCounts, err := m.countNodes(countCtx)
+	m.timer.end()
+	m.recordSource(sdk.SourceNodeCounts, err, time.Now())
+	// the checkout is renewed in its own slice even if counting nodes overran
+	checkoutCtx, cancelCheckout := budget.begin(ctx, phaseCheckout)
+	defer cancelCheckout()
 	if err != nil {
-		return fmt.Errorf("unable to determine number of active nodes: %v", err)
+		return m.handleNodeCountFailure(checkoutCtx, err)
 	}
+	m.timer.begin(phaseCheckout)
+	m.nodeCountFailures = 0
 	logrus.Debugf("found %d nodes from rancher metrics", nodeCounts.Total)
 	currentCheckoutInfo, err := m.getLicenseCheckoutInfo()
 	if err != nil {
@@ -89,70 +369,307 @@ func (m *AWS) runComplianceCheck(ctx context.Context) error {
 			ConsumptionToken: "",
 		}
 	}
-	requiredLicenses := int(math.Ceil(float64(nodeCounts.Total) / float64(nodesPerLicense)))
+	if backoff, err := m.checkInstance(currentCheckoutInfo, time.Now()); backoff {
+		m.timer.end()
+		return err
+	}
+	currentCheckoutInfo = m.reconcileRestoredState(checkoutCtx, currentCheckoutInfo)
+	// while paused or frozen for maintenance the held checkout is only renewed, pending checkouts are recovered once
+	// resumed
+	paused := m.adjustmentsHeld(time.Now())
+	if !paused {
+		currentCheckoutInfo = m.recoverPendingCheckout(checkoutCtx, *license, currentCheckoutInfo)
+		m.retryCheckIns(checkoutCtx, currentCheckoutInfo, time.Now())
+	}
+	currentCheckoutInfo = m.switchToSuccessor(checkoutCtx, *license, currentCheckoutInfo, paused)
+	m.reconcileCheckoutRequests(checkoutCtx, license, paused, time.Now())
+	environments := m.classifyEnvironments(nodeCounts)
+	m.trackZeroNodes(environments.licensed, time.Now())
+	requiredLicenses := m.targetLicenses(environments.licensed)
 	logrus.Debugf("have %d licenses checked out, need %d licenses", currentCheckoutInfo.EntitledLicenses, requiredLicenses)
-	if currentCheckoutInfo.EntitledLicenses != requiredLicenses {
+	checkedOut := false
+	// a declared checkout is changed on purpose, so it converges without confirming scale-downs or keeping excess
+	// licenses
+	confirming, keepExcess := false, false
+	if !m.declared() {
+		nodeCounts, requiredLicenses, confirming = m.confirmScaleDown(ctx, currentCheckoutInfo, nodeCounts, requiredLicenses, time.Now())
+		if nodeCounts != environments.counts {
+			// nodes were counted again to confirm a scale-down
+			environments = m.classifyEnvironments(nodeCounts)
+		}
+		keepExcess = confirming || m.keepExcessLicenses(currentCheckoutInfo, requiredLicenses, time.Now())
+	}
+	shadow := m.shadowInput(checkoutCtx, *license, currentCheckoutInfo, nodeCounts.Total, requiredLicenses, keepExcess)
+	// decision is what this check decided to do, compared with the decision of the shadow planner
+	var decision Decision
+	if replacesCheckout(currentCheckoutInfo.EntitledLicenses, requiredLicenses, keepExcess) && !paused {
 		// if we know we need a new set of entitlements, checkin what we are currently using since we only hold one
-		// checked out set of entitlements at a time
+		// checked out set of entitlements at a time. The check in and the checkout aren't limited by the check budget,
+		// a deadline between them would leave rancher without licenses until the next check
 		if currentCheckoutInfo.ConsumptionToken != "" {
-			_, err = m.aws.CheckInRancherLicense(ctx, currentCheckoutInfo.ConsumptionToken)
+			decision.CheckIn = currentCheckoutInfo.EntitledLicenses
+			err = m.checkIn(ctx, currentCheckoutInfo.ConsumptionToken, currentCheckoutInfo.Expiry, currentCheckoutInfo)
 			if err != nil {
-				logrus.Warnf("unable to checkin license with error %v", err)
+				logrus.Warnf("unable to checkin license, will retry with later checks: %v", err)
 			} else {
 				logrus.Debugf("successfully checked in license")
 				currentCheckoutInfo.EntitledLicenses = 0
 				currentCheckoutInfo.ConsumptionToken = ""
 			}
 		}
-		availableLicenses, err := m.aws.GetNumberOfAvailableEntitlements(ctx, *license)
-		logrus.Debugf("found %d entitlements available", availableLicenses)
+		usage, err := m.getEntitlementUsage(ctx, *license, currentCheckoutInfo.EntitledLicenses)
+		availableLicenses := usage.Available()
+		logrus.Debugf("found %d entitlements available, %d checked out outside of the adapter", availableLicenses, m.externalLicenses)
 		if err != nil {
 			logrus.Warnf("unable to determine number of available entitlements, will attempt full checkout %v", err)
 			// if we can't verify how many licenses are available, assume that we have enough to meet our requirements
-			availableLicenses = requiredLicenses
-		}
-		checkoutAmount := requiredLicenses
-		if checkoutAmount > availableLicenses {
-			// only checkout what we actually have available to us
-			checkoutAmount = availableLicenses
+			availableLicenses = -1
 		}
+		// only checkout what we actually have available to us
+		checkoutAmount := licensesToCheckOut(requiredLicenses, availableLicenses)
 		if checkoutAmount > 0 {
 			// it's possible that we have no licenses available - don't attempt checkout in this case
-			resp, err := m.aws.CheckoutRancherLicense(ctx, *license, checkoutAmount)
+			decision.Checkout = checkoutAmount
+			resp, err := m.checkout(ctx, *license, checkoutAmount, currentCheckoutInfo)
 			if err != nil {
+				m.recordSource(sdk.SourceLicenseManager, err, time.Now())
 				return fmt.Errorf("unable to checkout rancher licenses %v", err)
 			}
 			logrus.Debugf("successfully checked out license")
+			checkedOut = true
 			currentCheckoutInfo.ConsumptionToken = *resp.LicenseConsumptionToken
 			currentCheckoutInfo.EntitledLicenses = checkoutAmount
 			currentCheckoutInfo.Expiry = parseExpirationTimestamp(*resp.Expiration)
+			currentCheckoutInfo.Extensions = 0
+			currentCheckoutInfo.LicenseArn = awssdk.ToString(license.LicenseArn)
+			m.startUsageVerification(ctx, *license, currentCheckoutInfo.ConsumptionToken, m.externalLicenses+m.requestedLicenses+checkoutAmount)
 		}
-	} else if requiredLicenses != 0 {
-		// extend our checkout as long as we have something checked out
-		newCheckoutInfo, err := m.extendCheckout(ctx, 5*managerInterval, currentCheckoutInfo)
-		if err != nil {
-			currentCheckoutInfo.EntitledLicenses = 0
-			currentCheckoutInfo.ConsumptionToken = ""
-			logrus.Warnf("unable to extend license checkout, will assume it failed and reset: %v", err)
-		} else {
-			currentCheckoutInfo = newCheckoutInfo
+	} else {
+		// excess licenses which are kept after scaling down to no nodes are renewed like required ones, as are any
+		// licenses held while paused
+		holding := requiredLicenses != 0 || keepExcess || paused
+		if holding && m.rotationDue(currentCheckoutInfo) {
+			decision = Decision{CheckIn: currentCheckoutInfo.EntitledLicenses, Checkout: currentCheckoutInfo.EntitledLicenses}
+			rotatedCheckoutInfo, err := m.rotateCheckout(checkoutCtx, *license, currentCheckoutInfo)
+			if err != nil {
+				logrus.Warnf("unable to rotate consumption token: %v", err)
+			} else {
+				checkedOut = true
+			}
+			currentCheckoutInfo = rotatedCheckoutInfo
+		}
+		if holding && currentCheckoutInfo.ConsumptionToken != "" {
+			// extend our checkout as long as we have something checked out
+			newCheckoutInfo, err := m.extendCheckout(checkoutCtx, m.extensionMargin(time.Now()), currentCheckoutInfo)
+			if err != nil {
+				currentCheckoutInfo.EntitledLicenses = 0
+				currentCheckoutInfo.ConsumptionToken = ""
+				logrus.Warnf("unable to extend license checkout, will assume it failed and reset: %v", err)
+			} else {
+				currentCheckoutInfo = newCheckoutInfo
+			}
+		}
+		// keep track of checkouts made outside of the adapter even when our own checkout doesn't change
+		if _, err := m.getEntitlementUsage(checkoutCtx, *license, currentCheckoutInfo.EntitledLicenses); err != nil {
+			logrus.Warnf("unable to determine checkouts made outside of the adapter: %v", err)
 		}
 	}
 	err = m.saveCheckoutInfo(currentCheckoutInfo)
 	if err != nil {
 		logrus.Warnf("unable to save current checkout info, next run may fail with checkout/checkin")
 	}
+	m.reportTokenLimit(currentCheckoutInfo, time.Now())
+	if !paused {
+		m.compareShadow(shadow, decision, time.Now())
+	}
+	m.timer.end()
+	if _, captured := m.Inventory(); checkedOut || !captured {
+		// the inputs of a checkout restored after a restart aren't known, the first check's are used instead
+		m.captureInventory(*license, environments, requiredLicenses, currentCheckoutInfo)
+	}
 
+	// licenses kept after scaling down cover the nodes as well as exactly the required licenses do
+	licensed := currentCheckoutInfo.EntitledLicenses >= requiredLicenses
+	uncovered := m.uncoveredNodes(currentCheckoutInfo.EntitledLicenses, environments.licensed)
 	var statusMessage string
-	if currentCheckoutInfo.EntitledLicenses == requiredLicenses {
+	if licensed && uncovered > 0 {
+		licensed = false
+		statusMessage = m.describeDeclaredShortfall(environments.licensed)
+	} else if licensed {
 		statusMessage = fmt.Sprintf("%s Rancher server has the required amount of licenses", statusPrefix)
 	} else {
 		statusMessage = fmt.Sprintf("%s You have exceeded your licensed node count. At least %d more license(s) are required in AWS to become compliant.",
 			statusPrefix, requiredLicenses-currentCheckoutInfo.EntitledLicenses)
+		if m.externalLicenses > 0 {
+			statusMessage = fmt.Sprintf("%s %d license(s) are checked out outside of Rancher and can't be used until they are checked in.",
+				statusMessage, m.externalLicenses)
+		}
 	}
 	configMessage := fmt.Sprintf("Rancher server required %d license(s) and was able to check out %d license(s)", requiredLicenses, currentCheckoutInfo.EntitledLicenses)
+	if m.declared() {
+		configMessage = fmt.Sprintf("%s for the declared %d nodes", configMessage, m.declaredNodes())
+		if uncovered > 0 {
+			configMessage = fmt.Sprintf("%s, which don't cover %d of the %d nodes in use", configMessage, uncovered, environments.licensed)
+		}
+	}
+	if m.externalLicenses > 0 {
+		configMessage = fmt.Sprintf("%s, %d license(s) are checked out outside of the adapter", configMessage, m.externalLicenses)
+	}
+	if m.requestedLicenses > 0 {
+		configMessage = fmt.Sprintf("%s, %d license(s) are checked out for checkout requests", configMessage, m.requestedLicenses)
+	}
+	if nodeCounts.Diverged {
+		configMessage = fmt.Sprintf("%s, node count sources diverged (%s) and the largest count was used", configMessage, describeSources(nodeCounts.Sources))
+	}
+	if nonProduction := environments.nodes[sdk.EnvironmentNonProduction]; nonProduction > 0 {
+		configMessage = fmt.Sprintf("%s, %d of the %d nodes are non-production and counted as %d", configMessage, nonProduction,
+			nodeCounts.Total, environments.licensed-environments.nodes[sdk.EnvironmentProduction])
+	}
+	if len(environments.exemptions) > 0 {
+		configMessage = fmt.Sprintf("%s, %s", configMessage, describeExemptions(environments.exemptions, environments.exempt, m.location()))
+	}
+	var excessReleaseAt time.Time
+	if window, frozen := m.freeze(time.Now()); paused && frozen && !m.paused() {
+		configMessage = fmt.Sprintf("%s, checkout adjustments are frozen for AWS maintenance until %s", configMessage,
+			formatTime(window.End, m.location()))
+	} else if paused {
+		configMessage = fmt.Sprintf("%s, checkout adjustments are paused", configMessage)
+	} else if excess := currentCheckoutInfo.EntitledLicenses - requiredLicenses; excess > 0 {
+		excessReleaseAt = m.excessReleaseAt(currentCheckoutInfo)
+		configMessage = fmt.Sprintf("%s, %d excess license(s) are kept until %s", configMessage, excess, formatTime(excessReleaseAt, m.location()))
+	}
+	m.recordUsage(sdk.UsageSnapshot{
+		Nodes:              nodeCounts.Total,
+		NodesPerLicense:    nodesPerLicense,
+		RequiredLicenses:   requiredLicenses,
+		MinimumLicenses:    m.opts.MinimumLicenses,
+		DeclaredNodes:      m.declaredNodes(),
+		CheckedOutLicenses: currentCheckoutInfo.EntitledLicenses,
+		ExternalLicenses:   m.externalLicenses,
+		FailedClusters:     nodeCounts.FailedClusters,
+		NodeCountSources:   nodeCounts.Sources,
+		NodeCountDiverged:  nodeCounts.Diverged,
+		NodesByEnvironment: environments.nodes,
+		LicensedNodes:      environments.licensed,
+		ExemptNodes:        environments.exempt,
+		Exemptions:         environments.exemptions,
+		CheckoutRequests:   m.checkoutRequests,
+		RequestedLicenses:  m.requestedLicenses,
+		CheckoutExpiry:     sdk.OptionalTime(checkoutExpiry(currentCheckoutInfo)),
+		CheckoutRenewsAt:   sdk.OptionalTime(checkoutRenewsAt(currentCheckoutInfo)),
+		TokenExtensions:    currentCheckoutInfo.Extensions,
+		PendingCheckIns:    len(currentCheckoutInfo.PendingCheckIns),
+		OverAllocationMode: m.overAllocationMode(),
+		ZeroNodeMode:       m.reportedZeroNodeMode(),
+		ExcessReleaseAt:    sdk.OptionalTime(excessReleaseAt),
+		ObservedAt:         time.Now(),
+	})
+	m.recordFleetMetrics(nodeCounts, requiredLicenses, currentCheckoutInfo.EntitledLicenses)
+	if m.features.Enabled(features.AnomalyDetection) && budget.allows(phaseAnomalies) {
+		m.timer.begin(phaseAnomalies)
+		m.detectAnomalies(nodeCounts.Total, checkedOut, time.Now())
+		m.timer.end()
+	}
+	if m.usage != nil && m.features.Enabled(features.UsageHistory) {
+		if err := m.usage.Observe(nodeCounts.Total, environments.nodes[sdk.EnvironmentNonProduction], requiredLicenses, licensed, time.Now()); err != nil {
+			logrus.Warnf("[manager] unable to record usage history: %v", err)
+		}
+		// backfilling and compacting are caught up with by a later check if skipped
+		if budget.allows(phaseHistory) {
+			m.timer.begin(phaseHistory)
+			if !m.usageBackfilled {
+				m.backfillUsage(time.Now())
+			}
+			if err := m.usage.Compact(time.Now()); err != nil {
+				logrus.Warnf("[manager] unable to compact usage history: %v", err)
+			}
+			m.timer.end()
+		}
+	}
 
-	return m.updateAdapterOutput(currentCheckoutInfo.EntitledLicenses == requiredLicenses, configMessage, statusMessage)
+	reason := sdk.ReasonLicensed
+	if !licensed {
+		reason = sdk.ReasonInsufficientLicenses
+	}
+	reportedCompliance := licensed
+	if test := m.syntheticTest(time.Now()); test != nil {
+		// only the reported compliance is synthetic, usage history records the actual compliance
+		reportedCompliance, reason = false, sdk.ReasonSyntheticTest
+		configMessage, statusMessage = describeSyntheticTest(*test, configMessage, m.location())
+	}
+	m.timer.begin(phaseStatus)
+	budget.reserve(phaseStatus)
+	err = m.updateAdapterOutput(reportedCompliance, reason, configMessage, statusMessage)
+	m.timer.end()
+	if err != nil {
+		return err
+	}
+	if m.opts.PublishClusterSummaries && budget.allows(phaseSummaries) {
+		m.timer.begin(phaseSummaries)
+		m.publishClusterSummaries(environments)
+		m.timer.end()
+	}
+	return nil
+}
+
+// describeError produces the compliance reason and user-facing notification for an error which prevented the compliance
+// check. Errors which the user can act on without reading the logs are described directly
+func describeError(err error, loc *time.Location) (string, string) {
+	var regionErr *aws.RegionMismatchError
+	if errors.As(err, &regionErr) {
+		return sdk.ReasonRegionMismatch, fmt.Sprintf("%s The Rancher license is in region %s but the adapter is configured for region %s. Reinstall the adapter with the correct region.",
+			statusPrefix, regionErr.LicenseRegion, regionErr.ClientRegion)
+	}
+	var duplicateErr *DuplicateInstanceError
+	if errors.As(err, &duplicateErr) {
+		return sdk.ReasonDuplicateInstance, fmt.Sprintf("%s Another adapter (instance %s) is managing the same Rancher licenses. This adapter stopped changing them, please uninstall all but one adapter.",
+			statusPrefix, duplicateErr.Instance)
+	}
+	var unverifiedErr *UnverifiedError
+	if errors.As(err, &unverifiedErr) {
+		return sdk.ReasonEntitlementsUnverified, fmt.Sprintf("%s The Rancher license entitlements could not be verified since %s. Rancher is not compliant until they can be verified again, please check the adapter logs.",
+			statusPrefix, formatTime(unverifiedErr.Since, loc))
+	}
+	var nodeCountErr *NodeCountError
+	if errors.As(err, &nodeCountErr) {
+		return sdk.ReasonNodeCountUnavailable, fmt.Sprintf("%s Unable to count the nodes managed by Rancher for %d consecutive checks. Licenses are not renewed until nodes can be counted again, please check the adapter logs.",
+			statusPrefix, nodeCountErr.Failures)
+	}
+	var grantErr *aws.GrantStatusError
+	if errors.As(err, &grantErr) {
+		if grantErr.Revoked() {
+			return sdk.ReasonGrantRevoked, fmt.Sprintf("%s The grant of the Rancher license was revoked (%s) and its licenses were checked in. Please contact the seller of the license.",
+				statusPrefix, grantErr.Status)
+		}
+		return sdk.ReasonGrantDisabled, fmt.Sprintf("%s The grant of the Rancher license was disabled and its licenses were checked in. Licenses will be checked out again once it is enabled.", statusPrefix)
+	}
+	var issuerErr *aws.IssuerError
+	if errors.As(err, &issuerErr) {
+		return sdk.ReasonIssuerUnknown, fmt.Sprintf("%s The issuer of the Rancher license could not be determined, so it can't be checked out. Please contact the seller of the license.", statusPrefix)
+	}
+	var statusErr *aws.LicenseStatusError
+	if errors.As(err, &statusErr) {
+		if statusErr.Status == types.LicenseStatusExpired {
+			return sdk.ReasonLicenseExpired, fmt.Sprintf("%s The Rancher license has expired. Please renew it in AWS Marketplace.", statusPrefix)
+		}
+		return sdk.ReasonLicenseNotAvailable, fmt.Sprintf("%s The Rancher license has status %s and can't be checked out. Please check the license in AWS License Manager.",
+			statusPrefix, statusErr.Status)
+	}
+	var validityErr *aws.ValidityError
+	if errors.As(err, &validityErr) {
+		if validityErr.NotYetValid {
+			return sdk.ReasonLicenseNotYetValid, fmt.Sprintf("%s The Rancher license is not valid before %s.", statusPrefix, formatTime(validityErr.Begin, loc))
+		}
+		return sdk.ReasonLicenseExpired, fmt.Sprintf("%s The Rancher license expired at %s. Please renew it in AWS Marketplace.", statusPrefix, formatTime(validityErr.End, loc))
+	}
+	var entitlementErr *aws.EntitlementError
+	if errors.As(err, &entitlementErr) {
+		if entitlementErr.Missing {
+			return sdk.ReasonEntitlementMissing, fmt.Sprintf("%s The Rancher license was granted without node entitlements. The grant is misconfigured, please contact the seller of the license.", statusPrefix)
+		}
+		return sdk.ReasonNoEntitlementsGranted, fmt.Sprintf("%s The Rancher license grants 0 node entitlements. Please contact the seller of the license to purchase entitlements.", statusPrefix)
+	}
+	return sdk.ReasonError, fmt.Sprintf("%s Unable to run the adapter, please check the adapter logs", statusPrefix)
 }
 
 // extendCheckout extends the checkout of the licenses in info if info.Expiry is within minTimeTillExpiry
@@ -170,6 +687,10 @@ func (m *AWS) extendCheckout(ctx context.Context, minTimeTillExpiry time.Duratio
 		ConsumptionToken: *res.LicenseConsumptionToken,
 		Expiry:           parseExpirationTimestamp(*res.Expiration),
 		EntitledLicenses: info.EntitledLicenses,
+		ClusterUID:       info.ClusterUID,
+		Extensions:       info.Extensions + 1,
+		LicenseArn:       info.LicenseArn,
+		PendingCheckIns:  info.PendingCheckIns,
 	}, nil
 }
 
@@ -180,44 +701,71 @@ func (m *AWS) getLicenseCheckoutInfo() (*licenseCheckoutInfo, error) {
 	if err != nil {
 		return nil, err
 	}
-	token, tOk := secret.Data[tokenKey]
-	licenses, lOk := secret.Data[nodeKey]
-	expiry, eOk := secret.Data[expiryKey]
-	if !(tOk && lOk && eOk) {
-		// if we couldn't extract the token or node counts, we can't return accurate checkout info
-		return nil, fmt.Errorf("couldn't license consumption info from secret")
-	}
-	numLicenses, err := strconv.Atoi(string(licenses))
-	if err != nil {
-		return nil, fmt.Errorf("unable to parse the number of nodes the license token is for %v", err)
-	}
-	expiryTime, err := time.Parse(time.RFC3339, string(expiry))
+	cached, err := ReadCachedCheckout(secret.Data)
 	if err != nil {
-		return nil, fmt.Errorf("unable to parse the token's expiry time %v", err)
+		return nil, err
 	}
+	// absent for info saved by older versions, whose extensions weren't counted
+	extensions, _ := strconv.Atoi(string(secret.Data[extensionKey]))
+	// absent for info saved by older versions, which can't be told apart from this instance
+	heartbeat, _ := time.Parse(time.RFC3339, string(secret.Data[heartbeatKey]))
+	pendingLicenses, _ := strconv.Atoi(string(secret.Data[pendingNodes]))
 	return &licenseCheckoutInfo{
-		ConsumptionToken: string(token),
-		EntitledLicenses: numLicenses,
-		Expiry:           expiryTime,
+		ConsumptionToken: cached.ConsumptionToken,
+		EntitledLicenses: cached.Licenses,
+		Expiry:           cached.Expiry,
+		// absent for info saved by older versions, which is adopted by the current cluster
+		ClusterUID:      string(secret.Data[clusterKey]),
+		Extensions:      extensions,
+		Instance:        string(secret.Data[instanceKey]),
+		Heartbeat:       heartbeat,
+		PendingCheckout: string(secret.Data[pendingKey]),
+		PendingLicenses: pendingLicenses,
+		LicenseArn:      string(secret.Data[licenseKey]),
+		PendingCheckIns: parsePendingCheckIns(secret.Data[checkInKey]),
 	}, nil
 }
 
 // saveCheckoutInfo saves the checkoutInfo to the k8s cache. If this fails, returns an error
 func (m *AWS) saveCheckoutInfo(info *licenseCheckoutInfo) error {
-	return m.k8s.UpdateConsumptionTokenSecret(map[string]string{
+	data := map[string]string{
 		tokenKey:  info.ConsumptionToken,
 		nodeKey:   fmt.Sprintf("%d", info.EntitledLicenses),
 		expiryKey: info.Expiry.Format(time.RFC3339),
-	})
+
+		clusterKey:   info.ClusterUID,
+		extensionKey: strconv.Itoa(info.Extensions),
+		// the following are always written, even when empty, since the secret keeps keys which aren't
+		checkInKey:   formatPendingCheckIns(info.PendingCheckIns),
+		licenseKey:   info.LicenseArn,
+		pendingKey:   info.PendingCheckout,
+		pendingNodes: strconv.Itoa(info.PendingLicenses),
+	}
+	if m.instanceID != "" {
+		// also written by every save, besides the heartbeats (see writeHeartbeats)
+		data[instanceKey] = m.instanceID
+		data[heartbeatKey] = time.Now().Format(time.RFC3339)
+	}
+	return m.k8s.UpdateConsumptionTokenSecret(data)
+}
+
+// conditionMessage returns the message of the compliance condition of the local cluster. It only changes with
+// compliance rather than with the node count, so that the cluster isn't written by every check
+func conditionMessage(inCompliance bool) string {
+	if inCompliance {
+		return "Rancher has the required licenses, see the csp-adapter status for details"
+	}
+	return "Rancher doesn't have the required licenses, see the csp-adapter status for details"
 }
 
 // updateAdapterOutput uses the k8s client to update the status objects signaling compliance/non-compliance to other apps
-// configMessage is used to update the supportConfig configmap, and notificationMessage is created in a user-facing object
-func (m *AWS) updateAdapterOutput(inCompliance bool, configMessage string, notificationMessage string) error {
+// reason is one of the sdk reasons explaining the compliance status, configMessage is used to update the supportConfig configmap, and notificationMessage is created in a user-facing object
+func (m *AWS) updateAdapterOutput(inCompliance bool, reason string, configMessage string, notificationMessage string) error {
 	config := GetDefaultSupportConfig(m.k8s)
 	config.CSP = CSPInfo{
 		Name:       awsSupportConfigCSP,
 		AcctNumber: m.aws.AccountNumber(),
+		AcctAlias:  m.aws.AccountAlias(),
 	}
 	rancherVersion, err := m.k8s.GetRancherVersion()
 	if err != nil {
@@ -225,7 +773,9 @@ func (m *AWS) updateAdapterOutput(inCompliance bool, configMessage string, notif
 	}
 	config.Product = createProductString(rancherVersion)
 	info := ComplianceInfo{
-		Message: configMessage,
+		Reason:   reason,
+		Message:  configMessage,
+		Coverage: m.coverage(),
 	}
 	if inCompliance {
 		info.Status = StatusInCompliance
@@ -233,16 +783,133 @@ func (m *AWS) updateAdapterOutput(inCompliance bool, configMessage string, notif
 		info.Status = StatusNotInCompliance
 	}
 	config.Compliance = info
+	m.recordCompliance(info)
 	err = m.k8s.UpdateUserNotification(inCompliance, notificationMessage)
 	if err != nil {
 		// don't bother marshalling the config if we can't report the error to the user
 		return err
 	}
+	// UI extensions watching settings follow the compliance state without plumbing of their own
+	setting, err := json.Marshal(info)
+	if err != nil {
+		return fmt.Errorf("unable to marshall compliance setting: %v", err)
+	}
+	err = m.k8s.UpdateComplianceSetting(string(setting))
+	if err != nil {
+		return fmt.Errorf("unable to update compliance setting: %v", err)
+	}
+	if m.opts.PublishClusterCondition {
+		// only informational, alerting on it doesn't justify failing the compliance check
+		if err := m.k8s.UpdateComplianceCondition(inCompliance, reason, conditionMessage(inCompliance)); err != nil {
+			logrus.Warnf("[manager] unable to update compliance condition of the local cluster: %v", err)
+		}
+	}
 	marshalled, err := json.Marshal(config)
 	if err != nil {
 		return fmt.Errorf("unable to marshall config: %v", err)
 	}
-	return m.k8s.UpdateCSPConfigOutput(marshalled)
+	err = m.k8s.UpdateCSPConfigOutput(marshalled)
+	if m.opts.Reports != nil {
+		// the other sinks are written in the background, whether or not the configmap could be
+		m.opts.Reports.Record(reports.ConfigMapSink, err)
+		m.opts.Reports.Publish(marshalled)
+	}
+	return err
+}
+
+// refreshConfig reads the CSPAdapterConfig and applies it. The previous config is kept if it can't be read. Must be
+// called while holding the checkLock
+func (m *AWS) refreshConfig() {
+	config, err := m.k8s.GetAdapterConfig()
+	if err != nil {
+		logrus.Warnf("[manager] unable to read the csp adapter config, keeping the current one: %v", err)
+	} else {
+		m.config = config
+	}
+	m.refreshFeatures()
+	m.applyConfigPause()
+}
+
+// refreshFeatures reads the feature flags set for this install, those of the CSPAdapterConfig taking precedence over
+// the features configmap (see features.Set.Update). Flags keep their previous values if they can't be read
+func (m *AWS) refreshFeatures() {
+	if m.features == nil {
+		// defaults are used
+		return
+	}
+	data, err := m.k8s.GetFeatureFlags()
+	if err != nil {
+		logrus.Warnf("[manager] unable to read feature flags, keeping current values: %v", err)
+	} else {
+		var configured map[string]string
+		if m.config != nil {
+			configured = m.config.Features
+		}
+		m.features.Update(data, configured)
+	}
+	m.statusLock.Lock()
+	defer m.statusLock.Unlock()
+	previous := m.status.Features
+	m.status.Features = m.features.All()
+	if previous == nil {
+		// the flags read on startup aren't a change
+		return
+	}
+	for name, enabled := range m.status.Features {
+		if was, ok := previous[name]; ok && was == enabled {
+			continue
+		}
+		m.opts.Events.Publish(events.TypeConfigChanged, m.aws.AccountNumber(), events.ConfigChange{
+			Kind:     events.ConfigFeatureFlag,
+			Name:     name,
+			Value:    strconv.FormatBool(enabled),
+			Previous: strconv.FormatBool(previous[name]),
+		})
+	}
+}
+
+// recordUsage stores usage as the usage observed by the most recent compliance check
+func (m *AWS) recordUsage(usage sdk.UsageSnapshot) {
+	m.statusLock.Lock()
+	defer m.statusLock.Unlock()
+	m.status.Usage = usage
+}
+
+// recordCompliance stores info as the result of the most recent compliance check
+func (m *AWS) recordCompliance(info ComplianceInfo) {
+	m.statusLock.Lock()
+	defer m.statusLock.Unlock()
+	m.status.Account = m.aws.AccountNumber()
+	m.status.AccountAlias = m.aws.AccountAlias()
+	previous := m.status.Compliance
+	m.status.Compliance = sdk.ComplianceStatus{
+		Status:      info.Status,
+		Reason:      info.Reason,
+		Message:     info.Message,
+		LastChecked: time.Now(),
+	}
+	if previous.Status != info.Status || previous.Reason != info.Reason {
+		m.opts.Events.Publish(events.TypeComplianceChanged, m.status.Account, events.ComplianceChange{
+			Status:         info.Status,
+			Reason:         info.Reason,
+			Message:        info.Message,
+			PreviousStatus: previous.Status,
+			PreviousReason: previous.Reason,
+		})
+	}
+}
+
+// location is the timezone times are rendered in for humans, UTC unless a reporting timezone is configured
+func (m *AWS) location() *time.Location {
+	if m.opts.Location == nil {
+		return time.UTC
+	}
+	return m.opts.Location
+}
+
+// formatTime renders t in loc for the messages published to rancher, so that they match the customer's business day
+func formatTime(t time.Time, loc *time.Location) string {
+	return t.In(loc).Format(time.RFC3339)
 }
 
 func ticker(ctx context.Context, duration time.Duration) <-chan time.Time {
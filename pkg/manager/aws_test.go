@@ -6,8 +6,12 @@ import (
 	"fmt"
 	"strconv"
 	"testing"
+	"time"
 
+	"github.com/aws/aws-sdk-go-v2/service/licensemanager/types"
+	"github.com/rancher/csp-adapter/pkg/clients/aws"
 	"github.com/rancher/csp-adapter/pkg/mocks"
+	"github.com/rancher/csp-adapter/pkg/sdk"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -65,6 +69,10 @@ func (s *testScenario) runScenario(t *testing.T) {
 	assert.NoError(t, err, "expected to be able to marshall config output to a cspSupportConfig")
 	actualCompliance := config.Compliance.Status
 	assert.Equal(t, expectedCompliance, actualCompliance, fmt.Sprintf("Scenario: %v", s))
+	var setting ComplianceInfo
+	err = json.Unmarshal([]byte(mockK8sClient.ComplianceSetting), &setting)
+	assert.NoError(t, err, "expected the compliance setting to hold the compliance info")
+	assert.Equal(t, config.Compliance, setting, fmt.Sprintf("Scenario: %v", s))
 	actualEntitlements := 0
 	for _, value := range mockAWSClient.CheckedOutEntitlements {
 		actualEntitlements += value
@@ -182,3 +190,172 @@ func TestCheckInCheckout(t *testing.T) {
 		scenario.runScenario(t)
 	}
 }
+
+//TestGrantMisconfigured tests that licenses without usable entitlements are reported with a distinct reason
+func TestGrantMisconfigured(t *testing.T) {
+	mockAWSClient := mocks.NewMockAWSClient(0)
+	mockAWS := AWS{
+		aws:     mockAWSClient,
+		k8s:     mocks.NewMockK8sClient(nil),
+		scraper: mocks.NewMockScraper(20),
+	}
+	err := mockAWS.runComplianceCheck(context.TODO())
+	assert.Error(t, err, "expected an error for a license with 0 entitlements")
+	reason, notification := describeError(err, time.UTC)
+	assert.Equal(t, sdk.ReasonNoEntitlementsGranted, reason)
+	assert.Contains(t, notification, "contact the seller")
+	assert.Empty(t, mockAWSClient.CheckedOutEntitlements, "nothing should be checked out from a license without entitlements")
+
+	reason, _ = describeError(fmt.Errorf("wrapped: %w", &aws.EntitlementError{Missing: true}), time.UTC)
+	assert.Equal(t, sdk.ReasonEntitlementMissing, reason)
+	reason, _ = describeError(fmt.Errorf("unable to reach aws"), time.UTC)
+	assert.Equal(t, sdk.ReasonError, reason)
+}
+
+//TestClusterSummaries tests that each downstream cluster gets a summary of only its own consumption
+func TestClusterSummaries(t *testing.T) {
+	mockK8sClient := mocks.NewMockK8sClient(nil)
+	mockScraper := mocks.NewMockScraper(30)
+	mockScraper.Clusters = map[string]int{"c-abcde": 10, "c-fghij": 20}
+	mockAWS := AWS{
+		aws:     mocks.NewMockAWSClient(2),
+		k8s:     mockK8sClient,
+		scraper: mockScraper,
+		opts:    Options{PublishClusterSummaries: true},
+	}
+	err := mockAWS.runComplianceCheck(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, mockK8sClient.ClusterSummaries, 2, "expected a summary for each downstream cluster")
+	for clusterID, nodes := range mockScraper.Clusters {
+		var summary sdk.ClusterSummary
+		assert.NoError(t, json.Unmarshal(mockK8sClient.ClusterSummaries[clusterID], &summary))
+		assert.Equal(t, clusterID, summary.ClusterID)
+		assert.Equal(t, nodes, summary.Nodes)
+		assert.Equal(t, 30, summary.TotalNodes)
+		assert.Equal(t, sdk.ComplianceStatusCompliant, summary.Compliance.Status)
+	}
+
+	// summaries whose content didn't change aren't published again
+	mockK8sClient.ClusterSummaries = nil
+	mockScraper.Clusters = map[string]int{"c-abcde": 10, "c-fghij": 25}
+	assert.NoError(t, mockAWS.runComplianceCheck(context.TODO()))
+	assert.Len(t, mockK8sClient.ClusterSummaries, 1, "expected only the changed summary to be published")
+	assert.Contains(t, mockK8sClient.ClusterSummaries, "c-fghij")
+}
+
+func TestClusterCondition(t *testing.T) {
+	mockK8sClient := mocks.NewMockK8sClient(nil)
+	mockScraper := mocks.NewMockScraper(30)
+	mockAWS := AWS{
+		aws:     mocks.NewMockAWSClient(2),
+		k8s:     mockK8sClient,
+		scraper: mockScraper,
+		opts:    Options{PublishClusterCondition: true},
+	}
+	assert.NoError(t, mockAWS.runComplianceCheck(context.TODO()))
+	if assert.NotNil(t, mockK8sClient.ComplianceCondition) {
+		assert.True(t, mockK8sClient.ComplianceCondition.Compliant)
+		assert.Equal(t, sdk.ReasonLicensed, mockK8sClient.ComplianceCondition.Reason)
+		assert.Equal(t, conditionMessage(true), mockK8sClient.ComplianceCondition.Message, "the message shouldn't change with the node count")
+	}
+
+	mockScraper.Nodes = 1000
+	assert.NoError(t, mockAWS.runComplianceCheck(context.TODO()))
+	if assert.NotNil(t, mockK8sClient.ComplianceCondition) {
+		assert.False(t, mockK8sClient.ComplianceCondition.Compliant)
+		assert.Equal(t, sdk.ReasonInsufficientLicenses, mockK8sClient.ComplianceCondition.Reason)
+	}
+
+	// the condition is opt-in
+	disabledK8sClient := mocks.NewMockK8sClient(nil)
+	disabled := AWS{aws: mocks.NewMockAWSClient(2), k8s: disabledK8sClient, scraper: mocks.NewMockScraper(30)}
+	assert.NoError(t, disabled.runComplianceCheck(context.TODO()))
+	assert.Nil(t, disabledK8sClient.ComplianceCondition)
+}
+
+//TestRestoredState tests that checkout info created by another cluster is discarded and its token checked in
+func TestRestoredState(t *testing.T) {
+	mockAWSClient := mocks.NewMockAWSClient(2)
+	output, _ := mockAWSClient.CheckoutRancherLicense(context.TODO(), mockAWSClient.License, 1)
+	restoredToken := *output.LicenseConsumptionToken
+	mockK8sClient := mocks.NewMockK8sClient(map[string]string{
+		tokenKey:   restoredToken,
+		expiryKey:  *output.Expiration,
+		nodeKey:    "1",
+		clusterKey: "old-cluster",
+	})
+	mockK8sClient.ClusterUID = "new-cluster"
+	mockAWS := AWS{
+		aws:     mockAWSClient,
+		k8s:     mockK8sClient,
+		scraper: mocks.NewMockScraper(20),
+	}
+	err := mockAWS.runComplianceCheck(context.TODO())
+	assert.NoError(t, err)
+	_, ok := mockAWSClient.CheckedOutEntitlements[restoredToken]
+	assert.False(t, ok, "token from the restored state should be checked in")
+	assert.NotEqual(t, restoredToken, mockK8sClient.CurrentSecretData[tokenKey], "a new token should be checked out")
+	assert.Equal(t, "new-cluster", mockK8sClient.CurrentSecretData[clusterKey], "state should be owned by the current cluster")
+	assert.Equal(t, 1, len(mockAWSClient.CheckedOutEntitlements), "only the new checkout should remain")
+}
+
+//TestMinimumLicenses tests that the configured minimum is kept checked out when fewer licenses are needed
+func TestMinimumLicenses(t *testing.T) {
+	tests := []struct {
+		name            string
+		nodes           int
+		minimum         int
+		expectedLicense int
+	}{
+		{name: "no nodes", nodes: 0, minimum: 2, expectedLicense: 2},
+		{name: "fewer nodes than minimum", nodes: 20, minimum: 2, expectedLicense: 2},
+		{name: "more nodes than minimum", nodes: 60, minimum: 2, expectedLicense: 3},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			mockAWSClient := mocks.NewMockAWSClient(5)
+			mockAWS := AWS{
+				aws:     mockAWSClient,
+				k8s:     mocks.NewMockK8sClient(nil),
+				scraper: mocks.NewMockScraper(test.nodes),
+				opts:    Options{MinimumLicenses: test.minimum},
+			}
+			assert.NoError(t, mockAWS.runComplianceCheck(context.TODO()))
+			checkedOut := 0
+			for _, value := range mockAWSClient.CheckedOutEntitlements {
+				checkedOut += value
+			}
+			assert.Equal(t, test.expectedLicense, checkedOut)
+			assert.Equal(t, test.expectedLicense, mockAWS.Status().Usage.RequiredLicenses)
+			assert.Equal(t, sdk.ComplianceStatusCompliant, mockAWS.Status().Compliance.Status)
+		})
+	}
+}
+
+//TestLicenseNotUsable tests that licenses which can't be checked out are reported with precise reasons
+func TestLicenseNotUsable(t *testing.T) {
+	mockAWSClient := mocks.NewMockAWSClient(5)
+	mockAWSClient.License.Status = types.LicenseStatusExpired
+	mockAWS := NewAWS(mockAWSClient, mocks.NewMockK8sClient(nil), mocks.NewMockScraper(20), Options{})
+	err := mockAWS.runComplianceCheck(context.TODO())
+	reason, _ := describeError(err, time.UTC)
+	assert.Equal(t, sdk.ReasonLicenseExpired, reason)
+	assert.Empty(t, mockAWSClient.CheckedOutEntitlements, "nothing should be checked out from an expired license")
+
+	reason, _ = describeError(&aws.LicenseStatusError{Status: types.LicenseStatusSuspended}, time.UTC)
+	assert.Equal(t, sdk.ReasonLicenseNotAvailable, reason)
+	reason, _ = describeError(&aws.ValidityError{NotYetValid: true}, time.UTC)
+	assert.Equal(t, sdk.ReasonLicenseNotYetValid, reason)
+	reason, _ = describeError(&aws.IssuerError{}, time.UTC)
+	assert.Equal(t, sdk.ReasonIssuerUnknown, reason)
+}
+
+//TestDescribeErrorLocation tests that the times in notifications are rendered in the reporting timezone
+func TestDescribeErrorLocation(t *testing.T) {
+	err := &aws.ValidityError{End: time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)}
+	_, notification := describeError(err, time.UTC)
+	assert.Contains(t, notification, "2022-06-01T00:00:00Z")
+	_, notification = describeError(err, time.FixedZone("EDT", -4*60*60))
+	assert.Contains(t, notification, "2022-05-31T20:00:00-04:00")
+}
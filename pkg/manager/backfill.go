@@ -0,0 +1,27 @@
+package manager
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// backfillUsage estimates the usage of the hours before the adapter started in which it wasn't running, from the
+// registration times of rancher's nodes. It's only attempted once, since the gap can't be told apart from observed
+// hours after the first observation. Must be called while holding the checkLock
+func (m *AWS) backfillUsage(now time.Time) {
+	m.usageBackfilled = true
+	created, err := m.k8s.GetNodeCreationTimes()
+	if err != nil {
+		logrus.Warnf("[manager] unable to get node history, usage history won't be backfilled: %v", err)
+		return
+	}
+	backfilled, err := m.usage.Backfill(created, m.requiredLicenses, now)
+	if err != nil {
+		logrus.Warnf("[manager] unable to backfill usage history: %v", err)
+		return
+	}
+	if backfilled > 0 {
+		logrus.Infof("[manager] backfilled %d hours of usage history with estimates for the time the adapter wasn't running", backfilled)
+	}
+}
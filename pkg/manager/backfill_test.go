@@ -0,0 +1,34 @@
+package manager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/mocks"
+	"github.com/rancher/csp-adapter/pkg/usage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackfillUsage(t *testing.T) {
+	mockK8s := mocks.NewMockK8sClient(nil)
+	lastHour := time.Now().UTC().Truncate(time.Hour).Add(-3 * time.Hour)
+	require.NoError(t, usage.NewRecorder(mockK8s, usage.Retention{}).Observe(20, 0, 1, true, lastHour))
+	for i := 0; i < 20; i++ {
+		mockK8s.NodeCreationTimes = append(mockK8s.NodeCreationTimes, lastHour.Add(-time.Hour))
+	}
+
+	mockAWS := NewAWS(mocks.NewMockAWSClient(5), mockK8s, mocks.NewMockScraper(20), Options{MinimumLicenses: 2})
+	require.NoError(t, mockAWS.runComplianceCheck(context.Background()))
+
+	records, err := usage.Load(mockK8s, lastHour, time.Now())
+	require.NoError(t, err)
+	require.Len(t, records, 4)
+	for _, record := range records[1:3] {
+		assert.True(t, record.Estimated)
+		assert.Equal(t, 20, record.PeakNodes)
+		assert.Equal(t, 2, record.PeakRequiredLicenses, "estimates should keep the minimum licenses")
+	}
+	assert.False(t, records[3].Estimated)
+}
@@ -0,0 +1,109 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/metrics"
+	"github.com/sirupsen/logrus"
+)
+
+// phaseSlices are the fractions of the check budget allotted to each phase, in the order they run. The checkout phase,
+// which renews the checkout, gets the largest slice. Checking in and checking out a changed number of licenses isn't
+// limited by it, so that a check can't stop between the two and leave rancher without licenses
+var phaseSlices = map[string]float64{
+	phaseLicense:   0.1,
+	phaseCount:     0.3,
+	phaseCheckout:  0.35,
+	phaseAnomalies: 0.05,
+	phaseHistory:   0.05,
+	phaseStatus:    0.1,
+	phaseSummaries: 0.05,
+}
+
+// checkBudget allots each phase of a compliance check a slice of the check's budget. A phase may use the time the
+// phases before it left over, but overruns never shorten the slice of a later phase: critical phases get at least
+// their full slice and their calls are cancelled at its end, optional phases are skipped once the check has overrun. A
+// nil budget doesn't limit the check
+type checkBudget struct {
+	now     func() time.Time
+	started time.Time
+	total   time.Duration
+	// allotted is the end of the slices of the phases begun so far, relative to started
+	allotted time.Duration
+}
+
+// newCheckBudget returns a budget of total for a check starting now, nil if total isn't positive
+func newCheckBudget(total time.Duration, now func() time.Time) *checkBudget {
+	if total <= 0 {
+		return nil
+	}
+	return &checkBudget{now: now, started: now(), total: total}
+}
+
+// slice returns the time allotted to phase
+func (b *checkBudget) slice(phase string) time.Duration {
+	return time.Duration(float64(b.total) * phaseSlices[phase])
+}
+
+// begin returns the context of the critical phase, which is done at the end of its slice
+func (b *checkBudget) begin(ctx context.Context, phase string) (context.Context, context.CancelFunc) {
+	if b == nil {
+		return context.WithCancel(ctx)
+	}
+	return context.WithDeadline(ctx, b.reserve(phase))
+}
+
+// reserve allots the critical phase its slice, returning its end: the end of the slices of the phases before it and its
+// own, or a full slice from now if the phases before it overran
+func (b *checkBudget) reserve(phase string) time.Time {
+	if b == nil {
+		return time.Time{}
+	}
+	slice := b.slice(phase)
+	b.allotted += slice
+	deadline := b.started.Add(b.allotted)
+	if earliest := b.now().Add(slice); deadline.Before(earliest) {
+		deadline = earliest
+	}
+	return deadline
+}
+
+// allows returns whether the optional phase runs, which it doesn't once the check has overrun the slices of the phases
+// before it. Skipped phases are logged and counted
+func (b *checkBudget) allows(phase string) bool {
+	if b == nil {
+		return true
+	}
+	overrun := b.now().Sub(b.started.Add(b.allotted))
+	b.allotted += b.slice(phase)
+	if overrun <= 0 {
+		return true
+	}
+	logrus.Warnf("[manager] skipping the %s phase of the compliance check, the check overran its %s budget by %s",
+		phase, b.total, overrun.Round(time.Millisecond))
+	metrics.CheckPhasesSkipped.WithLabelValues(phase).Inc()
+	return false
+}
+
+// countNodes counts nodes until ctx is done. A count which overruns is cancelled and handled like a failed count, so
+// that the checkout is still renewed in time
+func (m *AWS) countNodes(ctx context.Context) (*metrics.NodeCounts, error) {
+	counts, err := m.scraper.ScrapeAndParse(ctx)
+	if err != nil && ctx.Err() != nil {
+		return nil, fmt.Errorf("counting nodes overran its slice of the check budget: %w", ctx.Err())
+	}
+	return counts, err
+}
+
+// renewWithinBudget renews the current checkout between scheduled compliance checks, cancelling calls which overrun
+// the check budget
+func (m *AWS) renewWithinBudget(ctx context.Context) (bool, error) {
+	if m.opts.CheckBudget > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.opts.CheckBudget)
+		defer cancel()
+	}
+	return m.renewCurrentCheckout(ctx)
+}
@@ -0,0 +1,90 @@
+package manager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/metrics"
+	"github.com/rancher/csp-adapter/pkg/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckBudget(t *testing.T) {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+	budget := newCheckBudget(10*time.Second, func() time.Time { return now })
+
+	ctx, cancel := budget.begin(context.Background(), phaseLicense)
+	defer cancel()
+	deadline, _ := ctx.Deadline()
+	assert.Equal(t, start.Add(time.Second), deadline)
+
+	now = now.Add(500 * time.Millisecond)
+	ctx, cancel = budget.begin(context.Background(), phaseCount)
+	defer cancel()
+	deadline, _ = ctx.Deadline()
+	assert.Equal(t, start.Add(4*time.Second), deadline, "time left over by earlier phases can be used")
+
+	now = start.Add(6 * time.Second)
+	ctx, cancel = budget.begin(context.Background(), phaseCheckout)
+	defer cancel()
+	deadline, _ = ctx.Deadline()
+	assert.Equal(t, now.Add(3500*time.Millisecond), deadline, "the checkout gets its full slice after counting overran")
+
+	now = start.Add(8500 * time.Millisecond)
+	assert.False(t, budget.allows(phaseAnomalies), "optional phases are skipped once the check overran")
+	assert.False(t, budget.allows(phaseHistory))
+	assert.Equal(t, now.Add(time.Second), budget.reserve(phaseStatus))
+
+	budget = newCheckBudget(10*time.Second, func() time.Time { return now })
+	assert.True(t, budget.allows(phaseAnomalies), "optional phases run within the budget")
+
+	var unlimited *checkBudget
+	assert.Nil(t, newCheckBudget(0, time.Now))
+	assert.True(t, unlimited.allows(phaseHistory))
+	ctx, cancel = unlimited.begin(context.Background(), phaseCount)
+	defer cancel()
+	_, ok := ctx.Deadline()
+	assert.False(t, ok, "checks without a budget aren't limited")
+}
+
+// slowScraper counts nodes once release is closed, unless ctx is done first
+type slowScraper struct {
+	*mocks.MockScraper
+	release  chan struct{}
+	returned chan struct{}
+}
+
+func (s slowScraper) ScrapeAndParse(ctx context.Context) (*metrics.NodeCounts, error) {
+	defer close(s.returned)
+	select {
+	case <-s.release:
+		return s.MockScraper.ScrapeAndParse(ctx)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func TestSlowNodeCountRenewsCheckout(t *testing.T) {
+	mockK8s := mocks.NewMockK8sClient(nil)
+	manager := NewAWS(mocks.NewMockAWSClient(5), mockK8s, mocks.NewMockScraper(20),
+		Options{CheckBudget: 100 * time.Millisecond, NodeCountFailureThreshold: 3})
+	require.NoError(t, manager.runComplianceCheck(context.Background()))
+	token := mockK8s.CurrentSecretData[tokenKey]
+
+	scraper := slowScraper{MockScraper: mocks.NewMockScraper(20), release: make(chan struct{}), returned: make(chan struct{})}
+	defer close(scraper.release)
+	manager.scraper = scraper
+	started := time.Now()
+	assert.NoError(t, manager.runComplianceCheck(context.Background()), "an overrunning count is tolerated like a failed one")
+	assert.Less(t, int64(time.Since(started)), int64(time.Second), "the count should be cancelled at its deadline")
+	select {
+	case <-scraper.returned:
+	default:
+		assert.Fail(t, "the scrape should have returned once its context was cancelled")
+	}
+	assert.Equal(t, 1, manager.nodeCountFailures)
+	assert.Equal(t, token, mockK8s.CurrentSecretData[tokenKey], "the checkout is kept and renewed")
+}
@@ -0,0 +1,72 @@
+package manager
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	lm "github.com/aws/aws-sdk-go-v2/service/licensemanager"
+)
+
+// CachedCheckout is the checkout recorded in the cache secret of an install, for commands which move a checkout
+// between installs. The adapter adopts a checkout recorded by them on its next compliance check
+type CachedCheckout struct {
+	ConsumptionToken string
+	Licenses         int
+	Expiry           time.Time
+	// LicenseArn is empty for checkouts recorded by older releases
+	LicenseArn string
+}
+
+// NewCachedCheckout returns the checkout of licenses made by res
+func NewCachedCheckout(res *lm.CheckoutLicenseOutput, licenses int) CachedCheckout {
+	return CachedCheckout{
+		ConsumptionToken: awssdk.ToString(res.LicenseConsumptionToken),
+		Licenses:         licenses,
+		Expiry:           parseExpirationTimestamp(awssdk.ToString(res.Expiration)),
+		LicenseArn:       awssdk.ToString(res.LicenseArn),
+	}
+}
+
+// ReadCachedCheckout reads the checkout recorded in the data of a cache secret. Every release of the adapter recorded
+// the token, licenses and expiry of its checkout under the same keys, so the data of any release can be read
+func ReadCachedCheckout(data map[string][]byte) (CachedCheckout, error) {
+	token, tOk := data[tokenKey]
+	licenses, lOk := data[nodeKey]
+	expiry, eOk := data[expiryKey]
+	if !(tOk && lOk && eOk) {
+		// if we couldn't extract the token or node counts, we can't return accurate checkout info
+		return CachedCheckout{}, fmt.Errorf("couldn't license consumption info from secret")
+	}
+	numLicenses, err := strconv.Atoi(string(licenses))
+	if err != nil {
+		return CachedCheckout{}, fmt.Errorf("unable to parse the number of nodes the license token is for %v", err)
+	}
+	expiryTime, err := time.Parse(time.RFC3339, string(expiry))
+	if err != nil {
+		return CachedCheckout{}, fmt.Errorf("unable to parse the token's expiry time %v", err)
+	}
+	return CachedCheckout{
+		ConsumptionToken: string(token),
+		Licenses:         numLicenses,
+		Expiry:           expiryTime,
+		LicenseArn:       string(data[licenseKey]),
+	}, nil
+}
+
+// Data returns the data of a cache secret recording the checkout. The extensions and pending check-ins recorded for a
+// previous checkout are cleared, the cluster uid is left to the adapter adopting the checkout
+func (c CachedCheckout) Data() map[string]string {
+	data := map[string]string{
+		tokenKey:     c.ConsumptionToken,
+		nodeKey:      strconv.Itoa(c.Licenses),
+		expiryKey:    c.Expiry.Format(time.RFC3339),
+		extensionKey: "0",
+		checkInKey:   "",
+	}
+	if c.LicenseArn != "" {
+		data[licenseKey] = c.LicenseArn
+	}
+	return data
+}
@@ -0,0 +1,38 @@
+package manager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCachedCheckout(t *testing.T) {
+	checkout := CachedCheckout{
+		ConsumptionToken: "token",
+		Licenses:         3,
+		Expiry:           time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+		LicenseArn:       "arn",
+	}
+	data := map[string][]byte{}
+	for key, value := range checkout.Data() {
+		data[key] = []byte(value)
+	}
+	read, err := ReadCachedCheckout(data)
+	require.NoError(t, err)
+	assert.Equal(t, checkout, read)
+
+	// the layout of the first releases
+	read, err = ReadCachedCheckout(map[string][]byte{
+		tokenKey:  []byte("token"),
+		nodeKey:   []byte("2"),
+		expiryKey: []byte("2022-01-01T00:00:00Z"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, read.Licenses)
+	assert.Empty(t, read.LicenseArn)
+
+	_, err = ReadCachedCheckout(map[string][]byte{tokenKey: []byte("token")})
+	assert.Error(t, err)
+}
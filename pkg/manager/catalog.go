@@ -0,0 +1,52 @@
+package manager
+
+import (
+	"context"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/rancher/csp-adapter/pkg/sdk"
+)
+
+// Products returns the rancher products known to the adapter and the licenses received for them. The catalog only
+// changes when offers are accepted or licenses expire, it's served from the read cache
+func (m *AWS) Products(ctx context.Context) ([]sdk.Product, sdk.Freshness, error) {
+	products, freshness, err := m.catalog.get(ctx)
+	if err != nil {
+		return nil, sdk.Freshness{}, err
+	}
+	return products.([]sdk.Product), freshness, nil
+}
+
+// Entitlements returns the usage of the entitlements of the rancher license, served from the read cache so that api
+// callers don't wait for License Manager
+func (m *AWS) Entitlements(ctx context.Context) (sdk.Entitlements, error) {
+	entitlements, freshness, err := m.entitlements.get(ctx)
+	if err != nil {
+		return sdk.Entitlements{}, err
+	}
+	result := entitlements.(sdk.Entitlements)
+	result.Freshness = freshness
+	return result, nil
+}
+
+func (m *AWS) fetchProducts(ctx context.Context) (interface{}, error) {
+	return m.aws.ListProducts(ctx)
+}
+
+func (m *AWS) fetchEntitlements(ctx context.Context) (interface{}, error) {
+	license, err := m.aws.GetRancherLicense(ctx)
+	if err != nil {
+		return nil, err
+	}
+	usage, err := m.aws.GetEntitlementUsage(ctx, *license)
+	if err != nil {
+		return nil, err
+	}
+	return sdk.Entitlements{
+		LicenseArn: awssdk.ToString(license.LicenseArn),
+		Dimension:  usage.Dimension,
+		Max:        usage.Max,
+		Consumed:   usage.Consumed,
+		Available:  usage.Available(),
+	}, nil
+}
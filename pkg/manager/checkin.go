@@ -0,0 +1,99 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/metrics"
+	"github.com/sirupsen/logrus"
+)
+
+// maxTokenLifetime is how long License Manager keeps a consumption token which isn't extended, used to age out pending
+// check-ins of tokens whose expiry isn't known
+const maxTokenLifetime = time.Hour
+
+// pendingCheckIn is a token the adapter no longer holds but couldn't check in, i.e. because License Manager throttled
+// the call. It's retried by every check until it succeeds or the token expires, at which point License Manager has
+// returned its licenses anyway
+type pendingCheckIn struct {
+	Token string `json:"token"`
+	// Until is when the token expires and the check-in is given up
+	Until time.Time `json:"until"`
+}
+
+// checkIn checks in token, which expires at expiry. If the check-in fails, it's queued in info so that it's retried by
+// later checks, also after a restart once info is saved. Must be called while holding the checkLock
+func (m *AWS) checkIn(ctx context.Context, token string, expiry time.Time, info *licenseCheckoutInfo) error {
+	_, err := m.aws.CheckInRancherLicense(ctx, token)
+	if err == nil {
+		return nil
+	}
+	for _, pending := range info.PendingCheckIns {
+		if pending.Token == token {
+			return err
+		}
+	}
+	if expiry.IsZero() {
+		expiry = time.Now().Add(maxTokenLifetime)
+	}
+	info.PendingCheckIns = append(info.PendingCheckIns, pendingCheckIn{Token: token, Until: expiry})
+	metrics.PendingCheckIns.Set(float64(len(info.PendingCheckIns)))
+	return err
+}
+
+// retryCheckIns retries the check-ins queued in info, dropping those which succeeded and those whose token expired by
+// now. The caller saves info with the result. Must be called while holding the checkLock
+func (m *AWS) retryCheckIns(ctx context.Context, info *licenseCheckoutInfo, now time.Time) {
+	var retried []pendingCheckIn
+	var tokens []string
+	for _, pending := range info.PendingCheckIns {
+		if pending.Token == info.ConsumptionToken {
+			// held again, i.e. because no other checkout replaced it, so it mustn't be checked in
+			continue
+		}
+		if !now.Before(pending.Until) {
+			logrus.Infof("[manager] giving up on checking in a token which expired at %s, its licenses were returned when it expired",
+				pending.Until.Format(time.RFC3339))
+			continue
+		}
+		retried = append(retried, pending)
+		tokens = append(tokens, pending.Token)
+	}
+	results := m.aws.CheckInRancherLicenses(ctx, tokens)
+	var remaining []pendingCheckIn
+	for _, pending := range retried {
+		if err := results[pending.Token]; err != nil {
+			logrus.Debugf("[manager] retry of pending check-in failed, retrying until %s: %v", pending.Until.Format(time.RFC3339), err)
+			remaining = append(remaining, pending)
+			continue
+		}
+		logrus.Infof("[manager] checked in a token whose check-in failed before")
+	}
+	info.PendingCheckIns = remaining
+	metrics.PendingCheckIns.Set(float64(len(remaining)))
+}
+
+// parsePendingCheckIns parses the check-ins queued in the cache secret. Absent for info saved by older versions
+func parsePendingCheckIns(data []byte) []pendingCheckIn {
+	if len(data) == 0 {
+		return nil
+	}
+	var pending []pendingCheckIn
+	if err := json.Unmarshal(data, &pending); err != nil {
+		logrus.Warnf("[manager] unable to parse pending check-ins, their tokens will expire on their own: %v", err)
+		return nil
+	}
+	return pending
+}
+
+// formatPendingCheckIns formats check-ins for the cache secret, empty if there are none so that a queue saved before is
+// cleared
+func formatPendingCheckIns(pending []pendingCheckIn) string {
+	if len(pending) == 0 {
+		return ""
+	}
+	// can't fail, pendingCheckIn only holds strings and times
+	data, _ := json.Marshal(pending)
+	return string(data)
+}
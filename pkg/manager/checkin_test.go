@@ -0,0 +1,89 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPendingCheckIns(t *testing.T) {
+	tests := []struct {
+		name string
+		// expire moves the pending check-in past the expiry of its token before the retry
+		expire bool
+	}{
+		{name: "retried after restart"},
+		{name: "aged out once the token expired", expire: true},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			mockAWSClient := mocks.NewMockAWSClient(5)
+			mockK8s := mocks.NewMockK8sClient(nil)
+			mockAWS := NewAWS(mockAWSClient, mockK8s, mocks.NewMockScraper(20), Options{InstanceID: "adapter"})
+			ctx := context.Background()
+			require.NoError(t, mockAWS.runComplianceCheck(ctx))
+			oldToken := mockK8s.CurrentSecretData[tokenKey]
+
+			// scaling up replaces the checkout, but the old token can't be checked in
+			mockAWSClient.CheckInErr = fmt.Errorf("throttled")
+			mockAWS.scraper = mocks.NewMockScraper(60)
+			require.NoError(t, mockAWS.runComplianceCheck(ctx))
+			newToken := mockK8s.CurrentSecretData[tokenKey]
+			assert.NotEqual(t, oldToken, newToken)
+			assert.Equal(t, map[string]int{oldToken: 1, newToken: 3}, mockAWSClient.CheckedOutEntitlements)
+			assert.Equal(t, 1, mockAWS.Status().Usage.PendingCheckIns)
+			pending := parsePendingCheckIns([]byte(mockK8s.CurrentSecretData[checkInKey]))
+			require.Len(t, pending, 1)
+			assert.Equal(t, oldToken, pending[0].Token)
+
+			if test.expire {
+				pending[0].Until = time.Now().Add(-time.Minute)
+				mockK8s.CurrentSecretData[checkInKey] = formatPendingCheckIns(pending)
+			}
+			// a restarted adapter retries the check-in
+			mockAWSClient.CheckInErr = nil
+			restarted := NewAWS(mockAWSClient, mockK8s, mocks.NewMockScraper(60), Options{InstanceID: "adapter"})
+			require.NoError(t, restarted.runComplianceCheck(ctx))
+			assert.Empty(t, mockK8s.CurrentSecretData[checkInKey])
+			assert.Equal(t, 0, restarted.Status().Usage.PendingCheckIns)
+			if test.expire {
+				assert.Contains(t, mockAWSClient.CheckedOutEntitlements, oldToken, "an expired token shouldn't be checked in")
+				return
+			}
+			assert.Equal(t, map[string]int{newToken: 3}, mockAWSClient.CheckedOutEntitlements)
+		})
+	}
+}
+
+func TestCheckInAllPendingCheckIns(t *testing.T) {
+	mockAWSClient := mocks.NewMockAWSClient(5)
+	mockK8s := mocks.NewMockK8sClient(nil)
+	mockAWS := NewAWS(mockAWSClient, mockK8s, mocks.NewMockScraper(20), Options{InstanceID: "adapter"})
+	ctx := context.Background()
+	require.NoError(t, mockAWS.runComplianceCheck(ctx))
+	// scaling up replaces the checkout, but the old token can't be checked in
+	mockAWSClient.CheckInErr = fmt.Errorf("throttled")
+	mockAWS.scraper = mocks.NewMockScraper(60)
+	require.NoError(t, mockAWS.runComplianceCheck(ctx))
+	require.Len(t, parsePendingCheckIns([]byte(mockK8s.CurrentSecretData[checkInKey])), 1)
+
+	mockAWSClient.CheckInErr = nil
+	var progress []string
+	require.NoError(t, mockAWS.CheckInAll(ctx, func(message string) { progress = append(progress, message) }))
+	assert.Empty(t, mockAWSClient.CheckedOutEntitlements, "the held and the pending tokens are checked in")
+	assert.Empty(t, mockK8s.CurrentSecretData[tokenKey])
+	assert.Empty(t, mockK8s.CurrentSecretData[checkInKey])
+	assert.Contains(t, progress, "checking in 1 token(s) whose check-in failed before")
+
+	require.NoError(t, mockAWS.runComplianceCheck(ctx))
+	assert.Empty(t, mockAWSClient.CheckedOutEntitlements, "checks don't check out again until adjustments are resumed")
+	mockAWS.Resume("admin")
+	require.NoError(t, mockAWS.runComplianceCheck(ctx))
+	assert.NotEmpty(t, mockAWSClient.CheckedOutEntitlements)
+}
@@ -0,0 +1,156 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/licensemanager/types"
+	"github.com/rancher/csp-adapter/pkg/clients/aws"
+	"github.com/rancher/csp-adapter/pkg/clients/k8s"
+	"github.com/rancher/csp-adapter/pkg/metrics"
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/sirupsen/logrus"
+)
+
+// reconcileCheckoutRequests executes the LicenseCheckoutRequests, whose checkouts are held separately from the
+// adapter's own checkout. Pending requests are checked out from license unless adjustments are held or license is nil,
+// active ones are extended until they expire and are then checked in and deleted. If the requests can't be read, the
+// previous ones are reported. Must be called while holding the checkLock
+func (m *AWS) reconcileCheckoutRequests(ctx context.Context, license *types.GrantedLicense, held bool, now time.Time) {
+	requests, err := m.k8s.GetCheckoutRequests()
+	if err != nil {
+		logrus.Warnf("[manager] unable to get license checkout requests: %v", err)
+		return
+	}
+	sort.Slice(requests, func(i, j int) bool {
+		return requests[i].Name < requests[j].Name
+	})
+	var usage *aws.EntitlementUsage
+	reported := make([]sdk.CheckoutRequest, 0, len(requests))
+	requested := 0
+	for _, request := range requests {
+		status := request.Status
+		switch {
+		case request.SpecError != "":
+			status = k8s.CheckoutRequestStatus{Phase: k8s.CheckoutRequestFailed, Message: request.SpecError}
+		case status.Phase == k8s.CheckoutRequestActive && !now.Before(status.Expires):
+			if _, err := m.aws.CheckInRancherLicense(ctx, status.ConsumptionToken); err != nil {
+				logrus.Warnf("[manager] unable to check in the checkout of license checkout request %s, will retry with later checks: %v", request.Name, err)
+				status.Message = fmt.Sprintf("expired, unable to check in: %v", err)
+				break
+			}
+			logrus.Infof("[manager] checked in %d license(s) of license checkout request %s, which expired", request.Count, request.Name)
+			if err := m.k8s.DeleteCheckoutRequest(request.Name); err != nil {
+				logrus.Warnf("[manager] unable to delete expired license checkout request %s: %v", request.Name, err)
+			}
+			continue
+		case status.Phase == k8s.CheckoutRequestActive:
+			if status.TokenExpiry.Sub(now) >= m.extensionMargin(now) {
+				break
+			}
+			resp, err := m.aws.ExtendRancherLicenseConsumptionToken(ctx, status.ConsumptionToken)
+			if err != nil {
+				// the token lapses once it expires, which isn't retried since the entitlements may be taken by then
+				logrus.Warnf("[manager] unable to extend the checkout of license checkout request %s: %v", request.Name, err)
+				status.Phase, status.Message = k8s.CheckoutRequestFailed, fmt.Sprintf("unable to extend the checkout: %v", err)
+				break
+			}
+			status.TokenExpiry = parseExpirationTimestamp(awssdk.ToString(resp.Expiration))
+		case status.Phase == k8s.CheckoutRequestFailed:
+			if !status.Expires.IsZero() && !now.Before(status.Expires) {
+				if err := m.k8s.DeleteCheckoutRequest(request.Name); err != nil {
+					logrus.Warnf("[manager] unable to delete expired license checkout request %s: %v", request.Name, err)
+				}
+				continue
+			}
+		case license == nil:
+			// pending requests are checked out by the next full compliance check
+		case held:
+			status.Phase, status.Message = k8s.CheckoutRequestPending, "waiting until checkout adjustments resume"
+		default:
+			if usage == nil {
+				current, err := m.aws.GetEntitlementUsage(ctx, *license)
+				if err != nil {
+					status.Phase, status.Message = k8s.CheckoutRequestPending, fmt.Sprintf("unable to get the usage of the license: %v", err)
+					break
+				}
+				usage = &current
+			}
+			status = m.executeCheckoutRequest(ctx, *license, request, usage, now)
+		}
+		if status.Phase == k8s.CheckoutRequestActive {
+			requested += request.Count
+		}
+		if status != request.Status {
+			if err := m.k8s.UpdateCheckoutRequestStatus(request.Name, status); err != nil {
+				logrus.Warnf("[manager] unable to update the status of license checkout request %s: %v", request.Name, err)
+				if status.Phase == k8s.CheckoutRequestActive && request.Status.Phase != k8s.CheckoutRequestActive {
+					// the token of a checkout which isn't recorded would never be checked in
+					m.releaseCheckoutRequest(ctx, request.Name, status.ConsumptionToken)
+					requested -= request.Count
+					status = request.Status
+				}
+			}
+		}
+		reported = append(reported, sdk.CheckoutRequest{
+			Name:    request.Name,
+			Count:   request.Count,
+			Reason:  request.Reason,
+			Phase:   status.Phase,
+			Message: status.Message,
+			Expires: sdk.OptionalTime(status.Expires),
+		})
+	}
+	m.checkoutRequests = reported
+	m.requestedLicenses = requested
+	metrics.RequestedLicenses.Set(float64(requested))
+}
+
+// renewCheckoutRequests extends and expires the checkouts of active LicenseCheckoutRequests without a full compliance
+// check, i.e. between the windows of a schedule or while nodes can't be counted. Must be called while holding the
+// checkLock
+func (m *AWS) renewCheckoutRequests(ctx context.Context, now time.Time) {
+	m.reconcileCheckoutRequests(ctx, nil, false, now)
+}
+
+// executeCheckoutRequest checks out the licenses of a pending request, given the usage of license which is reduced by
+// the checkout. Returns the status of the request
+func (m *AWS) executeCheckoutRequest(ctx context.Context, license types.GrantedLicense, request k8s.CheckoutRequest, usage *aws.EntitlementUsage, now time.Time) k8s.CheckoutRequestStatus {
+	if request.Dimension != "" && request.Dimension != usage.Dimension {
+		return k8s.CheckoutRequestStatus{
+			Phase:   k8s.CheckoutRequestFailed,
+			Message: fmt.Sprintf("the license grants %s entitlements, %s can't be checked out", usage.Dimension, request.Dimension),
+		}
+	}
+	if available := usage.Available(); request.Count > available {
+		return k8s.CheckoutRequestStatus{
+			Phase:   k8s.CheckoutRequestPending,
+			Message: fmt.Sprintf("waiting for %d license(s) to be available, %d are available", request.Count, available),
+		}
+	}
+	// retried checkouts of the request are idempotent, in case its status couldn't be updated after the checkout
+	resp, err := m.aws.CheckoutRancherLicense(aws.WithCheckoutToken(ctx, request.UID), license, request.Count)
+	if err != nil {
+		return k8s.CheckoutRequestStatus{Phase: k8s.CheckoutRequestPending, Message: fmt.Sprintf("unable to check out: %v", err)}
+	}
+	usage.Consumed += request.Count
+	logrus.Infof("[manager] checked out %d license(s) for license checkout request %s until %s", request.Count,
+		request.Name, formatTime(now.Add(request.Duration), m.location()))
+	return k8s.CheckoutRequestStatus{
+		Phase:            k8s.CheckoutRequestActive,
+		ConsumptionToken: awssdk.ToString(resp.LicenseConsumptionToken),
+		CheckedOutAt:     now,
+		Expires:          now.Add(request.Duration),
+		TokenExpiry:      parseExpirationTimestamp(awssdk.ToString(resp.Expiration)),
+	}
+}
+
+// releaseCheckoutRequest checks in the checkout made for the request name
+func (m *AWS) releaseCheckoutRequest(ctx context.Context, name, token string) {
+	if _, err := m.aws.CheckInRancherLicense(ctx, token); err != nil {
+		logrus.Warnf("[manager] unable to check in the checkout of license checkout request %s, it's held until it expires: %v", name, err)
+	}
+}
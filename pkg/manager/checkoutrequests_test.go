@@ -0,0 +1,117 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/clients/k8s"
+	"github.com/rancher/csp-adapter/pkg/mocks"
+	"github.com/rancher/csp-adapter/pkg/schedule"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func checkoutRequest(t *testing.T, mockK8s *mocks.MockK8sClient, name string) k8s.CheckoutRequest {
+	t.Helper()
+	for _, request := range mockK8s.CheckoutRequests {
+		if request.Name == name {
+			return request
+		}
+	}
+	require.Failf(t, "checkout request not found", "no checkout request %s", name)
+	return k8s.CheckoutRequest{}
+}
+
+func TestCheckoutRequests(t *testing.T) {
+	mockAWS := mocks.NewMockAWSClient(10)
+	mockK8s := mocks.NewMockK8sClient(nil)
+	mockK8s.CheckoutRequests = []k8s.CheckoutRequest{
+		{Name: "burst", UID: "uid-burst", Count: 3, Duration: 2 * time.Hour, Reason: "load test"},
+		{Name: "too-big", UID: "uid-too-big", Count: 20, Duration: time.Hour},
+		{Name: "other-dimension", UID: "uid-other", Dimension: "RANCHER_USER", Count: 1, Duration: time.Hour},
+		{Name: "invalid", UID: "uid-invalid", SpecError: "spec.count must be at least 1"},
+	}
+	manager := NewAWS(mockAWS, mockK8s, mocks.NewMockScraper(30), Options{})
+	require.NoError(t, manager.runComplianceCheck(context.Background()))
+
+	burst := checkoutRequest(t, mockK8s, "burst")
+	assert.Equal(t, k8s.CheckoutRequestActive, burst.Status.Phase)
+	assert.Equal(t, 3, mockAWS.CheckedOutEntitlements[burst.Status.ConsumptionToken])
+	assert.WithinDuration(t, time.Now().Add(2*time.Hour), burst.Status.Expires, time.Minute)
+	assert.Equal(t, k8s.CheckoutRequestPending, checkoutRequest(t, mockK8s, "too-big").Status.Phase)
+	assert.Equal(t, k8s.CheckoutRequestFailed, checkoutRequest(t, mockK8s, "other-dimension").Status.Phase)
+	assert.Equal(t, k8s.CheckoutRequestFailed, checkoutRequest(t, mockK8s, "invalid").Status.Phase)
+
+	usage := manager.Status().Usage
+	assert.Equal(t, 3, usage.RequestedLicenses)
+	assert.Equal(t, 2, usage.CheckedOutLicenses, "requests are checked out separately")
+	assert.Equal(t, 0, usage.ExternalLicenses, "requests aren't checked out outside of the adapter")
+	assert.Len(t, usage.CheckoutRequests, 4)
+
+	require.NoError(t, manager.runComplianceCheck(context.Background()))
+	assert.Equal(t, burst.Status.ConsumptionToken, checkoutRequest(t, mockK8s, "burst").Status.ConsumptionToken,
+		"active requests aren't checked out again")
+	assert.Equal(t, 3, manager.Status().Usage.RequestedLicenses)
+
+	for i := range mockK8s.CheckoutRequests {
+		if mockK8s.CheckoutRequests[i].Name == "burst" {
+			mockK8s.CheckoutRequests[i].Status.Expires = time.Now().Add(-time.Minute)
+		}
+	}
+	require.NoError(t, manager.runComplianceCheck(context.Background()))
+	assert.NotContains(t, mockAWS.CheckedOutEntitlements, burst.Status.ConsumptionToken, "expired requests are checked in")
+	assert.Len(t, mockK8s.CheckoutRequests, 3, "expired requests are deleted")
+	assert.Equal(t, 0, manager.Status().Usage.RequestedLicenses)
+}
+
+// expireRequestToken makes the token of the request name expire within the renewal margin
+func expireRequestToken(mockK8s *mocks.MockK8sClient, name string) time.Time {
+	expiry := time.Now().Add(time.Minute)
+	for i := range mockK8s.CheckoutRequests {
+		if mockK8s.CheckoutRequests[i].Name == name {
+			mockK8s.CheckoutRequests[i].Status.TokenExpiry = expiry
+		}
+	}
+	return expiry
+}
+
+func TestCheckoutRequestsRenewedBetweenChecks(t *testing.T) {
+	sched, err := schedule.Parse("0 9 * * *", time.UTC)
+	require.NoError(t, err)
+	mockAWS := mocks.NewMockAWSClient(10)
+	mockK8s := mocks.NewMockK8sClient(nil)
+	mockK8s.CheckoutRequests = []k8s.CheckoutRequest{
+		{Name: "burst", UID: "uid-burst", Count: 3, Duration: 24 * time.Hour},
+	}
+	scraper := mocks.NewMockScraper(30)
+	manager := NewAWS(mockAWS, mockK8s, scraper, Options{Schedule: sched, NodeCountFailureThreshold: 2})
+	ctx := context.Background()
+	require.NoError(t, manager.runComplianceCheck(ctx))
+	require.Equal(t, k8s.CheckoutRequestActive, checkoutRequest(t, mockK8s, "burst").Status.Phase)
+
+	// ticks between the windows of the schedule only renew
+	expiry := expireRequestToken(mockK8s, "burst")
+	renewed, err := manager.renewCurrentCheckout(ctx)
+	require.NoError(t, err)
+	assert.True(t, renewed)
+	burst := checkoutRequest(t, mockK8s, "burst")
+	assert.Equal(t, k8s.CheckoutRequestActive, burst.Status.Phase)
+	assert.True(t, burst.Status.TokenExpiry.After(expiry), "the token should be extended between scheduled checks")
+
+	scraper.Err = errors.New("rancher metrics unavailable")
+	for failures := 1; failures <= 2; failures++ {
+		expiry = expireRequestToken(mockK8s, "burst")
+		err = manager.runComplianceCheck(ctx)
+		if failures < 2 {
+			require.NoError(t, err)
+		} else {
+			var countErr *NodeCountError
+			require.ErrorAs(t, err, &countErr)
+		}
+		burst = checkoutRequest(t, mockK8s, "burst")
+		assert.Equal(t, k8s.CheckoutRequestActive, burst.Status.Phase)
+		assert.True(t, burst.Status.TokenExpiry.After(expiry), "the token should be extended while nodes can't be counted (%d failures)", failures)
+	}
+}
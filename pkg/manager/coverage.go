@@ -0,0 +1,79 @@
+package manager
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/sirupsen/logrus"
+)
+
+// checkoutExpiry returns when the checkout in info is returned unless renewed, zero if nothing is checked out
+func checkoutExpiry(info *licenseCheckoutInfo) time.Time {
+	if info.ConsumptionToken == "" {
+		return time.Time{}
+	}
+	return info.Expiry
+}
+
+// checkoutRenewsAt returns when the checkout in info is renewed next, zero if nothing is checked out. Checkouts are
+// extended by the first tick within renewalMargin of their expiry
+func checkoutRenewsAt(info *licenseCheckoutInfo) time.Time {
+	if info.ConsumptionToken == "" {
+		return time.Time{}
+	}
+	return info.Expiry.Add(-renewalMargin)
+}
+
+// coverage returns the coverage of the checkout recorded in the status, nil if nothing is checked out
+func (m *AWS) coverage() *CheckoutCoverage {
+	m.statusLock.RLock()
+	defer m.statusLock.RUnlock()
+	snapshot := m.status.Usage
+	if snapshot.CheckoutExpiry == nil || snapshot.CheckoutRenewsAt == nil {
+		return nil
+	}
+	return &CheckoutCoverage{
+		ExpiresAt: *snapshot.CheckoutExpiry,
+		RenewsAt:  *snapshot.CheckoutRenewsAt,
+	}
+}
+
+// publishRenewal records the checkout in info after it was renewed between compliance checks, logging failures to
+// publish it since the next compliance check publishes it again
+func (m *AWS) publishRenewal(info *licenseCheckoutInfo) {
+	if err := m.recordRenewal(info); err != nil {
+		logrus.Warnf("[manager] unable to publish renewed checkout: %v", err)
+	}
+}
+
+// recordRenewal records the checkout in info after it was renewed between compliance checks, and republishes the
+// compliance setting so that the countdown in the rancher UI follows the renewal. The support config is left to the
+// next compliance check
+func (m *AWS) recordRenewal(info *licenseCheckoutInfo) error {
+	m.statusLock.Lock()
+	m.status.Usage.CheckedOutLicenses = info.EntitledLicenses
+	m.status.Usage.TokenExtensions = info.Extensions
+	m.status.Usage.CheckoutExpiry = sdk.OptionalTime(checkoutExpiry(info))
+	m.status.Usage.CheckoutRenewsAt = sdk.OptionalTime(checkoutRenewsAt(info))
+	compliance := m.status.Compliance
+	m.statusLock.Unlock()
+	if compliance.LastChecked.IsZero() {
+		// nothing was published yet
+		return nil
+	}
+	setting, err := json.Marshal(ComplianceInfo{
+		Status:   compliance.Status,
+		Reason:   compliance.Reason,
+		Message:  compliance.Message,
+		Coverage: m.coverage(),
+	})
+	if err != nil {
+		return fmt.Errorf("unable to marshall compliance setting: %v", err)
+	}
+	if err := m.k8s.UpdateComplianceSetting(string(setting)); err != nil {
+		return fmt.Errorf("unable to update compliance setting: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package manager
+
+import (
+	"fmt"
+
+	"github.com/rancher/csp-adapter/pkg/entitlement"
+)
+
+// declared returns whether the checkout converges to the declared node count rather than the counted nodes
+func (m *AWS) declared() bool {
+	return m.declaredNodes() > 0
+}
+
+// declaredNodes returns the declared node count, zero if none is declared. The count declared by the CSPAdapterConfig
+// takes precedence over Options.DeclaredNodes
+func (m *AWS) declaredNodes() int {
+	if m.config != nil && m.config.DeclaredNodes != nil {
+		return *m.config.DeclaredNodes
+	}
+	return m.opts.DeclaredNodes
+}
+
+// targetLicenses returns the licenses the checkout converges to while licensed nodes are counted: those required for the
+// declared node count if one is declared, otherwise those required for the counted nodes
+func (m *AWS) targetLicenses(licensedNodes int) int {
+	if m.declared() {
+		return m.requiredLicenses(m.declaredNodes())
+	}
+	return m.requiredLicenses(licensedNodes)
+}
+
+// uncoveredNodes returns how many of the counted licensed nodes a declared checkout of checkedOut licenses doesn't
+// cover. Counted checkouts always cover the counted nodes, since they're made for them
+func (m *AWS) uncoveredNodes(checkedOut, licensedNodes int) int {
+	if !m.declared() {
+		return 0
+	}
+	licenses, err := entitlement.Licenses(checkedOut)
+	if err != nil {
+		return licensedNodes
+	}
+	covered, err := licenses.Nodes()
+	if err != nil || covered >= int64(licensedNodes) {
+		// a checkout too large to count its nodes covers any node count
+		return 0
+	}
+	return licensedNodes - int(covered)
+}
+
+// describeDeclaredShortfall is the notification of a declared checkout which doesn't cover the counted nodes
+func (m *AWS) describeDeclaredShortfall(licensedNodes int) string {
+	required := licensesFor(licensedNodes)
+	return fmt.Sprintf("%s The declared checkout of %d nodes doesn't cover the %d nodes in use. Declare at least %d nodes to become compliant.",
+		statusPrefix, m.declaredNodes(), licensedNodes, required*nodesPerLicense)
+}
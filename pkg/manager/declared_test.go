@@ -0,0 +1,62 @@
+package manager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/clients/k8s"
+	"github.com/rancher/csp-adapter/pkg/mocks"
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeclaredCheckout(t *testing.T) {
+	ctx := context.Background()
+	mockK8s := mocks.NewMockK8sClient(nil)
+	mockScraper := mocks.NewMockScraper(20)
+	opts := Options{DeclaredNodes: 500, ScaleDownConfirmation: time.Hour}
+	mockAWS := NewAWS(mocks.NewMockAWSClient(50), mockK8s, mockScraper, opts)
+	require.NoError(t, mockAWS.runComplianceCheck(ctx))
+	assert.Equal(t, "25", mockK8s.CurrentSecretData[nodeKey], "licenses should be checked out for the declared nodes")
+	status := mockAWS.Status()
+	assert.Equal(t, sdk.ReasonLicensed, status.Compliance.Reason)
+	assert.Equal(t, 500, status.Usage.DeclaredNodes)
+	assert.Equal(t, 20, status.Usage.Nodes)
+
+	// lowering the declaration converges right away, without confirming the scale-down
+	mockAWS.opts.DeclaredNodes = 100
+	require.NoError(t, mockAWS.runComplianceCheck(ctx))
+	assert.Equal(t, "5", mockK8s.CurrentSecretData[nodeKey])
+
+	// nodes outgrowing the declaration don't change the checkout
+	mockScraper.Nodes = 150
+	require.NoError(t, mockAWS.runComplianceCheck(ctx))
+	assert.Equal(t, "5", mockK8s.CurrentSecretData[nodeKey])
+	status = mockAWS.Status()
+	assert.Equal(t, sdk.ComplianceStatusNonCompliant, status.Compliance.Status)
+	assert.Equal(t, sdk.ReasonInsufficientLicenses, status.Compliance.Reason)
+	assert.Contains(t, status.Compliance.Message, "don't cover 50 of the 150 nodes")
+}
+
+func TestAdapterConfigDeclaredNodes(t *testing.T) {
+	ctx := context.Background()
+	mockK8s := mocks.NewMockK8sClient(nil)
+	mockScraper := mocks.NewMockScraper(20)
+	mockAWS := NewAWS(mocks.NewMockAWSClient(50), mockK8s, mockScraper, Options{DeclaredNodes: 500})
+	declared := 100
+	mockK8s.AdapterConfig = &k8s.AdapterConfig{DeclaredNodes: &declared}
+	require.NoError(t, mockAWS.runComplianceCheck(ctx))
+	assert.Equal(t, "5", mockK8s.CurrentSecretData[nodeKey], "the config's declaration should take precedence over the chart's")
+	assert.Equal(t, 100, mockAWS.Status().Usage.DeclaredNodes)
+
+	undeclared := 0
+	mockK8s.AdapterConfig = &k8s.AdapterConfig{DeclaredNodes: &undeclared}
+	require.NoError(t, mockAWS.runComplianceCheck(ctx))
+	assert.Equal(t, "1", mockK8s.CurrentSecretData[nodeKey], "declaring 0 nodes should follow the counted nodes")
+
+	mockK8s.AdapterConfig = &k8s.AdapterConfig{}
+	require.NoError(t, mockAWS.runComplianceCheck(ctx))
+	assert.Equal(t, "25", mockK8s.CurrentSecretData[nodeKey], "the chart's declaration should apply once the config has none")
+}
@@ -0,0 +1,79 @@
+package manager
+
+import (
+	"math"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/clients/k8s"
+	"github.com/rancher/csp-adapter/pkg/metrics"
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/sirupsen/logrus"
+)
+
+// environmentCounts are the counted nodes split by the environment of their clusters
+type environmentCounts struct {
+	// counts are the node counts which were classified
+	counts *metrics.NodeCounts
+	// nodes holds the nodes of each environment, and clusters the environment of each cluster. Both are nil unless
+	// clusters are classified. Exempt nodes are in neither environment
+	nodes    map[string]int
+	clusters map[string]string
+	// exemptions are the active ClusterLicenseExemptions, and exempt the number of nodes of their clusters
+	exemptions []sdk.LicenseExemption
+	exempt     int
+	// licensed is the number of nodes licenses are required for
+	licensed int
+}
+
+// classifyEnvironments splits counts by the environment of their clusters when Options.ClassifyEnvironments is set.
+// Production nodes are licensed in full, non-production nodes at Options.NonProductionRatio. Nodes which aren't
+// attributed to a cluster, or whose cluster can't be looked up, are production so that they're never under-licensed.
+// Nodes of clusters with an active ClusterLicenseExemption aren't licensed, whether or not clusters are classified
+func (m *AWS) classifyEnvironments(counts *metrics.NodeCounts) environmentCounts {
+	result := environmentCounts{counts: counts}
+	var exempt map[string]bool
+	result.exemptions, exempt = m.activeExemptions(counts, time.Now())
+	for clusterID := range exempt {
+		result.exempt += counts.Clusters[clusterID]
+	}
+	if result.exempt > counts.Total {
+		// cross-validated totals may be lower than the clusters counted by the primary source
+		result.exempt = counts.Total
+	}
+	total := counts.Total - result.exempt
+	result.licensed = total
+	if !m.opts.ClassifyEnvironments {
+		return result
+	}
+	clusters, err := m.k8s.GetClusters()
+	if err != nil {
+		logrus.Warnf("[manager] unable to get cluster environments, counting all nodes as production: %v", err)
+		return result
+	}
+	result.clusters = map[string]string{}
+	nonProduction := 0
+	for clusterID, nodes := range counts.Clusters {
+		environment := clusterEnvironment(clusters[clusterID])
+		result.clusters[clusterID] = environment
+		if environment == sdk.EnvironmentNonProduction && !exempt[clusterID] {
+			nonProduction += nodes
+		}
+	}
+	if nonProduction > total {
+		nonProduction = total
+	}
+	result.nodes = map[string]int{
+		sdk.EnvironmentProduction:    total - nonProduction,
+		sdk.EnvironmentNonProduction: nonProduction,
+	}
+	result.licensed = total - nonProduction + int(math.Ceil(float64(nonProduction)*m.opts.NonProductionRatio))
+	return result
+}
+
+// clusterEnvironment returns the environment of a cluster, production unless it's labeled non-production
+func clusterEnvironment(info k8s.ClusterInfo) string {
+	if info.Environment == sdk.EnvironmentNonProduction {
+		return sdk.EnvironmentNonProduction
+	}
+	return sdk.EnvironmentProduction
+}
@@ -0,0 +1,90 @@
+package manager
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rancher/csp-adapter/pkg/clients/k8s"
+	"github.com/rancher/csp-adapter/pkg/mocks"
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifyEnvironments(t *testing.T) {
+	tests := []struct {
+		name                 string
+		classify             bool
+		ratio                float64
+		expectedLicensed     int
+		expectedLicenses     string
+		expectedEnvironments map[string]int
+	}{
+		{
+			name:             "not classified",
+			ratio:            0,
+			expectedLicensed: 60,
+			expectedLicenses: "3",
+		},
+		{
+			name:                 "counted in full",
+			classify:             true,
+			ratio:                1,
+			expectedLicensed:     60,
+			expectedLicenses:     "3",
+			expectedEnvironments: map[string]int{sdk.EnvironmentProduction: 25, sdk.EnvironmentNonProduction: 35},
+		},
+		{
+			name:                 "excluded",
+			classify:             true,
+			ratio:                0,
+			expectedLicensed:     25,
+			expectedLicenses:     "2",
+			expectedEnvironments: map[string]int{sdk.EnvironmentProduction: 25, sdk.EnvironmentNonProduction: 35},
+		},
+		{
+			name:                 "counted at half",
+			classify:             true,
+			ratio:                0.5,
+			expectedLicensed:     43,
+			expectedLicenses:     "3",
+			expectedEnvironments: map[string]int{sdk.EnvironmentProduction: 25, sdk.EnvironmentNonProduction: 35},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			mockK8s := mocks.NewMockK8sClient(nil)
+			mockK8s.Clusters = map[string]k8s.ClusterInfo{
+				"c-prod":    {Name: "prod", Environment: sdk.EnvironmentProduction},
+				"c-staging": {Name: "staging", Environment: sdk.EnvironmentNonProduction},
+				"c-dev":     {Name: "dev", Environment: sdk.EnvironmentNonProduction},
+				"c-edge":    {Name: "edge"},
+			}
+			// 5 nodes aren't attributed to a cluster and count as production
+			mockScraper := mocks.NewMockScraper(60)
+			mockScraper.Clusters = map[string]int{"c-prod": 15, "c-staging": 20, "c-dev": 15, "c-edge": 5}
+			mockAWS := NewAWS(mocks.NewMockAWSClient(5), mockK8s, mockScraper, Options{
+				ClassifyEnvironments: test.classify,
+				NonProductionRatio:   test.ratio,
+			})
+			require.NoError(t, mockAWS.runComplianceCheck(context.Background()))
+
+			assert.Equal(t, test.expectedLicenses, mockK8s.CurrentSecretData[nodeKey])
+			usage := mockAWS.Status().Usage
+			assert.Equal(t, 60, usage.Nodes)
+			assert.Equal(t, test.expectedLicensed, usage.LicensedNodes)
+			assert.Equal(t, test.expectedEnvironments, usage.NodesByEnvironment)
+
+			inventory, ok := mockAWS.Inventory()
+			require.True(t, ok)
+			for _, cluster := range inventory.Clusters {
+				if test.classify {
+					assert.Equal(t, clusterEnvironment(mockK8s.Clusters[cluster.ID]), cluster.Environment, cluster.ID)
+				} else {
+					assert.Empty(t, cluster.Environment, cluster.ID)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package manager
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/metrics"
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/sirupsen/logrus"
+)
+
+// activeExemptions returns the ClusterLicenseExemptions active at now with the nodes counted for their clusters, and
+// the set of exempt clusters. Expired exemptions are ignored. If the exemptions can't be read every cluster is counted,
+// so that nodes are never under-licensed
+func (m *AWS) activeExemptions(counts *metrics.NodeCounts, now time.Time) ([]sdk.LicenseExemption, map[string]bool) {
+	exemptions, err := m.k8s.GetExemptions()
+	if err != nil {
+		logrus.Warnf("[manager] unable to get cluster license exemptions, counting every cluster: %v", err)
+		return nil, nil
+	}
+	var active []sdk.LicenseExemption
+	exempt := map[string]bool{}
+	for _, exemption := range exemptions {
+		if !exemption.Active(now) {
+			logrus.Debugf("[manager] cluster license exemption %s of cluster %s expired at %s", exemption.Name,
+				exemption.ClusterID, exemption.Expires)
+			continue
+		}
+		exempt[exemption.ClusterID] = true
+		active = append(active, sdk.LicenseExemption{
+			Name:      exemption.Name,
+			ClusterID: exemption.ClusterID,
+			Reason:    exemption.Reason,
+			Expires:   exemption.Expires,
+			Nodes:     counts.Clusters[exemption.ClusterID],
+		})
+	}
+	sort.Slice(active, func(i, j int) bool {
+		return active[i].Name < active[j].Name
+	})
+	return active, exempt
+}
+
+// describeExemptions lists the active exemptions for the support config, with their expiry rendered in loc
+func describeExemptions(exemptions []sdk.LicenseExemption, exemptNodes int, loc *time.Location) string {
+	descriptions := make([]string, 0, len(exemptions))
+	for _, exemption := range exemptions {
+		descriptions = append(descriptions, fmt.Sprintf("%s until %s (%s)", exemption.ClusterID,
+			formatTime(exemption.Expires, loc), exemption.Reason))
+	}
+	return fmt.Sprintf("%d nodes of exempt clusters aren't counted: %s", exemptNodes, strings.Join(descriptions, "; "))
+}
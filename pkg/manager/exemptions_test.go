@@ -0,0 +1,105 @@
+package manager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/clients/k8s"
+	"github.com/rancher/csp-adapter/pkg/mocks"
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClusterLicenseExemptions(t *testing.T) {
+	expires := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+	tests := []struct {
+		name                 string
+		classify             bool
+		exemptions           []k8s.Exemption
+		expectedLicenses     string
+		expectedExemptNodes  int
+		expectedExemptions   []sdk.LicenseExemption
+		expectedEnvironments map[string]int
+	}{
+		{
+			name:             "no exemptions",
+			expectedLicenses: "3",
+		},
+		{
+			name: "exempt cluster",
+			exemptions: []k8s.Exemption{
+				{Name: "migrating", ClusterID: "c-prod", Reason: "migrating", Expires: expires},
+			},
+			expectedLicenses:    "2",
+			expectedExemptNodes: 25,
+			expectedExemptions: []sdk.LicenseExemption{
+				{Name: "migrating", ClusterID: "c-prod", Reason: "migrating", Expires: expires, Nodes: 25},
+			},
+		},
+		{
+			name: "expired exemption",
+			exemptions: []k8s.Exemption{
+				{Name: "migrated", ClusterID: "c-prod", Reason: "migrating", Expires: time.Now().Add(-time.Hour)},
+			},
+			expectedLicenses: "3",
+		},
+		{
+			name: "cluster exempt twice is counted once",
+			exemptions: []k8s.Exemption{
+				{Name: "b", ClusterID: "c-prod", Reason: "migrating", Expires: expires},
+				{Name: "a", ClusterID: "c-prod", Reason: "licensed otherwise", Expires: expires},
+			},
+			expectedLicenses:    "2",
+			expectedExemptNodes: 25,
+			expectedExemptions: []sdk.LicenseExemption{
+				{Name: "a", ClusterID: "c-prod", Reason: "licensed otherwise", Expires: expires, Nodes: 25},
+				{Name: "b", ClusterID: "c-prod", Reason: "migrating", Expires: expires, Nodes: 25},
+			},
+		},
+		{
+			name:     "exempt non-production cluster",
+			classify: true,
+			exemptions: []k8s.Exemption{
+				{Name: "testing", ClusterID: "c-dev", Reason: "load testing", Expires: expires},
+			},
+			expectedLicenses:    "2",
+			expectedExemptNodes: 30,
+			expectedExemptions: []sdk.LicenseExemption{
+				{Name: "testing", ClusterID: "c-dev", Reason: "load testing", Expires: expires, Nodes: 30},
+			},
+			expectedEnvironments: map[string]int{sdk.EnvironmentProduction: 30, sdk.EnvironmentNonProduction: 0},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			mockK8s := mocks.NewMockK8sClient(nil)
+			mockK8s.Clusters = map[string]k8s.ClusterInfo{
+				"c-prod": {Name: "prod"},
+				"c-dev":  {Name: "dev", Environment: sdk.EnvironmentNonProduction},
+			}
+			mockK8s.Exemptions = test.exemptions
+			// 5 nodes aren't attributed to a cluster
+			mockScraper := mocks.NewMockScraper(60)
+			mockScraper.Clusters = map[string]int{"c-prod": 25, "c-dev": 30}
+			manager := NewAWS(mocks.NewMockAWSClient(5), mockK8s, mockScraper, Options{
+				ClassifyEnvironments: test.classify,
+				NonProductionRatio:   1,
+			})
+			require.NoError(t, manager.runComplianceCheck(context.Background()))
+
+			assert.Equal(t, test.expectedLicenses, mockK8s.CurrentSecretData[nodeKey])
+			usage := manager.Status().Usage
+			assert.Equal(t, 60, usage.Nodes, "exempt nodes are still counted")
+			assert.Equal(t, 60-test.expectedExemptNodes, usage.LicensedNodes)
+			assert.Equal(t, test.expectedExemptNodes, usage.ExemptNodes)
+			assert.Equal(t, test.expectedExemptions, usage.Exemptions)
+			assert.Equal(t, test.expectedEnvironments, usage.NodesByEnvironment)
+			for _, exemption := range test.expectedExemptions {
+				assert.Contains(t, string(mockK8s.CurrentSupportConfig), exemption.Reason, "active exemptions are reported")
+			}
+		})
+	}
+}
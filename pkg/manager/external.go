@@ -0,0 +1,87 @@
+package manager
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/licensemanager/types"
+	"github.com/rancher/csp-adapter/pkg/clients/aws"
+	"github.com/rancher/csp-adapter/pkg/metrics"
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/sirupsen/logrus"
+)
+
+// getEntitlementUsage gets the usage of the license's entitlements and attributes the consumption which isn't held by
+// the adapter to checkouts made outside of it (i.e. manually with the aws cli or by scripts). held is the number of
+// licenses the adapter currently holds. Must be called while holding the checkLock
+func (m *AWS) getEntitlementUsage(ctx context.Context, license types.GrantedLicense, held int) (aws.EntitlementUsage, error) {
+	m.timer.begin(phaseUsage)
+	usage, err := m.aws.GetEntitlementUsage(ctx, license)
+	m.timer.end()
+	m.recordSource(sdk.SourceLicenseManager, err, time.Now())
+	if err != nil {
+		return aws.EntitlementUsage{}, err
+	}
+	// checkouts made for LicenseCheckoutRequests are the adapter's too
+	external := externalLicenses(usage, held+m.requestedLicenses)
+	if external != m.externalLicenses {
+		if external > 0 {
+			logrus.Infof("[manager] %d license(s) are checked out outside of the adapter, they are not available to rancher", external)
+		} else {
+			logrus.Infof("[manager] no licenses are checked out outside of the adapter anymore")
+		}
+	}
+	m.externalLicenses = external
+	m.verified = true
+	metrics.ExternalLicenses.Set(float64(external))
+	recordEntitlementMetrics(awssdk.ToString(license.LicenseArn), usage)
+	m.recordForecastMetrics(awssdk.ToString(license.LicenseArn), usage, time.Now())
+	return usage, nil
+}
+
+// entitlementSeries are the dimension and license labels of the entitlement gauges exported last
+var (
+	entitlementSeriesLock sync.Mutex
+	entitlementSeries     = map[[2]string]bool{}
+)
+
+// recordEntitlementMetrics exports the usage of every dimension of the license the adapter checks out from. Series of
+// a previous license (i.e. before a switchover to its renewal) or dimension are removed once the current ones are set,
+// so that alerts only follow the license in use and never see the gauges without series
+func recordEntitlementMetrics(licenseArn string, usage aws.EntitlementUsage) {
+	dimensions := usage.Dimensions
+	if len(dimensions) == 0 {
+		dimensions = []aws.DimensionUsage{{Name: usage.Dimension, Max: int64(usage.Max), Consumed: int64(usage.Consumed)}}
+	}
+	entitlementSeriesLock.Lock()
+	defer entitlementSeriesLock.Unlock()
+	current := map[[2]string]bool{}
+	for _, dimension := range dimensions {
+		current[[2]string{dimension.Name, licenseArn}] = true
+		metrics.EntitlementMax.WithLabelValues(dimension.Name, licenseArn).Set(float64(dimension.Max))
+		metrics.EntitlementConsumed.WithLabelValues(dimension.Name, licenseArn).Set(float64(dimension.Consumed))
+		metrics.EntitlementAvailable.WithLabelValues(dimension.Name, licenseArn).Set(float64(dimension.Available()))
+	}
+	for series := range entitlementSeries {
+		if current[series] {
+			continue
+		}
+		metrics.EntitlementMax.DeleteLabelValues(series[0], series[1])
+		metrics.EntitlementConsumed.DeleteLabelValues(series[0], series[1])
+		metrics.EntitlementAvailable.DeleteLabelValues(series[0], series[1])
+	}
+	entitlementSeries = current
+}
+
+// externalLicenses returns the number of licenses consumed by checkouts which weren't made by the adapter, given that
+// the adapter holds held licenses
+func externalLicenses(usage aws.EntitlementUsage, held int) int {
+	external := usage.Consumed - held
+	if external < 0 {
+		// the adapter's checkout already expired in License Manager, it's checked out again by this check
+		return 0
+	}
+	return external
+}
@@ -0,0 +1,75 @@
+package manager
+
+import (
+	"context"
+	"testing"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rancher/csp-adapter/pkg/clients/aws"
+	"github.com/rancher/csp-adapter/pkg/metrics"
+	"github.com/rancher/csp-adapter/pkg/mocks"
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExternalConsumption(t *testing.T) {
+	mockAWSClient := mocks.NewMockAWSClient(10)
+	mockAWSClient.ExternalEntitlements = 4
+	mockK8s := mocks.NewMockK8sClient(nil)
+	mockAWS := NewAWS(mockAWSClient, mockK8s, mocks.NewMockScraper(200), Options{})
+	ctx := context.Background()
+
+	assert.NoError(t, mockAWS.runComplianceCheck(ctx))
+	status := mockAWS.Status()
+	assert.Equal(t, 6, status.Usage.CheckedOutLicenses, "licenses checked out outside of the adapter aren't available")
+	assert.Equal(t, 4, status.Usage.ExternalLicenses)
+	assert.Equal(t, sdk.ReasonInsufficientLicenses, status.Compliance.Reason)
+	assert.Contains(t, mockK8s.CurrentNotificationMessage, "4 license(s) are checked out outside of Rancher")
+
+	// the adapter's own checkout isn't attributed to external consumers while it's renewed
+	mockAWSClient.ExternalEntitlements = 0
+	assert.NoError(t, mockAWS.runComplianceCheck(ctx))
+	assert.Equal(t, 10, mockAWS.Status().Usage.CheckedOutLicenses)
+	assert.NoError(t, mockAWS.runComplianceCheck(ctx))
+	status = mockAWS.Status()
+	assert.Equal(t, 0, status.Usage.ExternalLicenses)
+	assert.Equal(t, sdk.ReasonLicensed, status.Compliance.Reason)
+}
+
+func TestEntitlementMetrics(t *testing.T) {
+	mockAWSClient := mocks.NewMockAWSClient(10)
+	mockAWSClient.ExternalEntitlements = 2
+	mockAWS := NewAWS(mockAWSClient, mocks.NewMockK8sClient(nil), mocks.NewMockScraper(60), Options{})
+	assert.NoError(t, mockAWS.runComplianceCheck(context.Background()))
+
+	// the usage is read before the adapter's checkout, only the external consumption is counted yet
+	licenseArn := awssdk.ToString(mockAWSClient.License.LicenseArn)
+	assert.Equal(t, 10.0, testutil.ToFloat64(metrics.EntitlementMax.WithLabelValues("RKE_NODE_SUPP", licenseArn)))
+	assert.Equal(t, 2.0, testutil.ToFloat64(metrics.EntitlementConsumed.WithLabelValues("RKE_NODE_SUPP", licenseArn)))
+	assert.Equal(t, 8.0, testutil.ToFloat64(metrics.EntitlementAvailable.WithLabelValues("RKE_NODE_SUPP", licenseArn)))
+
+	// series of another license are removed
+	recordEntitlementMetrics("arn:aws:license-manager::1:license:l-renewed", aws.EntitlementUsage{Max: 5, Consumed: 1, Dimension: "RKE_NODE_SUPP"})
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics.EntitlementAvailable))
+	assert.Equal(t, 4.0, testutil.ToFloat64(metrics.EntitlementAvailable.WithLabelValues("RKE_NODE_SUPP", "arn:aws:license-manager::1:license:l-renewed")))
+
+	// every dimension is exported
+	recordEntitlementMetrics("arn:aws:license-manager::1:license:l-renewed", aws.EntitlementUsage{
+		Max:       5,
+		Consumed:  1,
+		Dimension: "RKE_NODE_SUPP",
+		Dimensions: []aws.DimensionUsage{
+			{Name: "RKE_NODE_SUPP", Max: 5, Consumed: 1},
+			{Name: "STORAGE_GB", Max: 100, Consumed: 40},
+		},
+	})
+	assert.Equal(t, 2, testutil.CollectAndCount(metrics.EntitlementAvailable))
+	assert.Equal(t, 60.0, testutil.ToFloat64(metrics.EntitlementAvailable.WithLabelValues("STORAGE_GB", "arn:aws:license-manager::1:license:l-renewed")))
+}
+
+func TestExternalLicenses(t *testing.T) {
+	assert.Equal(t, 3, externalLicenses(aws.EntitlementUsage{Max: 10, Consumed: 8}, 5))
+	assert.Equal(t, 0, externalLicenses(aws.EntitlementUsage{Max: 10, Consumed: 5}, 5))
+	assert.Equal(t, 0, externalLicenses(aws.EntitlementUsage{Max: 10, Consumed: 0}, 5), "an expired checkout isn't negative consumption")
+}
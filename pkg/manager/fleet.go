@@ -0,0 +1,64 @@
+package manager
+
+import (
+	"strings"
+
+	"github.com/rancher/csp-adapter/pkg/metrics"
+	"github.com/sirupsen/logrus"
+)
+
+// unknownProvider labels clusters whose provider rancher didn't detect, or which couldn't be looked up
+const unknownProvider = "unknown"
+
+// clusterCountBuckets are the upper bounds of the cluster_count label of the license metrics, which keep its
+// cardinality bounded however large the fleet grows
+var clusterCountBuckets = []struct {
+	max   int
+	label string
+}{
+	{0, "0"},
+	{5, "1-5"},
+	{20, "6-20"},
+	{100, "21-100"},
+	{500, "101-500"},
+}
+
+// clusterCountBucket returns the cluster_count label for clusters
+func clusterCountBucket(clusters int) string {
+	for _, bucket := range clusterCountBuckets {
+		if clusters <= bucket.max {
+			return bucket.label
+		}
+	}
+	return "500+"
+}
+
+// recordFleetMetrics exports the number of managed clusters and nodes per provider and the licenses they require, so
+// that capacity planning dashboards can correlate entitlement use with the growth of the fleet
+func (m *AWS) recordFleetMetrics(nodeCounts *metrics.NodeCounts, requiredLicenses, checkedOutLicenses int) {
+	clusters, err := m.k8s.GetClusters()
+	if err != nil {
+		logrus.Warnf("[manager] unable to get cluster providers for metrics: %v", err)
+	}
+	clustersByProvider := map[string]int{}
+	nodesByProvider := map[string]int{}
+	for clusterID, nodes := range nodeCounts.Clusters {
+		provider := unknownProvider
+		if info, ok := clusters[clusterID]; ok && info.Provider != "" {
+			provider = strings.ToLower(info.Provider)
+		}
+		clustersByProvider[provider]++
+		nodesByProvider[provider] += nodes
+	}
+	// providers whose last cluster was removed must not keep reporting it
+	metrics.ManagedClusters.Reset()
+	metrics.ManagedNodes.Reset()
+	for provider, count := range clustersByProvider {
+		metrics.ManagedClusters.WithLabelValues(provider).Set(float64(count))
+		metrics.ManagedNodes.WithLabelValues(provider).Set(float64(nodesByProvider[provider]))
+	}
+	bucket := clusterCountBucket(len(nodeCounts.Clusters))
+	metrics.Licenses.Reset()
+	metrics.Licenses.WithLabelValues("required", bucket).Set(float64(requiredLicenses))
+	metrics.Licenses.WithLabelValues("checked_out", bucket).Set(float64(checkedOutLicenses))
+}
@@ -0,0 +1,46 @@
+package manager
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rancher/csp-adapter/pkg/clients/k8s"
+	"github.com/rancher/csp-adapter/pkg/metrics"
+	"github.com/rancher/csp-adapter/pkg/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFleetMetrics(t *testing.T) {
+	mockK8s := mocks.NewMockK8sClient(nil)
+	mockK8s.Clusters = map[string]k8s.ClusterInfo{
+		"c-abcde": {Name: "production", Provider: "EKS"},
+		"c-fghij": {Name: "staging", Provider: "eks"},
+		"c-klmno": {Name: "edge", Provider: "k3s"},
+	}
+	mockScraper := mocks.NewMockScraper(45)
+	mockScraper.Clusters = map[string]int{"c-abcde": 20, "c-fghij": 10, "c-klmno": 5, "c-pqrst": 10}
+	mockAWS := NewAWS(mocks.NewMockAWSClient(5), mockK8s, mockScraper, Options{})
+	assert.NoError(t, mockAWS.runComplianceCheck(context.Background()))
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(metrics.ManagedClusters.WithLabelValues("eks")))
+	assert.Equal(t, 30.0, testutil.ToFloat64(metrics.ManagedNodes.WithLabelValues("eks")))
+	assert.Equal(t, 5.0, testutil.ToFloat64(metrics.ManagedNodes.WithLabelValues("k3s")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.ManagedClusters.WithLabelValues(unknownProvider)))
+	assert.Equal(t, 3.0, testutil.ToFloat64(metrics.Licenses.WithLabelValues("required", "1-5")))
+	assert.Equal(t, 3.0, testutil.ToFloat64(metrics.Licenses.WithLabelValues("checked_out", "1-5")))
+
+	// removed providers stop being reported
+	mockScraper.Clusters = map[string]int{"c-abcde": 20}
+	mockAWS.recordFleetMetrics(&metrics.NodeCounts{Total: 20, Clusters: mockScraper.Clusters}, 1, 1)
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics.ManagedClusters))
+	assert.Equal(t, 2, testutil.CollectAndCount(metrics.Licenses))
+}
+
+func TestClusterCountBucket(t *testing.T) {
+	assert.Equal(t, "0", clusterCountBucket(0))
+	assert.Equal(t, "1-5", clusterCountBucket(5))
+	assert.Equal(t, "6-20", clusterCountBucket(6))
+	assert.Equal(t, "101-500", clusterCountBucket(500))
+	assert.Equal(t, "500+", clusterCountBucket(501))
+}
@@ -0,0 +1,96 @@
+package manager
+
+import (
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/clients/aws"
+	"github.com/rancher/csp-adapter/pkg/metrics"
+)
+
+const (
+	// forecastWindow is how far back consumption is sampled to project the exhaustion of entitlements
+	forecastWindow = 7 * 24 * time.Hour
+	// forecastMinSpan is the span samples must cover before exhaustion is projected, so that a few checks after a
+	// restart don't extrapolate a burst
+	forecastMinSpan = 24 * time.Hour
+	// forecastResolution is the interval samples are kept at, the largest consumption within it is sampled
+	forecastResolution = time.Hour
+)
+
+// consumptionSample is the consumption of entitlements observed at a point in time
+type consumptionSample struct {
+	at       time.Time
+	consumed int
+}
+
+// utilizationForecast projects when the entitlements of the license run out from the trend of their consumption
+type utilizationForecast struct {
+	samples []consumptionSample
+}
+
+// observe samples the consumption of entitlements at now, dropping samples which left the window and the oldest
+// beyond max
+func (f *utilizationForecast) observe(consumed int, now time.Time, max int) {
+	cutoff := now.Add(-forecastWindow)
+	kept := f.samples[:0]
+	for _, sample := range f.samples {
+		if sample.at.After(cutoff) {
+			kept = append(kept, sample)
+		}
+	}
+	f.samples = kept
+	if last := len(f.samples) - 1; last >= 0 && now.Sub(f.samples[last].at) < forecastResolution {
+		if consumed > f.samples[last].consumed {
+			f.samples[last].consumed = consumed
+		}
+	} else {
+		f.samples = append(f.samples, consumptionSample{at: now, consumed: consumed})
+	}
+	f.samples = f.samples[metrics.Retain(retainedForecastSamples, len(f.samples), max):]
+}
+
+// growthPerDay returns the growth of consumption per day by a least squares fit of the samples, false unless they
+// cover forecastMinSpan
+func (f *utilizationForecast) growthPerDay() (float64, bool) {
+	if len(f.samples) < 2 || f.samples[len(f.samples)-1].at.Sub(f.samples[0].at) < forecastMinSpan {
+		return 0, false
+	}
+	var sumX, sumY, sumXY, sumXX float64
+	for _, sample := range f.samples {
+		x := sample.at.Sub(f.samples[0].at).Hours() / 24
+		y := float64(sample.consumed)
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+	n := float64(len(f.samples))
+	return (n*sumXY - sumX*sumY) / (n*sumXX - sumX*sumX), true
+}
+
+// daysUntilExhaustion returns the days until the available entitlements are consumed at the current growth, false if
+// no exhaustion is projected: consumption isn't growing or its trend isn't known yet. Exhausted entitlements project 0
+func (f *utilizationForecast) daysUntilExhaustion(available int) (float64, bool) {
+	if available <= 0 {
+		return 0, true
+	}
+	growth, ok := f.growthPerDay()
+	if !ok || growth <= 0 {
+		return 0, false
+	}
+	return float64(available) / growth, true
+}
+
+// recordForecastMetrics exports the utilization of the license's entitlements and the projected days until they're
+// exhausted. Like the entitlement metrics, only the series of the license in use are kept
+func (m *AWS) recordForecastMetrics(licenseArn string, usage aws.EntitlementUsage, now time.Time) {
+	m.forecast.observe(usage.Consumed, now, m.maxSamples())
+	metrics.EntitlementUtilization.Reset()
+	metrics.EntitlementExhaustionDays.Reset()
+	if usage.Max > 0 {
+		metrics.EntitlementUtilization.WithLabelValues(usage.Dimension, licenseArn).Set(float64(usage.Consumed) / float64(usage.Max))
+	}
+	if days, ok := m.forecast.daysUntilExhaustion(usage.Available()); ok {
+		metrics.EntitlementExhaustionDays.WithLabelValues(usage.Dimension, licenseArn).Set(days)
+	}
+}
@@ -0,0 +1,66 @@
+package manager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rancher/csp-adapter/pkg/clients/aws"
+	"github.com/rancher/csp-adapter/pkg/metrics"
+	"github.com/rancher/csp-adapter/pkg/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUtilizationForecast(t *testing.T) {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	var forecast utilizationForecast
+	forecast.observe(10, start, DefaultMaxSamples)
+	forecast.observe(12, start.Add(12*time.Hour), DefaultMaxSamples)
+	_, ok := forecast.daysUntilExhaustion(10)
+	assert.False(t, ok, "nothing is projected before the samples cover a day")
+
+	forecast.observe(14, start.Add(24*time.Hour), DefaultMaxSamples)
+	days, ok := forecast.daysUntilExhaustion(10)
+	assert.True(t, ok)
+	assert.InDelta(t, 2.5, days, 0.001, "consumption grows by 4 a day")
+
+	forecast.observe(20, start.Add(24*time.Hour+time.Minute), DefaultMaxSamples)
+	assert.Len(t, forecast.samples, 3, "samples are kept hourly")
+	assert.Equal(t, 20, forecast.samples[2].consumed, "the largest consumption of the hour is sampled")
+
+	days, ok = forecast.daysUntilExhaustion(0)
+	assert.True(t, ok)
+	assert.Equal(t, 0.0, days, "exhausted entitlements project 0 days")
+
+	forecast.observe(14, start.Add(9*24*time.Hour), DefaultMaxSamples)
+	forecast.observe(14, start.Add(10*24*time.Hour), DefaultMaxSamples)
+	assert.Len(t, forecast.samples, 2, "samples past the window are dropped")
+	_, ok = forecast.daysUntilExhaustion(10)
+	assert.False(t, ok, "no exhaustion is projected without growth")
+}
+
+func TestForecastMetrics(t *testing.T) {
+	manager := NewAWS(mocks.NewMockAWSClient(10), mocks.NewMockK8sClient(nil), mocks.NewMockScraper(60), Options{})
+	licenseArn := "arn:aws:license-manager::1:license:l-1"
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	manager.recordForecastMetrics(licenseArn, aws.EntitlementUsage{Max: 10, Consumed: 2, Dimension: "RKE_NODE_SUPP"}, start)
+	assert.Equal(t, 0.2, testutil.ToFloat64(metrics.EntitlementUtilization.WithLabelValues("RKE_NODE_SUPP", licenseArn)))
+	assert.Equal(t, 0, testutil.CollectAndCount(metrics.EntitlementExhaustionDays), "no exhaustion is projected yet")
+
+	manager.recordForecastMetrics(licenseArn, aws.EntitlementUsage{Max: 10, Consumed: 4, Dimension: "RKE_NODE_SUPP"}, start.Add(24*time.Hour))
+	assert.Equal(t, 0.4, testutil.ToFloat64(metrics.EntitlementUtilization.WithLabelValues("RKE_NODE_SUPP", licenseArn)))
+	assert.Equal(t, 3.0, testutil.ToFloat64(metrics.EntitlementExhaustionDays.WithLabelValues("RKE_NODE_SUPP", licenseArn)))
+}
+
+func TestForecastMaxSamples(t *testing.T) {
+	var forecast utilizationForecast
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	for hour := 0; hour < 10; hour++ {
+		forecast.observe(hour, start.Add(time.Duration(hour)*time.Hour), 4)
+	}
+	require.Len(t, forecast.samples, 4, "the oldest samples are dropped beyond the bound")
+	assert.Equal(t, 6, forecast.samples[0].consumed)
+	assert.Equal(t, 9, forecast.samples[3].consumed)
+	assert.Equal(t, 4.0, testutil.ToFloat64(metrics.RetainedItems.WithLabelValues(retainedForecastSamples)))
+}
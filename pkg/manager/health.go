@@ -0,0 +1,65 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/metrics"
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/sirupsen/logrus"
+)
+
+const healthProbeInterval = 1 * time.Minute
+
+// probeServiceHealth checks the availability of License Manager every healthProbeInterval until ctx is cancelled. This
+// runs independently of compliance checks so that outages are visible even while no check is running
+func (m *AWS) probeServiceHealth(ctx context.Context) {
+	m.checkServiceHealth(ctx)
+	for range ticker(ctx, healthProbeInterval) {
+		m.checkServiceHealth(ctx)
+	}
+}
+
+// checkServiceHealth probes License Manager, recording the result in the status and metrics
+func (m *AWS) checkServiceHealth(ctx context.Context) sdk.ServiceHealth {
+	err := m.aws.CheckServiceHealth(ctx)
+	health := sdk.ServiceHealth{
+		Up:          err == nil,
+		LastChecked: time.Now(),
+	}
+	if err != nil {
+		logrus.Warnf("[manager] aws license manager is unavailable: %v", err)
+		health.LastError = err.Error()
+		metrics.LicenseManagerUp.Set(0)
+		metrics.LicenseManagerProbeFailures.Inc()
+		metrics.LicenseManagerLastError.Reset()
+		metrics.LicenseManagerLastError.WithLabelValues(probeErrorClass(err)).Set(1)
+	} else {
+		metrics.LicenseManagerUp.Set(1)
+		metrics.LicenseManagerLastError.Reset()
+	}
+	m.statusLock.Lock()
+	defer m.statusLock.Unlock()
+	m.status.Service = health
+	return health
+}
+
+// probeErrorClass returns the class of a probe error, which bounds the label values of the last error metric. Errors of
+// the aws api are told apart by their code, every other error is a network error
+func probeErrorClass(err error) string {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "timeout"
+	}
+	var apiErr interface{ ErrorCode() string }
+	if !errors.As(err, &apiErr) {
+		return "network"
+	}
+	switch apiErr.ErrorCode() {
+	case "ThrottlingException", "RateLimitExceededException", "TooManyRequestsException":
+		return "throttled"
+	case "AccessDeniedException", "AuthorizationException", "UnrecognizedClientException", "ExpiredTokenException":
+		return "access_denied"
+	}
+	return "api"
+}
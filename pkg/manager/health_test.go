@@ -0,0 +1,80 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/licensemanager/types"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rancher/csp-adapter/pkg/metrics"
+	"github.com/rancher/csp-adapter/pkg/mocks"
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckServiceHealth(t *testing.T) {
+	mockAWSClient := mocks.NewMockAWSClient(1)
+	mockAWS := AWS{
+		aws:     mockAWSClient,
+		k8s:     mocks.NewMockK8sClient(nil),
+		scraper: mocks.NewMockScraper(20),
+	}
+	health := mockAWS.checkServiceHealth(context.TODO())
+	assert.True(t, health.Up, "service should be up when the probe succeeds")
+	assert.Empty(t, health.LastError)
+
+	mockAWSClient.ServiceHealthErr = fmt.Errorf("service unavailable")
+	health = mockAWS.checkServiceHealth(context.TODO())
+	assert.False(t, health.Up, "service should be down when the probe fails")
+	assert.Equal(t, "service unavailable", health.LastError)
+	assert.Equal(t, health, mockAWS.Status().Service, "probe result should be visible in the status")
+}
+
+func TestLastProbeErrorMetric(t *testing.T) {
+	mockAWSClient := mocks.NewMockAWSClient(1)
+	mockAWS := AWS{
+		aws:     mockAWSClient,
+		k8s:     mocks.NewMockK8sClient(nil),
+		scraper: mocks.NewMockScraper(20),
+	}
+	tests := []struct {
+		err   error
+		class string
+	}{
+		{err: &types.RateLimitExceededException{}, class: "throttled"},
+		{err: fmt.Errorf("operation error: %w", &types.AccessDeniedException{}), class: "access_denied"},
+		{err: &types.ServerInternalException{}, class: "api"},
+		{err: fmt.Errorf("probe: %w", context.DeadlineExceeded), class: "timeout"},
+		{err: fmt.Errorf("dial tcp: connection refused"), class: "network"},
+	}
+	for _, test := range tests {
+		mockAWSClient.ServiceHealthErr = test.err
+		mockAWS.checkServiceHealth(context.TODO())
+		assert.Equal(t, 1, testutil.CollectAndCount(metrics.LicenseManagerLastError), "only the last error should be reported")
+		assert.Equal(t, float64(1), testutil.ToFloat64(metrics.LicenseManagerLastError.WithLabelValues(test.class)), test.err.Error())
+	}
+
+	mockAWSClient.ServiceHealthErr = nil
+	mockAWS.checkServiceHealth(context.TODO())
+	assert.Equal(t, 0, testutil.CollectAndCount(metrics.LicenseManagerLastError), "no error should be reported once the probe succeeds")
+}
+
+func TestServiceUnavailableReason(t *testing.T) {
+	mockAWSClient := mocks.NewMockAWSClient(1)
+	mockAWSClient.ServiceHealthErr = fmt.Errorf("service unavailable")
+	mockK8sClient := mocks.NewMockK8sClient(nil)
+	// the scraper failing makes the compliance check fail with a generic error
+	mockAWS := NewAWS(mockAWSClient, mockK8sClient, &mocks.MockScraper{Err: fmt.Errorf("unable to scrape")}, Options{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs := make(chan error, 2)
+	mockAWS.reportCheckError(ctx, fmt.Errorf("unable to determine number of active nodes"), errs)
+
+	var config CSPSupportConfig
+	assert.NoError(t, json.Unmarshal(mockK8sClient.CurrentSupportConfig, &config))
+	assert.Equal(t, sdk.ReasonServiceUnavailable, config.Compliance.Reason)
+	assert.Equal(t, sdk.ReasonServiceUnavailable, mockAWS.Status().Compliance.Reason)
+	assert.Contains(t, mockK8sClient.CurrentNotificationMessage, "currently unavailable")
+}
@@ -0,0 +1,106 @@
+package manager
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/metrics"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// duplicateInstanceWindow is how recently another instance must have written the checkout info to be considered
+	// running. Instances write their heartbeat every heartbeatInterval, so a few missed heartbeats mean the instance is
+	// gone
+	duplicateInstanceWindow = 3 * heartbeatInterval
+	// heartbeatInterval is how often the running instance writes its heartbeat
+	heartbeatInterval = managerInterval
+	// duplicateInstanceGrace is how long another running instance is waited for before it's reported, which covers
+	// the overlap of the old and new pod during a rolling update
+	duplicateInstanceGrace = 5 * time.Minute
+)
+
+// DuplicateInstanceError is returned by compliance checks while another adapter instance (i.e. a second release of
+// the chart) keeps writing the same checkout info. The check backs off rather than check out licenses a second time
+type DuplicateInstanceError struct {
+	Instance string
+	Since    time.Time
+}
+
+func (e *DuplicateInstanceError) Error() string {
+	return fmt.Sprintf("adapter instance %s is managing the same licenses since %s, remove all but one adapter",
+		e.Instance, e.Since.Format(time.RFC3339))
+}
+
+// newInstanceID returns a random identity for this adapter process
+func newInstanceID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// checkInstance returns whether the checkout info was recently written by another running instance, in which case
+// the caller must back off without changing it. Once the other instance kept writing for longer than
+// duplicateInstanceGrace a DuplicateInstanceError is returned as well, so that it's reported. Must be called while
+// holding the checkLock
+func (m *AWS) checkInstance(info *licenseCheckoutInfo, now time.Time) (bool, error) {
+	if m.instanceID == "" || info.Instance == "" || info.Instance == m.instanceID || now.Sub(info.Heartbeat) > duplicateInstanceWindow {
+		m.setOwner(true)
+		if !m.duplicateSince.IsZero() {
+			logrus.Infof("[manager] adapter instance %s stopped writing the checkout info, resuming license management", m.duplicateOf)
+			metrics.DuplicateInstance.Set(0)
+		}
+		m.duplicateSince = time.Time{}
+		m.duplicateOf = ""
+		return false, nil
+	}
+	m.setOwner(false)
+	if m.duplicateSince.IsZero() || m.duplicateOf != info.Instance {
+		logrus.Warnf("[manager] adapter instance %s wrote the checkout info at %s, backing off until it stops",
+			info.Instance, info.Heartbeat.Format(time.RFC3339))
+		m.duplicateSince = now
+		m.duplicateOf = info.Instance
+	}
+	if now.Sub(m.duplicateSince) <= duplicateInstanceGrace {
+		return true, nil
+	}
+	metrics.DuplicateInstance.Set(1)
+	return true, &DuplicateInstanceError{Instance: info.Instance, Since: m.duplicateSince}
+}
+
+// setOwner records whether this instance manages the checkout info, see writeHeartbeat
+func (m *AWS) setOwner(owner bool) {
+	m.instanceLock.Lock()
+	defer m.instanceLock.Unlock()
+	m.owner = owner
+}
+
+// writeHeartbeats writes the instance heartbeat every heartbeatInterval until ctx is cancelled
+func (m *AWS) writeHeartbeats(ctx context.Context) {
+	for range ticker(ctx, heartbeatInterval) {
+		if err := m.writeHeartbeat(time.Now()); err != nil {
+			logrus.Warnf("[manager] unable to write the instance heartbeat: %v", err)
+		}
+	}
+}
+
+// writeHeartbeat writes the instance heartbeat to the checkout info, so that other instances can tell this one is
+// running. It's written independently of the compliance checks, since a check which runs long or fails before saving
+// the checkout info doesn't mean the instance is gone. Nothing is written before a check found no other instance
+// running, or while backing off for one, so that a new instance doesn't take over from a running one
+func (m *AWS) writeHeartbeat(now time.Time) error {
+	m.instanceLock.Lock()
+	defer m.instanceLock.Unlock()
+	if !m.owner || m.instanceID == "" {
+		return nil
+	}
+	return m.k8s.UpdateConsumptionTokenSecret(map[string]string{
+		instanceKey:  m.instanceID,
+		heartbeatKey: now.Format(time.RFC3339),
+	})
+}
@@ -0,0 +1,83 @@
+package manager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/mocks"
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDuplicateInstance(t *testing.T) {
+	mockAWSClient := mocks.NewMockAWSClient(5)
+	mockK8s := mocks.NewMockK8sClient(nil)
+	first := NewAWS(mockAWSClient, mockK8s, mocks.NewMockScraper(40), Options{InstanceID: "first"})
+	second := NewAWS(mockAWSClient, mockK8s, mocks.NewMockScraper(60), Options{InstanceID: "second"})
+	ctx := context.Background()
+
+	require.NoError(t, first.runComplianceCheck(ctx))
+	assert.Equal(t, "first", mockK8s.CurrentSecretData[instanceKey])
+	token := mockK8s.CurrentSecretData[tokenKey]
+
+	// the second instance needs more licenses, but mustn't touch the checkout of the running first instance
+	require.NoError(t, second.runComplianceCheck(ctx))
+	assert.Equal(t, token, mockK8s.CurrentSecretData[tokenKey])
+	assert.Equal(t, "first", mockK8s.CurrentSecretData[instanceKey])
+	assert.Len(t, mockAWSClient.CheckedOutEntitlements, 1)
+	renewed, err := second.renewCheckout(ctx)
+	assert.True(t, renewed)
+	assert.NoError(t, err)
+
+	// reported once the first instance keeps running past the grace period
+	info, err := second.getLicenseCheckoutInfo()
+	require.NoError(t, err)
+	later := info.Heartbeat.Add(duplicateInstanceGrace + time.Minute)
+	info.Heartbeat = later
+	backoff, err := second.checkInstance(info, later)
+	assert.True(t, backoff)
+	var duplicateErr *DuplicateInstanceError
+	require.ErrorAs(t, err, &duplicateErr)
+	assert.Equal(t, "first", duplicateErr.Instance)
+	reason, _ := describeError(err, time.UTC)
+	assert.Equal(t, sdk.ReasonDuplicateInstance, reason)
+
+	// taken over once the first instance stops writing
+	backoff, err = second.checkInstance(info, later.Add(duplicateInstanceWindow+time.Second))
+	assert.False(t, backoff)
+	assert.NoError(t, err)
+	mockK8s.CurrentSecretData[heartbeatKey] = time.Now().Add(-time.Hour).Format(time.RFC3339)
+	require.NoError(t, second.runComplianceCheck(ctx))
+	assert.Equal(t, "second", mockK8s.CurrentSecretData[instanceKey])
+	assert.Equal(t, "3", mockK8s.CurrentSecretData[nodeKey])
+}
+
+func TestInstanceHeartbeat(t *testing.T) {
+	mockAWSClient := mocks.NewMockAWSClient(5)
+	mockK8s := mocks.NewMockK8sClient(nil)
+	first := NewAWS(mockAWSClient, mockK8s, mocks.NewMockScraper(40), Options{InstanceID: "first"})
+	second := NewAWS(mockAWSClient, mockK8s, mocks.NewMockScraper(40), Options{InstanceID: "second"})
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+
+	require.NoError(t, first.writeHeartbeat(now))
+	assert.Empty(t, mockK8s.CurrentSecretData[heartbeatKey], "no heartbeat is written before a check found no other instance")
+
+	require.NoError(t, first.runComplianceCheck(ctx))
+	token := mockK8s.CurrentSecretData[tokenKey]
+	later := now.Add(time.Hour)
+	require.NoError(t, first.writeHeartbeat(later))
+	assert.Equal(t, later.Format(time.RFC3339), mockK8s.CurrentSecretData[heartbeatKey])
+	assert.Equal(t, token, mockK8s.CurrentSecretData[tokenKey], "the heartbeat doesn't change the checkout info")
+
+	// an instance backing off for the running one doesn't write its heartbeat
+	info, err := second.getLicenseCheckoutInfo()
+	require.NoError(t, err)
+	backoff, _ := second.checkInstance(info, later)
+	require.True(t, backoff)
+	require.NoError(t, second.writeHeartbeat(later.Add(time.Minute)))
+	assert.Equal(t, "first", mockK8s.CurrentSecretData[instanceKey])
+	assert.Equal(t, later.Format(time.RFC3339), mockK8s.CurrentSecretData[heartbeatKey])
+}
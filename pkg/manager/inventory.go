@@ -0,0 +1,75 @@
+package manager
+
+import (
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/licensemanager/types"
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/sirupsen/logrus"
+)
+
+// Inventory returns the inventory captured when the current checkout was made, false if none was captured yet
+func (m *AWS) Inventory() (sdk.Inventory, bool) {
+	m.statusLock.RLock()
+	defer m.statusLock.RUnlock()
+	if m.inventory == nil {
+		return sdk.Inventory{}, false
+	}
+	return *m.inventory, true
+}
+
+// captureInventory records what the checkout described by info was based on. Cluster names and versions are best
+// effort, the inventory is captured without them if they can't be read
+func (m *AWS) captureInventory(license types.GrantedLicense, environments environmentCounts, requiredLicenses int, info *licenseCheckoutInfo) {
+	nodeCounts := environments.counts
+	inventory := sdk.Inventory{
+		CapturedAt:         time.Now(),
+		Account:            m.aws.AccountNumber(),
+		AccountAlias:       m.aws.AccountAlias(),
+		Nodes:              nodeCounts.Total,
+		Clusters:           []sdk.InventoryCluster{},
+		RequiredLicenses:   requiredLicenses,
+		CheckedOutLicenses: info.EntitledLicenses,
+		License: sdk.InventoryLicense{
+			Arn:        aws.ToString(license.LicenseArn),
+			ProductSKU: aws.ToString(license.ProductSKU),
+			Status:     string(license.Status),
+		},
+	}
+	for _, entitlement := range license.Entitlements {
+		dimension := sdk.ProductDimension{
+			Name: aws.ToString(entitlement.Name),
+			Unit: string(entitlement.Unit),
+		}
+		if entitlement.MaxCount != nil {
+			dimension.MaxCount = *entitlement.MaxCount
+		}
+		inventory.License.Entitlements = append(inventory.License.Entitlements, dimension)
+	}
+	if version, err := m.k8s.GetRancherVersion(); err == nil {
+		inventory.RancherVersion = version
+	} else {
+		logrus.Warnf("[manager] unable to get rancher version for the inventory: %v", err)
+	}
+	clusters, err := m.k8s.GetClusters()
+	if err != nil {
+		logrus.Warnf("[manager] unable to get cluster names and versions for the inventory: %v", err)
+	}
+	for clusterID, nodes := range nodeCounts.Clusters {
+		cluster := sdk.InventoryCluster{ID: clusterID, Nodes: nodes, Environment: environments.clusters[clusterID]}
+		if clusterInfo, ok := clusters[clusterID]; ok {
+			cluster.Name = clusterInfo.Name
+			cluster.KubernetesVersion = clusterInfo.KubernetesVersion
+		}
+		inventory.Clusters = append(inventory.Clusters, cluster)
+	}
+	sort.Slice(inventory.Clusters, func(i, j int) bool {
+		return inventory.Clusters[i].ID < inventory.Clusters[j].ID
+	})
+
+	m.statusLock.Lock()
+	defer m.statusLock.Unlock()
+	m.inventory = &inventory
+}
@@ -0,0 +1,46 @@
+package manager
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rancher/csp-adapter/pkg/clients/k8s"
+	"github.com/rancher/csp-adapter/pkg/mocks"
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInventory(t *testing.T) {
+	mockAWSClient := mocks.NewMockAWSClient(5)
+	mockK8s := mocks.NewMockK8sClient(nil)
+	mockK8s.RancherVersion = "v2.6.5"
+	mockK8s.Clusters = map[string]k8s.ClusterInfo{
+		"c-abcde": {Name: "production", KubernetesVersion: "v1.23.6"},
+	}
+	mockScraper := mocks.NewMockScraper(30)
+	mockScraper.Clusters = map[string]int{"c-fghij": 10, "c-abcde": 20}
+	mockAWS := NewAWS(mockAWSClient, mockK8s, mockScraper, Options{})
+	_, ok := mockAWS.Inventory()
+	assert.False(t, ok, "no inventory should be available before the first check")
+
+	ctx := context.Background()
+	assert.NoError(t, mockAWS.runComplianceCheck(ctx))
+	inventory, ok := mockAWS.Inventory()
+	assert.True(t, ok)
+	assert.Equal(t, "v2.6.5", inventory.RancherVersion)
+	assert.Equal(t, 30, inventory.Nodes)
+	assert.Equal(t, 2, inventory.CheckedOutLicenses)
+	assert.Equal(t, *mockAWSClient.License.LicenseArn, inventory.License.Arn)
+	assert.Equal(t, []sdk.InventoryCluster{
+		{ID: "c-abcde", Name: "production", KubernetesVersion: "v1.23.6", Nodes: 20},
+		{ID: "c-fghij", Nodes: 10},
+	}, inventory.Clusters)
+
+	// renewing the checkout keeps the inventory it was based on
+	mockScraper.Clusters = map[string]int{"c-fghij": 15, "c-abcde": 20}
+	mockScraper.Nodes = 35
+	assert.NoError(t, mockAWS.runComplianceCheck(ctx))
+	renewed, _ := mockAWS.Inventory()
+	assert.Equal(t, inventory.CapturedAt, renewed.CapturedAt)
+	assert.Equal(t, 30, renewed.Nodes)
+}
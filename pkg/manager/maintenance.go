@@ -0,0 +1,119 @@
+package manager
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// maintenanceLead is how long before a maintenance window the checkout is frozen. The checkout is extended to its
+	// full lifetime within the lead, so that the window starts with as much coverage as License Manager grants
+	maintenanceLead = 5 * time.Minute
+	// maintenanceMargin is how long before its expiry a checkout is extended during a maintenance window, instead of
+	// renewalMargin, leaving about 60 ticks to retry extensions which fail while License Manager is unreliable
+	maintenanceMargin = 30 * time.Minute
+)
+
+// DeclareMaintenance declares a window of planned AWS maintenance on behalf of the named user, returning it. Windows
+// which already ended or end before they start are refused. Declared windows are kept until they end or are cancelled
+func (m *AWS) DeclareMaintenance(by string, window sdk.MaintenanceWindow) (sdk.MaintenanceWindow, error) {
+	now := time.Now()
+	if !window.End.After(window.Start) {
+		return sdk.MaintenanceWindow{}, fmt.Errorf("maintenance window must end after it starts")
+	}
+	if !window.End.After(now) {
+		return sdk.MaintenanceWindow{}, fmt.Errorf("maintenance window ended at %s", window.End.Format(time.RFC3339))
+	}
+	window.DeclaredBy = by
+	window.Frozen = false
+	logrus.Infof("[manager] %s declared AWS maintenance from %s to %s, freezing the checkout from %s: %s", by,
+		window.Start.Format(time.RFC3339), window.End.Format(time.RFC3339), window.Start.Add(-maintenanceLead).Format(time.RFC3339),
+		window.Reason)
+	m.statusLock.Lock()
+	defer m.statusLock.Unlock()
+	// replaced rather than appended to, since copies of the status share it
+	windows := append([]sdk.MaintenanceWindow{window}, m.maintenance...)
+	sort.SliceStable(windows, func(i, k int) bool { return windows[i].Start.Before(windows[k].Start) })
+	m.maintenance = windows
+	window.Frozen = frozenFor(window, now)
+	return window, nil
+}
+
+// CancelMaintenance cancels the maintenance windows declared through the admin api on behalf of the named user,
+// returning how many were cancelled. Configured windows can only be removed from the configuration
+func (m *AWS) CancelMaintenance(by string) int {
+	m.statusLock.Lock()
+	defer m.statusLock.Unlock()
+	var kept []sdk.MaintenanceWindow
+	for _, window := range m.maintenance {
+		if window.DeclaredBy == "" {
+			kept = append(kept, window)
+		}
+	}
+	cancelled := len(m.maintenance) - len(kept)
+	if cancelled > 0 {
+		logrus.Infof("[manager] %s cancelled %d declared maintenance window(s)", by, cancelled)
+	}
+	m.maintenance = kept
+	return cancelled
+}
+
+// maintenanceStatus returns the windows which haven't ended by now, marking those the checkout is frozen for. Must be
+// called while holding the statusLock
+func (m *AWS) maintenanceStatus(now time.Time) []sdk.MaintenanceWindow {
+	var windows []sdk.MaintenanceWindow
+	for _, window := range m.maintenance {
+		if window.End.After(now) {
+			window.Frozen = frozenFor(window, now)
+			windows = append(windows, window)
+		}
+	}
+	return windows
+}
+
+// frozenFor returns whether the checkout is frozen for window at now
+func frozenFor(window sdk.MaintenanceWindow, now time.Time) bool {
+	return !now.Before(window.Start.Add(-maintenanceLead)) && now.Before(window.End)
+}
+
+// freeze returns the maintenance window the checkout is frozen for at now, false if there is none. Overlapping windows
+// freeze the checkout until the last of them ends
+func (m *AWS) freeze(now time.Time) (sdk.MaintenanceWindow, bool) {
+	m.statusLock.RLock()
+	defer m.statusLock.RUnlock()
+	for _, window := range m.maintenance {
+		if frozenFor(window, now) {
+			return window, true
+		}
+	}
+	return sdk.MaintenanceWindow{}, false
+}
+
+// adjustmentsHeld returns whether checkouts and check-ins are held back at now, because they're paused or frozen for a
+// maintenance window
+func (m *AWS) adjustmentsHeld(now time.Time) bool {
+	if m.paused() {
+		return true
+	}
+	_, frozen := m.freeze(now)
+	return frozen
+}
+
+// extensionMargin returns how long before its expiry the checkout is extended at now. Ahead of a maintenance window,
+// the checkout is extended once it's no longer at nearly its full lifetime, so that it enters the window fully
+// extended. During the window, it's extended well ahead of its expiry
+func (m *AWS) extensionMargin(now time.Time) time.Duration {
+	window, frozen := m.freeze(now)
+	switch {
+	case !frozen:
+		return renewalMargin
+	case now.Before(window.Start):
+		return maxTokenLifetime - maintenanceLead
+	default:
+		return maintenanceMargin
+	}
+}
@@ -0,0 +1,76 @@
+package manager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/mocks"
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtensionMargin(t *testing.T) {
+	start := time.Now().Add(time.Hour)
+	mockAWS := NewAWS(mocks.NewMockAWSClient(5), mocks.NewMockK8sClient(nil), mocks.NewMockScraper(20), Options{
+		Maintenance: []sdk.MaintenanceWindow{{Start: start, End: start.Add(2 * time.Hour)}},
+	})
+	tests := []struct {
+		name string
+		at   time.Time
+		want time.Duration
+	}{
+		{name: "before the freeze", at: start.Add(-maintenanceLead - time.Second), want: renewalMargin},
+		{name: "ahead of the window", at: start.Add(-time.Minute), want: maxTokenLifetime - maintenanceLead},
+		{name: "during the window", at: start.Add(time.Hour), want: maintenanceMargin},
+		{name: "after the window", at: start.Add(2 * time.Hour), want: renewalMargin},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, mockAWS.extensionMargin(test.at))
+		})
+	}
+}
+
+func TestMaintenanceFreeze(t *testing.T) {
+	ctx := context.Background()
+	mockAWSClient := mocks.NewMockAWSClient(5)
+	mockK8s := mocks.NewMockK8sClient(nil)
+	mockScraper := mocks.NewMockScraper(20)
+	mockAWS := NewAWS(mockAWSClient, mockK8s, mockScraper, Options{})
+	require.NoError(t, mockAWS.runComplianceCheck(ctx))
+	token := mockK8s.CurrentSecretData[tokenKey]
+
+	_, err := mockAWS.DeclareMaintenance("admin", sdk.MaintenanceWindow{Start: time.Now().Add(time.Hour), End: time.Now()})
+	assert.Error(t, err, "windows must end after they start")
+	window, err := mockAWS.DeclareMaintenance("admin", sdk.MaintenanceWindow{
+		Start:  time.Now().Add(time.Minute),
+		End:    time.Now().Add(time.Hour),
+		Reason: "AWS Health event",
+	})
+	require.NoError(t, err)
+	assert.True(t, window.Frozen, "the checkout is frozen ahead of the window")
+	assert.Equal(t, "admin", window.DeclaredBy)
+
+	// the checkout expires in an hour, so a renewal within the lead extends it to its full lifetime
+	mockK8s.CurrentSecretData[expiryKey] = time.Now().Add(50 * time.Minute).Format(time.RFC3339)
+	renewed, err := mockAWS.renewCurrentCheckout(ctx)
+	require.NoError(t, err)
+	assert.True(t, renewed)
+	assert.Equal(t, "1", mockK8s.CurrentSecretData[extensionKey], "the checkout is extended ahead of the window")
+
+	mockScraper.Nodes = 60
+	require.NoError(t, mockAWS.runComplianceCheck(ctx))
+	assert.Equal(t, token, mockK8s.CurrentSecretData[tokenKey], "the checkout isn't adjusted while frozen")
+	status := mockAWS.Status()
+	require.Len(t, status.Maintenance, 1)
+	assert.True(t, status.Maintenance[0].Frozen)
+	assert.Contains(t, status.Compliance.Message, "frozen for AWS maintenance")
+
+	assert.Equal(t, 1, mockAWS.CancelMaintenance("admin"))
+	require.NoError(t, mockAWS.runComplianceCheck(ctx))
+	assert.Equal(t, "3", mockK8s.CurrentSecretData[nodeKey])
+	assert.Empty(t, mockAWS.Status().Maintenance)
+}
@@ -0,0 +1,63 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// NodeCountError is returned by the compliance check once nodes couldn't be counted for
+// Options.NodeCountFailureThreshold consecutive checks
+type NodeCountError struct {
+	Failures int
+	Err      error
+}
+
+func (e *NodeCountError) Error() string {
+	return fmt.Sprintf("unable to determine number of active nodes for %d consecutive checks: %v", e.Failures, e.Err)
+}
+
+func (e *NodeCountError) Unwrap() error {
+	return e.Err
+}
+
+// handleNodeCountFailure decides how a compliance check proceeds when nodes couldn't be counted. Short outages of the
+// counting source keep the current checkout renewed. Once they persist, the checkout is no longer renewed at a count
+// which may be stale, and a NodeCountError is returned so that the status is reported as degraded. The checkouts of
+// LicenseCheckoutRequests don't depend on the node count and are renewed either way
+func (m *AWS) handleNodeCountFailure(ctx context.Context, scrapeErr error) error {
+	m.nodeCountFailures++
+	if m.nodeCountFailures >= m.opts.NodeCountFailureThreshold {
+		m.renewCheckoutRequests(ctx, time.Now())
+		return &NodeCountError{Failures: m.nodeCountFailures, Err: scrapeErr}
+	}
+	logrus.Warnf("[manager] unable to count nodes (%d of %d allowed failures), keeping current checkout: %v",
+		m.nodeCountFailures, m.opts.NodeCountFailureThreshold, scrapeErr)
+	renewed, err := m.renewCheckout(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to renew checkout while nodes can't be counted: %w", err)
+	}
+	if !renewed {
+		// renewCheckout only renews them along with a checkout of the adapter's own
+		m.renewCheckoutRequests(ctx, time.Now())
+	}
+	return nil
+}
+
+// describeSources lists the nodes counted by each source, i.e. "clusters: 12, metrics: 10"
+func describeSources(sources map[string]int) string {
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	counts := make([]string, 0, len(names))
+	for _, name := range names {
+		counts = append(counts, fmt.Sprintf("%s: %d", name, sources[name]))
+	}
+	return strings.Join(counts, ", ")
+}
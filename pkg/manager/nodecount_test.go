@@ -0,0 +1,38 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/rancher/csp-adapter/pkg/mocks"
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNodeCountFailures(t *testing.T) {
+	mockScraper := mocks.NewMockScraper(20)
+	mockAWS := NewAWS(mocks.NewMockAWSClient(5), mocks.NewMockK8sClient(nil), mockScraper, Options{NodeCountFailureThreshold: 3})
+	ctx := context.Background()
+	assert.NoError(t, mockAWS.runComplianceCheck(ctx))
+	assert.Equal(t, sdk.ComplianceStatusCompliant, mockAWS.Status().Compliance.Status)
+
+	mockScraper.Err = fmt.Errorf("rancher metrics unavailable")
+	for i := 1; i < 3; i++ {
+		assert.NoErrorf(t, mockAWS.runComplianceCheck(ctx), "failure %d should be tolerated", i)
+		assert.Equal(t, sdk.ComplianceStatusCompliant, mockAWS.Status().Compliance.Status, "status should be kept while failures are tolerated")
+	}
+	err := mockAWS.runComplianceCheck(ctx)
+	var nodeCountErr *NodeCountError
+	assert.True(t, errors.As(err, &nodeCountErr), "expected NodeCountError once the threshold is reached, got %v", err)
+	errs := make(chan error, 2)
+	mockAWS.reportCheckError(ctx, err, errs)
+	assert.Equal(t, sdk.ReasonNodeCountUnavailable, mockAWS.Status().Compliance.Reason)
+	assert.Equal(t, sdk.ComplianceStatusNonCompliant, mockAWS.Status().Compliance.Status)
+
+	mockScraper.Err = nil
+	assert.NoError(t, mockAWS.runComplianceCheck(ctx))
+	assert.Equal(t, sdk.ComplianceStatusCompliant, mockAWS.Status().Compliance.Status)
+	assert.Equal(t, 0, mockAWS.nodeCountFailures, "failures should be reset once nodes are counted")
+}
@@ -0,0 +1,99 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/rancher/csp-adapter/pkg/metrics"
+	"github.com/sirupsen/logrus"
+)
+
+// Audit runs a full compliance check immediately instead of waiting for the next scheduled check. Failures are
+// reported the same way as failures of scheduled checks
+func (m *AWS) Audit(ctx context.Context, progress func(message string)) error {
+	progress("waiting for running compliance check")
+	m.checkLock.Lock()
+	defer m.checkLock.Unlock()
+	progress("running compliance check")
+	if err := m.runComplianceCheck(ctx); err != nil {
+		reason, notification := describeError(err, m.location())
+		if updErr := m.updateAdapterOutput(false, reason, fmt.Sprintf("unable to run compliance check with error: %v", err), notification); updErr != nil {
+			logrus.Warnf("[manager] unable to report failed audit: %v", updErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// checkInAllPauser is who pauses checkout adjustments when all licenses are checked in
+const checkInAllPauser = "check-in-all job"
+
+// CheckInAll returns every license held by the adapter to AWS, along with the tokens whose check-in failed before and is
+// still pending, i.e. before the adapter is uninstalled. Checkout adjustments are paused first, so that the following
+// compliance checks don't check licenses out again until they're resumed
+func (m *AWS) CheckInAll(ctx context.Context, progress func(message string)) error {
+	progress("waiting for running compliance check")
+	m.checkLock.Lock()
+	defer m.checkLock.Unlock()
+	m.Pause(checkInAllPauser, "all licenses were checked in, resume checkout adjustments to check them out again")
+	progress("paused checkout adjustments until they're resumed")
+	info, err := m.getLicenseCheckoutInfo()
+	if err != nil {
+		return fmt.Errorf("unable to get current license consumption info: %w", err)
+	}
+	if info.ConsumptionToken == "" && len(info.PendingCheckIns) == 0 {
+		progress("no licenses checked out")
+		return nil
+	}
+	var tokens []string
+	if info.ConsumptionToken != "" {
+		progress(fmt.Sprintf("checking in %d license(s)", info.EntitledLicenses))
+		tokens = append(tokens, info.ConsumptionToken)
+	}
+	if len(info.PendingCheckIns) > 0 {
+		progress(fmt.Sprintf("checking in %d token(s) whose check-in failed before", len(info.PendingCheckIns)))
+		for _, pending := range info.PendingCheckIns {
+			tokens = append(tokens, pending.Token)
+		}
+	}
+	results := m.aws.CheckInRancherLicenses(ctx, tokens)
+	var remaining []pendingCheckIn
+	for _, pending := range info.PendingCheckIns {
+		if results[pending.Token] != nil {
+			remaining = append(remaining, pending)
+		}
+	}
+	info.PendingCheckIns = remaining
+	metrics.PendingCheckIns.Set(float64(len(remaining)))
+	var heldErr error
+	if info.ConsumptionToken != "" {
+		if heldErr = results[info.ConsumptionToken]; heldErr == nil {
+			info.ConsumptionToken = ""
+			info.EntitledLicenses = 0
+		}
+	}
+	// saved even if the held licenses couldn't be checked in, so that pending check-ins which succeeded aren't retried
+	saveErr := m.saveCheckoutInfo(info)
+	if heldErr != nil {
+		return fmt.Errorf("unable to check in licenses: %w", heldErr)
+	}
+	if saveErr != nil {
+		return fmt.Errorf("checked in licenses but unable to save checkout info: %w", saveErr)
+	}
+	if len(remaining) > 0 {
+		progress(fmt.Sprintf("checked in all licenses, %d pending check-in(s) failed again and are retried until their tokens expire", len(remaining)))
+		return nil
+	}
+	progress("checked in all licenses")
+	return nil
+}
+
+// Export writes the latest compliance report to the report sinks again, i.e. after a sink was reconfigured or its
+// object was removed
+func (m *AWS) Export(ctx context.Context, progress func(message string)) error {
+	if m.opts.Reports == nil {
+		return errors.New("no report sinks are configured")
+	}
+	return m.opts.Reports.Export(ctx, progress)
+}
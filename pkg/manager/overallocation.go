@@ -0,0 +1,68 @@
+package manager
+
+import (
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/sirupsen/logrus"
+)
+
+// overAllocationMode returns the configured handling of excess licenses, defaulting to checking them in
+func (m *AWS) overAllocationMode() string {
+	if m.opts.OverAllocationMode == "" {
+		return sdk.OverAllocationCheckIn
+	}
+	return m.opts.OverAllocationMode
+}
+
+// keepExcessLicenses returns whether the licenses in info, which may be more than required after rancher scaled down,
+// are kept rather than replaced with a checkout of the required licenses. Must be called while holding the checkLock
+func (m *AWS) keepExcessLicenses(info *licenseCheckoutInfo, required int, now time.Time) bool {
+	if info.ConsumptionToken == "" || info.EntitledLicenses <= required {
+		m.overAllocatedSince = time.Time{}
+		return false
+	}
+	if releaseAt, zeroNodes := m.zeroNodeReleaseAt(); zeroNodes {
+		// the zero node mode replaces the over-allocation mode while no nodes are licensed
+		m.overAllocatedSince = time.Time{}
+		return now.Before(releaseAt)
+	}
+	if m.overAllocatedSince.IsZero() {
+		m.overAllocatedSince = now
+		if m.overAllocationMode() != sdk.OverAllocationCheckIn {
+			logrus.Infof("[manager] %d license(s) are checked out but only %d are required, keeping them until %s",
+				info.EntitledLicenses, required, m.excessReleaseAt(info).Format(time.RFC3339))
+		}
+	}
+	releaseAt := m.excessReleaseAt(info)
+	return !releaseAt.IsZero() && now.Before(releaseAt)
+}
+
+// excessReleaseAt returns when the excess licenses in info are checked in, zero if the adapter doesn't hold any. Must
+// be called while holding the checkLock
+func (m *AWS) excessReleaseAt(info *licenseCheckoutInfo) time.Time {
+	zeroReleaseAt, zeroNodes := m.zeroNodeReleaseAt()
+	if !m.scaleDownSince.IsZero() {
+		// the scale-down is confirmed first, excess licenses are handled according to the mode afterwards
+		confirmedAt := m.scaleDownSince.Add(m.opts.ScaleDownConfirmation)
+		if zeroNodes && zeroReleaseAt.After(confirmedAt) {
+			return zeroReleaseAt
+		}
+		return confirmedAt
+	}
+	if zeroNodes {
+		return zeroReleaseAt
+	}
+	if m.overAllocatedSince.IsZero() {
+		return time.Time{}
+	}
+	switch m.overAllocationMode() {
+	case sdk.OverAllocationRetain:
+		return m.overAllocatedSince.Add(m.opts.OverAllocationRetention)
+	case sdk.OverAllocationExpire:
+		// the token is replaced when it would otherwise be extended
+		return info.Expiry.Add(-renewalMargin)
+	default:
+		return m.overAllocatedSince
+	}
+}
@@ -0,0 +1,76 @@
+package manager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/mocks"
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOverAllocation(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		// keep is whether the excess licenses are kept by the check right after scaling down
+		keep bool
+		// release makes the kept excess licenses due for check-in
+		release func(m *AWS, mockK8s *mocks.MockK8sClient)
+	}{
+		{
+			name: "check in",
+		},
+		{
+			name: "retain",
+			opts: Options{OverAllocationMode: sdk.OverAllocationRetain, OverAllocationRetention: time.Hour},
+			keep: true,
+			release: func(m *AWS, mockK8s *mocks.MockK8sClient) {
+				m.overAllocatedSince = m.overAllocatedSince.Add(-2 * time.Hour)
+			},
+		},
+		{
+			name: "expire",
+			opts: Options{OverAllocationMode: sdk.OverAllocationExpire},
+			keep: true,
+			release: func(m *AWS, mockK8s *mocks.MockK8sClient) {
+				mockK8s.CurrentSecretData[expiryKey] = time.Now().Add(time.Minute).Format(time.RFC3339)
+			},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			mockAWSClient := mocks.NewMockAWSClient(5)
+			mockK8s := mocks.NewMockK8sClient(nil)
+			mockScraper := mocks.NewMockScraper(60)
+			mockAWS := NewAWS(mockAWSClient, mockK8s, mockScraper, test.opts)
+			require.NoError(t, mockAWS.runComplianceCheck(ctx))
+			assert.Equal(t, "3", mockK8s.CurrentSecretData[nodeKey])
+
+			mockScraper.Nodes = 20
+			require.NoError(t, mockAWS.runComplianceCheck(ctx))
+			status := mockAWS.Status()
+			assert.Equal(t, sdk.ReasonLicensed, status.Compliance.Reason)
+			assert.Equal(t, mockAWS.overAllocationMode(), status.Usage.OverAllocationMode)
+			if !test.keep {
+				assert.Equal(t, "1", mockK8s.CurrentSecretData[nodeKey])
+				assert.Nil(t, status.Usage.ExcessReleaseAt)
+				return
+			}
+			assert.Equal(t, "3", mockK8s.CurrentSecretData[nodeKey])
+			assert.Equal(t, 3, status.Usage.CheckedOutLicenses)
+			require.NotNil(t, status.Usage.ExcessReleaseAt)
+			assert.True(t, status.Usage.ExcessReleaseAt.After(time.Now()))
+
+			test.release(mockAWS, mockK8s)
+			require.NoError(t, mockAWS.runComplianceCheck(ctx))
+			assert.Equal(t, "1", mockK8s.CurrentSecretData[nodeKey])
+			assert.Len(t, mockAWSClient.CheckedOutEntitlements, 1)
+			assert.Nil(t, mockAWS.Status().Usage.ExcessReleaseAt)
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package manager
+
+import (
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/clients/k8s"
+	"github.com/rancher/csp-adapter/pkg/events"
+	"github.com/rancher/csp-adapter/pkg/metrics"
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/sirupsen/logrus"
+)
+
+// Pause halts checkout adjustments until Resume is called, i.e. while operators respond to an incident and need the
+// adapter to stop changing AWS state. Compliance checks keep renewing the held checkout and reporting compliance, but
+// no licenses are checked out or in, so compliance may drift while paused. Pausing again only updates the reason
+func (m *AWS) Pause(by, reason string) sdk.PauseStatus {
+	m.statusLock.Lock()
+	defer m.statusLock.Unlock()
+	paused := sdk.PauseStatus{By: by, Reason: reason, Since: time.Now()}
+	change := events.ConfigChange{
+		Kind:     events.ConfigCheckoutAdjustments,
+		Name:     "paused",
+		Value:    "true",
+		Previous: "false",
+		By:       by,
+		Reason:   reason,
+	}
+	if m.status.Paused != nil {
+		paused.Since = m.status.Paused.Since
+		change.Previous = "true"
+	} else {
+		logrus.Warnf("[manager] checkout adjustments paused by %s: %s", by, reason)
+	}
+	m.opts.Events.Publish(events.TypeConfigChanged, m.aws.AccountNumber(), change)
+	// replaced rather than updated, since copies of the status share it
+	m.status.Paused = &paused
+	metrics.Paused.Set(1)
+	return paused
+}
+
+// Resume lets compliance checks adjust the checkout again, triggering a check which catches up with the current usage
+func (m *AWS) Resume(by string) {
+	m.statusLock.Lock()
+	defer m.statusLock.Unlock()
+	if m.status.Paused == nil {
+		return
+	}
+	logrus.Infof("[manager] checkout adjustments resumed by %s after %s", by, time.Since(m.status.Paused.Since).Round(time.Second))
+	m.status.Paused = nil
+	metrics.Paused.Set(0)
+	m.opts.Events.Publish(events.TypeConfigChanged, m.aws.AccountNumber(), events.ConfigChange{
+		Kind:     events.ConfigCheckoutAdjustments,
+		Name:     "paused",
+		Value:    "false",
+		Previous: "true",
+		By:       by,
+	})
+	m.TriggerCheck()
+}
+
+// adapterConfigPauser is who pauses checkout adjustments through the CSPAdapterConfig
+const adapterConfigPauser = "CSPAdapterConfig " + k8s.AdapterConfigName
+
+// applyConfigPause pauses checkout adjustments while the CSPAdapterConfig pauses them, and resumes them once it stops
+// unless they were paused by someone else since. Must be called while holding the checkLock
+func (m *AWS) applyConfigPause() {
+	m.statusLock.RLock()
+	current := m.status.Paused
+	m.statusLock.RUnlock()
+	if m.config != nil && m.config.Paused {
+		if current == nil || current.By != adapterConfigPauser || current.Reason != m.config.PauseReason {
+			m.Pause(adapterConfigPauser, m.config.PauseReason)
+		}
+		return
+	}
+	if current != nil && current.By == adapterConfigPauser {
+		m.Resume(adapterConfigPauser)
+	}
+}
+
+// paused returns whether checkout adjustments are paused
+func (m *AWS) paused() bool {
+	m.statusLock.RLock()
+	defer m.statusLock.RUnlock()
+	return m.status.Paused != nil
+}
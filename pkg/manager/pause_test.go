@@ -0,0 +1,106 @@
+package manager
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rancher/csp-adapter/pkg/clients/k8s"
+	"github.com/rancher/csp-adapter/pkg/events"
+	"github.com/rancher/csp-adapter/pkg/mocks"
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPause(t *testing.T) {
+	ctx := context.Background()
+	mockAWSClient := mocks.NewMockAWSClient(5)
+	mockK8s := mocks.NewMockK8sClient(nil)
+	mockScraper := mocks.NewMockScraper(20)
+	mockAWS := NewAWS(mockAWSClient, mockK8s, mockScraper, Options{})
+	require.NoError(t, mockAWS.runComplianceCheck(ctx))
+	token := mockK8s.CurrentSecretData[tokenKey]
+
+	paused := mockAWS.Pause("admin", "incident")
+	assert.Equal(t, "incident", paused.Reason)
+	// pausing again keeps when the adapter was paused
+	assert.Equal(t, paused.Since, mockAWS.Pause("admin", "still investigating").Since)
+	mockScraper.Nodes = 60
+	require.NoError(t, mockAWS.runComplianceCheck(ctx))
+	assert.Equal(t, token, mockK8s.CurrentSecretData[tokenKey], "the checkout shouldn't be adjusted while paused")
+	assert.Equal(t, "1", mockK8s.CurrentSecretData[nodeKey])
+	status := mockAWS.Status()
+	require.NotNil(t, status.Paused)
+	assert.Equal(t, "still investigating", status.Paused.Reason)
+	assert.Equal(t, sdk.ReasonInsufficientLicenses, status.Compliance.Reason)
+	assert.Contains(t, status.Compliance.Message, "checkout adjustments are paused")
+
+	mockAWS.Resume("admin")
+	select {
+	case <-mockAWS.trigger:
+	default:
+		t.Fatal("resuming should trigger a compliance check")
+	}
+	require.NoError(t, mockAWS.runComplianceCheck(ctx))
+	assert.Equal(t, "3", mockK8s.CurrentSecretData[nodeKey])
+	assert.Len(t, mockAWSClient.CheckedOutEntitlements, 1)
+	status = mockAWS.Status()
+	assert.Nil(t, status.Paused)
+	assert.Equal(t, sdk.ReasonLicensed, status.Compliance.Reason)
+}
+
+// publishedTypes records the types of the events published to the firehose
+type publishedTypes []string
+
+func (p *publishedTypes) Publish(ctx context.Context, event events.Event, payload []byte) error {
+	*p = append(*p, event.Type)
+	return nil
+}
+
+func (p *publishedTypes) Close() error {
+	return nil
+}
+
+func TestPublishChanges(t *testing.T) {
+	ctx := context.Background()
+	var published publishedTypes
+	firehose := events.NewFirehose(&published, 10)
+	mockAWS := NewAWS(mocks.NewMockAWSClient(5), mocks.NewMockK8sClient(nil), mocks.NewMockScraper(20), Options{Events: firehose})
+	require.NoError(t, mockAWS.runComplianceCheck(ctx))
+	mockAWS.Pause("admin", "incident")
+	mockAWS.Resume("admin")
+	// the compliance didn't change
+	require.NoError(t, mockAWS.runComplianceCheck(ctx))
+
+	done, cancel := context.WithCancel(ctx)
+	cancel()
+	firehose.Run(done)
+	assert.Equal(t, publishedTypes{events.TypeComplianceChanged, events.TypeConfigChanged, events.TypeConfigChanged}, published)
+}
+
+func TestAdapterConfigPause(t *testing.T) {
+	ctx := context.Background()
+	mockK8s := mocks.NewMockK8sClient(nil)
+	mockScraper := mocks.NewMockScraper(20)
+	mockAWS := NewAWS(mocks.NewMockAWSClient(5), mockK8s, mockScraper, Options{})
+	require.NoError(t, mockAWS.runComplianceCheck(ctx))
+
+	mockK8s.AdapterConfig = &k8s.AdapterConfig{Paused: true, PauseReason: "INC-123"}
+	mockScraper.Nodes = 60
+	require.NoError(t, mockAWS.runComplianceCheck(ctx))
+	assert.Equal(t, "1", mockK8s.CurrentSecretData[nodeKey], "the checkout shouldn't be adjusted while the config pauses it")
+	status := mockAWS.Status()
+	require.NotNil(t, status.Paused)
+	assert.Equal(t, adapterConfigPauser, status.Paused.By)
+	assert.Equal(t, "INC-123", status.Paused.Reason)
+
+	mockK8s.AdapterConfig = &k8s.AdapterConfig{}
+	require.NoError(t, mockAWS.runComplianceCheck(ctx))
+	assert.Nil(t, mockAWS.Status().Paused, "unpausing the config should resume")
+	require.NoError(t, mockAWS.runComplianceCheck(ctx))
+	assert.Equal(t, "3", mockK8s.CurrentSecretData[nodeKey])
+
+	mockAWS.Pause("admin", "incident")
+	require.NoError(t, mockAWS.runComplianceCheck(ctx))
+	assert.Equal(t, "admin", mockAWS.Status().Paused.By, "pauses made elsewhere shouldn't be resumed by the config")
+}
@@ -0,0 +1,92 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/metrics"
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/sirupsen/logrus"
+)
+
+// permissionCheckInterval is how often the adapter's kubernetes permissions are checked, so that roles edited after
+// the install are noticed
+const permissionCheckInterval = 10 * time.Minute
+
+// checkPermissionsPeriodically checks the adapter's kubernetes permissions every permissionCheckInterval until ctx is
+// cancelled
+func (m *AWS) checkPermissionsPeriodically(ctx context.Context) {
+	m.checkPermissions(ctx)
+	for range ticker(ctx, permissionCheckInterval) {
+		m.checkPermissions(ctx)
+	}
+}
+
+// checkPermissions reviews the adapter's kubernetes permissions, reporting the missing ones in the status with the rbac
+// rules which grant them, so that a misconfigured role can be fixed before it fails a compliance check
+func (m *AWS) checkPermissions(ctx context.Context) {
+	missing, err := m.opts.Permissions.MissingPermissions(ctx)
+	condition := sdk.Condition{
+		Type:   sdk.ConditionPermissionsGranted,
+		Status: "True",
+	}
+	var report []sdk.MissingPermission
+	switch {
+	case err != nil:
+		logrus.Warnf("[manager] unable to check the adapter's kubernetes permissions: %v", err)
+		condition.Status = "Unknown"
+		condition.Reason = "ReviewFailed"
+		condition.Message = err.Error()
+	case len(missing) > 0:
+		condition.Status = "False"
+		condition.Reason = "MissingPermissions"
+		condition.Message = fmt.Sprintf("%d kubernetes permission(s) are missing, see missingPermissions for the rbac rules to add", len(missing))
+		for _, permission := range missing {
+			rule, role := permission.Rule()
+			logrus.Warnf("[manager] missing kubernetes permission to %s, add %s to the adapter's %s", permission, rule, role)
+			report = append(report, sdk.MissingPermission{
+				Verb:      permission.Verb,
+				Group:     permission.Group,
+				Resource:  permission.Resource,
+				Namespace: permission.Namespace,
+				Name:      permission.Name,
+				Rule:      rule,
+				Role:      role,
+			})
+		}
+	}
+	if err == nil {
+		metrics.MissingPermissions.Set(float64(len(missing)))
+	}
+	m.statusLock.Lock()
+	defer m.statusLock.Unlock()
+	m.status.Conditions = setStatusCondition(m.status.Conditions, condition, time.Now())
+	if err == nil {
+		// the previous result is kept while the permissions can't be reviewed
+		m.status.MissingPermissions = report
+	}
+}
+
+// setStatusCondition returns conditions with the condition of desired's type replaced by desired. The transition time
+// is kept unless the status changed
+func setStatusCondition(conditions []sdk.Condition, desired sdk.Condition, now time.Time) []sdk.Condition {
+	desired.LastTransitionTime = now
+	updated := make([]sdk.Condition, 0, len(conditions)+1)
+	found := false
+	for _, condition := range conditions {
+		if condition.Type != desired.Type {
+			updated = append(updated, condition)
+			continue
+		}
+		if condition.Status == desired.Status {
+			desired.LastTransitionTime = condition.LastTransitionTime
+		}
+		updated = append(updated, desired)
+		found = true
+	}
+	if !found {
+		updated = append(updated, desired)
+	}
+	return updated
+}
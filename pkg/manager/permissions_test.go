@@ -0,0 +1,66 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/rancher/csp-adapter/pkg/clients/k8s"
+	"github.com/rancher/csp-adapter/pkg/mocks"
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockPermissions struct {
+	missing []k8s.Permission
+	err     error
+}
+
+func (p *mockPermissions) MissingPermissions(ctx context.Context) ([]k8s.Permission, error) {
+	return p.missing, p.err
+}
+
+func TestCheckPermissions(t *testing.T) {
+	permissions := &mockPermissions{}
+	m := NewAWS(mocks.NewMockAWSClient(2), mocks.NewMockK8sClient(nil), mocks.NewMockScraper(10), Options{Permissions: permissions})
+
+	m.checkPermissions(context.Background())
+	status := m.Status()
+	assert.Len(t, status.Conditions, 1)
+	assert.Equal(t, sdk.ConditionPermissionsGranted, status.Conditions[0].Type)
+	assert.Equal(t, "True", status.Conditions[0].Status)
+	assert.Empty(t, status.MissingPermissions)
+	granted := status.Conditions[0].LastTransitionTime
+
+	permissions.missing = []k8s.Permission{{Verb: "update", Group: "management.cattle.io", Resource: "clusters", Name: "local"}}
+	m.checkPermissions(context.Background())
+	status = m.Status()
+	assert.Len(t, status.Conditions, 1)
+	assert.Equal(t, "False", status.Conditions[0].Status)
+	assert.Equal(t, "MissingPermissions", status.Conditions[0].Reason)
+	assert.False(t, status.Conditions[0].LastTransitionTime.Before(granted))
+	if assert.Len(t, status.MissingPermissions, 1) {
+		assert.Equal(t, `{apiGroups: ["management.cattle.io"], resources: ["clusters"], resourceNames: ["local"], verbs: ["update"]}`,
+			status.MissingPermissions[0].Rule)
+		assert.Equal(t, "ClusterRole", status.MissingPermissions[0].Role)
+	}
+	missingSince := status.Conditions[0].LastTransitionTime
+
+	// failed reviews keep the permissions found missing before
+	permissions.err = errors.New("unavailable")
+	m.checkPermissions(context.Background())
+	status = m.Status()
+	assert.Equal(t, "Unknown", status.Conditions[0].Status)
+	assert.Len(t, status.MissingPermissions, 1)
+
+	// the transition time only changes with the status
+	permissions.err = nil
+	m.checkPermissions(context.Background())
+	m.checkPermissions(context.Background())
+	status = m.Status()
+	assert.Equal(t, "False", status.Conditions[0].Status)
+	assert.False(t, status.Conditions[0].LastTransitionTime.Before(missingSince))
+	last := status.Conditions[0].LastTransitionTime
+	m.checkPermissions(context.Background())
+	assert.Equal(t, last, m.Status().Conditions[0].LastTransitionTime)
+}
@@ -0,0 +1,83 @@
+package manager
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// readCacheTTL is how long a read of License Manager served to api callers is fresh. Older reads are still served
+	// while they're refreshed in the background
+	readCacheTTL = 5 * time.Minute
+	// readRefreshTimeout limits background refreshes, which outlive the request that triggered them
+	readRefreshTimeout = 30 * time.Second
+)
+
+// readThrough caches a read of License Manager for api callers with stale-while-revalidate semantics: only the first
+// read waits for License Manager, later reads are served from the cache and trigger a background refresh once the
+// cached result is older than the ttl. A failed refresh keeps serving the last result and is reported with it
+type readThrough struct {
+	name  string
+	fetch func(ctx context.Context) (interface{}, error)
+	ttl   time.Duration
+	now   func() time.Time
+
+	lock       sync.Mutex
+	value      interface{}
+	fetchedAt  time.Time
+	refreshing bool
+	refreshErr error
+	// refreshed is called when a background refresh finishes, for tests
+	refreshed func()
+}
+
+func newReadThrough(name string, fetch func(ctx context.Context) (interface{}, error)) *readThrough {
+	return &readThrough{name: name, fetch: fetch, ttl: readCacheTTL, now: time.Now}
+}
+
+// get returns the cached result and its freshness, fetching it first if nothing is cached yet
+func (c *readThrough) get(ctx context.Context) (interface{}, sdk.Freshness, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.fetchedAt.IsZero() {
+		// concurrent first reads wait for the same fetch instead of each calling License Manager
+		value, err := c.fetch(ctx)
+		if err != nil {
+			return nil, sdk.Freshness{}, err
+		}
+		c.value, c.fetchedAt = value, c.now()
+	}
+	freshness := sdk.Freshness{FetchedAt: c.fetchedAt, Stale: c.now().Sub(c.fetchedAt) >= c.ttl}
+	if c.refreshErr != nil {
+		freshness.RefreshError = c.refreshErr.Error()
+	}
+	if freshness.Stale && !c.refreshing {
+		c.refreshing = true
+		go c.refresh()
+	}
+	return c.value, freshness, nil
+}
+
+// refresh replaces the cached result with a fresh read, keeping it if the read fails
+func (c *readThrough) refresh() {
+	ctx, cancel := context.WithTimeout(context.Background(), readRefreshTimeout)
+	defer cancel()
+	value, err := c.fetch(ctx)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.refreshing = false
+	if err != nil {
+		logrus.Warnf("[manager] unable to refresh the cached %s, serving the result from %s: %v", c.name,
+			c.fetchedAt.Format(time.RFC3339), err)
+		c.refreshErr = err
+	} else {
+		c.value, c.fetchedAt, c.refreshErr = value, c.now(), nil
+	}
+	if c.refreshed != nil {
+		c.refreshed()
+	}
+}
@@ -0,0 +1,62 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadThrough(t *testing.T) {
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	fetches := 0
+	var fetchErr error
+	release := make(chan struct{})
+	cache := newReadThrough("test", func(ctx context.Context) (interface{}, error) {
+		if fetches > 0 {
+			<-release
+		}
+		fetches++
+		return fetches, fetchErr
+	})
+	cache.now = func() time.Time { return now }
+	refreshed := make(chan struct{})
+	cache.refreshed = func() { refreshed <- struct{}{} }
+
+	value, freshness, err := cache.get(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, value, "the first read is fetched")
+	assert.False(t, freshness.Stale)
+
+	now = now.Add(readCacheTTL)
+	value, freshness, err = cache.get(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, value, "stale reads are served without waiting for the refresh")
+	assert.True(t, freshness.Stale)
+	_, _, err = cache.get(context.Background())
+	require.NoError(t, err)
+	close(release)
+	<-refreshed
+	assert.Equal(t, 2, fetches, "a single refresh runs at a time")
+
+	value, freshness, err = cache.get(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, value)
+	assert.False(t, freshness.Stale)
+	assert.Equal(t, now, freshness.FetchedAt)
+
+	fetchErr = errors.New("throttled")
+	now = now.Add(readCacheTTL)
+	_, _, err = cache.get(context.Background())
+	require.NoError(t, err)
+	<-refreshed
+	value, freshness, err = cache.get(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, value, "a failed refresh keeps the last result")
+	assert.True(t, freshness.Stale)
+	assert.Equal(t, "throttled", freshness.RefreshError)
+	<-refreshed
+}
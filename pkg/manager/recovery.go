@@ -0,0 +1,102 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	lm "github.com/aws/aws-sdk-go-v2/service/licensemanager"
+	"github.com/aws/aws-sdk-go-v2/service/licensemanager/types"
+	"github.com/google/uuid"
+	"github.com/rancher/csp-adapter/pkg/audit"
+	"github.com/rancher/csp-adapter/pkg/clients/aws"
+	"github.com/sirupsen/logrus"
+)
+
+// checkout checks out amount licenses. The checkout is recorded as pending in info before it's made, so that it can
+// be recovered by recoverPendingCheckout if its result is lost, and cleared from info once it succeeded. The caller
+// saves info with the result. Must be called while holding the checkLock
+func (m *AWS) checkout(ctx context.Context, license types.GrantedLicense, amount int, info *licenseCheckoutInfo) (*lm.CheckoutLicenseOutput, error) {
+	info.PendingCheckout = uuid.New().String()
+	info.PendingLicenses = amount
+	if err := m.saveCheckoutInfo(info); err != nil {
+		// a checkout which isn't recorded anywhere couldn't be recovered, so it isn't made
+		return nil, fmt.Errorf("unable to record pending checkout: %w", err)
+	}
+	resp, err := m.aws.CheckoutRancherLicense(aws.WithCheckoutToken(ctx, info.PendingCheckout), license, amount)
+	if err != nil {
+		// the checkout may have succeeded even though the call failed, i.e. on a timeout, so it stays pending
+		return nil, err
+	}
+	info.PendingCheckout = ""
+	info.PendingLicenses = 0
+	return resp, nil
+}
+
+// recoverPendingCheckout resolves a checkout which an earlier check started but didn't record the result of, because
+// the adapter crashed or the call failed. If License Manager's usage confirms that more licenses are consumed than
+// the adapter holds, the checkout is repeated with the same idempotency token, which returns its consumption token
+// without checking out more licenses. The recovered checkout is adopted if the adapter holds no other, and checked in
+// otherwise. Decisions are logged and audited. Returns the info which should be used for the rest of the compliance
+// check. Must be called while holding the checkLock
+func (m *AWS) recoverPendingCheckout(ctx context.Context, license types.GrantedLicense, info *licenseCheckoutInfo) *licenseCheckoutInfo {
+	if info.PendingCheckout == "" {
+		return info
+	}
+	usage, err := m.getEntitlementUsage(ctx, license, info.EntitledLicenses)
+	if err != nil {
+		logrus.Warnf("[manager] unable to get license usage to recover a pending checkout, retrying on the next check: %v", err)
+		return info
+	}
+	clientToken, amount := info.PendingCheckout, info.PendingLicenses
+	info.PendingCheckout = ""
+	info.PendingLicenses = 0
+	event := audit.NewEvent(m.aws.AccountNumber(), audit.ActionRecover, nil)
+	event.Entitlements = amount
+	event.LicenseArn = awssdk.ToString(license.LicenseArn)
+	if unaccounted := usage.Consumed - info.EntitledLicenses; unaccounted < amount {
+		logrus.Infof("[manager] pending checkout of %d license(s) didn't take effect, %d license(s) are consumed and %d are held",
+			amount, usage.Consumed, info.EntitledLicenses)
+		event.Decision = audit.DecisionDiscarded
+		m.emitAudit(ctx, event)
+		return info
+	}
+	resp, err := m.aws.CheckoutRancherLicense(aws.WithCheckoutToken(ctx, clientToken), license, amount)
+	if err != nil {
+		// nothing extends it, so it expires on its own
+		logrus.Warnf("[manager] unable to recover pending checkout of %d license(s), it will expire: %v", amount, err)
+		event.Outcome = audit.OutcomeFailure
+		event.Error = err.Error()
+		m.emitAudit(ctx, event)
+		return info
+	}
+	token := awssdk.ToString(resp.LicenseConsumptionToken)
+	event.TokenID = audit.TokenID(token)
+	if info.ConsumptionToken == "" {
+		logrus.Warnf("[manager] recovered a checkout of %d license(s) which wasn't recorded, adopting it", amount)
+		info.ConsumptionToken = token
+		info.EntitledLicenses = amount
+		info.Expiry = parseExpirationTimestamp(awssdk.ToString(resp.Expiration))
+		info.Extensions = 0
+		info.LicenseArn = awssdk.ToString(license.LicenseArn)
+		event.Decision = audit.DecisionAdopted
+		m.emitAudit(ctx, event)
+		return info
+	}
+	logrus.Warnf("[manager] recovered a checkout of %d license(s) which wasn't recorded while holding another, checking it in", amount)
+	event.Decision = audit.DecisionCheckedIn
+	if err := m.checkIn(ctx, token, parseExpirationTimestamp(awssdk.ToString(resp.Expiration)), info); err != nil {
+		logrus.Warnf("[manager] unable to check in recovered checkout, retrying until it expires: %v", err)
+		event.Outcome = audit.OutcomeFailure
+		event.Error = err.Error()
+	}
+	m.emitAudit(ctx, event)
+	return info
+}
+
+// emitAudit emits event to the audit sink, if one is configured
+func (m *AWS) emitAudit(ctx context.Context, event audit.Event) {
+	if m.opts.Audit != nil {
+		audit.Emit(ctx, m.opts.Audit, event)
+	}
+}
@@ -0,0 +1,118 @@
+package manager
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/audit"
+	"github.com/rancher/csp-adapter/pkg/clients/aws"
+	"github.com/rancher/csp-adapter/pkg/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecoverPendingCheckout(t *testing.T) {
+	tests := []struct {
+		name string
+		// orphaned is true if the pending checkout succeeded before the adapter crashed
+		orphaned bool
+		// held is true if the adapter recorded another checkout which it still holds
+		held             bool
+		expectedDecision string
+	}{
+		{
+			name:             "adopt",
+			orphaned:         true,
+			expectedDecision: audit.DecisionAdopted,
+		},
+		{
+			name:             "check in",
+			orphaned:         true,
+			held:             true,
+			expectedDecision: audit.DecisionCheckedIn,
+		},
+		{
+			name:             "discard",
+			expectedDecision: audit.DecisionDiscarded,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			mockAWSClient := mocks.NewMockAWSClient(10)
+			mockK8s := mocks.NewMockK8sClient(map[string]string{
+				tokenKey:     "",
+				nodeKey:      "0",
+				expiryKey:    time.Time{}.Format(time.RFC3339),
+				extensionKey: "0",
+				pendingKey:   "client-token",
+				pendingNodes: "2",
+			})
+			var orphan string
+			if test.orphaned {
+				resp, err := mockAWSClient.CheckoutRancherLicense(aws.WithCheckoutToken(ctx, "client-token"), mockAWSClient.License, 2)
+				require.NoError(t, err)
+				orphan = *resp.LicenseConsumptionToken
+			}
+			var held string
+			if test.held {
+				resp, err := mockAWSClient.CheckoutRancherLicense(ctx, mockAWSClient.License, 2)
+				require.NoError(t, err)
+				held = *resp.LicenseConsumptionToken
+				mockK8s.CurrentSecretData[tokenKey] = held
+				mockK8s.CurrentSecretData[nodeKey] = "2"
+				mockK8s.CurrentSecretData[expiryKey] = time.Now().Add(time.Hour).Format(time.RFC3339)
+			}
+			var events bytes.Buffer
+			mockAWS := NewAWS(mockAWSClient, mockK8s, mocks.NewMockScraper(40), Options{Audit: audit.NewWriterSink(&events)})
+			require.NoError(t, mockAWS.runComplianceCheck(ctx))
+
+			assert.Empty(t, mockK8s.CurrentSecretData[pendingKey])
+			assert.Len(t, mockAWSClient.CheckedOutEntitlements, 1, "only the checkout required for 40 nodes should be held")
+			switch test.expectedDecision {
+			case audit.DecisionAdopted:
+				assert.Equal(t, orphan, mockK8s.CurrentSecretData[tokenKey])
+			case audit.DecisionCheckedIn:
+				assert.Equal(t, held, mockK8s.CurrentSecretData[tokenKey])
+				assert.NotContains(t, mockAWSClient.CheckedOutEntitlements, orphan)
+			}
+
+			var recoveries []audit.Event
+			for _, line := range strings.Split(strings.TrimSpace(events.String()), "\n") {
+				var event audit.Event
+				require.NoError(t, json.Unmarshal([]byte(line), &event))
+				if event.Action == audit.ActionRecover {
+					recoveries = append(recoveries, event)
+				}
+			}
+			require.Len(t, recoveries, 1)
+			assert.Equal(t, test.expectedDecision, recoveries[0].Decision)
+			assert.Equal(t, audit.OutcomeSuccess, recoveries[0].Outcome)
+			assert.Equal(t, 2, recoveries[0].Entitlements)
+		})
+	}
+}
+
+func TestPendingCheckoutCleared(t *testing.T) {
+	ctx := context.Background()
+	mockAWSClient := mocks.NewMockAWSClient(10)
+	mockK8s := mocks.NewMockK8sClient(map[string]string{})
+	var events bytes.Buffer
+	mockAWS := NewAWS(mockAWSClient, mockK8s, mocks.NewMockScraper(40), Options{Audit: audit.NewWriterSink(&events)})
+	require.NoError(t, mockAWS.runComplianceCheck(ctx))
+	token := mockK8s.CurrentSecretData[tokenKey]
+	require.NotEmpty(t, token)
+
+	// the secret merges the keys of every save, the pending checkout must be cleared rather than left out
+	assert.Empty(t, mockK8s.CurrentSecretData[pendingKey])
+	assert.Equal(t, "0", mockK8s.CurrentSecretData[pendingNodes])
+	require.NoError(t, mockAWS.runComplianceCheck(ctx))
+	assert.Equal(t, token, mockK8s.CurrentSecretData[tokenKey], "the checkout should be kept rather than recovered")
+	assert.Len(t, mockAWSClient.CheckedOutEntitlements, 1)
+	assert.NotContains(t, events.String(), `"action":"`+audit.ActionRecover+`"`, "no recovery is expected after a successful checkout")
+}
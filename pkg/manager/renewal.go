@@ -0,0 +1,83 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/licensemanager/types"
+	"github.com/rancher/csp-adapter/pkg/audit"
+	"github.com/rancher/csp-adapter/pkg/metrics"
+	"github.com/sirupsen/logrus"
+)
+
+// switchToSuccessor moves the checkout in info to license if it was checked out from another license. When a grant is
+// renewed, its successor is received under a new arn while the grant it replaces is still valid, and the aws client
+// only selects the successor once the replaced grant is expiring or can't be checked out anymore (see
+// aws.selectValidLicense), so a different license means the checkout has to move. The same number of licenses is
+// checked out on the successor before the token of the replaced grant is checked in, so that rancher is never left
+// without licenses. If the checkout fails, the replaced grant is kept and the switchover is retried by the next check.
+// While paused no switchover is made, since it checks licenses out and in. Switchovers are logged and audited. Returns
+// the info which should be used for the rest of the compliance check. Must be called while holding the checkLock
+func (m *AWS) switchToSuccessor(ctx context.Context, license types.GrantedLicense, info *licenseCheckoutInfo, paused bool) *licenseCheckoutInfo {
+	successorArn := awssdk.ToString(license.LicenseArn)
+	if info.ConsumptionToken == "" || info.LicenseArn == successorArn {
+		return info
+	}
+	if info.LicenseArn == "" {
+		// info saved by older versions doesn't record its license, it's assumed to be the current one
+		info.LicenseArn = successorArn
+		return info
+	}
+	if paused {
+		logrus.Warnf("[manager] checkout adjustments are paused, keeping the checkout of %d license(s) on license %s instead of moving it to %s",
+			info.EntitledLicenses, info.LicenseArn, successorArn)
+		return info
+	}
+	logrus.Infof("[manager] license %s was renewed as %s, moving the checkout of %d license(s) to it",
+		info.LicenseArn, successorArn, info.EntitledLicenses)
+	event := audit.NewEvent(m.aws.AccountNumber(), audit.ActionSwitchover, nil)
+	event.LicenseArn = successorArn
+	event.PreviousLicenseArn = info.LicenseArn
+	amount := info.EntitledLicenses
+	// not getEntitlementUsage, the licenses held on the replaced grant aren't held on the successor
+	usage, err := m.aws.GetEntitlementUsage(ctx, license)
+	if err == nil && usage.Available() < amount {
+		logrus.Warnf("[manager] only %d of %d license(s) are available on the renewed license", usage.Available(), amount)
+		amount = usage.Available()
+	}
+	if amount <= 0 {
+		err = fmt.Errorf("no entitlements are available on license %s", successorArn)
+		logrus.Warnf("[manager] unable to move checkout to the renewed license, keeping the replaced one: %v", err)
+		event.Outcome = audit.OutcomeFailure
+		event.Error = err.Error()
+		m.emitAudit(ctx, event)
+		return info
+	}
+	event.Entitlements = amount
+	resp, err := m.checkout(ctx, license, amount, info)
+	if err != nil {
+		// the failed checkout stays pending, in case it did succeed
+		logrus.Warnf("[manager] unable to move checkout to the renewed license, keeping the replaced one: %v", err)
+		event.Outcome = audit.OutcomeFailure
+		event.Error = err.Error()
+		m.emitAudit(ctx, event)
+		return info
+	}
+	if err := m.checkIn(ctx, info.ConsumptionToken, info.Expiry, info); err != nil {
+		// no longer extended, so it expires within the hour
+		logrus.Warnf("[manager] unable to check in the token of the replaced license, retrying until it expires: %v", err)
+	}
+	token := awssdk.ToString(resp.LicenseConsumptionToken)
+	event.TokenID = audit.TokenID(token)
+	m.emitAudit(ctx, event)
+	metrics.LicenseSwitchovers.Inc()
+	return &licenseCheckoutInfo{
+		ConsumptionToken: token,
+		EntitledLicenses: amount,
+		Expiry:           parseExpirationTimestamp(awssdk.ToString(resp.Expiration)),
+		ClusterUID:       info.ClusterUID,
+		LicenseArn:       successorArn,
+		PendingCheckIns:  info.PendingCheckIns,
+	}
+}
@@ -0,0 +1,83 @@
+package manager
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/rancher/csp-adapter/pkg/audit"
+	"github.com/rancher/csp-adapter/pkg/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSwitchToSuccessor(t *testing.T) {
+	tests := []struct {
+		name            string
+		maxEntitlements int
+		// switched is whether the checkout moves to the successor
+		switched bool
+		// paused pauses checkout adjustments until the successor is received, resuming after one check
+		paused bool
+	}{
+		{name: "checkout moves to renewed license", maxEntitlements: 5, switched: true},
+		{name: "replaced license kept without room on the renewed license", maxEntitlements: 2},
+		{name: "checkout moves once resumed", maxEntitlements: 5, switched: true, paused: true},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			mockAWSClient := mocks.NewMockAWSClient(test.maxEntitlements)
+			mockK8s := mocks.NewMockK8sClient(nil)
+			var events bytes.Buffer
+			mockAWS := NewAWS(mockAWSClient, mockK8s, mocks.NewMockScraper(40), Options{Audit: audit.NewWriterSink(&events)})
+			ctx := context.Background()
+			require.NoError(t, mockAWS.runComplianceCheck(ctx))
+			replacedArn := *mockAWSClient.License.LicenseArn
+			replacedToken := mockK8s.CurrentSecretData[tokenKey]
+			assert.Equal(t, replacedArn, mockK8s.CurrentSecretData[licenseKey])
+
+			successorArn := replacedArn + "-renewed"
+			mockAWSClient.License.LicenseArn = &successorArn
+			if test.paused {
+				mockAWS.Pause("admin", "INC-123")
+				require.NoError(t, mockAWS.runComplianceCheck(ctx))
+				assert.NotContains(t, events.String(), audit.ActionSwitchover, "no switchover is made while paused")
+				assert.Equal(t, replacedToken, mockK8s.CurrentSecretData[tokenKey])
+				assert.Equal(t, replacedArn, mockK8s.CurrentSecretData[licenseKey])
+				mockAWS.Resume("admin")
+			}
+			require.NoError(t, mockAWS.runComplianceCheck(ctx))
+
+			var switchovers []audit.Event
+			for _, line := range strings.Split(strings.TrimSpace(events.String()), "\n") {
+				var event audit.Event
+				require.NoError(t, json.Unmarshal([]byte(line), &event))
+				if event.Action == audit.ActionSwitchover {
+					switchovers = append(switchovers, event)
+				}
+			}
+			require.Len(t, switchovers, 1)
+			assert.Equal(t, successorArn, switchovers[0].LicenseArn)
+			assert.Equal(t, replacedArn, switchovers[0].PreviousLicenseArn)
+			if !test.switched {
+				assert.Equal(t, audit.OutcomeFailure, switchovers[0].Outcome)
+				assert.Equal(t, replacedToken, mockK8s.CurrentSecretData[tokenKey])
+				assert.Equal(t, replacedArn, mockK8s.CurrentSecretData[licenseKey])
+				return
+			}
+			assert.Equal(t, audit.OutcomeSuccess, switchovers[0].Outcome)
+			assert.Equal(t, 2, switchovers[0].Entitlements)
+			token := mockK8s.CurrentSecretData[tokenKey]
+			assert.Equal(t, audit.TokenID(token), switchovers[0].TokenID)
+			assert.Equal(t, map[string]int{token: 2}, mockAWSClient.CheckedOutEntitlements, "the replaced token should be checked in")
+			assert.Equal(t, successorArn, mockK8s.CurrentSecretData[licenseKey])
+
+			// the switchover is only made once
+			require.NoError(t, mockAWS.runComplianceCheck(ctx))
+			assert.Equal(t, token, mockK8s.CurrentSecretData[tokenKey])
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package manager
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/mocks"
+	"github.com/rancher/csp-adapter/pkg/reports"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// bucketSink keeps the latest report written to it
+type bucketSink struct {
+	lock   sync.Mutex
+	latest []byte
+}
+
+func (s *bucketSink) Name() string {
+	return "s3"
+}
+
+func (s *bucketSink) Write(ctx context.Context, report []byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.latest = report
+	return nil
+}
+
+func (s *bucketSink) report() []byte {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.latest
+}
+
+func TestReportSinks(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sink := &bucketSink{}
+	dispatcher := reports.NewDispatcher(reports.DefaultOptions, sink)
+	dispatcher.Start(ctx)
+	mockK8s := mocks.NewMockK8sClient(nil)
+	manager := NewAWS(mocks.NewMockAWSClient(5), mockK8s, mocks.NewMockScraper(20), Options{Reports: dispatcher})
+	require.NoError(t, manager.runComplianceCheck(ctx))
+
+	require.Eventually(t, func() bool { return sink.report() != nil }, time.Second, time.Millisecond)
+	assert.Equal(t, mockK8s.CurrentSupportConfig, sink.report(), "sinks receive the report written to the configmap")
+	statuses := manager.Status().Reports
+	require.Len(t, statuses, 2)
+	assert.Equal(t, reports.ConfigMapSink, statuses[0].Name)
+	assert.NotNil(t, statuses[0].LastSuccess)
+}
@@ -0,0 +1,52 @@
+package manager
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+)
+
+// reconcileRestoredState detects checkout info which was created by a different cluster - which happens when rancher is
+// restored from a backup into a new cluster - and discards it, so that tokens belonging to the old install aren't
+// renewed. Returns the info which should be used for the rest of the compliance check
+func (m *AWS) reconcileRestoredState(ctx context.Context, info *licenseCheckoutInfo) *licenseCheckoutInfo {
+	clusterUID, err := m.getClusterUID()
+	if err != nil {
+		// without the current identity we can't tell if this is a restore, keep the state rather than discard a valid token
+		logrus.Warnf("[manager] unable to determine cluster identity, skipping restore detection: %v", err)
+		return info
+	}
+	if info.ClusterUID == "" || info.ClusterUID == clusterUID {
+		info.ClusterUID = clusterUID
+		return info
+	}
+	logrus.Warnf("[manager] checkout info was created by cluster %s but this is cluster %s, rancher was likely restored from a backup. Discarding restored state",
+		info.ClusterUID, clusterUID)
+	restored := &licenseCheckoutInfo{
+		ClusterUID: clusterUID,
+	}
+	if info.ConsumptionToken != "" {
+		// the token belongs to the old install, return its entitlements if it's still active
+		if err := m.checkIn(ctx, info.ConsumptionToken, info.Expiry, restored); err != nil {
+			logrus.Warnf("[manager] unable to check in token from restored state, retrying until it expires: %v", err)
+		} else {
+			logrus.Infof("[manager] checked in token from restored state")
+		}
+	}
+	return restored
+}
+
+// getClusterUID returns the identity of the cluster the adapter is running in, caching it after the first lookup
+func (m *AWS) getClusterUID() (string, error) {
+	m.statusLock.Lock()
+	defer m.statusLock.Unlock()
+	if m.clusterUID != "" {
+		return m.clusterUID, nil
+	}
+	uid, err := m.k8s.GetClusterUID()
+	if err != nil {
+		return "", err
+	}
+	m.clusterUID = uid
+	return uid, nil
+}
@@ -0,0 +1,38 @@
+package manager
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rancher/csp-adapter/pkg/mocks"
+	"github.com/rancher/csp-adapter/pkg/state"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestImportedState tests that a checkout migrated to another cluster with the state commands is adopted by the
+// target cluster, rather than discarded like state restored from a backup
+func TestImportedState(t *testing.T) {
+	mockAWSClient := mocks.NewMockAWSClient(10)
+	source := mocks.NewMockK8sClient(nil)
+	source.ClusterUID = "source-cluster"
+	sourceAWS := NewAWS(mockAWSClient, source, mocks.NewMockScraper(20), Options{InstanceID: "source-instance"})
+	require.NoError(t, sourceAWS.runComplianceCheck(context.Background()))
+	token := source.CurrentSecretData[tokenKey]
+	require.NotEmpty(t, token)
+	require.Equal(t, "source-cluster", source.CurrentSecretData[clusterKey])
+
+	exported, err := state.Export(source)
+	require.NoError(t, err)
+	target := mocks.NewMockK8sClient(nil)
+	target.ClusterUID = "target-cluster"
+	require.NoError(t, state.Import(target, exported, false))
+
+	targetAWS := NewAWS(mockAWSClient, target, mocks.NewMockScraper(20), Options{InstanceID: "target-instance"})
+	require.NoError(t, targetAWS.runComplianceCheck(context.Background()))
+	assert.Equal(t, token, target.CurrentSecretData[tokenKey], "the imported checkout should be kept")
+	assert.Contains(t, mockAWSClient.CheckedOutEntitlements, token)
+	assert.Len(t, mockAWSClient.CheckedOutEntitlements, 1, "nothing should be checked out again")
+	assert.Equal(t, "target-cluster", target.CurrentSecretData[clusterKey])
+	assert.Equal(t, "target-instance", target.CurrentSecretData[instanceKey])
+}
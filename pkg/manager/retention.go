@@ -0,0 +1,19 @@
+package manager
+
+// DefaultMaxSamples is the number of observations each in-memory collection of the manager keeps by default. It holds
+// the anomaly window at a check every few seconds and the forecast window at its hourly resolution
+const DefaultMaxSamples = 1000
+
+// collections of the manager reported by metrics.RetainedItems
+const (
+	retainedUsageSamples    = "usage_samples"
+	retainedCheckouts       = "checkouts"
+	retainedForecastSamples = "forecast_samples"
+)
+
+func (m *AWS) maxSamples() int {
+	if m.opts.MaxSamples > 0 {
+		return m.opts.MaxSamples
+	}
+	return DefaultMaxSamples
+}
@@ -0,0 +1,46 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/clients/aws"
+	"github.com/sirupsen/logrus"
+)
+
+// releaseUnusableLicense checks in the licenses held by the adapter when validation shows that the license can no
+// longer be used, because its grant was disabled or revoked or its status changed. Renewing or checking out such a
+// license will keep failing until the seller restores it, so the adapter stops holding it instead. Must be called while
+// holding the checkLock
+func (m *AWS) releaseUnusableLicense(ctx context.Context, validationErr error) {
+	var grantErr *aws.GrantStatusError
+	var statusErr *aws.LicenseStatusError
+	if !errors.As(validationErr, &grantErr) && !errors.As(validationErr, &statusErr) {
+		return
+	}
+	if !m.licenseUnusable {
+		logrus.Warnf("[manager] rancher license can no longer be used, releasing held licenses: %v", validationErr)
+		m.licenseUnusable = true
+	}
+	if m.adjustmentsHeld(time.Now()) {
+		// the checkout expires on its own unless the adapter is resumed before
+		logrus.Warnf("[manager] checkout adjustments are paused or frozen for AWS maintenance, not releasing held licenses")
+		return
+	}
+	info, err := m.getLicenseCheckoutInfo()
+	if err != nil || info.ConsumptionToken == "" {
+		// nothing held
+		return
+	}
+	if err := m.checkIn(ctx, info.ConsumptionToken, info.Expiry, info); err != nil {
+		// the checkout expires on its own, it won't be extended anymore
+		logrus.Warnf("[manager] unable to check in licenses of unusable license, retrying until they expire at %s: %v", info.Expiry.Format(time.RFC3339), err)
+	}
+	info.ConsumptionToken = ""
+	info.EntitledLicenses = 0
+	info.Expiry = time.Time{}
+	if err := m.saveCheckoutInfo(info); err != nil {
+		logrus.Warnf("unable to save current checkout info, next run may fail with checkout/checkin")
+	}
+}
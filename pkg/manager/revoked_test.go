@@ -0,0 +1,43 @@
+package manager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/licensemanager/types"
+	"github.com/rancher/csp-adapter/pkg/clients/aws"
+	"github.com/rancher/csp-adapter/pkg/mocks"
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGrantDisabled(t *testing.T) {
+	mockAWSClient := mocks.NewMockAWSClient(5)
+	mockK8s := mocks.NewMockK8sClient(nil)
+	mockAWS := NewAWS(mockAWSClient, mockK8s, mocks.NewMockScraper(40), Options{})
+	ctx := context.Background()
+	assert.NoError(t, mockAWS.runComplianceCheck(ctx))
+	assert.Len(t, mockAWSClient.CheckedOutEntitlements, 1)
+
+	mockAWSClient.License.ReceivedMetadata = &types.ReceivedMetadata{ReceivedStatus: types.ReceivedStatusDisabled}
+	for i := 0; i < 2; i++ {
+		err := mockAWS.runComplianceCheck(ctx)
+		assert.Error(t, err)
+		errs := make(chan error, 2)
+		mockAWS.reportCheckError(ctx, err, errs)
+		status := mockAWS.Status()
+		assert.Equal(t, sdk.ComplianceStatusNonCompliant, status.Compliance.Status)
+		assert.Equal(t, sdk.ReasonGrantDisabled, status.Compliance.Reason)
+		assert.Empty(t, mockAWSClient.CheckedOutEntitlements, "licenses of a disabled grant should be checked in")
+		assert.Empty(t, mockK8s.CurrentSecretData[tokenKey], "the checked in token shouldn't be renewed")
+	}
+
+	mockAWSClient.License.ReceivedMetadata.ReceivedStatus = types.ReceivedStatusActive
+	assert.NoError(t, mockAWS.runComplianceCheck(ctx))
+	assert.Equal(t, sdk.ReasonLicensed, mockAWS.Status().Compliance.Reason)
+	assert.False(t, mockAWS.licenseUnusable)
+
+	reason, _ := describeError(&aws.GrantStatusError{Status: types.ReceivedStatusDeleted}, time.UTC)
+	assert.Equal(t, sdk.ReasonGrantRevoked, reason)
+}
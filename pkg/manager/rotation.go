@@ -0,0 +1,128 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/licensemanager/types"
+	"github.com/rancher/csp-adapter/pkg/metrics"
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/sirupsen/logrus"
+)
+
+// tokenRotationMargin is the number of extensions left in the budget when the token is rotated, so that renewals
+// between compliance checks can't use up the budget before the next check rotates it
+const tokenRotationMargin = 2
+
+// extensionBudgetLow returns whether the token in info is about to reach Options.MaxTokenExtensions, after which
+// License Manager won't extend it anymore and a fresh checkout is required
+func (m *AWS) extensionBudgetLow(info *licenseCheckoutInfo) bool {
+	return m.opts.MaxTokenExtensions > 0 && info.ConsumptionToken != "" &&
+		info.Extensions >= m.opts.MaxTokenExtensions-tokenRotationMargin
+}
+
+// tokenLimitApproaching returns whether the token in info is within Options.TokenLimitWarning extensions of
+// Options.MaxTokenExtensions, so that its rotation is announced ahead of time
+func (m *AWS) tokenLimitApproaching(info *licenseCheckoutInfo) bool {
+	return m.opts.MaxTokenExtensions > 0 && m.opts.TokenLimitWarning > 0 && info.ConsumptionToken != "" &&
+		info.Extensions >= m.opts.MaxTokenExtensions-m.opts.TokenLimitWarning
+}
+
+// rotationDue returns whether a full compliance check rotates the token in info. With a schedule full checks only run
+// in the change window, so a token approaching its limit is rotated in the window instead of once its budget runs
+// low, which may well be outside of it
+func (m *AWS) rotationDue(info *licenseCheckoutInfo) bool {
+	return m.extensionBudgetLow(info) || (m.opts.Schedule != nil && m.tokenLimitApproaching(info))
+}
+
+// reportTokenLimit sets the TokenLimitApproaching condition for the token in info, warning once per token when it
+// approaches the extension limit. Must be called while holding the checkLock
+func (m *AWS) reportTokenLimit(info *licenseCheckoutInfo, now time.Time) {
+	if m.opts.MaxTokenExtensions <= 0 || m.opts.TokenLimitWarning <= 0 || info.ConsumptionToken == "" {
+		return
+	}
+	condition := sdk.Condition{
+		Type:    sdk.ConditionTokenLimitApproaching,
+		Status:  "False",
+		Reason:  "WithinLimit",
+		Message: fmt.Sprintf("consumption token was extended %d of %d times", info.Extensions, m.opts.MaxTokenExtensions),
+	}
+	approaching := m.tokenLimitApproaching(info)
+	if approaching {
+		condition.Status = "True"
+		condition.Reason = "RotationScheduled"
+		condition.Message = fmt.Sprintf("%s, it will be rotated with a fresh checkout %s", condition.Message, m.describeRotation(info, now))
+		if m.tokenLimitWarned != info.ConsumptionToken {
+			logrus.Warnf("[manager] %s", condition.Message)
+			metrics.TokenLimitWarnings.Inc()
+		}
+		m.tokenLimitWarned = info.ConsumptionToken
+	}
+	m.statusLock.Lock()
+	m.status.Conditions = setStatusCondition(m.status.Conditions, condition, now)
+	m.statusLock.Unlock()
+}
+
+// describeRotation describes when the token in info is rotated: in the next change window if there is one before the
+// extension budget runs low, otherwise once it does. Tokens are extended about once an hour
+func (m *AWS) describeRotation(info *licenseCheckoutInfo, now time.Time) string {
+	remaining := m.opts.MaxTokenExtensions - tokenRotationMargin - info.Extensions
+	if remaining <= 0 {
+		return "by the next compliance check"
+	}
+	budgetLowAt := info.Expiry.Add(time.Duration(remaining-1) * time.Hour)
+	if m.opts.Schedule != nil {
+		if next := m.opts.Schedule.Next(now); !next.IsZero() && next.Before(budgetLowAt) {
+			return fmt.Sprintf("in the next change window at %s", formatTime(next, m.location()))
+		}
+		return fmt.Sprintf("at about %s, outside of the change window as there is none before", formatTime(budgetLowAt, m.location()))
+	}
+	return fmt.Sprintf("at about %s", formatTime(budgetLowAt, m.location()))
+}
+
+// rotateCheckout replaces the checkout in info with a fresh checkout of the same number of licenses. The new checkout
+// is made before the old one is checked in, so that rancher is never left without licenses, unless the license has no
+// room for both at once. Returns the checkout info after the rotation, which holds no licenses if the rotation failed
+// after checking in the old token. Must be called while holding the checkLock
+func (m *AWS) rotateCheckout(ctx context.Context, license types.GrantedLicense, info *licenseCheckoutInfo) (*licenseCheckoutInfo, error) {
+	logrus.Infof("[manager] consumption token was extended %d times, rotating it before reaching the limit of %d",
+		info.Extensions, m.opts.MaxTokenExtensions)
+	usage, err := m.getEntitlementUsage(ctx, license, info.EntitledLicenses)
+	overlap := err == nil && usage.Available() >= info.EntitledLicenses
+	if !overlap {
+		logrus.Warnf("[manager] not enough entitlements to hold both tokens during rotation, checking in the current token first")
+		if _, err := m.aws.CheckInRancherLicense(ctx, info.ConsumptionToken); err != nil {
+			return info, fmt.Errorf("unable to check in token for rotation: %w", err)
+		}
+	}
+	resp, err := m.checkout(ctx, license, info.EntitledLicenses, info)
+	if err != nil {
+		if !overlap {
+			// the failed checkout stays pending, in case it did succeed
+			return &licenseCheckoutInfo{
+				ClusterUID:      info.ClusterUID,
+				PendingCheckout: info.PendingCheckout,
+				PendingLicenses: info.PendingLicenses,
+				PendingCheckIns: info.PendingCheckIns,
+			}, fmt.Errorf("unable to checkout rotated token: %w", err)
+		}
+		return info, fmt.Errorf("unable to checkout rotated token: %w", err)
+	}
+	if overlap {
+		if err := m.checkIn(ctx, info.ConsumptionToken, info.Expiry, info); err != nil {
+			// no longer extended, so it expires within the hour
+			logrus.Warnf("[manager] unable to check in rotated token, retrying until it expires: %v", err)
+		}
+	}
+	metrics.TokenRotations.Inc()
+	return &licenseCheckoutInfo{
+		ConsumptionToken: *resp.LicenseConsumptionToken,
+		EntitledLicenses: info.EntitledLicenses,
+		Expiry:           parseExpirationTimestamp(*resp.Expiration),
+		ClusterUID:       info.ClusterUID,
+		LicenseArn:       awssdk.ToString(license.LicenseArn),
+		PendingCheckIns:  info.PendingCheckIns,
+	}, nil
+}
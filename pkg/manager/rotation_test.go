@@ -0,0 +1,124 @@
+package manager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rancher/csp-adapter/pkg/metrics"
+	"github.com/rancher/csp-adapter/pkg/mocks"
+	"github.com/rancher/csp-adapter/pkg/schedule"
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenRotation(t *testing.T) {
+	tests := []struct {
+		name            string
+		maxEntitlements int
+	}{
+		{name: "checkout before checkin", maxEntitlements: 5},
+		{name: "checkin before checkout without room for both", maxEntitlements: 1},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			mockAWSClient := mocks.NewMockAWSClient(test.maxEntitlements)
+			mockK8s := mocks.NewMockK8sClient(nil)
+			mockAWS := NewAWS(mockAWSClient, mockK8s, mocks.NewMockScraper(20), Options{MaxTokenExtensions: 5})
+			ctx := context.Background()
+			require.NoError(t, mockAWS.runComplianceCheck(ctx))
+			firstToken := mockK8s.CurrentSecretData[tokenKey]
+
+			// expiring soon, so the check extends the token
+			mockK8s.CurrentSecretData[expiryKey] = time.Now().Add(time.Minute).Format(time.RFC3339)
+			require.NoError(t, mockAWS.runComplianceCheck(ctx))
+			assert.Equal(t, "1", mockK8s.CurrentSecretData[extensionKey])
+			assert.Equal(t, 1, mockAWS.Status().Usage.TokenExtensions)
+			assert.Equal(t, firstToken, mockK8s.CurrentSecretData[tokenKey])
+
+			mockK8s.CurrentSecretData[extensionKey] = "3"
+			require.NoError(t, mockAWS.runComplianceCheck(ctx))
+			rotatedToken := mockK8s.CurrentSecretData[tokenKey]
+			assert.NotEqual(t, firstToken, rotatedToken, "the token should be rotated before reaching the limit")
+			assert.Equal(t, "0", mockK8s.CurrentSecretData[extensionKey])
+			assert.Equal(t, map[string]int{rotatedToken: 1}, mockAWSClient.CheckedOutEntitlements, "the old token should be checked in")
+			assert.Equal(t, 1, mockAWS.Status().Usage.CheckedOutLicenses)
+		})
+	}
+}
+
+func TestTokenRotationDisabled(t *testing.T) {
+	m := &AWS{}
+	assert.False(t, m.extensionBudgetLow(&licenseCheckoutInfo{ConsumptionToken: "token", Extensions: 1000}))
+}
+
+func TestTokenLimitWarning(t *testing.T) {
+	// matches every minute, so that every check runs in the change window
+	always, err := schedule.Parse("* * * * *", time.UTC)
+	require.NoError(t, err)
+	tests := []struct {
+		name          string
+		schedule      *schedule.Schedule
+		expectRotated bool
+	}{
+		{name: "rotated once the budget runs low"},
+		{name: "rotated in the change window", schedule: always, expectRotated: true},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			mockAWSClient := mocks.NewMockAWSClient(5)
+			mockK8s := mocks.NewMockK8sClient(nil)
+			mockAWS := NewAWS(mockAWSClient, mockK8s, mocks.NewMockScraper(20), Options{
+				MaxTokenExtensions: 24,
+				TokenLimitWarning:  6,
+				Schedule:           test.schedule,
+			})
+			ctx := context.Background()
+			require.NoError(t, mockAWS.runComplianceCheck(ctx))
+			firstToken := mockK8s.CurrentSecretData[tokenKey]
+			conditions := mockAWS.Status().Conditions
+			require.Len(t, conditions, 1)
+			assert.Equal(t, sdk.ConditionTokenLimitApproaching, conditions[0].Type)
+			assert.Equal(t, "False", conditions[0].Status)
+
+			before := testutil.ToFloat64(metrics.TokenLimitWarnings)
+			mockK8s.CurrentSecretData[extensionKey] = "18"
+			require.NoError(t, mockAWS.runComplianceCheck(ctx))
+			require.NoError(t, mockAWS.runComplianceCheck(ctx))
+			conditions = mockAWS.Status().Conditions
+			require.Len(t, conditions, 1)
+			condition := conditions[0]
+			if test.expectRotated {
+				assert.NotEqual(t, firstToken, mockK8s.CurrentSecretData[tokenKey])
+				assert.Equal(t, "False", condition.Status, "the rotated token is within the limit")
+				assert.Equal(t, 0.0, testutil.ToFloat64(metrics.TokenLimitWarnings)-before)
+				return
+			}
+			assert.Equal(t, firstToken, mockK8s.CurrentSecretData[tokenKey])
+			assert.Equal(t, "True", condition.Status)
+			assert.Equal(t, "RotationScheduled", condition.Reason)
+			assert.Equal(t, 1.0, testutil.ToFloat64(metrics.TokenLimitWarnings)-before, "a token is only warned about once")
+		})
+	}
+}
+
+func TestDescribeRotation(t *testing.T) {
+	expiry := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	info := &licenseCheckoutInfo{ConsumptionToken: "token", Expiry: expiry, Extensions: 18}
+	m := &AWS{opts: Options{MaxTokenExtensions: 24, TokenLimitWarning: 6}}
+	// 4 more extensions until the budget runs low, the last of them at 15:00
+	assert.Equal(t, "at about 2022-06-01T15:00:00Z", m.describeRotation(info, expiry))
+
+	nightly, err := schedule.Parse("0 2 * * *", time.UTC)
+	require.NoError(t, err)
+	m.opts.Schedule = nightly
+	assert.Contains(t, m.describeRotation(info, expiry), "outside of the change window")
+	info.Extensions = 6
+	assert.Equal(t, "in the next change window at 2022-06-02T02:00:00Z", m.describeRotation(info, expiry))
+	info.Extensions = 22
+	assert.Equal(t, "by the next compliance check", m.describeRotation(info, expiry))
+}
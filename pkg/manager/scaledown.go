@@ -0,0 +1,42 @@
+package manager
+
+import (
+	"context"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/metrics"
+	"github.com/sirupsen/logrus"
+)
+
+// confirmScaleDown delays checking in licenses after a scale-down by Options.ScaleDownConfirmation, so that a transient
+// counting error (i.e. an api blip showing zero nodes) doesn't release licenses which are still required. The held
+// licenses are kept while the scale-down is confirmed, once the confirmation period elapsed nodes are counted again and
+// the recount decides. Returns the node counts and required licenses the check proceeds with, and whether the held
+// licenses are kept for now. Must be called while holding the checkLock
+func (m *AWS) confirmScaleDown(ctx context.Context, info *licenseCheckoutInfo, counts *metrics.NodeCounts, required int, now time.Time) (*metrics.NodeCounts, int, bool) {
+	if m.opts.ScaleDownConfirmation <= 0 || info.ConsumptionToken == "" || required >= info.EntitledLicenses {
+		m.scaleDownSince = time.Time{}
+		return counts, required, false
+	}
+	if m.scaleDownSince.IsZero() {
+		m.scaleDownSince = now
+		logrus.Infof("[manager] %d license(s) are required for %d nodes but %d are held, confirming the scale-down until %s",
+			required, counts.Total, info.EntitledLicenses, now.Add(m.opts.ScaleDownConfirmation).Format(time.RFC3339))
+	}
+	if now.Before(m.scaleDownSince.Add(m.opts.ScaleDownConfirmation)) {
+		return counts, required, true
+	}
+	recount, err := m.scraper.ScrapeAndParse(ctx)
+	if err != nil {
+		logrus.Warnf("[manager] unable to count nodes again to confirm the scale-down, keeping held licenses: %v", err)
+		return counts, required, true
+	}
+	m.scaleDownSince = time.Time{}
+	recounted := m.requiredLicenses(m.classifyEnvironments(recount).licensed)
+	if recounted >= info.EntitledLicenses {
+		logrus.Warnf("[manager] scale-down to %d nodes wasn't confirmed, %d nodes were counted again", counts.Total, recount.Total)
+	} else {
+		logrus.Infof("[manager] scale-down confirmed, %d nodes were counted again", recount.Total)
+	}
+	return recount, recounted, false
+}
@@ -0,0 +1,70 @@
+package manager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/metrics"
+	"github.com/rancher/csp-adapter/pkg/mocks"
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sequenceScraper counts the given nodes in turn, repeating the last count once they are used up
+type sequenceScraper struct {
+	nodes []int
+}
+
+func (s *sequenceScraper) ScrapeAndParse(context.Context) (*metrics.NodeCounts, error) {
+	nodes := s.nodes[0]
+	if len(s.nodes) > 1 {
+		s.nodes = s.nodes[1:]
+	}
+	return &metrics.NodeCounts{Total: nodes}, nil
+}
+
+func TestConfirmScaleDown(t *testing.T) {
+	tests := []struct {
+		name string
+		// recount is the number of nodes counted when confirming the scale-down to 0 nodes
+		recount       int
+		expectedNodes string
+	}{
+		{
+			name:          "transient",
+			recount:       60,
+			expectedNodes: "3",
+		},
+		{
+			name:          "confirmed",
+			recount:       20,
+			expectedNodes: "1",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			mockAWSClient := mocks.NewMockAWSClient(5)
+			mockK8s := mocks.NewMockK8sClient(nil)
+			scraper := &sequenceScraper{nodes: []int{60, 0, 0, test.recount}}
+			mockAWS := NewAWS(mockAWSClient, mockK8s, scraper, Options{ScaleDownConfirmation: 5 * time.Minute})
+			require.NoError(t, mockAWS.runComplianceCheck(ctx))
+			token := mockK8s.CurrentSecretData[tokenKey]
+
+			// the licenses are kept while the scale-down is confirmed
+			require.NoError(t, mockAWS.runComplianceCheck(ctx))
+			assert.Equal(t, token, mockK8s.CurrentSecretData[tokenKey])
+			assert.Equal(t, "3", mockK8s.CurrentSecretData[nodeKey])
+			assert.Equal(t, sdk.OptionalTime(mockAWS.scaleDownSince.Add(5*time.Minute)), mockAWS.Status().Usage.ExcessReleaseAt)
+
+			mockAWS.scaleDownSince = mockAWS.scaleDownSince.Add(-10 * time.Minute)
+			require.NoError(t, mockAWS.runComplianceCheck(ctx))
+			assert.Equal(t, test.expectedNodes, mockK8s.CurrentSecretData[nodeKey])
+			assert.Equal(t, test.recount, mockAWS.Status().Usage.Nodes)
+			assert.True(t, mockAWS.scaleDownSince.IsZero())
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/sirupsen/logrus"
+)
+
+// fullCheckDue returns whether the tick at now should run a full compliance check. Without a schedule every tick does,
+// otherwise only ticks at or after the first scheduled time following the last full check
+func (m *AWS) fullCheckDue(lastFull, now time.Time) bool {
+	if m.opts.Schedule == nil || lastFull.IsZero() {
+		return true
+	}
+	next := m.opts.Schedule.Next(lastFull)
+	return !next.IsZero() && !now.Before(next)
+}
+
+// renewCurrentCheckout renews the current checkout between scheduled compliance checks, without changing the number of
+// licenses checked out. Returns false if there is no checkout to renew
+func (m *AWS) renewCurrentCheckout(ctx context.Context) (bool, error) {
+	m.checkLock.Lock()
+	defer m.checkLock.Unlock()
+	return m.renewCheckout(ctx)
+}
+
+// renewCheckout extends the current checkout if it expires soon. Returns false if there is no checkout to renew. Must
+// be called while holding the checkLock
+func (m *AWS) renewCheckout(ctx context.Context) (bool, error) {
+	info, err := m.getLicenseCheckoutInfo()
+	if err != nil || info.ConsumptionToken == "" {
+		return false, nil
+	}
+	if backoff, err := m.checkInstance(info, time.Now()); backoff {
+		return true, err
+	}
+	if !m.adjustmentsHeld(time.Now()) {
+		// saved with the renewal below, or by the next full check
+		m.retryCheckIns(ctx, info, time.Now())
+	}
+	m.renewCheckoutRequests(ctx, time.Now())
+	if m.extensionBudgetLow(info) {
+		license, err := m.aws.GetRancherLicense(ctx)
+		if err != nil {
+			return true, fmt.Errorf("unable to get rancher license to rotate token: %w", err)
+		}
+		info, err = m.rotateCheckout(ctx, *license, info)
+		if saveErr := m.saveCheckoutInfo(info); saveErr != nil {
+			logrus.Warnf("unable to save current checkout info, next run may fail with checkout/checkin")
+		}
+		m.publishRenewal(info)
+		m.reportTokenLimit(info, time.Now())
+		return true, err
+	}
+	if m.opts.OverAllocationMode == sdk.OverAllocationExpire && !m.overAllocatedSince.IsZero() && time.Until(info.Expiry) <= renewalMargin {
+		// excess licenses are only kept until the token expires, a full check replaces it with the required licenses
+		return false, nil
+	}
+	extended, err := m.extendCheckout(ctx, m.extensionMargin(time.Now()), info)
+	if err != nil {
+		return true, fmt.Errorf("unable to extend license checkout: %w", err)
+	}
+	if err := m.saveCheckoutInfo(extended); err != nil {
+		logrus.Warnf("unable to save current checkout info, next run may fail with checkout/checkin")
+	}
+	if !extended.Expiry.Equal(info.Expiry) {
+		m.publishRenewal(extended)
+		m.reportTokenLimit(extended, time.Now())
+	}
+	return true, nil
+}
@@ -0,0 +1,79 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/mocks"
+	"github.com/rancher/csp-adapter/pkg/schedule"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFullCheckDue(t *testing.T) {
+	sched, err := schedule.Parse("0 9 * * *", time.UTC)
+	require.NoError(t, err)
+	lastFull := time.Date(2022, time.June, 1, 9, 0, 0, 0, time.UTC)
+
+	unscheduled := &AWS{}
+	assert.True(t, unscheduled.fullCheckDue(lastFull, lastFull.Add(managerInterval)), "without a schedule every tick should be a full check")
+
+	m := &AWS{opts: Options{Schedule: sched}}
+	assert.True(t, m.fullCheckDue(time.Time{}, lastFull), "the first check should be a full check")
+	assert.False(t, m.fullCheckDue(lastFull, lastFull.Add(time.Hour)))
+	assert.True(t, m.fullCheckDue(lastFull, lastFull.Add(24*time.Hour)))
+}
+
+func TestRenewCurrentCheckout(t *testing.T) {
+	mockAWS := NewAWS(mocks.NewMockAWSClient(5), mocks.NewMockK8sClient(nil), mocks.NewMockScraper(20), Options{})
+	ctx := context.Background()
+	renewed, err := mockAWS.renewCurrentCheckout(ctx)
+	assert.NoError(t, err)
+	assert.False(t, renewed, "nothing should be renewed before a checkout")
+
+	require.NoError(t, mockAWS.runComplianceCheck(ctx))
+	before, err := mockAWS.getLicenseCheckoutInfo()
+	require.NoError(t, err)
+	// the node count changing between scheduled checks must not change the checkout
+	mockAWS.scraper = mocks.NewMockScraper(200)
+	renewed, err = mockAWS.renewCurrentCheckout(ctx)
+	assert.NoError(t, err)
+	assert.True(t, renewed)
+	after, err := mockAWS.getLicenseCheckoutInfo()
+	require.NoError(t, err)
+	assert.Equal(t, before.EntitledLicenses, after.EntitledLicenses)
+}
+
+func TestCheckoutCoverage(t *testing.T) {
+	mockK8s := mocks.NewMockK8sClient(nil)
+	mockAWS := NewAWS(mocks.NewMockAWSClient(5), mockK8s, mocks.NewMockScraper(20), Options{})
+	ctx := context.Background()
+	require.NoError(t, mockAWS.runComplianceCheck(ctx))
+	usage := mockAWS.Status().Usage
+	require.NotNil(t, usage.CheckoutExpiry)
+	assert.Equal(t, usage.CheckoutExpiry.Add(-renewalMargin), *usage.CheckoutRenewsAt)
+	assertPublishedCoverage(t, mockK8s, *usage.CheckoutExpiry)
+
+	// expiring soon, so the token is extended between full checks
+	soon := time.Now().Add(time.Minute).Truncate(time.Second)
+	mockK8s.CurrentSecretData[expiryKey] = soon.Format(time.RFC3339)
+	renewed, err := mockAWS.renewCurrentCheckout(ctx)
+	require.NoError(t, err)
+	require.True(t, renewed)
+	usage = mockAWS.Status().Usage
+	assert.True(t, usage.CheckoutExpiry.After(soon), "the status should follow the renewal")
+	assert.Equal(t, 1, usage.TokenExtensions)
+	assertPublishedCoverage(t, mockK8s, *usage.CheckoutExpiry)
+}
+
+func assertPublishedCoverage(t *testing.T, mockK8s *mocks.MockK8sClient, expiry time.Time) {
+	t.Helper()
+	var setting ComplianceInfo
+	require.NoError(t, json.Unmarshal([]byte(mockK8s.ComplianceSetting), &setting))
+	require.NotNil(t, setting.Coverage)
+	assert.True(t, expiry.Equal(setting.Coverage.ExpiresAt))
+	assert.True(t, expiry.Add(-renewalMargin).Equal(setting.Coverage.RenewsAt))
+	assert.Equal(t, StatusInCompliance, setting.Status)
+}
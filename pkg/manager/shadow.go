@@ -0,0 +1,170 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/licensemanager/types"
+	"github.com/rancher/csp-adapter/pkg/metrics"
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/sirupsen/logrus"
+)
+
+// PlanInput is what a compliance check decides what to do with the licenses on
+type PlanInput struct {
+	Nodes    int
+	Required int
+	// Held is the number of licenses held by the adapter before the check
+	Held int
+	// Available is the number of licenses which can be checked out in addition to Held, -1 if it's unknown
+	Available int
+	// KeepExcess is true if licenses held beyond Required are kept, according to Options.OverAllocationMode
+	KeepExcess bool
+	// RotationDue is true if the held consumption token has to be replaced before reaching its extension limit
+	RotationDue bool
+}
+
+// Decision is what a compliance check does with the licenses
+type Decision struct {
+	// CheckIn is the number of held licenses which are checked in
+	CheckIn int
+	// Checkout is the number of licenses which are checked out
+	Checkout int
+}
+
+func (d Decision) String() string {
+	return fmt.Sprintf("check in %d, check out %d", d.CheckIn, d.Checkout)
+}
+
+// Planner decides what a compliance check does with the licenses. The adapter's own decisions are made by
+// StablePlanner, other planners can be run in shadow mode (see Options.Shadow) to compare their decisions with it before
+// they are rolled out
+type Planner interface {
+	Name() string
+	Plan(input PlanInput) Decision
+}
+
+// StablePlanner describes the decisions of the adapter's compliance checks: the held checkout is replaced with a
+// checkout of the required licenses whenever they differ, since the adapter holds a single checkout at a time
+type StablePlanner struct{}
+
+func (StablePlanner) Name() string {
+	return "stable"
+}
+
+func (StablePlanner) Plan(input PlanInput) Decision {
+	if !replacesCheckout(input.Held, input.Required, input.KeepExcess) {
+		if input.RotationDue {
+			return Decision{CheckIn: input.Held, Checkout: input.Held}
+		}
+		return Decision{}
+	}
+	available := input.Available
+	if available >= 0 {
+		// the held licenses are available again once they are checked in
+		available += input.Held
+	}
+	return Decision{CheckIn: input.Held, Checkout: licensesToCheckOut(input.Required, available)}
+}
+
+// replacesCheckout returns whether a compliance check replaces the held checkout of held licenses with a checkout of
+// required licenses. It's shared by the compliance checks and StablePlanner, so that shadow planners are compared with
+// the decisions the checks actually make
+func replacesCheckout(held, required int, keepExcess bool) bool {
+	return held != required && !(keepExcess && held > required)
+}
+
+// licensesToCheckOut returns the number of licenses checked out to hold required licenses when available can be checked
+// out, -1 if the availability is unknown, in which case the required licenses are attempted
+func licensesToCheckOut(required, available int) int {
+	if available < 0 {
+		return required
+	}
+	if checkout := minInt(required, available); checkout > 0 {
+		return checkout
+	}
+	return 0
+}
+
+// DeltaCheckoutPlanner only checks out the missing licenses when more are required, keeping the held checkout rather
+// than replacing it. Licenses can't be checked in partially, so scaling down still replaces the held checkout
+type DeltaCheckoutPlanner struct{}
+
+func (DeltaCheckoutPlanner) Name() string {
+	return "delta-checkout"
+}
+
+func (DeltaCheckoutPlanner) Plan(input PlanInput) Decision {
+	if input.Held == 0 || input.Required <= input.Held {
+		return StablePlanner{}.Plan(input)
+	}
+	missing := input.Required - input.Held
+	if input.Available >= 0 {
+		missing = minInt(missing, input.Available)
+	}
+	return Decision{Checkout: missing}
+}
+
+// Planners are the planners which can be run in shadow mode, by name
+var Planners = map[string]Planner{
+	StablePlanner{}.Name():        StablePlanner{},
+	DeltaCheckoutPlanner{}.Name(): DeltaCheckoutPlanner{},
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// shadowInput returns the input of the shadow planner for the compliance check of license, or nil if no shadow
+// planner is configured. Must be called while holding the checkLock, before the check changes the held licenses
+func (m *AWS) shadowInput(ctx context.Context, license types.GrantedLicense, info *licenseCheckoutInfo, nodes, required int, keepExcess bool) *PlanInput {
+	if m.opts.Shadow == nil {
+		return nil
+	}
+	input := &PlanInput{
+		Nodes:       nodes,
+		Required:    required,
+		Held:        info.EntitledLicenses,
+		Available:   -1,
+		KeepExcess:  keepExcess,
+		RotationDue: m.extensionBudgetLow(info),
+	}
+	if info.ConsumptionToken == "" {
+		input.Held = 0
+	}
+	if usage, err := m.aws.GetEntitlementUsage(ctx, license); err == nil {
+		input.Available = usage.Available()
+	} else {
+		logrus.Debugf("[shadow] unable to get license usage, planning with unknown availability: %v", err)
+	}
+	return input
+}
+
+// compareShadow compares the decision of the shadow planner for input with the decision the compliance check made,
+// logging and counting divergences. The shadow planner's decision is never carried out
+func (m *AWS) compareShadow(input *PlanInput, decision Decision, now time.Time) {
+	if input == nil {
+		return
+	}
+	shadow := m.opts.Shadow.Plan(*input)
+	m.statusLock.Lock()
+	defer m.statusLock.Unlock()
+	if m.status.Shadow == nil {
+		m.status.Shadow = &sdk.ShadowStatus{Planner: m.opts.Shadow.Name()}
+	}
+	m.status.Shadow.Checks++
+	if shadow == decision {
+		return
+	}
+	divergence := fmt.Sprintf("for %d nodes requiring %d license(s) with %d held and %d available the adapter decided to %s, %s would %s",
+		input.Nodes, input.Required, input.Held, input.Available, decision, m.opts.Shadow.Name(), shadow)
+	logrus.Warnf("[shadow] %s", divergence)
+	metrics.ShadowDivergences.WithLabelValues(m.opts.Shadow.Name()).Inc()
+	m.status.Shadow.Divergences++
+	m.status.Shadow.LastDivergence = divergence
+	m.status.Shadow.LastDivergenceAt = sdk.OptionalTime(now)
+}
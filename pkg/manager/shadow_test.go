@@ -0,0 +1,95 @@
+package manager
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rancher/csp-adapter/pkg/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShadowPlanner(t *testing.T) {
+	tests := []struct {
+		name                string
+		shadow              Planner
+		expectedDivergences int
+	}{
+		{
+			name:   "stable",
+			shadow: StablePlanner{},
+		},
+		{
+			name:   "delta checkout",
+			shadow: DeltaCheckoutPlanner{},
+			// only scaling up is planned differently
+			expectedDivergences: 1,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			mockAWSClient := mocks.NewMockAWSClient(5)
+			mockK8s := mocks.NewMockK8sClient(nil)
+			mockScraper := mocks.NewMockScraper(20)
+			mockAWS := NewAWS(mockAWSClient, mockK8s, mockScraper, Options{Shadow: test.shadow})
+			for _, nodes := range []int{20, 20, 60, 20} {
+				mockScraper.Nodes = nodes
+				require.NoError(t, mockAWS.runComplianceCheck(ctx))
+			}
+			// the shadow planner's decisions aren't carried out
+			assert.Equal(t, "1", mockK8s.CurrentSecretData[nodeKey])
+			assert.Len(t, mockAWSClient.CheckedOutEntitlements, 1)
+
+			status := mockAWS.Status().Shadow
+			require.NotNil(t, status)
+			assert.Equal(t, test.shadow.Name(), status.Planner)
+			assert.Equal(t, 4, status.Checks)
+			assert.Equal(t, test.expectedDivergences, status.Divergences)
+			if test.expectedDivergences > 0 {
+				assert.Contains(t, status.LastDivergence, "check out 2")
+			}
+		})
+	}
+}
+
+func TestDeltaCheckoutPlanner(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    PlanInput
+		expected Decision
+	}{
+		{
+			name:     "first checkout",
+			input:    PlanInput{Required: 3, Available: 5},
+			expected: Decision{Checkout: 3},
+		},
+		{
+			name:     "scale up",
+			input:    PlanInput{Required: 3, Held: 1, Available: 4},
+			expected: Decision{Checkout: 2},
+		},
+		{
+			name:     "scale up beyond available",
+			input:    PlanInput{Required: 5, Held: 1, Available: 2},
+			expected: Decision{Checkout: 2},
+		},
+		{
+			name:     "scale down",
+			input:    PlanInput{Required: 1, Held: 3, Available: 2},
+			expected: Decision{CheckIn: 3, Checkout: 1},
+		},
+		{
+			name:     "rotation",
+			input:    PlanInput{Required: 3, Held: 3, Available: 2, RotationDue: true},
+			expected: Decision{CheckIn: 3, Checkout: 3},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, DeltaCheckoutPlanner{}.Plan(test.input))
+		})
+	}
+}
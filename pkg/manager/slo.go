@@ -0,0 +1,32 @@
+package manager
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/sdk"
+)
+
+// reportErrorBudget sets the ErrorBudgetExhausted condition from the SLO tracker, so that a spent error budget is
+// reported with the adapter's other conditions. Does nothing without a tracker
+func (m *AWS) reportErrorBudget(now time.Time) {
+	if m.opts.SLO == nil {
+		return
+	}
+	status := m.opts.SLO.Status(now)
+	condition := sdk.Condition{
+		Type:    sdk.ConditionErrorBudgetExhausted,
+		Status:  "False",
+		Reason:  "WithinBudget",
+		Message: fmt.Sprintf("%.0f%% of the error budget of the last %s remains", status.ErrorBudgetRemaining*100, status.Window),
+	}
+	if status.BudgetExhausted {
+		condition.Status = "True"
+		condition.Reason = "BudgetExhausted"
+		condition.Message = fmt.Sprintf("%.2f%% of %d License Manager operations succeeded within the last %s, below the target of %.2f%%",
+			status.SuccessRatio*100, status.Operations, status.Window, status.Target*100)
+	}
+	m.statusLock.Lock()
+	m.status.Conditions = setStatusCondition(m.status.Conditions, condition, now)
+	m.statusLock.Unlock()
+}
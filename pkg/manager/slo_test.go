@@ -0,0 +1,42 @@
+package manager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/mocks"
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/rancher/csp-adapter/pkg/slo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorBudgetCondition(t *testing.T) {
+	tracker, err := slo.NewTracker(slo.DefaultOptions)
+	require.NoError(t, err)
+	m := NewAWS(mocks.NewMockAWSClient(5), mocks.NewMockK8sClient(nil), mocks.NewMockScraper(20), Options{SLO: tracker})
+	require.NoError(t, m.runComplianceCheck(context.Background()))
+	condition := findCondition(m.Status().Conditions, sdk.ConditionErrorBudgetExhausted)
+	require.NotNil(t, condition)
+	assert.Equal(t, "False", condition.Status)
+
+	now := time.Now()
+	for i := 0; i < 10; i++ {
+		tracker.Record(false, now)
+	}
+	m.reportErrorBudget(now)
+	condition = findCondition(m.Status().Conditions, sdk.ConditionErrorBudgetExhausted)
+	require.NotNil(t, condition)
+	assert.Equal(t, "True", condition.Status)
+	assert.Equal(t, "BudgetExhausted", condition.Reason)
+}
+
+func findCondition(conditions []sdk.Condition, conditionType string) *sdk.Condition {
+	for i := range conditions {
+		if conditions[i].Type == conditionType {
+			return &conditions[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package manager
+
+import (
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/sirupsen/logrus"
+)
+
+// statusSources are the sources the status is built from, with the fields each of them feeds. While a source is
+// unavailable its fields keep the values of its last successful read, so the status degrades rather than fails
+var statusSources = []sdk.DataSourceStatus{
+	{
+		Name:   sdk.SourceLicenseManager,
+		Fields: []string{"usage.checkedOutLicenses", "usage.externalLicenses", "usage.checkoutExpiry", "usage.checkoutRenewsAt"},
+	},
+	{
+		Name: sdk.SourceNodeCounts,
+		Fields: []string{"usage.nodes", "usage.requiredLicenses", "usage.licensedNodes", "usage.nodesByEnvironment",
+			"usage.failedClusters", "usage.nodeCountSources"},
+	},
+}
+
+// recordSource records the outcome of a read of the source name at now. Sources are replaced rather than changed in
+// place, since copies of the status handed out by Status share them
+func (m *AWS) recordSource(name string, err error, now time.Time) {
+	m.statusLock.Lock()
+	defer m.statusLock.Unlock()
+	sources := append([]sdk.DataSourceStatus(nil), m.status.Sources...)
+	for i := range sources {
+		source := &sources[i]
+		if source.Name != name {
+			continue
+		}
+		if err == nil {
+			if source.Stale {
+				logrus.Infof("[manager] %s is available again after failing since %s", name, source.FailingSince.Format(time.RFC3339))
+			}
+			source.LastSuccess, source.LastError, source.FailingSince, source.Stale = sdk.OptionalTime(now), "", nil, false
+			break
+		}
+		if !source.Stale {
+			source.FailingSince = sdk.OptionalTime(now)
+		}
+		source.LastError, source.Stale = err.Error(), true
+	}
+	m.status.Sources = sources
+}
+
+// degraded returns whether any of sources is stale
+func degraded(sources []sdk.DataSourceStatus) bool {
+	for _, source := range sources {
+		if source.Stale {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/licensemanager/types"
+	"github.com/rancher/csp-adapter/pkg/mocks"
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// unavailableLicenseManager fails to read the license while down is set
+type unavailableLicenseManager struct {
+	*mocks.MockAWSClient
+	down bool
+}
+
+func (c *unavailableLicenseManager) GetRancherLicense(ctx context.Context) (*types.GrantedLicense, error) {
+	if c.down {
+		return nil, errors.New("license manager is unavailable")
+	}
+	return c.MockAWSClient.GetRancherLicense(ctx)
+}
+
+func source(t *testing.T, status sdk.Status, name string) sdk.DataSourceStatus {
+	t.Helper()
+	for _, source := range status.Sources {
+		if source.Name == name {
+			return source
+		}
+	}
+	require.Failf(t, "source not reported", "no source %s in the status", name)
+	return sdk.DataSourceStatus{}
+}
+
+func TestStatusSources(t *testing.T) {
+	client := &unavailableLicenseManager{MockAWSClient: mocks.NewMockAWSClient(5)}
+	scraper := mocks.NewMockScraper(30)
+	manager := NewAWS(client, mocks.NewMockK8sClient(nil), scraper, Options{NodeCountFailureThreshold: 3})
+	assert.Len(t, manager.Status().Sources, 2, "sources are listed before the first check")
+	assert.False(t, manager.Status().Degraded)
+
+	require.NoError(t, manager.runComplianceCheck(context.Background()))
+	status := manager.Status()
+	assert.False(t, status.Degraded)
+	assert.NotNil(t, source(t, status, sdk.SourceLicenseManager).LastSuccess)
+	assert.Equal(t, 30, status.Usage.Nodes)
+
+	client.down = true
+	assert.Error(t, manager.runComplianceCheck(context.Background()))
+	status = manager.Status()
+	assert.True(t, status.Degraded)
+	licenseManager := source(t, status, sdk.SourceLicenseManager)
+	assert.True(t, licenseManager.Stale)
+	assert.Equal(t, "license manager is unavailable", licenseManager.LastError)
+	assert.NotNil(t, licenseManager.FailingSince)
+	assert.Contains(t, licenseManager.Fields, "usage.checkedOutLicenses")
+	assert.Equal(t, 30, status.Usage.Nodes, "fields keep the values of the last successful read")
+	assert.Equal(t, 2, status.Usage.CheckedOutLicenses)
+
+	failingSince := licenseManager.FailingSince
+	assert.Error(t, manager.runComplianceCheck(context.Background()))
+	assert.Equal(t, failingSince, source(t, manager.Status(), sdk.SourceLicenseManager).FailingSince, "failures continue the outage")
+
+	client.down = false
+	scraper.Err = errors.New("rancher is slow")
+	assert.NoError(t, manager.runComplianceCheck(context.Background()), "a node count failure is tolerated")
+	status = manager.Status()
+	assert.False(t, source(t, status, sdk.SourceLicenseManager).Stale, "license manager is available again")
+	assert.True(t, source(t, status, sdk.SourceNodeCounts).Stale)
+	assert.True(t, status.Degraded)
+
+	scraper.Err = nil
+	require.NoError(t, manager.runComplianceCheck(context.Background()))
+	assert.False(t, manager.Status().Degraded)
+}
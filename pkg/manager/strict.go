@@ -0,0 +1,49 @@
+package manager
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// UnverifiedError is returned by compliance checks in strict mode once the availability of entitlements couldn't be
+// verified for longer than Options.StrictTimeout. Rancher is reported as non-compliant regardless of the licenses held
+type UnverifiedError struct {
+	Since time.Time
+	Err   error
+}
+
+func (e *UnverifiedError) Error() string {
+	msg := fmt.Sprintf("entitlements couldn't be verified since %s", e.Since.Format(time.RFC3339))
+	if e.Err != nil {
+		msg = fmt.Sprintf("%s: %v", msg, e.Err)
+	}
+	return msg
+}
+
+func (e *UnverifiedError) Unwrap() error {
+	return e.Err
+}
+
+// failClosed applies strict mode to the outcome of a compliance check which ended at now, returning an UnverifiedError
+// in place of checkErr once entitlements went unverified for too long. Must be called while holding the checkLock
+func (m *AWS) failClosed(checkErr error, now time.Time) error {
+	if m.opts.StrictTimeout <= 0 {
+		return checkErr
+	}
+	if checkErr == nil && m.verified {
+		if !m.unverifiedSince.IsZero() {
+			logrus.Infof("[manager] entitlements were verified again")
+		}
+		m.unverifiedSince = time.Time{}
+		return nil
+	}
+	if m.unverifiedSince.IsZero() {
+		m.unverifiedSince = now
+	}
+	if now.Sub(m.unverifiedSince) <= m.opts.StrictTimeout {
+		return checkErr
+	}
+	return &UnverifiedError{Since: m.unverifiedSince, Err: checkErr}
+}
@@ -0,0 +1,57 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/mocks"
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStrictMode(t *testing.T) {
+	mockScraper := mocks.NewMockScraper(20)
+	mockK8s := mocks.NewMockK8sClient(nil)
+	mockAWS := NewAWS(mocks.NewMockAWSClient(5), mockK8s, mockScraper, Options{
+		NodeCountFailureThreshold: 10,
+		StrictTimeout:             time.Hour,
+	})
+	ctx := context.Background()
+	start := time.Date(2022, time.June, 1, 12, 0, 0, 0, time.UTC)
+	assert.NoError(t, mockAWS.failClosed(mockAWS.runComplianceCheck(ctx), start))
+
+	// tolerated failures keep the checkout, but don't verify entitlements
+	mockScraper.Err = fmt.Errorf("rancher metrics unavailable")
+	assert.NoError(t, mockAWS.failClosed(mockAWS.runComplianceCheck(ctx), start.Add(time.Minute)))
+	assert.NoError(t, mockAWS.failClosed(mockAWS.runComplianceCheck(ctx), start.Add(time.Hour)), "failures within the timeout should be tolerated")
+	err := mockAWS.failClosed(mockAWS.runComplianceCheck(ctx), start.Add(time.Hour+2*time.Minute))
+	var unverifiedErr *UnverifiedError
+	require.True(t, errors.As(err, &unverifiedErr), "expected UnverifiedError after the timeout, got %v", err)
+	assert.Equal(t, start.Add(time.Minute), unverifiedErr.Since)
+
+	errs := make(chan error, 2)
+	mockAWS.reportCheckError(ctx, err, errs)
+	status := mockAWS.Status().Compliance
+	assert.Equal(t, sdk.ComplianceStatusNonCompliant, status.Status)
+	assert.Equal(t, sdk.ReasonEntitlementsUnverified, status.Reason)
+	var config CSPSupportConfig
+	require.NoError(t, json.Unmarshal(mockK8s.CurrentSupportConfig, &config))
+	assert.Equal(t, StatusNotInCompliance, config.Compliance.Status)
+
+	mockScraper.Err = nil
+	assert.NoError(t, mockAWS.failClosed(mockAWS.runComplianceCheck(ctx), start.Add(2*time.Hour)))
+	status = mockAWS.Status().Compliance
+	assert.Equal(t, sdk.ReasonLicensed, status.Reason)
+	assert.True(t, mockAWS.unverifiedSince.IsZero())
+}
+
+func TestStrictModeDisabled(t *testing.T) {
+	m := &AWS{unverifiedSince: time.Now().Add(-24 * time.Hour)}
+	checkErr := fmt.Errorf("license manager unavailable")
+	assert.Equal(t, checkErr, m.failClosed(checkErr, time.Now()), "errors should be passed through without a strict timeout")
+}
@@ -0,0 +1,153 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/identity"
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/sirupsen/logrus"
+	apierror "k8s.io/apimachinery/pkg/api/errors"
+)
+
+const (
+	// subscribedKey holds the lower case usernames the adapter subscribed in the cache secret, as a json list
+	subscribedKey = "subscribedUsers"
+)
+
+// syncSubscriptions subscribes the users counted by the user counter to the product licensed per user, and stops the
+// subscriptions it started of users who are no longer counted. Subscriptions started outside of the adapter are left
+// alone. Users which can't be (un)subscribed are reported in the status and retried on the next sync
+func (m *AWS) syncSubscriptions(ctx context.Context, now time.Time) error {
+	subscriptions := m.opts.Subscriptions
+	status := &sdk.SubscriptionStatus{
+		Product:    subscriptions.Product(),
+		LastSynced: now,
+	}
+	defer func() {
+		m.statusLock.Lock()
+		defer m.statusLock.Unlock()
+		m.status.Subscriptions = status
+	}()
+
+	counter := m.opts.UserCounter
+	if counter == nil {
+		counter = identity.RancherUsers{Client: m.k8s}
+	}
+	users, err := counter.CountUsers(ctx)
+	if err != nil {
+		status.LastError = err.Error()
+		return fmt.Errorf("unable to count rancher users for subscriptions: %w", err)
+	}
+	desired := map[string]string{}
+	for _, user := range users {
+		// active directory usernames are case insensitive
+		desired[strings.ToLower(user.Username)] = user.Username
+	}
+	status.RancherUsers = len(desired)
+
+	subscribed, err := subscriptions.ListSubscribedUsers(ctx)
+	if err != nil {
+		status.LastError = err.Error()
+		return fmt.Errorf("unable to list subscriptions to %s: %w", status.Product, err)
+	}
+	current := map[string]string{}
+	for _, username := range subscribed {
+		current[strings.ToLower(username)] = username
+	}
+	managed, err := m.managedSubscriptions()
+	if err != nil {
+		status.LastError = err.Error()
+		return fmt.Errorf("unable to read the subscriptions started by the adapter: %w", err)
+	}
+	before := len(managed)
+	for key := range managed {
+		// subscriptions which ended or failed are started again if the user is still counted
+		if _, ok := current[key]; !ok {
+			delete(managed, key)
+		}
+	}
+
+	var errs []string
+	for key, username := range desired {
+		if _, ok := current[key]; ok {
+			continue
+		}
+		if err := subscriptions.SubscribeUser(ctx, username); err != nil {
+			status.Failed = append(status.Failed, username)
+			errs = append(errs, fmt.Sprintf("%s: %v", username, err))
+			continue
+		}
+		logrus.Infof("[manager] subscribed user %s to %s", username, status.Product)
+		current[key] = username
+		managed[key] = true
+	}
+	for key, username := range current {
+		if _, ok := desired[key]; ok || !managed[key] {
+			continue
+		}
+		if err := subscriptions.UnsubscribeUser(ctx, username); err != nil {
+			status.Failed = append(status.Failed, username)
+			errs = append(errs, fmt.Sprintf("%s: %v", username, err))
+			continue
+		}
+		logrus.Infof("[manager] stopped subscription of user %s to %s", username, status.Product)
+		delete(current, key)
+		delete(managed, key)
+	}
+	if err := m.saveManagedSubscriptions(managed, before); err != nil {
+		errs = append(errs, fmt.Sprintf("unable to record the subscriptions started by the adapter: %v", err))
+	}
+	status.SubscribedUsers = len(current)
+	sort.Strings(status.Failed)
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		status.LastError = strings.Join(errs, "; ")
+		return fmt.Errorf("unable to sync %d subscriptions to %s: %s", len(errs), status.Product, status.LastError)
+	}
+	return nil
+}
+
+// managedSubscriptions returns the lower case usernames of the users the adapter subscribed
+func (m *AWS) managedSubscriptions() (map[string]bool, error) {
+	managed := map[string]bool{}
+	secret, err := m.k8s.GetConsumptionTokenSecret()
+	if apierror.IsNotFound(err) {
+		return managed, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	data := secret.Data[subscribedKey]
+	if len(data) == 0 {
+		return managed, nil
+	}
+	var usernames []string
+	if err := json.Unmarshal(data, &usernames); err != nil {
+		return nil, fmt.Errorf("unable to parse %s of the cache secret: %w", subscribedKey, err)
+	}
+	for _, username := range usernames {
+		managed[username] = true
+	}
+	return managed, nil
+}
+
+// saveManagedSubscriptions records the users the adapter subscribed in the cache secret. The secret isn't written if
+// nothing was subscribed before or since
+func (m *AWS) saveManagedSubscriptions(managed map[string]bool, before int) error {
+	if before == 0 && len(managed) == 0 {
+		return nil
+	}
+	usernames := make([]string, 0, len(managed))
+	for username := range managed {
+		usernames = append(usernames, username)
+	}
+	sort.Strings(usernames)
+	// can't fail, it's a list of strings
+	data, _ := json.Marshal(usernames)
+	return m.k8s.UpdateConsumptionTokenSecret(map[string]string{subscribedKey: string(data)})
+}
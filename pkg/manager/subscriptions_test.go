@@ -0,0 +1,92 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/clients/k8s"
+	"github.com/rancher/csp-adapter/pkg/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSubscriptions struct {
+	subscribed map[string]bool
+	failing    map[string]bool
+}
+
+func (f *fakeSubscriptions) Product() string {
+	return "office_professional_plus"
+}
+
+func (f *fakeSubscriptions) ListSubscribedUsers(ctx context.Context) ([]string, error) {
+	var usernames []string
+	for username := range f.subscribed {
+		usernames = append(usernames, username)
+	}
+	return usernames, nil
+}
+
+func (f *fakeSubscriptions) SubscribeUser(ctx context.Context, username string) error {
+	if f.failing[username] {
+		return errors.New("user not found in directory")
+	}
+	f.subscribed[username] = true
+	return nil
+}
+
+func (f *fakeSubscriptions) UnsubscribeUser(ctx context.Context, username string) error {
+	if f.failing[username] {
+		return errors.New("user not found in directory")
+	}
+	delete(f.subscribed, username)
+	return nil
+}
+
+func adUser(id, username string, enabled bool) k8s.RancherUser {
+	return k8s.RancherUser{ID: id, Enabled: enabled, ProviderUsernames: map[string]string{"activedirectory": username}}
+}
+
+func TestSyncSubscriptions(t *testing.T) {
+	subscriptions := &fakeSubscriptions{
+		subscribed: map[string]bool{"Alice": true, "mallory": true},
+		failing:    map[string]bool{"dave": true},
+	}
+	mockK8s := mocks.NewMockK8sClient(nil)
+	mockK8s.AuthProviders = []string{"activedirectory"}
+	mockK8s.Users = []k8s.RancherUser{
+		adUser("u-1", "alice", true),
+		adUser("u-2", "bob", true),
+		adUser("u-3", "carol", false),
+		adUser("u-4", "dave", true),
+		{ID: "u-5", Username: "admin", Enabled: true},
+	}
+	m := NewAWS(mocks.NewMockAWSClient(5), mockK8s, mocks.NewMockScraper(1), Options{Subscriptions: subscriptions})
+	now := time.Now()
+
+	err := m.syncSubscriptions(context.Background(), now)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "dave")
+	assert.Equal(t, map[string]bool{"Alice": true, "bob": true, "mallory": true}, subscriptions.subscribed,
+		"usernames are matched case insensitively, disabled and local users are not subscribed, subscriptions the adapter didn't start are kept")
+
+	status := m.Status().Subscriptions
+	require.NotNil(t, status)
+	assert.Equal(t, "office_professional_plus", status.Product)
+	assert.Equal(t, 3, status.RancherUsers)
+	assert.Equal(t, 3, status.SubscribedUsers)
+	assert.Equal(t, []string{"dave"}, status.Failed)
+	assert.Equal(t, now, status.LastSynced)
+
+	delete(subscriptions.failing, "dave")
+	require.NoError(t, m.syncSubscriptions(context.Background(), now))
+	assert.Equal(t, 4, m.Status().Subscriptions.SubscribedUsers)
+	assert.Empty(t, m.Status().Subscriptions.Failed)
+
+	// only the subscriptions the adapter started are stopped
+	mockK8s.Users = []k8s.RancherUser{adUser("u-4", "dave", true)}
+	require.NoError(t, m.syncSubscriptions(context.Background(), now))
+	assert.Equal(t, map[string]bool{"Alice": true, "dave": true, "mallory": true}, subscriptions.subscribed)
+}
@@ -0,0 +1,116 @@
+package manager
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/sirupsen/logrus"
+	apierror "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// summaryRefreshInterval is how long a summary whose content didn't change is kept before it's published again, so
+// that its timestamps show that the adapter is still running without rewriting every summary on every check
+const summaryRefreshInterval = time.Hour
+
+// SummaryCache keeps the summary last published to each cluster, so that summaries which didn't change aren't
+// published again
+type SummaryCache interface {
+	// PublishedSummary returns the summary last published to the cluster and when, false if none was
+	PublishedSummary(clusterID string) (sdk.ClusterSummary, time.Time, bool, error)
+	RecordSummary(summary sdk.ClusterSummary, at time.Time) error
+	// RetainSummaries forgets the summaries of clusters which aren't in clusters
+	RetainSummaries(clusters map[string]int) error
+}
+
+// publishedSummary is a summary which was published at a time
+type publishedSummary struct {
+	summary sdk.ClusterSummary
+	at      time.Time
+}
+
+// memorySummaries is the SummaryCache used without a configured cache
+type memorySummaries map[string]publishedSummary
+
+func (c memorySummaries) PublishedSummary(clusterID string) (sdk.ClusterSummary, time.Time, bool, error) {
+	published, ok := c[clusterID]
+	return published.summary, published.at, ok, nil
+}
+
+func (c memorySummaries) RecordSummary(summary sdk.ClusterSummary, at time.Time) error {
+	c[summary.ClusterID] = publishedSummary{summary: summary, at: at}
+	return nil
+}
+
+func (c memorySummaries) RetainSummaries(clusters map[string]int) error {
+	for clusterID := range c {
+		if _, ok := clusters[clusterID]; !ok {
+			delete(c, clusterID)
+		}
+	}
+	return nil
+}
+
+// publishClusterSummaries publishes a summary containing only its own consumption to each downstream cluster's
+// namespace. Summaries which only differ from the published one in their timestamps are skipped until
+// summaryRefreshInterval passed. Failures are logged and don't fail the compliance check, since the summaries are
+// informational. Must be called while holding the checkLock
+func (m *AWS) publishClusterSummaries(environments environmentCounts) {
+	nodeCounts := environments.counts
+	compliance := m.Status().Compliance
+	now := time.Now()
+	if m.summaries == nil {
+		m.summaries = m.opts.Summaries
+		if m.summaries == nil {
+			m.summaries = memorySummaries{}
+		}
+	}
+	for clusterID, nodes := range nodeCounts.Clusters {
+		if clusterID == "" {
+			continue
+		}
+		summary := sdk.ClusterSummary{
+			ClusterID:   clusterID,
+			Nodes:       nodes,
+			TotalNodes:  nodeCounts.Total,
+			Environment: environments.clusters[clusterID],
+			Compliance:  compliance,
+			ObservedAt:  now,
+		}
+		published, at, ok, err := m.summaries.PublishedSummary(clusterID)
+		if err != nil {
+			logrus.Warnf("[manager] unable to read the summary published to cluster %s, publishing it again: %v", clusterID, err)
+		} else if ok && sameSummary(published, summary) && now.Sub(at) < summaryRefreshInterval {
+			continue
+		}
+		marshalled, err := json.Marshal(summary)
+		if err != nil {
+			logrus.Warnf("[manager] unable to marshal summary for cluster %s: %v", clusterID, err)
+			continue
+		}
+		err = m.k8s.UpdateClusterSummary(clusterID, marshalled)
+		if apierror.IsNotFound(err) {
+			// the cluster's namespace may not exist yet (or anymore), the next check will try again
+			logrus.Debugf("[manager] namespace for cluster %s not found, skipping summary", clusterID)
+			continue
+		}
+		if err != nil {
+			logrus.Warnf("[manager] unable to publish summary for cluster %s: %v", clusterID, err)
+			continue
+		}
+		if err := m.summaries.RecordSummary(summary, now); err != nil {
+			logrus.Warnf("[manager] unable to cache the summary published to cluster %s: %v", clusterID, err)
+		}
+	}
+	// removed clusters are published again if they come back
+	if err := m.summaries.RetainSummaries(nodeCounts.Clusters); err != nil {
+		logrus.Warnf("[manager] unable to forget the summaries of removed clusters: %v", err)
+	}
+}
+
+// sameSummary returns whether a and b only differ in their timestamps
+func sameSummary(a, b sdk.ClusterSummary) bool {
+	a.ObservedAt, b.ObservedAt = time.Time{}, time.Time{}
+	a.Compliance.LastChecked, b.Compliance.LastChecked = time.Time{}, time.Time{}
+	return a == b
+}
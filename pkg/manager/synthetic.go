@@ -0,0 +1,73 @@
+package manager
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/sirupsen/logrus"
+)
+
+// MaxSyntheticTest bounds how long non-compliance can be injected, so that a forgotten test doesn't hide the actual
+// compliance for long
+const MaxSyntheticTest = 24 * time.Hour
+
+// InjectNonCompliance reports the install as out of compliance for duration, on behalf of by, so that operators can
+// verify that their alerting and notification routing reach them. Nothing is checked in: licenses are held and renewed
+// as usual, and every message marks the non-compliance as synthetic. Injecting again replaces the running test
+func (m *AWS) InjectNonCompliance(by, reason string, duration time.Duration) (sdk.SyntheticTestStatus, error) {
+	if duration < time.Minute || duration > MaxSyntheticTest {
+		return sdk.SyntheticTestStatus{}, fmt.Errorf("synthetic non-compliance must last between 1 minute and %s", MaxSyntheticTest)
+	}
+	now := time.Now()
+	test := sdk.SyntheticTestStatus{By: by, Reason: reason, Since: now, Until: now.Add(duration)}
+	m.statusLock.Lock()
+	m.status.SyntheticTest = &test
+	m.statusLock.Unlock()
+	logrus.Warnf("[manager] injecting synthetic non-compliance for %s on behalf of %s: %s", duration, by, reason)
+	m.TriggerCheck()
+	// reports the actual compliance again as soon as the test ends, rather than at the next scheduled check
+	time.AfterFunc(duration, m.TriggerCheck)
+	return test, nil
+}
+
+// ClearNonCompliance ends a synthetic non-compliance test early
+func (m *AWS) ClearNonCompliance(by string) {
+	m.statusLock.Lock()
+	cleared := m.status.SyntheticTest != nil
+	m.status.SyntheticTest = nil
+	m.statusLock.Unlock()
+	if cleared {
+		logrus.Infof("[manager] synthetic non-compliance cleared by %s", by)
+		m.TriggerCheck()
+	}
+}
+
+// syntheticTest returns the synthetic non-compliance test running at now, nil if none is. Tests which ended are removed
+// from the status
+func (m *AWS) syntheticTest(now time.Time) *sdk.SyntheticTestStatus {
+	m.statusLock.Lock()
+	defer m.statusLock.Unlock()
+	if m.status.SyntheticTest == nil {
+		return nil
+	}
+	if !now.Before(m.status.SyntheticTest.Until) {
+		logrus.Infof("[manager] synthetic non-compliance injected by %s ended", m.status.SyntheticTest.By)
+		m.status.SyntheticTest = nil
+		return nil
+	}
+	test := *m.status.SyntheticTest
+	return &test
+}
+
+// describeSyntheticTest marks the messages of a check as synthetic non-compliance, keeping the actual compliance in the
+// config message
+func describeSyntheticTest(test sdk.SyntheticTestStatus, configMessage string, loc *time.Location) (string, string) {
+	marker := fmt.Sprintf("[SYNTHETIC TEST by %s until %s]", test.By, formatTime(test.Until, loc))
+	if test.Reason != "" {
+		marker = fmt.Sprintf("%s (%s)", marker, test.Reason)
+	}
+	statusMessage := fmt.Sprintf("%s %s This is a test of compliance alerting, no licenses were checked in and no action is required.",
+		statusPrefix, marker)
+	return fmt.Sprintf("%s synthetic non-compliance, actually: %s", marker, configMessage), statusMessage
+}
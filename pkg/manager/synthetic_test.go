@@ -0,0 +1,53 @@
+package manager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/mocks"
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyntheticNonCompliance(t *testing.T) {
+	ctx := context.Background()
+	mockAWSClient := mocks.NewMockAWSClient(5)
+	mockK8s := mocks.NewMockK8sClient(nil)
+	mockAWS := NewAWS(mockAWSClient, mockK8s, mocks.NewMockScraper(20), Options{})
+	require.NoError(t, mockAWS.runComplianceCheck(ctx))
+	token := mockK8s.CurrentSecretData[tokenKey]
+
+	_, err := mockAWS.InjectNonCompliance("admin", "", 0)
+	assert.Error(t, err)
+	_, err = mockAWS.InjectNonCompliance("admin", "", 2*MaxSyntheticTest)
+	assert.Error(t, err)
+
+	test, err := mockAWS.InjectNonCompliance("admin", "alert drill", time.Hour)
+	require.NoError(t, err)
+	require.NoError(t, mockAWS.runComplianceCheck(ctx))
+	status := mockAWS.Status()
+	assert.Equal(t, sdk.ComplianceStatusNonCompliant, status.Compliance.Status)
+	assert.Equal(t, sdk.ReasonSyntheticTest, status.Compliance.Reason)
+	assert.Contains(t, status.Compliance.Message, "SYNTHETIC TEST")
+	assert.Contains(t, status.Compliance.Message, "alert drill")
+	require.NotNil(t, status.SyntheticTest)
+	assert.Equal(t, test.Until, status.SyntheticTest.Until)
+	assert.Equal(t, token, mockK8s.CurrentSecretData[tokenKey], "no licenses should be checked in")
+	assert.Equal(t, "1", mockK8s.CurrentSecretData[nodeKey])
+
+	// the test ends once it expires
+	assert.NotNil(t, mockAWS.syntheticTest(test.Until.Add(-time.Second)))
+	assert.Nil(t, mockAWS.syntheticTest(test.Until))
+	require.NoError(t, mockAWS.runComplianceCheck(ctx))
+	status = mockAWS.Status()
+	assert.Equal(t, sdk.ReasonLicensed, status.Compliance.Reason)
+	assert.Nil(t, status.SyntheticTest)
+
+	_, err = mockAWS.InjectNonCompliance("admin", "", time.Hour)
+	require.NoError(t, err)
+	mockAWS.ClearNonCompliance("admin")
+	require.NoError(t, mockAWS.runComplianceCheck(ctx))
+	assert.Equal(t, sdk.ReasonLicensed, mockAWS.Status().Compliance.Reason)
+}
@@ -0,0 +1,101 @@
+package manager
+
+import (
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/metrics"
+	"github.com/rancher/csp-adapter/pkg/sdk"
+)
+
+// Phases of a compliance check which are timed
+const (
+	phaseLicense  = "license"
+	phaseCount    = "count"
+	phaseUsage    = "usage"
+	phaseCheckout = "checkout"
+	phaseStatus   = "status"
+	// optional phases, skipped once a check overran its budget
+	phaseAnomalies = "anomalies"
+	phaseHistory   = "history"
+	phaseSummaries = "summaries"
+)
+
+// phaseTimer measures the time spent in each phase of a compliance check. Phases can be nested, the time spent in a
+// nested phase isn't counted towards the phase it was started in. A nil timer measures nothing
+type phaseTimer struct {
+	now       func() time.Time
+	started   time.Time
+	since     time.Time
+	stack     []string
+	order     []string
+	durations map[string]time.Duration
+}
+
+func newPhaseTimer(now func() time.Time) *phaseTimer {
+	started := now()
+	return &phaseTimer{
+		now:       now,
+		started:   started,
+		since:     started,
+		durations: map[string]time.Duration{},
+	}
+}
+
+// begin starts timing phase, pausing the current phase until end is called
+func (t *phaseTimer) begin(phase string) {
+	if t == nil {
+		return
+	}
+	t.pause()
+	if _, ok := t.durations[phase]; !ok {
+		t.order = append(t.order, phase)
+		t.durations[phase] = 0
+	}
+	t.stack = append(t.stack, phase)
+}
+
+// end stops timing the most recently begun phase, resuming the phase it was begun in
+func (t *phaseTimer) end() {
+	if t == nil || len(t.stack) == 0 {
+		return
+	}
+	t.pause()
+	t.stack = t.stack[:len(t.stack)-1]
+}
+
+// pause adds the time since the last change to the current phase
+func (t *phaseTimer) pause() {
+	now := t.now()
+	if len(t.stack) > 0 {
+		t.durations[t.stack[len(t.stack)-1]] += now.Sub(t.since)
+	}
+	t.since = now
+}
+
+// timings returns the durations of the phases in the order they were first begun, and the duration of the whole check
+func (t *phaseTimer) timings() sdk.CheckTimings {
+	timings := sdk.CheckTimings{
+		TotalSeconds: t.now().Sub(t.started).Seconds(),
+		Phases:       make([]sdk.PhaseTiming, 0, len(t.order)),
+	}
+	for _, phase := range t.order {
+		timings.Phases = append(timings.Phases, sdk.PhaseTiming{Phase: phase, Seconds: t.durations[phase].Seconds()})
+	}
+	return timings
+}
+
+// recordTimings exposes the timings of the compliance check which was timed by t in the status and in metrics
+func (m *AWS) recordTimings(t *phaseTimer) {
+	for len(t.stack) > 0 {
+		// phases which were left by returning early
+		t.end()
+	}
+	timings := t.timings()
+	for _, phase := range timings.Phases {
+		metrics.CheckPhaseDuration.WithLabelValues(phase.Phase).Observe(phase.Seconds)
+	}
+	metrics.CheckDuration.Observe(timings.TotalSeconds)
+	m.statusLock.Lock()
+	defer m.statusLock.Unlock()
+	m.status.Timings = &timings
+}
@@ -0,0 +1,51 @@
+package manager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/mocks"
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPhaseTimer(t *testing.T) {
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	advance := func(d time.Duration) { now = now.Add(d) }
+	timer := newPhaseTimer(func() time.Time { return now })
+
+	timer.begin(phaseCount)
+	advance(2 * time.Second)
+	timer.end()
+	timer.begin(phaseCheckout)
+	advance(time.Second)
+	timer.begin(phaseUsage)
+	advance(3 * time.Second)
+	timer.end()
+	advance(time.Second)
+	timer.end()
+	advance(time.Second)
+
+	assert.Equal(t, sdk.CheckTimings{
+		TotalSeconds: 8,
+		Phases: []sdk.PhaseTiming{
+			{Phase: phaseCount, Seconds: 2},
+			{Phase: phaseCheckout, Seconds: 2},
+			{Phase: phaseUsage, Seconds: 3},
+		},
+	}, timer.timings(), "nested phases shouldn't count towards the phase they were started in")
+}
+
+func TestCheckTimings(t *testing.T) {
+	mockAWS := NewAWS(mocks.NewMockAWSClient(5), mocks.NewMockK8sClient(nil), mocks.NewMockScraper(20), Options{})
+	assert.NoError(t, mockAWS.runComplianceCheck(context.Background()))
+	timings := mockAWS.Status().Timings
+	if assert.NotNil(t, timings) {
+		var phases []string
+		for _, phase := range timings.Phases {
+			phases = append(phases, phase.Phase)
+		}
+		assert.Equal(t, []string{phaseLicense, phaseCount, phaseCheckout, phaseUsage, phaseAnomalies, phaseHistory, phaseStatus}, phases)
+	}
+}
@@ -1,12 +1,135 @@
 package manager
 
 import (
+	"context"
 	"fmt"
 	"strings"
+	"time"
 
+	"github.com/rancher/csp-adapter/pkg/audit"
+	"github.com/rancher/csp-adapter/pkg/clients/aws"
 	"github.com/rancher/csp-adapter/pkg/clients/k8s"
+	"github.com/rancher/csp-adapter/pkg/costs"
+	"github.com/rancher/csp-adapter/pkg/events"
+	"github.com/rancher/csp-adapter/pkg/identity"
+	"github.com/rancher/csp-adapter/pkg/reports"
+	"github.com/rancher/csp-adapter/pkg/schedule"
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/rancher/csp-adapter/pkg/slo"
+	"github.com/rancher/csp-adapter/pkg/usage"
 )
 
+// Options configures optional behavior of a manager
+type Options struct {
+	// PublishClusterSummaries publishes a compliance summary to the namespace of each downstream cluster, so that
+	// cluster owners can see their own consumption
+	PublishClusterSummaries bool
+	// Summaries keeps the summary last published to each cluster, nil keeps them in memory
+	Summaries SummaryCache
+	// PublishClusterCondition sets a LicenseCompliant condition on rancher's local cluster, so that alerts on cluster
+	// conditions surface compliance problems
+	PublishClusterCondition bool
+	// MinimumLicenses is the number of licenses which are always kept checked out, even if fewer are needed for the
+	// current number of nodes (i.e. a contractual minimum)
+	MinimumLicenses int
+	// DeclaredNodes, if set, is the node count the checkout converges to instead of the counted nodes, i.e. declared in
+	// git by operators who plan their consumption. Nodes are still counted to report whether the declaration covers
+	// them
+	DeclaredNodes int
+	// NodeCountFailureThreshold is the number of consecutive compliance checks which may fail to count nodes while
+	// the current checkout is still renewed. Once reached, renewal stops and the status is reported as degraded
+	NodeCountFailureThreshold int
+	// Schedule restricts full compliance checks, which may check out or check in licenses, to the times it matches.
+	// In between, the current checkout is only renewed. Nil runs a full check on every interval
+	Schedule *schedule.Schedule
+	// CheckBudget limits how long a compliance check takes, so that the checkout is renewed within the check interval
+	// even if counting nodes is slow. Each phase is allotted a slice of the budget, calls overrunning the slice of a
+	// critical phase are cancelled and optional phases (anomaly detection, usage history compaction, cluster summaries)
+	// are skipped once the check has overrun. 0 doesn't limit checks
+	CheckBudget time.Duration
+	// StrictTimeout enables strict mode when positive. Once the availability of entitlements couldn't be verified for
+	// longer than StrictTimeout, rancher is reported as non-compliant even if licenses are still checked out
+	StrictTimeout time.Duration
+	// MaxTokenExtensions is the number of times License Manager allows a consumption token to be extended. The token
+	// is rotated with a fresh checkout shortly before reaching it. 0 never rotates tokens
+	MaxTokenExtensions int
+	// TokenLimitWarning is the number of extensions left before MaxTokenExtensions at which the upcoming rotation is
+	// announced in the status. With a Schedule, the token is rotated by the first full check in the change window from
+	// then on, rather than only once its budget is nearly used up. 0 doesn't announce rotations
+	TokenLimitWarning int
+	// Subscriptions subscribes the users counted by UserCounter to a product licensed per user. Nil disables user
+	// subscriptions
+	Subscriptions aws.SubscriptionClient
+	// UserCounter selects the users who are subscribed. Nil counts every enabled rancher user which logs in through
+	// active directory
+	UserCounter identity.UserCounter
+	// OverAllocationMode is what happens to checked out licenses which are no longer required after scaling down, one
+	// of the sdk.OverAllocation constants. Empty checks them in
+	OverAllocationMode string
+	// OverAllocationRetention is how long excess licenses are kept with sdk.OverAllocationRetain
+	OverAllocationRetention time.Duration
+	// ScaleDownConfirmation is how long a scale-down has to persist before licenses are checked in, after which nodes
+	// are counted again to confirm it, so that a transient counting error doesn't release licenses which are still
+	// required. 0 checks them in on the first check which requires fewer
+	ScaleDownConfirmation time.Duration
+	// ZeroNodeMode is what happens to the checkout while no nodes are licensed, one of the sdk.ZeroNodes constants.
+	// Empty handles it like any other scale-down. ZeroNodeGracePeriod is how long the checkout is kept with
+	// sdk.ZeroNodesGrace
+	ZeroNodeMode        string
+	ZeroNodeGracePeriod time.Duration
+	// ClassifyEnvironments classifies downstream clusters as production or non-production by their
+	// k8s.EnvironmentLabel, and counts the nodes of non-production clusters at NonProductionRatio (i.e. 0 excludes them,
+	// 0.5 counts half of them). Clusters which aren't labeled non-production are production
+	ClassifyEnvironments bool
+	NonProductionRatio   float64
+	// UsageRetention is how long the usage history is kept at each resolution, zero durations use the defaults
+	UsageRetention usage.Retention
+	// UsageStore stores the usage history, nil stores it through the kubernetes client
+	UsageStore usage.Store
+	// Shadow is run alongside every compliance check to compare its decisions with the adapter's, without carrying them
+	// out, so that a new planner can be vetted before it's rolled out. Nil disables shadow mode
+	Shadow Planner
+	// InstanceID identifies this adapter in the checkout info it writes, so that other instances writing the same
+	// info are detected. Empty generates a random identity
+	InstanceID string
+	// Audit receives the decisions taken when recovering checkouts which weren't recorded. Nil only logs them
+	Audit audit.Sink
+	// Events publishes compliance and config changes to the event firehose. Nil doesn't publish them
+	Events *events.Firehose
+	// Reports writes the compliance report to sinks besides the configmap rancher reads it from, i.e. a bucket. Nil
+	// only writes the configmap
+	Reports *reports.Dispatcher
+	// Costs estimates the monthly AWS cost of the adapter's integrations, reported in the status. Nil omits it from the
+	// status
+	Costs *costs.Estimator
+	// SLO tracks the success of License Manager operations, reported in the status. Nil omits it from the status
+	SLO *slo.Tracker
+	// Permissions checks the kubernetes permissions of the adapter on startup and periodically, reporting those which
+	// are missing in the status. Nil doesn't check them
+	Permissions PermissionChecker
+	// UsageVerificationWindow is how long License Manager is polled after a checkout until its usage reflects the
+	// checked out licenses. Checkouts whose usage doesn't materialize within the window are flagged in the status.
+	// 0 doesn't verify checkouts
+	UsageVerificationWindow time.Duration
+	// MaxSamples bounds each of the collections of recent observations held in memory (usage samples and checkouts
+	// for anomaly detection, consumption samples for the forecast), dropping the oldest once reached. Their windows
+	// already bound them, MaxSamples protects long-running adapters whose checks are triggered much more often than
+	// expected. 0 uses DefaultMaxSamples
+	MaxSamples int
+	// Maintenance are windows of planned AWS maintenance, while which License Manager is expected to be unreliable. The
+	// checkout is frozen for each of them, see sdk.MaintenanceWindow. More can be declared at runtime with
+	// DeclareMaintenance
+	Maintenance []sdk.MaintenanceWindow
+	// Location is the reporting timezone, which the times in messages published to rancher are rendered in. Nil
+	// renders them in UTC
+	Location *time.Location
+}
+
+// PermissionChecker returns the kubernetes permissions the adapter needs but wasn't granted
+type PermissionChecker interface {
+	MissingPermissions(ctx context.Context) ([]k8s.Permission, error)
+}
+
 type CSPSupportConfig struct {
 	SupportEligible bool           `json:"support_eligible,omitempty"`
 	Platform        string         `json:"platform"`
@@ -18,11 +141,12 @@ type CSPSupportConfig struct {
 type CSPInfo struct {
 	Name       string `json:"name"`
 	AcctNumber string `json:"acct_number"`
+	AcctAlias  string `json:"acct_alias,omitempty"`
 }
 
 const (
-	StatusInCompliance    = "Compliant"
-	StatusNotInCompliance = "NonCompliant"
+	StatusInCompliance    = sdk.ComplianceStatusCompliant
+	StatusNotInCompliance = sdk.ComplianceStatusNonCompliant
 	defaultPlatform       = "x86_64"
 	// SUSE support config reads EC2 as being for AWS, we want to use the same syntax to be consistent
 	awsSupportConfigCSP = "EC2"
@@ -30,7 +154,17 @@ const (
 
 type ComplianceInfo struct {
 	Status  string `json:"status"`
+	Reason  string `json:"reason,omitempty"`
 	Message string `json:"message"`
+	// Coverage is when the checked out licenses expire and are renewed, nil if none are checked out
+	Coverage *CheckoutCoverage `json:"coverage,omitempty"`
+}
+
+// CheckoutCoverage lets the rancher UI count down to the next renewal of the checked out licenses, so that the expiry
+// of a consumption token isn't mistaken for a gap in coverage
+type CheckoutCoverage struct {
+	ExpiresAt time.Time `json:"expires_at"`
+	RenewsAt  time.Time `json:"renews_at"`
 }
 
 // GetDefaultSupportConfig produces a CSPSupportConfig with values that could be inferred from k8s
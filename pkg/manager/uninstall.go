@@ -0,0 +1,76 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/rancher/csp-adapter/pkg/audit"
+	"github.com/rancher/csp-adapter/pkg/clients/aws"
+	corev1 "k8s.io/api/core/v1"
+	apierror "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// CacheSecret is the cache secret of an install. k8s.Clients implements it
+type CacheSecret interface {
+	GetConsumptionTokenSecret() (*corev1.Secret, error)
+	UpdateConsumptionTokenSecret(data map[string]string) error
+}
+
+// CheckInCached checks in the checkout recorded in the cache secret of an install along with its queued check-ins, and
+// clears those which were checked in from the secret. The chart's uninstall hook runs it, so that the licenses of an
+// uninstalled adapter are returned right away instead of once their tokens expire. Returns the number of tokens checked
+// in, and an error listing those which couldn't be
+func CheckInCached(ctx context.Context, client aws.Client, cache CacheSecret) (int, error) {
+	secret, err := cache.GetConsumptionTokenSecret()
+	if apierror.IsNotFound(err) {
+		// the adapter never checked out
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("unable to read the cache secret: %w", err)
+	}
+	held := string(secret.Data[tokenKey])
+	pending := parsePendingCheckIns(secret.Data[checkInKey])
+	var tokens []string
+	if held != "" {
+		tokens = append(tokens, held)
+	}
+	for _, p := range pending {
+		tokens = append(tokens, p.Token)
+	}
+	if len(tokens) == 0 {
+		return 0, nil
+	}
+	results := client.CheckInRancherLicenses(ctx, tokens)
+	var failed []string
+	var remaining []pendingCheckIn
+	for _, p := range pending {
+		if err := results[p.Token]; err != nil {
+			remaining = append(remaining, p)
+			failed = append(failed, fmt.Sprintf("%s: %v", audit.TokenID(p.Token), err))
+		}
+	}
+	data := make(map[string]string, len(secret.Data))
+	for key, value := range secret.Data {
+		data[key] = string(value)
+	}
+	data[checkInKey] = formatPendingCheckIns(remaining)
+	if held != "" {
+		if err := results[held]; err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", audit.TokenID(held), err))
+		} else {
+			data[tokenKey] = ""
+			data[nodeKey] = "0"
+		}
+	}
+	checkedIn := len(results) - len(failed)
+	if err := cache.UpdateConsumptionTokenSecret(data); err != nil {
+		return checkedIn, fmt.Errorf("checked in %d token(s) but unable to update the cache secret: %w", checkedIn, err)
+	}
+	if len(failed) > 0 {
+		return checkedIn, fmt.Errorf("%d token(s) couldn't be checked in and stay consumed until they expire: %s", len(failed),
+			strings.Join(failed, "; "))
+	}
+	return checkedIn, nil
+}
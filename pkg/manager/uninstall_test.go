@@ -0,0 +1,40 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/rancher/csp-adapter/pkg/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckInCached(t *testing.T) {
+	ctx := context.Background()
+	mockAWSClient := mocks.NewMockAWSClient(5)
+	checkedIn, err := CheckInCached(ctx, mockAWSClient, mocks.NewMockK8sClient(nil))
+	require.NoError(t, err, "an install without a cache secret has nothing to check in")
+	assert.Zero(t, checkedIn)
+
+	mockK8s := mocks.NewMockK8sClient(nil)
+	mockAWS := NewAWS(mockAWSClient, mockK8s, mocks.NewMockScraper(20), Options{})
+	require.NoError(t, mockAWS.runComplianceCheck(ctx))
+	mockAWSClient.CheckInErr = fmt.Errorf("throttled")
+	mockAWS.scraper = mocks.NewMockScraper(60)
+	require.NoError(t, mockAWS.runComplianceCheck(ctx))
+	require.Len(t, parsePendingCheckIns([]byte(mockK8s.CurrentSecretData[checkInKey])), 1)
+
+	checkedIn, err = CheckInCached(ctx, mockAWSClient, mockK8s)
+	assert.Error(t, err)
+	assert.Zero(t, checkedIn)
+	assert.NotEmpty(t, mockK8s.CurrentSecretData[tokenKey], "a checkout which couldn't be checked in should stay recorded")
+
+	mockAWSClient.CheckInErr = nil
+	checkedIn, err = CheckInCached(ctx, mockAWSClient, mockK8s)
+	require.NoError(t, err)
+	assert.Equal(t, 2, checkedIn, "the held and the pending tokens should be checked in")
+	assert.Empty(t, mockAWSClient.CheckedOutEntitlements)
+	assert.Empty(t, mockK8s.CurrentSecretData[tokenKey])
+	assert.Empty(t, mockK8s.CurrentSecretData[checkInKey])
+}
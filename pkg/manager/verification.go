@@ -0,0 +1,92 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/licensemanager/types"
+	"github.com/rancher/csp-adapter/pkg/clients/aws"
+	"github.com/rancher/csp-adapter/pkg/metrics"
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/sirupsen/logrus"
+)
+
+// usageVerificationPolls is how many times the usage is polled within the verification window
+const usageVerificationPolls = 6
+
+// startUsageVerification verifies in the background that the usage of license reflects the checkout of token, which
+// should bring its consumption to at least expected. License Manager is eventually consistent, so a checkout isn't
+// flagged until its usage didn't materialize within the verification window. Must be called while holding the
+// checkLock
+func (m *AWS) startUsageVerification(ctx context.Context, license types.GrantedLicense, token string, expected int) {
+	if m.opts.UsageVerificationWindow <= 0 {
+		return
+	}
+	m.statusLock.Lock()
+	m.verifying = token
+	m.status.Conditions = setStatusCondition(m.status.Conditions, sdk.Condition{
+		Type:    sdk.ConditionCheckoutVerified,
+		Status:  "Unknown",
+		Reason:  "Verifying",
+		Message: fmt.Sprintf("waiting for License Manager to report %d consumed license(s)", expected),
+	}, time.Now())
+	m.statusLock.Unlock()
+	go m.verifyCheckoutUsage(ctx, license, token, expected)
+}
+
+// verifyCheckoutUsage polls the usage of license until its consumption reaches expected or the verification window
+// passes, and records the outcome unless a later checkout superseded token
+func (m *AWS) verifyCheckoutUsage(ctx context.Context, license types.GrantedLicense, token string, expected int) {
+	interval := m.opts.UsageVerificationWindow / usageVerificationPolls
+	consumed := -1
+	var err error
+	for poll := 0; poll < usageVerificationPolls; poll++ {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+		var usage aws.EntitlementUsage
+		usage, err = m.aws.GetEntitlementUsage(ctx, license)
+		if err != nil {
+			logrus.Debugf("[manager] unable to get usage to verify checkout, retrying: %v", err)
+			continue
+		}
+		consumed = usage.Consumed
+		if consumed >= expected {
+			break
+		}
+	}
+	condition := sdk.Condition{
+		Type:   sdk.ConditionCheckoutVerified,
+		Status: "True",
+		Reason: "UsageReflected",
+	}
+	switch {
+	case consumed >= expected:
+		logrus.Debugf("[manager] License Manager reports %d consumed license(s), checkout verified", consumed)
+	case consumed < 0:
+		logrus.Warnf("[manager] unable to verify the usage of the checkout on license %s within %s: %v",
+			awssdk.ToString(license.LicenseArn), m.opts.UsageVerificationWindow, err)
+		condition.Status = "Unknown"
+		condition.Reason = "UsageUnavailable"
+		condition.Message = err.Error()
+	default:
+		logrus.Warnf("[manager] License Manager reports %d consumed license(s) on license %s %s after the checkout, expected at least %d. The checkout was accepted but its usage didn't materialize",
+			consumed, awssdk.ToString(license.LicenseArn), m.opts.UsageVerificationWindow, expected)
+		metrics.UnverifiedCheckouts.Inc()
+		condition.Status = "False"
+		condition.Reason = "UsageNotReflected"
+		condition.Message = fmt.Sprintf("License Manager reports %d consumed license(s) %s after the checkout, expected at least %d",
+			consumed, m.opts.UsageVerificationWindow, expected)
+	}
+	m.statusLock.Lock()
+	defer m.statusLock.Unlock()
+	if m.verifying != token {
+		// superseded by a later checkout, whose verification records its own outcome
+		return
+	}
+	m.status.Conditions = setStatusCondition(m.status.Conditions, condition, time.Now())
+}
@@ -0,0 +1,96 @@
+package manager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rancher/csp-adapter/pkg/metrics"
+	"github.com/rancher/csp-adapter/pkg/mocks"
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyCheckoutUsage(t *testing.T) {
+	tests := []struct {
+		name string
+		// consumed is the usage License Manager reports after the checkout
+		consumed   int
+		superseded bool
+		wantStatus string
+		wantReason string
+		wantFlag   bool
+	}{
+		{
+			name:       "usage reflects the checkout",
+			consumed:   3,
+			wantStatus: "True",
+			wantReason: "UsageReflected",
+		},
+		{
+			name:       "usage never materialized",
+			consumed:   1,
+			wantStatus: "False",
+			wantReason: "UsageNotReflected",
+			wantFlag:   true,
+		},
+		{
+			name:       "superseded by a later checkout",
+			consumed:   1,
+			superseded: true,
+			wantFlag:   true,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			awsClient := mocks.NewMockAWSClient(5)
+			awsClient.ExternalEntitlements = test.consumed
+			m := NewAWS(awsClient, mocks.NewMockK8sClient(nil), mocks.NewMockScraper(10), Options{
+				UsageVerificationWindow: 6 * time.Millisecond,
+			})
+			before := testutil.ToFloat64(metrics.UnverifiedCheckouts)
+
+			m.verifying = "token"
+			if test.superseded {
+				m.verifying = "later-token"
+			}
+			m.verifyCheckoutUsage(context.Background(), awsClient.License, "token", 3)
+
+			status := m.Status()
+			if test.superseded {
+				// the later checkout's verification records its own outcome
+				assert.Empty(t, status.Conditions)
+			} else if assert.Len(t, status.Conditions, 1) {
+				assert.Equal(t, sdk.ConditionCheckoutVerified, status.Conditions[0].Type)
+				assert.Equal(t, test.wantStatus, status.Conditions[0].Status)
+				assert.Equal(t, test.wantReason, status.Conditions[0].Reason)
+			}
+			flagged := testutil.ToFloat64(metrics.UnverifiedCheckouts) - before
+			if test.wantFlag {
+				assert.Greater(t, flagged, 0.0)
+			} else {
+				assert.Equal(t, 0.0, flagged)
+			}
+		})
+	}
+}
+
+func TestStartUsageVerification(t *testing.T) {
+	awsClient := mocks.NewMockAWSClient(5)
+	m := NewAWS(awsClient, mocks.NewMockK8sClient(nil), mocks.NewMockScraper(10), Options{})
+	m.startUsageVerification(context.Background(), awsClient.License, "token", 3)
+	assert.Empty(t, m.Status().Conditions, "verification is disabled without a window")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	// cancelled before the first poll, so that the pending condition is kept
+	cancel()
+	m.opts.UsageVerificationWindow = time.Hour
+	m.startUsageVerification(ctx, awsClient.License, "token", 3)
+	status := m.Status()
+	if assert.Len(t, status.Conditions, 1) {
+		assert.Equal(t, "Unknown", status.Conditions[0].Status)
+		assert.Equal(t, "Verifying", status.Conditions[0].Reason)
+	}
+}
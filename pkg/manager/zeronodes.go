@@ -0,0 +1,63 @@
+package manager
+
+import (
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/sirupsen/logrus"
+)
+
+// zeroNodeMode returns the configured handling of checks which license no nodes, defaulting to handling them like
+// any other scale-down
+func (m *AWS) zeroNodeMode() string {
+	if m.opts.ZeroNodeMode == "" {
+		return sdk.ZeroNodesScaleDown
+	}
+	return m.opts.ZeroNodeMode
+}
+
+// trackZeroNodes records when the check started to license no nodes, i.e. on a fresh install or after every cluster
+// was deleted. Must be called while holding the checkLock
+func (m *AWS) trackZeroNodes(nodes int, now time.Time) {
+	if nodes > 0 {
+		m.zeroNodesSince = time.Time{}
+		return
+	}
+	if !m.zeroNodesSince.IsZero() {
+		return
+	}
+	m.zeroNodesSince = now
+	switch m.zeroNodeMode() {
+	case sdk.ZeroNodesHoldMinimum:
+		logrus.Infof("[manager] no nodes are licensed, holding a checkout of at least %d license(s)", m.requiredLicenses(0))
+	case sdk.ZeroNodesCheckIn:
+		logrus.Infof("[manager] no nodes are licensed, checking in every license once confirmed")
+	case sdk.ZeroNodesGrace:
+		logrus.Infof("[manager] no nodes are licensed, keeping the current checkout until %s",
+			formatTime(now.Add(m.opts.ZeroNodeGracePeriod), m.location()))
+	}
+}
+
+// zeroNodeReleaseAt returns when the checkout held while no nodes are licensed is checked in, and whether the zero
+// node mode decides it rather than the over-allocation mode. Must be called while holding the checkLock
+func (m *AWS) zeroNodeReleaseAt() (time.Time, bool) {
+	if m.zeroNodesSince.IsZero() {
+		return time.Time{}, false
+	}
+	switch m.zeroNodeMode() {
+	case sdk.ZeroNodesCheckIn:
+		return m.zeroNodesSince, true
+	case sdk.ZeroNodesGrace:
+		return m.zeroNodesSince.Add(m.opts.ZeroNodeGracePeriod), true
+	}
+	return time.Time{}, false
+}
+
+// reportedZeroNodeMode returns the zero node mode for the status while no nodes are licensed, empty otherwise. Must be
+// called while holding the checkLock
+func (m *AWS) reportedZeroNodeMode() string {
+	if m.zeroNodesSince.IsZero() {
+		return ""
+	}
+	return m.zeroNodeMode()
+}
@@ -0,0 +1,89 @@
+package manager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/mocks"
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestZeroNodes(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		// expectedFresh is the number of licenses checked out by a fresh install without nodes
+		expectedFresh string
+		// expectedKept is the number of licenses held by the check after every cluster was deleted
+		expectedKept string
+		// release makes the kept checkout due for check-in, nil if none is kept
+		release func(m *AWS)
+		// expectedReleased is the number of licenses held once the kept checkout was released
+		expectedReleased string
+	}{
+		{
+			name:             "scale-down keeps the minimum",
+			opts:             Options{MinimumLicenses: 1},
+			expectedFresh:    "1",
+			expectedKept:     "1",
+			expectedReleased: "1",
+		},
+		{
+			name:             "hold minimum",
+			opts:             Options{ZeroNodeMode: sdk.ZeroNodesHoldMinimum},
+			expectedFresh:    "1",
+			expectedKept:     "1",
+			expectedReleased: "1",
+		},
+		{
+			name:             "check in everything",
+			opts:             Options{ZeroNodeMode: sdk.ZeroNodesCheckIn, MinimumLicenses: 1},
+			expectedKept:     "0",
+			expectedReleased: "0",
+		},
+		{
+			name:         "grace period",
+			opts:         Options{ZeroNodeMode: sdk.ZeroNodesGrace, ZeroNodeGracePeriod: time.Hour, OverAllocationMode: sdk.OverAllocationRetain, OverAllocationRetention: 24 * time.Hour},
+			expectedKept: "3",
+			release: func(m *AWS) {
+				m.zeroNodesSince = m.zeroNodesSince.Add(-2 * time.Hour)
+			},
+			expectedReleased: "0",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			mockAWSClient := mocks.NewMockAWSClient(5)
+			mockK8s := mocks.NewMockK8sClient(nil)
+			mockScraper := mocks.NewMockScraper(0)
+			mockAWS := NewAWS(mockAWSClient, mockK8s, mockScraper, test.opts)
+			require.NoError(t, mockAWS.runComplianceCheck(ctx))
+			if test.expectedFresh != "" {
+				assert.Equal(t, test.expectedFresh, mockK8s.CurrentSecretData[nodeKey])
+			} else {
+				assert.Empty(t, mockAWSClient.CheckedOutEntitlements, "nothing is checked out without nodes")
+			}
+			assert.Equal(t, mockAWS.zeroNodeMode(), mockAWS.Status().Usage.ZeroNodeMode)
+
+			mockScraper.Nodes = 60
+			require.NoError(t, mockAWS.runComplianceCheck(ctx))
+			assert.Equal(t, "3", mockK8s.CurrentSecretData[nodeKey])
+			assert.Empty(t, mockAWS.Status().Usage.ZeroNodeMode, "the mode is only reported without nodes")
+
+			mockScraper.Nodes = 0
+			require.NoError(t, mockAWS.runComplianceCheck(ctx))
+			assert.Equal(t, test.expectedKept, mockK8s.CurrentSecretData[nodeKey])
+			if test.release != nil {
+				test.release(mockAWS)
+				require.NoError(t, mockAWS.runComplianceCheck(ctx))
+			}
+			assert.Equal(t, test.expectedReleased, mockK8s.CurrentSecretData[nodeKey])
+			assert.Equal(t, sdk.ReasonLicensed, mockAWS.Status().Compliance.Reason)
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ClusterNodeCounter counts the nodes of individual downstream clusters through the rancher api
+type ClusterNodeCounter interface {
+	// ListClusterIDs returns the ids of all downstream clusters, excluding the local cluster
+	ListClusterIDs(ctx context.Context) ([]string, error)
+	// CountNodes returns the number of nodes of the cluster with the given id
+	CountNodes(ctx context.Context, clusterID string) (int, error)
+}
+
+// ClusterScraperOptions configures how many clusters are counted at once and how long counting a cluster may take
+type ClusterScraperOptions struct {
+	Parallelism int
+	Timeout     time.Duration
+	// Cache keeps the last count of each cluster, nil keeps them in memory
+	Cache NodeCountCache
+}
+
+// NodeCountCache keeps the last node count of each cluster which could be counted, for clusters which can't be counted
+// to fall back to
+type NodeCountCache interface {
+	// LastNodeCount returns the last count of the cluster, false if it was never counted
+	LastNodeCount(clusterID string) (int, bool, error)
+	// ReplaceNodeCounts replaces the known counts with counts, forgetting the clusters which aren't in it
+	ReplaceNodeCounts(counts map[string]int) error
+}
+
+// memoryNodeCounts is the NodeCountCache used without a configured cache
+type memoryNodeCounts map[string]int
+
+func (c *memoryNodeCounts) LastNodeCount(clusterID string) (int, bool, error) {
+	nodes, ok := (*c)[clusterID]
+	return nodes, ok, nil
+}
+
+func (c *memoryNodeCounts) ReplaceNodeCounts(counts map[string]int) error {
+	*c = counts
+	return nil
+}
+
+// DefaultClusterScraperOptions are used for options which aren't set
+var DefaultClusterScraperOptions = ClusterScraperOptions{
+	Parallelism: 10,
+	Timeout:     10 * time.Second,
+}
+
+// clusterScraper counts nodes by counting every downstream cluster separately and concurrently. Clusters which can't
+// be counted keep the count of the last scrape which succeeded for them, so that a few unreachable clusters don't
+// make the total drop
+type clusterScraper struct {
+	counter ClusterNodeCounter
+	opts    ClusterScraperOptions
+
+	lock      sync.Mutex
+	lastKnown NodeCountCache
+}
+
+func NewClusterScraper(counter ClusterNodeCounter, opts ClusterScraperOptions) Scraper {
+	if opts.Parallelism < 1 {
+		opts.Parallelism = DefaultClusterScraperOptions.Parallelism
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultClusterScraperOptions.Timeout
+	}
+	lastKnown := opts.Cache
+	if lastKnown == nil {
+		lastKnown = &memoryNodeCounts{}
+	}
+	return &clusterScraper{
+		counter:   counter,
+		opts:      opts,
+		lastKnown: lastKnown,
+	}
+}
+
+type clusterCount struct {
+	clusterID string
+	nodes     int
+	err       error
+}
+
+func (s *clusterScraper) ScrapeAndParse(ctx context.Context) (*NodeCounts, error) {
+	clusterIDs, err := s.counter.ListClusterIDs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list downstream clusters: %w", err)
+	}
+
+	ids := make(chan string)
+	results := make(chan clusterCount, len(clusterIDs))
+	var wg sync.WaitGroup
+	for i := 0; i < s.opts.Parallelism && i < len(clusterIDs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for clusterID := range ids {
+				nodes, err := s.countCluster(ctx, clusterID)
+				results <- clusterCount{clusterID: clusterID, nodes: nodes, err: err}
+			}
+		}()
+	}
+	for _, clusterID := range clusterIDs {
+		ids <- clusterID
+	}
+	close(ids)
+	wg.Wait()
+	close(results)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	counts := &NodeCounts{Clusters: map[string]int{}}
+	lastKnown := map[string]int{}
+	for result := range results {
+		nodes := result.nodes
+		if result.err != nil {
+			logrus.Warnf("[scraper] unable to count nodes of cluster %s: %v", result.clusterID, result.err)
+			counts.FailedClusters = append(counts.FailedClusters, result.clusterID)
+			var ok bool
+			var err error
+			if nodes, ok, err = s.lastKnown.LastNodeCount(result.clusterID); err != nil {
+				logrus.Warnf("[scraper] unable to read the last node count of cluster %s: %v", result.clusterID, err)
+				continue
+			} else if !ok {
+				// never counted, nothing to fall back to
+				continue
+			}
+		}
+		lastKnown[result.clusterID] = nodes
+		counts.Clusters[result.clusterID] = nodes
+		counts.Total += nodes
+	}
+	// clusters which were removed are forgotten
+	if err := s.lastKnown.ReplaceNodeCounts(lastKnown); err != nil {
+		logrus.Warnf("[scraper] unable to cache node counts: %v", err)
+	}
+	sort.Strings(counts.FailedClusters)
+	if len(clusterIDs) > 0 && len(counts.FailedClusters) == len(clusterIDs) {
+		return nil, fmt.Errorf("unable to count nodes of any of the %d downstream clusters", len(clusterIDs))
+	}
+	return counts, nil
+}
+
+// countCluster counts the nodes of a single cluster, giving up once the configured timeout has passed
+func (s *clusterScraper) countCluster(ctx context.Context, clusterID string) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.opts.Timeout)
+	defer cancel()
+	return s.counter.CountNodes(ctx, clusterID)
+}
@@ -0,0 +1,94 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeClusterCounter struct {
+	lock    sync.Mutex
+	nodes   map[string]int
+	failing map[string]bool
+	hanging map[string]bool
+	active  int32
+	maxSeen int32
+}
+
+func (f *fakeClusterCounter) ListClusterIDs(ctx context.Context) ([]string, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	var ids []string
+	for id := range f.nodes {
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func (f *fakeClusterCounter) CountNodes(ctx context.Context, clusterID string) (int, error) {
+	active := atomic.AddInt32(&f.active, 1)
+	defer atomic.AddInt32(&f.active, -1)
+	for {
+		maxSeen := atomic.LoadInt32(&f.maxSeen)
+		if active <= maxSeen || atomic.CompareAndSwapInt32(&f.maxSeen, maxSeen, active) {
+			break
+		}
+	}
+	f.lock.Lock()
+	nodes, failing, hanging := f.nodes[clusterID], f.failing[clusterID], f.hanging[clusterID]
+	f.lock.Unlock()
+	if hanging {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	}
+	// give other workers a chance to run concurrently
+	time.Sleep(time.Millisecond)
+	if failing {
+		return 0, fmt.Errorf("cluster %s unreachable", clusterID)
+	}
+	return nodes, nil
+}
+
+func TestClusterScraper(t *testing.T) {
+	counter := &fakeClusterCounter{nodes: map[string]int{}}
+	for i := 0; i < 20; i++ {
+		counter.nodes[fmt.Sprintf("c-%02d", i)] = 3
+	}
+	scraper := NewClusterScraper(counter, ClusterScraperOptions{Parallelism: 4, Timeout: 50 * time.Millisecond})
+
+	counts, err := scraper.ScrapeAndParse(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 60, counts.Total)
+	assert.Len(t, counts.Clusters, 20)
+	assert.Empty(t, counts.FailedClusters)
+	assert.LessOrEqual(t, atomic.LoadInt32(&counter.maxSeen), int32(4), "no more clusters than the parallelism should be counted at once")
+	assert.Greater(t, atomic.LoadInt32(&counter.maxSeen), int32(1), "clusters should be counted concurrently")
+
+	// failing and slow clusters keep their last known count
+	counter.lock.Lock()
+	counter.nodes["c-00"] = 10
+	counter.failing = map[string]bool{"c-00": true}
+	counter.hanging = map[string]bool{"c-01": true}
+	counter.nodes["c-new"] = 5
+	counter.failing["c-new"] = true
+	counter.lock.Unlock()
+	counts, err = scraper.ScrapeAndParse(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"c-00", "c-01", "c-new"}, counts.FailedClusters)
+	assert.Equal(t, 60, counts.Total, "failed clusters should be counted with their last known count, new ones not at all")
+	assert.Equal(t, 3, counts.Clusters["c-00"])
+
+	// all clusters failing is a failure of the scrape
+	counter.lock.Lock()
+	for id := range counter.nodes {
+		counter.failing[id] = true
+	}
+	counter.lock.Unlock()
+	_, err = scraper.ScrapeAndParse(context.Background())
+	assert.Error(t, err)
+}
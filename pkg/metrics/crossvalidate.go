@@ -0,0 +1,97 @@
+package metrics
+
+import (
+	"context"
+
+	"fmt"
+	"sort"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Source is a named node count source which can be cross-validated with another
+type Source struct {
+	Name    string
+	Scraper Scraper
+}
+
+// crossValidatingScraper counts nodes from two independent sources and compares them, so that one source
+// undercounting (i.e. missing clusters) doesn't go unnoticed. The larger count is used, so that divergences never
+// release licenses which the other source shows to be required
+type crossValidatingScraper struct {
+	primary   Source
+	secondary Source
+	threshold int
+}
+
+// NewCrossValidatingScraper returns a Scraper counting nodes from both primary and secondary every time. Counts which
+// differ by more than threshold nodes are flagged as diverged. If one of the sources fails, the count of the other is
+// used on its own
+func NewCrossValidatingScraper(primary, secondary Source, threshold int) Scraper {
+	return &crossValidatingScraper{
+		primary:   primary,
+		secondary: secondary,
+		threshold: threshold,
+	}
+}
+
+func (s *crossValidatingScraper) ScrapeAndParse(ctx context.Context) (*NodeCounts, error) {
+	primary, primaryErr := s.primary.Scraper.ScrapeAndParse(ctx)
+	secondary, secondaryErr := s.secondary.Scraper.ScrapeAndParse(ctx)
+	switch {
+	case primaryErr != nil && secondaryErr != nil:
+		return nil, fmt.Errorf("unable to count nodes from %s: %v, or from %s: %w", s.primary.Name, primaryErr, s.secondary.Name, secondaryErr)
+	case primaryErr != nil:
+		logrus.Warnf("[scraper] unable to count nodes from %s, using %s only: %v", s.primary.Name, s.secondary.Name, primaryErr)
+		return s.single(s.secondary.Name, secondary), nil
+	case secondaryErr != nil:
+		logrus.Warnf("[scraper] unable to count nodes from %s, using %s only: %v", s.secondary.Name, s.primary.Name, secondaryErr)
+		return s.single(s.primary.Name, primary), nil
+	}
+	NodeCountBySource.WithLabelValues(s.primary.Name).Set(float64(primary.Total))
+	NodeCountBySource.WithLabelValues(s.secondary.Name).Set(float64(secondary.Total))
+	counts := primary
+	if secondary.Total > primary.Total {
+		counts = secondary
+	}
+	counts.Sources = map[string]int{
+		s.primary.Name:   primary.Total,
+		s.secondary.Name: secondary.Total,
+	}
+	counts.FailedClusters = mergeFailedClusters(primary.FailedClusters, secondary.FailedClusters)
+	difference := primary.Total - secondary.Total
+	if difference < 0 {
+		difference = -difference
+	}
+	if difference > s.threshold {
+		logrus.Warnf("[scraper] node counts diverged by more than %d nodes: %s counted %d, %s counted %d, using %d",
+			s.threshold, s.primary.Name, primary.Total, s.secondary.Name, secondary.Total, counts.Total)
+		NodeCountDivergences.Inc()
+		counts.Diverged = true
+	}
+	return counts, nil
+}
+
+// single returns the counts of the only source which could be counted
+func (s *crossValidatingScraper) single(name string, counts *NodeCounts) *NodeCounts {
+	NodeCountBySource.WithLabelValues(name).Set(float64(counts.Total))
+	counts.Sources = map[string]int{name: counts.Total}
+	return counts
+}
+
+// mergeFailedClusters returns the clusters which failed in either source, sorted
+func mergeFailedClusters(a, b []string) []string {
+	if len(a) == 0 && len(b) == 0 {
+		return nil
+	}
+	seen := map[string]bool{}
+	var merged []string
+	for _, clusterID := range append(append([]string{}, a...), b...) {
+		if !seen[clusterID] {
+			seen[clusterID] = true
+			merged = append(merged, clusterID)
+		}
+	}
+	sort.Strings(merged)
+	return merged
+}
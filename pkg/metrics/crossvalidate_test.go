@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCrossValidatingScraper(t *testing.T) {
+	tests := []struct {
+		name             string
+		primary          staticScraper
+		secondary        staticScraper
+		expectedTotal    int
+		expectedSources  map[string]int
+		expectedDiverged bool
+		expectedFailed   []string
+		expectedErr      bool
+	}{
+		{
+			name:            "within threshold",
+			primary:         staticScraper{counts: NodeCounts{Total: 10}},
+			secondary:       staticScraper{counts: NodeCounts{Total: 12}},
+			expectedTotal:   12,
+			expectedSources: map[string]int{"metrics": 10, "clusters": 12},
+		},
+		{
+			name:             "diverged",
+			primary:          staticScraper{counts: NodeCounts{Total: 30, FailedClusters: []string{"c-2"}}},
+			secondary:        staticScraper{counts: NodeCounts{Total: 0, FailedClusters: []string{"c-1", "c-2"}}},
+			expectedTotal:    30,
+			expectedSources:  map[string]int{"metrics": 30, "clusters": 0},
+			expectedDiverged: true,
+			expectedFailed:   []string{"c-1", "c-2"},
+		},
+		{
+			name:            "secondary failed",
+			primary:         staticScraper{counts: NodeCounts{Total: 10}},
+			secondary:       staticScraper{err: errors.New("unavailable")},
+			expectedTotal:   10,
+			expectedSources: map[string]int{"metrics": 10},
+		},
+		{
+			name:        "both failed",
+			primary:     staticScraper{err: errors.New("unavailable")},
+			secondary:   staticScraper{err: errors.New("unavailable")},
+			expectedErr: true,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			scraper := NewCrossValidatingScraper(Source{Name: "metrics", Scraper: test.primary}, Source{Name: "clusters", Scraper: test.secondary}, 5)
+			counts, err := scraper.ScrapeAndParse(context.Background())
+			if test.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedTotal, counts.Total)
+			assert.Equal(t, test.expectedSources, counts.Sources)
+			assert.Equal(t, test.expectedDiverged, counts.Diverged)
+			assert.Equal(t, test.expectedFailed, counts.FailedClusters)
+		})
+	}
+}
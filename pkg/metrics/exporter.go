@@ -0,0 +1,274 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metrics exported by the adapter itself, as opposed to the rancher metrics read by the Scraper
+const metricsNamespace = "csp_adapter"
+
+var (
+	registry = prometheus.NewRegistry()
+
+	// LicenseManagerUp is 1 if the most recent probe of AWS License Manager succeeded, 0 otherwise
+	LicenseManagerUp = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "license_manager_up",
+		Help:      "Whether the most recent probe of AWS License Manager succeeded",
+	})
+	// LicenseManagerProbeFailures counts failed probes of AWS License Manager
+	LicenseManagerProbeFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "license_manager_probe_failures_total",
+		Help:      "Number of failed probes of AWS License Manager",
+	})
+	// LicenseManagerLastError is 1 for the class of error which failed the most recent probe of AWS License Manager,
+	// and has no series while the probes succeed
+	LicenseManagerLastError = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "license_manager_last_error",
+		Help:      "1 for the class of error (throttled, access_denied, timeout, api or network) of the most recent failed probe of AWS License Manager",
+	}, []string{"class"})
+	// UsageAnomalies counts detected usage anomalies by type
+	UsageAnomalies = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "usage_anomalies_total",
+		Help:      "Number of usage anomalies detected, by type",
+	}, []string{"type"})
+	// CheckDuration is the duration of compliance checks
+	CheckDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "check_duration_seconds",
+		Help:      "Duration of compliance checks",
+	})
+	// CheckPhaseDuration is the duration of the phases of compliance checks, by phase
+	CheckPhaseDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "check_phase_duration_seconds",
+		Help:      "Duration of the phases of compliance checks, by phase",
+	}, []string{"phase"})
+	// CheckPhasesSkipped counts the optional phases of compliance checks which were skipped because the check overran its
+	// budget, by phase
+	CheckPhasesSkipped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "check_phases_skipped_total",
+		Help:      "Optional phases of compliance checks skipped because the check overran its budget, by phase",
+	}, []string{"phase"})
+	// ExternalLicenses is the number of licenses consumed by checkouts which weren't made by the adapter
+	ExternalLicenses = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "external_licenses",
+		Help:      "Number of licenses checked out outside of the adapter, i.e. manually with the aws cli",
+	})
+	// RequestedLicenses is the number of licenses checked out for LicenseCheckoutRequests
+	RequestedLicenses = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "requested_licenses",
+		Help:      "Number of licenses checked out for LicenseCheckoutRequests, separately from the adapter's own checkout",
+	})
+	// TokenLimitWarnings counts consumption tokens which approached the extension limit, announcing their rotation
+	TokenLimitWarnings = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "token_limit_warnings_total",
+		Help:      "Number of consumption tokens which approached the extension limit",
+	})
+	// TokenRotations counts consumption tokens replaced by a fresh checkout before reaching the extension limit
+	TokenRotations = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "token_rotations_total",
+		Help:      "Number of consumption tokens rotated before reaching the extension limit",
+	})
+	// LicenseSwitchovers counts checkouts moved to the grant which replaced their license when it was renewed
+	LicenseSwitchovers = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "license_switchovers_total",
+		Help:      "Number of checkouts moved to the renewed grant of their license",
+	})
+
+	// UnverifiedCheckouts counts checkouts which License Manager accepted but whose usage never materialized
+	UnverifiedCheckouts = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "unverified_checkouts_total",
+		Help:      "Number of checkouts whose usage License Manager didn't report within the verification window",
+	})
+	// PendingCheckIns is the number of tokens whose check-in failed and is retried until they expire
+	PendingCheckIns = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "pending_checkins",
+		Help:      "Number of tokens whose check-in failed and is retried until they expire",
+	})
+	// MissingPermissions is the number of kubernetes permissions the adapter needs but wasn't granted
+	MissingPermissions = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "missing_permissions",
+		Help:      "Number of kubernetes permissions the adapter needs but wasn't granted",
+	})
+	// UnchangedWrites counts writes to kubernetes which were skipped since the object already held the content
+	UnchangedWrites = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "unchanged_writes_total",
+		Help:      "Number of writes to kubernetes skipped since the object already held the content",
+	})
+	// PendingWrites is the number of writes to kubernetes which failed and are waiting to be retried
+	PendingWrites = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "pending_writes",
+		Help:      "Number of writes to kubernetes waiting to be retried",
+	})
+	// LicenseOperations counts the License Manager operations of the adapter, by operation and outcome
+	LicenseOperations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "license_operations_total",
+		Help:      "Number of License Manager operations, by operation and outcome",
+	}, []string{"operation", "outcome"})
+	// SubsystemPanics counts the panics recovered by the supervisor, by the subsystem which was restarted
+	SubsystemPanics = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "subsystem_panics_total",
+		Help:      "Number of panics recovered in the adapter's subsystems, which were restarted, by subsystem",
+	}, []string{"subsystem"})
+	// ManagedClusters is the number of downstream clusters whose nodes are counted, by provider
+	ManagedClusters = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "managed_clusters",
+		Help:      "Number of downstream clusters managed by rancher, by provider",
+	}, []string{"provider"})
+	// ManagedNodes is the number of nodes counted towards the license, by provider of their cluster
+	ManagedNodes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "managed_nodes",
+		Help:      "Number of nodes of downstream clusters counted towards the license, by provider of their cluster",
+	}, []string{"provider"})
+	// Licenses is the number of licenses required and checked out, labelled with the size of the fleet they are for
+	Licenses = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "licenses",
+		Help:      "Number of licenses by state (required or checked_out), labelled with the bucket of the number of managed clusters",
+	}, []string{"state", "cluster_count"})
+	// ShadowDivergences counts compliance checks whose decision differed from the decision of the shadow planner
+	ShadowDivergences = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "shadow_divergences_total",
+		Help:      "Number of compliance checks whose decision differed from the shadow planner's, by planner",
+	}, []string{"planner"})
+	// DuplicateInstance is 1 while another adapter instance is detected writing the same checkout state
+	DuplicateInstance = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "duplicate_instance",
+		Help:      "1 while another adapter instance is writing the same checkout state, i.e. a second release of the chart",
+	})
+	// NodeCountBySource is the number of nodes counted by each source when node counts are cross-validated
+	NodeCountBySource = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "node_count",
+		Help:      "Number of nodes counted by each node count source, when node counts are cross-validated",
+	}, []string{"source"})
+	// NodeCountPushFresh is 1 while nodes are counted from pushed counts, 0 once they went stale
+	NodeCountPushFresh = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "node_count_push_fresh",
+		Help:      "Whether nodes are counted from fresh pushed counts (1) or pulled because the pushed counts went stale (0)",
+	})
+	// NodeCountDivergences counts node counts whose sources differed by more than the configured threshold
+	NodeCountDivergences = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "node_count_divergences_total",
+		Help:      "Number of node counts whose sources differed by more than the configured threshold",
+	})
+	// ProfileSnapshots counts heap and goroutine snapshots captured on abnormal memory growth
+	ProfileSnapshots = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "profile_snapshots_total",
+		Help:      "Number of times heap and goroutine profiles were captured because the adapter's memory grew abnormally",
+	})
+	// FirehoseEvents counts the events of the event firehose by outcome: published, failed or dropped because the
+	// broker couldn't keep up
+	FirehoseEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "firehose_events_total",
+		Help:      "Number of events of the event firehose, by outcome",
+	}, []string{"outcome"})
+	// EntitlementMax, EntitlementConsumed and EntitlementAvailable describe the entitlements of the license the adapter
+	// checks out from, by dimension and license arn. Their names and labels are a stable contract (see the README), so
+	// that alert rules keep working across releases
+	EntitlementMax = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "entitlement_max",
+		Help:      "Number of entitlements granted by the license, by dimension and license",
+	}, []string{"dimension", "license"})
+	EntitlementConsumed = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "entitlement_consumed",
+		Help:      "Number of entitlements of the license checked out by anyone, by dimension and license",
+	}, []string{"dimension", "license"})
+	EntitlementAvailable = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "entitlement_available",
+		Help:      "Number of entitlements of the license which can still be checked out, by dimension and license",
+	}, []string{"dimension", "license"})
+	// EntitlementUtilization and EntitlementExhaustionDays forecast the use of the entitlements of the license, by
+	// dimension and license arn, for automation such as purchasing workflows. They're part of the same stable contract
+	EntitlementUtilization = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "entitlement_utilization_ratio",
+		Help:      "Ratio of the entitlements of the license which are consumed, by dimension and license",
+	}, []string{"dimension", "license"})
+	EntitlementExhaustionDays = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "entitlement_exhaustion_days",
+		Help:      "Projected days until the available entitlements of the license are consumed, absent unless consumption grows",
+	}, []string{"dimension", "license"})
+	// ReportWrites counts the writes of compliance reports by sink and outcome (written or failed), and
+	// ReportLastWritten is when each sink was last written to successfully, so that a stale sink can be alerted on
+	ReportWrites = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "report_writes_total",
+		Help:      "Number of writes of compliance reports, by sink and outcome",
+	}, []string{"sink", "outcome"})
+	ReportLastWritten = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "report_last_written_timestamp_seconds",
+		Help:      "Unix time of the last successful write of the compliance report, by sink",
+	}, []string{"sink"})
+	// Paused is 1 while checkout adjustments are paused
+	Paused = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "paused",
+		Help:      "1 while checkout adjustments are paused, i.e. during incident response",
+	})
+	// RetainedItems is the number of items held by the adapter's in-memory collections, by collection
+	RetainedItems = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "retained_items",
+		Help:      "Number of items held in memory, by collection",
+	}, []string{"collection"})
+	// RetainedItemsDropped counts the oldest items dropped from in-memory collections which reached their bound, by
+	// collection
+	RetainedItemsDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "retained_items_dropped_total",
+		Help:      "Number of items dropped from in-memory collections which reached their bound, by collection",
+	}, []string{"collection"})
+)
+
+func init() {
+	registry.MustRegister(LicenseManagerUp, LicenseManagerProbeFailures, LicenseManagerLastError, UsageAnomalies, ExternalLicenses, CheckDuration, CheckPhaseDuration, CheckPhasesSkipped, PendingWrites, UnchangedWrites, TokenRotations, TokenLimitWarnings, LicenseSwitchovers,
+		UnverifiedCheckouts, PendingCheckIns, MissingPermissions, LicenseOperations, SubsystemPanics, DuplicateInstance,
+		ManagedClusters, ManagedNodes, Licenses, EntitlementMax, EntitlementConsumed, EntitlementAvailable, ShadowDivergences, Paused,
+		EntitlementUtilization, EntitlementExhaustionDays, ReportWrites, ReportLastWritten,
+		NodeCountBySource, NodeCountPushFresh, NodeCountDivergences, ProfileSnapshots, FirehoseEvents, RetainedItems,
+		RetainedItemsDropped, RequestedLicenses)
+}
+
+// Register adds collectors to the registry served by Handler
+func Register(collectors ...prometheus.Collector) {
+	registry.MustRegister(collectors...)
+}
+
+// Handler serves the adapter's metrics in the prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
@@ -1,6 +1,7 @@
 package metrics
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"strings"
@@ -87,3 +88,17 @@ func (m *mockPrometheusServer) requestAuthorized(req *http.Request) bool {
 func (m *mockPrometheusServer) requestAuthenticated(req *http.Request) bool {
 	return req.Header.Get("Authorization") != ""
 }
+
+// staticScraper returns the same counts, or error, on every scrape
+type staticScraper struct {
+	counts NodeCounts
+	err    error
+}
+
+func (s staticScraper) ScrapeAndParse(context.Context) (*NodeCounts, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	counts := s.counts
+	return &counts, nil
+}
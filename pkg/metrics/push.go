@@ -0,0 +1,125 @@
+package metrics
+
+import (
+	"context"
+
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// maxPushedNodes bounds the node count of a single pushed cluster, larger counts are assumed to be a mistake
+	maxPushedNodes = 100000
+	// PushSource names pushed counts in NodeCounts.Sources, so that the status tells they weren't counted by the adapter
+	PushSource = "push"
+	// pushClockSkew is how far in the future a push may be observed, to allow for clocks which are slightly ahead
+	pushClockSkew = time.Minute
+)
+
+// PushScraper counts nodes from the counts pushed by external systems (i.e. where the adapter's access to the rancher
+// api is restricted), falling back to pulling them with another Scraper while no push is fresh. Each push is a
+// snapshot of every cluster, which replaces the previous one and is fresh for the ttl after it was observed
+type PushScraper struct {
+	fallback Scraper
+	ttl      time.Duration
+	now      func() time.Time
+
+	lock sync.Mutex
+	// clusters are the node counts of the last push, observed at observedAt and pushed by pushedBy
+	clusters   map[string]int
+	observedAt time.Time
+	pushedBy   string
+	// stale is true once the last push went stale, so that the fallback is only logged when it starts
+	stale bool
+}
+
+// NewPushScraper returns a PushScraper whose pushes are fresh for ttl, counting nodes with fallback otherwise
+func NewPushScraper(fallback Scraper, ttl time.Duration) *PushScraper {
+	return &PushScraper{
+		fallback: fallback,
+		ttl:      ttl,
+		now:      time.Now,
+	}
+}
+
+// PushNodeCounts validates and records the node count of every cluster, as observed at observedAt (now if zero) by
+// the caller by. Returns when the counts go stale unless pushed again
+func (s *PushScraper) PushNodeCounts(clusters map[string]int, observedAt time.Time, by string) (time.Time, error) {
+	now := s.now()
+	if observedAt.IsZero() {
+		observedAt = now
+	}
+	if len(clusters) == 0 {
+		return time.Time{}, errors.New("no clusters were pushed, push the node count of every cluster")
+	}
+	for id, nodes := range clusters {
+		if id == "" {
+			return time.Time{}, errors.New("cluster ids can't be empty")
+		}
+		if nodes < 0 || nodes > maxPushedNodes {
+			return time.Time{}, fmt.Errorf("node count %d of cluster %s must be between 0 and %d", nodes, id, maxPushedNodes)
+		}
+	}
+	if observedAt.After(now.Add(pushClockSkew)) {
+		return time.Time{}, fmt.Errorf("observedAt %s is in the future", observedAt.Format(time.RFC3339))
+	}
+	expiresAt := observedAt.Add(s.ttl)
+	if !expiresAt.After(now) {
+		return time.Time{}, fmt.Errorf("observedAt %s is older than the push ttl of %s", observedAt.Format(time.RFC3339), s.ttl)
+	}
+	counts := make(map[string]int, len(clusters))
+	for id, nodes := range clusters {
+		counts[id] = nodes
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !observedAt.After(s.observedAt) {
+		return time.Time{}, fmt.Errorf("counts observed at %s were already pushed", s.observedAt.Format(time.RFC3339))
+	}
+	if s.stale || s.clusters == nil {
+		logrus.Infof("[scraper] counting nodes from the counts pushed by %s", by)
+	}
+	s.clusters = counts
+	s.observedAt = observedAt
+	s.pushedBy = by
+	s.stale = false
+	NodeCountPushFresh.Set(1)
+	return expiresAt, nil
+}
+
+func (s *PushScraper) ScrapeAndParse(ctx context.Context) (*NodeCounts, error) {
+	if counts := s.fresh(); counts != nil {
+		return counts, nil
+	}
+	return s.fallback.ScrapeAndParse(ctx)
+}
+
+// fresh returns the counts of the last push, nil if nothing was pushed or the push went stale
+func (s *PushScraper) fresh() *NodeCounts {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.clusters == nil {
+		return nil
+	}
+	if !s.now().Before(s.observedAt.Add(s.ttl)) {
+		if !s.stale {
+			logrus.Warnf("[scraper] the node counts pushed by %s went stale at %s, pulling them instead until they're pushed again",
+				s.pushedBy, s.observedAt.Add(s.ttl).Format(time.RFC3339))
+			s.stale = true
+			NodeCountPushFresh.Set(0)
+		}
+		return nil
+	}
+	counts := &NodeCounts{Clusters: make(map[string]int, len(s.clusters))}
+	for id, nodes := range s.clusters {
+		counts.Clusters[id] = nodes
+		counts.Total += nodes
+	}
+	counts.Sources = map[string]int{PushSource: counts.Total}
+	return counts
+}
@@ -0,0 +1,87 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPushScraperValidation(t *testing.T) {
+	now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		clusters   map[string]int
+		observedAt time.Time
+		expectErr  bool
+	}{
+		{name: "valid", clusters: map[string]int{"local": 3, "c-1": 10}},
+		{name: "observed a while ago", clusters: map[string]int{"local": 3}, observedAt: now.Add(-time.Minute)},
+		{name: "no clusters", clusters: map[string]int{}, expectErr: true},
+		{name: "empty cluster id", clusters: map[string]int{"": 3}, expectErr: true},
+		{name: "negative count", clusters: map[string]int{"local": -1}, expectErr: true},
+		{name: "implausible count", clusters: map[string]int{"local": maxPushedNodes + 1}, expectErr: true},
+		{name: "observed in the future", clusters: map[string]int{"local": 3}, observedAt: now.Add(time.Hour), expectErr: true},
+		{name: "already stale", clusters: map[string]int{"local": 3}, observedAt: now.Add(-10 * time.Minute), expectErr: true},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			scraper := NewPushScraper(staticScraper{}, 5*time.Minute)
+			scraper.now = func() time.Time { return now }
+			expiresAt, err := scraper.PushNodeCounts(test.clusters, test.observedAt, "tester")
+			if test.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			observedAt := test.observedAt
+			if observedAt.IsZero() {
+				observedAt = now
+			}
+			assert.Equal(t, observedAt.Add(5*time.Minute), expiresAt)
+		})
+	}
+}
+
+func TestPushScraperFallback(t *testing.T) {
+	now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	scraper := NewPushScraper(staticScraper{counts: NodeCounts{Total: 7}}, 5*time.Minute)
+	scraper.now = func() time.Time { return now }
+
+	// pulled until something is pushed
+	counts, err := scraper.ScrapeAndParse(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 7, counts.Total)
+
+	_, err = scraper.PushNodeCounts(map[string]int{"local": 3, "c-1": 10}, time.Time{}, "tester")
+	require.NoError(t, err)
+	counts, err = scraper.ScrapeAndParse(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 13, counts.Total)
+	assert.Equal(t, map[string]int{"local": 3, "c-1": 10}, counts.Clusters)
+	assert.Equal(t, map[string]int{PushSource: 13}, counts.Sources)
+	assert.Equal(t, 1.0, testutil.ToFloat64(NodeCountPushFresh))
+
+	// older counts don't replace newer ones
+	_, err = scraper.PushNodeCounts(map[string]int{"local": 3}, now.Add(-time.Minute), "tester")
+	assert.Error(t, err)
+
+	// pulled again once the push went stale
+	now = now.Add(5 * time.Minute)
+	counts, err = scraper.ScrapeAndParse(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 7, counts.Total)
+	assert.Equal(t, 0.0, testutil.ToFloat64(NodeCountPushFresh))
+
+	// until the next push, which replaces every cluster
+	_, err = scraper.PushNodeCounts(map[string]int{"local": 4}, time.Time{}, "tester")
+	require.NoError(t, err)
+	counts, err = scraper.ScrapeAndParse(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 4, counts.Total)
+	assert.Equal(t, map[string]int{"local": 4}, counts.Clusters)
+}
@@ -0,0 +1,14 @@
+package metrics
+
+// Retain reports that the in-memory collection holds n items and returns how many of its oldest items have to be
+// dropped to keep at most max. Dropped items are counted, so that a bound which is too tight shows in the metrics
+// rather than as silently lost history
+func Retain(collection string, n, max int) int {
+	drop := 0
+	if n > max {
+		drop = n - max
+		RetainedItemsDropped.WithLabelValues(collection).Add(float64(drop))
+	}
+	RetainedItems.WithLabelValues(collection).Set(float64(n - drop))
+	return drop
+}
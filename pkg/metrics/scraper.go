@@ -1,6 +1,8 @@
 package metrics
 
 import (
+	"context"
+
 	"fmt"
 	"net/http"
 	"strings"
@@ -13,7 +15,7 @@ import (
 
 // Scraper defines behavior that a Rancher metrics scraper should implement
 type Scraper interface {
-	ScrapeAndParse() (*NodeCounts, error)
+	ScrapeAndParse(ctx context.Context) (*NodeCounts, error)
 }
 
 type scraper struct {
@@ -38,10 +40,20 @@ const (
 
 type NodeCounts struct {
 	Total int
+	// Clusters holds the node count of each downstream cluster included in Total, by cluster id
+	Clusters map[string]int
+	// FailedClusters are the ids of the clusters which couldn't be counted. They are included in Total with the count
+	// of the last scrape which succeeded for them, if there was one
+	FailedClusters []string
+	// Sources holds the total counted by each source when counts are cross-validated, by source name. Total is the
+	// largest of them
+	Sources map[string]int
+	// Diverged is true if the sources' counts differed by more than the configured threshold
+	Diverged bool
 }
 
-func (s *scraper) ScrapeAndParse() (*NodeCounts, error) {
-	req, err := http.NewRequest(http.MethodGet, s.metricsURL, nil)
+func (s *scraper) ScrapeAndParse(ctx context.Context) (*NodeCounts, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.metricsURL, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -69,6 +81,7 @@ func (s *scraper) ScrapeAndParse() (*NodeCounts, error) {
 	}
 
 	var nodeCount int
+	clusters := map[string]int{}
 	for _, metric := range nodeMetricFamily.GetMetric() {
 		isMetricForLocal, err := isMetricForLocalCluster(metric)
 		clusterNodeCount := int(metric.GetGauge().GetValue())
@@ -79,15 +92,27 @@ func (s *scraper) ScrapeAndParse() (*NodeCounts, error) {
 		}
 		if !isMetricForLocal {
 			nodeCount += clusterNodeCount
+			clusters[getClusterID(metric)] += clusterNodeCount
 		}
 
 	}
 
 	return &NodeCounts{
-		Total: nodeCount,
+		Total:    nodeCount,
+		Clusters: clusters,
 	}, nil
 }
 
+// getClusterID returns the value of the cluster id label of metric, callers must verify that the label is present
+func getClusterID(metric *prometheusClient.Metric) string {
+	for _, label := range metric.GetLabel() {
+		if label.GetName() == clusterNameLabel {
+			return label.GetValue()
+		}
+	}
+	return ""
+}
+
 func isMetricForLocalCluster(metric *prometheusClient.Metric) (bool, error) {
 	for _, label := range metric.GetLabel() {
 		if label.Name != nil && *label.Name == clusterNameLabel {
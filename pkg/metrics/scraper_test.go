@@ -1,6 +1,7 @@
 package metrics
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -119,13 +120,19 @@ func TestScrapeAndParse(t *testing.T) {
 				cli:        &http.Client{},
 				cfg:        config,
 			}
-			res, err := metricsScraper.ScrapeAndParse()
+			res, err := metricsScraper.ScrapeAndParse(context.Background())
 			if test.expectedError {
 				assert.Error(t, err, "expected an error but err was nil")
 			} else {
 				assert.NoError(t, err, "expected no error but there was an error")
 				assert.NotNil(t, res, "expected a result but was nil")
 				assert.Equal(t, test.expectedTotal, res.Total, "did not get expected number of nodes")
+				clusterTotal := 0
+				for clusterID, nodes := range res.Clusters {
+					assert.NotEqual(t, localClusterID, clusterID, "local cluster should not be included in cluster counts")
+					clusterTotal += nodes
+				}
+				assert.Equal(t, res.Total, clusterTotal, "cluster counts should add up to the total")
 			}
 		})
 	}
@@ -0,0 +1,381 @@
+// Package migrate moves the license checkout of an install from an older adapter release, or from another tool which
+// checked out the license, to this adapter in a single operation: the legacy checkouts are checked in, and the
+// licenses they held are checked out again and recorded in the adapter's cache secret, which the adapter adopts on its
+// next compliance check. A migration which fails before it recorded the new checkout is rolled back, so that the
+// install is never left without a checkout covering it
+package migrate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/licensemanager/types"
+	"github.com/google/uuid"
+	"github.com/rancher/csp-adapter/pkg/audit"
+	"github.com/rancher/csp-adapter/pkg/clients/aws"
+	"github.com/rancher/csp-adapter/pkg/entitlement"
+	"github.com/rancher/csp-adapter/pkg/manager"
+	corev1 "k8s.io/api/core/v1"
+	apierror "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// Checkout is a checkout held by the legacy install
+type Checkout struct {
+	ConsumptionToken string `json:"consumptionToken"`
+	Licenses         int    `json:"licenses,omitempty"`
+	// Nodes is the number of nodes the checkout covers, for tools which record nodes rather than licenses. Converted to
+	// Licenses when the migration is planned
+	Nodes  int       `json:"nodes,omitempty"`
+	Expiry time.Time `json:"expiry,omitempty"`
+}
+
+// Inventory is the layout of the file listing the checkouts of another tool
+type Inventory struct {
+	Checkouts []Checkout `json:"checkouts"`
+}
+
+// Source is where the legacy install records its checkouts
+type Source interface {
+	// Checkouts returns the checkouts held by the legacy install
+	Checkouts() ([]Checkout, error)
+	// Restore records replacement as the checkout of the legacy install, when a migration is rolled back after its
+	// checkouts were checked in
+	Restore(replacement manager.CachedCheckout) error
+	String() string
+}
+
+// SecretStore is the cache secret of an older adapter release. k8s.SecretStore implements it
+type SecretStore interface {
+	Get() (*corev1.Secret, error)
+	Update(data map[string]string) error
+	String() string
+}
+
+// FromSecret returns the source of an older adapter release, which recorded its checkout in store. Every release
+// recorded its checkout under the same keys, so the checkout of any release can be migrated
+func FromSecret(store SecretStore) Source {
+	return secretSource{store: store}
+}
+
+type secretSource struct {
+	store SecretStore
+}
+
+func (s secretSource) Checkouts() ([]Checkout, error) {
+	secret, err := s.store.Get()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %w", s.store, err)
+	}
+	cached, err := manager.ReadCachedCheckout(secret.Data)
+	if err != nil {
+		return nil, fmt.Errorf("%s doesn't record a checkout: %w", s.store, err)
+	}
+	return []Checkout{{ConsumptionToken: cached.ConsumptionToken, Licenses: cached.Licenses, Expiry: cached.Expiry}}, nil
+}
+
+// Restore records replacement in the secret, which the older release extends like the checkout it made itself
+func (s secretSource) Restore(replacement manager.CachedCheckout) error {
+	return s.store.Update(replacement.Data())
+}
+
+func (s secretSource) String() string {
+	return s.store.String()
+}
+
+// FromFile returns the source of another tool whose checkouts are listed in the Inventory at path
+func FromFile(path string) Source {
+	return fileSource{path: path}
+}
+
+type fileSource struct {
+	path string
+}
+
+func (s fileSource) Checkouts() ([]Checkout, error) {
+	file, err := os.Open(s.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	decoder := json.NewDecoder(file)
+	// a typo would otherwise silently drop the licenses of a checkout
+	decoder.DisallowUnknownFields()
+	var inventory Inventory
+	if err := decoder.Decode(&inventory); err != nil {
+		return nil, fmt.Errorf("unable to read the inventory %s: %w", s.path, err)
+	}
+	return inventory.Checkouts, nil
+}
+
+// Restore rewrites the inventory with replacement, for the operator to hand over to the other tool
+func (s fileSource) Restore(replacement manager.CachedCheckout) error {
+	data, err := json.MarshalIndent(Inventory{Checkouts: []Checkout{{
+		ConsumptionToken: replacement.ConsumptionToken,
+		Licenses:         replacement.Licenses,
+		Expiry:           replacement.Expiry,
+	}}}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, data, 0600)
+}
+
+func (s fileSource) String() string {
+	return "inventory " + s.path
+}
+
+// Target is the cache secret of this adapter. k8s.Clients implements it
+type Target interface {
+	GetConsumptionTokenSecret() (*corev1.Secret, error)
+	UpdateConsumptionTokenSecret(data map[string]string) error
+}
+
+// ErrCheckoutExists is returned by NewPlan if the adapter already records a checkout, which the migration would replace
+var ErrCheckoutExists = errors.New("the adapter already records a checkout")
+
+// Options configures a migration
+type Options struct {
+	// Licenses is the number of licenses checked out for the adapter, the licenses held by the legacy checkouts if 0
+	Licenses int
+	// Force migrates even though the adapter already records a checkout, which is then left to expire
+	Force bool
+}
+
+// Plan is the steps of a migration, decided before anything is changed so that they can be reviewed
+type Plan struct {
+	Source     string
+	LicenseArn string
+	// Legacy are the checkouts of the legacy install, whose Licenses are always set
+	Legacy []Checkout
+	// LegacyLicenses is the number of licenses held by the legacy checkouts
+	LegacyLicenses int
+	// Licenses is the number of licenses checked out for the adapter
+	Licenses int
+	// Available is the number of licenses which can be checked out while the legacy checkouts are held
+	Available int
+	// CheckInFirst is set if Licenses can't be checked out while the legacy checkouts are held. They're then checked
+	// in first, leaving the install without a checkout until the new one is made
+	CheckInFirst bool
+}
+
+// NewPlan plans the migration of the checkouts of source to target
+func NewPlan(ctx context.Context, client aws.Client, source Source, target Target, opts Options) (Plan, error) {
+	if !opts.Force {
+		secret, err := target.GetConsumptionTokenSecret()
+		if err != nil && !apierror.IsNotFound(err) {
+			return Plan{}, fmt.Errorf("unable to read cache secret: %w", err)
+		}
+		if err == nil {
+			if _, err := manager.ReadCachedCheckout(secret.Data); err == nil {
+				return Plan{}, ErrCheckoutExists
+			}
+		}
+	}
+	legacy, err := source.Checkouts()
+	if err != nil {
+		return Plan{}, err
+	}
+	if len(legacy) == 0 {
+		return Plan{}, fmt.Errorf("%s records no checkouts, there is nothing to migrate", source)
+	}
+	plan := Plan{Source: source.String(), Legacy: make([]Checkout, 0, len(legacy))}
+	var total entitlement.Quantity
+	for i, checkout := range legacy {
+		if checkout.ConsumptionToken == "" {
+			return Plan{}, fmt.Errorf("checkout %d of %s has no consumption token", i+1, source)
+		}
+		licenses, err := checkoutLicenses(checkout)
+		if err != nil {
+			return Plan{}, fmt.Errorf("checkout %d of %s: %w", i+1, source, err)
+		}
+		if total, err = total.Add(licenses); err != nil {
+			return Plan{}, err
+		}
+		if checkout.Licenses, err = licenses.Int(); err != nil {
+			return Plan{}, err
+		}
+		plan.Legacy = append(plan.Legacy, checkout)
+	}
+	if plan.LegacyLicenses, err = total.Int(); err != nil {
+		return Plan{}, err
+	}
+	plan.Licenses = plan.LegacyLicenses
+	if opts.Licenses > 0 {
+		plan.Licenses = opts.Licenses
+	}
+
+	license, err := client.GetRancherLicense(ctx)
+	if err != nil {
+		return Plan{}, fmt.Errorf("unable to get the rancher license: %w", err)
+	}
+	if err := client.ValidateLicense(*license); err != nil {
+		return Plan{}, err
+	}
+	plan.LicenseArn = awssdk.ToString(license.LicenseArn)
+	usage, err := client.GetEntitlementUsage(ctx, *license)
+	if err != nil {
+		return Plan{}, fmt.Errorf("unable to get the usage of license %s: %w", plan.LicenseArn, err)
+	}
+	if plan.Licenses > usage.Max {
+		return Plan{}, fmt.Errorf("license %s grants %d licenses, %d can't be checked out", plan.LicenseArn, usage.Max, plan.Licenses)
+	}
+	plan.Available = usage.Available()
+	plan.CheckInFirst = plan.Licenses > plan.Available
+	return plan, nil
+}
+
+// checkoutLicenses returns the licenses held by checkout, converting the nodes of tools which record nodes
+func checkoutLicenses(checkout Checkout) (entitlement.Quantity, error) {
+	switch {
+	case checkout.Licenses > 0 && checkout.Nodes > 0:
+		return entitlement.Quantity{}, errors.New("only one of licenses and nodes can be set")
+	case checkout.Licenses > 0:
+		return entitlement.Licenses(checkout.Licenses)
+	case checkout.Nodes > 0:
+		return entitlement.ForNodes(checkout.Nodes)
+	default:
+		return entitlement.Quantity{}, errors.New("one of licenses and nodes must be set")
+	}
+}
+
+// Describe writes the steps of the plan to w
+func (p Plan) Describe(w io.Writer) {
+	fmt.Fprintf(w, "migrating %d checkout(s) holding %d license(s) from %s to license %s\n", len(p.Legacy), p.LegacyLicenses, p.Source, p.LicenseArn)
+	fmt.Fprintln(w, "the legacy install must be stopped first, it would otherwise check out its licenses again")
+	if p.CheckInFirst {
+		fmt.Fprintf(w, "  1. check in the %d legacy checkout(s), only %d license(s) are available while they're held\n", len(p.Legacy), p.Available)
+		fmt.Fprintf(w, "  2. check out %d license(s)\n", p.Licenses)
+		fmt.Fprintln(w, "  3. record the checkout in the adapter's cache secret")
+		fmt.Fprintln(w, "if a step fails, the licenses of the legacy checkouts are checked out again and restored to the legacy install")
+		return
+	}
+	fmt.Fprintf(w, "  1. check out %d license(s), %d are available\n", p.Licenses, p.Available)
+	fmt.Fprintln(w, "  2. record the checkout in the adapter's cache secret")
+	fmt.Fprintf(w, "  3. check in the %d legacy checkout(s)\n", len(p.Legacy))
+	fmt.Fprintln(w, "if the checkout can't be recorded, it's checked in and the legacy checkouts are kept")
+}
+
+// Run migrates the checkouts of source to target as planned, writing its progress to out. Fails with the steps which
+// were rolled back, or couldn't be, if the migration fails
+func Run(ctx context.Context, client aws.Client, source Source, target Target, plan Plan, out io.Writer) error {
+	license, err := client.GetRancherLicense(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to get the rancher license, nothing was migrated: %w", err)
+	}
+	m := migration{ctx: ctx, client: client, license: *license, source: source, target: target, out: out}
+	if plan.CheckInFirst {
+		return m.checkInFirst(plan)
+	}
+	return m.checkOutFirst(plan)
+}
+
+// migration is a running migration
+type migration struct {
+	ctx     context.Context
+	client  aws.Client
+	license types.GrantedLicense
+	source  Source
+	target  Target
+	out     io.Writer
+}
+
+// checkOutFirst makes and records the new checkout before checking in the legacy checkouts, so that the install is
+// covered throughout. Once the new checkout is recorded, legacy checkouts which can't be checked in are left to expire
+func (m *migration) checkOutFirst(plan Plan) error {
+	checkout, err := m.checkout(plan.Licenses)
+	if err != nil {
+		return fmt.Errorf("unable to check out %d license(s), nothing was migrated: %w", plan.Licenses, err)
+	}
+	fmt.Fprintf(m.out, "checked out %d license(s)\n", plan.Licenses)
+	if err := m.target.UpdateConsumptionTokenSecret(checkout.Data()); err != nil {
+		return m.rollback(fmt.Errorf("unable to record the checkout: %w", err), &checkout, 0)
+	}
+	fmt.Fprintln(m.out, "recorded the checkout in the adapter's cache secret")
+	tokens := make([]string, 0, len(plan.Legacy))
+	for _, legacy := range plan.Legacy {
+		tokens = append(tokens, legacy.ConsumptionToken)
+	}
+	results := m.client.CheckInRancherLicenses(m.ctx, tokens)
+	var failed []string
+	for _, legacy := range plan.Legacy {
+		if err := results[legacy.ConsumptionToken]; err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", audit.TokenID(legacy.ConsumptionToken), err))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("migrated, but %d legacy checkout(s) couldn't be checked in and stay consumed until they expire: %s",
+			len(failed), strings.Join(failed, "; "))
+	}
+	fmt.Fprintf(m.out, "checked in %d legacy checkout(s)\n", len(plan.Legacy))
+	return nil
+}
+
+// checkInFirst checks in the legacy checkouts before making and recording the new checkout, for licenses which can't
+// hold both at once
+func (m *migration) checkInFirst(plan Plan) error {
+	checkedIn := 0
+	for _, legacy := range plan.Legacy {
+		if _, err := m.client.CheckInRancherLicense(m.ctx, legacy.ConsumptionToken); err != nil {
+			return m.rollback(fmt.Errorf("unable to check in legacy checkout %s: %w", audit.TokenID(legacy.ConsumptionToken), err), nil, checkedIn)
+		}
+		checkedIn += legacy.Licenses
+	}
+	fmt.Fprintf(m.out, "checked in %d legacy checkout(s)\n", len(plan.Legacy))
+	checkout, err := m.checkout(plan.Licenses)
+	if err != nil {
+		return m.rollback(fmt.Errorf("unable to check out %d license(s): %w", plan.Licenses, err), nil, checkedIn)
+	}
+	fmt.Fprintf(m.out, "checked out %d license(s)\n", plan.Licenses)
+	if err := m.target.UpdateConsumptionTokenSecret(checkout.Data()); err != nil {
+		return m.rollback(fmt.Errorf("unable to record the checkout: %w", err), &checkout, checkedIn)
+	}
+	fmt.Fprintln(m.out, "recorded the checkout in the adapter's cache secret")
+	return nil
+}
+
+// checkout checks out licenses. The idempotency token lets License Manager deduplicate retries of the call
+func (m *migration) checkout(licenses int) (manager.CachedCheckout, error) {
+	res, err := m.client.CheckoutRancherLicense(aws.WithCheckoutToken(m.ctx, uuid.New().String()), m.license, licenses)
+	if err != nil {
+		return manager.CachedCheckout{}, err
+	}
+	return manager.NewCachedCheckout(res, licenses), nil
+}
+
+// rollback undoes the steps of a migration which failed with cause: the new checkout, if one was made, is checked in,
+// and the licenses of legacy checkouts which were already checked in are checked out again and restored to the source.
+// Returns cause, with the steps which couldn't be rolled back
+func (m *migration) rollback(cause error, checkout *manager.CachedCheckout, checkedIn int) error {
+	var failed []string
+	if checkout != nil {
+		if _, err := m.client.CheckInRancherLicense(m.ctx, checkout.ConsumptionToken); err != nil {
+			failed = append(failed, fmt.Sprintf("unable to check in the new checkout %s, it stays consumed until %s: %v",
+				audit.TokenID(checkout.ConsumptionToken), checkout.Expiry.Format(time.RFC3339), err))
+		} else {
+			fmt.Fprintln(m.out, "rolled back: checked in the new checkout")
+		}
+	}
+	if checkedIn > 0 {
+		replacement, err := m.checkout(checkedIn)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("unable to check out the %d license(s) of the legacy checkouts again: %v", checkedIn, err))
+		} else if err := m.source.Restore(replacement); err != nil {
+			// the token is needed to record the checkout in the legacy install by hand
+			failed = append(failed, fmt.Sprintf("unable to restore the replacement checkout to %s, record its token %s by hand: %v",
+				m.source, replacement.ConsumptionToken, err))
+		} else {
+			fmt.Fprintf(m.out, "rolled back: restored a checkout of %d license(s) to %s\n", checkedIn, m.source)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%v, and the migration couldn't be rolled back: %s", cause, strings.Join(failed, "; "))
+	}
+	return fmt.Errorf("%w, the migration was rolled back", cause)
+}
@@ -0,0 +1,268 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	lm "github.com/aws/aws-sdk-go-v2/service/licensemanager"
+	"github.com/aws/aws-sdk-go-v2/service/licensemanager/types"
+	"github.com/rancher/csp-adapter/pkg/manager"
+	"github.com/rancher/csp-adapter/pkg/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memorySource holds the checkouts of a legacy install
+type memorySource struct {
+	checkouts  []Checkout
+	restored   *manager.CachedCheckout
+	restoreErr error
+}
+
+func (s *memorySource) Checkouts() ([]Checkout, error) {
+	return s.checkouts, nil
+}
+
+func (s *memorySource) Restore(replacement manager.CachedCheckout) error {
+	if s.restoreErr != nil {
+		return s.restoreErr
+	}
+	s.restored = &replacement
+	return nil
+}
+
+func (s *memorySource) String() string {
+	return "memory"
+}
+
+// failingClient fails the first checkoutErrs checkouts
+type failingClient struct {
+	*mocks.MockAWSClient
+	checkoutErrs int
+}
+
+func (c *failingClient) CheckoutRancherLicense(ctx context.Context, l types.GrantedLicense, entitlementAmt int) (*lm.CheckoutLicenseOutput, error) {
+	if c.checkoutErrs > 0 {
+		c.checkoutErrs--
+		return nil, errors.New("checkout failed")
+	}
+	return c.MockAWSClient.CheckoutRancherLicense(ctx, l, entitlementAmt)
+}
+
+// failingTarget fails to record checkouts
+type failingTarget struct {
+	*mocks.MockK8sClient
+}
+
+func (t failingTarget) UpdateConsumptionTokenSecret(data map[string]string) error {
+	return errors.New("secret can't be written")
+}
+
+// legacyCheckout checks out licenses like a legacy install would
+func legacyCheckout(t *testing.T, client *mocks.MockAWSClient, licenses int) Checkout {
+	t.Helper()
+	res, err := client.CheckoutRancherLicense(context.Background(), client.License, licenses)
+	require.NoError(t, err)
+	return Checkout{ConsumptionToken: *res.LicenseConsumptionToken, Licenses: licenses}
+}
+
+func TestMigrate(t *testing.T) {
+	tests := []struct {
+		name             string
+		maxEntitlements  int
+		legacyLicenses   []int
+		licenses         int
+		checkoutErrs     int
+		recordErr        bool
+		wantCheckInFirst bool
+		wantErr          bool
+		// wantLicenses are the licenses of the recorded checkout, 0 if none is recorded
+		wantLicenses int
+		// wantLegacyHeld is whether the legacy checkouts are still held
+		wantLegacyHeld bool
+		// wantRestored are the licenses of the checkout restored to the source, 0 if none is restored
+		wantRestored int
+	}{
+		{
+			name:            "licenses available while the legacy checkout is held",
+			maxEntitlements: 4,
+			legacyLicenses:  []int{2},
+			wantLicenses:    2,
+		},
+		{
+			name:             "licenses only available once the legacy checkouts are checked in",
+			maxEntitlements:  3,
+			legacyLicenses:   []int{1, 2},
+			wantCheckInFirst: true,
+			wantLicenses:     3,
+		},
+		{
+			name:            "other number of licenses",
+			maxEntitlements: 5,
+			legacyLicenses:  []int{2},
+			licenses:        3,
+			wantLicenses:    3,
+		},
+		{
+			name:            "failed checkout changes nothing",
+			maxEntitlements: 4,
+			legacyLicenses:  []int{2},
+			checkoutErrs:    1,
+			wantErr:         true,
+			wantLegacyHeld:  true,
+		},
+		{
+			name:            "checkout which can't be recorded is checked in",
+			maxEntitlements: 4,
+			legacyLicenses:  []int{2},
+			recordErr:       true,
+			wantErr:         true,
+			wantLegacyHeld:  true,
+		},
+		{
+			name:             "failed checkout after checking in is rolled back",
+			maxEntitlements:  2,
+			legacyLicenses:   []int{2},
+			checkoutErrs:     1,
+			wantCheckInFirst: true,
+			wantErr:          true,
+			wantRestored:     2,
+		},
+		{
+			name:             "unrecorded checkout after checking in is rolled back",
+			maxEntitlements:  2,
+			legacyLicenses:   []int{2},
+			recordErr:        true,
+			wantCheckInFirst: true,
+			wantErr:          true,
+			wantRestored:     2,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			mockAWS := mocks.NewMockAWSClient(test.maxEntitlements)
+			source := &memorySource{}
+			for _, licenses := range test.legacyLicenses {
+				source.checkouts = append(source.checkouts, legacyCheckout(t, mockAWS, licenses))
+			}
+			mockK8s := mocks.NewMockK8sClient(nil)
+			var target Target = mockK8s
+			if test.recordErr {
+				target = failingTarget{mockK8s}
+			}
+
+			plan, err := NewPlan(ctx, mockAWS, source, target, Options{Licenses: test.licenses})
+			require.NoError(t, err)
+			assert.Equal(t, test.wantCheckInFirst, plan.CheckInFirst)
+
+			client := &failingClient{MockAWSClient: mockAWS, checkoutErrs: test.checkoutErrs}
+			err = Run(ctx, client, source, target, plan, io.Discard)
+			if test.wantErr {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+
+			held := 0
+			for _, licenses := range mockAWS.CheckedOutEntitlements {
+				held += licenses
+			}
+			for _, legacy := range source.checkouts {
+				_, ok := mockAWS.CheckedOutEntitlements[legacy.ConsumptionToken]
+				assert.Equal(t, test.wantLegacyHeld, ok, "legacy checkout held")
+			}
+			if test.wantLicenses > 0 {
+				recorded, err := manager.ReadCachedCheckout(secretData(mockK8s.CurrentSecretData))
+				require.NoError(t, err)
+				assert.Equal(t, test.wantLicenses, recorded.Licenses)
+				assert.Equal(t, test.wantLicenses, mockAWS.CheckedOutEntitlements[recorded.ConsumptionToken])
+				assert.Equal(t, test.wantLicenses, held, "only the new checkout is held")
+			} else {
+				assert.Nil(t, mockK8s.CurrentSecretData, "no checkout is recorded")
+			}
+			if test.wantRestored > 0 {
+				require.NotNil(t, source.restored)
+				assert.Equal(t, test.wantRestored, source.restored.Licenses)
+				assert.Equal(t, test.wantRestored, mockAWS.CheckedOutEntitlements[source.restored.ConsumptionToken])
+				assert.Equal(t, test.wantRestored, held, "only the restored checkout is held")
+			} else {
+				assert.Nil(t, source.restored)
+			}
+		})
+	}
+}
+
+func secretData(data map[string]string) map[string][]byte {
+	binData := map[string][]byte{}
+	for key, value := range data {
+		binData[key] = []byte(value)
+	}
+	return binData
+}
+
+func TestFailedRollback(t *testing.T) {
+	ctx := context.Background()
+	mockAWS := mocks.NewMockAWSClient(2)
+	source := &memorySource{restoreErr: errors.New("secret was deleted")}
+	source.checkouts = []Checkout{legacyCheckout(t, mockAWS, 2)}
+	target := mocks.NewMockK8sClient(nil)
+	plan, err := NewPlan(ctx, mockAWS, source, target, Options{})
+	require.NoError(t, err)
+
+	err = Run(ctx, &failingClient{MockAWSClient: mockAWS, checkoutErrs: 1}, source, target, plan, io.Discard)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "couldn't be rolled back")
+	assert.Contains(t, err.Error(), "by hand", "the replacement token is reported for restoring it by hand")
+}
+
+func TestNewPlan(t *testing.T) {
+	ctx := context.Background()
+	mockAWS := mocks.NewMockAWSClient(4)
+	source := &memorySource{checkouts: []Checkout{{ConsumptionToken: "a", Nodes: 35}, {ConsumptionToken: "b", Licenses: 1}}}
+	plan, err := NewPlan(ctx, mockAWS, source, mocks.NewMockK8sClient(nil), Options{})
+	require.NoError(t, err)
+	assert.Equal(t, 3, plan.LegacyLicenses, "nodes are converted to whole licenses")
+	assert.Equal(t, 2, plan.Legacy[0].Licenses)
+	assert.Equal(t, 3, plan.Licenses)
+
+	_, err = NewPlan(ctx, mockAWS, source, mocks.NewMockK8sClient(nil), Options{Licenses: 5})
+	assert.Error(t, err, "more licenses than granted can't be checked out")
+
+	_, err = NewPlan(ctx, mockAWS, &memorySource{}, mocks.NewMockK8sClient(nil), Options{})
+	assert.Error(t, err, "a source without checkouts has nothing to migrate")
+
+	for _, invalid := range []Checkout{{Licenses: 1}, {ConsumptionToken: "a"}, {ConsumptionToken: "a", Licenses: 1, Nodes: 1}} {
+		_, err = NewPlan(ctx, mockAWS, &memorySource{checkouts: []Checkout{invalid}}, mocks.NewMockK8sClient(nil), Options{})
+		assert.Error(t, err, "invalid checkout %+v", invalid)
+	}
+
+	recorded := mocks.NewMockK8sClient(map[string]string{"consumptionToken": "c", "entitledNodes": "1", "expiry": "2022-01-01T00:00:00Z"})
+	_, err = NewPlan(ctx, mockAWS, source, recorded, Options{})
+	assert.True(t, errors.Is(err, ErrCheckoutExists))
+	_, err = NewPlan(ctx, mockAWS, source, recorded, Options{Force: true})
+	assert.NoError(t, err)
+}
+
+func TestFileSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "inventory.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"checkouts": [{"consumptionToken": "a", "nodes": 35}]}`), 0600))
+	source := FromFile(path)
+	checkouts, err := source.Checkouts()
+	require.NoError(t, err)
+	assert.Equal(t, []Checkout{{ConsumptionToken: "a", Nodes: 35}}, checkouts)
+
+	require.NoError(t, source.Restore(manager.CachedCheckout{ConsumptionToken: "b", Licenses: 2}))
+	checkouts, err = source.Checkouts()
+	require.NoError(t, err)
+	assert.Equal(t, []Checkout{{ConsumptionToken: "b", Licenses: 2}}, checkouts, "the replacement is written to the inventory")
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"checkouts": [{"consumptionToken": "a", "node": 35}]}`), 0600))
+	_, err = source.Checkouts()
+	assert.Error(t, err, "unknown fields are rejected")
+}
@@ -4,34 +4,51 @@ import (
 	"context"
 	"fmt"
 	"strconv"
+	"sync"
 	"time"
 
 	lm "github.com/aws/aws-sdk-go-v2/service/licensemanager"
 	"github.com/aws/aws-sdk-go-v2/service/licensemanager/types"
+	"github.com/rancher/csp-adapter/pkg/clients/aws"
+	"github.com/rancher/csp-adapter/pkg/sdk"
 )
 
 type MockAWSClient struct {
 	AWSAccountNumber       string
+	AWSAccountAlias        string
 	License                types.GrantedLicense
 	CheckedOutEntitlements map[string]int
 	CheckoutTokenCtr       int
+	ServiceHealthErr       error
+	// CheckInErr fails every check-in if set
+	CheckInErr error
+	// ExternalEntitlements are consumed by checkouts made outside of the adapter
+	ExternalEntitlements int
+	// ClientTokens maps the idempotency tokens of checkouts to the consumption tokens they returned
+	ClientTokens map[string]string
+	// checkInLock serializes check-ins, which aws.CheckInConcurrently makes concurrently
+	checkInLock sync.Mutex
 }
 
 const (
-	rkeEntitlement = "RKE_NODE_SUPP"
-	fakeAWSAccount = "111111111111"
-	fakeLicenseID  = "l-12345"
+	rkeEntitlement  = "RKE_NODE_SUPP"
+	fakeAWSAccount  = "111111111111"
+	fakeLicenseID   = "l-12345"
+	fakeFingerprint = "aws:294406891311:AWS/Marketplace:issuer-fingerprint"
 )
 
 func NewMockAWSClient(maxEntitlements int) *MockAWSClient {
 	fakeLicenseArn := fmt.Sprintf("arn:aws:license-manager::%s:license:%s", fakeAWSAccount, fakeLicenseID)
 	entitlementName := rkeEntitlement
+	fingerprint := fakeFingerprint
 	// technically not a lossless conversion, but we should never run a test with values this high
 	maxCount := int64(maxEntitlements)
 	return &MockAWSClient{
 		AWSAccountNumber: fakeAWSAccount,
 		License: types.GrantedLicense{
 			LicenseArn: &fakeLicenseArn,
+			Status:     types.LicenseStatusAvailable,
+			Issuer:     &types.IssuerDetails{KeyFingerprint: &fingerprint},
 			Entitlements: []types.Entitlement{{
 				Name:     &entitlementName,
 				Unit:     types.EntitlementUnitCount,
@@ -39,6 +56,7 @@ func NewMockAWSClient(maxEntitlements int) *MockAWSClient {
 			}},
 		},
 		CheckedOutEntitlements: map[string]int{},
+		ClientTokens:           map[string]string{},
 	}
 }
 
@@ -46,6 +64,14 @@ func (m *MockAWSClient) AccountNumber() string {
 	return m.AWSAccountNumber
 }
 
+func (m *MockAWSClient) AccountAlias() string {
+	return m.AWSAccountAlias
+}
+
+func (m *MockAWSClient) RefreshAccountNumber(ctx context.Context) (string, error) {
+	return m.AWSAccountNumber, nil
+}
+
 func (m *MockAWSClient) GetRancherLicense(ctx context.Context) (*types.GrantedLicense, error) {
 	return &m.License, nil
 }
@@ -55,8 +81,22 @@ func (m *MockAWSClient) CheckoutRancherLicense(ctx context.Context, l types.Gran
 		//TODO: Not found aws error mock
 		return nil, fmt.Errorf("license not found")
 	}
+	clientToken := aws.CheckoutTokenFromContext(ctx)
+	if consumptionToken, ok := m.ClientTokens[clientToken]; ok {
+		// repeated checkouts return the original checkout
+		expiryTime := time.Now().Add(1 * time.Hour).Format(time.RFC3339)
+		return &lm.CheckoutLicenseOutput{
+			CheckoutType:            types.CheckoutTypeProvisional,
+			Expiration:              &expiryTime,
+			LicenseArn:              l.LicenseArn,
+			LicenseConsumptionToken: &consumptionToken,
+		}, nil
+	}
 	// remove from checkedIn and append to checkedOut
 	consumptionToken := m.genConsumptionToken()
+	if clientToken != "" {
+		m.ClientTokens[clientToken] = consumptionToken
+	}
 	currentTotal := 0
 	for _, value := range m.CheckedOutEntitlements {
 		currentTotal += value
@@ -85,6 +125,11 @@ func (m *MockAWSClient) CheckoutRancherLicense(ctx context.Context, l types.Gran
 }
 
 func (m *MockAWSClient) CheckInRancherLicense(ctx context.Context, consumptionToken string) (*lm.CheckInLicenseOutput, error) {
+	m.checkInLock.Lock()
+	defer m.checkInLock.Unlock()
+	if m.CheckInErr != nil {
+		return nil, m.CheckInErr
+	}
 	//TODO: not found consumption token aws error mock
 	_, ok := m.CheckedOutEntitlements[consumptionToken]
 	if !ok {
@@ -94,6 +139,10 @@ func (m *MockAWSClient) CheckInRancherLicense(ctx context.Context, consumptionTo
 	return &lm.CheckInLicenseOutput{}, nil
 }
 
+func (m *MockAWSClient) CheckInRancherLicenses(ctx context.Context, tokens []string) map[string]error {
+	return aws.CheckInConcurrently(ctx, m, tokens, aws.DefaultCheckInParallelism)
+}
+
 func (m *MockAWSClient) ExtendRancherLicenseConsumptionToken(ctx context.Context, consumptionToken string) (*lm.ExtendLicenseConsumptionOutput, error) {
 	_, ok := m.CheckedOutEntitlements[consumptionToken]
 	if !ok {
@@ -107,16 +156,43 @@ func (m *MockAWSClient) ExtendRancherLicenseConsumptionToken(ctx context.Context
 }
 
 func (m *MockAWSClient) GetNumberOfAvailableEntitlements(ctx context.Context, license types.GrantedLicense) (int, error) {
-	currentTotal := 0
+	usage, err := m.GetEntitlementUsage(ctx, license)
+	if err != nil {
+		return 0, err
+	}
+	return usage.Available(), nil
+}
+
+func (m *MockAWSClient) GetEntitlementUsage(ctx context.Context, license types.GrantedLicense) (aws.EntitlementUsage, error) {
+	currentTotal := m.ExternalEntitlements
 	for _, value := range m.CheckedOutEntitlements {
 		currentTotal += value
 	}
 	maxEntitlements := m.getMaxRKEEntitlements()
-	remaining := maxEntitlements - currentTotal
-	if remaining < 0 {
-		return 0, fmt.Errorf("over entitlements")
+	if maxEntitlements-currentTotal < 0 {
+		return aws.EntitlementUsage{}, fmt.Errorf("over entitlements")
+	}
+	return aws.EntitlementUsage{Max: maxEntitlements, Consumed: currentTotal, Dimension: rkeEntitlement}, nil
+}
+
+func (m *MockAWSClient) CheckServiceHealth(ctx context.Context) error {
+	return m.ServiceHealthErr
+}
+
+func (m *MockAWSClient) ListProducts(ctx context.Context) ([]sdk.Product, error) {
+	product := sdk.Product{Name: "Rancher"}
+	// mocks built without a license have no product to report
+	if m.License.LicenseArn != nil {
+		product.Found = true
+		product.Active = true
+		product.Matched = true
+		product.LicenseArn = *m.License.LicenseArn
 	}
-	return remaining, nil
+	return []sdk.Product{product}, nil
+}
+
+func (m *MockAWSClient) ValidateLicense(l types.GrantedLicense) error {
+	return aws.ValidateLicense(l, time.Now())
 }
 
 func (m *MockAWSClient) genConsumptionToken() string {
@@ -0,0 +1,10 @@
+package mocks
+
+// Mocks of the adapter's interfaces are generated with moq, so that they follow changes to the interfaces. Run go
+// generate after changing an interface listed here
+//go:generate go run github.com/matryer/moq@v0.6.0 -out zz_generated_awsclient.go -pkg mocks ../clients/aws Client:AWSClientMock
+//go:generate go run github.com/matryer/moq@v0.6.0 -out zz_generated_subscriptionclient.go -pkg mocks ../clients/aws SubscriptionClient
+//go:generate go run github.com/matryer/moq@v0.6.0 -out zz_generated_secretsclient.go -pkg mocks ../clients/aws SecretsClient
+//go:generate go run github.com/matryer/moq@v0.6.0 -out zz_generated_k8sclient.go -pkg mocks ../clients/k8s Client:K8sClientMock
+//go:generate go run github.com/matryer/moq@v0.6.0 -out zz_generated_scraper.go -pkg mocks ../metrics Scraper
+//go:generate go run github.com/matryer/moq@v0.6.0 -out zz_generated_clusternodecounter.go -pkg mocks ../metrics ClusterNodeCounter
@@ -1,6 +1,9 @@
 package mocks
 
 import (
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/clients/k8s"
 	corev1 "k8s.io/api/core/v1"
 	apierror "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime/schema"
@@ -10,8 +13,28 @@ type MockK8sClient struct {
 	CurrentSecretData          map[string]string
 	CurrentSupportConfig       []byte
 	CurrentNotificationMessage string
+	ComplianceSetting          string
+	ComplianceCondition        *ComplianceCondition
 	RancherHostName            string
 	RancherVersion             string
+	ClusterSummaries           map[string][]byte
+	ClusterUID                 string
+	UsageHistory               map[string][]byte
+	Clusters                   map[string]k8s.ClusterInfo
+	FeatureFlags               map[string]string
+	Users                      []k8s.RancherUser
+	AuthProviders              []string
+	NodeCreationTimes          []time.Time
+	Exemptions                 []k8s.Exemption
+	CheckoutRequests           []k8s.CheckoutRequest
+	AdapterConfig              *k8s.AdapterConfig
+}
+
+// ComplianceCondition is the LicenseCompliant condition set on the local cluster
+type ComplianceCondition struct {
+	Compliant bool
+	Reason    string
+	Message   string
 }
 
 func NewMockK8sClient(secretData map[string]string) *MockK8sClient {
@@ -35,9 +58,17 @@ func (m *MockK8sClient) GetConsumptionTokenSecret() (*corev1.Secret, error) {
 	return nil, apierror.NewNotFound(schema.GroupResource{Group: "", Resource: "secret"}, "test-secret")
 }
 
+// UpdateConsumptionTokenSecret merges data into the secret, like the api server merges the StringData of an update
 func (m *MockK8sClient) UpdateConsumptionTokenSecret(data map[string]string) error {
 	// todo: mock error
-	m.CurrentSecretData = data
+	merged := map[string]string{}
+	for key, value := range m.CurrentSecretData {
+		merged[key] = value
+	}
+	for key, value := range data {
+		merged[key] = value
+	}
+	m.CurrentSecretData = merged
 	return nil
 }
 
@@ -61,3 +92,102 @@ func (m *MockK8sClient) GetRancherHostname() (string, error) {
 func (m *MockK8sClient) GetRancherVersion() (string, error) {
 	return m.RancherVersion, nil
 }
+
+func (m *MockK8sClient) UpdateClusterSummary(clusterID string, marshalledData []byte) error {
+	if m.ClusterSummaries == nil {
+		m.ClusterSummaries = map[string][]byte{}
+	}
+	m.ClusterSummaries[clusterID] = marshalledData
+	return nil
+}
+
+func (m *MockK8sClient) UpdateComplianceSetting(value string) error {
+	m.ComplianceSetting = value
+	return nil
+}
+
+func (m *MockK8sClient) UpdateComplianceCondition(compliant bool, reason, message string) error {
+	m.ComplianceCondition = &ComplianceCondition{Compliant: compliant, Reason: reason, Message: message}
+	return nil
+}
+
+func (m *MockK8sClient) GetClusterUID() (string, error) {
+	return m.ClusterUID, nil
+}
+
+func (m *MockK8sClient) GetUsageHistory(month string) ([]byte, error) {
+	return m.UsageHistory[month], nil
+}
+
+func (m *MockK8sClient) UpdateUsageHistory(month string, data []byte) error {
+	if m.UsageHistory == nil {
+		m.UsageHistory = map[string][]byte{}
+	}
+	m.UsageHistory[month] = data
+	return nil
+}
+
+func (m *MockK8sClient) ListUsageHistory() ([]string, error) {
+	var months []string
+	for month := range m.UsageHistory {
+		months = append(months, month)
+	}
+	return months, nil
+}
+
+func (m *MockK8sClient) DeleteUsageHistory(month string) error {
+	delete(m.UsageHistory, month)
+	return nil
+}
+
+func (m *MockK8sClient) GetClusters() (map[string]k8s.ClusterInfo, error) {
+	return m.Clusters, nil
+}
+
+func (m *MockK8sClient) GetFeatureFlags() (map[string]string, error) {
+	return m.FeatureFlags, nil
+}
+
+func (m *MockK8sClient) GetUsers() ([]k8s.RancherUser, error) {
+	return m.Users, nil
+}
+
+func (m *MockK8sClient) GetEnabledAuthProviders() ([]string, error) {
+	return m.AuthProviders, nil
+}
+
+func (m *MockK8sClient) GetNodeCreationTimes() ([]time.Time, error) {
+	return m.NodeCreationTimes, nil
+}
+
+func (m *MockK8sClient) GetExemptions() ([]k8s.Exemption, error) {
+	return m.Exemptions, nil
+}
+
+func (m *MockK8sClient) GetAdapterConfig() (*k8s.AdapterConfig, error) {
+	return m.AdapterConfig, nil
+}
+
+func (m *MockK8sClient) GetCheckoutRequests() ([]k8s.CheckoutRequest, error) {
+	return m.CheckoutRequests, nil
+}
+
+func (m *MockK8sClient) UpdateCheckoutRequestStatus(name string, status k8s.CheckoutRequestStatus) error {
+	for i := range m.CheckoutRequests {
+		if m.CheckoutRequests[i].Name == name {
+			m.CheckoutRequests[i].Status = status
+			return nil
+		}
+	}
+	return apierror.NewNotFound(k8s.CheckoutRequestResource.GroupResource(), name)
+}
+
+func (m *MockK8sClient) DeleteCheckoutRequest(name string) error {
+	for i := range m.CheckoutRequests {
+		if m.CheckoutRequests[i].Name == name {
+			m.CheckoutRequests = append(m.CheckoutRequests[:i], m.CheckoutRequests[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
@@ -1,11 +1,15 @@
 package mocks
 
 import (
+	"context"
+
 	"github.com/rancher/csp-adapter/pkg/metrics"
 )
 
 type MockScraper struct {
-	Nodes int
+	Nodes    int
+	Clusters map[string]int
+	Err      error
 }
 
 func NewMockScraper(numNodes int) *MockScraper {
@@ -14,9 +18,12 @@ func NewMockScraper(numNodes int) *MockScraper {
 	}
 }
 
-func (m *MockScraper) ScrapeAndParse() (*metrics.NodeCounts, error) {
-	// TODO: Error case
+func (m *MockScraper) ScrapeAndParse(context.Context) (*metrics.NodeCounts, error) {
+	if m.Err != nil {
+		return nil, m.Err
+	}
 	return &metrics.NodeCounts{
-		Total: m.Nodes,
+		Total:    m.Nodes,
+		Clusters: m.Clusters,
 	}, nil
 }
@@ -0,0 +1,635 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package mocks
+
+import (
+	"context"
+	lm "github.com/aws/aws-sdk-go-v2/service/licensemanager"
+	"github.com/aws/aws-sdk-go-v2/service/licensemanager/types"
+	"github.com/rancher/csp-adapter/pkg/clients/aws"
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"sync"
+)
+
+// Ensure, that AWSClientMock does implement aws.Client.
+// If this is not the case, regenerate this file with moq.
+var _ aws.Client = &AWSClientMock{}
+
+// AWSClientMock is a mock implementation of aws.Client.
+//
+//	func TestSomethingThatUsesClient(t *testing.T) {
+//
+//		// make and configure a mocked aws.Client
+//		mockedClient := &AWSClientMock{
+//			AccountAliasFunc: func() string {
+//				panic("mock out the AccountAlias method")
+//			},
+//			AccountNumberFunc: func() string {
+//				panic("mock out the AccountNumber method")
+//			},
+//			CheckInRancherLicenseFunc: func(ctx context.Context, consumptionToken string) (*lm.CheckInLicenseOutput, error) {
+//				panic("mock out the CheckInRancherLicense method")
+//			},
+//			CheckInRancherLicensesFunc: func(ctx context.Context, tokens []string) map[string]error {
+//				panic("mock out the CheckInRancherLicenses method")
+//			},
+//			CheckServiceHealthFunc: func(ctx context.Context) error {
+//				panic("mock out the CheckServiceHealth method")
+//			},
+//			CheckoutRancherLicenseFunc: func(ctx context.Context, l types.GrantedLicense, entitlementAmt int) (*lm.CheckoutLicenseOutput, error) {
+//				panic("mock out the CheckoutRancherLicense method")
+//			},
+//			ExtendRancherLicenseConsumptionTokenFunc: func(ctx context.Context, consumptionToken string) (*lm.ExtendLicenseConsumptionOutput, error) {
+//				panic("mock out the ExtendRancherLicenseConsumptionToken method")
+//			},
+//			GetEntitlementUsageFunc: func(ctx context.Context, license types.GrantedLicense) (aws.EntitlementUsage, error) {
+//				panic("mock out the GetEntitlementUsage method")
+//			},
+//			GetNumberOfAvailableEntitlementsFunc: func(ctx context.Context, license types.GrantedLicense) (int, error) {
+//				panic("mock out the GetNumberOfAvailableEntitlements method")
+//			},
+//			GetRancherLicenseFunc: func(ctx context.Context) (*types.GrantedLicense, error) {
+//				panic("mock out the GetRancherLicense method")
+//			},
+//			ListProductsFunc: func(ctx context.Context) ([]sdk.Product, error) {
+//				panic("mock out the ListProducts method")
+//			},
+//			RefreshAccountNumberFunc: func(ctx context.Context) (string, error) {
+//				panic("mock out the RefreshAccountNumber method")
+//			},
+//			ValidateLicenseFunc: func(l types.GrantedLicense) error {
+//				panic("mock out the ValidateLicense method")
+//			},
+//		}
+//
+//		// use mockedClient in code that requires aws.Client
+//		// and then make assertions.
+//
+//	}
+type AWSClientMock struct {
+	// AccountAliasFunc mocks the AccountAlias method.
+	AccountAliasFunc func() string
+
+	// AccountNumberFunc mocks the AccountNumber method.
+	AccountNumberFunc func() string
+
+	// CheckInRancherLicenseFunc mocks the CheckInRancherLicense method.
+	CheckInRancherLicenseFunc func(ctx context.Context, consumptionToken string) (*lm.CheckInLicenseOutput, error)
+
+	// CheckInRancherLicensesFunc mocks the CheckInRancherLicenses method.
+	CheckInRancherLicensesFunc func(ctx context.Context, tokens []string) map[string]error
+
+	// CheckServiceHealthFunc mocks the CheckServiceHealth method.
+	CheckServiceHealthFunc func(ctx context.Context) error
+
+	// CheckoutRancherLicenseFunc mocks the CheckoutRancherLicense method.
+	CheckoutRancherLicenseFunc func(ctx context.Context, l types.GrantedLicense, entitlementAmt int) (*lm.CheckoutLicenseOutput, error)
+
+	// ExtendRancherLicenseConsumptionTokenFunc mocks the ExtendRancherLicenseConsumptionToken method.
+	ExtendRancherLicenseConsumptionTokenFunc func(ctx context.Context, consumptionToken string) (*lm.ExtendLicenseConsumptionOutput, error)
+
+	// GetEntitlementUsageFunc mocks the GetEntitlementUsage method.
+	GetEntitlementUsageFunc func(ctx context.Context, license types.GrantedLicense) (aws.EntitlementUsage, error)
+
+	// GetNumberOfAvailableEntitlementsFunc mocks the GetNumberOfAvailableEntitlements method.
+	GetNumberOfAvailableEntitlementsFunc func(ctx context.Context, license types.GrantedLicense) (int, error)
+
+	// GetRancherLicenseFunc mocks the GetRancherLicense method.
+	GetRancherLicenseFunc func(ctx context.Context) (*types.GrantedLicense, error)
+
+	// ListProductsFunc mocks the ListProducts method.
+	ListProductsFunc func(ctx context.Context) ([]sdk.Product, error)
+
+	// RefreshAccountNumberFunc mocks the RefreshAccountNumber method.
+	RefreshAccountNumberFunc func(ctx context.Context) (string, error)
+
+	// ValidateLicenseFunc mocks the ValidateLicense method.
+	ValidateLicenseFunc func(l types.GrantedLicense) error
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// AccountAlias holds details about calls to the AccountAlias method.
+		AccountAlias []struct {
+		}
+		// AccountNumber holds details about calls to the AccountNumber method.
+		AccountNumber []struct {
+		}
+		// CheckInRancherLicense holds details about calls to the CheckInRancherLicense method.
+		CheckInRancherLicense []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ConsumptionToken is the consumptionToken argument value.
+			ConsumptionToken string
+		}
+		// CheckInRancherLicenses holds details about calls to the CheckInRancherLicenses method.
+		CheckInRancherLicenses []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Tokens is the tokens argument value.
+			Tokens []string
+		}
+		// CheckServiceHealth holds details about calls to the CheckServiceHealth method.
+		CheckServiceHealth []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+		}
+		// CheckoutRancherLicense holds details about calls to the CheckoutRancherLicense method.
+		CheckoutRancherLicense []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// L is the l argument value.
+			L types.GrantedLicense
+			// EntitlementAmt is the entitlementAmt argument value.
+			EntitlementAmt int
+		}
+		// ExtendRancherLicenseConsumptionToken holds details about calls to the ExtendRancherLicenseConsumptionToken method.
+		ExtendRancherLicenseConsumptionToken []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ConsumptionToken is the consumptionToken argument value.
+			ConsumptionToken string
+		}
+		// GetEntitlementUsage holds details about calls to the GetEntitlementUsage method.
+		GetEntitlementUsage []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// License is the license argument value.
+			License types.GrantedLicense
+		}
+		// GetNumberOfAvailableEntitlements holds details about calls to the GetNumberOfAvailableEntitlements method.
+		GetNumberOfAvailableEntitlements []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// License is the license argument value.
+			License types.GrantedLicense
+		}
+		// GetRancherLicense holds details about calls to the GetRancherLicense method.
+		GetRancherLicense []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+		}
+		// ListProducts holds details about calls to the ListProducts method.
+		ListProducts []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+		}
+		// RefreshAccountNumber holds details about calls to the RefreshAccountNumber method.
+		RefreshAccountNumber []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+		}
+		// ValidateLicense holds details about calls to the ValidateLicense method.
+		ValidateLicense []struct {
+			// L is the l argument value.
+			L types.GrantedLicense
+		}
+	}
+	lockAccountAlias                         sync.RWMutex
+	lockAccountNumber                        sync.RWMutex
+	lockCheckInRancherLicense                sync.RWMutex
+	lockCheckInRancherLicenses               sync.RWMutex
+	lockCheckServiceHealth                   sync.RWMutex
+	lockCheckoutRancherLicense               sync.RWMutex
+	lockExtendRancherLicenseConsumptionToken sync.RWMutex
+	lockGetEntitlementUsage                  sync.RWMutex
+	lockGetNumberOfAvailableEntitlements     sync.RWMutex
+	lockGetRancherLicense                    sync.RWMutex
+	lockListProducts                         sync.RWMutex
+	lockRefreshAccountNumber                 sync.RWMutex
+	lockValidateLicense                      sync.RWMutex
+}
+
+// AccountAlias calls AccountAliasFunc.
+func (mock *AWSClientMock) AccountAlias() string {
+	if mock.AccountAliasFunc == nil {
+		panic("AWSClientMock.AccountAliasFunc: method is nil but Client.AccountAlias was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockAccountAlias.Lock()
+	mock.calls.AccountAlias = append(mock.calls.AccountAlias, callInfo)
+	mock.lockAccountAlias.Unlock()
+	return mock.AccountAliasFunc()
+}
+
+// AccountAliasCalls gets all the calls that were made to AccountAlias.
+// Check the length with:
+//
+//	len(mockedClient.AccountAliasCalls())
+func (mock *AWSClientMock) AccountAliasCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockAccountAlias.RLock()
+	calls = mock.calls.AccountAlias
+	mock.lockAccountAlias.RUnlock()
+	return calls
+}
+
+// AccountNumber calls AccountNumberFunc.
+func (mock *AWSClientMock) AccountNumber() string {
+	if mock.AccountNumberFunc == nil {
+		panic("AWSClientMock.AccountNumberFunc: method is nil but Client.AccountNumber was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockAccountNumber.Lock()
+	mock.calls.AccountNumber = append(mock.calls.AccountNumber, callInfo)
+	mock.lockAccountNumber.Unlock()
+	return mock.AccountNumberFunc()
+}
+
+// AccountNumberCalls gets all the calls that were made to AccountNumber.
+// Check the length with:
+//
+//	len(mockedClient.AccountNumberCalls())
+func (mock *AWSClientMock) AccountNumberCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockAccountNumber.RLock()
+	calls = mock.calls.AccountNumber
+	mock.lockAccountNumber.RUnlock()
+	return calls
+}
+
+// CheckInRancherLicense calls CheckInRancherLicenseFunc.
+func (mock *AWSClientMock) CheckInRancherLicense(ctx context.Context, consumptionToken string) (*lm.CheckInLicenseOutput, error) {
+	if mock.CheckInRancherLicenseFunc == nil {
+		panic("AWSClientMock.CheckInRancherLicenseFunc: method is nil but Client.CheckInRancherLicense was just called")
+	}
+	callInfo := struct {
+		Ctx              context.Context
+		ConsumptionToken string
+	}{
+		Ctx:              ctx,
+		ConsumptionToken: consumptionToken,
+	}
+	mock.lockCheckInRancherLicense.Lock()
+	mock.calls.CheckInRancherLicense = append(mock.calls.CheckInRancherLicense, callInfo)
+	mock.lockCheckInRancherLicense.Unlock()
+	return mock.CheckInRancherLicenseFunc(ctx, consumptionToken)
+}
+
+// CheckInRancherLicenseCalls gets all the calls that were made to CheckInRancherLicense.
+// Check the length with:
+//
+//	len(mockedClient.CheckInRancherLicenseCalls())
+func (mock *AWSClientMock) CheckInRancherLicenseCalls() []struct {
+	Ctx              context.Context
+	ConsumptionToken string
+} {
+	var calls []struct {
+		Ctx              context.Context
+		ConsumptionToken string
+	}
+	mock.lockCheckInRancherLicense.RLock()
+	calls = mock.calls.CheckInRancherLicense
+	mock.lockCheckInRancherLicense.RUnlock()
+	return calls
+}
+
+// CheckInRancherLicenses calls CheckInRancherLicensesFunc.
+func (mock *AWSClientMock) CheckInRancherLicenses(ctx context.Context, tokens []string) map[string]error {
+	if mock.CheckInRancherLicensesFunc == nil {
+		panic("AWSClientMock.CheckInRancherLicensesFunc: method is nil but Client.CheckInRancherLicenses was just called")
+	}
+	callInfo := struct {
+		Ctx    context.Context
+		Tokens []string
+	}{
+		Ctx:    ctx,
+		Tokens: tokens,
+	}
+	mock.lockCheckInRancherLicenses.Lock()
+	mock.calls.CheckInRancherLicenses = append(mock.calls.CheckInRancherLicenses, callInfo)
+	mock.lockCheckInRancherLicenses.Unlock()
+	return mock.CheckInRancherLicensesFunc(ctx, tokens)
+}
+
+// CheckInRancherLicensesCalls gets all the calls that were made to CheckInRancherLicenses.
+// Check the length with:
+//
+//	len(mockedClient.CheckInRancherLicensesCalls())
+func (mock *AWSClientMock) CheckInRancherLicensesCalls() []struct {
+	Ctx    context.Context
+	Tokens []string
+} {
+	var calls []struct {
+		Ctx    context.Context
+		Tokens []string
+	}
+	mock.lockCheckInRancherLicenses.RLock()
+	calls = mock.calls.CheckInRancherLicenses
+	mock.lockCheckInRancherLicenses.RUnlock()
+	return calls
+}
+
+// CheckServiceHealth calls CheckServiceHealthFunc.
+func (mock *AWSClientMock) CheckServiceHealth(ctx context.Context) error {
+	if mock.CheckServiceHealthFunc == nil {
+		panic("AWSClientMock.CheckServiceHealthFunc: method is nil but Client.CheckServiceHealth was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+	}{
+		Ctx: ctx,
+	}
+	mock.lockCheckServiceHealth.Lock()
+	mock.calls.CheckServiceHealth = append(mock.calls.CheckServiceHealth, callInfo)
+	mock.lockCheckServiceHealth.Unlock()
+	return mock.CheckServiceHealthFunc(ctx)
+}
+
+// CheckServiceHealthCalls gets all the calls that were made to CheckServiceHealth.
+// Check the length with:
+//
+//	len(mockedClient.CheckServiceHealthCalls())
+func (mock *AWSClientMock) CheckServiceHealthCalls() []struct {
+	Ctx context.Context
+} {
+	var calls []struct {
+		Ctx context.Context
+	}
+	mock.lockCheckServiceHealth.RLock()
+	calls = mock.calls.CheckServiceHealth
+	mock.lockCheckServiceHealth.RUnlock()
+	return calls
+}
+
+// CheckoutRancherLicense calls CheckoutRancherLicenseFunc.
+func (mock *AWSClientMock) CheckoutRancherLicense(ctx context.Context, l types.GrantedLicense, entitlementAmt int) (*lm.CheckoutLicenseOutput, error) {
+	if mock.CheckoutRancherLicenseFunc == nil {
+		panic("AWSClientMock.CheckoutRancherLicenseFunc: method is nil but Client.CheckoutRancherLicense was just called")
+	}
+	callInfo := struct {
+		Ctx            context.Context
+		L              types.GrantedLicense
+		EntitlementAmt int
+	}{
+		Ctx:            ctx,
+		L:              l,
+		EntitlementAmt: entitlementAmt,
+	}
+	mock.lockCheckoutRancherLicense.Lock()
+	mock.calls.CheckoutRancherLicense = append(mock.calls.CheckoutRancherLicense, callInfo)
+	mock.lockCheckoutRancherLicense.Unlock()
+	return mock.CheckoutRancherLicenseFunc(ctx, l, entitlementAmt)
+}
+
+// CheckoutRancherLicenseCalls gets all the calls that were made to CheckoutRancherLicense.
+// Check the length with:
+//
+//	len(mockedClient.CheckoutRancherLicenseCalls())
+func (mock *AWSClientMock) CheckoutRancherLicenseCalls() []struct {
+	Ctx            context.Context
+	L              types.GrantedLicense
+	EntitlementAmt int
+} {
+	var calls []struct {
+		Ctx            context.Context
+		L              types.GrantedLicense
+		EntitlementAmt int
+	}
+	mock.lockCheckoutRancherLicense.RLock()
+	calls = mock.calls.CheckoutRancherLicense
+	mock.lockCheckoutRancherLicense.RUnlock()
+	return calls
+}
+
+// ExtendRancherLicenseConsumptionToken calls ExtendRancherLicenseConsumptionTokenFunc.
+func (mock *AWSClientMock) ExtendRancherLicenseConsumptionToken(ctx context.Context, consumptionToken string) (*lm.ExtendLicenseConsumptionOutput, error) {
+	if mock.ExtendRancherLicenseConsumptionTokenFunc == nil {
+		panic("AWSClientMock.ExtendRancherLicenseConsumptionTokenFunc: method is nil but Client.ExtendRancherLicenseConsumptionToken was just called")
+	}
+	callInfo := struct {
+		Ctx              context.Context
+		ConsumptionToken string
+	}{
+		Ctx:              ctx,
+		ConsumptionToken: consumptionToken,
+	}
+	mock.lockExtendRancherLicenseConsumptionToken.Lock()
+	mock.calls.ExtendRancherLicenseConsumptionToken = append(mock.calls.ExtendRancherLicenseConsumptionToken, callInfo)
+	mock.lockExtendRancherLicenseConsumptionToken.Unlock()
+	return mock.ExtendRancherLicenseConsumptionTokenFunc(ctx, consumptionToken)
+}
+
+// ExtendRancherLicenseConsumptionTokenCalls gets all the calls that were made to ExtendRancherLicenseConsumptionToken.
+// Check the length with:
+//
+//	len(mockedClient.ExtendRancherLicenseConsumptionTokenCalls())
+func (mock *AWSClientMock) ExtendRancherLicenseConsumptionTokenCalls() []struct {
+	Ctx              context.Context
+	ConsumptionToken string
+} {
+	var calls []struct {
+		Ctx              context.Context
+		ConsumptionToken string
+	}
+	mock.lockExtendRancherLicenseConsumptionToken.RLock()
+	calls = mock.calls.ExtendRancherLicenseConsumptionToken
+	mock.lockExtendRancherLicenseConsumptionToken.RUnlock()
+	return calls
+}
+
+// GetEntitlementUsage calls GetEntitlementUsageFunc.
+func (mock *AWSClientMock) GetEntitlementUsage(ctx context.Context, license types.GrantedLicense) (aws.EntitlementUsage, error) {
+	if mock.GetEntitlementUsageFunc == nil {
+		panic("AWSClientMock.GetEntitlementUsageFunc: method is nil but Client.GetEntitlementUsage was just called")
+	}
+	callInfo := struct {
+		Ctx     context.Context
+		License types.GrantedLicense
+	}{
+		Ctx:     ctx,
+		License: license,
+	}
+	mock.lockGetEntitlementUsage.Lock()
+	mock.calls.GetEntitlementUsage = append(mock.calls.GetEntitlementUsage, callInfo)
+	mock.lockGetEntitlementUsage.Unlock()
+	return mock.GetEntitlementUsageFunc(ctx, license)
+}
+
+// GetEntitlementUsageCalls gets all the calls that were made to GetEntitlementUsage.
+// Check the length with:
+//
+//	len(mockedClient.GetEntitlementUsageCalls())
+func (mock *AWSClientMock) GetEntitlementUsageCalls() []struct {
+	Ctx     context.Context
+	License types.GrantedLicense
+} {
+	var calls []struct {
+		Ctx     context.Context
+		License types.GrantedLicense
+	}
+	mock.lockGetEntitlementUsage.RLock()
+	calls = mock.calls.GetEntitlementUsage
+	mock.lockGetEntitlementUsage.RUnlock()
+	return calls
+}
+
+// GetNumberOfAvailableEntitlements calls GetNumberOfAvailableEntitlementsFunc.
+func (mock *AWSClientMock) GetNumberOfAvailableEntitlements(ctx context.Context, license types.GrantedLicense) (int, error) {
+	if mock.GetNumberOfAvailableEntitlementsFunc == nil {
+		panic("AWSClientMock.GetNumberOfAvailableEntitlementsFunc: method is nil but Client.GetNumberOfAvailableEntitlements was just called")
+	}
+	callInfo := struct {
+		Ctx     context.Context
+		License types.GrantedLicense
+	}{
+		Ctx:     ctx,
+		License: license,
+	}
+	mock.lockGetNumberOfAvailableEntitlements.Lock()
+	mock.calls.GetNumberOfAvailableEntitlements = append(mock.calls.GetNumberOfAvailableEntitlements, callInfo)
+	mock.lockGetNumberOfAvailableEntitlements.Unlock()
+	return mock.GetNumberOfAvailableEntitlementsFunc(ctx, license)
+}
+
+// GetNumberOfAvailableEntitlementsCalls gets all the calls that were made to GetNumberOfAvailableEntitlements.
+// Check the length with:
+//
+//	len(mockedClient.GetNumberOfAvailableEntitlementsCalls())
+func (mock *AWSClientMock) GetNumberOfAvailableEntitlementsCalls() []struct {
+	Ctx     context.Context
+	License types.GrantedLicense
+} {
+	var calls []struct {
+		Ctx     context.Context
+		License types.GrantedLicense
+	}
+	mock.lockGetNumberOfAvailableEntitlements.RLock()
+	calls = mock.calls.GetNumberOfAvailableEntitlements
+	mock.lockGetNumberOfAvailableEntitlements.RUnlock()
+	return calls
+}
+
+// GetRancherLicense calls GetRancherLicenseFunc.
+func (mock *AWSClientMock) GetRancherLicense(ctx context.Context) (*types.GrantedLicense, error) {
+	if mock.GetRancherLicenseFunc == nil {
+		panic("AWSClientMock.GetRancherLicenseFunc: method is nil but Client.GetRancherLicense was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+	}{
+		Ctx: ctx,
+	}
+	mock.lockGetRancherLicense.Lock()
+	mock.calls.GetRancherLicense = append(mock.calls.GetRancherLicense, callInfo)
+	mock.lockGetRancherLicense.Unlock()
+	return mock.GetRancherLicenseFunc(ctx)
+}
+
+// GetRancherLicenseCalls gets all the calls that were made to GetRancherLicense.
+// Check the length with:
+//
+//	len(mockedClient.GetRancherLicenseCalls())
+func (mock *AWSClientMock) GetRancherLicenseCalls() []struct {
+	Ctx context.Context
+} {
+	var calls []struct {
+		Ctx context.Context
+	}
+	mock.lockGetRancherLicense.RLock()
+	calls = mock.calls.GetRancherLicense
+	mock.lockGetRancherLicense.RUnlock()
+	return calls
+}
+
+// ListProducts calls ListProductsFunc.
+func (mock *AWSClientMock) ListProducts(ctx context.Context) ([]sdk.Product, error) {
+	if mock.ListProductsFunc == nil {
+		panic("AWSClientMock.ListProductsFunc: method is nil but Client.ListProducts was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+	}{
+		Ctx: ctx,
+	}
+	mock.lockListProducts.Lock()
+	mock.calls.ListProducts = append(mock.calls.ListProducts, callInfo)
+	mock.lockListProducts.Unlock()
+	return mock.ListProductsFunc(ctx)
+}
+
+// ListProductsCalls gets all the calls that were made to ListProducts.
+// Check the length with:
+//
+//	len(mockedClient.ListProductsCalls())
+func (mock *AWSClientMock) ListProductsCalls() []struct {
+	Ctx context.Context
+} {
+	var calls []struct {
+		Ctx context.Context
+	}
+	mock.lockListProducts.RLock()
+	calls = mock.calls.ListProducts
+	mock.lockListProducts.RUnlock()
+	return calls
+}
+
+// RefreshAccountNumber calls RefreshAccountNumberFunc.
+func (mock *AWSClientMock) RefreshAccountNumber(ctx context.Context) (string, error) {
+	if mock.RefreshAccountNumberFunc == nil {
+		panic("AWSClientMock.RefreshAccountNumberFunc: method is nil but Client.RefreshAccountNumber was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+	}{
+		Ctx: ctx,
+	}
+	mock.lockRefreshAccountNumber.Lock()
+	mock.calls.RefreshAccountNumber = append(mock.calls.RefreshAccountNumber, callInfo)
+	mock.lockRefreshAccountNumber.Unlock()
+	return mock.RefreshAccountNumberFunc(ctx)
+}
+
+// RefreshAccountNumberCalls gets all the calls that were made to RefreshAccountNumber.
+// Check the length with:
+//
+//	len(mockedClient.RefreshAccountNumberCalls())
+func (mock *AWSClientMock) RefreshAccountNumberCalls() []struct {
+	Ctx context.Context
+} {
+	var calls []struct {
+		Ctx context.Context
+	}
+	mock.lockRefreshAccountNumber.RLock()
+	calls = mock.calls.RefreshAccountNumber
+	mock.lockRefreshAccountNumber.RUnlock()
+	return calls
+}
+
+// ValidateLicense calls ValidateLicenseFunc.
+func (mock *AWSClientMock) ValidateLicense(l types.GrantedLicense) error {
+	if mock.ValidateLicenseFunc == nil {
+		panic("AWSClientMock.ValidateLicenseFunc: method is nil but Client.ValidateLicense was just called")
+	}
+	callInfo := struct {
+		L types.GrantedLicense
+	}{
+		L: l,
+	}
+	mock.lockValidateLicense.Lock()
+	mock.calls.ValidateLicense = append(mock.calls.ValidateLicense, callInfo)
+	mock.lockValidateLicense.Unlock()
+	return mock.ValidateLicenseFunc(l)
+}
+
+// ValidateLicenseCalls gets all the calls that were made to ValidateLicense.
+// Check the length with:
+//
+//	len(mockedClient.ValidateLicenseCalls())
+func (mock *AWSClientMock) ValidateLicenseCalls() []struct {
+	L types.GrantedLicense
+} {
+	var calls []struct {
+		L types.GrantedLicense
+	}
+	mock.lockValidateLicense.RLock()
+	calls = mock.calls.ValidateLicense
+	mock.lockValidateLicense.RUnlock()
+	return calls
+}
@@ -0,0 +1,126 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package mocks
+
+import (
+	"context"
+	"github.com/rancher/csp-adapter/pkg/metrics"
+	"sync"
+)
+
+// Ensure, that ClusterNodeCounterMock does implement metrics.ClusterNodeCounter.
+// If this is not the case, regenerate this file with moq.
+var _ metrics.ClusterNodeCounter = &ClusterNodeCounterMock{}
+
+// ClusterNodeCounterMock is a mock implementation of metrics.ClusterNodeCounter.
+//
+//	func TestSomethingThatUsesClusterNodeCounter(t *testing.T) {
+//
+//		// make and configure a mocked metrics.ClusterNodeCounter
+//		mockedClusterNodeCounter := &ClusterNodeCounterMock{
+//			CountNodesFunc: func(ctx context.Context, clusterID string) (int, error) {
+//				panic("mock out the CountNodes method")
+//			},
+//			ListClusterIDsFunc: func(ctx context.Context) ([]string, error) {
+//				panic("mock out the ListClusterIDs method")
+//			},
+//		}
+//
+//		// use mockedClusterNodeCounter in code that requires metrics.ClusterNodeCounter
+//		// and then make assertions.
+//
+//	}
+type ClusterNodeCounterMock struct {
+	// CountNodesFunc mocks the CountNodes method.
+	CountNodesFunc func(ctx context.Context, clusterID string) (int, error)
+
+	// ListClusterIDsFunc mocks the ListClusterIDs method.
+	ListClusterIDsFunc func(ctx context.Context) ([]string, error)
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// CountNodes holds details about calls to the CountNodes method.
+		CountNodes []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ClusterID is the clusterID argument value.
+			ClusterID string
+		}
+		// ListClusterIDs holds details about calls to the ListClusterIDs method.
+		ListClusterIDs []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+		}
+	}
+	lockCountNodes     sync.RWMutex
+	lockListClusterIDs sync.RWMutex
+}
+
+// CountNodes calls CountNodesFunc.
+func (mock *ClusterNodeCounterMock) CountNodes(ctx context.Context, clusterID string) (int, error) {
+	if mock.CountNodesFunc == nil {
+		panic("ClusterNodeCounterMock.CountNodesFunc: method is nil but ClusterNodeCounter.CountNodes was just called")
+	}
+	callInfo := struct {
+		Ctx       context.Context
+		ClusterID string
+	}{
+		Ctx:       ctx,
+		ClusterID: clusterID,
+	}
+	mock.lockCountNodes.Lock()
+	mock.calls.CountNodes = append(mock.calls.CountNodes, callInfo)
+	mock.lockCountNodes.Unlock()
+	return mock.CountNodesFunc(ctx, clusterID)
+}
+
+// CountNodesCalls gets all the calls that were made to CountNodes.
+// Check the length with:
+//
+//	len(mockedClusterNodeCounter.CountNodesCalls())
+func (mock *ClusterNodeCounterMock) CountNodesCalls() []struct {
+	Ctx       context.Context
+	ClusterID string
+} {
+	var calls []struct {
+		Ctx       context.Context
+		ClusterID string
+	}
+	mock.lockCountNodes.RLock()
+	calls = mock.calls.CountNodes
+	mock.lockCountNodes.RUnlock()
+	return calls
+}
+
+// ListClusterIDs calls ListClusterIDsFunc.
+func (mock *ClusterNodeCounterMock) ListClusterIDs(ctx context.Context) ([]string, error) {
+	if mock.ListClusterIDsFunc == nil {
+		panic("ClusterNodeCounterMock.ListClusterIDsFunc: method is nil but ClusterNodeCounter.ListClusterIDs was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+	}{
+		Ctx: ctx,
+	}
+	mock.lockListClusterIDs.Lock()
+	mock.calls.ListClusterIDs = append(mock.calls.ListClusterIDs, callInfo)
+	mock.lockListClusterIDs.Unlock()
+	return mock.ListClusterIDsFunc(ctx)
+}
+
+// ListClusterIDsCalls gets all the calls that were made to ListClusterIDs.
+// Check the length with:
+//
+//	len(mockedClusterNodeCounter.ListClusterIDsCalls())
+func (mock *ClusterNodeCounterMock) ListClusterIDsCalls() []struct {
+	Ctx context.Context
+} {
+	var calls []struct {
+		Ctx context.Context
+	}
+	mock.lockListClusterIDs.RLock()
+	calls = mock.calls.ListClusterIDs
+	mock.lockListClusterIDs.RUnlock()
+	return calls
+}
@@ -0,0 +1,1034 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package mocks
+
+import (
+	"github.com/rancher/csp-adapter/pkg/clients/k8s"
+	corev1 "k8s.io/api/core/v1"
+	"sync"
+	"time"
+)
+
+// Ensure, that K8sClientMock does implement k8s.Client.
+// If this is not the case, regenerate this file with moq.
+var _ k8s.Client = &K8sClientMock{}
+
+// K8sClientMock is a mock implementation of k8s.Client.
+//
+//	func TestSomethingThatUsesClient(t *testing.T) {
+//
+//		// make and configure a mocked k8s.Client
+//		mockedClient := &K8sClientMock{
+//			DeleteCheckoutRequestFunc: func(name string) error {
+//				panic("mock out the DeleteCheckoutRequest method")
+//			},
+//			DeleteUsageHistoryFunc: func(month string) error {
+//				panic("mock out the DeleteUsageHistory method")
+//			},
+//			GetAdapterConfigFunc: func() (*k8s.AdapterConfig, error) {
+//				panic("mock out the GetAdapterConfig method")
+//			},
+//			GetCheckoutRequestsFunc: func() ([]k8s.CheckoutRequest, error) {
+//				panic("mock out the GetCheckoutRequests method")
+//			},
+//			GetClusterUIDFunc: func() (string, error) {
+//				panic("mock out the GetClusterUID method")
+//			},
+//			GetClustersFunc: func() (map[string]k8s.ClusterInfo, error) {
+//				panic("mock out the GetClusters method")
+//			},
+//			GetConsumptionTokenSecretFunc: func() (*corev1.Secret, error) {
+//				panic("mock out the GetConsumptionTokenSecret method")
+//			},
+//			GetEnabledAuthProvidersFunc: func() ([]string, error) {
+//				panic("mock out the GetEnabledAuthProviders method")
+//			},
+//			GetExemptionsFunc: func() ([]k8s.Exemption, error) {
+//				panic("mock out the GetExemptions method")
+//			},
+//			GetFeatureFlagsFunc: func() (map[string]string, error) {
+//				panic("mock out the GetFeatureFlags method")
+//			},
+//			GetNodeCreationTimesFunc: func() ([]time.Time, error) {
+//				panic("mock out the GetNodeCreationTimes method")
+//			},
+//			GetRancherHostnameFunc: func() (string, error) {
+//				panic("mock out the GetRancherHostname method")
+//			},
+//			GetRancherVersionFunc: func() (string, error) {
+//				panic("mock out the GetRancherVersion method")
+//			},
+//			GetUsageHistoryFunc: func(month string) ([]byte, error) {
+//				panic("mock out the GetUsageHistory method")
+//			},
+//			GetUsersFunc: func() ([]k8s.RancherUser, error) {
+//				panic("mock out the GetUsers method")
+//			},
+//			ListUsageHistoryFunc: func() ([]string, error) {
+//				panic("mock out the ListUsageHistory method")
+//			},
+//			UpdateCSPConfigOutputFunc: func(marshalledData []byte) error {
+//				panic("mock out the UpdateCSPConfigOutput method")
+//			},
+//			UpdateCheckoutRequestStatusFunc: func(name string, status k8s.CheckoutRequestStatus) error {
+//				panic("mock out the UpdateCheckoutRequestStatus method")
+//			},
+//			UpdateClusterSummaryFunc: func(clusterID string, marshalledData []byte) error {
+//				panic("mock out the UpdateClusterSummary method")
+//			},
+//			UpdateComplianceConditionFunc: func(compliant bool, reason string, message string) error {
+//				panic("mock out the UpdateComplianceCondition method")
+//			},
+//			UpdateComplianceSettingFunc: func(value string) error {
+//				panic("mock out the UpdateComplianceSetting method")
+//			},
+//			UpdateConsumptionTokenSecretFunc: func(data map[string]string) error {
+//				panic("mock out the UpdateConsumptionTokenSecret method")
+//			},
+//			UpdateUsageHistoryFunc: func(month string, data []byte) error {
+//				panic("mock out the UpdateUsageHistory method")
+//			},
+//			UpdateUserNotificationFunc: func(isInCompliance bool, message string) error {
+//				panic("mock out the UpdateUserNotification method")
+//			},
+//		}
+//
+//		// use mockedClient in code that requires k8s.Client
+//		// and then make assertions.
+//
+//	}
+type K8sClientMock struct {
+	// DeleteCheckoutRequestFunc mocks the DeleteCheckoutRequest method.
+	DeleteCheckoutRequestFunc func(name string) error
+
+	// DeleteUsageHistoryFunc mocks the DeleteUsageHistory method.
+	DeleteUsageHistoryFunc func(month string) error
+
+	// GetAdapterConfigFunc mocks the GetAdapterConfig method.
+	GetAdapterConfigFunc func() (*k8s.AdapterConfig, error)
+
+	// GetCheckoutRequestsFunc mocks the GetCheckoutRequests method.
+	GetCheckoutRequestsFunc func() ([]k8s.CheckoutRequest, error)
+
+	// GetClusterUIDFunc mocks the GetClusterUID method.
+	GetClusterUIDFunc func() (string, error)
+
+	// GetClustersFunc mocks the GetClusters method.
+	GetClustersFunc func() (map[string]k8s.ClusterInfo, error)
+
+	// GetConsumptionTokenSecretFunc mocks the GetConsumptionTokenSecret method.
+	GetConsumptionTokenSecretFunc func() (*corev1.Secret, error)
+
+	// GetEnabledAuthProvidersFunc mocks the GetEnabledAuthProviders method.
+	GetEnabledAuthProvidersFunc func() ([]string, error)
+
+	// GetExemptionsFunc mocks the GetExemptions method.
+	GetExemptionsFunc func() ([]k8s.Exemption, error)
+
+	// GetFeatureFlagsFunc mocks the GetFeatureFlags method.
+	GetFeatureFlagsFunc func() (map[string]string, error)
+
+	// GetNodeCreationTimesFunc mocks the GetNodeCreationTimes method.
+	GetNodeCreationTimesFunc func() ([]time.Time, error)
+
+	// GetRancherHostnameFunc mocks the GetRancherHostname method.
+	GetRancherHostnameFunc func() (string, error)
+
+	// GetRancherVersionFunc mocks the GetRancherVersion method.
+	GetRancherVersionFunc func() (string, error)
+
+	// GetUsageHistoryFunc mocks the GetUsageHistory method.
+	GetUsageHistoryFunc func(month string) ([]byte, error)
+
+	// GetUsersFunc mocks the GetUsers method.
+	GetUsersFunc func() ([]k8s.RancherUser, error)
+
+	// ListUsageHistoryFunc mocks the ListUsageHistory method.
+	ListUsageHistoryFunc func() ([]string, error)
+
+	// UpdateCSPConfigOutputFunc mocks the UpdateCSPConfigOutput method.
+	UpdateCSPConfigOutputFunc func(marshalledData []byte) error
+
+	// UpdateCheckoutRequestStatusFunc mocks the UpdateCheckoutRequestStatus method.
+	UpdateCheckoutRequestStatusFunc func(name string, status k8s.CheckoutRequestStatus) error
+
+	// UpdateClusterSummaryFunc mocks the UpdateClusterSummary method.
+	UpdateClusterSummaryFunc func(clusterID string, marshalledData []byte) error
+
+	// UpdateComplianceConditionFunc mocks the UpdateComplianceCondition method.
+	UpdateComplianceConditionFunc func(compliant bool, reason string, message string) error
+
+	// UpdateComplianceSettingFunc mocks the UpdateComplianceSetting method.
+	UpdateComplianceSettingFunc func(value string) error
+
+	// UpdateConsumptionTokenSecretFunc mocks the UpdateConsumptionTokenSecret method.
+	UpdateConsumptionTokenSecretFunc func(data map[string]string) error
+
+	// UpdateUsageHistoryFunc mocks the UpdateUsageHistory method.
+	UpdateUsageHistoryFunc func(month string, data []byte) error
+
+	// UpdateUserNotificationFunc mocks the UpdateUserNotification method.
+	UpdateUserNotificationFunc func(isInCompliance bool, message string) error
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// DeleteCheckoutRequest holds details about calls to the DeleteCheckoutRequest method.
+		DeleteCheckoutRequest []struct {
+			// Name is the name argument value.
+			Name string
+		}
+		// DeleteUsageHistory holds details about calls to the DeleteUsageHistory method.
+		DeleteUsageHistory []struct {
+			// Month is the month argument value.
+			Month string
+		}
+		// GetAdapterConfig holds details about calls to the GetAdapterConfig method.
+		GetAdapterConfig []struct {
+		}
+		// GetCheckoutRequests holds details about calls to the GetCheckoutRequests method.
+		GetCheckoutRequests []struct {
+		}
+		// GetClusterUID holds details about calls to the GetClusterUID method.
+		GetClusterUID []struct {
+		}
+		// GetClusters holds details about calls to the GetClusters method.
+		GetClusters []struct {
+		}
+		// GetConsumptionTokenSecret holds details about calls to the GetConsumptionTokenSecret method.
+		GetConsumptionTokenSecret []struct {
+		}
+		// GetEnabledAuthProviders holds details about calls to the GetEnabledAuthProviders method.
+		GetEnabledAuthProviders []struct {
+		}
+		// GetExemptions holds details about calls to the GetExemptions method.
+		GetExemptions []struct {
+		}
+		// GetFeatureFlags holds details about calls to the GetFeatureFlags method.
+		GetFeatureFlags []struct {
+		}
+		// GetNodeCreationTimes holds details about calls to the GetNodeCreationTimes method.
+		GetNodeCreationTimes []struct {
+		}
+		// GetRancherHostname holds details about calls to the GetRancherHostname method.
+		GetRancherHostname []struct {
+		}
+		// GetRancherVersion holds details about calls to the GetRancherVersion method.
+		GetRancherVersion []struct {
+		}
+		// GetUsageHistory holds details about calls to the GetUsageHistory method.
+		GetUsageHistory []struct {
+			// Month is the month argument value.
+			Month string
+		}
+		// GetUsers holds details about calls to the GetUsers method.
+		GetUsers []struct {
+		}
+		// ListUsageHistory holds details about calls to the ListUsageHistory method.
+		ListUsageHistory []struct {
+		}
+		// UpdateCSPConfigOutput holds details about calls to the UpdateCSPConfigOutput method.
+		UpdateCSPConfigOutput []struct {
+			// MarshalledData is the marshalledData argument value.
+			MarshalledData []byte
+		}
+		// UpdateCheckoutRequestStatus holds details about calls to the UpdateCheckoutRequestStatus method.
+		UpdateCheckoutRequestStatus []struct {
+			// Name is the name argument value.
+			Name string
+			// Status is the status argument value.
+			Status k8s.CheckoutRequestStatus
+		}
+		// UpdateClusterSummary holds details about calls to the UpdateClusterSummary method.
+		UpdateClusterSummary []struct {
+			// ClusterID is the clusterID argument value.
+			ClusterID string
+			// MarshalledData is the marshalledData argument value.
+			MarshalledData []byte
+		}
+		// UpdateComplianceCondition holds details about calls to the UpdateComplianceCondition method.
+		UpdateComplianceCondition []struct {
+			// Compliant is the compliant argument value.
+			Compliant bool
+			// Reason is the reason argument value.
+			Reason string
+			// Message is the message argument value.
+			Message string
+		}
+		// UpdateComplianceSetting holds details about calls to the UpdateComplianceSetting method.
+		UpdateComplianceSetting []struct {
+			// Value is the value argument value.
+			Value string
+		}
+		// UpdateConsumptionTokenSecret holds details about calls to the UpdateConsumptionTokenSecret method.
+		UpdateConsumptionTokenSecret []struct {
+			// Data is the data argument value.
+			Data map[string]string
+		}
+		// UpdateUsageHistory holds details about calls to the UpdateUsageHistory method.
+		UpdateUsageHistory []struct {
+			// Month is the month argument value.
+			Month string
+			// Data is the data argument value.
+			Data []byte
+		}
+		// UpdateUserNotification holds details about calls to the UpdateUserNotification method.
+		UpdateUserNotification []struct {
+			// IsInCompliance is the isInCompliance argument value.
+			IsInCompliance bool
+			// Message is the message argument value.
+			Message string
+		}
+	}
+	lockDeleteCheckoutRequest        sync.RWMutex
+	lockDeleteUsageHistory           sync.RWMutex
+	lockGetAdapterConfig             sync.RWMutex
+	lockGetCheckoutRequests          sync.RWMutex
+	lockGetClusterUID                sync.RWMutex
+	lockGetClusters                  sync.RWMutex
+	lockGetConsumptionTokenSecret    sync.RWMutex
+	lockGetEnabledAuthProviders      sync.RWMutex
+	lockGetExemptions                sync.RWMutex
+	lockGetFeatureFlags              sync.RWMutex
+	lockGetNodeCreationTimes         sync.RWMutex
+	lockGetRancherHostname           sync.RWMutex
+	lockGetRancherVersion            sync.RWMutex
+	lockGetUsageHistory              sync.RWMutex
+	lockGetUsers                     sync.RWMutex
+	lockListUsageHistory             sync.RWMutex
+	lockUpdateCSPConfigOutput        sync.RWMutex
+	lockUpdateCheckoutRequestStatus  sync.RWMutex
+	lockUpdateClusterSummary         sync.RWMutex
+	lockUpdateComplianceCondition    sync.RWMutex
+	lockUpdateComplianceSetting      sync.RWMutex
+	lockUpdateConsumptionTokenSecret sync.RWMutex
+	lockUpdateUsageHistory           sync.RWMutex
+	lockUpdateUserNotification       sync.RWMutex
+}
+
+// DeleteCheckoutRequest calls DeleteCheckoutRequestFunc.
+func (mock *K8sClientMock) DeleteCheckoutRequest(name string) error {
+	if mock.DeleteCheckoutRequestFunc == nil {
+		panic("K8sClientMock.DeleteCheckoutRequestFunc: method is nil but Client.DeleteCheckoutRequest was just called")
+	}
+	callInfo := struct {
+		Name string
+	}{
+		Name: name,
+	}
+	mock.lockDeleteCheckoutRequest.Lock()
+	mock.calls.DeleteCheckoutRequest = append(mock.calls.DeleteCheckoutRequest, callInfo)
+	mock.lockDeleteCheckoutRequest.Unlock()
+	return mock.DeleteCheckoutRequestFunc(name)
+}
+
+// DeleteCheckoutRequestCalls gets all the calls that were made to DeleteCheckoutRequest.
+// Check the length with:
+//
+//	len(mockedClient.DeleteCheckoutRequestCalls())
+func (mock *K8sClientMock) DeleteCheckoutRequestCalls() []struct {
+	Name string
+} {
+	var calls []struct {
+		Name string
+	}
+	mock.lockDeleteCheckoutRequest.RLock()
+	calls = mock.calls.DeleteCheckoutRequest
+	mock.lockDeleteCheckoutRequest.RUnlock()
+	return calls
+}
+
+// DeleteUsageHistory calls DeleteUsageHistoryFunc.
+func (mock *K8sClientMock) DeleteUsageHistory(month string) error {
+	if mock.DeleteUsageHistoryFunc == nil {
+		panic("K8sClientMock.DeleteUsageHistoryFunc: method is nil but Client.DeleteUsageHistory was just called")
+	}
+	callInfo := struct {
+		Month string
+	}{
+		Month: month,
+	}
+	mock.lockDeleteUsageHistory.Lock()
+	mock.calls.DeleteUsageHistory = append(mock.calls.DeleteUsageHistory, callInfo)
+	mock.lockDeleteUsageHistory.Unlock()
+	return mock.DeleteUsageHistoryFunc(month)
+}
+
+// DeleteUsageHistoryCalls gets all the calls that were made to DeleteUsageHistory.
+// Check the length with:
+//
+//	len(mockedClient.DeleteUsageHistoryCalls())
+func (mock *K8sClientMock) DeleteUsageHistoryCalls() []struct {
+	Month string
+} {
+	var calls []struct {
+		Month string
+	}
+	mock.lockDeleteUsageHistory.RLock()
+	calls = mock.calls.DeleteUsageHistory
+	mock.lockDeleteUsageHistory.RUnlock()
+	return calls
+}
+
+// GetAdapterConfig calls GetAdapterConfigFunc.
+func (mock *K8sClientMock) GetAdapterConfig() (*k8s.AdapterConfig, error) {
+	if mock.GetAdapterConfigFunc == nil {
+		panic("K8sClientMock.GetAdapterConfigFunc: method is nil but Client.GetAdapterConfig was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockGetAdapterConfig.Lock()
+	mock.calls.GetAdapterConfig = append(mock.calls.GetAdapterConfig, callInfo)
+	mock.lockGetAdapterConfig.Unlock()
+	return mock.GetAdapterConfigFunc()
+}
+
+// GetAdapterConfigCalls gets all the calls that were made to GetAdapterConfig.
+// Check the length with:
+//
+//	len(mockedClient.GetAdapterConfigCalls())
+func (mock *K8sClientMock) GetAdapterConfigCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockGetAdapterConfig.RLock()
+	calls = mock.calls.GetAdapterConfig
+	mock.lockGetAdapterConfig.RUnlock()
+	return calls
+}
+
+// GetCheckoutRequests calls GetCheckoutRequestsFunc.
+func (mock *K8sClientMock) GetCheckoutRequests() ([]k8s.CheckoutRequest, error) {
+	if mock.GetCheckoutRequestsFunc == nil {
+		panic("K8sClientMock.GetCheckoutRequestsFunc: method is nil but Client.GetCheckoutRequests was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockGetCheckoutRequests.Lock()
+	mock.calls.GetCheckoutRequests = append(mock.calls.GetCheckoutRequests, callInfo)
+	mock.lockGetCheckoutRequests.Unlock()
+	return mock.GetCheckoutRequestsFunc()
+}
+
+// GetCheckoutRequestsCalls gets all the calls that were made to GetCheckoutRequests.
+// Check the length with:
+//
+//	len(mockedClient.GetCheckoutRequestsCalls())
+func (mock *K8sClientMock) GetCheckoutRequestsCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockGetCheckoutRequests.RLock()
+	calls = mock.calls.GetCheckoutRequests
+	mock.lockGetCheckoutRequests.RUnlock()
+	return calls
+}
+
+// GetClusterUID calls GetClusterUIDFunc.
+func (mock *K8sClientMock) GetClusterUID() (string, error) {
+	if mock.GetClusterUIDFunc == nil {
+		panic("K8sClientMock.GetClusterUIDFunc: method is nil but Client.GetClusterUID was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockGetClusterUID.Lock()
+	mock.calls.GetClusterUID = append(mock.calls.GetClusterUID, callInfo)
+	mock.lockGetClusterUID.Unlock()
+	return mock.GetClusterUIDFunc()
+}
+
+// GetClusterUIDCalls gets all the calls that were made to GetClusterUID.
+// Check the length with:
+//
+//	len(mockedClient.GetClusterUIDCalls())
+func (mock *K8sClientMock) GetClusterUIDCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockGetClusterUID.RLock()
+	calls = mock.calls.GetClusterUID
+	mock.lockGetClusterUID.RUnlock()
+	return calls
+}
+
+// GetClusters calls GetClustersFunc.
+func (mock *K8sClientMock) GetClusters() (map[string]k8s.ClusterInfo, error) {
+	if mock.GetClustersFunc == nil {
+		panic("K8sClientMock.GetClustersFunc: method is nil but Client.GetClusters was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockGetClusters.Lock()
+	mock.calls.GetClusters = append(mock.calls.GetClusters, callInfo)
+	mock.lockGetClusters.Unlock()
+	return mock.GetClustersFunc()
+}
+
+// GetClustersCalls gets all the calls that were made to GetClusters.
+// Check the length with:
+//
+//	len(mockedClient.GetClustersCalls())
+func (mock *K8sClientMock) GetClustersCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockGetClusters.RLock()
+	calls = mock.calls.GetClusters
+	mock.lockGetClusters.RUnlock()
+	return calls
+}
+
+// GetConsumptionTokenSecret calls GetConsumptionTokenSecretFunc.
+func (mock *K8sClientMock) GetConsumptionTokenSecret() (*corev1.Secret, error) {
+	if mock.GetConsumptionTokenSecretFunc == nil {
+		panic("K8sClientMock.GetConsumptionTokenSecretFunc: method is nil but Client.GetConsumptionTokenSecret was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockGetConsumptionTokenSecret.Lock()
+	mock.calls.GetConsumptionTokenSecret = append(mock.calls.GetConsumptionTokenSecret, callInfo)
+	mock.lockGetConsumptionTokenSecret.Unlock()
+	return mock.GetConsumptionTokenSecretFunc()
+}
+
+// GetConsumptionTokenSecretCalls gets all the calls that were made to GetConsumptionTokenSecret.
+// Check the length with:
+//
+//	len(mockedClient.GetConsumptionTokenSecretCalls())
+func (mock *K8sClientMock) GetConsumptionTokenSecretCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockGetConsumptionTokenSecret.RLock()
+	calls = mock.calls.GetConsumptionTokenSecret
+	mock.lockGetConsumptionTokenSecret.RUnlock()
+	return calls
+}
+
+// GetEnabledAuthProviders calls GetEnabledAuthProvidersFunc.
+func (mock *K8sClientMock) GetEnabledAuthProviders() ([]string, error) {
+	if mock.GetEnabledAuthProvidersFunc == nil {
+		panic("K8sClientMock.GetEnabledAuthProvidersFunc: method is nil but Client.GetEnabledAuthProviders was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockGetEnabledAuthProviders.Lock()
+	mock.calls.GetEnabledAuthProviders = append(mock.calls.GetEnabledAuthProviders, callInfo)
+	mock.lockGetEnabledAuthProviders.Unlock()
+	return mock.GetEnabledAuthProvidersFunc()
+}
+
+// GetEnabledAuthProvidersCalls gets all the calls that were made to GetEnabledAuthProviders.
+// Check the length with:
+//
+//	len(mockedClient.GetEnabledAuthProvidersCalls())
+func (mock *K8sClientMock) GetEnabledAuthProvidersCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockGetEnabledAuthProviders.RLock()
+	calls = mock.calls.GetEnabledAuthProviders
+	mock.lockGetEnabledAuthProviders.RUnlock()
+	return calls
+}
+
+// GetExemptions calls GetExemptionsFunc.
+func (mock *K8sClientMock) GetExemptions() ([]k8s.Exemption, error) {
+	if mock.GetExemptionsFunc == nil {
+		panic("K8sClientMock.GetExemptionsFunc: method is nil but Client.GetExemptions was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockGetExemptions.Lock()
+	mock.calls.GetExemptions = append(mock.calls.GetExemptions, callInfo)
+	mock.lockGetExemptions.Unlock()
+	return mock.GetExemptionsFunc()
+}
+
+// GetExemptionsCalls gets all the calls that were made to GetExemptions.
+// Check the length with:
+//
+//	len(mockedClient.GetExemptionsCalls())
+func (mock *K8sClientMock) GetExemptionsCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockGetExemptions.RLock()
+	calls = mock.calls.GetExemptions
+	mock.lockGetExemptions.RUnlock()
+	return calls
+}
+
+// GetFeatureFlags calls GetFeatureFlagsFunc.
+func (mock *K8sClientMock) GetFeatureFlags() (map[string]string, error) {
+	if mock.GetFeatureFlagsFunc == nil {
+		panic("K8sClientMock.GetFeatureFlagsFunc: method is nil but Client.GetFeatureFlags was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockGetFeatureFlags.Lock()
+	mock.calls.GetFeatureFlags = append(mock.calls.GetFeatureFlags, callInfo)
+	mock.lockGetFeatureFlags.Unlock()
+	return mock.GetFeatureFlagsFunc()
+}
+
+// GetFeatureFlagsCalls gets all the calls that were made to GetFeatureFlags.
+// Check the length with:
+//
+//	len(mockedClient.GetFeatureFlagsCalls())
+func (mock *K8sClientMock) GetFeatureFlagsCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockGetFeatureFlags.RLock()
+	calls = mock.calls.GetFeatureFlags
+	mock.lockGetFeatureFlags.RUnlock()
+	return calls
+}
+
+// GetNodeCreationTimes calls GetNodeCreationTimesFunc.
+func (mock *K8sClientMock) GetNodeCreationTimes() ([]time.Time, error) {
+	if mock.GetNodeCreationTimesFunc == nil {
+		panic("K8sClientMock.GetNodeCreationTimesFunc: method is nil but Client.GetNodeCreationTimes was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockGetNodeCreationTimes.Lock()
+	mock.calls.GetNodeCreationTimes = append(mock.calls.GetNodeCreationTimes, callInfo)
+	mock.lockGetNodeCreationTimes.Unlock()
+	return mock.GetNodeCreationTimesFunc()
+}
+
+// GetNodeCreationTimesCalls gets all the calls that were made to GetNodeCreationTimes.
+// Check the length with:
+//
+//	len(mockedClient.GetNodeCreationTimesCalls())
+func (mock *K8sClientMock) GetNodeCreationTimesCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockGetNodeCreationTimes.RLock()
+	calls = mock.calls.GetNodeCreationTimes
+	mock.lockGetNodeCreationTimes.RUnlock()
+	return calls
+}
+
+// GetRancherHostname calls GetRancherHostnameFunc.
+func (mock *K8sClientMock) GetRancherHostname() (string, error) {
+	if mock.GetRancherHostnameFunc == nil {
+		panic("K8sClientMock.GetRancherHostnameFunc: method is nil but Client.GetRancherHostname was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockGetRancherHostname.Lock()
+	mock.calls.GetRancherHostname = append(mock.calls.GetRancherHostname, callInfo)
+	mock.lockGetRancherHostname.Unlock()
+	return mock.GetRancherHostnameFunc()
+}
+
+// GetRancherHostnameCalls gets all the calls that were made to GetRancherHostname.
+// Check the length with:
+//
+//	len(mockedClient.GetRancherHostnameCalls())
+func (mock *K8sClientMock) GetRancherHostnameCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockGetRancherHostname.RLock()
+	calls = mock.calls.GetRancherHostname
+	mock.lockGetRancherHostname.RUnlock()
+	return calls
+}
+
+// GetRancherVersion calls GetRancherVersionFunc.
+func (mock *K8sClientMock) GetRancherVersion() (string, error) {
+	if mock.GetRancherVersionFunc == nil {
+		panic("K8sClientMock.GetRancherVersionFunc: method is nil but Client.GetRancherVersion was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockGetRancherVersion.Lock()
+	mock.calls.GetRancherVersion = append(mock.calls.GetRancherVersion, callInfo)
+	mock.lockGetRancherVersion.Unlock()
+	return mock.GetRancherVersionFunc()
+}
+
+// GetRancherVersionCalls gets all the calls that were made to GetRancherVersion.
+// Check the length with:
+//
+//	len(mockedClient.GetRancherVersionCalls())
+func (mock *K8sClientMock) GetRancherVersionCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockGetRancherVersion.RLock()
+	calls = mock.calls.GetRancherVersion
+	mock.lockGetRancherVersion.RUnlock()
+	return calls
+}
+
+// GetUsageHistory calls GetUsageHistoryFunc.
+func (mock *K8sClientMock) GetUsageHistory(month string) ([]byte, error) {
+	if mock.GetUsageHistoryFunc == nil {
+		panic("K8sClientMock.GetUsageHistoryFunc: method is nil but Client.GetUsageHistory was just called")
+	}
+	callInfo := struct {
+		Month string
+	}{
+		Month: month,
+	}
+	mock.lockGetUsageHistory.Lock()
+	mock.calls.GetUsageHistory = append(mock.calls.GetUsageHistory, callInfo)
+	mock.lockGetUsageHistory.Unlock()
+	return mock.GetUsageHistoryFunc(month)
+}
+
+// GetUsageHistoryCalls gets all the calls that were made to GetUsageHistory.
+// Check the length with:
+//
+//	len(mockedClient.GetUsageHistoryCalls())
+func (mock *K8sClientMock) GetUsageHistoryCalls() []struct {
+	Month string
+} {
+	var calls []struct {
+		Month string
+	}
+	mock.lockGetUsageHistory.RLock()
+	calls = mock.calls.GetUsageHistory
+	mock.lockGetUsageHistory.RUnlock()
+	return calls
+}
+
+// GetUsers calls GetUsersFunc.
+func (mock *K8sClientMock) GetUsers() ([]k8s.RancherUser, error) {
+	if mock.GetUsersFunc == nil {
+		panic("K8sClientMock.GetUsersFunc: method is nil but Client.GetUsers was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockGetUsers.Lock()
+	mock.calls.GetUsers = append(mock.calls.GetUsers, callInfo)
+	mock.lockGetUsers.Unlock()
+	return mock.GetUsersFunc()
+}
+
+// GetUsersCalls gets all the calls that were made to GetUsers.
+// Check the length with:
+//
+//	len(mockedClient.GetUsersCalls())
+func (mock *K8sClientMock) GetUsersCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockGetUsers.RLock()
+	calls = mock.calls.GetUsers
+	mock.lockGetUsers.RUnlock()
+	return calls
+}
+
+// ListUsageHistory calls ListUsageHistoryFunc.
+func (mock *K8sClientMock) ListUsageHistory() ([]string, error) {
+	if mock.ListUsageHistoryFunc == nil {
+		panic("K8sClientMock.ListUsageHistoryFunc: method is nil but Client.ListUsageHistory was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockListUsageHistory.Lock()
+	mock.calls.ListUsageHistory = append(mock.calls.ListUsageHistory, callInfo)
+	mock.lockListUsageHistory.Unlock()
+	return mock.ListUsageHistoryFunc()
+}
+
+// ListUsageHistoryCalls gets all the calls that were made to ListUsageHistory.
+// Check the length with:
+//
+//	len(mockedClient.ListUsageHistoryCalls())
+func (mock *K8sClientMock) ListUsageHistoryCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockListUsageHistory.RLock()
+	calls = mock.calls.ListUsageHistory
+	mock.lockListUsageHistory.RUnlock()
+	return calls
+}
+
+// UpdateCSPConfigOutput calls UpdateCSPConfigOutputFunc.
+func (mock *K8sClientMock) UpdateCSPConfigOutput(marshalledData []byte) error {
+	if mock.UpdateCSPConfigOutputFunc == nil {
+		panic("K8sClientMock.UpdateCSPConfigOutputFunc: method is nil but Client.UpdateCSPConfigOutput was just called")
+	}
+	callInfo := struct {
+		MarshalledData []byte
+	}{
+		MarshalledData: marshalledData,
+	}
+	mock.lockUpdateCSPConfigOutput.Lock()
+	mock.calls.UpdateCSPConfigOutput = append(mock.calls.UpdateCSPConfigOutput, callInfo)
+	mock.lockUpdateCSPConfigOutput.Unlock()
+	return mock.UpdateCSPConfigOutputFunc(marshalledData)
+}
+
+// UpdateCSPConfigOutputCalls gets all the calls that were made to UpdateCSPConfigOutput.
+// Check the length with:
+//
+//	len(mockedClient.UpdateCSPConfigOutputCalls())
+func (mock *K8sClientMock) UpdateCSPConfigOutputCalls() []struct {
+	MarshalledData []byte
+} {
+	var calls []struct {
+		MarshalledData []byte
+	}
+	mock.lockUpdateCSPConfigOutput.RLock()
+	calls = mock.calls.UpdateCSPConfigOutput
+	mock.lockUpdateCSPConfigOutput.RUnlock()
+	return calls
+}
+
+// UpdateCheckoutRequestStatus calls UpdateCheckoutRequestStatusFunc.
+func (mock *K8sClientMock) UpdateCheckoutRequestStatus(name string, status k8s.CheckoutRequestStatus) error {
+	if mock.UpdateCheckoutRequestStatusFunc == nil {
+		panic("K8sClientMock.UpdateCheckoutRequestStatusFunc: method is nil but Client.UpdateCheckoutRequestStatus was just called")
+	}
+	callInfo := struct {
+		Name   string
+		Status k8s.CheckoutRequestStatus
+	}{
+		Name:   name,
+		Status: status,
+	}
+	mock.lockUpdateCheckoutRequestStatus.Lock()
+	mock.calls.UpdateCheckoutRequestStatus = append(mock.calls.UpdateCheckoutRequestStatus, callInfo)
+	mock.lockUpdateCheckoutRequestStatus.Unlock()
+	return mock.UpdateCheckoutRequestStatusFunc(name, status)
+}
+
+// UpdateCheckoutRequestStatusCalls gets all the calls that were made to UpdateCheckoutRequestStatus.
+// Check the length with:
+//
+//	len(mockedClient.UpdateCheckoutRequestStatusCalls())
+func (mock *K8sClientMock) UpdateCheckoutRequestStatusCalls() []struct {
+	Name   string
+	Status k8s.CheckoutRequestStatus
+} {
+	var calls []struct {
+		Name   string
+		Status k8s.CheckoutRequestStatus
+	}
+	mock.lockUpdateCheckoutRequestStatus.RLock()
+	calls = mock.calls.UpdateCheckoutRequestStatus
+	mock.lockUpdateCheckoutRequestStatus.RUnlock()
+	return calls
+}
+
+// UpdateClusterSummary calls UpdateClusterSummaryFunc.
+func (mock *K8sClientMock) UpdateClusterSummary(clusterID string, marshalledData []byte) error {
+	if mock.UpdateClusterSummaryFunc == nil {
+		panic("K8sClientMock.UpdateClusterSummaryFunc: method is nil but Client.UpdateClusterSummary was just called")
+	}
+	callInfo := struct {
+		ClusterID      string
+		MarshalledData []byte
+	}{
+		ClusterID:      clusterID,
+		MarshalledData: marshalledData,
+	}
+	mock.lockUpdateClusterSummary.Lock()
+	mock.calls.UpdateClusterSummary = append(mock.calls.UpdateClusterSummary, callInfo)
+	mock.lockUpdateClusterSummary.Unlock()
+	return mock.UpdateClusterSummaryFunc(clusterID, marshalledData)
+}
+
+// UpdateClusterSummaryCalls gets all the calls that were made to UpdateClusterSummary.
+// Check the length with:
+//
+//	len(mockedClient.UpdateClusterSummaryCalls())
+func (mock *K8sClientMock) UpdateClusterSummaryCalls() []struct {
+	ClusterID      string
+	MarshalledData []byte
+} {
+	var calls []struct {
+		ClusterID      string
+		MarshalledData []byte
+	}
+	mock.lockUpdateClusterSummary.RLock()
+	calls = mock.calls.UpdateClusterSummary
+	mock.lockUpdateClusterSummary.RUnlock()
+	return calls
+}
+
+// UpdateComplianceCondition calls UpdateComplianceConditionFunc.
+func (mock *K8sClientMock) UpdateComplianceCondition(compliant bool, reason string, message string) error {
+	if mock.UpdateComplianceConditionFunc == nil {
+		panic("K8sClientMock.UpdateComplianceConditionFunc: method is nil but Client.UpdateComplianceCondition was just called")
+	}
+	callInfo := struct {
+		Compliant bool
+		Reason    string
+		Message   string
+	}{
+		Compliant: compliant,
+		Reason:    reason,
+		Message:   message,
+	}
+	mock.lockUpdateComplianceCondition.Lock()
+	mock.calls.UpdateComplianceCondition = append(mock.calls.UpdateComplianceCondition, callInfo)
+	mock.lockUpdateComplianceCondition.Unlock()
+	return mock.UpdateComplianceConditionFunc(compliant, reason, message)
+}
+
+// UpdateComplianceConditionCalls gets all the calls that were made to UpdateComplianceCondition.
+// Check the length with:
+//
+//	len(mockedClient.UpdateComplianceConditionCalls())
+func (mock *K8sClientMock) UpdateComplianceConditionCalls() []struct {
+	Compliant bool
+	Reason    string
+	Message   string
+} {
+	var calls []struct {
+		Compliant bool
+		Reason    string
+		Message   string
+	}
+	mock.lockUpdateComplianceCondition.RLock()
+	calls = mock.calls.UpdateComplianceCondition
+	mock.lockUpdateComplianceCondition.RUnlock()
+	return calls
+}
+
+// UpdateComplianceSetting calls UpdateComplianceSettingFunc.
+func (mock *K8sClientMock) UpdateComplianceSetting(value string) error {
+	if mock.UpdateComplianceSettingFunc == nil {
+		panic("K8sClientMock.UpdateComplianceSettingFunc: method is nil but Client.UpdateComplianceSetting was just called")
+	}
+	callInfo := struct {
+		Value string
+	}{
+		Value: value,
+	}
+	mock.lockUpdateComplianceSetting.Lock()
+	mock.calls.UpdateComplianceSetting = append(mock.calls.UpdateComplianceSetting, callInfo)
+	mock.lockUpdateComplianceSetting.Unlock()
+	return mock.UpdateComplianceSettingFunc(value)
+}
+
+// UpdateComplianceSettingCalls gets all the calls that were made to UpdateComplianceSetting.
+// Check the length with:
+//
+//	len(mockedClient.UpdateComplianceSettingCalls())
+func (mock *K8sClientMock) UpdateComplianceSettingCalls() []struct {
+	Value string
+} {
+	var calls []struct {
+		Value string
+	}
+	mock.lockUpdateComplianceSetting.RLock()
+	calls = mock.calls.UpdateComplianceSetting
+	mock.lockUpdateComplianceSetting.RUnlock()
+	return calls
+}
+
+// UpdateConsumptionTokenSecret calls UpdateConsumptionTokenSecretFunc.
+func (mock *K8sClientMock) UpdateConsumptionTokenSecret(data map[string]string) error {
+	if mock.UpdateConsumptionTokenSecretFunc == nil {
+		panic("K8sClientMock.UpdateConsumptionTokenSecretFunc: method is nil but Client.UpdateConsumptionTokenSecret was just called")
+	}
+	callInfo := struct {
+		Data map[string]string
+	}{
+		Data: data,
+	}
+	mock.lockUpdateConsumptionTokenSecret.Lock()
+	mock.calls.UpdateConsumptionTokenSecret = append(mock.calls.UpdateConsumptionTokenSecret, callInfo)
+	mock.lockUpdateConsumptionTokenSecret.Unlock()
+	return mock.UpdateConsumptionTokenSecretFunc(data)
+}
+
+// UpdateConsumptionTokenSecretCalls gets all the calls that were made to UpdateConsumptionTokenSecret.
+// Check the length with:
+//
+//	len(mockedClient.UpdateConsumptionTokenSecretCalls())
+func (mock *K8sClientMock) UpdateConsumptionTokenSecretCalls() []struct {
+	Data map[string]string
+} {
+	var calls []struct {
+		Data map[string]string
+	}
+	mock.lockUpdateConsumptionTokenSecret.RLock()
+	calls = mock.calls.UpdateConsumptionTokenSecret
+	mock.lockUpdateConsumptionTokenSecret.RUnlock()
+	return calls
+}
+
+// UpdateUsageHistory calls UpdateUsageHistoryFunc.
+func (mock *K8sClientMock) UpdateUsageHistory(month string, data []byte) error {
+	if mock.UpdateUsageHistoryFunc == nil {
+		panic("K8sClientMock.UpdateUsageHistoryFunc: method is nil but Client.UpdateUsageHistory was just called")
+	}
+	callInfo := struct {
+		Month string
+		Data  []byte
+	}{
+		Month: month,
+		Data:  data,
+	}
+	mock.lockUpdateUsageHistory.Lock()
+	mock.calls.UpdateUsageHistory = append(mock.calls.UpdateUsageHistory, callInfo)
+	mock.lockUpdateUsageHistory.Unlock()
+	return mock.UpdateUsageHistoryFunc(month, data)
+}
+
+// UpdateUsageHistoryCalls gets all the calls that were made to UpdateUsageHistory.
+// Check the length with:
+//
+//	len(mockedClient.UpdateUsageHistoryCalls())
+func (mock *K8sClientMock) UpdateUsageHistoryCalls() []struct {
+	Month string
+	Data  []byte
+} {
+	var calls []struct {
+		Month string
+		Data  []byte
+	}
+	mock.lockUpdateUsageHistory.RLock()
+	calls = mock.calls.UpdateUsageHistory
+	mock.lockUpdateUsageHistory.RUnlock()
+	return calls
+}
+
+// UpdateUserNotification calls UpdateUserNotificationFunc.
+func (mock *K8sClientMock) UpdateUserNotification(isInCompliance bool, message string) error {
+	if mock.UpdateUserNotificationFunc == nil {
+		panic("K8sClientMock.UpdateUserNotificationFunc: method is nil but Client.UpdateUserNotification was just called")
+	}
+	callInfo := struct {
+		IsInCompliance bool
+		Message        string
+	}{
+		IsInCompliance: isInCompliance,
+		Message:        message,
+	}
+	mock.lockUpdateUserNotification.Lock()
+	mock.calls.UpdateUserNotification = append(mock.calls.UpdateUserNotification, callInfo)
+	mock.lockUpdateUserNotification.Unlock()
+	return mock.UpdateUserNotificationFunc(isInCompliance, message)
+}
+
+// UpdateUserNotificationCalls gets all the calls that were made to UpdateUserNotification.
+// Check the length with:
+//
+//	len(mockedClient.UpdateUserNotificationCalls())
+func (mock *K8sClientMock) UpdateUserNotificationCalls() []struct {
+	IsInCompliance bool
+	Message        string
+} {
+	var calls []struct {
+		IsInCompliance bool
+		Message        string
+	}
+	mock.lockUpdateUserNotification.RLock()
+	calls = mock.calls.UpdateUserNotification
+	mock.lockUpdateUserNotification.RUnlock()
+	return calls
+}
@@ -0,0 +1,76 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package mocks
+
+import (
+	"context"
+	"github.com/rancher/csp-adapter/pkg/metrics"
+	"sync"
+)
+
+// Ensure, that ScraperMock does implement metrics.Scraper.
+// If this is not the case, regenerate this file with moq.
+var _ metrics.Scraper = &ScraperMock{}
+
+// ScraperMock is a mock implementation of metrics.Scraper.
+//
+//	func TestSomethingThatUsesScraper(t *testing.T) {
+//
+//		// make and configure a mocked metrics.Scraper
+//		mockedScraper := &ScraperMock{
+//			ScrapeAndParseFunc: func(ctx context.Context) (*metrics.NodeCounts, error) {
+//				panic("mock out the ScrapeAndParse method")
+//			},
+//		}
+//
+//		// use mockedScraper in code that requires metrics.Scraper
+//		// and then make assertions.
+//
+//	}
+type ScraperMock struct {
+	// ScrapeAndParseFunc mocks the ScrapeAndParse method.
+	ScrapeAndParseFunc func(ctx context.Context) (*metrics.NodeCounts, error)
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// ScrapeAndParse holds details about calls to the ScrapeAndParse method.
+		ScrapeAndParse []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+		}
+	}
+	lockScrapeAndParse sync.RWMutex
+}
+
+// ScrapeAndParse calls ScrapeAndParseFunc.
+func (mock *ScraperMock) ScrapeAndParse(ctx context.Context) (*metrics.NodeCounts, error) {
+	if mock.ScrapeAndParseFunc == nil {
+		panic("ScraperMock.ScrapeAndParseFunc: method is nil but Scraper.ScrapeAndParse was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+	}{
+		Ctx: ctx,
+	}
+	mock.lockScrapeAndParse.Lock()
+	mock.calls.ScrapeAndParse = append(mock.calls.ScrapeAndParse, callInfo)
+	mock.lockScrapeAndParse.Unlock()
+	return mock.ScrapeAndParseFunc(ctx)
+}
+
+// ScrapeAndParseCalls gets all the calls that were made to ScrapeAndParse.
+// Check the length with:
+//
+//	len(mockedScraper.ScrapeAndParseCalls())
+func (mock *ScraperMock) ScrapeAndParseCalls() []struct {
+	Ctx context.Context
+} {
+	var calls []struct {
+		Ctx context.Context
+	}
+	mock.lockScrapeAndParse.RLock()
+	calls = mock.calls.ScrapeAndParse
+	mock.lockScrapeAndParse.RUnlock()
+	return calls
+}
@@ -0,0 +1,82 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package mocks
+
+import (
+	"context"
+	"github.com/rancher/csp-adapter/pkg/clients/aws"
+	"sync"
+)
+
+// Ensure, that SecretsClientMock does implement aws.SecretsClient.
+// If this is not the case, regenerate this file with moq.
+var _ aws.SecretsClient = &SecretsClientMock{}
+
+// SecretsClientMock is a mock implementation of aws.SecretsClient.
+//
+//	func TestSomethingThatUsesSecretsClient(t *testing.T) {
+//
+//		// make and configure a mocked aws.SecretsClient
+//		mockedSecretsClient := &SecretsClientMock{
+//			GetSecretValueFunc: func(ctx context.Context, id string) (string, error) {
+//				panic("mock out the GetSecretValue method")
+//			},
+//		}
+//
+//		// use mockedSecretsClient in code that requires aws.SecretsClient
+//		// and then make assertions.
+//
+//	}
+type SecretsClientMock struct {
+	// GetSecretValueFunc mocks the GetSecretValue method.
+	GetSecretValueFunc func(ctx context.Context, id string) (string, error)
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// GetSecretValue holds details about calls to the GetSecretValue method.
+		GetSecretValue []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// ID is the id argument value.
+			ID string
+		}
+	}
+	lockGetSecretValue sync.RWMutex
+}
+
+// GetSecretValue calls GetSecretValueFunc.
+func (mock *SecretsClientMock) GetSecretValue(ctx context.Context, id string) (string, error) {
+	if mock.GetSecretValueFunc == nil {
+		panic("SecretsClientMock.GetSecretValueFunc: method is nil but SecretsClient.GetSecretValue was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+		ID  string
+	}{
+		Ctx: ctx,
+		ID:  id,
+	}
+	mock.lockGetSecretValue.Lock()
+	mock.calls.GetSecretValue = append(mock.calls.GetSecretValue, callInfo)
+	mock.lockGetSecretValue.Unlock()
+	return mock.GetSecretValueFunc(ctx, id)
+}
+
+// GetSecretValueCalls gets all the calls that were made to GetSecretValue.
+// Check the length with:
+//
+//	len(mockedSecretsClient.GetSecretValueCalls())
+func (mock *SecretsClientMock) GetSecretValueCalls() []struct {
+	Ctx context.Context
+	ID  string
+} {
+	var calls []struct {
+		Ctx context.Context
+		ID  string
+	}
+	mock.lockGetSecretValue.RLock()
+	calls = mock.calls.GetSecretValue
+	mock.lockGetSecretValue.RUnlock()
+	return calls
+}
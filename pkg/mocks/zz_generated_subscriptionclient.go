@@ -0,0 +1,213 @@
+// Code generated by moq; DO NOT EDIT.
+// github.com/matryer/moq
+
+package mocks
+
+import (
+	"context"
+	"github.com/rancher/csp-adapter/pkg/clients/aws"
+	"sync"
+)
+
+// Ensure, that SubscriptionClientMock does implement aws.SubscriptionClient.
+// If this is not the case, regenerate this file with moq.
+var _ aws.SubscriptionClient = &SubscriptionClientMock{}
+
+// SubscriptionClientMock is a mock implementation of aws.SubscriptionClient.
+//
+//	func TestSomethingThatUsesSubscriptionClient(t *testing.T) {
+//
+//		// make and configure a mocked aws.SubscriptionClient
+//		mockedSubscriptionClient := &SubscriptionClientMock{
+//			ListSubscribedUsersFunc: func(ctx context.Context) ([]string, error) {
+//				panic("mock out the ListSubscribedUsers method")
+//			},
+//			ProductFunc: func() string {
+//				panic("mock out the Product method")
+//			},
+//			SubscribeUserFunc: func(ctx context.Context, username string) error {
+//				panic("mock out the SubscribeUser method")
+//			},
+//			UnsubscribeUserFunc: func(ctx context.Context, username string) error {
+//				panic("mock out the UnsubscribeUser method")
+//			},
+//		}
+//
+//		// use mockedSubscriptionClient in code that requires aws.SubscriptionClient
+//		// and then make assertions.
+//
+//	}
+type SubscriptionClientMock struct {
+	// ListSubscribedUsersFunc mocks the ListSubscribedUsers method.
+	ListSubscribedUsersFunc func(ctx context.Context) ([]string, error)
+
+	// ProductFunc mocks the Product method.
+	ProductFunc func() string
+
+	// SubscribeUserFunc mocks the SubscribeUser method.
+	SubscribeUserFunc func(ctx context.Context, username string) error
+
+	// UnsubscribeUserFunc mocks the UnsubscribeUser method.
+	UnsubscribeUserFunc func(ctx context.Context, username string) error
+
+	// calls tracks calls to the methods.
+	calls struct {
+		// ListSubscribedUsers holds details about calls to the ListSubscribedUsers method.
+		ListSubscribedUsers []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+		}
+		// Product holds details about calls to the Product method.
+		Product []struct {
+		}
+		// SubscribeUser holds details about calls to the SubscribeUser method.
+		SubscribeUser []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Username is the username argument value.
+			Username string
+		}
+		// UnsubscribeUser holds details about calls to the UnsubscribeUser method.
+		UnsubscribeUser []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Username is the username argument value.
+			Username string
+		}
+	}
+	lockListSubscribedUsers sync.RWMutex
+	lockProduct             sync.RWMutex
+	lockSubscribeUser       sync.RWMutex
+	lockUnsubscribeUser     sync.RWMutex
+}
+
+// ListSubscribedUsers calls ListSubscribedUsersFunc.
+func (mock *SubscriptionClientMock) ListSubscribedUsers(ctx context.Context) ([]string, error) {
+	if mock.ListSubscribedUsersFunc == nil {
+		panic("SubscriptionClientMock.ListSubscribedUsersFunc: method is nil but SubscriptionClient.ListSubscribedUsers was just called")
+	}
+	callInfo := struct {
+		Ctx context.Context
+	}{
+		Ctx: ctx,
+	}
+	mock.lockListSubscribedUsers.Lock()
+	mock.calls.ListSubscribedUsers = append(mock.calls.ListSubscribedUsers, callInfo)
+	mock.lockListSubscribedUsers.Unlock()
+	return mock.ListSubscribedUsersFunc(ctx)
+}
+
+// ListSubscribedUsersCalls gets all the calls that were made to ListSubscribedUsers.
+// Check the length with:
+//
+//	len(mockedSubscriptionClient.ListSubscribedUsersCalls())
+func (mock *SubscriptionClientMock) ListSubscribedUsersCalls() []struct {
+	Ctx context.Context
+} {
+	var calls []struct {
+		Ctx context.Context
+	}
+	mock.lockListSubscribedUsers.RLock()
+	calls = mock.calls.ListSubscribedUsers
+	mock.lockListSubscribedUsers.RUnlock()
+	return calls
+}
+
+// Product calls ProductFunc.
+func (mock *SubscriptionClientMock) Product() string {
+	if mock.ProductFunc == nil {
+		panic("SubscriptionClientMock.ProductFunc: method is nil but SubscriptionClient.Product was just called")
+	}
+	callInfo := struct {
+	}{}
+	mock.lockProduct.Lock()
+	mock.calls.Product = append(mock.calls.Product, callInfo)
+	mock.lockProduct.Unlock()
+	return mock.ProductFunc()
+}
+
+// ProductCalls gets all the calls that were made to Product.
+// Check the length with:
+//
+//	len(mockedSubscriptionClient.ProductCalls())
+func (mock *SubscriptionClientMock) ProductCalls() []struct {
+} {
+	var calls []struct {
+	}
+	mock.lockProduct.RLock()
+	calls = mock.calls.Product
+	mock.lockProduct.RUnlock()
+	return calls
+}
+
+// SubscribeUser calls SubscribeUserFunc.
+func (mock *SubscriptionClientMock) SubscribeUser(ctx context.Context, username string) error {
+	if mock.SubscribeUserFunc == nil {
+		panic("SubscriptionClientMock.SubscribeUserFunc: method is nil but SubscriptionClient.SubscribeUser was just called")
+	}
+	callInfo := struct {
+		Ctx      context.Context
+		Username string
+	}{
+		Ctx:      ctx,
+		Username: username,
+	}
+	mock.lockSubscribeUser.Lock()
+	mock.calls.SubscribeUser = append(mock.calls.SubscribeUser, callInfo)
+	mock.lockSubscribeUser.Unlock()
+	return mock.SubscribeUserFunc(ctx, username)
+}
+
+// SubscribeUserCalls gets all the calls that were made to SubscribeUser.
+// Check the length with:
+//
+//	len(mockedSubscriptionClient.SubscribeUserCalls())
+func (mock *SubscriptionClientMock) SubscribeUserCalls() []struct {
+	Ctx      context.Context
+	Username string
+} {
+	var calls []struct {
+		Ctx      context.Context
+		Username string
+	}
+	mock.lockSubscribeUser.RLock()
+	calls = mock.calls.SubscribeUser
+	mock.lockSubscribeUser.RUnlock()
+	return calls
+}
+
+// UnsubscribeUser calls UnsubscribeUserFunc.
+func (mock *SubscriptionClientMock) UnsubscribeUser(ctx context.Context, username string) error {
+	if mock.UnsubscribeUserFunc == nil {
+		panic("SubscriptionClientMock.UnsubscribeUserFunc: method is nil but SubscriptionClient.UnsubscribeUser was just called")
+	}
+	callInfo := struct {
+		Ctx      context.Context
+		Username string
+	}{
+		Ctx:      ctx,
+		Username: username,
+	}
+	mock.lockUnsubscribeUser.Lock()
+	mock.calls.UnsubscribeUser = append(mock.calls.UnsubscribeUser, callInfo)
+	mock.lockUnsubscribeUser.Unlock()
+	return mock.UnsubscribeUserFunc(ctx, username)
+}
+
+// UnsubscribeUserCalls gets all the calls that were made to UnsubscribeUser.
+// Check the length with:
+//
+//	len(mockedSubscriptionClient.UnsubscribeUserCalls())
+func (mock *SubscriptionClientMock) UnsubscribeUserCalls() []struct {
+	Ctx      context.Context
+	Username string
+} {
+	var calls []struct {
+		Ctx      context.Context
+		Username string
+	}
+	mock.lockUnsubscribeUser.RLock()
+	calls = mock.calls.UnsubscribeUser
+	mock.lockUnsubscribeUser.RUnlock()
+	return calls
+}
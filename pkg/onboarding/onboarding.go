@@ -0,0 +1,242 @@
+// Package onboarding verifies that an account is set up for the adapter, by walking the chain the adapter relies on:
+// the aws credentials, the acceptance of the license grant, the entitlements it grants, and a checkout and check-in
+// of a single entitlement. The outcome of each step is collected in a report which can be attached to a support ticket
+package onboarding
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/licensemanager/types"
+	"github.com/rancher/csp-adapter/pkg/audit"
+	"github.com/rancher/csp-adapter/pkg/clients/aws"
+)
+
+// Outcome is the outcome of a step of the verification
+type Outcome string
+
+const (
+	Pass Outcome = "PASS"
+	Fail Outcome = "FAIL"
+	// Skip is the outcome of steps which weren't run because a step they depend on failed
+	Skip Outcome = "SKIP"
+)
+
+// Names of the steps, in the order they run
+const (
+	StepCredentials  = "credentials"
+	StepGrant        = "grant acceptance"
+	StepEntitlements = "entitlement presence"
+	StepCheckout     = "checkout"
+	StepCheckIn      = "check-in"
+	StepCleanup      = "cleanup"
+)
+
+// Step is the outcome of a step of the verification
+type Step struct {
+	Name    string        `json:"name"`
+	Outcome Outcome       `json:"outcome"`
+	Detail  string        `json:"detail,omitempty"`
+	Elapsed time.Duration `json:"elapsed"`
+}
+
+// Report is the outcome of a verification
+type Report struct {
+	Version       string    `json:"version"`
+	StartedAt     time.Time `json:"startedAt"`
+	AccountNumber string    `json:"accountNumber,omitempty"`
+	LicenseArn    string    `json:"licenseArn,omitempty"`
+	Steps         []Step    `json:"steps"`
+}
+
+// Passed returns whether every step passed
+func (r Report) Passed() bool {
+	for _, step := range r.Steps {
+		if step.Outcome != Pass {
+			return false
+		}
+	}
+	return len(r.Steps) > 0
+}
+
+// Write writes the report as text, one line per step
+func (r Report) Write(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "csp-adapter onboarding verification (version %s)\n", r.Version)
+	fmt.Fprintf(&b, "started: %s\n", r.StartedAt.UTC().Format(time.RFC3339))
+	if r.AccountNumber != "" {
+		fmt.Fprintf(&b, "account: %s\n", r.AccountNumber)
+	}
+	if r.LicenseArn != "" {
+		fmt.Fprintf(&b, "license: %s\n", r.LicenseArn)
+	}
+	b.WriteString("\n")
+	for _, step := range r.Steps {
+		fmt.Fprintf(&b, "[%s] %-20s %s", step.Outcome, step.Name, step.Elapsed.Round(time.Millisecond))
+		if step.Detail != "" {
+			fmt.Fprintf(&b, ": %s", step.Detail)
+		}
+		b.WriteString("\n")
+	}
+	result := Fail
+	if r.Passed() {
+		result = Pass
+	}
+	fmt.Fprintf(&b, "\nresult: %s\n", result)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteJSON writes the report as indented json
+func (r Report) WriteJSON(w io.Writer) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
+// Connect creates the client the verification is run with. Its error fails the credentials step
+type Connect func(ctx context.Context) (aws.Client, error)
+
+// verification is a running verification
+type verification struct {
+	ctx    context.Context
+	now    func() time.Time
+	report Report
+	// failed is set once a step failed, later steps are skipped
+	failed bool
+}
+
+// Verify runs every step of the verification with the client created by connect, returning its report. Once a step
+// fails, the steps after it are skipped, except the cleanup which checks in the entitlement if it was checked out. An
+// error is only returned if the cleanup left the entitlement checked out, and names the token to check in by hand
+func Verify(ctx context.Context, version string, connect Connect) (Report, error) {
+	v := verification{ctx: ctx, now: time.Now, report: Report{Version: version}}
+	v.report.StartedAt = v.now()
+
+	var client aws.Client
+	v.run(StepCredentials, func() (string, error) {
+		var err error
+		if client, err = connect(ctx); err != nil {
+			return "", err
+		}
+		// the client doesn't fail if the account can't be looked up, which this step verifies
+		if _, err := client.RefreshAccountNumber(ctx); err != nil {
+			return "", fmt.Errorf("unable to look up the account: %w", err)
+		}
+		v.report.AccountNumber = client.AccountNumber()
+		if alias := client.AccountAlias(); alias != "" {
+			return fmt.Sprintf("resolved account %s (%s)", client.AccountNumber(), alias), nil
+		}
+		return fmt.Sprintf("resolved account %s", client.AccountNumber()), nil
+	})
+
+	var license *types.GrantedLicense
+	v.run(StepGrant, func() (string, error) {
+		var err error
+		if license, err = client.GetRancherLicense(ctx); err != nil {
+			return "", fmt.Errorf("no rancher license was received, accept the grant in the License Manager console of the license's home region: %w", err)
+		}
+		v.report.LicenseArn = awssdk.ToString(license.LicenseArn)
+		status := grantStatus(*license)
+		if status == types.ReceivedStatusPendingAccept || status == types.ReceivedStatusPendingWorkflow {
+			return "", fmt.Errorf("the grant has status %s, accept and activate it in the License Manager console", status)
+		}
+		if err := client.ValidateLicense(*license); err != nil {
+			return "", err
+		}
+		if status == "" {
+			return "license received", nil
+		}
+		return fmt.Sprintf("grant has status %s", status), nil
+	})
+
+	v.run(StepEntitlements, func() (string, error) {
+		usage, err := client.GetEntitlementUsage(ctx, *license)
+		if err != nil {
+			return "", err
+		}
+		detail := fmt.Sprintf("%s: %d granted, %d consumed, %d available", usage.Dimension, usage.Max, usage.Consumed, usage.Available())
+		if usage.Max == 0 {
+			return "", fmt.Errorf("the license grants no entitlements (%s)", detail)
+		}
+		if usage.Available() < 1 {
+			return "", fmt.Errorf("no entitlement is available to check out (%s)", detail)
+		}
+		return detail, nil
+	})
+
+	var token string
+	v.run(StepCheckout, func() (string, error) {
+		res, err := client.CheckoutRancherLicense(ctx, *license, 1)
+		if err != nil {
+			return "", err
+		}
+		token = awssdk.ToString(res.LicenseConsumptionToken)
+		if token == "" {
+			return "", errors.New("the checkout returned no consumption token")
+		}
+		return fmt.Sprintf("checked out 1 entitlement, token %s", audit.TokenID(token)), nil
+	})
+
+	checkedIn := false
+	v.run(StepCheckIn, func() (string, error) {
+		if _, err := client.CheckInRancherLicense(ctx, token); err != nil {
+			return "", err
+		}
+		checkedIn = true
+		return fmt.Sprintf("checked in token %s", audit.TokenID(token)), nil
+	})
+
+	// the cleanup runs even if an earlier step failed, so that the verification never leaves an entitlement checked out
+	var cleanupErr error
+	v.always(StepCleanup, func() (string, error) {
+		if token == "" || checkedIn {
+			return "nothing is left checked out", nil
+		}
+		if _, err := client.CheckInRancherLicense(ctx, token); err != nil {
+			cleanupErr = fmt.Errorf("the verification's checkout of 1 entitlement couldn't be checked in, check in token %s by hand "+
+				"or let it expire: %w", token, err)
+			return "", fmt.Errorf("token %s is still checked out: %w", audit.TokenID(token), err)
+		}
+		return fmt.Sprintf("checked in token %s on retry", audit.TokenID(token)), nil
+	})
+	return v.report, cleanupErr
+}
+
+// run runs the step with fn, which returns its detail, unless an earlier step failed
+func (v *verification) run(name string, fn func() (string, error)) {
+	if v.failed {
+		v.report.Steps = append(v.report.Steps, Step{Name: name, Outcome: Skip, Detail: "an earlier step failed"})
+		return
+	}
+	v.always(name, fn)
+}
+
+// always runs the step with fn, which returns its detail, even if an earlier step failed
+func (v *verification) always(name string, fn func() (string, error)) {
+	started := v.now()
+	detail, err := fn()
+	step := Step{Name: name, Outcome: Pass, Detail: detail, Elapsed: v.now().Sub(started)}
+	if err != nil {
+		step.Outcome, step.Detail = Fail, err.Error()
+		v.failed = true
+	}
+	v.report.Steps = append(v.report.Steps, step)
+}
+
+// grantStatus returns the status of the grant license was received through, empty if it isn't known
+func grantStatus(license types.GrantedLicense) types.ReceivedStatus {
+	if license.ReceivedMetadata == nil {
+		return ""
+	}
+	return license.ReceivedMetadata.ReceivedStatus
+}
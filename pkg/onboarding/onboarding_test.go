@@ -0,0 +1,157 @@
+package onboarding
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	lm "github.com/aws/aws-sdk-go-v2/service/licensemanager"
+	"github.com/aws/aws-sdk-go-v2/service/licensemanager/types"
+	"github.com/rancher/csp-adapter/pkg/clients/aws"
+	"github.com/rancher/csp-adapter/pkg/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flakyCheckIn fails the first checkInErrs check-ins
+type flakyCheckIn struct {
+	*mocks.MockAWSClient
+	checkInErrs int
+}
+
+func (c *flakyCheckIn) CheckInRancherLicense(ctx context.Context, consumptionToken string) (*lm.CheckInLicenseOutput, error) {
+	if c.checkInErrs > 0 {
+		c.checkInErrs--
+		return nil, errors.New("check-in failed")
+	}
+	return c.MockAWSClient.CheckInRancherLicense(ctx, consumptionToken)
+}
+
+func outcomes(report Report) map[string]Outcome {
+	result := map[string]Outcome{}
+	for _, step := range report.Steps {
+		result[step.Name] = step.Outcome
+	}
+	return result
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name        string
+		client      func() (aws.Client, *mocks.MockAWSClient)
+		connectErr  error
+		want        map[string]Outcome
+		wantErr     bool
+		wantHeld    int
+		wantPassed  bool
+		wantAccount string
+	}{
+		{
+			name: "onboarded",
+			client: func() (aws.Client, *mocks.MockAWSClient) {
+				m := mocks.NewMockAWSClient(2)
+				return m, m
+			},
+			want: map[string]Outcome{StepCredentials: Pass, StepGrant: Pass, StepEntitlements: Pass, StepCheckout: Pass,
+				StepCheckIn: Pass, StepCleanup: Pass},
+			wantPassed:  true,
+			wantAccount: "111111111111",
+		},
+		{
+			name:       "no credentials",
+			connectErr: errors.New("no credentials"),
+			want: map[string]Outcome{StepCredentials: Fail, StepGrant: Skip, StepEntitlements: Skip, StepCheckout: Skip,
+				StepCheckIn: Skip, StepCleanup: Pass},
+		},
+		{
+			name: "grant not accepted",
+			client: func() (aws.Client, *mocks.MockAWSClient) {
+				m := mocks.NewMockAWSClient(2)
+				m.License.ReceivedMetadata = &types.ReceivedMetadata{ReceivedStatus: types.ReceivedStatusPendingAccept}
+				return m, m
+			},
+			want: map[string]Outcome{StepCredentials: Pass, StepGrant: Fail, StepEntitlements: Skip, StepCheckout: Skip,
+				StepCheckIn: Skip, StepCleanup: Pass},
+			wantAccount: "111111111111",
+		},
+		{
+			name: "no entitlement available",
+			client: func() (aws.Client, *mocks.MockAWSClient) {
+				m := mocks.NewMockAWSClient(2)
+				m.ExternalEntitlements = 2
+				return m, m
+			},
+			want: map[string]Outcome{StepCredentials: Pass, StepGrant: Pass, StepEntitlements: Fail, StepCheckout: Skip,
+				StepCheckIn: Skip, StepCleanup: Pass},
+			wantAccount: "111111111111",
+		},
+		{
+			name: "failed check-in is retried by the cleanup",
+			client: func() (aws.Client, *mocks.MockAWSClient) {
+				m := mocks.NewMockAWSClient(2)
+				return &flakyCheckIn{MockAWSClient: m, checkInErrs: 1}, m
+			},
+			want: map[string]Outcome{StepCredentials: Pass, StepGrant: Pass, StepEntitlements: Pass, StepCheckout: Pass,
+				StepCheckIn: Fail, StepCleanup: Pass},
+			wantAccount: "111111111111",
+		},
+		{
+			name: "entitlement left checked out",
+			client: func() (aws.Client, *mocks.MockAWSClient) {
+				m := mocks.NewMockAWSClient(2)
+				m.CheckInErr = errors.New("access denied")
+				return m, m
+			},
+			want: map[string]Outcome{StepCredentials: Pass, StepGrant: Pass, StepEntitlements: Pass, StepCheckout: Pass,
+				StepCheckIn: Fail, StepCleanup: Fail},
+			wantErr:     true,
+			wantHeld:    1,
+			wantAccount: "111111111111",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			var mock *mocks.MockAWSClient
+			report, err := Verify(context.Background(), "dev", func(ctx context.Context) (aws.Client, error) {
+				if test.connectErr != nil {
+					return nil, test.connectErr
+				}
+				var client aws.Client
+				client, mock = test.client()
+				return client, nil
+			})
+			if test.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, test.want, outcomes(report))
+			assert.Len(t, report.Steps, 6, "every step is reported")
+			assert.Equal(t, test.wantPassed, report.Passed())
+			assert.Equal(t, test.wantAccount, report.AccountNumber)
+			if mock != nil {
+				held := 0
+				for _, licenses := range mock.CheckedOutEntitlements {
+					held += licenses
+				}
+				assert.Equal(t, test.wantHeld, held)
+			}
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	report, err := Verify(context.Background(), "v1.0.0", func(ctx context.Context) (aws.Client, error) {
+		return mocks.NewMockAWSClient(2), nil
+	})
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, report.Write(&buf))
+	assert.Contains(t, buf.String(), "version v1.0.0")
+	assert.Contains(t, buf.String(), "account: 111111111111")
+	assert.Contains(t, buf.String(), "[PASS] checkout")
+	assert.Contains(t, buf.String(), "result: PASS")
+	assert.NotContains(t, buf.String(), "token 1\n", "consumption tokens are only reported by their id")
+}
@@ -0,0 +1,206 @@
+// Package profiling captures heap and goroutine profiles when the adapter's memory grows abnormally, so that leaks in
+// long-running installs can be diagnosed after the fact without keeping the pprof endpoints enabled
+package profiling
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/metrics"
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/sirupsen/logrus"
+)
+
+// Options configures when snapshots are captured and how many are kept
+type Options struct {
+	// Dir is the directory snapshots are written to
+	Dir string
+	// Growth is the factor the heap must grow by over its lowest size since the previous snapshot for another one to be
+	// captured, i.e. 2 captures snapshots once the heap doubled
+	Growth float64
+	// MinHeapBytes is the heap below which no snapshots are captured, growth of a heap this small isn't worth diagnosing
+	MinHeapBytes uint64
+	// Interval is how often the heap is sampled
+	Interval time.Duration
+	// MaxSnapshots is the number of snapshots of each kind which are kept, older ones are deleted
+	MaxSnapshots int
+}
+
+// DefaultOptions are the options used by the adapter, apart from Dir
+var DefaultOptions = Options{
+	Growth:       2,
+	MinHeapBytes: 64 << 20,
+	Interval:     time.Minute,
+	MaxSnapshots: 5,
+}
+
+// kinds are the profiles captured in every snapshot
+var kinds = []string{"heap", "goroutine"}
+
+const (
+	snapshotSuffix     = ".pb.gz"
+	snapshotTimeFormat = "20060102T150405Z"
+)
+
+// Watcher samples the heap and captures snapshots when it grows abnormally
+type Watcher struct {
+	opts Options
+	// heap returns the allocated heap, replaced in tests
+	heap func() uint64
+
+	lock sync.Mutex
+	// threshold is the heap which triggers the next snapshot, 0 until the heap was first sampled
+	threshold uint64
+}
+
+func NewWatcher(opts Options) (*Watcher, error) {
+	if opts.Growth <= 1 {
+		return nil, fmt.Errorf("heap growth must be greater than 1, got %v", opts.Growth)
+	}
+	if err := os.MkdirAll(opts.Dir, 0700); err != nil {
+		return nil, fmt.Errorf("unable to create profile snapshot directory: %w", err)
+	}
+	return &Watcher{opts: opts, heap: allocatedHeap}, nil
+}
+
+func allocatedHeap() uint64 {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.HeapAlloc
+}
+
+// Run samples the heap every interval until ctx is cancelled
+func (w *Watcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if err := w.sample(now); err != nil {
+				logrus.Warnf("[profiling] unable to capture profile snapshot: %v", err)
+			}
+		}
+	}
+}
+
+// sample captures a snapshot if the heap grew by the configured factor over its lowest size since the previous
+// snapshot. The threshold follows the heap down, so that a heap which shrank and grows again is captured again
+func (w *Watcher) sample(now time.Time) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	heap := w.heap()
+	next := uint64(float64(heap) * w.opts.Growth)
+	if w.threshold == 0 || heap < w.threshold && next < w.threshold {
+		w.threshold = next
+		return nil
+	}
+	if heap < w.threshold || heap < w.opts.MinHeapBytes {
+		return nil
+	}
+	logrus.Warnf("[profiling] heap grew to %d bytes, capturing heap and goroutine profiles in %s", heap, w.opts.Dir)
+	w.threshold = next
+	metrics.ProfileSnapshots.Inc()
+	for _, kind := range kinds {
+		if err := w.capture(kind, now, heap); err != nil {
+			return err
+		}
+		if err := w.prune(kind); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *Watcher) capture(kind string, now time.Time, heap uint64) error {
+	name := fmt.Sprintf("%s-%s-%d%s", kind, now.UTC().Format(snapshotTimeFormat), heap, snapshotSuffix)
+	file, err := os.OpenFile(filepath.Join(w.opts.Dir, name), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if err := pprof.Lookup(kind).WriteTo(file, 0); err != nil {
+		file.Close()
+		return fmt.Errorf("unable to write %s profile: %w", kind, err)
+	}
+	return file.Close()
+}
+
+// prune deletes the oldest snapshots of kind beyond the number which are kept
+func (w *Watcher) prune(kind string) error {
+	snapshots, err := w.Snapshots()
+	if err != nil {
+		return err
+	}
+	kept := 0
+	// newest first
+	for i := len(snapshots) - 1; i >= 0; i-- {
+		if snapshots[i].Kind != kind {
+			continue
+		}
+		if kept++; kept > w.opts.MaxSnapshots {
+			if err := os.Remove(filepath.Join(w.opts.Dir, snapshots[i].Name)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Snapshots lists the captured snapshots, oldest first
+func (w *Watcher) Snapshots() ([]sdk.ProfileSnapshot, error) {
+	entries, err := os.ReadDir(w.opts.Dir)
+	if err != nil {
+		return nil, err
+	}
+	snapshots := []sdk.ProfileSnapshot{}
+	for _, entry := range entries {
+		if snapshot, ok := parseSnapshot(entry.Name()); ok {
+			snapshots = append(snapshots, snapshot)
+		}
+	}
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		return snapshots[i].Time.Before(snapshots[j].Time)
+	})
+	return snapshots, nil
+}
+
+// Open opens the snapshot with name for reading. Names not listed by Snapshots are refused, so that no other file can
+// be read
+func (w *Watcher) Open(name string) (io.ReadCloser, error) {
+	if _, ok := parseSnapshot(name); !ok {
+		return nil, os.ErrNotExist
+	}
+	return os.Open(filepath.Join(w.opts.Dir, name))
+}
+
+// parseSnapshot parses the name of a snapshot written by capture
+func parseSnapshot(name string) (sdk.ProfileSnapshot, bool) {
+	parts := strings.Split(strings.TrimSuffix(name, snapshotSuffix), "-")
+	if len(parts) != 3 || !strings.HasSuffix(name, snapshotSuffix) {
+		return sdk.ProfileSnapshot{}, false
+	}
+	known := false
+	for _, kind := range kinds {
+		known = known || parts[0] == kind
+	}
+	at, err := time.Parse(snapshotTimeFormat, parts[1])
+	if err != nil || !known {
+		return sdk.ProfileSnapshot{}, false
+	}
+	heap, err := strconv.ParseUint(parts[2], 10, 64)
+	if err != nil {
+		return sdk.ProfileSnapshot{}, false
+	}
+	return sdk.ProfileSnapshot{Name: name, Kind: parts[0], Time: at, HeapBytes: heap}, true
+}
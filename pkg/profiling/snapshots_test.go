@@ -0,0 +1,78 @@
+package profiling
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rancher/csp-adapter/pkg/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatcher(t *testing.T) {
+	opts := DefaultOptions
+	opts.Dir = t.TempDir()
+	opts.MinHeapBytes = 100
+	opts.MaxSnapshots = 2
+	w, err := NewWatcher(opts)
+	require.NoError(t, err)
+
+	tests := []struct {
+		heap      uint64
+		snapshots int
+	}{
+		// the first sample is the baseline
+		{heap: 100},
+		{heap: 150},
+		{heap: 200, snapshots: 1},
+		// growing further needs another doubling
+		{heap: 300, snapshots: 1},
+		// the threshold follows the heap down
+		{heap: 120, snapshots: 1},
+		{heap: 240, snapshots: 2},
+		{heap: 480, snapshots: 3},
+		{heap: 960, snapshots: 4},
+	}
+	captured := testutil.ToFloat64(metrics.ProfileSnapshots)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, test := range tests {
+		heap := test.heap
+		w.heap = func() uint64 { return heap }
+		now = now.Add(time.Minute)
+		require.NoError(t, w.sample(now))
+		assert.Equal(t, float64(test.snapshots), testutil.ToFloat64(metrics.ProfileSnapshots)-captured, "sample %d", i)
+	}
+
+	snapshots, err := w.Snapshots()
+	require.NoError(t, err)
+	assert.Len(t, snapshots, 4, "only the last 2 snapshots of each kind should be kept")
+	assert.Equal(t, uint64(480), snapshots[0].HeapBytes)
+	assert.Equal(t, uint64(960), snapshots[3].HeapBytes)
+
+	file, err := w.Open(snapshots[3].Name)
+	require.NoError(t, err)
+	content, _ := io.ReadAll(file)
+	file.Close()
+	assert.NotEmpty(t, content)
+
+	_, err = w.Open("../../etc/passwd")
+	assert.Error(t, err, "only snapshots should be opened")
+}
+
+func TestWatcherMinHeap(t *testing.T) {
+	opts := DefaultOptions
+	opts.Dir = t.TempDir()
+	w, err := NewWatcher(opts)
+	require.NoError(t, err)
+
+	for _, heap := range []uint64{1 << 20, 4 << 20} {
+		heap := heap
+		w.heap = func() uint64 { return heap }
+		require.NoError(t, w.sample(time.Now()))
+	}
+	snapshots, err := w.Snapshots()
+	require.NoError(t, err)
+	assert.Empty(t, snapshots, "growth of a small heap shouldn't be captured")
+}
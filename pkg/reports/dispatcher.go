@@ -0,0 +1,228 @@
+package reports
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/metrics"
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/rancher/csp-adapter/pkg/supervisor"
+	"github.com/sirupsen/logrus"
+)
+
+// Options configures how writes to sinks are retried
+type Options struct {
+	// InitialBackoff is the time waited before the first retry of a failed write
+	InitialBackoff time.Duration
+	// MaxBackoff is the longest time waited between retries, the backoff doubles after every failed retry
+	MaxBackoff time.Duration
+	// Timeout limits each write
+	Timeout time.Duration
+}
+
+// DefaultOptions are the options used by the adapter
+var DefaultOptions = Options{
+	InitialBackoff: 1 * time.Second,
+	MaxBackoff:     5 * time.Minute,
+	Timeout:        30 * time.Second,
+}
+
+// Dispatcher writes each published report to every sink. Every sink has a writer of its own which retries failed
+// writes with backoff until they succeed or a newer report replaces the one being retried, since only the latest
+// report matters. The outcome of the writes is tracked per sink for the status
+type Dispatcher struct {
+	opts    Options
+	writers []*writer
+
+	lock     sync.Mutex
+	statuses map[string]*sdk.ReportSinkStatus
+	// names are the sinks in the order they're reported, starting with the configmap
+	names []string
+	// latest is the latest published report, rewritten by Export
+	latest []byte
+	// recorded is closed and replaced whenever the outcome of a write is recorded
+	recorded chan struct{}
+}
+
+// writer writes reports to a single sink
+type writer struct {
+	sink Sink
+	// latest holds the latest report which wasn't handed to the sink yet
+	latest chan []byte
+}
+
+// NewDispatcher returns a dispatcher writing to sinks. The configmap the manager writes itself is reported along with
+// them, see Record
+func NewDispatcher(opts Options, sinks ...Sink) *Dispatcher {
+	d := &Dispatcher{
+		opts:     opts,
+		statuses: map[string]*sdk.ReportSinkStatus{ConfigMapSink: {Name: ConfigMapSink}},
+		names:    []string{ConfigMapSink},
+		recorded: make(chan struct{}),
+	}
+	for _, sink := range sinks {
+		d.writers = append(d.writers, &writer{sink: sink, latest: make(chan []byte, 1)})
+		d.statuses[sink.Name()] = &sdk.ReportSinkStatus{Name: sink.Name()}
+		d.names = append(d.names, sink.Name())
+	}
+	return d
+}
+
+// Start runs the writer of each sink in a supervised goroutine until ctx is cancelled
+func (d *Dispatcher) Start(ctx context.Context) {
+	for _, w := range d.writers {
+		w := w
+		supervisor.Go(ctx, "report sink "+w.sink.Name(), func(ctx context.Context) {
+			d.run(ctx, w)
+		})
+	}
+}
+
+// Publish hands report to the writer of every sink, replacing a report they haven't written yet
+func (d *Dispatcher) Publish(report []byte) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.latest = report
+	for _, w := range d.writers {
+		// writers only receive, so once the report they didn't write yet is dropped there's room for report
+		select {
+		case <-w.latest:
+		default:
+		}
+		w.latest <- report
+		d.statuses[w.sink.Name()].Pending = true
+	}
+}
+
+// ErrNoReport is returned by Export before the first report is published
+var ErrNoReport = errors.New("no compliance report was published yet")
+
+// Export writes the latest report to every sink again and waits until each sink attempted the write, returning the
+// errors of the sinks which failed. The write goes through the writer of each sink, so that it can't overwrite a newer
+// report, and failed writes keep being retried in the background
+func (d *Dispatcher) Export(ctx context.Context, progress func(message string)) error {
+	d.lock.Lock()
+	report := d.latest
+	d.lock.Unlock()
+	if report == nil {
+		return ErrNoReport
+	}
+	start := time.Now()
+	d.Publish(report)
+	progress(fmt.Sprintf("writing the compliance report to %d sink(s)", len(d.writers)))
+	for {
+		d.lock.Lock()
+		recorded := d.recorded
+		var waiting int
+		var failed []string
+		for _, w := range d.writers {
+			status := d.statuses[w.sink.Name()]
+			switch {
+			case status.LastAttempt == nil || status.LastAttempt.Before(start):
+				waiting++
+			case status.LastError != "":
+				failed = append(failed, fmt.Sprintf("%s: %s", status.Name, status.LastError))
+			}
+		}
+		d.lock.Unlock()
+		if waiting == 0 {
+			if len(failed) > 0 {
+				return fmt.Errorf("unable to write the compliance report to %s", strings.Join(failed, ", "))
+			}
+			progress("wrote the compliance report to every sink")
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-recorded:
+		}
+	}
+}
+
+// Record tracks the outcome of a write of the report to the sink name made outside of the dispatcher, i.e. the
+// configmap written by the manager
+func (d *Dispatcher) Record(name string, err error) {
+	d.record(name, err, time.Now())
+}
+
+// Status returns the state of every sink
+func (d *Dispatcher) Status() []sdk.ReportSinkStatus {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	statuses := make([]sdk.ReportSinkStatus, 0, len(d.names))
+	for _, name := range d.names {
+		statuses = append(statuses, *d.statuses[name])
+	}
+	return statuses
+}
+
+// run writes the reports handed to w until ctx is cancelled
+func (d *Dispatcher) run(ctx context.Context, w *writer) {
+	for {
+		var report []byte
+		select {
+		case <-ctx.Done():
+			return
+		case report = <-w.latest:
+		}
+		backoff := d.opts.InitialBackoff
+		for !d.write(ctx, w.sink, report) {
+			select {
+			case <-ctx.Done():
+				return
+			case report = <-w.latest:
+				// the newer report is written right away instead of retrying the one it replaces
+				backoff = d.opts.InitialBackoff
+				continue
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+			if backoff > d.opts.MaxBackoff {
+				backoff = d.opts.MaxBackoff
+			}
+		}
+	}
+}
+
+// write attempts to write report to sink, returning whether it succeeded
+func (d *Dispatcher) write(ctx context.Context, sink Sink, report []byte) bool {
+	writeCtx, cancel := context.WithTimeout(ctx, d.opts.Timeout)
+	defer cancel()
+	err := sink.Write(writeCtx, report)
+	if err != nil {
+		logrus.Warnf("[reports] unable to write the compliance report to the %s sink, will retry: %v", sink.Name(), err)
+	}
+	d.record(sink.Name(), err, time.Now())
+	return err == nil
+}
+
+// record tracks the outcome of a write to the sink name at now
+func (d *Dispatcher) record(name string, err error, now time.Time) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	status, ok := d.statuses[name]
+	if !ok {
+		return
+	}
+	close(d.recorded)
+	d.recorded = make(chan struct{})
+	status.LastAttempt = sdk.OptionalTime(now)
+	if err != nil {
+		status.LastError = err.Error()
+		status.ConsecutiveFailures++
+		status.Pending = true
+		metrics.ReportWrites.WithLabelValues(name, "failed").Inc()
+		return
+	}
+	status.LastSuccess = sdk.OptionalTime(now)
+	status.LastError = ""
+	status.ConsecutiveFailures = 0
+	status.Pending = false
+	metrics.ReportWrites.WithLabelValues(name, "written").Inc()
+	metrics.ReportLastWritten.WithLabelValues(name).Set(float64(now.Unix()))
+}
@@ -0,0 +1,111 @@
+package reports
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memorySink records the reports written to it, failing while failing is set
+type memorySink struct {
+	name string
+
+	lock    sync.Mutex
+	failing bool
+	written [][]byte
+}
+
+func (s *memorySink) Name() string {
+	return s.name
+}
+
+func (s *memorySink) Write(ctx context.Context, report []byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.failing {
+		return errors.New("unavailable")
+	}
+	s.written = append(s.written, report)
+	return nil
+}
+
+func (s *memorySink) reports() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	var reports []string
+	for _, report := range s.written {
+		reports = append(reports, string(report))
+	}
+	return reports
+}
+
+func (s *memorySink) setFailing(failing bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.failing = failing
+}
+
+var testOptions = Options{InitialBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond, Timeout: time.Second}
+
+func TestDispatcher(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	healthy := &memorySink{name: "s3"}
+	failing := &memorySink{name: "webhook", failing: true}
+	dispatcher := NewDispatcher(testOptions, healthy, failing)
+	dispatcher.Start(ctx)
+
+	dispatcher.Record(ConfigMapSink, nil)
+	dispatcher.Publish([]byte("first"))
+	require.Eventually(t, func() bool { return len(healthy.reports()) == 1 }, time.Second, time.Millisecond,
+		"a failing sink doesn't hold up the others")
+	require.Eventually(t, func() bool { return dispatcher.Status()[2].ConsecutiveFailures > 1 }, time.Second, time.Millisecond,
+		"failed writes are retried")
+
+	statuses := dispatcher.Status()
+	require.Len(t, statuses, 3)
+	assert.Equal(t, ConfigMapSink, statuses[0].Name)
+	assert.NotNil(t, statuses[0].LastSuccess)
+	assert.Equal(t, "s3", statuses[1].Name)
+	assert.False(t, statuses[1].Pending)
+	assert.Equal(t, "webhook", statuses[2].Name)
+	assert.True(t, statuses[2].Pending)
+	assert.Equal(t, "unavailable", statuses[2].LastError)
+	assert.Nil(t, statuses[2].LastSuccess)
+
+	dispatcher.Publish([]byte("second"))
+	require.Eventually(t, func() bool { return len(healthy.reports()) == 2 }, time.Second, time.Millisecond)
+	failing.setFailing(false)
+	require.Eventually(t, func() bool { return !dispatcher.Status()[2].Pending }, time.Second, time.Millisecond)
+	written := failing.reports()
+	assert.Equal(t, "second", written[len(written)-1], "a newer report replaces the one being retried")
+	assert.Empty(t, dispatcher.Status()[2].LastError)
+	assert.Equal(t, []string{"first", "second"}, healthy.reports())
+}
+
+func TestDispatcherExport(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	healthy := &memorySink{name: "s3"}
+	failing := &memorySink{name: "webhook", failing: true}
+	dispatcher := NewDispatcher(testOptions, healthy, failing)
+	dispatcher.Start(ctx)
+	progress := func(message string) {}
+
+	assert.ErrorIs(t, dispatcher.Export(ctx, progress), ErrNoReport)
+
+	dispatcher.Publish([]byte("report"))
+	err := dispatcher.Export(ctx, progress)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "webhook: unavailable")
+	assert.Equal(t, "report", healthy.reports()[len(healthy.reports())-1])
+
+	failing.setFailing(false)
+	assert.NoError(t, dispatcher.Export(ctx, progress))
+	assert.Equal(t, "report", failing.reports()[len(failing.reports())-1])
+}
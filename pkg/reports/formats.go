@@ -0,0 +1,72 @@
+package reports
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Formats of the compliance report written to sinks
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+)
+
+// Renderer converts the json compliance report into the format the consumer of a sink reads, so that each sink can
+// be given its own format
+type Renderer interface {
+	// ContentType is the media type of rendered reports, for uploads and webhook requests
+	ContentType() string
+	Render(report []byte) ([]byte, error)
+}
+
+var renderers = map[string]Renderer{
+	FormatJSON: jsonRenderer{},
+	FormatYAML: yamlRenderer{},
+}
+
+// RendererFor returns the renderer for format, one of Formats. An empty format is json, the format rancher reads
+func RendererFor(format string) (Renderer, error) {
+	if format == "" {
+		format = FormatJSON
+	}
+	renderer, ok := renderers[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown report format %q, must be one of %s", format, strings.Join(Formats(), ", "))
+	}
+	return renderer, nil
+}
+
+// Formats returns the formats the compliance report can be written to sinks in
+func Formats() []string {
+	var formats []string
+	for format := range renderers {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// jsonRenderer passes the report through as rancher reads it
+type jsonRenderer struct{}
+
+func (jsonRenderer) ContentType() string {
+	return "application/json"
+}
+
+func (jsonRenderer) Render(report []byte) ([]byte, error) {
+	return report, nil
+}
+
+// yamlRenderer renders the same fields as jsonRenderer, for consumers which are reviewed by hand or kept in git
+type yamlRenderer struct{}
+
+func (yamlRenderer) ContentType() string {
+	return "application/yaml"
+}
+
+func (yamlRenderer) Render(report []byte) ([]byte, error) {
+	return yaml.JSONToYAML(report)
+}
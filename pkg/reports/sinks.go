@@ -0,0 +1,141 @@
+// Package reports writes the adapter's compliance reports, the csp config published to rancher, to sinks next to the
+// configmap rancher reads them from, i.e. a bucket kept for auditors or a webhook. Each sink is written independently
+// with its own retries, so that a failing sink neither holds up the others nor the compliance check
+package reports
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/rancher/csp-adapter/pkg/audit"
+	"github.com/rancher/csp-adapter/pkg/clients/aws"
+	"github.com/rancher/csp-adapter/pkg/secrets"
+	"github.com/rancher/csp-adapter/pkg/signing"
+)
+
+// Sink receives compliance reports, each report replaces the previous one
+type Sink interface {
+	// Name identifies the sink in logs, metrics and the status
+	Name() string
+	Write(ctx context.Context, report []byte) error
+}
+
+// ConfigMapSink names the configmap rancher reads the compliance report from, which the manager writes itself
+const ConfigMapSink = "configmap"
+
+// WebhookSink posts each report to a url, as json unless WithRenderer selects another format. The url and authorization are resolved for every report, so that
+// rotated credentials are used without restarting the adapter
+type WebhookSink struct {
+	url           secrets.Provider
+	authorization secrets.Provider
+	keys          signing.Source
+	renderer      Renderer
+	cli           *http.Client
+}
+
+// NewWebhookSink returns a sink posting to url. authorization is the value of the Authorization header sent with each
+// report, nil if the url authenticates the adapter itself
+func NewWebhookSink(url, authorization secrets.Provider) *WebhookSink {
+	return &WebhookSink{
+		url:           url,
+		authorization: authorization,
+		renderer:      jsonRenderer{},
+		cli:           &http.Client{},
+	}
+}
+
+// WithRenderer posts each report in the format of renderer
+func (s *WebhookSink) WithRenderer(renderer Renderer) *WebhookSink {
+	s.renderer = renderer
+	return s
+}
+
+// WithSigning signs each report with the active key of keys, in the header audit webhooks are signed in
+func (s *WebhookSink) WithSigning(keys signing.Source) *WebhookSink {
+	s.keys = keys
+	return s
+}
+
+func (s *WebhookSink) Name() string {
+	return "webhook"
+}
+
+func (s *WebhookSink) Write(ctx context.Context, report []byte) error {
+	url, err := s.url.Value(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to resolve report webhook url: %v", err)
+	}
+	report, err = s.renderer.Render(report)
+	if err != nil {
+		return fmt.Errorf("unable to render report: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(report))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", s.renderer.ContentType())
+	if s.authorization != nil {
+		authorization, err := s.authorization.Value(ctx)
+		if err != nil {
+			return fmt.Errorf("unable to resolve report webhook authorization: %v", err)
+		}
+		req.Header.Set("Authorization", authorization)
+	}
+	if s.keys != nil {
+		keyring, err := s.keys.Keyring()
+		if err != nil {
+			return fmt.Errorf("unable to sign report: %v", err)
+		}
+		req.Header.Set(audit.SignatureHeader, keyring.Sign(report).String())
+	}
+	res, err := s.cli.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusUnauthorized || res.StatusCode == http.StatusForbidden || res.StatusCode == http.StatusNotFound {
+		// the credentials were likely rotated since they were last read, the retry reads them again
+		secrets.Invalidate(s.url)
+		if s.authorization != nil {
+			secrets.Invalidate(s.authorization)
+		}
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("report webhook responded with %v", res.StatusCode)
+	}
+	return nil
+}
+
+// S3Sink stores each report as an object of a bucket, replacing the previous report. Enable versioning on the bucket to
+// keep every report
+type S3Sink struct {
+	bucket   aws.BucketClient
+	key      string
+	renderer Renderer
+}
+
+// NewS3Sink returns a sink storing reports as the object key of bucket, as json unless WithRenderer selects another
+// format
+func NewS3Sink(bucket aws.BucketClient, key string) *S3Sink {
+	return &S3Sink{bucket: bucket, key: key, renderer: jsonRenderer{}}
+}
+
+// WithRenderer stores each report in the format of renderer
+func (s *S3Sink) WithRenderer(renderer Renderer) *S3Sink {
+	s.renderer = renderer
+	return s
+}
+
+func (s *S3Sink) Name() string {
+	return "s3"
+}
+
+func (s *S3Sink) Write(ctx context.Context, report []byte) error {
+	report, err := s.renderer.Render(report)
+	if err != nil {
+		return fmt.Errorf("unable to render report: %v", err)
+	}
+	return s.bucket.PutObject(ctx, s.key, s.renderer.ContentType(), report)
+}
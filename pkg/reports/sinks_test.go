@@ -0,0 +1,59 @@
+package reports
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rancher/csp-adapter/pkg/secrets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookSink(t *testing.T) {
+	var received []byte
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var err error
+		received, err = ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		authorization = r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	report := []byte(`{"compliance":{"status":"InCompliance"}}`)
+	assert.NoError(t, NewWebhookSink(secrets.Literal(server.URL), secrets.Literal("Bearer abc")).Write(context.Background(), report))
+	assert.Equal(t, report, received)
+	assert.Equal(t, "Bearer abc", authorization)
+
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	assert.Error(t, NewWebhookSink(secrets.Literal(server.URL), nil).Write(context.Background(), report))
+}
+
+func TestWebhookSinkFormat(t *testing.T) {
+	var received []byte
+	var contentType string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var err error
+		received, err = ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		contentType = r.Header.Get("Content-Type")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	renderer, err := RendererFor(FormatYAML)
+	require.NoError(t, err)
+	sink := NewWebhookSink(secrets.Literal(server.URL), nil).WithRenderer(renderer)
+	assert.NoError(t, sink.Write(context.Background(), []byte(`{"compliance":{"status":"InCompliance"}}`)))
+	assert.Equal(t, "compliance:\n  status: InCompliance\n", string(received))
+	assert.Equal(t, "application/yaml", contentType)
+
+	_, err = RendererFor("csv")
+	assert.EqualError(t, err, `unknown report format "csv", must be one of json, yaml`)
+}
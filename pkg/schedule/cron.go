@@ -0,0 +1,145 @@
+// Package schedule parses cron expressions, which decide when the adapter runs full compliance checks
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed cron expression with the standard five fields: minute, hour, day of month, month and day of
+// week. Each field is *, a value, a range (a-b) or a list of those separated by commas, optionally with a step (*/15)
+type Schedule struct {
+	minutes, hours, daysOfMonth, months, daysOfWeek uint64
+	// domRestricted and dowRestricted are true if the day of month and day of week fields aren't *. Like in cron, a day
+	// matches if either restricted field matches
+	domRestricted, dowRestricted bool
+	location                     *time.Location
+}
+
+type field struct {
+	name     string
+	min, max int
+}
+
+var (
+	minuteField     = field{name: "minute", min: 0, max: 59}
+	hourField       = field{name: "hour", min: 0, max: 23}
+	dayOfMonthField = field{name: "day of month", min: 1, max: 31}
+	monthField      = field{name: "month", min: 1, max: 12}
+	// day of week 7 is accepted as sunday, like in most crons
+	dayOfWeekField = field{name: "day of week", min: 0, max: 7}
+)
+
+// Parse parses expr, whose times are evaluated in location
+func Parse(expr string, location *time.Location) (*Schedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression %q must have 5 fields, has %d", expr, len(fields))
+	}
+	s := &Schedule{location: location}
+	var err error
+	if s.minutes, err = minuteField.parse(fields[0]); err != nil {
+		return nil, err
+	}
+	if s.hours, err = hourField.parse(fields[1]); err != nil {
+		return nil, err
+	}
+	if s.daysOfMonth, err = dayOfMonthField.parse(fields[2]); err != nil {
+		return nil, err
+	}
+	if s.months, err = monthField.parse(fields[3]); err != nil {
+		return nil, err
+	}
+	if s.daysOfWeek, err = dayOfWeekField.parse(fields[4]); err != nil {
+		return nil, err
+	}
+	if s.daysOfWeek&(1<<7) != 0 {
+		s.daysOfWeek |= 1
+	}
+	s.domRestricted = fields[2] != "*"
+	s.dowRestricted = fields[4] != "*"
+	return s, nil
+}
+
+// parse returns the values matched by expr as a bit set
+func (f field) parse(expr string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(expr, ",") {
+		rangeExpr, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			rangeExpr = part[:i]
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step in %s field %q", f.name, part)
+			}
+		}
+		low, high := f.min, f.max
+		if rangeExpr != "*" {
+			bounds := strings.SplitN(rangeExpr, "-", 2)
+			var err error
+			if low, err = f.value(bounds[0]); err != nil {
+				return 0, err
+			}
+			high = low
+			if len(bounds) == 2 {
+				if high, err = f.value(bounds[1]); err != nil {
+					return 0, err
+				}
+			} else if step > 1 {
+				// a/step means from a to the end of the range
+				high = f.max
+			}
+			if high < low {
+				return 0, fmt.Errorf("invalid range in %s field %q", f.name, part)
+			}
+		}
+		for v := low; v <= high; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (f field) value(s string) (int, error) {
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("invalid %s %q, must be between %d and %d", f.name, s, f.min, f.max)
+	}
+	return v, nil
+}
+
+// maxSearch limits how far Next looks ahead, expressions like "0 0 30 2 *" never match
+const maxSearch = 5 * 366 * 24 * time.Hour
+
+// Next returns the first time after t matched by the schedule, or the zero time if there is none
+func (s *Schedule) Next(t time.Time) time.Time {
+	next := t.In(s.location).Truncate(time.Minute).Add(time.Minute)
+	limit := next.Add(maxSearch)
+	for next.Before(limit) {
+		switch {
+		case s.months&(1<<uint(next.Month())) == 0:
+			next = time.Date(next.Year(), next.Month()+1, 1, 0, 0, 0, 0, s.location)
+		case !s.dayMatches(next):
+			next = time.Date(next.Year(), next.Month(), next.Day()+1, 0, 0, 0, 0, s.location)
+		case s.hours&(1<<uint(next.Hour())) == 0:
+			// truncating would round in absolute time, which misses the hour in zones with half hour offsets
+			next = time.Date(next.Year(), next.Month(), next.Day(), next.Hour()+1, 0, 0, 0, s.location)
+		case s.minutes&(1<<uint(next.Minute())) == 0:
+			next = next.Add(time.Minute)
+		default:
+			return next
+		}
+	}
+	return time.Time{}
+}
+
+func (s *Schedule) dayMatches(t time.Time) bool {
+	dom := s.daysOfMonth&(1<<uint(t.Day())) != 0
+	dow := s.daysOfWeek&(1<<uint(t.Weekday())) != 0
+	if s.domRestricted && s.dowRestricted {
+		return dom || dow
+	}
+	return dom && dow
+}
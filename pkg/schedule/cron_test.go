@@ -0,0 +1,61 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNext(t *testing.T) {
+	// a wednesday
+	start := time.Date(2022, time.June, 1, 18, 30, 15, 0, time.UTC)
+	tests := []struct {
+		name string
+		expr string
+		want time.Time
+	}{
+		{name: "every minute", expr: "* * * * *", want: time.Date(2022, time.June, 1, 18, 31, 0, 0, time.UTC)},
+		{name: "step", expr: "*/15 * * * *", want: time.Date(2022, time.June, 1, 18, 45, 0, 0, time.UTC)},
+		{name: "business hours", expr: "*/10 9-17 * * 1-5", want: time.Date(2022, time.June, 2, 9, 0, 0, 0, time.UTC)},
+		{name: "weekends", expr: "0 12 * * 6,0", want: time.Date(2022, time.June, 4, 12, 0, 0, 0, time.UTC)},
+		{name: "sunday as 7", expr: "0 0 * * 7", want: time.Date(2022, time.June, 5, 0, 0, 0, 0, time.UTC)},
+		{name: "day of month or day of week", expr: "0 0 15 * 5", want: time.Date(2022, time.June, 3, 0, 0, 0, 0, time.UTC)},
+		{name: "next year", expr: "0 0 1 1 *", want: time.Date(2023, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{name: "never", expr: "0 0 30 2 *", want: time.Time{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, err := Parse(test.expr, time.UTC)
+			require.NoError(t, err)
+			assert.Equal(t, test.want, s.Next(start))
+		})
+	}
+}
+
+func TestNextLocation(t *testing.T) {
+	location := time.FixedZone("UTC+2", 2*60*60)
+	s, err := Parse("0 9 * * *", location)
+	require.NoError(t, err)
+	next := s.Next(time.Date(2022, time.June, 1, 8, 0, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2022, time.June, 2, 7, 0, 0, 0, time.UTC), next.UTC())
+}
+
+func TestNextHalfHourOffset(t *testing.T) {
+	// i.e. Asia/Kolkata
+	location := time.FixedZone("UTC+5:30", 5*60*60+30*60)
+	s, err := Parse("0 2 * * *", location)
+	require.NoError(t, err)
+	next := s.Next(time.Date(2022, time.June, 1, 12, 0, 0, 0, location))
+	assert.Equal(t, time.Date(2022, time.June, 2, 2, 0, 0, 0, location), next)
+	assert.Equal(t, time.Date(2022, time.June, 1, 20, 30, 0, 0, time.UTC), next.UTC())
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8",
+		"*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		_, err := Parse(expr, time.UTC)
+		assert.Errorf(t, err, "expected %q to be invalid", expr)
+	}
+}
@@ -0,0 +1,116 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	// StatusPath is the path that the adapter serves its Status document on
+	StatusPath = "/v1/status"
+	// JobsPath lists the jobs started through the admin api, a single job is served at JobsPath/<id>
+	JobsPath = "/v1/jobs"
+	// ProductsPath is the path that the adapter serves the catalog of known products on
+	ProductsPath = "/v1/products"
+	// InventoryPath is the path that the adapter serves the Inventory of its current checkout on
+	InventoryPath = "/v1/inventory"
+	// EntitlementsPath is the path that the adapter serves the Entitlements of its license on
+	EntitlementsPath = "/v1/entitlements"
+)
+
+// Client reads the status API of a running csp adapter
+type Client struct {
+	baseURL string
+	cli     *http.Client
+	token   string
+}
+
+// NewClient creates a Client for the adapter reachable at baseURL (i.e. http://rancher-csp-adapter.cattle-csp-adapter-system:8080).
+// If httpClient is nil, http.DefaultClient is used
+func NewClient(baseURL string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		cli:     httpClient,
+	}
+}
+
+// WithBearerToken sets a token (i.e. a service account token) which is sent with every request, for adapters which
+// authenticate callers using TokenReviews. Client certificates for mTLS are configured on the http.Client instead
+func (c *Client) WithBearerToken(token string) *Client {
+	c.token = token
+	return c
+}
+
+// GetStatus retrieves the current Status from the adapter
+func (c *Client) GetStatus(ctx context.Context) (*Status, error) {
+	var status Status
+	if err := c.get(ctx, StatusPath, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// GetProducts retrieves the rancher products known to the adapter and the licenses received for them
+func (c *Client) GetProducts(ctx context.Context) ([]Product, error) {
+	var products []Product
+	if err := c.get(ctx, ProductsPath, &products); err != nil {
+		return nil, err
+	}
+	return products, nil
+}
+
+// GetEntitlements retrieves the usage of the entitlements of the adapter's license. It's served from the adapter's
+// cache, see Entitlements.Freshness
+func (c *Client) GetEntitlements(ctx context.Context) (*Entitlements, error) {
+	var entitlements Entitlements
+	if err := c.get(ctx, EntitlementsPath, &entitlements); err != nil {
+		return nil, err
+	}
+	return &entitlements, nil
+}
+
+// GetInventory retrieves the Inventory which the adapter's current checkout is based on
+func (c *Client) GetInventory(ctx context.Context) (*Inventory, error) {
+	var inventory Inventory
+	if err := c.get(ctx, InventoryPath, &inventory); err != nil {
+		return nil, err
+	}
+	return &inventory, nil
+}
+
+// GetJob retrieves the job with the given id from the adapter
+func (c *Client) GetJob(ctx context.Context, id string) (*Job, error) {
+	var job Job
+	if err := c.get(ctx, JobsPath+"/"+url.PathEscape(id), &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// get issues a GET for path and decodes the json response body into out
+func (c *Client) get(ctx context.Context, path string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	res, err := c.cli.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("error got %v response from %s", res.StatusCode, path)
+	}
+	return json.NewDecoder(res.Body).Decode(out)
+}
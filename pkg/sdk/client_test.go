@@ -0,0 +1,60 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetStatus(t *testing.T) {
+	tests := []struct {
+		name          string
+		statusCode    int
+		status        Status
+		expectedError bool
+	}{
+		{
+			name:       "compliant status",
+			statusCode: http.StatusOK,
+			status: Status{
+				CSP:        "EC2",
+				Account:    "123456789101",
+				Compliance: ComplianceStatus{Status: ComplianceStatusCompliant},
+				Usage:      UsageSnapshot{Nodes: 40, RequiredLicenses: 2, CheckedOutLicenses: 2},
+			},
+			expectedError: false,
+		},
+		{
+			name:          "server error",
+			statusCode:    http.StatusInternalServerError,
+			expectedError: true,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != StatusPath {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				w.WriteHeader(test.statusCode)
+				_ = json.NewEncoder(w).Encode(test.status)
+			}))
+			defer server.Close()
+
+			status, err := NewClient(server.URL+"/", nil).GetStatus(context.Background())
+			if test.expectedError {
+				assert.Error(t, err, "expected an error but err was nil")
+				return
+			}
+			assert.NoError(t, err, "expected no error but there was an error")
+			assert.Equal(t, test.status, *status, "did not get expected status")
+			assert.Equal(t, test.status.Compliance.InCompliance(), status.Compliance.InCompliance())
+		})
+	}
+}
@@ -0,0 +1,578 @@
+// Package sdk provides dependency-light types and a client for reading the csp adapter's status API. It intentionally
+// avoids importing the cloud provider SDKs so that other tools can consume compliance data cheaply
+package sdk
+
+import "time"
+
+const (
+	// ComplianceStatusCompliant is reported when rancher holds the licenses it requires
+	ComplianceStatusCompliant = "Compliant"
+	// ComplianceStatusNonCompliant is reported when rancher does not hold the licenses it requires, or the adapter
+	// was unable to determine compliance
+	ComplianceStatusNonCompliant = "NonCompliant"
+	// ComplianceStatusUnknown is reported before the adapter has finished its first compliance check
+	ComplianceStatusUnknown = "Unknown"
+)
+
+// Reasons explain the compliance status, so that consumers can react to specific causes
+const (
+	// ReasonLicensed means that all required licenses are checked out
+	ReasonLicensed = "Licensed"
+	// ReasonInsufficientLicenses means that more licenses are required than could be checked out
+	ReasonInsufficientLicenses = "InsufficientLicenses"
+	// ReasonNoEntitlementsGranted means that the license was granted with 0 node entitlements
+	ReasonNoEntitlementsGranted = "NoEntitlementsGranted"
+	// ReasonEntitlementMissing means that the license was granted without the node entitlement
+	ReasonEntitlementMissing = "EntitlementMissing"
+	// ReasonGrantDisabled means that the seller disabled the grant of the license, so held licenses were checked in
+	ReasonGrantDisabled = "GrantDisabled"
+	// ReasonGrantRevoked means that the seller revoked the grant of the license, so held licenses were checked in
+	ReasonGrantRevoked = "GrantRevoked"
+	// ReasonIssuerUnknown means that the key fingerprint of the license's issuer is unknown, so it can't be checked out
+	ReasonIssuerUnknown = "IssuerUnknown"
+	// ReasonLicenseNotAvailable means that the license's status doesn't allow checkouts, i.e. it was suspended or deactivated
+	ReasonLicenseNotAvailable = "LicenseNotAvailable"
+	// ReasonLicenseExpired means that the license expired, either by its status or its validity period
+	ReasonLicenseExpired = "LicenseExpired"
+	// ReasonLicenseNotYetValid means that the validity period of the license hasn't begun yet
+	ReasonLicenseNotYetValid = "LicenseNotYetValid"
+	// ReasonRegionMismatch means that the license is homed in a different region than the adapter is configured for
+	ReasonRegionMismatch = "RegionMismatch"
+	// ReasonServiceUnavailable means that the compliance check failed while the license service was unavailable, so
+	// rancher may well be licensed once the service recovers
+	ReasonServiceUnavailable = "ServiceUnavailable"
+	// ReasonNodeCountUnavailable means that nodes couldn't be counted for several consecutive checks, so the adapter
+	// stopped renewing licenses at a count which may be stale
+	ReasonNodeCountUnavailable = "NodeCountUnavailable"
+	// ReasonEntitlementsUnverified means that strict mode is enabled and the availability of entitlements couldn't be
+	// verified for longer than allowed, so rancher is reported as non-compliant regardless of the licenses it holds
+	ReasonEntitlementsUnverified = "EntitlementsUnverified"
+	// ReasonDuplicateInstance means that another adapter instance is managing the same licenses, so this instance
+	// stopped changing them until the other one is removed
+	ReasonDuplicateInstance = "DuplicateInstance"
+	// ReasonSyntheticTest means that non-compliance was injected by an operator to test alerting, rancher still holds
+	// its licenses
+	ReasonSyntheticTest = "SyntheticTest"
+	// ReasonError means that the adapter was unable to complete the compliance check
+	ReasonError = "Error"
+)
+
+// Status is the document served by the adapter's status endpoint
+type Status struct {
+	CSP     string `json:"csp"`
+	Account string `json:"account"`
+	// AccountAlias is the alias of the account, empty if it has none or the adapter isn't allowed to read it
+	AccountAlias string `json:"accountAlias,omitempty"`
+	// Instance identifies the adapter instance serving the status
+	Instance   string           `json:"instance,omitempty"`
+	Compliance ComplianceStatus `json:"compliance"`
+	Usage      UsageSnapshot    `json:"usage"`
+	Service    ServiceHealth    `json:"service"`
+	// Anomalies are the unusual usage patterns which are currently detected
+	Anomalies []Anomaly `json:"anomalies,omitempty"`
+	// Timings are the durations of the phases of the most recent compliance check
+	Timings *CheckTimings `json:"timings,omitempty"`
+	// Features are the values of the adapter's feature flags, by flag name
+	Features map[string]bool `json:"features,omitempty"`
+	// Subscriptions describes the users subscribed to a product licensed per user, nil unless enabled
+	Subscriptions *SubscriptionStatus `json:"subscriptions,omitempty"`
+	// SLO describes the success of License Manager operations against the adapter's service level objective
+	SLO *SLOStatus `json:"slo,omitempty"`
+	// Shadow compares the adapter's decisions with those of a shadow planner, nil unless shadow mode is enabled
+	Shadow *ShadowStatus `json:"shadow,omitempty"`
+	// Paused is set while checkout adjustments are paused, nil otherwise
+	Paused *PauseStatus `json:"paused,omitempty"`
+	// Maintenance lists the declared AWS maintenance windows which haven't ended yet, earliest first
+	Maintenance []MaintenanceWindow `json:"maintenance,omitempty"`
+	// SyntheticTest is set while non-compliance is injected to test alerting, nil otherwise
+	SyntheticTest *SyntheticTestStatus `json:"syntheticTest,omitempty"`
+	// Conditions describe the adapter's setup, i.e. whether it was granted the permissions it needs, and whether its
+	// latest checkout was verified
+	Conditions []Condition `json:"conditions,omitempty"`
+	// MissingPermissions are the kubernetes permissions the adapter needs but wasn't granted, with the rbac rule which
+	// grants each of them
+	MissingPermissions []MissingPermission `json:"missingPermissions,omitempty"`
+	// Reports describes the writes of the compliance report to each sink, nil unless sinks besides the configmap are
+	// configured
+	Reports []ReportSinkStatus `json:"reports,omitempty"`
+	// Costs is the estimated monthly AWS cost of the adapter's integrations
+	Costs *CostEstimate `json:"costs,omitempty"`
+	// Sources describes the availability of the sources the status is built from. Degraded is set while any of them is
+	// stale, the fields it feeds then hold the values of its last successful read
+	Sources  []DataSourceStatus `json:"sources,omitempty"`
+	Degraded bool               `json:"degraded,omitempty"`
+}
+
+// Sources of the status
+const (
+	// SourceLicenseManager is AWS License Manager, which the license and its entitlements are read from
+	SourceLicenseManager = "licenseManager"
+	// SourceNodeCounts is rancher, which nodes are counted from
+	SourceNodeCounts = "nodeCounts"
+)
+
+// DataSourceStatus describes the availability of a source the status is built from
+type DataSourceStatus struct {
+	Name string `json:"name"`
+	// Fields are the fields of the status read from the source, as dotted json paths
+	Fields      []string   `json:"fields"`
+	LastSuccess *time.Time `json:"lastSuccess,omitempty"`
+	// LastError is the error of the last read if it failed, FailingSince when reads started failing
+	LastError    string     `json:"lastError,omitempty"`
+	FailingSince *time.Time `json:"failingSince,omitempty"`
+	// Stale is set while reads fail, the source's fields then hold the values of its last successful read
+	Stale bool `json:"stale"`
+}
+
+// CostEstimate is the estimated monthly AWS cost of the adapter's integrations, in USD. Requests are extrapolated from
+// those made since the adapter started
+type CostEstimate struct {
+	Since      time.Time       `json:"since"`
+	MonthlyUSD float64         `json:"monthlyUSD"`
+	Operations []OperationCost `json:"operations,omitempty"`
+	Resources  []ResourceCost  `json:"resources,omitempty"`
+}
+
+// OperationCost is the estimated monthly cost of the requests of an operation
+type OperationCost struct {
+	Service   string `json:"service"`
+	Operation string `json:"operation"`
+	// Calls are the requests made since the adapter started
+	Calls        int     `json:"calls"`
+	MonthlyCalls int     `json:"monthlyCalls"`
+	MonthlyUSD   float64 `json:"monthlyUSD"`
+}
+
+// ResourceCost is the monthly cost of a resource the adapter uses, i.e. a secret
+type ResourceCost struct {
+	Service    string  `json:"service"`
+	Resource   string  `json:"resource"`
+	MonthlyUSD float64 `json:"monthlyUSD"`
+}
+
+// ReportSinkStatus describes the writes of the compliance report to a sink
+type ReportSinkStatus struct {
+	// Name is the kind of the sink: configmap, s3 or webhook
+	Name string `json:"name"`
+	// LastSuccess is when the report was last written, which is how fresh the report in the sink is
+	LastSuccess *time.Time `json:"lastSuccess,omitempty"`
+	LastAttempt *time.Time `json:"lastAttempt,omitempty"`
+	// LastError is the error of the last write if it failed
+	LastError           string `json:"lastError,omitempty"`
+	ConsecutiveFailures int    `json:"consecutiveFailures,omitempty"`
+	// Pending is set while the latest report wasn't written to the sink yet
+	Pending bool `json:"pending,omitempty"`
+}
+
+// OptionalTime returns a pointer to t, or nil if t is zero, for the times of the status which are omitted while unset
+func OptionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// ConditionPermissionsGranted is true when the adapter was granted every kubernetes permission it needs
+const ConditionPermissionsGranted = "PermissionsGranted"
+
+// ConditionCheckoutVerified is true once the usage reported by License Manager reflects the adapter's latest checkout.
+// False flags a checkout which License Manager accepted but whose usage never materialized
+const ConditionCheckoutVerified = "CheckoutVerified"
+
+// ConditionTokenLimitApproaching is true while the consumption token is within a few extensions of the limit License
+// Manager allows, and describes when it's rotated with a fresh checkout
+const ConditionTokenLimitApproaching = "TokenLimitApproaching"
+
+// ConditionErrorBudgetExhausted is true while the error budget of License Manager operations within the SLO window is
+// spent, see SLOStatus
+const ConditionErrorBudgetExhausted = "ErrorBudgetExhausted"
+
+// Condition is an aspect of the adapter's setup, in the style of kubernetes conditions
+type Condition struct {
+	Type string `json:"type"`
+	// Status is True, False or Unknown
+	Status  string `json:"status"`
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message,omitempty"`
+	// LastTransitionTime is when Status last changed
+	LastTransitionTime time.Time `json:"lastTransitionTime"`
+}
+
+// MissingPermission is a kubernetes permission the adapter wasn't granted
+type MissingPermission struct {
+	Verb      string `json:"verb"`
+	Group     string `json:"group"`
+	Resource  string `json:"resource"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name,omitempty"`
+	// Rule is the rbac rule which grants the permission, to add to the rules of Role
+	Rule string `json:"rule"`
+	// Role is the kind of role the rule belongs to, a ClusterRole or a Role in the adapter's namespace
+	Role string `json:"role"`
+}
+
+// PauseStatus describes why checkout adjustments are paused. While paused, the held checkout is still renewed and
+// compliance is still reported, but no licenses are checked out or in
+type PauseStatus struct {
+	// By is who paused the adapter, i.e. the user of the admin api or the deployment annotation
+	By     string    `json:"by"`
+	Reason string    `json:"reason,omitempty"`
+	Since  time.Time `json:"since"`
+}
+
+// MaintenanceWindow is a declared period of planned AWS maintenance, while which License Manager is expected to be
+// unreliable. The checkout is frozen ahead of it: extended to its full lifetime shortly before the window starts, then
+// extended well ahead of its expiry so that failing extensions can be retried, and neither checked out nor checked in
+// until the window ends
+type MaintenanceWindow struct {
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	Reason string    `json:"reason,omitempty"`
+	// DeclaredBy is the user of the admin api who declared the window, empty for windows configured on the adapter
+	DeclaredBy string `json:"declaredBy,omitempty"`
+	// Frozen is true while the checkout is frozen for the window
+	Frozen bool `json:"frozen"`
+}
+
+// SyntheticTestStatus describes non-compliance injected by an operator to test alerting and notification routing end
+// to end. While it runs, compliance is reported as NonCompliant with ReasonSyntheticTest, but licenses are held and
+// renewed as usual
+type SyntheticTestStatus struct {
+	By     string    `json:"by"`
+	Reason string    `json:"reason,omitempty"`
+	Since  time.Time `json:"since"`
+	Until  time.Time `json:"until"`
+}
+
+// ProfileSnapshot is a heap or goroutine profile captured when the adapter's memory grew abnormally
+type ProfileSnapshot struct {
+	Name string `json:"name"`
+	// Kind is the pprof profile, heap or goroutine
+	Kind string    `json:"kind"`
+	Time time.Time `json:"time"`
+	// HeapBytes is the allocated heap which triggered the snapshot
+	HeapBytes uint64 `json:"heapBytes"`
+}
+
+// ShadowStatus describes how the decisions of a planner run in shadow mode compare with the adapter's
+type ShadowStatus struct {
+	Planner string `json:"planner"`
+	// Checks is the number of compliance checks the planner was compared in
+	Checks int `json:"checks"`
+	// Divergences is the number of those checks in which the planner decided differently
+	Divergences      int        `json:"divergences"`
+	LastDivergence   string     `json:"lastDivergence,omitempty"`
+	LastDivergenceAt *time.Time `json:"lastDivergenceAt,omitempty"`
+}
+
+// SLOStatus describes the success of License Manager operations within the rolling window of the service level
+// objective
+type SLOStatus struct {
+	// Target is the ratio of operations which should succeed
+	Target float64 `json:"target"`
+	Window string  `json:"window"`
+	// Operations is the number of operations within the window
+	Operations   int     `json:"operations"`
+	SuccessRatio float64 `json:"successRatio"`
+	// ErrorBudgetRemaining is the ratio of the error budget which isn't spent, negative once overspent
+	ErrorBudgetRemaining float64 `json:"errorBudgetRemaining"`
+	// BudgetExhausted is true while the error budget of the window is spent
+	BudgetExhausted bool `json:"budgetExhausted"`
+	// BurnRates are the rates the error budget is spent at within shorter windows, by window. A rate of 1 spends the
+	// budget exactly over the window of the objective
+	BurnRates map[string]float64 `json:"burnRates,omitempty"`
+}
+
+// SubscriptionStatus describes the result of the most recent sync of rancher users to the subscriptions of a product
+// licensed per user
+type SubscriptionStatus struct {
+	Product string `json:"product"`
+	// RancherUsers is the number of rancher users which should be subscribed, after deduplication and exclusions
+	RancherUsers int `json:"rancherUsers"`
+	// SubscribedUsers is the number of users subscribed to the product after the sync
+	SubscribedUsers int `json:"subscribedUsers"`
+	// Failed are the usernames whose subscription couldn't be started or stopped
+	Failed     []string  `json:"failed,omitempty"`
+	LastError  string    `json:"lastError,omitempty"`
+	LastSynced time.Time `json:"lastSynced"`
+}
+
+// ServiceHealth describes the availability of the CSP's license service, which is probed independently of compliance
+// checks
+type ServiceHealth struct {
+	Up          bool      `json:"up"`
+	LastError   string    `json:"lastError,omitempty"`
+	LastChecked time.Time `json:"lastChecked"`
+}
+
+// ComplianceStatus describes the result of the most recent compliance check
+type ComplianceStatus struct {
+	Status      string    `json:"status"`
+	Reason      string    `json:"reason"`
+	Message     string    `json:"message"`
+	LastChecked time.Time `json:"lastChecked"`
+}
+
+// UsageSnapshot describes the node usage and license consumption observed during the most recent compliance check
+type UsageSnapshot struct {
+	Nodes              int `json:"nodes"`
+	NodesPerLicense    int `json:"nodesPerLicense"`
+	RequiredLicenses   int `json:"requiredLicenses"`
+	MinimumLicenses    int `json:"minimumLicenses,omitempty"`
+	CheckedOutLicenses int `json:"checkedOutLicenses"`
+	// ExternalLicenses are consumed by checkouts which weren't made by the adapter, i.e. manually with the aws cli. They
+	// count against the entitlements of the license but don't cover any of rancher's nodes
+	ExternalLicenses int `json:"externalLicenses,omitempty"`
+	// DeclaredNodes is the node count the checkout converges to instead of Nodes, zero unless one is declared
+	DeclaredNodes int `json:"declaredNodes,omitempty"`
+	// FailedClusters are the downstream clusters whose nodes couldn't be counted. Nodes is based on the last count
+	// which succeeded for them
+	FailedClusters []string `json:"failedClusters,omitempty"`
+	// NodeCountSources holds the nodes counted by each source when node counts are cross-validated, Nodes is the largest
+	// of them. NodeCountDiverged is set if they differed by more than the configured threshold
+	NodeCountSources  map[string]int `json:"nodeCountSources,omitempty"`
+	NodeCountDiverged bool           `json:"nodeCountDiverged,omitempty"`
+	// NodesByEnvironment holds the nodes counted in each environment when clusters are classified as production or
+	// non-production. LicensedNodes is the number of nodes licenses are required for, with non-production nodes counted
+	// at the configured ratio
+	NodesByEnvironment map[string]int `json:"nodesByEnvironment,omitempty"`
+	LicensedNodes      int            `json:"licensedNodes,omitempty"`
+	// Exemptions are the active ClusterLicenseExemptions, ExemptNodes the nodes of their clusters which aren't licensed
+	Exemptions  []LicenseExemption `json:"exemptions,omitempty"`
+	ExemptNodes int                `json:"exemptNodes,omitempty"`
+	// CheckoutRequests are the LicenseCheckoutRequests, RequestedLicenses the licenses held by their checkouts. These
+	// are checked out separately and aren't part of CheckedOutLicenses or ExternalLicenses
+	CheckoutRequests  []CheckoutRequest `json:"checkoutRequests,omitempty"`
+	RequestedLicenses int               `json:"requestedLicenses,omitempty"`
+	// TokenExtensions is the number of times the current consumption token was extended. The token is rotated with a
+	// fresh checkout before reaching the limit of extensions
+	TokenExtensions int `json:"tokenExtensions,omitempty"`
+	// CheckoutExpiry is when the checked out licenses are returned unless renewed, CheckoutRenewsAt when the adapter
+	// renews them next. Both are zero if nothing is checked out
+	CheckoutExpiry   *time.Time `json:"checkoutExpiry,omitempty"`
+	CheckoutRenewsAt *time.Time `json:"checkoutRenewsAt,omitempty"`
+	// PendingCheckIns is the number of tokens no longer held whose check-in failed and is retried
+	PendingCheckIns int `json:"pendingCheckIns,omitempty"`
+	// OverAllocationMode is what happens to checked out licenses which are no longer required after scaling down, one
+	// of the OverAllocation constants
+	OverAllocationMode string `json:"overAllocationMode,omitempty"`
+	// ZeroNodeMode is how the checkout is handled while no nodes are licensed, one of the ZeroNodes constants. Only set
+	// while no nodes are
+	ZeroNodeMode string `json:"zeroNodeMode,omitempty"`
+	// ExcessReleaseAt is when licenses checked out beyond the required licenses are checked in, zero if there are none
+	ExcessReleaseAt *time.Time `json:"excessReleaseAt,omitempty"`
+	ObservedAt      time.Time  `json:"observedAt"`
+}
+
+// LicenseExemption is an active ClusterLicenseExemption, declaring a downstream cluster exempt from entitlement counting
+type LicenseExemption struct {
+	Name      string    `json:"name"`
+	ClusterID string    `json:"clusterId"`
+	Reason    string    `json:"reason"`
+	Expires   time.Time `json:"expires"`
+	// Nodes are the nodes counted for the cluster which aren't licensed
+	Nodes int `json:"nodes"`
+}
+
+// CheckoutRequest is a LicenseCheckoutRequest, requesting a checkout of Count licenses until Expires
+type CheckoutRequest struct {
+	Name   string `json:"name"`
+	Count  int    `json:"count"`
+	Reason string `json:"reason,omitempty"`
+	// Phase is Pending until the checkout is made, then Active until it expires, or Failed
+	Phase   string     `json:"phase"`
+	Message string     `json:"message,omitempty"`
+	Expires *time.Time `json:"expires,omitempty"`
+}
+
+// Environments downstream clusters are classified as, which may be accounted for differently
+const (
+	EnvironmentProduction    = "production"
+	EnvironmentNonProduction = "non-production"
+)
+
+// Modes of handling licenses which are no longer required after scaling down
+const (
+	// OverAllocationCheckIn checks in excess licenses on the next compliance check
+	OverAllocationCheckIn = "checkin"
+	// OverAllocationRetain keeps excess licenses for a retention period, so that nodes which come back shortly after
+	// don't cause a check-in and checkout each
+	OverAllocationRetain = "retain"
+	// OverAllocationExpire keeps excess licenses until the consumption token expires instead of extending it
+	OverAllocationExpire = "expire"
+)
+
+// Modes of handling checks which license no nodes, i.e. on a fresh install or after every cluster was deleted
+const (
+	// ZeroNodesScaleDown handles reaching zero nodes like any other scale-down, keeping MinimumLicenses checked out and
+	// handling the excess by the over-allocation mode
+	ZeroNodesScaleDown = "scale-down"
+	// ZeroNodesHoldMinimum keeps at least one license checked out, or MinimumLicenses if more, so that a fresh install
+	// holds a checkout before its first cluster is added
+	ZeroNodesHoldMinimum = "hold-minimum"
+	// ZeroNodesCheckIn checks in every license, including MinimumLicenses, once the scale-down is confirmed
+	ZeroNodesCheckIn = "checkin"
+	// ZeroNodesGrace keeps the last checkout for a grace period, i.e. while clusters are rebuilt, before checking in the
+	// licenses which are no longer required
+	ZeroNodesGrace = "grace"
+)
+
+// InCompliance returns true if the status reports that rancher is compliant
+func (s ComplianceStatus) InCompliance() bool {
+	return s.Status == ComplianceStatusCompliant
+}
+
+// ClusterSummary is the compliance summary published for a single downstream cluster, so that the owners of the cluster
+// can see its consumption without access to the adapter's namespace
+type ClusterSummary struct {
+	ClusterID string `json:"clusterId"`
+	// Nodes is the number of nodes of this cluster counted towards license consumption
+	Nodes int `json:"nodes"`
+	// TotalNodes is the number of nodes counted across all downstream clusters
+	TotalNodes int `json:"totalNodes"`
+	// Environment is the environment the cluster is classified as, empty unless clusters are classified
+	Environment string           `json:"environment,omitempty"`
+	Compliance  ComplianceStatus `json:"compliance"`
+	ObservedAt  time.Time        `json:"observedAt"`
+}
+
+// Job states, in the order a job moves through them. A failed attempt which will be retried returns the job to
+// JobStatePending
+const (
+	JobStatePending   = "Pending"
+	JobStateRunning   = "Running"
+	JobStateSucceeded = "Succeeded"
+	JobStateFailed    = "Failed"
+)
+
+// Job describes a long-running operation started through the admin api. Handlers starting an operation return the job
+// immediately, its progress can then be followed through JobsPath
+type Job struct {
+	ID   string `json:"id"`
+	Kind string `json:"kind"`
+	// RequestedBy is the user who started the job and the address they called from, resolved through trusted proxies
+	RequestedBy string `json:"requestedBy,omitempty"`
+	// State is one of the JobState constants
+	State string `json:"state"`
+	// Progress is the latest progress message reported by the running operation
+	Progress string `json:"progress,omitempty"`
+	// Attempts is the number of times the operation was started, including retries
+	Attempts   int        `json:"attempts"`
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+}
+
+// Done returns true if the job won't make any further progress
+func (j Job) Done() bool {
+	return j.State == JobStateSucceeded || j.State == JobStateFailed
+}
+
+// Anomaly types, describing unusual usage patterns which may lead to unexpected overuse
+const (
+	// AnomalyNodeSurge means that the node count at least doubled within the detection window, i.e. due to a runaway
+	// autoscaler or a counting bug
+	AnomalyNodeSurge = "NodeSurge"
+	// AnomalyCheckoutChurn means that licenses were repeatedly checked in and out within the detection window
+	AnomalyCheckoutChurn = "CheckoutChurn"
+)
+
+// Anomaly is an unusual usage pattern detected in the usage history of the adapter
+type Anomaly struct {
+	// Type is one of the Anomaly constants
+	Type       string    `json:"type"`
+	Message    string    `json:"message"`
+	DetectedAt time.Time `json:"detectedAt"`
+}
+
+// Freshness describes how current a response the adapter serves from its cache of License Manager reads is. The
+// response also carries an Age header, and a Warning header while it's stale
+type Freshness struct {
+	// FetchedAt is when the data was read from License Manager
+	FetchedAt time.Time `json:"fetchedAt"`
+	// Stale is set once the data is older than the cache's ttl, while it's refreshed in the background
+	Stale bool `json:"stale,omitempty"`
+	// RefreshError is the error of the last background refresh if it failed, the data is from before it
+	RefreshError string `json:"refreshError,omitempty"`
+}
+
+// Entitlements is the usage of the entitlements of the rancher license, by anyone in the account
+type Entitlements struct {
+	LicenseArn string `json:"licenseArn"`
+	Dimension  string `json:"dimension"`
+	Max        int    `json:"max"`
+	Consumed   int    `json:"consumed"`
+	Available  int    `json:"available"`
+	Freshness
+}
+
+// Product describes a rancher product sku which can be purchased through the CSP's marketplace, and the license
+// received for it, so that installers can confirm that they accepted the right offer
+type Product struct {
+	Name string `json:"name"`
+	SKU  string `json:"sku"`
+	// Found is true if a license for the sku was received by the account
+	Found bool `json:"found"`
+	// Active is true if the license found for the sku can be used
+	Active bool `json:"active"`
+	// Matched is true for the product whose license is used by the adapter
+	Matched    bool               `json:"matched"`
+	LicenseArn string             `json:"licenseArn,omitempty"`
+	Status     string             `json:"status,omitempty"`
+	Dimensions []ProductDimension `json:"dimensions,omitempty"`
+}
+
+// ProductDimension is an entitlement carried by a product's license
+type ProductDimension struct {
+	Name     string `json:"name"`
+	Unit     string `json:"unit"`
+	MaxCount int64  `json:"maxCount"`
+}
+
+// Inventory is a point-in-time record of what the current checkout was based on: the clusters and nodes which were
+// counted, the license which was checked out and the licenses which are held. It can be attached to change tickets
+type Inventory struct {
+	CapturedAt     time.Time `json:"capturedAt"`
+	Account        string    `json:"account"`
+	AccountAlias   string    `json:"accountAlias,omitempty"`
+	RancherVersion string    `json:"rancherVersion,omitempty"`
+	// Nodes is the number of nodes counted across all downstream clusters
+	Nodes              int                `json:"nodes"`
+	Clusters           []InventoryCluster `json:"clusters"`
+	License            InventoryLicense   `json:"license"`
+	RequiredLicenses   int                `json:"requiredLicenses"`
+	CheckedOutLicenses int                `json:"checkedOutLicenses"`
+}
+
+// InventoryCluster is a downstream cluster counted towards license consumption
+type InventoryCluster struct {
+	ID                string `json:"id"`
+	Name              string `json:"name,omitempty"`
+	KubernetesVersion string `json:"kubernetesVersion,omitempty"`
+	// Environment is the environment the cluster is classified as, empty unless clusters are classified
+	Environment string `json:"environment,omitempty"`
+	Nodes       int    `json:"nodes"`
+}
+
+// InventoryLicense is the license which licenses were checked out from
+type InventoryLicense struct {
+	Arn          string             `json:"arn"`
+	ProductSKU   string             `json:"productSku,omitempty"`
+	Status       string             `json:"status,omitempty"`
+	Entitlements []ProductDimension `json:"entitlements,omitempty"`
+}
+
+// CheckTimings are the durations of a compliance check and its phases, so that operators can spot which phase slows
+// down as their environment grows
+type CheckTimings struct {
+	TotalSeconds float64       `json:"totalSeconds"`
+	Phases       []PhaseTiming `json:"phases"`
+}
+
+// PhaseTiming is the time spent in a phase of a compliance check: getting the license, counting nodes, getting the
+// license usage, changing the checkout or writing the status
+type PhaseTiming struct {
+	Phase   string  `json:"phase"`
+	Seconds float64 `json:"seconds"`
+}
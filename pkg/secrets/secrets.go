@@ -0,0 +1,184 @@
+// Package secrets resolves credentials of the adapter's notification integrations (i.e. the audit webhook) from where
+// they are stored, so that they can be kept in an external secret store and rotated without restarting the adapter
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/clients/aws"
+	"github.com/sirupsen/logrus"
+)
+
+// Provider returns the current value of a secret. Values may change between calls when the secret is rotated, so
+// callers should get the value each time they use it rather than keeping it
+type Provider interface {
+	Value(ctx context.Context) (string, error)
+}
+
+// Literal is a secret configured directly, i.e. in an environment variable populated from a Kubernetes Secret
+type Literal string
+
+func (l Literal) Value(ctx context.Context) (string, error) {
+	return string(l), nil
+}
+
+// File is a secret read from a file, i.e. a mounted Kubernetes Secret or a file rendered by the Vault agent injector.
+// The file is read again whenever it's modified, so rotated values are picked up
+type File struct {
+	path string
+
+	lock    sync.Mutex
+	modTime time.Time
+	value   string
+}
+
+func NewFile(path string) *File {
+	return &File{path: path}
+}
+
+func (f *File) Value(ctx context.Context) (string, error) {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return "", fmt.Errorf("unable to read secret file: %v", err)
+	}
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if !info.ModTime().Equal(f.modTime) {
+		data, err := ioutil.ReadFile(f.path)
+		if err != nil {
+			return "", fmt.Errorf("unable to read secret file: %v", err)
+		}
+		// editors and templates usually end files with a newline, which is never part of the secret
+		f.value = strings.TrimRight(string(data), "\r\n")
+		f.modTime = info.ModTime()
+	}
+	return f.value, nil
+}
+
+// DefaultRefreshInterval is how long values read from a secret store are used before they are read again
+const DefaultRefreshInterval = 5 * time.Minute
+
+// SecretsManager is a secret stored in AWS Secrets Manager. Values are cached for the refresh interval, so that the
+// secret isn't read for every notification, and can be invalidated early when a credential is rejected
+type SecretsManager struct {
+	client  aws.SecretsClient
+	id      string
+	key     string
+	refresh time.Duration
+
+	lock    sync.Mutex
+	value   string
+	expires time.Time
+}
+
+// NewSecretsManager returns the secret with id (an arn or name). If key is set the secret is expected to hold a json
+// object, and the value is its field key, as with key/value secrets created in the console
+func NewSecretsManager(client aws.SecretsClient, id, key string, refresh time.Duration) *SecretsManager {
+	return &SecretsManager{
+		client:  client,
+		id:      id,
+		key:     key,
+		refresh: refresh,
+	}
+}
+
+func (s *SecretsManager) Value(ctx context.Context) (string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	now := time.Now()
+	if now.Before(s.expires) {
+		return s.value, nil
+	}
+	value, err := s.read(ctx)
+	if err != nil {
+		if s.value == "" {
+			return "", err
+		}
+		// a credential which may have been rotated is more likely to work than none at all
+		logrus.Warnf("[secrets] unable to refresh secret %s, using the previous value: %v", s.id, err)
+		return s.value, nil
+	}
+	s.value = value
+	s.expires = now.Add(s.refresh)
+	return value, nil
+}
+
+// Invalidate makes the next Value read the secret again
+func (s *SecretsManager) Invalidate() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.expires = time.Time{}
+}
+
+func (s *SecretsManager) read(ctx context.Context) (string, error) {
+	value, err := s.client.GetSecretValue(ctx, s.id)
+	if err != nil || s.key == "" {
+		return value, err
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(value), &fields); err != nil {
+		return "", fmt.Errorf("secret %s isn't a json object, so key %s can't be read from it", s.id, s.key)
+	}
+	field, ok := fields[s.key]
+	if !ok {
+		return "", fmt.Errorf("secret %s has no key %s", s.id, s.key)
+	}
+	if str, ok := field.(string); ok {
+		return str, nil
+	}
+	return fmt.Sprint(field), nil
+}
+
+// Invalidate makes providers which cache values read the secret again the next time it's used, i.e. after a
+// credential was rejected because it was rotated. Providers which don't cache values are left as they are
+func Invalidate(p Provider) {
+	if i, ok := p.(interface{ Invalidate() }); ok {
+		i.Invalidate()
+	}
+}
+
+// Reference schemes understood by Parse
+const (
+	fileScheme           = "file://"
+	secretsManagerScheme = "aws-secretsmanager://"
+)
+
+// Parse returns the provider for a secret reference:
+//   - file:///vault/secrets/webhook reads the secret from a file
+//   - aws-secretsmanager://rancher/webhook#url reads the field url of the secret rancher/webhook from AWS Secrets
+//     Manager, the #key suffix is optional
+//
+// Anything else is taken literally. newClient is only called for references to AWS Secrets Manager
+func Parse(ref string, newClient func() (aws.SecretsClient, error)) (Provider, error) {
+	switch {
+	case strings.HasPrefix(ref, fileScheme):
+		path := strings.TrimPrefix(ref, fileScheme)
+		if path == "" {
+			return nil, fmt.Errorf("secret reference %q has no path", ref)
+		}
+		return NewFile(path), nil
+	case strings.HasPrefix(ref, secretsManagerScheme):
+		id := strings.TrimPrefix(ref, secretsManagerScheme)
+		var key string
+		if i := strings.LastIndex(id, "#"); i >= 0 {
+			id, key = id[:i], id[i+1:]
+		}
+		if id == "" {
+			return nil, fmt.Errorf("secret reference %q has no secret id", ref)
+		}
+		client, err := newClient()
+		if err != nil {
+			return nil, err
+		}
+		return NewSecretsManager(client, id, key, DefaultRefreshInterval), nil
+	default:
+		return Literal(ref), nil
+	}
+}
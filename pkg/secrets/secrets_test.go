@@ -0,0 +1,149 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/clients/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSecretsClient struct {
+	values map[string]string
+	err    error
+	calls  int
+}
+
+func (f *fakeSecretsClient) GetSecretValue(ctx context.Context, id string) (string, error) {
+	f.calls++
+	if f.err != nil {
+		return "", f.err
+	}
+	value, ok := f.values[id]
+	if !ok {
+		return "", errors.New("not found")
+	}
+	return value, nil
+}
+
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "webhook")
+	require.NoError(t, ioutil.WriteFile(path, []byte("https://siem.example.com/1\n"), 0600))
+	file := NewFile(path)
+	ctx := context.Background()
+
+	value, err := file.Value(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "https://siem.example.com/1", value)
+
+	// rotated by the vault agent
+	require.NoError(t, ioutil.WriteFile(path, []byte("https://siem.example.com/2"), 0600))
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(path, later, later))
+	value, err = file.Value(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "https://siem.example.com/2", value)
+
+	require.NoError(t, os.Remove(path))
+	_, err = file.Value(ctx)
+	assert.Error(t, err)
+}
+
+func TestSecretsManager(t *testing.T) {
+	client := &fakeSecretsClient{values: map[string]string{
+		"rancher/webhook": `{"url":"https://siem.example.com/1","token":"abc"}`,
+	}}
+	secret := NewSecretsManager(client, "rancher/webhook", "token", time.Hour)
+	ctx := context.Background()
+
+	value, err := secret.Value(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "abc", value)
+
+	// cached until invalidated
+	client.values["rancher/webhook"] = `{"token":"def"}`
+	value, err = secret.Value(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "abc", value)
+	assert.Equal(t, 1, client.calls)
+
+	Invalidate(secret)
+	value, err = secret.Value(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "def", value)
+
+	// the previous value is kept if the secret can't be refreshed
+	client.err = errors.New("throttled")
+	Invalidate(secret)
+	value, err = secret.Value(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "def", value)
+
+	_, err = NewSecretsManager(client, "rancher/webhook", "", time.Hour).Value(ctx)
+	assert.Error(t, err)
+	client.err = nil
+	_, err = NewSecretsManager(client, "rancher/webhook", "missing", time.Hour).Value(ctx)
+	assert.Error(t, err)
+}
+
+func TestParse(t *testing.T) {
+	client := &fakeSecretsClient{values: map[string]string{"rancher/webhook": "https://siem.example.com"}}
+	newClient := func() (aws.SecretsClient, error) {
+		return client, nil
+	}
+	tests := []struct {
+		name      string
+		ref       string
+		expected  Provider
+		expectErr bool
+	}{
+		{
+			name:     "literal",
+			ref:      "https://siem.example.com",
+			expected: Literal("https://siem.example.com"),
+		},
+		{
+			name:     "file",
+			ref:      "file:///vault/secrets/webhook",
+			expected: NewFile("/vault/secrets/webhook"),
+		},
+		{
+			name:     "secrets manager",
+			ref:      "aws-secretsmanager://rancher/webhook",
+			expected: NewSecretsManager(client, "rancher/webhook", "", DefaultRefreshInterval),
+		},
+		{
+			name:     "secrets manager key",
+			ref:      "aws-secretsmanager://arn:aws:secretsmanager:us-east-1:123456789101:secret:rancher#url",
+			expected: NewSecretsManager(client, "arn:aws:secretsmanager:us-east-1:123456789101:secret:rancher", "url", DefaultRefreshInterval),
+		},
+		{
+			name:      "secrets manager without id",
+			ref:       "aws-secretsmanager://#url",
+			expectErr: true,
+		},
+		{
+			name:      "file without path",
+			ref:       "file://",
+			expectErr: true,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			provider, err := Parse(test.ref, newClient)
+			if test.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, provider)
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	authv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	authclient "k8s.io/client-go/kubernetes/typed/authentication/v1"
+)
+
+// Authenticator determines the identity of the caller of a request
+type Authenticator interface {
+	// Authenticate returns the name of the user which issued r, or an error if the request could not be authenticated
+	Authenticate(r *http.Request) (string, error)
+}
+
+var errUnauthenticated = errors.New("request could not be authenticated")
+
+// ClientCertAuthenticator authenticates requests which presented a client certificate verified during the tls
+// handshake. Only useful when the server requires client certificates (see Options.ClientCAFile)
+type ClientCertAuthenticator struct{}
+
+func (ClientCertAuthenticator) Authenticate(r *http.Request) (string, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return "", errUnauthenticated
+	}
+	return r.TLS.VerifiedChains[0][0].Subject.CommonName, nil
+}
+
+const (
+	// defaultTokenReviewTTL is how long the outcome of a TokenReview is reused. Dashboards polling the status send the
+	// same token every few seconds, each of which would otherwise cost a call to the kubernetes api. Tokens revoked in
+	// the meantime are rejected once their review expires
+	defaultTokenReviewTTL = 10 * time.Second
+	// maxCachedTokenReviews bounds the cached reviews, so that callers sending random tokens can't grow the cache
+	maxCachedTokenReviews = 1000
+)
+
+// TokenReviewAuthenticator authenticates bearer tokens using the kubernetes TokenReview api. If AllowedUsers or
+// AllowedGroups are set, the authenticated user must match at least one of them. The outcome of each review is reused
+// for CacheTTL
+type TokenReviewAuthenticator struct {
+	TokenReviews  authclient.TokenReviewInterface
+	AllowedUsers  []string
+	AllowedGroups []string
+	// CacheTTL is how long the outcome of a review is reused, defaultTokenReviewTTL if zero
+	CacheTTL time.Duration
+
+	lock sync.Mutex
+	// reviews are keyed by the sha256 of the token, so that tokens aren't kept in memory
+	reviews map[[sha256.Size]byte]cachedReview
+	now     func() time.Time
+}
+
+// cachedReview is the outcome of a successful TokenReview
+type cachedReview struct {
+	status    authv1.TokenReviewStatus
+	expiresAt time.Time
+}
+
+func (t *TokenReviewAuthenticator) Authenticate(r *http.Request) (string, error) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" || token == r.Header.Get("Authorization") {
+		return "", errUnauthenticated
+	}
+	status, err := t.review(r.Context(), token)
+	if err != nil {
+		return "", fmt.Errorf("unable to review token: %v", err)
+	}
+	if !status.Authenticated {
+		return "", errUnauthenticated
+	}
+	user := status.User
+	if !t.isAllowed(user) {
+		return "", fmt.Errorf("user %s is not allowed to use the api", user.Username)
+	}
+	return user.Username, nil
+}
+
+// review returns the status of a TokenReview of token, reusing the review of the same token within the ttl. Reviews
+// which fail aren't cached, so that an unavailable api doesn't lock callers out for longer than it's unavailable
+func (t *TokenReviewAuthenticator) review(ctx context.Context, token string) (authv1.TokenReviewStatus, error) {
+	key := sha256.Sum256([]byte(token))
+	now := time.Now()
+	if t.now != nil {
+		now = t.now()
+	}
+	t.lock.Lock()
+	cached, ok := t.reviews[key]
+	t.lock.Unlock()
+	if ok && now.Before(cached.expiresAt) {
+		return cached.status, nil
+	}
+	review, err := t.TokenReviews.Create(ctx, &authv1.TokenReview{
+		Spec: authv1.TokenReviewSpec{Token: token},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return authv1.TokenReviewStatus{}, err
+	}
+	ttl := t.CacheTTL
+	if ttl == 0 {
+		ttl = defaultTokenReviewTTL
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.reviews == nil {
+		t.reviews = map[[sha256.Size]byte]cachedReview{}
+	}
+	if len(t.reviews) >= maxCachedTokenReviews {
+		for cachedKey, cached := range t.reviews {
+			if !now.Before(cached.expiresAt) {
+				delete(t.reviews, cachedKey)
+			}
+		}
+	}
+	if len(t.reviews) < maxCachedTokenReviews {
+		t.reviews[key] = cachedReview{status: review.Status, expiresAt: now.Add(ttl)}
+	}
+	return review.Status, nil
+}
+
+func (t *TokenReviewAuthenticator) isAllowed(user authv1.UserInfo) bool {
+	if len(t.AllowedUsers) == 0 && len(t.AllowedGroups) == 0 {
+		return true
+	}
+	return isAllowed(user.Username, user.Groups, t.AllowedUsers, t.AllowedGroups)
+}
+
+// isAllowed returns whether user is one of allowedUsers or a member of one of allowedGroups
+func isAllowed(user string, groups, allowedUsers, allowedGroups []string) bool {
+	for _, allowed := range allowedUsers {
+		if user == allowed {
+			return true
+		}
+	}
+	for _, allowed := range allowedGroups {
+		for _, group := range groups {
+			if group == allowed {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// AnyAuthenticator authenticates a request if any of its Authenticators can, trying each in order
+type AnyAuthenticator []Authenticator
+
+func (a AnyAuthenticator) Authenticate(r *http.Request) (string, error) {
+	var errs []string
+	for _, authenticator := range a {
+		user, err := authenticator.Authenticate(r)
+		if err == nil {
+			return user, nil
+		}
+		errs = append(errs, err.Error())
+	}
+	return "", fmt.Errorf("unable to authenticate request: %s", strings.Join(errs, ", "))
+}
+
+type userKey struct{}
+
+// UserFromContext returns the authenticated user stored in ctx by the server, if any
+func UserFromContext(ctx context.Context) string {
+	user, _ := ctx.Value(userKey{}).(string)
+	return user
+}
@@ -0,0 +1,172 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	authv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const validToken = "abc123abc123abc123"
+
+func newFakeTokenReviews(users map[string]authv1.UserInfo) *fake.Clientset {
+	clientset := fake.NewSimpleClientset()
+	clientset.PrependReactor("create", "tokenreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authv1.TokenReview).DeepCopy()
+		user, ok := users[review.Spec.Token]
+		review.Status.Authenticated = ok
+		review.Status.User = user
+		return true, review, nil
+	})
+	return clientset
+}
+
+func TestAuthentication(t *testing.T) {
+	tests := []struct {
+		name          string
+		token         string
+		allowedGroups []string
+		noAuth        bool
+		path          string
+		expectedCode  int
+	}{
+		{
+			name:         "valid token",
+			token:        validToken,
+			path:         "/v1/status",
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "invalid token",
+			token:        "not-a-token",
+			path:         "/v1/status",
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "no token",
+			path:         "/v1/status",
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:          "valid token, not in allowed group",
+			token:         validToken,
+			allowedGroups: []string{"system:masters"},
+			path:          "/v1/status",
+			expectedCode:  http.StatusUnauthorized,
+		},
+		{
+			name:          "valid token, in allowed group",
+			token:         validToken,
+			allowedGroups: []string{"csp-readers"},
+			path:          "/v1/status",
+			expectedCode:  http.StatusOK,
+		},
+		{
+			name:         "public route, no token",
+			path:         openAPIPath,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "no authentication configured",
+			noAuth:       true,
+			path:         "/v1/status",
+			expectedCode: http.StatusOK,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			clientset := newFakeTokenReviews(map[string]authv1.UserInfo{
+				validToken: {Username: "system:serviceaccount:cattle-system:reader", Groups: []string{"csp-readers"}},
+			})
+			opts := Options{}
+			if !test.noAuth {
+				opts.Authenticator = &TokenReviewAuthenticator{
+					TokenReviews:  clientset.AuthenticationV1().TokenReviews(),
+					AllowedGroups: test.allowedGroups,
+				}
+			}
+			req := httptest.NewRequest(http.MethodGet, test.path, nil)
+			if test.token != "" {
+				req.Header.Set("Authorization", "Bearer "+test.token)
+			}
+			rec := httptest.NewRecorder()
+			New(opts, staticStatus{}).Handler().ServeHTTP(rec, req)
+			assert.Equal(t, test.expectedCode, rec.Code, "did not get expected response code")
+		})
+	}
+}
+
+func TestTokenReviewCache(t *testing.T) {
+	clientset := newFakeTokenReviews(map[string]authv1.UserInfo{
+		validToken: {Username: "system:serviceaccount:cattle-system:reader"},
+	})
+	now := time.Date(2022, 6, 1, 12, 0, 0, 0, time.UTC)
+	authenticator := &TokenReviewAuthenticator{
+		TokenReviews: clientset.AuthenticationV1().TokenReviews(),
+		now:          func() time.Time { return now },
+	}
+	authenticate := func(token string) error {
+		req := httptest.NewRequest(http.MethodGet, "/v1/status", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		_, err := authenticator.Authenticate(req)
+		return err
+	}
+	reviews := func() int {
+		count := 0
+		for _, action := range clientset.Actions() {
+			if action.GetVerb() == "create" {
+				count++
+			}
+		}
+		return count
+	}
+
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, authenticate(validToken))
+		assert.Error(t, authenticate("not-a-token"))
+	}
+	assert.Equal(t, 2, reviews(), "each token should be reviewed once within the ttl")
+
+	now = now.Add(defaultTokenReviewTTL)
+	assert.NoError(t, authenticate(validToken))
+	assert.Equal(t, 3, reviews(), "the token should be reviewed again once its review expired")
+}
+
+func TestAdminRouteRequiresAuthenticator(t *testing.T) {
+	s := New(Options{}, staticStatus{})
+	handler := s.authenticated(route{
+		method:  http.MethodPost,
+		admin:   true,
+		handler: func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) },
+	})
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+	assert.Equal(t, http.StatusForbidden, rec.Code, "admin routes must not be served without authentication")
+}
+
+func TestMetricsAuthentication(t *testing.T) {
+	tests := []struct {
+		name         string
+		opts         Options
+		expectedCode int
+	}{
+		{name: "served without authentication by default", expectedCode: http.StatusOK},
+		{name: "authentication requested without an authenticator", opts: Options{AuthenticateMetrics: true}, expectedCode: http.StatusForbidden},
+		{name: "authenticated", opts: Options{AuthenticateMetrics: true, Authenticator: allowAll{}}, expectedCode: http.StatusOK},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			New(test.opts, staticStatus{}).Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, metricsPath, nil))
+			assert.Equal(t, test.expectedCode, rec.Code)
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type staticEntitlements sdk.Entitlements
+
+func (e staticEntitlements) Entitlements(ctx context.Context) (sdk.Entitlements, error) {
+	return sdk.Entitlements(e), nil
+}
+
+func TestEntitlementsRoute(t *testing.T) {
+	entitlements := staticEntitlements{Max: 10, Consumed: 4, Available: 6}
+	entitlements.FetchedAt = time.Now().Add(-10 * time.Minute)
+	entitlements.Stale = true
+	server := httptest.NewServer(New(Options{Entitlements: entitlements}, staticStatus{}).Handler())
+	defer server.Close()
+
+	res, err := http.Get(server.URL + sdk.EntitlementsPath)
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "600", res.Header.Get("Age"))
+	assert.Equal(t, staleWarning, res.Header.Get("Warning"))
+
+	got, err := sdk.NewClient(server.URL, nil).GetEntitlements(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 6, got.Available)
+	assert.True(t, got.Stale, "staleness is part of the response")
+}
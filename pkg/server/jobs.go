@@ -0,0 +1,130 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/rancher/csp-adapter/pkg/jobs"
+	"github.com/rancher/csp-adapter/pkg/sdk"
+)
+
+// JobQueue runs long operations in the background, keeping track of their progress
+type JobQueue interface {
+	// Submit queues fn to run as a job of the given kind, requested by the named user
+	Submit(kind, requestedBy string, fn jobs.Func) (sdk.Job, error)
+	// Get returns the job with the given id, if it is known
+	Get(id string) (sdk.Job, bool)
+	// List returns all known jobs
+	List() []sdk.Job
+}
+
+// Operations are the long-running operations which can be started through the admin api
+type Operations interface {
+	// Audit runs a full compliance check
+	Audit(ctx context.Context, progress func(message string)) error
+	// CheckInAll returns all checked out licenses
+	CheckInAll(ctx context.Context, progress func(message string)) error
+	// Export writes the latest compliance report to the report sinks
+	Export(ctx context.Context, progress func(message string)) error
+}
+
+const (
+	jobPath     = sdk.JobsPath + "/{id}"
+	auditPath   = "/v1/admin/audit"
+	checkInPath = "/v1/admin/checkin"
+	exportPath  = "/v1/admin/export"
+
+	jobKindAudit   = "audit"
+	jobKindCheckIn = "checkin"
+	jobKindExport  = "export"
+)
+
+func (s *Server) jobRoutes() []route {
+	return []route{
+		{
+			method:   http.MethodGet,
+			path:     sdk.JobsPath,
+			summary:  "List the jobs started through the admin api, most recent first",
+			response: []sdk.Job{},
+			handler:  s.listJobs,
+		},
+		{
+			method:   http.MethodGet,
+			path:     jobPath,
+			summary:  "Get the progress of a job",
+			response: sdk.Job{},
+			handler:  s.getJob,
+		},
+		{
+			method:   http.MethodPost,
+			path:     auditPath,
+			summary:  "Start a job running a full compliance check",
+			response: sdk.Job{},
+			code:     http.StatusAccepted,
+			handler:  s.submitJob(jobKindAudit, s.opts.Operations.Audit),
+			admin:    true,
+		},
+		{
+			method:   http.MethodPost,
+			path:     checkInPath,
+			summary:  "Start a job checking in all licenses held by the adapter",
+			response: sdk.Job{},
+			code:     http.StatusAccepted,
+			handler:  s.submitJob(jobKindCheckIn, s.opts.Operations.CheckInAll),
+			admin:    true,
+		},
+		{
+			method:   http.MethodPost,
+			path:     exportPath,
+			summary:  "Start a job writing the latest compliance report to the report sinks",
+			response: sdk.Job{},
+			code:     http.StatusAccepted,
+			handler:  s.submitJob(jobKindExport, s.opts.Operations.Export),
+			admin:    true,
+		},
+	}
+}
+
+func (s *Server) listJobs(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.opts.Jobs.List())
+}
+
+func (s *Server) getJob(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, sdk.JobsPath+"/")
+	job, ok := s.opts.Jobs.Get(id)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: "job not found"})
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+// submitJob returns a handler which queues fn as a job of the given kind and responds with the queued job
+func (s *Server) submitJob(kind string, fn jobs.Func) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		job, err := s.opts.Jobs.Submit(kind, requester(r), fn)
+		if errors.Is(err, jobs.ErrQueueFull) {
+			writeJSON(w, http.StatusServiceUnavailable, errorResponse{Error: err.Error()})
+			return
+		}
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+			return
+		}
+		w.Header().Set("Location", sdk.JobsPath+"/"+job.ID)
+		writeJSON(w, http.StatusAccepted, job)
+	}
+}
+
+// requester describes the caller of r for the jobs they start, i.e. "admin (10.0.0.1)"
+func requester(r *http.Request) string {
+	c := clientFromRequest(r)
+	user := UserFromContext(r.Context())
+	if user == "" {
+		return c.ip
+	}
+	return fmt.Sprintf("%s (%s)", user, c.ip)
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/jobs"
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeOperations struct{}
+
+func (fakeOperations) Audit(ctx context.Context, progress func(message string)) error {
+	progress("audited")
+	return nil
+}
+
+func (fakeOperations) CheckInAll(ctx context.Context, progress func(message string)) error {
+	return errors.New("unable to check in")
+}
+
+func (fakeOperations) Export(ctx context.Context, progress func(message string)) error {
+	progress("exported")
+	return nil
+}
+
+func TestJobRoutes(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	runner := jobs.NewRunner(jobs.Options{QueueSize: 2, Retention: 2})
+	go runner.Run(ctx)
+	server := httptest.NewServer(New(Options{
+		Authenticator: allowAll{},
+		Jobs:          runner,
+		Operations:    fakeOperations{},
+	}, staticStatus{}).Handler())
+	defer server.Close()
+	client := sdk.NewClient(server.URL, nil)
+
+	tests := []struct {
+		path          string
+		expectedState string
+	}{
+		{path: auditPath, expectedState: sdk.JobStateSucceeded},
+		{path: checkInPath, expectedState: sdk.JobStateFailed},
+		{path: exportPath, expectedState: sdk.JobStateSucceeded},
+	}
+	for _, test := range tests {
+		res, err := http.Post(server.URL+test.path, "application/json", nil)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusAccepted, res.StatusCode)
+		var job sdk.Job
+		assert.NoError(t, json.NewDecoder(res.Body).Decode(&job))
+		res.Body.Close()
+		assert.Equal(t, sdk.JobsPath+"/"+job.ID, res.Header.Get("Location"))
+
+		assert.Eventually(t, func() bool {
+			current, err := client.GetJob(ctx, job.ID)
+			if err != nil {
+				return false
+			}
+			job = *current
+			return job.Done()
+		}, 5*time.Second, 10*time.Millisecond)
+		assert.Equalf(t, test.expectedState, job.State, "unexpected state of job started by %s", test.path)
+	}
+
+	_, err := client.GetJob(ctx, "unknown")
+	assert.Error(t, err, "expected unknown job to not be found")
+}
+
+type allowAll struct{}
+
+func (allowAll) Authenticate(r *http.Request) (string, error) {
+	return "admin", nil
+}
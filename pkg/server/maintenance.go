@@ -0,0 +1,70 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/sdk"
+)
+
+// MaintenanceScheduler freezes the checkout ahead of planned AWS maintenance, so that it stays covered while License
+// Manager is unreliable
+type MaintenanceScheduler interface {
+	// DeclareMaintenance declares a window of planned AWS maintenance on behalf of the named user
+	DeclareMaintenance(by string, window sdk.MaintenanceWindow) (sdk.MaintenanceWindow, error)
+	// CancelMaintenance cancels the windows declared through the api, returning how many were cancelled
+	CancelMaintenance(by string) int
+}
+
+const maintenancePath = "/v1/admin/maintenance"
+
+// MaintenanceRequest declares AWS maintenance from Start to End, Reason is reported with it
+type MaintenanceRequest struct {
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+func (s *Server) maintenanceRoutes() []route {
+	return []route{
+		{
+			method:   http.MethodPost,
+			path:     maintenancePath,
+			summary:  "Declare planned AWS maintenance, the checkout is fully extended before it starts and not adjusted until it ends",
+			request:  MaintenanceRequest{},
+			response: sdk.MaintenanceWindow{},
+			handler:  s.declareMaintenance,
+			admin:    true,
+		},
+		{
+			method:  http.MethodDelete,
+			path:    maintenancePath,
+			summary: "Cancel the maintenance windows declared through the api",
+			code:    http.StatusNoContent,
+			handler: s.cancelMaintenance,
+			admin:   true,
+		},
+	}
+}
+
+func (s *Server) declareMaintenance(w http.ResponseWriter, r *http.Request) {
+	var req MaintenanceRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	window, err := s.opts.Maintenance.DeclareMaintenance(requester(r), sdk.MaintenanceWindow{
+		Start:  req.Start,
+		End:    req.End,
+		Reason: req.Reason,
+	})
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, window)
+}
+
+func (s *Server) cancelMaintenance(w http.ResponseWriter, r *http.Request) {
+	s.opts.Maintenance.CancelMaintenance(requester(r))
+	w.WriteHeader(http.StatusNoContent)
+}
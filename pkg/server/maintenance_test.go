@@ -0,0 +1,67 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeMaintenance struct {
+	windows []sdk.MaintenanceWindow
+}
+
+func (m *fakeMaintenance) DeclareMaintenance(by string, window sdk.MaintenanceWindow) (sdk.MaintenanceWindow, error) {
+	if !window.End.After(window.Start) {
+		return sdk.MaintenanceWindow{}, errors.New("maintenance window must end after it starts")
+	}
+	window.DeclaredBy = by
+	m.windows = append(m.windows, window)
+	return window, nil
+}
+
+func (m *fakeMaintenance) CancelMaintenance(by string) int {
+	cancelled := len(m.windows)
+	m.windows = nil
+	return cancelled
+}
+
+func TestMaintenanceRoutes(t *testing.T) {
+	maintenance := &fakeMaintenance{}
+	server := httptest.NewServer(New(Options{
+		Authenticator: allowAll{},
+		Maintenance:   maintenance,
+	}, staticStatus{}).Handler())
+	defer server.Close()
+
+	res, err := http.Post(server.URL+maintenancePath, "application/json",
+		strings.NewReader(`{"start":"2026-11-03T02:00:00Z","end":"2026-11-03T06:00:00Z","reason":"AWS Health event"}`))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	var window sdk.MaintenanceWindow
+	assert.NoError(t, json.NewDecoder(res.Body).Decode(&window))
+	res.Body.Close()
+	assert.Equal(t, "AWS Health event", window.Reason)
+	assert.Contains(t, window.DeclaredBy, "admin")
+	assert.Equal(t, 2, window.Start.Hour())
+
+	res, err = http.Post(server.URL+maintenancePath, "application/json",
+		strings.NewReader(`{"start":"2026-11-03T06:00:00Z","end":"2026-11-03T02:00:00Z"}`))
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+	req, err := http.NewRequest(http.MethodDelete, server.URL+maintenancePath, nil)
+	require.NoError(t, err)
+	res, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusNoContent, res.StatusCode)
+	assert.Empty(t, maintenance.windows)
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"net/http"
+)
+
+// MockController adjusts the synthetic license used when the adapter runs with --mock-csp
+type MockController interface {
+	// SetMaxEntitlements changes the number of entitlements granted by the synthetic license
+	SetMaxEntitlements(maxEntitlements int)
+	// InjectFailure makes every call of operation fail with message until failures are cleared
+	InjectFailure(operation, message string) error
+	// ClearFailures removes all injected failures
+	ClearFailures()
+}
+
+const (
+	mockEntitlementsPath = "/v1/mock/entitlements"
+	mockFailuresPath     = "/v1/mock/failures"
+)
+
+// MockEntitlementsRequest sets the number of entitlements granted by the synthetic license
+type MockEntitlementsRequest struct {
+	MaxEntitlements int `json:"maxEntitlements"`
+}
+
+// MockFailureRequest injects a failure into an operation of the synthetic license service. Operation is one of
+// license, checkout, checkin, extend, usage or health
+type MockFailureRequest struct {
+	Operation string `json:"operation"`
+	Message   string `json:"message"`
+}
+
+// mockRoutes change the compliance the adapter reports, so like the other admin routes they're only served to
+// authenticated callers
+func (s *Server) mockRoutes() []route {
+	return []route{
+		{
+			method:  http.MethodPut,
+			path:    mockEntitlementsPath,
+			summary: "Set the number of entitlements granted by the synthetic license (mock mode only)",
+			request: MockEntitlementsRequest{},
+			handler: s.setMockEntitlements,
+			admin:   true,
+		},
+		{
+			method:  http.MethodPost,
+			path:    mockFailuresPath,
+			summary: "Inject a failure into an operation of the synthetic license service (mock mode only)",
+			request: MockFailureRequest{},
+			handler: s.injectMockFailure,
+			admin:   true,
+		},
+		{
+			method:  http.MethodDelete,
+			path:    mockFailuresPath,
+			summary: "Clear all injected failures (mock mode only)",
+			handler: s.clearMockFailures,
+			admin:   true,
+		},
+	}
+}
+
+func (s *Server) setMockEntitlements(w http.ResponseWriter, r *http.Request) {
+	var req MockEntitlementsRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if req.MaxEntitlements < 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "maxEntitlements can't be negative"})
+		return
+	}
+	s.opts.Mock.SetMaxEntitlements(req.MaxEntitlements)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) injectMockFailure(w http.ResponseWriter, r *http.Request) {
+	var req MockFailureRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if req.Message == "" {
+		req.Message = "injected failure"
+	}
+	if err := s.opts.Mock.InjectFailure(req.Operation, req.Message); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) clearMockFailures(w http.ResponseWriter, r *http.Request) {
+	s.opts.Mock.ClearFailures()
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,53 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeMock struct {
+	maxEntitlements int
+}
+
+func (f *fakeMock) SetMaxEntitlements(maxEntitlements int) {
+	f.maxEntitlements = maxEntitlements
+}
+
+func (f *fakeMock) InjectFailure(operation, message string) error {
+	return nil
+}
+
+func (f *fakeMock) ClearFailures() {}
+
+func TestMockRoutesRequireAuthentication(t *testing.T) {
+	tests := []struct {
+		name          string
+		authenticator Authenticator
+		expectedCode  int
+		expectedMax   int
+	}{
+		{name: "without authentication", expectedCode: http.StatusForbidden},
+		{name: "authenticated", authenticator: allowAll{}, expectedCode: http.StatusNoContent, expectedMax: 10},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			mock := &fakeMock{}
+			server := httptest.NewServer(New(Options{Mock: mock, Authenticator: test.authenticator}, staticStatus{}).Handler())
+			defer server.Close()
+
+			req, err := http.NewRequest(http.MethodPut, server.URL+mockEntitlementsPath, strings.NewReader(`{"maxEntitlements":10}`))
+			require.NoError(t, err)
+			res, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			res.Body.Close()
+			assert.Equal(t, test.expectedCode, res.StatusCode)
+			assert.Equal(t, test.expectedMax, mock.maxEntitlements)
+		})
+	}
+}
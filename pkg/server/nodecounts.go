@@ -0,0 +1,54 @@
+package server
+
+import (
+	"net/http"
+	"time"
+)
+
+// NodeCountReceiver records the node counts pushed by external systems
+type NodeCountReceiver interface {
+	// PushNodeCounts records the node count of every cluster as observed at observedAt (now if zero) by the caller by,
+	// returning when the counts go stale unless pushed again. Returns an error if the counts are invalid
+	PushNodeCounts(clusters map[string]int, observedAt time.Time, by string) (time.Time, error)
+}
+
+const nodeCountsPath = "/v1/admin/nodecounts"
+
+// NodeCountsRequest pushes the node count of every cluster, replacing the counts pushed before. ObservedAt is when the
+// counts were taken, now if omitted
+type NodeCountsRequest struct {
+	Clusters   map[string]int `json:"clusters"`
+	ObservedAt time.Time      `json:"observedAt,omitempty"`
+}
+
+// NodeCountsResponse tells when the pushed counts go stale, after which nodes are counted by the adapter again
+type NodeCountsResponse struct {
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+func (s *Server) nodeCountRoutes() []route {
+	return []route{
+		{
+			method:   http.MethodPost,
+			path:     nodeCountsPath,
+			summary:  "Push the node count of every cluster, used instead of counting nodes until the push goes stale",
+			request:  NodeCountsRequest{},
+			response: NodeCountsResponse{},
+			handler:  s.pushNodeCounts,
+			admin:    true,
+		},
+	}
+}
+
+func (s *Server) pushNodeCounts(w http.ResponseWriter, r *http.Request) {
+	var req NodeCountsRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	expiresAt, err := s.opts.NodeCounts.PushNodeCounts(req.Clusters, req.ObservedAt, requester(r))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, NodeCountsResponse{ExpiresAt: expiresAt})
+}
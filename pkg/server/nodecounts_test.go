@@ -0,0 +1,62 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeNodeCounts struct {
+	clusters map[string]int
+	by       string
+}
+
+func (f *fakeNodeCounts) PushNodeCounts(clusters map[string]int, observedAt time.Time, by string) (time.Time, error) {
+	if len(clusters) == 0 {
+		return time.Time{}, errors.New("no clusters were pushed")
+	}
+	f.clusters = clusters
+	f.by = by
+	return time.Date(2022, 6, 1, 12, 5, 0, 0, time.UTC), nil
+}
+
+func TestPushNodeCounts(t *testing.T) {
+	counts := &fakeNodeCounts{}
+	server := httptest.NewServer(New(Options{
+		Authenticator: allowAll{},
+		NodeCounts:    counts,
+	}, staticStatus{}).Handler())
+	defer server.Close()
+
+	res, err := http.Post(server.URL+nodeCountsPath, "application/json", strings.NewReader(`{"clusters":{"local":3,"c-1":10}}`))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	var pushed NodeCountsResponse
+	assert.NoError(t, json.NewDecoder(res.Body).Decode(&pushed))
+	res.Body.Close()
+	assert.Equal(t, time.Date(2022, 6, 1, 12, 5, 0, 0, time.UTC), pushed.ExpiresAt)
+	assert.Equal(t, map[string]int{"local": 3, "c-1": 10}, counts.clusters)
+	assert.Contains(t, counts.by, "admin")
+
+	res, err = http.Post(server.URL+nodeCountsPath, "application/json", strings.NewReader(`{"clusters":{}}`))
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+}
+
+func TestPushNodeCountsRequiresAuthentication(t *testing.T) {
+	server := httptest.NewServer(New(Options{NodeCounts: &fakeNodeCounts{}}, staticStatus{}).Handler())
+	defer server.Close()
+
+	res, err := http.Post(server.URL+nodeCountsPath, "application/json", strings.NewReader(`{"clusters":{"local":3}}`))
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusForbidden, res.StatusCode)
+}
@@ -0,0 +1,442 @@
+package server
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/secrets"
+	"github.com/sirupsen/logrus"
+)
+
+// StatusPageOptions configures the html status page, which is served to users signed in with an OpenID Connect
+// provider so that license stakeholders can follow compliance in a browser without access to rancher or the cluster
+type StatusPageOptions struct {
+	// IssuerURL is the issuer of the provider, its endpoints are discovered from IssuerURL/.well-known/openid-configuration.
+	// It must be https, since tls authenticates the id tokens the provider issues
+	IssuerURL    string
+	ClientID     string
+	ClientSecret secrets.Provider
+	// URL is the address browsers reach the adapter at, i.e. https://csp-adapter.example.com. URL/ui/callback must be
+	// registered as a redirect uri of the client
+	URL string
+	// AllowedUsers and AllowedGroups are the users who may see the page, matched against the UsernameClaim and
+	// GroupsClaim of their id token. At least one must be set, since providers may let anyone sign in
+	AllowedUsers  []string
+	AllowedGroups []string
+	// UsernameClaim is the claim identifying users, email if empty. Emails which the provider reports as unverified are
+	// refused
+	UsernameClaim string
+	// GroupsClaim is the claim listing the groups of users, groups if empty
+	GroupsClaim string
+	// SessionKey signs the cookies of the page's sessions. Sessions end when it changes
+	SessionKey []byte
+	// HTTPClient is used to call the provider, a client with a short timeout if nil
+	HTTPClient *http.Client
+}
+
+const (
+	statusPagePath = "/ui/status"
+	callbackPath   = "/ui/callback"
+	logoutPath     = "/ui/logout"
+
+	sessionCookie = "csp_adapter_session"
+	loginCookie   = "csp_adapter_login"
+	// sessionTTL is how long users stay signed in
+	sessionTTL = 8 * time.Hour
+	// loginTTL is how long users have to sign in with the provider
+	loginTTL = 10 * time.Minute
+
+	oidcTimeout = 10 * time.Second
+)
+
+// Validate returns an error if the options are incomplete
+func (o StatusPageOptions) Validate() error {
+	switch {
+	case o.IssuerURL == "" || o.ClientID == "" || o.ClientSecret == nil || o.URL == "":
+		return errors.New("the status page requires an issuer url, client id, client secret and url")
+	case len(o.AllowedUsers) == 0 && len(o.AllowedGroups) == 0:
+		return errors.New("the status page requires allowed users or groups")
+	case len(o.SessionKey) < 32:
+		return errors.New("the status page requires a session key of at least 32 bytes")
+	}
+	if _, err := url.Parse(o.URL); err != nil {
+		return fmt.Errorf("invalid status page url: %v", err)
+	}
+	if err := requireHTTPS(o.IssuerURL); err != nil {
+		return fmt.Errorf("invalid status page issuer url: %v", err)
+	}
+	return nil
+}
+
+// requireHTTPS returns an error unless raw is an https url
+func requireHTTPS(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("%s isn't an https url", raw)
+	}
+	return nil
+}
+
+// providerMetadata are the parts of the provider's discovery document the page uses
+type providerMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+}
+
+// oidcClient signs users in with the authorization code flow (with PKCE) and keeps their sessions in signed cookies
+type oidcClient struct {
+	opts StatusPageOptions
+	http *http.Client
+	now  func() time.Time
+
+	lock sync.Mutex
+	// metadata is discovered on first use, so that the adapter starts while the provider is unavailable
+	metadata *providerMetadata
+}
+
+func newOIDCClient(opts StatusPageOptions) *oidcClient {
+	if opts.UsernameClaim == "" {
+		opts.UsernameClaim = "email"
+	}
+	if opts.GroupsClaim == "" {
+		opts.GroupsClaim = "groups"
+	}
+	client := opts.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: oidcTimeout}
+	}
+	return &oidcClient{opts: opts, http: client, now: time.Now}
+}
+
+// discover returns the provider's metadata, reading its discovery document the first time
+func (c *oidcClient) discover(ctx context.Context) (*providerMetadata, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.metadata != nil {
+		return c.metadata, nil
+	}
+	issuer := strings.TrimSuffix(c.opts.IssuerURL, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	var metadata providerMetadata
+	if err := c.do(req, &metadata); err != nil {
+		return nil, fmt.Errorf("unable to discover the openid provider: %v", err)
+	}
+	if strings.TrimSuffix(metadata.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("openid provider reports issuer %s instead of %s", metadata.Issuer, c.opts.IssuerURL)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" {
+		return nil, errors.New("openid provider doesn't report its authorization and token endpoints")
+	}
+	for _, endpoint := range []string{issuer, metadata.AuthorizationEndpoint, metadata.TokenEndpoint} {
+		if err := requireHTTPS(endpoint); err != nil {
+			return nil, fmt.Errorf("openid provider endpoint refused: %v", err)
+		}
+	}
+	c.metadata = &metadata
+	return c.metadata, nil
+}
+
+// do issues req, decoding the json response into out
+func (c *oidcClient) do(req *http.Request, out interface{}) error {
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %d: %s", req.URL.Path, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return json.Unmarshal(body, out)
+}
+
+// loginState is kept in a cookie while the user signs in with the provider
+type loginState struct {
+	State    string    `json:"state"`
+	Nonce    string    `json:"nonce"`
+	Verifier string    `json:"verifier"`
+	Expires  time.Time `json:"expires"`
+}
+
+// session is kept in a cookie once the user signed in. Groups are kept so that the allowed users and groups are
+// checked again on every request, rather than only when the user signed in
+type session struct {
+	User    string    `json:"user"`
+	Groups  []string  `json:"groups,omitempty"`
+	Expires time.Time `json:"expires"`
+}
+
+func (c *oidcClient) redirectURL() string {
+	return strings.TrimSuffix(c.opts.URL, "/") + callbackPath
+}
+
+// login redirects the browser to the provider to sign in
+func (c *oidcClient) login(w http.ResponseWriter, r *http.Request) {
+	metadata, err := c.discover(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	state, err := newLoginState(c.now().Add(loginTTL))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	c.setCookie(w, loginCookie, state, loginTTL)
+	challenge := sha256.Sum256([]byte(state.Verifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {c.opts.ClientID},
+		"redirect_uri":          {c.redirectURL()},
+		"scope":                 {"openid email profile"},
+		"state":                 {state.State},
+		"nonce":                 {state.Nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	separator := "?"
+	if strings.Contains(metadata.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	http.Redirect(w, r, metadata.AuthorizationEndpoint+separator+query.Encode(), http.StatusFound)
+}
+
+// callback completes the sign in, starting a session for allowed users
+func (c *oidcClient) callback(w http.ResponseWriter, r *http.Request) {
+	if reason := r.URL.Query().Get("error"); reason != "" {
+		http.Error(w, fmt.Sprintf("sign in failed: %s %s", reason, r.URL.Query().Get("error_description")), http.StatusUnauthorized)
+		return
+	}
+	var state loginState
+	if err := c.readCookie(r, loginCookie, &state); err != nil || c.now().After(state.Expires) {
+		http.Error(w, "sign in expired, reload the status page", http.StatusBadRequest)
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(state.State), []byte(r.URL.Query().Get("state"))) != 1 {
+		http.Error(w, "sign in state doesn't match, reload the status page", http.StatusBadRequest)
+		return
+	}
+	c.clearCookie(w, loginCookie)
+	user, groups, err := c.exchange(r.Context(), r.URL.Query().Get("code"), state)
+	if err != nil {
+		logrus.Errorf("[server] status page sign in failed: %v", err)
+		http.Error(w, "sign in failed", http.StatusUnauthorized)
+		return
+	}
+	if !isAllowed(user, groups, c.opts.AllowedUsers, c.opts.AllowedGroups) {
+		logrus.Infof("[server] %s isn't allowed to see the status page", user)
+		http.Error(w, fmt.Sprintf("%s isn't allowed to see the status page", user), http.StatusForbidden)
+		return
+	}
+	logrus.Infof("[server] %s signed in to the status page from %s", user, clientFromRequest(r).ip)
+	c.setCookie(w, sessionCookie, session{User: user, Groups: groups, Expires: c.now().Add(sessionTTL)}, sessionTTL)
+	http.Redirect(w, r, statusPagePath, http.StatusFound)
+}
+
+// exchange redeems code for the user's id token, returning the user and groups it names. The token is received from
+// the token endpoint over a connection the client initiated, so tls authenticates the issuer in place of the token's
+// signature (OpenID Connect Core 3.1.3.7); its issuer, audience, expiry and nonce are still checked
+func (c *oidcClient) exchange(ctx context.Context, code string, state loginState) (string, []string, error) {
+	metadata, err := c.discover(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	secret, err := c.opts.ClientSecret.Value(ctx)
+	if err != nil {
+		return "", nil, fmt.Errorf("unable to resolve the client secret: %v", err)
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {c.redirectURL()},
+		"code_verifier": {state.Verifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(c.opts.ClientID), url.QueryEscape(secret))
+	var token struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := c.do(req, &token); err != nil {
+		return "", nil, fmt.Errorf("unable to redeem the authorization code: %v", err)
+	}
+	claims, err := decodeIDToken(token.IDToken)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := c.validateClaims(claims, metadata.Issuer, state.Nonce); err != nil {
+		return "", nil, err
+	}
+	return c.identity(claims)
+}
+
+// idTokenClaims are the claims of an id token, by name
+type idTokenClaims map[string]interface{}
+
+// decodeIDToken returns the claims of the jwt raw
+func decodeIDToken(raw string) (idTokenClaims, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("id token isn't a jwt")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode id token: %v", err)
+	}
+	var claims idTokenClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("unable to decode id token: %v", err)
+	}
+	return claims, nil
+}
+
+func (c *oidcClient) validateClaims(claims idTokenClaims, issuer, nonce string) error {
+	if iss, _ := claims["iss"].(string); iss != issuer {
+		return fmt.Errorf("id token was issued by %q instead of %s", iss, issuer)
+	}
+	audienceOK := false
+	switch aud := claims["aud"].(type) {
+	case string:
+		audienceOK = aud == c.opts.ClientID
+	case []interface{}:
+		for _, a := range aud {
+			audienceOK = audienceOK || a == c.opts.ClientID
+		}
+	}
+	if !audienceOK {
+		return fmt.Errorf("id token isn't issued to client %s", c.opts.ClientID)
+	}
+	exp, _ := claims["exp"].(float64)
+	if !c.now().Before(time.Unix(int64(exp), 0)) {
+		return errors.New("id token expired")
+	}
+	if got, _ := claims["nonce"].(string); subtle.ConstantTimeCompare([]byte(got), []byte(nonce)) != 1 {
+		return errors.New("id token nonce doesn't match")
+	}
+	return nil
+}
+
+// identity returns the user and groups named by claims
+func (c *oidcClient) identity(claims idTokenClaims) (string, []string, error) {
+	user, _ := claims[c.opts.UsernameClaim].(string)
+	if user == "" {
+		return "", nil, fmt.Errorf("id token has no %s claim", c.opts.UsernameClaim)
+	}
+	if c.opts.UsernameClaim == "email" {
+		if verified, ok := claims["email_verified"].(bool); ok && !verified {
+			return "", nil, fmt.Errorf("email %s isn't verified", user)
+		}
+	}
+	var groups []string
+	if values, ok := claims[c.opts.GroupsClaim].([]interface{}); ok {
+		for _, value := range values {
+			if group, ok := value.(string); ok {
+				groups = append(groups, group)
+			}
+		}
+	}
+	return user, groups, nil
+}
+
+// session returns the user signed in with r, false if none is or if the user is no longer allowed to see the page
+func (c *oidcClient) session(r *http.Request) (string, bool) {
+	var s session
+	if err := c.readCookie(r, sessionCookie, &s); err != nil || c.now().After(s.Expires) || s.User == "" {
+		return "", false
+	}
+	if !isAllowed(s.User, s.Groups, c.opts.AllowedUsers, c.opts.AllowedGroups) {
+		return "", false
+	}
+	return s.User, true
+}
+
+func (c *oidcClient) logout(w http.ResponseWriter, r *http.Request) {
+	c.clearCookie(w, sessionCookie)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, "signed out of the csp-adapter status page")
+}
+
+// setCookie sets the cookie name to value, signed with the session key
+func (c *oidcClient) setCookie(w http.ResponseWriter, name string, value interface{}, ttl time.Duration) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		logrus.Errorf("[server] unable to encode cookie %s: %v", name, err)
+		return
+	}
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    payload + "." + c.mac(payload),
+		Path:     "/ui/",
+		MaxAge:   int(ttl.Seconds()),
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(c.opts.URL, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func (c *oidcClient) clearCookie(w http.ResponseWriter, name string) {
+	http.SetCookie(w, &http.Cookie{Name: name, Path: "/ui/", MaxAge: -1, HttpOnly: true})
+}
+
+// readCookie decodes the cookie name of r into out, if its signature is valid
+func (c *oidcClient) readCookie(r *http.Request, name string, out interface{}) error {
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return err
+	}
+	i := strings.LastIndex(cookie.Value, ".")
+	if i < 0 || !hmac.Equal([]byte(cookie.Value[i+1:]), []byte(c.mac(cookie.Value[:i]))) {
+		return fmt.Errorf("cookie %s has an invalid signature", name)
+	}
+	data, err := base64.RawURLEncoding.DecodeString(cookie.Value[:i])
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+func (c *oidcClient) mac(payload string) string {
+	h := hmac.New(sha256.New, c.opts.SessionKey)
+	h.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// newLoginState returns the random state, nonce and code verifier of a sign in which expires at expires
+func newLoginState(expires time.Time) (loginState, error) {
+	values := make([]string, 3)
+	for i := range values {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return loginState{}, fmt.Errorf("unable to start sign in: %v", err)
+		}
+		values[i] = base64.RawURLEncoding.EncodeToString(b)
+	}
+	return loginState{State: values[0], Nonce: values[1], Verifier: values[2], Expires: expires}, nil
+}
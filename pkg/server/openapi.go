@@ -0,0 +1,168 @@
+package server
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	openAPIPath    = "/openapi.json"
+	openAPIVersion = "3.0.3"
+	apiTitle       = "Rancher CSP Adapter"
+	apiVersion     = "v1"
+	schemaRefRoot  = "#/components/schemas/"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// openAPIDocument produces an openapi 3 document describing routes. Schemas for response types are derived from
+// their go definition and json tags
+func openAPIDocument(routes []route) map[string]interface{} {
+	schemas := map[string]interface{}{}
+	paths := map[string]interface{}{}
+	for _, rt := range routes {
+		responses := map[string]interface{}{
+			"204": map[string]interface{}{"description": "No Content"},
+		}
+		if rt.response != nil {
+			code := rt.code
+			if code == 0 {
+				code = http.StatusOK
+			}
+			responses = map[string]interface{}{
+				strconv.Itoa(code): map[string]interface{}{
+					"description": http.StatusText(code),
+					"content":     jsonContent(reflect.TypeOf(rt.response), schemas),
+				},
+			}
+		} else if rt.code != 0 && rt.code != http.StatusNoContent {
+			responses = map[string]interface{}{
+				strconv.Itoa(rt.code): map[string]interface{}{
+					"description": http.StatusText(rt.code),
+					"content":     map[string]interface{}{"application/octet-stream": map[string]interface{}{}},
+				},
+			}
+		}
+		operation := map[string]interface{}{
+			"summary":   rt.summary,
+			"responses": responses,
+		}
+		if parameters := pathParameters(rt.path); len(parameters) > 0 {
+			operation["parameters"] = parameters
+		}
+		if rt.request != nil {
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content":  jsonContent(reflect.TypeOf(rt.request), schemas),
+			}
+		}
+		pathItem, ok := paths[rt.path].(map[string]interface{})
+		if !ok {
+			pathItem = map[string]interface{}{}
+			paths[rt.path] = pathItem
+		}
+		pathItem[strings.ToLower(rt.method)] = operation
+	}
+	return map[string]interface{}{
+		"openapi": openAPIVersion,
+		"info": map[string]interface{}{
+			"title":   apiTitle,
+			"version": apiVersion,
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+		},
+	}
+}
+
+// pathParameters documents the {parameter} segments of path, which are all strings
+func pathParameters(path string) []interface{} {
+	var parameters []interface{}
+	for _, segment := range strings.Split(path, "/") {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			parameters = append(parameters, map[string]interface{}{
+				"name":     strings.Trim(segment, "{}"),
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]interface{}{"type": "string"},
+			})
+		}
+	}
+	return parameters
+}
+
+func jsonContent(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"application/json": map[string]interface{}{
+			"schema": schemaFor(t, schemas),
+		},
+	}
+}
+
+// schemaFor returns the openapi schema for t. Named structs are added to schemas and referenced
+func schemaFor(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	if t == nil {
+		return map[string]interface{}{}
+	}
+	if t == timeType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return schemaFor(t.Elem(), schemas)
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": schemaFor(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaFor(t.Elem(), schemas)}
+	case reflect.Struct:
+		if _, ok := schemas[t.Name()]; !ok && t.Name() != "" {
+			// reserve the name before descending so that recursive types terminate
+			schemas[t.Name()] = nil
+			schemas[t.Name()] = structSchema(t, schemas)
+		}
+		if t.Name() == "" {
+			return structSchema(t, schemas)
+		}
+		return map[string]interface{}{"$ref": schemaRefRoot + t.Name()}
+	}
+	return map[string]interface{}{}
+}
+
+func structSchema(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	properties := map[string]interface{}{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			// unexported fields aren't marshalled
+			continue
+		}
+		name := field.Name
+		if tag, ok := field.Tag.Lookup("json"); ok {
+			tagName := strings.Split(tag, ",")[0]
+			if tagName == "-" {
+				continue
+			}
+			if tagName != "" {
+				name = tagName
+			}
+		}
+		properties[name] = schemaFor(field.Type, schemas)
+	}
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+}
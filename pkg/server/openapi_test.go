@@ -0,0 +1,48 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/stretchr/testify/assert"
+)
+
+type staticStatus struct{}
+
+func (staticStatus) Status() sdk.Status {
+	return sdk.Status{}
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	s := New(Options{}, staticStatus{})
+	server := httptest.NewServer(s.Handler())
+	defer server.Close()
+
+	res, err := http.Get(server.URL + openAPIPath)
+	assert.NoError(t, err, "expected no error getting openapi document")
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	var doc struct {
+		OpenAPI    string                                       `json:"openapi"`
+		Paths      map[string]map[string]interface{}            `json:"paths"`
+		Components map[string]map[string]map[string]interface{} `json:"components"`
+	}
+	assert.NoError(t, json.NewDecoder(res.Body).Decode(&doc))
+	assert.Equal(t, openAPIVersion, doc.OpenAPI)
+	// every route that is served must be documented
+	for _, rt := range s.routes() {
+		_, ok := doc.Paths[rt.path][strings.ToLower(rt.method)]
+		assert.Truef(t, ok, "route %s %s missing from openapi document", rt.method, rt.path)
+	}
+	statusSchema, ok := doc.Components["schemas"]["Status"]
+	assert.True(t, ok, "expected Status schema to be documented")
+	properties := statusSchema["properties"].(map[string]interface{})
+	for _, field := range []string{"csp", "account", "compliance", "usage"} {
+		assert.Containsf(t, properties, field, "expected field %s in Status schema", field)
+	}
+}
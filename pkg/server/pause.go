@@ -0,0 +1,60 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/rancher/csp-adapter/pkg/sdk"
+)
+
+// Pauser halts and resumes checkout adjustments, i.e. while operators respond to an incident
+type Pauser interface {
+	// Pause halts checkout adjustments on behalf of the named user until Resume is called
+	Pause(by, reason string) sdk.PauseStatus
+	// Resume lets compliance checks adjust the checkout again
+	Resume(by string)
+}
+
+const (
+	pausePath  = "/v1/admin/pause"
+	resumePath = "/v1/admin/resume"
+)
+
+// PauseRequest pauses checkout adjustments, Reason is reported in the status while paused
+type PauseRequest struct {
+	Reason string `json:"reason"`
+}
+
+func (s *Server) pauseRoutes() []route {
+	return []route{
+		{
+			method:   http.MethodPost,
+			path:     pausePath,
+			summary:  "Stop checking licenses out or in until resumed, the held checkout is still renewed",
+			request:  PauseRequest{},
+			response: sdk.PauseStatus{},
+			handler:  s.pause,
+			admin:    true,
+		},
+		{
+			method:  http.MethodPost,
+			path:    resumePath,
+			summary: "Resume checkout adjustments and check compliance immediately",
+			code:    http.StatusNoContent,
+			handler: s.resume,
+			admin:   true,
+		},
+	}
+}
+
+func (s *Server) pause(w http.ResponseWriter, r *http.Request) {
+	var req PauseRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	writeJSON(w, http.StatusOK, s.opts.Pauser.Pause(requester(r), req.Reason))
+}
+
+func (s *Server) resume(w http.ResponseWriter, r *http.Request) {
+	s.opts.Pauser.Resume(requester(r))
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakePauser struct {
+	paused *sdk.PauseStatus
+}
+
+func (p *fakePauser) Pause(by, reason string) sdk.PauseStatus {
+	p.paused = &sdk.PauseStatus{By: by, Reason: reason, Since: time.Now()}
+	return *p.paused
+}
+
+func (p *fakePauser) Resume(by string) {
+	p.paused = nil
+}
+
+func TestPauseRoutes(t *testing.T) {
+	pauser := &fakePauser{}
+	server := httptest.NewServer(New(Options{
+		Authenticator: allowAll{},
+		Pauser:        pauser,
+	}, staticStatus{}).Handler())
+	defer server.Close()
+
+	res, err := http.Post(server.URL+pausePath, "application/json", strings.NewReader(`{"reason":"incident"}`))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	var paused sdk.PauseStatus
+	assert.NoError(t, json.NewDecoder(res.Body).Decode(&paused))
+	res.Body.Close()
+	assert.Equal(t, "incident", paused.Reason)
+	assert.Contains(t, paused.By, "admin")
+	require.NotNil(t, pauser.paused)
+
+	res, err = http.Post(server.URL+resumePath, "application/json", nil)
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusNoContent, res.StatusCode)
+	assert.Nil(t, pauser.paused)
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"strings"
+
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/sirupsen/logrus"
+)
+
+// ProfileSnapshots lists and opens the profiles captured when the adapter's memory grew abnormally
+type ProfileSnapshots interface {
+	Snapshots() ([]sdk.ProfileSnapshot, error)
+	Open(name string) (io.ReadCloser, error)
+}
+
+const (
+	pprofPath     = "/debug/pprof/{profile}"
+	snapshotsPath = "/v1/admin/profiles"
+	snapshotPath  = "/v1/admin/profiles/{name}"
+)
+
+func (s *Server) profilingRoutes() []route {
+	return []route{
+		{
+			method:  http.MethodGet,
+			path:    pprofPath,
+			summary: "Serve the runtime profiles of the adapter in the pprof format, an empty profile lists them",
+			code:    http.StatusOK,
+			handler: s.getProfile,
+			admin:   true,
+		},
+	}
+}
+
+func (s *Server) snapshotRoutes() []route {
+	return []route{
+		{
+			method:   http.MethodGet,
+			path:     snapshotsPath,
+			summary:  "List the profiles captured when the adapter's memory grew abnormally",
+			response: []sdk.ProfileSnapshot{},
+			handler:  s.listSnapshots,
+			admin:    true,
+		},
+		{
+			method:  http.MethodGet,
+			path:    snapshotPath,
+			summary: "Download a captured profile in the pprof format",
+			code:    http.StatusOK,
+			handler: s.getSnapshot,
+			admin:   true,
+		},
+	}
+}
+
+func (s *Server) getProfile(w http.ResponseWriter, r *http.Request) {
+	switch strings.TrimPrefix(r.URL.Path, "/debug/pprof/") {
+	case "cmdline":
+		pprof.Cmdline(w, r)
+	case "profile":
+		pprof.Profile(w, r)
+	case "symbol":
+		pprof.Symbol(w, r)
+	case "trace":
+		pprof.Trace(w, r)
+	default:
+		// serves the index as well as the named runtime profiles, i.e. heap and goroutine
+		pprof.Index(w, r)
+	}
+}
+
+func (s *Server) listSnapshots(w http.ResponseWriter, r *http.Request) {
+	snapshots, err := s.opts.Snapshots.Snapshots()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, snapshots)
+}
+
+func (s *Server) getSnapshot(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, snapshotsPath+"/")
+	snapshot, err := s.opts.Snapshots.Open(name)
+	if errors.Is(err, os.ErrNotExist) {
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: "profile snapshot not found"})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, errorResponse{Error: err.Error()})
+		return
+	}
+	defer snapshot.Close()
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+	if _, err := io.Copy(w, snapshot); err != nil {
+		logrus.Debugf("[server] unable to send profile snapshot %s: %v", name, err)
+	}
+}
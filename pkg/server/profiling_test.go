@@ -0,0 +1,78 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSnapshots map[string]string
+
+func (s fakeSnapshots) Snapshots() ([]sdk.ProfileSnapshot, error) {
+	var snapshots []sdk.ProfileSnapshot
+	for name := range s {
+		snapshots = append(snapshots, sdk.ProfileSnapshot{Name: name, Kind: "heap", Time: time.Now()})
+	}
+	return snapshots, nil
+}
+
+func (s fakeSnapshots) Open(name string) (io.ReadCloser, error) {
+	content, ok := s[name]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return io.NopCloser(strings.NewReader(content)), nil
+}
+
+func TestProfilingRoutes(t *testing.T) {
+	server := httptest.NewServer(New(Options{
+		Authenticator: allowAll{},
+		Profiling:     true,
+		Snapshots:     fakeSnapshots{"heap.pb.gz": "profile"},
+	}, staticStatus{}).Handler())
+	defer server.Close()
+
+	res, err := http.Get(server.URL + "/debug/pprof/goroutine?debug=1")
+	require.NoError(t, err)
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Contains(t, string(body), "goroutine profile")
+
+	res, err = http.Get(server.URL + snapshotsPath)
+	require.NoError(t, err)
+	var snapshots []sdk.ProfileSnapshot
+	assert.NoError(t, json.NewDecoder(res.Body).Decode(&snapshots))
+	res.Body.Close()
+	require.Len(t, snapshots, 1)
+
+	res, err = http.Get(server.URL + snapshotsPath + "/" + snapshots[0].Name)
+	require.NoError(t, err)
+	body, _ = io.ReadAll(res.Body)
+	res.Body.Close()
+	assert.Equal(t, "profile", string(body))
+
+	res, err = http.Get(server.URL + snapshotsPath + "/missing.pb.gz")
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+}
+
+func TestProfilingRequiresAuthentication(t *testing.T) {
+	server := httptest.NewServer(New(Options{Profiling: true}, staticStatus{}).Handler())
+	defer server.Close()
+
+	res, err := http.Get(server.URL + "/debug/pprof/heap")
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusForbidden, res.StatusCode, "profiles should never be served without authentication")
+}
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+const (
+	forwardedForHeader           = "X-Forwarded-For"
+	forwardedAuthorizationHeader = "X-Forwarded-Authorization"
+)
+
+// TrustedProxies are the networks of the proxies (i.e. rancher's proxy or an ingress controller) whose forwarding
+// headers are trusted. Requests from other addresses are taken at face value, so that clients can't spoof their
+// address or identity by setting the headers themselves
+type TrustedProxies []*net.IPNet
+
+// ParseTrustedProxies parses a list of CIDRs or single ip addresses
+func ParseTrustedProxies(values []string) (TrustedProxies, error) {
+	var proxies TrustedProxies
+	for _, value := range values {
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q, must be an ip address or cidr", value)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				bits = 8 * net.IPv4len
+			}
+			value = fmt.Sprintf("%s/%d", value, bits)
+		}
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q, must be an ip address or cidr", value)
+		}
+		proxies = append(proxies, network)
+	}
+	return proxies, nil
+}
+
+func (t TrustedProxies) contains(ip net.IP) bool {
+	for _, network := range t {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+type clientKey struct{}
+
+// client describes the original caller of a request
+type client struct {
+	ip string
+	// proxied is true if the request was forwarded by a trusted proxy
+	proxied bool
+}
+
+// resolve returns r with the original caller stored in its context. For requests forwarded by a trusted proxy the
+// caller is the last address in X-Forwarded-For which isn't a trusted proxy itself, and X-Forwarded-Authorization (set
+// by proxies which authenticate requests with their own credentials) replaces the Authorization header
+func (t TrustedProxies) resolve(r *http.Request) *http.Request {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	c := client{ip: host}
+	if remote := net.ParseIP(host); remote != nil && t.contains(remote) {
+		c.proxied = true
+		var forwarded []string
+		for _, value := range r.Header.Values(forwardedForHeader) {
+			for _, ip := range strings.Split(value, ",") {
+				if ip = strings.TrimSpace(ip); ip != "" {
+					forwarded = append(forwarded, ip)
+				}
+			}
+		}
+		// walk back from the proxy closest to the server, every hop up to the first untrusted one vouches for the next
+		for i := len(forwarded) - 1; i >= 0; i-- {
+			ip := net.ParseIP(forwarded[i])
+			if ip == nil {
+				break
+			}
+			c.ip = forwarded[i]
+			if !t.contains(ip) {
+				break
+			}
+		}
+		if authorization := r.Header.Get(forwardedAuthorizationHeader); authorization != "" {
+			r = r.Clone(r.Context())
+			r.Header.Set("Authorization", authorization)
+		}
+	}
+	return r.WithContext(context.WithValue(r.Context(), clientKey{}, c))
+}
+
+func clientFromRequest(r *http.Request) client {
+	if c, ok := r.Context().Value(clientKey{}).(client); ok {
+		return c
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return client{ip: host}
+}
+
+// ClientIPFromContext returns the address of the original caller of the request ctx belongs to, resolved through
+// trusted proxies
+func ClientIPFromContext(ctx context.Context) string {
+	c, _ := ctx.Value(clientKey{}).(client)
+	return c.ip
+}
+
+// ProxyHeaderAuthenticator authenticates requests forwarded by a trusted proxy which authenticated the caller itself
+// and passes on their name in Header (i.e. X-Forwarded-User set by an oauth2 proxy). Requests which didn't come through
+// a trusted proxy are never authenticated by it
+type ProxyHeaderAuthenticator struct {
+	Header string
+}
+
+func (p ProxyHeaderAuthenticator) Authenticate(r *http.Request) (string, error) {
+	if !clientFromRequest(r).proxied {
+		return "", errUnauthenticated
+	}
+	user := r.Header.Get(p.Header)
+	if user == "" {
+		return "", errUnauthenticated
+	}
+	return user, nil
+}
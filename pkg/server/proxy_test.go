@@ -0,0 +1,133 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	authv1 "k8s.io/api/authentication/v1"
+)
+
+func TestResolveClient(t *testing.T) {
+	proxies, err := ParseTrustedProxies([]string{"10.0.0.0/8", "fd00::1"})
+	require.NoError(t, err)
+	tests := []struct {
+		name            string
+		remoteAddr      string
+		forwardedFor    []string
+		expectedIP      string
+		expectedProxied bool
+	}{
+		{
+			name:       "direct request",
+			remoteAddr: "192.168.1.5:51000",
+			expectedIP: "192.168.1.5",
+		},
+		{
+			name:         "untrusted peer can't spoof its address",
+			remoteAddr:   "192.168.1.5:51000",
+			forwardedFor: []string{"1.2.3.4"},
+			expectedIP:   "192.168.1.5",
+		},
+		{
+			name:            "trusted proxy",
+			remoteAddr:      "10.1.2.3:51000",
+			forwardedFor:    []string{"203.0.113.7"},
+			expectedIP:      "203.0.113.7",
+			expectedProxied: true,
+		},
+		{
+			name:            "chain of trusted proxies",
+			remoteAddr:      "[fd00::1]:51000",
+			forwardedFor:    []string{"1.2.3.4, 203.0.113.7", "10.0.0.2"},
+			expectedIP:      "203.0.113.7",
+			expectedProxied: true,
+		},
+		{
+			name:            "trusted proxy without forwarding header",
+			remoteAddr:      "10.1.2.3:51000",
+			expectedIP:      "10.1.2.3",
+			expectedProxied: true,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/v1/status", nil)
+			r.RemoteAddr = test.remoteAddr
+			for _, value := range test.forwardedFor {
+				r.Header.Add(forwardedForHeader, value)
+			}
+			c := clientFromRequest(proxies.resolve(r))
+			assert.Equal(t, test.expectedIP, c.ip)
+			assert.Equal(t, test.expectedProxied, c.proxied)
+		})
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	_, err := ParseTrustedProxies([]string{"not-an-ip"})
+	assert.Error(t, err)
+	_, err = ParseTrustedProxies([]string{"10.0.0.0/33"})
+	assert.Error(t, err)
+}
+
+func TestProxyAuthentication(t *testing.T) {
+	proxies, err := ParseTrustedProxies([]string{"10.0.0.1"})
+	require.NoError(t, err)
+	s := New(Options{
+		Authenticator: AnyAuthenticator{
+			&TokenReviewAuthenticator{TokenReviews: newFakeTokenReviews(map[string]authv1.UserInfo{
+				validToken: {Username: "alice"},
+			}).AuthenticationV1().TokenReviews()},
+			ProxyHeaderAuthenticator{Header: "X-Forwarded-User"},
+		},
+		TrustedProxies: proxies,
+	}, staticStatus{})
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		headers      map[string]string
+		expectedCode int
+	}{
+		{
+			name:         "user header from trusted proxy",
+			remoteAddr:   "10.0.0.1:4000",
+			headers:      map[string]string{"X-Forwarded-User": "alice"},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "user header from anyone else",
+			remoteAddr:   "10.0.0.2:4000",
+			headers:      map[string]string{"X-Forwarded-User": "alice"},
+			expectedCode: http.StatusUnauthorized,
+		},
+		{
+			name:         "forwarded authorization from trusted proxy",
+			remoteAddr:   "10.0.0.1:4000",
+			headers:      map[string]string{"Authorization": "Bearer proxy-token", forwardedAuthorizationHeader: "Bearer " + validToken},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "forwarded authorization from anyone else",
+			remoteAddr:   "10.0.0.2:4000",
+			headers:      map[string]string{forwardedAuthorizationHeader: "Bearer " + validToken},
+			expectedCode: http.StatusUnauthorized,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/v1/status", nil)
+			r.RemoteAddr = test.remoteAddr
+			for key, value := range test.headers {
+				r.Header.Set(key, value)
+			}
+			w := httptest.NewRecorder()
+			s.Handler().ServeHTTP(w, r)
+			assert.Equal(t, test.expectedCode, w.Code)
+		})
+	}
+}
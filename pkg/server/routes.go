@@ -0,0 +1,118 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/rancher/csp-adapter/pkg/sdk"
+)
+
+// route describes a single endpoint of the api. Both the handlers registered with the mux and the served openapi
+// document are produced from the same routes, so the two can't drift apart
+type route struct {
+	method  string
+	path    string
+	summary string
+	// request and response are zero values of the types accepted and returned as json by the handler, used to produce
+	// the openapi schemas. A nil request means the route has no body, a nil response means it returns no content
+	request  interface{}
+	response interface{}
+	// code is the status code of a successful response, 200 if unset. Routes with a code but no response return content
+	// other than json, i.e. profiles
+	code    int
+	handler http.HandlerFunc
+	// public routes can be called without authentication
+	public bool
+	// admin routes change the adapter's state and are refused unless an Authenticator is configured
+	admin bool
+}
+
+// routes returns every documented route served by s. New endpoints must be added here
+func (s *Server) routes() []route {
+	routes := []route{
+		{
+			method:   http.MethodGet,
+			path:     sdk.StatusPath,
+			summary:  "Get the result of the most recent compliance check",
+			response: sdk.Status{},
+			handler:  s.getStatus,
+		},
+	}
+	if s.opts.Catalog != nil {
+		routes = append(routes, route{
+			method:   http.MethodGet,
+			path:     sdk.ProductsPath,
+			summary:  "List the known rancher products, which of them were found in the account and which one is used",
+			response: []sdk.Product{},
+			handler:  s.getProducts,
+		})
+	}
+	if s.opts.Entitlements != nil {
+		routes = append(routes, route{
+			method:   http.MethodGet,
+			path:     sdk.EntitlementsPath,
+			summary:  "Get the usage of the license's entitlements, served from a cache refreshed in the background",
+			response: sdk.Entitlements{},
+			handler:  s.getEntitlements,
+		})
+	}
+	if s.opts.Inventory != nil {
+		routes = append(routes, route{
+			method:   http.MethodGet,
+			path:     sdk.InventoryPath,
+			summary:  "Get the clusters, nodes and license which the current checkout is based on",
+			response: sdk.Inventory{},
+			handler:  s.getInventory,
+		})
+	}
+	if s.opts.Jobs != nil && s.opts.Operations != nil {
+		routes = append(routes, s.jobRoutes()...)
+	}
+	if s.opts.Pauser != nil {
+		routes = append(routes, s.pauseRoutes()...)
+	}
+	if s.opts.SyntheticTests != nil {
+		routes = append(routes, s.syntheticRoutes()...)
+	}
+	if s.opts.Maintenance != nil {
+		routes = append(routes, s.maintenanceRoutes()...)
+	}
+	if s.opts.Profiling {
+		routes = append(routes, s.profilingRoutes()...)
+	}
+	if s.opts.Snapshots != nil {
+		routes = append(routes, s.snapshotRoutes()...)
+	}
+	if s.opts.NodeCounts != nil {
+		routes = append(routes, s.nodeCountRoutes()...)
+	}
+	if s.opts.Mock != nil {
+		routes = append(routes, s.mockRoutes()...)
+	}
+	return routes
+}
+
+// muxPath is the pattern rt is registered with. Paths ending in a {parameter} are served as a subtree, handlers read
+// the parameter from the remainder of the request path
+func (rt route) muxPath() string {
+	if i := strings.Index(rt.path, "{"); i >= 0 {
+		return rt.path[:i]
+	}
+	return rt.path
+}
+
+func (rt route) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rt.handler(w, r)
+}
+
+// methodHandler dispatches requests for a single path to the handler registered for the request's method
+type methodHandler map[string]http.Handler
+
+func (m methodHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	handler, ok := m[r.Method]
+	if !ok {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	handler.ServeHTTP(w, r)
+}
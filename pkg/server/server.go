@@ -0,0 +1,337 @@
+// Package server contains the http server which exposes the adapter's status to other tools
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/metrics"
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/rancher/csp-adapter/pkg/supervisor"
+	"github.com/sirupsen/logrus"
+)
+
+// StatusProvider supplies the current status of the adapter
+type StatusProvider interface {
+	// Status returns the result of the most recent compliance check
+	Status() sdk.Status
+}
+
+// ProductCatalog supplies the rancher products known to the adapter
+type ProductCatalog interface {
+	// Products returns the known products and the licenses received for them, and how current they are
+	Products(ctx context.Context) ([]sdk.Product, sdk.Freshness, error)
+}
+
+// EntitlementReader supplies the usage of the entitlements of the adapter's license
+type EntitlementReader interface {
+	// Entitlements returns the usage of the license's entitlements, and how current it is
+	Entitlements(ctx context.Context) (sdk.Entitlements, error)
+}
+
+// InventoryProvider supplies the inventory which the current checkout is based on
+type InventoryProvider interface {
+	// Inventory returns the inventory captured for the current checkout, false if none was captured yet
+	Inventory() (sdk.Inventory, bool)
+}
+
+// Options configures how the server listens and authenticates callers
+type Options struct {
+	// Addrs are the addresses to listen on, all serving the same routes. An address without a host (i.e. ":8080")
+	// listens on every IPv4 and IPv6 address, "[::]:8080" only on IPv6 and "0.0.0.0:8080" only on IPv4
+	Addrs []string
+	// TLSCertFile and TLSKeyFile enable tls when both are set. The files are reloaded when they change on disk
+	TLSCertFile string
+	TLSKeyFile  string
+	// ClientCAFile, if set, requires callers to present a client certificate signed by this ca (mTLS)
+	ClientCAFile string
+	// Authenticator authenticates callers of non-public routes. If nil, those routes are open, with the exception of
+	// admin routes which are never served without authentication
+	Authenticator Authenticator
+	// TrustedProxies are the proxies whose X-Forwarded-For and X-Forwarded-Authorization headers are honored, so that
+	// logs and jobs record the original caller of requests made through them
+	TrustedProxies TrustedProxies
+	// Mock, if set, adds routes adjusting the synthetic license of an adapter running with --mock-csp
+	Mock MockController
+	// Jobs and Operations, if both set, add admin routes starting long-running operations as jobs and routes following
+	// their progress
+	Jobs       JobQueue
+	Operations Operations
+	// Pauser, if set, adds admin routes pausing and resuming checkout adjustments
+	Pauser Pauser
+	// SyntheticTests, if set, adds admin routes injecting synthetic non-compliance
+	SyntheticTests SyntheticTester
+	// Maintenance, if set, adds admin routes declaring and cancelling planned AWS maintenance
+	Maintenance MaintenanceScheduler
+	// Catalog, if set, adds a route listing the known products
+	Catalog ProductCatalog
+	// Entitlements, if set, adds a route serving the usage of the license's entitlements
+	Entitlements EntitlementReader
+	// Inventory, if set, adds a route serving the inventory of the current checkout
+	Inventory InventoryProvider
+	// Profiling adds admin routes serving the runtime profiles of the adapter (pprof)
+	Profiling bool
+	// AuthenticateMetrics serves /metrics only to callers authenticated like those of admin routes. Otherwise metrics
+	// are served to anyone who can reach the port, which is what prometheus scrapes without credentials expect
+	AuthenticateMetrics bool
+	// Snapshots, if set, adds admin routes listing and downloading the profiles captured on abnormal memory growth
+	Snapshots ProfileSnapshots
+	// NodeCounts, if set, adds an admin route where external systems push node counts
+	NodeCounts NodeCountReceiver
+	// StatusPage, if set, serves a read-only html status page on /ui/status to users signed in with an OpenID Connect
+	// provider, independently of Authenticator
+	StatusPage *StatusPageOptions
+}
+
+type Server struct {
+	opts   Options
+	status StatusProvider
+}
+
+func New(opts Options, status StatusProvider) *Server {
+	return &Server{
+		opts:   opts,
+		status: status,
+	}
+}
+
+const (
+	shutdownTimeout = 5 * time.Second
+	metricsPath     = "/metrics"
+)
+
+// Start serves the status api on the configured address until ctx is cancelled. Errors are reported on errs
+func (s *Server) Start(ctx context.Context, errs chan<- error) {
+	srv := &http.Server{
+		Handler: s.Handler(),
+	}
+	useTLS := s.opts.TLSCertFile != "" && s.opts.TLSKeyFile != ""
+	if useTLS {
+		reloader, err := newCertReloader(s.opts.TLSCertFile, s.opts.TLSKeyFile, s.opts.ClientCAFile)
+		if err != nil {
+			errs <- err
+			return
+		}
+		srv.TLSConfig = reloader.TLSConfig()
+	}
+	listeners, err := listen(s.opts.Addrs)
+	if err != nil {
+		errs <- err
+		return
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			logrus.Warnf("[server] unable to gracefully shutdown: %v", err)
+		}
+	}()
+	for _, ln := range listeners {
+		ln := ln
+		// supervised, so that a panic in the server restarts it instead of taking down the compliance checks
+		supervisor.Go(ctx, "status server "+ln.Addr().String(), func(ctx context.Context) {
+			logrus.Infof("[server] listening on %s, tls: %t", ln.Addr(), useTLS)
+			var err error
+			if useTLS {
+				// certificates are provided by the TLSConfig so they can be rotated
+				err = srv.ServeTLS(ln, "", "")
+			} else {
+				err = srv.Serve(ln)
+			}
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				errs <- err
+			}
+		})
+	}
+}
+
+// listen listens on every address in addrs, closing the listeners already opened if one of them fails
+func listen(addrs []string) ([]net.Listener, error) {
+	var listeners []net.Listener
+	for _, addr := range addrs {
+		ln, err := net.Listen(listenNetwork(addr), addr)
+		if err != nil {
+			for _, opened := range listeners {
+				opened.Close()
+			}
+			return nil, fmt.Errorf("unable to listen on %s: %w", addr, err)
+		}
+		listeners = append(listeners, ln)
+	}
+	return listeners, nil
+}
+
+// listenNetwork returns the network which addr is listened on. Addresses with an IP literal only listen on its family,
+// so that "0.0.0.0:8080" and "[::]:8080" can be listened on side by side. Others listen on both families
+func listenNetwork(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "tcp"
+	}
+	ip := net.ParseIP(host)
+	switch {
+	case ip == nil:
+		return "tcp"
+	case ip.To4() != nil:
+		return "tcp4"
+	default:
+		return "tcp6"
+	}
+}
+
+// Handler returns the http.Handler serving all routes of the status api, as well as the openapi document describing them
+func (s *Server) Handler() http.Handler {
+	routes := s.routes()
+	doc := openAPIDocument(routes)
+	routes = append(routes, route{
+		method:  http.MethodGet,
+		path:    openAPIPath,
+		public:  true,
+		handler: func(w http.ResponseWriter, r *http.Request) { writeJSON(w, http.StatusOK, doc) },
+	})
+	handlers := map[string]methodHandler{}
+	for _, rt := range routes {
+		path := rt.muxPath()
+		if handlers[path] == nil {
+			handlers[path] = methodHandler{}
+		}
+		handlers[path][rt.method] = s.authenticated(rt)
+	}
+	mux := http.NewServeMux()
+	for path, handler := range handlers {
+		mux.Handle(path, handler)
+	}
+	if s.opts.StatusPage != nil {
+		// the page authenticates browsers itself and isn't part of the api, so it isn't documented in the openapi document
+		for path, handler := range s.statusPageRoutes() {
+			mux.Handle(path, handler)
+		}
+	}
+	// metrics are scraped by prometheus, which usually isn't configured to authenticate against the adapter. They
+	// expose the license and node counts of the account, so they can be put behind authentication
+	mux.Handle(metricsPath, s.authenticated(route{
+		method:  http.MethodGet,
+		path:    metricsPath,
+		handler: metrics.Handler().ServeHTTP,
+		public:  !s.opts.AuthenticateMetrics,
+		admin:   s.opts.AuthenticateMetrics,
+	}))
+	return mux
+}
+
+// authenticated wraps rt so that callers must be authenticated unless the route is public
+func (s *Server) authenticated(rt route) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = s.opts.TrustedProxies.resolve(r)
+		if rt.public {
+			rt.ServeHTTP(w, r)
+			return
+		}
+		if s.opts.Authenticator == nil {
+			if rt.admin {
+				writeJSON(w, http.StatusForbidden, errorResponse{Error: "admin routes require authentication to be configured"})
+				return
+			}
+			rt.ServeHTTP(w, r)
+			return
+		}
+		user, err := s.opts.Authenticator.Authenticate(r)
+		if err != nil {
+			logrus.Debugf("[server] rejected request to %s from %s: %v", r.URL.Path, clientFromRequest(r).ip, err)
+			writeJSON(w, http.StatusUnauthorized, errorResponse{Error: "unauthorized"})
+			return
+		}
+		if rt.admin {
+			logrus.Infof("[server] %s from %s called %s %s", user, clientFromRequest(r).ip, r.Method, r.URL.Path)
+		}
+		rt.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userKey{}, user)))
+	})
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// getStatus serves the status even while sources it's built from are unavailable, with the fields they feed marked
+// stale, so that dashboards degrade rather than fail
+func (s *Server) getStatus(w http.ResponseWriter, r *http.Request) {
+	status := s.status.Status()
+	if status.Degraded {
+		w.Header().Set("Warning", staleWarning)
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+func (s *Server) getProducts(w http.ResponseWriter, r *http.Request) {
+	products, freshness, err := s.opts.Catalog.Products(r.Context())
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, errorResponse{Error: fmt.Sprintf("unable to list products: %v", err)})
+		return
+	}
+	writeFreshness(w, freshness)
+	writeJSON(w, http.StatusOK, products)
+}
+
+func (s *Server) getEntitlements(w http.ResponseWriter, r *http.Request) {
+	entitlements, err := s.opts.Entitlements.Entitlements(r.Context())
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, errorResponse{Error: fmt.Sprintf("unable to get entitlements: %v", err)})
+		return
+	}
+	writeFreshness(w, entitlements.Freshness)
+	writeJSON(w, http.StatusOK, entitlements)
+}
+
+// staleWarning is the Warning header of responses served from the cache while it's refreshed (RFC 7234)
+const staleWarning = `110 - "Response is Stale"`
+
+// writeFreshness sets the headers of a response served from the cache of License Manager reads: its Age, and a
+// Warning while it's stale
+func writeFreshness(w http.ResponseWriter, freshness sdk.Freshness) {
+	age := time.Since(freshness.FetchedAt)
+	if age < 0 {
+		age = 0
+	}
+	w.Header().Set("Age", strconv.Itoa(int(age.Seconds())))
+	if freshness.Stale {
+		w.Header().Set("Warning", staleWarning)
+	}
+}
+
+func (s *Server) getInventory(w http.ResponseWriter, r *http.Request) {
+	inventory, ok := s.opts.Inventory.Inventory()
+	if !ok {
+		writeJSON(w, http.StatusServiceUnavailable, errorResponse{Error: "no compliance check has completed yet"})
+		return
+	}
+	writeJSON(w, http.StatusOK, inventory)
+}
+
+// maxRequestBytes limits the size of request bodies, which are all small json documents
+const maxRequestBytes = 1 << 20
+
+// readJSON decodes the json body of r into out, writing a bad request response and returning false if it can't
+func readJSON(w http.ResponseWriter, r *http.Request, out interface{}) bool {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(out); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("invalid request body: %v", err)})
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, code int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		logrus.Warnf("[server] unable to write response: %v", err)
+	}
+}
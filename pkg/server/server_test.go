@@ -0,0 +1,78 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListenNetwork(t *testing.T) {
+	assert.Equal(t, "tcp", listenNetwork(":8080"))
+	assert.Equal(t, "tcp", listenNetwork("localhost:8080"))
+	assert.Equal(t, "tcp4", listenNetwork("0.0.0.0:8080"))
+	assert.Equal(t, "tcp6", listenNetwork("[::]:8080"))
+	assert.Equal(t, "tcp6", listenNetwork("[fd00::1]:8080"))
+}
+
+func TestListenDualStack(t *testing.T) {
+	if ln, err := net.Listen("tcp6", "[::1]:0"); err != nil {
+		t.Skipf("ipv6 is unavailable: %v", err)
+	} else {
+		ln.Close()
+	}
+	listeners, err := listen([]string{"127.0.0.1:0", "[::1]:0"})
+	require.NoError(t, err)
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})}
+	defer srv.Close()
+	for _, ln := range listeners {
+		go srv.Serve(ln)
+	}
+	for _, ln := range listeners {
+		resp, err := http.Get(fmt.Sprintf("http://%s/", ln.Addr()))
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode, "expected response on %s", ln.Addr())
+	}
+
+	_, err = listen([]string{"127.0.0.1:0", "not an address"})
+	assert.Error(t, err)
+}
+
+type degradedStatus struct{}
+
+func (degradedStatus) Status() sdk.Status {
+	return sdk.Status{
+		Usage:    sdk.UsageSnapshot{Nodes: 30, CheckedOutLicenses: 2},
+		Degraded: true,
+		Sources: []sdk.DataSourceStatus{
+			{Name: sdk.SourceLicenseManager, Fields: []string{"usage.checkedOutLicenses"}, LastError: "throttled", Stale: true},
+		},
+	}
+}
+
+func TestDegradedStatus(t *testing.T) {
+	server := httptest.NewServer(New(Options{}, degradedStatus{}).Handler())
+	defer server.Close()
+
+	res, err := http.Get(server.URL + sdk.StatusPath)
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode, "the status is served while sources fail")
+	assert.Equal(t, staleWarning, res.Header.Get("Warning"))
+
+	status, err := sdk.NewClient(server.URL, nil).GetStatus(context.Background())
+	require.NoError(t, err)
+	assert.True(t, status.Degraded)
+	assert.Equal(t, 2, status.Usage.CheckedOutLicenses, "stale fields keep their last values")
+	require.Len(t, status.Sources, 1)
+	assert.Equal(t, "throttled", status.Sources[0].LastError)
+}
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/reports"
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/sirupsen/logrus"
+)
+
+// statusPageTemplate renders the status page. It is deliberately minimal and read-only: it loads no scripts and offers
+// no actions besides signing out
+var statusPageTemplate = template.Must(template.New("status").Funcs(template.FuncMap{
+	"timestamp": func(t time.Time) string {
+		if t.IsZero() {
+			return "never"
+		}
+		return t.UTC().Format(time.RFC3339)
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="60">
+<title>Rancher license compliance</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 1.5em; }
+th, td { text-align: left; padding: 0.3em 1em 0.3em 0; }
+.compliant { color: #18794e; }
+.noncompliant { color: #c62828; }
+.muted { color: #777; }
+</style>
+</head>
+<body>
+<h1>Rancher license compliance</h1>
+<p class="muted">Signed in as {{.User}}. <a href="{{.LogoutPath}}">Sign out</a></p>
+<h2>Compliance</h2>
+<table>
+<tr><th>State</th><td class="{{if .Status.Compliance.InCompliance}}compliant{{else}}noncompliant{{end}}">{{.Status.Compliance.Status}}</td></tr>
+{{- if .Status.Compliance.Reason}}
+<tr><th>Reason</th><td>{{.Status.Compliance.Reason}}</td></tr>
+{{- end}}
+{{- if .Status.Compliance.Message}}
+<tr><th>Message</th><td>{{.Status.Compliance.Message}}</td></tr>
+{{- end}}
+<tr><th>Last checked</th><td>{{timestamp .Status.Compliance.LastChecked}}</td></tr>
+<tr><th>Last report written</th><td>{{timestamp .LastReport}}</td></tr>
+<tr><th>Nodes</th><td>{{.Status.Usage.Nodes}}</td></tr>
+<tr><th>Licenses required</th><td>{{.Status.Usage.RequiredLicenses}}</td></tr>
+<tr><th>Licenses checked out</th><td>{{.Status.Usage.CheckedOutLicenses}}</td></tr>
+</table>
+<h2>Entitlements</h2>
+{{- if .EntitlementsError}}
+<p class="noncompliant">{{.EntitlementsError}}</p>
+{{- else if .Entitlements}}
+<table>
+<tr><th>Dimension</th><td>{{.Entitlements.Dimension}}</td></tr>
+<tr><th>Granted</th><td>{{.Entitlements.Max}}</td></tr>
+<tr><th>Consumed</th><td>{{.Entitlements.Consumed}}</td></tr>
+<tr><th>Available</th><td>{{.Entitlements.Available}}</td></tr>
+<tr><th>Read</th><td>{{timestamp .Entitlements.FetchedAt}}{{if .Entitlements.Stale}} <span class="muted">(refreshing)</span>{{end}}</td></tr>
+</table>
+{{- else}}
+<p class="muted">Entitlements aren't available.</p>
+{{- end}}
+</body>
+</html>
+`))
+
+// statusPageData is rendered by statusPageTemplate
+type statusPageData struct {
+	User       string
+	LogoutPath string
+	Status     sdk.Status
+	// LastReport is when the compliance report was last written, the last check if report writes aren't tracked
+	LastReport        time.Time
+	Entitlements      *sdk.Entitlements
+	EntitlementsError string
+}
+
+// statusPageRoutes returns the handlers of the status page and of signing in to it, by path
+func (s *Server) statusPageRoutes() map[string]http.Handler {
+	client := newOIDCClient(*s.opts.StatusPage)
+	get := func(handler http.HandlerFunc) http.Handler {
+		return methodHandler{http.MethodGet: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handler(w, s.opts.TrustedProxies.resolve(r))
+		})}
+	}
+	return map[string]http.Handler{
+		statusPagePath: get(func(w http.ResponseWriter, r *http.Request) {
+			user, ok := client.session(r)
+			if !ok {
+				client.login(w, r)
+				return
+			}
+			s.getStatusPage(w, r, user)
+		}),
+		callbackPath: get(client.callback),
+		logoutPath:   get(client.logout),
+	}
+}
+
+func (s *Server) getStatusPage(w http.ResponseWriter, r *http.Request, user string) {
+	status := s.status.Status()
+	data := statusPageData{
+		User:       user,
+		LogoutPath: logoutPath,
+		Status:     status,
+		LastReport: status.Compliance.LastChecked,
+	}
+	for _, sink := range status.Reports {
+		if sink.Name == reports.ConfigMapSink {
+			data.LastReport = time.Time{}
+			if sink.LastSuccess != nil {
+				data.LastReport = *sink.LastSuccess
+			}
+		}
+	}
+	if s.opts.Entitlements != nil {
+		entitlements, err := s.opts.Entitlements.Entitlements(r.Context())
+		if err != nil {
+			data.EntitlementsError = "Entitlements couldn't be read from License Manager."
+			logrus.Warnf("[server] unable to get entitlements for the status page: %v", err)
+		} else {
+			data.Entitlements = &entitlements
+		}
+	}
+	var buf bytes.Buffer
+	if err := statusPageTemplate.Execute(&buf, data); err != nil {
+		logrus.Errorf("[server] unable to render the status page: %v", err)
+		http.Error(w, "unable to render the status page", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'")
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,258 @@
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/rancher/csp-adapter/pkg/secrets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeProvider is an OpenID Connect provider which signs in whoever is named by claims
+type fakeProvider struct {
+	*httptest.Server
+	t *testing.T
+	// claims returns the claims of the id token issued for nonce
+	claims func(nonce string) map[string]interface{}
+	// challenge and nonce are those of the latest authorization request
+	challenge string
+	nonce     string
+}
+
+func newFakeProvider(t *testing.T) *fakeProvider {
+	p := &fakeProvider{t: t}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, providerMetadata{
+			Issuer:                p.URL,
+			AuthorizationEndpoint: p.URL + "/authorize",
+			TokenEndpoint:         p.URL + "/token",
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		user, password, _ := r.BasicAuth()
+		verifier := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if user != "adapter" || password != "s3cret" || r.PostForm.Get("code") != "code-1" ||
+			base64.RawURLEncoding.EncodeToString(verifier[:]) != p.challenge {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+			return
+		}
+		payload, err := json.Marshal(p.claims(p.nonce))
+		require.NoError(t, err)
+		token := "eyJhbGciOiJub25lIn0." + base64.RawURLEncoding.EncodeToString(payload) + "."
+		writeJSON(w, http.StatusOK, map[string]string{"id_token": token, "token_type": "Bearer"})
+	})
+	p.Server = httptest.NewTLSServer(mux)
+	return p
+}
+
+type compliantStatus struct{}
+
+func (compliantStatus) Status() sdk.Status {
+	return sdk.Status{
+		Compliance: sdk.ComplianceStatus{Status: sdk.ComplianceStatusCompliant, LastChecked: time.Now()},
+		Reports:    []sdk.ReportSinkStatus{{Name: "configmap", LastSuccess: sdk.OptionalTime(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC))}},
+	}
+}
+
+func validClaims(p *fakeProvider) func(nonce string) map[string]interface{} {
+	return func(nonce string) map[string]interface{} {
+		return map[string]interface{}{
+			"iss":            p.URL,
+			"aud":            "adapter",
+			"exp":            time.Now().Add(time.Hour).Unix(),
+			"nonce":          nonce,
+			"email":          "alice@example.com",
+			"email_verified": true,
+			"groups":         []string{"finance"},
+		}
+	}
+}
+
+func TestStatusPage(t *testing.T) {
+	tests := []struct {
+		name       string
+		claims     func(claims map[string]interface{})
+		badState   bool
+		wantStatus int
+	}{
+		{name: "allowed user"},
+		{name: "allowed group", claims: func(c map[string]interface{}) { c["email"] = "bob@example.com" }},
+		{
+			name: "user not allowed",
+			claims: func(c map[string]interface{}) {
+				c["email"], c["groups"] = "mallory@example.com", []string{"engineering"}
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{name: "unverified email", claims: func(c map[string]interface{}) { c["email_verified"] = false }, wantStatus: http.StatusUnauthorized},
+		{name: "other audience", claims: func(c map[string]interface{}) { c["aud"] = []string{"other"} }, wantStatus: http.StatusUnauthorized},
+		{name: "other issuer", claims: func(c map[string]interface{}) { c["iss"] = "https://evil.example.com" }, wantStatus: http.StatusUnauthorized},
+		{name: "replayed nonce", claims: func(c map[string]interface{}) { c["nonce"] = "old" }, wantStatus: http.StatusUnauthorized},
+		{name: "expired token", claims: func(c map[string]interface{}) { c["exp"] = time.Now().Add(-time.Minute).Unix() }, wantStatus: http.StatusUnauthorized},
+		{name: "forged state", badState: true, wantStatus: http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			provider := newFakeProvider(t)
+			defer provider.Close()
+			provider.claims = func(nonce string) map[string]interface{} {
+				claims := validClaims(provider)(nonce)
+				if test.claims != nil {
+					test.claims(claims)
+				}
+				return claims
+			}
+
+			var handler http.Handler
+			adapter := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { handler.ServeHTTP(w, r) }))
+			defer adapter.Close()
+			handler = New(Options{
+				Entitlements: staticEntitlements{Dimension: "RKE_NODE_SUPP", Max: 10, Consumed: 4, Available: 6},
+				StatusPage: &StatusPageOptions{
+					IssuerURL:     provider.URL,
+					HTTPClient:    provider.Client(),
+					ClientID:      "adapter",
+					ClientSecret:  secrets.Literal("s3cret"),
+					URL:           adapter.URL,
+					AllowedUsers:  []string{"alice@example.com"},
+					AllowedGroups: []string{"finance"},
+					SessionKey:    make([]byte, 32),
+				},
+			}, compliantStatus{}).Handler()
+
+			jar, err := cookiejar.New(nil)
+			require.NoError(t, err)
+			browser := &http.Client{Jar: jar, CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			}}
+
+			res, err := browser.Get(adapter.URL + statusPagePath)
+			require.NoError(t, err)
+			res.Body.Close()
+			require.Equal(t, http.StatusFound, res.StatusCode, "signed out users are sent to the provider")
+			authorize, err := url.Parse(res.Header.Get("Location"))
+			require.NoError(t, err)
+			assert.Equal(t, provider.URL+"/authorize", authorize.Scheme+"://"+authorize.Host+authorize.Path)
+			query := authorize.Query()
+			assert.Equal(t, adapter.URL+callbackPath, query.Get("redirect_uri"))
+			assert.Equal(t, "S256", query.Get("code_challenge_method"))
+			provider.challenge, provider.nonce = query.Get("code_challenge"), query.Get("nonce")
+
+			state := query.Get("state")
+			if test.badState {
+				state = "forged"
+			}
+			res, err = browser.Get(adapter.URL + callbackPath + "?" + url.Values{"code": {"code-1"}, "state": {state}}.Encode())
+			require.NoError(t, err)
+			res.Body.Close()
+			if test.wantStatus != 0 {
+				assert.Equal(t, test.wantStatus, res.StatusCode)
+				res, err = browser.Get(adapter.URL + statusPagePath)
+				require.NoError(t, err)
+				res.Body.Close()
+				assert.Equal(t, http.StatusFound, res.StatusCode, "no session is started")
+				return
+			}
+			require.Equal(t, http.StatusFound, res.StatusCode)
+			assert.Equal(t, statusPagePath, res.Header.Get("Location"))
+
+			res, err = browser.Get(adapter.URL + statusPagePath)
+			require.NoError(t, err)
+			body, err := ioutil.ReadAll(res.Body)
+			res.Body.Close()
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusOK, res.StatusCode)
+			assert.Equal(t, "text/html; charset=utf-8", res.Header.Get("Content-Type"))
+			page := string(body)
+			assert.Contains(t, page, sdk.ComplianceStatusCompliant)
+			assert.Contains(t, page, "2022-01-01T00:00:00Z", "the last report time is shown")
+			assert.Contains(t, page, "<tr><th>Available</th><td>6</td></tr>")
+
+			res, err = browser.Get(adapter.URL + logoutPath)
+			require.NoError(t, err)
+			res.Body.Close()
+			res, err = browser.Get(adapter.URL + statusPagePath)
+			require.NoError(t, err)
+			res.Body.Close()
+			assert.Equal(t, http.StatusFound, res.StatusCode, "signed out")
+		})
+	}
+}
+
+func TestStatusPageSessionSignature(t *testing.T) {
+	client := newOIDCClient(StatusPageOptions{
+		SessionKey:   []byte("0123456789abcdef0123456789abcdef"),
+		AllowedUsers: []string{"alice@example.com"},
+	})
+	rec := httptest.NewRecorder()
+	client.setCookie(rec, sessionCookie, session{User: "alice@example.com", Expires: time.Now().Add(time.Hour)}, time.Hour)
+	cookie := rec.Result().Cookies()[0]
+
+	req := httptest.NewRequest(http.MethodGet, statusPagePath, nil)
+	req.AddCookie(cookie)
+	user, ok := client.session(req)
+	assert.True(t, ok)
+	assert.Equal(t, "alice@example.com", user)
+
+	forged, err := json.Marshal(session{User: "alice@example.com", Groups: []string{"finance"}, Expires: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+	cookie.Value = base64.RawURLEncoding.EncodeToString(forged) + cookie.Value[len(cookie.Value)-44:]
+	req = httptest.NewRequest(http.MethodGet, statusPagePath, nil)
+	req.AddCookie(cookie)
+	_, ok = client.session(req)
+	assert.False(t, ok, "sessions can't be forged without the key")
+
+	client.opts.AllowedUsers = []string{"bob@example.com"}
+	req = httptest.NewRequest(http.MethodGet, statusPagePath, nil)
+	req.AddCookie(rec.Result().Cookies()[0])
+	_, ok = client.session(req)
+	assert.False(t, ok, "users removed from the allowed users are signed out")
+}
+
+func TestStatusPageRequiresHTTPSProvider(t *testing.T) {
+	var issuer string
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, providerMetadata{
+			Issuer:                issuer,
+			AuthorizationEndpoint: issuer + "/authorize",
+			TokenEndpoint:         issuer + "/token",
+		})
+	}))
+	defer provider.Close()
+	issuer = provider.URL
+
+	client := newOIDCClient(StatusPageOptions{IssuerURL: issuer})
+	_, err := client.discover(context.Background())
+	assert.Error(t, err, "id tokens received over http aren't authenticated")
+}
+
+func TestStatusPageOptionsValidate(t *testing.T) {
+	opts := StatusPageOptions{
+		IssuerURL:    "https://idp.example.com",
+		ClientID:     "adapter",
+		ClientSecret: secrets.Literal("s3cret"),
+		URL:          "https://csp-adapter.example.com",
+		AllowedUsers: []string{"alice@example.com"},
+		SessionKey:   make([]byte, 32),
+	}
+	assert.NoError(t, opts.Validate())
+	opts.IssuerURL = "http://idp.example.com"
+	assert.Error(t, opts.Validate(), "the issuer must be https")
+	opts.IssuerURL = "https://idp.example.com"
+	opts.AllowedUsers = nil
+	assert.Error(t, opts.Validate(), "anyone the provider lets sign in would see the page")
+}
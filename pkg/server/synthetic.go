@@ -0,0 +1,65 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/sdk"
+)
+
+// SyntheticTester injects synthetic non-compliance, so that operators can test their alerting end to end
+type SyntheticTester interface {
+	// InjectNonCompliance reports the install as out of compliance for duration on behalf of the named user, without
+	// checking in any licenses
+	InjectNonCompliance(by, reason string, duration time.Duration) (sdk.SyntheticTestStatus, error)
+	// ClearNonCompliance ends the running test early
+	ClearNonCompliance(by string)
+}
+
+const syntheticPath = "/v1/admin/synthetic-noncompliance"
+
+// SyntheticTestRequest injects non-compliance for Minutes, Reason is reported with it
+type SyntheticTestRequest struct {
+	Minutes int    `json:"minutes"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+func (s *Server) syntheticRoutes() []route {
+	return []route{
+		{
+			method:   http.MethodPost,
+			path:     syntheticPath,
+			summary:  "Report the install as out of compliance for a number of minutes to test alerting, no licenses are checked in",
+			request:  SyntheticTestRequest{},
+			response: sdk.SyntheticTestStatus{},
+			handler:  s.injectNonCompliance,
+			admin:    true,
+		},
+		{
+			method:  http.MethodDelete,
+			path:    syntheticPath,
+			summary: "End a synthetic non-compliance test early",
+			code:    http.StatusNoContent,
+			handler: s.clearNonCompliance,
+			admin:   true,
+		},
+	}
+}
+
+func (s *Server) injectNonCompliance(w http.ResponseWriter, r *http.Request) {
+	var req SyntheticTestRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	test, err := s.opts.SyntheticTests.InjectNonCompliance(requester(r), req.Reason, time.Duration(req.Minutes)*time.Minute)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, test)
+}
+
+func (s *Server) clearNonCompliance(w http.ResponseWriter, r *http.Request) {
+	s.opts.SyntheticTests.ClearNonCompliance(requester(r))
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSyntheticTester struct {
+	test *sdk.SyntheticTestStatus
+}
+
+func (f *fakeSyntheticTester) InjectNonCompliance(by, reason string, duration time.Duration) (sdk.SyntheticTestStatus, error) {
+	if duration <= 0 {
+		return sdk.SyntheticTestStatus{}, errors.New("invalid duration")
+	}
+	now := time.Now()
+	f.test = &sdk.SyntheticTestStatus{By: by, Reason: reason, Since: now, Until: now.Add(duration)}
+	return *f.test, nil
+}
+
+func (f *fakeSyntheticTester) ClearNonCompliance(by string) {
+	f.test = nil
+}
+
+func TestSyntheticRoutes(t *testing.T) {
+	tester := &fakeSyntheticTester{}
+	server := httptest.NewServer(New(Options{
+		Authenticator:  allowAll{},
+		SyntheticTests: tester,
+	}, staticStatus{}).Handler())
+	defer server.Close()
+
+	res, err := http.Post(server.URL+syntheticPath, "application/json", strings.NewReader(`{"minutes":0}`))
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+	res, err = http.Post(server.URL+syntheticPath, "application/json", strings.NewReader(`{"minutes":15,"reason":"alert routing drill"}`))
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	var test sdk.SyntheticTestStatus
+	assert.NoError(t, json.NewDecoder(res.Body).Decode(&test))
+	res.Body.Close()
+	assert.Equal(t, "alert routing drill", test.Reason)
+	assert.Equal(t, 15*time.Minute, test.Until.Sub(test.Since))
+	require.NotNil(t, tester.test)
+
+	req, err := http.NewRequest(http.MethodDelete, server.URL+syntheticPath, nil)
+	require.NoError(t, err)
+	res, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusNoContent, res.StatusCode)
+	assert.Nil(t, tester.test)
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// certReloader serves the certificate (and client ca) found on disk, reloading them when the mounted files change so
+// that rotated secrets are picked up without a restart
+type certReloader struct {
+	certFile     string
+	keyFile      string
+	clientCAFile string
+
+	lock     sync.Mutex
+	modTimes map[string]time.Time
+	config   *tls.Config
+}
+
+func newCertReloader(certFile, keyFile, clientCAFile string) (*certReloader, error) {
+	c := &certReloader{
+		certFile:     certFile,
+		keyFile:      keyFile,
+		clientCAFile: clientCAFile,
+		modTimes:     map[string]time.Time{},
+	}
+	if _, err := c.load(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// TLSConfig returns a tls.Config which always serves the latest certificates from disk
+func (c *certReloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			config, err := c.load()
+			if err != nil {
+				// keep serving the last good config, rotation may be in progress
+				logrus.Warnf("[server] unable to reload certificates, using previous certificates: %v", err)
+			}
+			return config, nil
+		},
+	}
+}
+
+// load returns the current config, re-reading the files from disk if any of them have changed
+func (c *certReloader) load() (*tls.Config, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	modTimes, changed, err := c.filesChanged()
+	if err != nil || !changed {
+		return c.config, err
+	}
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return c.config, fmt.Errorf("unable to load serving certificate: %v", err)
+	}
+	config := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	}
+	if c.clientCAFile != "" {
+		caPEM, err := os.ReadFile(c.clientCAFile)
+		if err != nil {
+			return c.config, fmt.Errorf("unable to read client ca: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return c.config, fmt.Errorf("no certificates found in client ca %s", c.clientCAFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	logrus.Infof("[server] loaded tls certificates")
+	c.modTimes = modTimes
+	c.config = config
+	return c.config, nil
+}
+
+// filesChanged returns the current modification times of the files and whether any differ from the loaded ones
+func (c *certReloader) filesChanged() (map[string]time.Time, bool, error) {
+	modTimes := map[string]time.Time{}
+	changed := false
+	for _, file := range []string{c.certFile, c.keyFile, c.clientCAFile} {
+		if file == "" {
+			continue
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, false, err
+		}
+		modTimes[file] = info.ModTime()
+		if !info.ModTime().Equal(c.modTimes[file]) {
+			changed = true
+		}
+	}
+	return modTimes, changed, nil
+}
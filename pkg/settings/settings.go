@@ -0,0 +1,204 @@
+// Package settings loads the adapter's settings from layered files: base documents which apply everywhere, overlaid by
+// the documents of the environment the adapter runs in (i.e. staging or production), so that several clusters can be
+// configured from the same files in git. Settings are the adapter's environment variables, which keep precedence over
+// the files unless the files are loaded without looking up the environment
+package settings
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// document is a single yaml document of a config file. Documents without an environment are the base, the others
+// overlay it in the named environment
+type document struct {
+	Environment string                 `json:"environment,omitempty"`
+	Settings    map[string]interface{} `json:"settings"`
+}
+
+// Setting is the effective value of a setting and where it came from
+type Setting struct {
+	Name  string
+	Value string
+	// Origin describes the layer which set Value, i.e. "base (/etc/csp-adapter/config/base.yaml)"
+	Origin string
+}
+
+// Config is the effective configuration merged from base documents, environment overlays and the process environment,
+// in increasing precedence
+type Config struct {
+	settings map[string]Setting
+}
+
+// OriginEnv is the origin of settings taken from the process environment, which override every file
+const OriginEnv = "environment variable"
+
+var (
+	settingName       = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+	documentSeparator = regexp.MustCompile(`(?m)^---\s*$`)
+)
+
+// Load merges the documents of the files at paths for environment. A path which is a directory loads its .yaml and
+// .yml files in lexical order. Base documents are applied in file order first, then the documents of environment in
+// file order, each overriding the settings of the layers before. Documents of other environments are skipped.
+// lookupEnv returns the process environment, which overrides every file. Nil ignores it
+func Load(paths []string, environment string, lookupEnv func(string) (string, bool)) (*Config, error) {
+	files, err := expand(paths)
+	if err != nil {
+		return nil, err
+	}
+	var base, overlays []layer
+	for _, file := range files {
+		documents, err := readDocuments(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, doc := range documents {
+			switch doc.Environment {
+			case "":
+				base = append(base, layer{origin: fmt.Sprintf("base (%s)", file), document: doc})
+			case environment:
+				overlays = append(overlays, layer{origin: fmt.Sprintf("overlay %s (%s)", environment, file), document: doc})
+			}
+		}
+	}
+	c := &Config{settings: map[string]Setting{}}
+	for _, l := range append(base, overlays...) {
+		for name, value := range l.document.Settings {
+			c.settings[name] = Setting{Name: name, Value: value.(string), Origin: l.origin}
+		}
+	}
+	if lookupEnv != nil {
+		for name := range c.settings {
+			if value, ok := lookupEnv(name); ok {
+				c.settings[name] = Setting{Name: name, Value: value, Origin: OriginEnv}
+			}
+		}
+	}
+	return c, nil
+}
+
+type layer struct {
+	origin   string
+	document document
+}
+
+// Settings returns the effective settings sorted by name
+func (c *Config) Settings() []Setting {
+	settings := make([]Setting, 0, len(c.settings))
+	for _, setting := range c.settings {
+		settings = append(settings, setting)
+	}
+	sort.Slice(settings, func(i, j int) bool { return settings[i].Name < settings[j].Name })
+	return settings
+}
+
+// Apply sets the settings which weren't taken from the process environment with setenv, so that the adapter reads the
+// effective configuration from its environment
+func (c *Config) Apply(setenv func(name, value string) error) error {
+	for _, setting := range c.Settings() {
+		if setting.Origin == OriginEnv {
+			continue
+		}
+		if err := setenv(setting.Name, setting.Value); err != nil {
+			return fmt.Errorf("unable to apply %s: %v", setting.Name, err)
+		}
+	}
+	return nil
+}
+
+// Render formats the effective settings as a yaml document, commenting where each of them came from
+func (c *Config) Render() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("settings:\n")
+	for _, setting := range c.Settings() {
+		value, err := yaml.Marshal(setting.Value)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&buf, "  %s: %s # %s\n", setting.Name, strings.TrimSpace(string(value)), setting.Origin)
+	}
+	return buf.Bytes(), nil
+}
+
+// expand replaces directories in paths by the yaml files they contain
+func expand(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		// sorted by name, so that files can be prefixed to order them
+		for _, entry := range entries {
+			ext := filepath.Ext(entry.Name())
+			// skips the hidden entries of mounted configmaps
+			if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") || (ext != ".yaml" && ext != ".yml") {
+				continue
+			}
+			files = append(files, filepath.Join(path, entry.Name()))
+		}
+	}
+	return files, nil
+}
+
+// readDocuments parses the yaml documents of file, validating their settings and formatting their values as strings
+func readDocuments(file string) ([]document, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var documents []document
+	for i, raw := range splitDocuments(data) {
+		var doc document
+		if err := yaml.UnmarshalStrict(raw, &doc); err != nil {
+			return nil, fmt.Errorf("invalid document %d of %s: %v", i+1, file, err)
+		}
+		for name, value := range doc.Settings {
+			if !settingName.MatchString(name) {
+				return nil, fmt.Errorf("invalid setting %q in document %d of %s, settings are named like the adapter's environment variables", name, i+1, file)
+			}
+			switch value := value.(type) {
+			case string:
+			case bool:
+				doc.Settings[name] = strconv.FormatBool(value)
+			case float64:
+				doc.Settings[name] = strconv.FormatFloat(value, 'f', -1, 64)
+			case nil:
+				doc.Settings[name] = ""
+			default:
+				return nil, fmt.Errorf("setting %s in document %d of %s must be a string, number or boolean", name, i+1, file)
+			}
+		}
+		documents = append(documents, doc)
+	}
+	return documents, nil
+}
+
+// splitDocuments splits data at yaml document separators, dropping empty documents
+func splitDocuments(data []byte) [][]byte {
+	var documents [][]byte
+	for _, raw := range documentSeparator.Split(string(data), -1) {
+		if strings.TrimSpace(raw) == "" {
+			continue
+		}
+		documents = append(documents, []byte(raw))
+	}
+	return documents
+}
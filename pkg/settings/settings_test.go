@@ -0,0 +1,155 @@
+package settings
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const baseFile = `settings:
+  MINIMUM_LICENSES: 2
+  SCALE_DOWN_CONFIRMATION_MINUTES: 5
+  CLASSIFY_ENVIRONMENTS: false
+---
+environment: production
+settings:
+  MINIMUM_LICENSES: 10
+---
+environment: staging
+settings:
+  MINIMUM_LICENSES: 0
+`
+
+const overlayFile = `environment: production
+settings:
+  SCALE_DOWN_CONFIRMATION_MINUTES: "15"
+  NON_PRODUCTION_RATIO: 0.5
+`
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	base := writeFile(t, dir, "00-base.yaml", baseFile)
+	overlay := writeFile(t, dir, "10-production.yaml", overlayFile)
+	writeFile(t, dir, "README.md", "not a config file")
+
+	tests := []struct {
+		name        string
+		paths       []string
+		environment string
+		env         map[string]string
+		// filesOverride loads without looking up the environment, like the adapter with CONFIG_FILES_OVERRIDE_ENV
+		filesOverride bool
+		expected      []Setting
+	}{
+		{
+			name:  "base only",
+			paths: []string{base, overlay},
+			expected: []Setting{
+				{Name: "CLASSIFY_ENVIRONMENTS", Value: "false", Origin: "base (" + base + ")"},
+				{Name: "MINIMUM_LICENSES", Value: "2", Origin: "base (" + base + ")"},
+				{Name: "SCALE_DOWN_CONFIRMATION_MINUTES", Value: "5", Origin: "base (" + base + ")"},
+			},
+		},
+		{
+			name:        "overlays override the base of every file",
+			paths:       []string{dir},
+			environment: "production",
+			expected: []Setting{
+				{Name: "CLASSIFY_ENVIRONMENTS", Value: "false", Origin: "base (" + base + ")"},
+				{Name: "MINIMUM_LICENSES", Value: "10", Origin: "overlay production (" + base + ")"},
+				{Name: "NON_PRODUCTION_RATIO", Value: "0.5", Origin: "overlay production (" + overlay + ")"},
+				{Name: "SCALE_DOWN_CONFIRMATION_MINUTES", Value: "15", Origin: "overlay production (" + overlay + ")"},
+			},
+		},
+		{
+			name:        "environment variables override the files",
+			paths:       []string{base},
+			environment: "staging",
+			env:         map[string]string{"MINIMUM_LICENSES": "4", "UNRELATED": "x"},
+			expected: []Setting{
+				{Name: "CLASSIFY_ENVIRONMENTS", Value: "false", Origin: "base (" + base + ")"},
+				{Name: "MINIMUM_LICENSES", Value: "4", Origin: OriginEnv},
+				{Name: "SCALE_DOWN_CONFIRMATION_MINUTES", Value: "5", Origin: "base (" + base + ")"},
+			},
+		},
+		{
+			name:          "files override the environment variables",
+			paths:         []string{base},
+			environment:   "staging",
+			env:           map[string]string{"MINIMUM_LICENSES": "4"},
+			filesOverride: true,
+			expected: []Setting{
+				{Name: "CLASSIFY_ENVIRONMENTS", Value: "false", Origin: "base (" + base + ")"},
+				{Name: "MINIMUM_LICENSES", Value: "0", Origin: "overlay staging (" + base + ")"},
+				{Name: "SCALE_DOWN_CONFIRMATION_MINUTES", Value: "5", Origin: "base (" + base + ")"},
+			},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			lookupEnv := func(name string) (string, bool) {
+				value, ok := test.env[name]
+				return value, ok
+			}
+			if test.filesOverride {
+				lookupEnv = nil
+			}
+			cfg, err := Load(test.paths, test.environment, lookupEnv)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, cfg.Settings())
+		})
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{name: "unknown field", content: "setting:\n  MINIMUM_LICENSES: 2\n"},
+		{name: "invalid name", content: "settings:\n  minimumLicenses: 2\n"},
+		{name: "nested value", content: "settings:\n  LICENSE_TAGS:\n    environment: production\n"},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			path := writeFile(t, t.TempDir(), "config.yaml", test.content)
+			_, err := Load([]string{path}, "", nil)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestApplyAndRender(t *testing.T) {
+	path := writeFile(t, t.TempDir(), "config.yaml", baseFile)
+	cfg, err := Load([]string{path}, "production", func(name string) (string, bool) {
+		return "3", name == "SCALE_DOWN_CONFIRMATION_MINUTES"
+	})
+	require.NoError(t, err)
+
+	applied := map[string]string{}
+	require.NoError(t, cfg.Apply(func(name, value string) error {
+		applied[name] = value
+		return nil
+	}))
+	// settings from the environment are left alone
+	assert.Equal(t, map[string]string{"CLASSIFY_ENVIRONMENTS": "false", "MINIMUM_LICENSES": "10"}, applied)
+
+	rendered, err := cfg.Render()
+	require.NoError(t, err)
+	assert.Equal(t, `settings:
+  CLASSIFY_ENVIRONMENTS: "false" # base (`+path+`)
+  MINIMUM_LICENSES: "10" # overlay production (`+path+`)
+  SCALE_DOWN_CONFIRMATION_MINUTES: "3" # environment variable
+`, string(rendered))
+}
@@ -0,0 +1,184 @@
+// Package setup proposes the configuration of a first install of the adapter from the license found in the account, lets
+// an operator review it and renders it as helm values
+package setup
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"sigs.k8s.io/yaml"
+)
+
+// nodeDimension is the entitlement dimension the adapter checks out by default, other dimensions need an alias
+const nodeDimension = "RKE_NODE_SUPP"
+
+// Proposal is the configuration proposed for an install. Review lets the operator change the fields from RoleName on
+type Proposal struct {
+	AccountNumber string
+	// Product, SKU and LicenseArn describe the license which was found, for the operator's information
+	Product    string
+	SKU        string
+	LicenseArn string
+
+	RoleName string
+	// DimensionAliases are the dimensions granted by the license in place of RKE_NODE_SUPP
+	DimensionAliases               []string
+	MinimumLicenses                int
+	MaxTokenExtensions             int
+	ScaleDownConfirmationMinutes   int
+	UsageVerificationWindowSeconds int
+}
+
+// Propose proposes the configuration for account from the rancher products it received a license for. The product the
+// adapter would use is preferred, otherwise the first with an active license. Returns an error describing what is
+// missing if no license can be used
+func Propose(account, roleName string, products []sdk.Product) (Proposal, error) {
+	var found *sdk.Product
+	var inactive []string
+	for i := range products {
+		product := &products[i]
+		if product.Matched {
+			found = product
+			break
+		}
+		if product.Active && found == nil {
+			found = product
+		}
+		if product.Found && !product.Active {
+			inactive = append(inactive, fmt.Sprintf("%s (%s)", product.Name, product.Status))
+		}
+	}
+	if found == nil {
+		if len(inactive) > 0 {
+			return Proposal{}, fmt.Errorf("the licenses received by account %s can't be used: %s", account, strings.Join(inactive, ", "))
+		}
+		return Proposal{}, fmt.Errorf("account %s received no rancher license, subscribe to rancher in the AWS marketplace and accept the license grant in License Manager", account)
+	}
+	proposal := Proposal{
+		AccountNumber:                  account,
+		Product:                        found.Name,
+		SKU:                            found.SKU,
+		LicenseArn:                     found.LicenseArn,
+		RoleName:                       roleName,
+		MaxTokenExtensions:             24,
+		ScaleDownConfirmationMinutes:   5,
+		UsageVerificationWindowSeconds: 120,
+	}
+	grantsNodes := false
+	var counted []string
+	for _, dimension := range found.Dimensions {
+		if dimension.Name == nodeDimension {
+			grantsNodes = true
+		} else if dimension.Unit == "Count" {
+			counted = append(counted, dimension.Name)
+		}
+	}
+	if !grantsNodes {
+		// the license was issued under a renamed dimension
+		proposal.DimensionAliases = counted
+	}
+	return proposal, nil
+}
+
+// Review prompts for each configurable value of p on out, reading the answers from in. An empty answer keeps the
+// proposed value, invalid answers are asked again
+func Review(in io.Reader, out io.Writer, p *Proposal) error {
+	scanner := bufio.NewScanner(in)
+	ask := func(question, proposed string) (string, error) {
+		fmt.Fprintf(out, "%s [%s]: ", question, proposed)
+		if !scanner.Scan() {
+			if err := scanner.Err(); err != nil {
+				return "", err
+			}
+			return "", io.ErrUnexpectedEOF
+		}
+		answer := strings.TrimSpace(scanner.Text())
+		if answer == "" {
+			return proposed, nil
+		}
+		return answer, nil
+	}
+	askInt := func(question string, value *int) error {
+		for {
+			answer, err := ask(question, strconv.Itoa(*value))
+			if err != nil {
+				return err
+			}
+			n, err := strconv.Atoi(answer)
+			if err == nil && n >= 0 {
+				*value = n
+				return nil
+			}
+			fmt.Fprintf(out, "%q isn't a number of 0 or more\n", answer)
+		}
+	}
+
+	fmt.Fprintf(out, "found %s license %s (sku %s) in account %s\n", p.Product, p.LicenseArn, p.SKU, p.AccountNumber)
+	answer, err := ask("iam role used by the adapter", p.RoleName)
+	if err != nil {
+		return err
+	}
+	p.RoleName = answer
+	answer, err = ask("other names of the RKE_NODE_SUPP dimension, comma separated, - for none", strings.Join(p.DimensionAliases, ","))
+	if err != nil {
+		return err
+	}
+	p.DimensionAliases = nil
+	if answer != "-" {
+		for _, alias := range strings.Split(answer, ",") {
+			if alias = strings.TrimSpace(alias); alias != "" {
+				p.DimensionAliases = append(p.DimensionAliases, alias)
+			}
+		}
+	}
+	if err := askInt("licenses always kept checked out (contractual minimum)", &p.MinimumLicenses); err != nil {
+		return err
+	}
+	if err := askInt("extensions allowed per consumption token", &p.MaxTokenExtensions); err != nil {
+		return err
+	}
+	if err := askInt("minutes a scale-down is confirmed before licenses are checked in", &p.ScaleDownConfirmationMinutes); err != nil {
+		return err
+	}
+	return askInt("seconds to wait for License Manager to report a checkout's usage", &p.UsageVerificationWindowSeconds)
+}
+
+type values struct {
+	MinimumLicenses    int `json:"minimumLicenses"`
+	MaxTokenExtensions int `json:"maxTokenExtensions"`
+	ScaleDown          struct {
+		ConfirmationMinutes int `json:"confirmationMinutes"`
+	} `json:"scaleDown"`
+	UsageVerification struct {
+		WindowSeconds int `json:"windowSeconds"`
+	} `json:"usageVerification"`
+	AWS struct {
+		Enabled          bool     `json:"enabled"`
+		AccountNumber    string   `json:"accountNumber"`
+		RoleName         string   `json:"roleName"`
+		DimensionAliases []string `json:"dimensionAliases,omitempty"`
+	} `json:"aws"`
+}
+
+// Values renders p as values of the adapter's helm chart
+func (p Proposal) Values() ([]byte, error) {
+	var v values
+	v.MinimumLicenses = p.MinimumLicenses
+	v.MaxTokenExtensions = p.MaxTokenExtensions
+	v.ScaleDown.ConfirmationMinutes = p.ScaleDownConfirmationMinutes
+	v.UsageVerification.WindowSeconds = p.UsageVerificationWindowSeconds
+	v.AWS.Enabled = true
+	v.AWS.AccountNumber = p.AccountNumber
+	v.AWS.RoleName = p.RoleName
+	v.AWS.DimensionAliases = p.DimensionAliases
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	header := fmt.Sprintf("# generated by csp-adapter init for the %s license %s\n", p.Product, p.LicenseArn)
+	return append([]byte(header), data...), nil
+}
@@ -0,0 +1,134 @@
+package setup
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPropose(t *testing.T) {
+	nodes := sdk.ProductDimension{Name: "RKE_NODE_SUPP", Unit: "Count", MaxCount: 10}
+	tests := []struct {
+		name            string
+		products        []sdk.Product
+		expectedArn     string
+		expectedAliases []string
+		expectedErr     string
+	}{
+		{
+			name: "matched product",
+			products: []sdk.Product{
+				{Name: "Rancher", Found: true, Active: true, LicenseArn: "arn-1", Dimensions: []sdk.ProductDimension{nodes}},
+				{Name: "Rancher (EMEA)", Found: true, Active: true, Matched: true, LicenseArn: "arn-2", Dimensions: []sdk.ProductDimension{nodes}},
+			},
+			expectedArn: "arn-2",
+		},
+		{
+			name: "first active product",
+			products: []sdk.Product{
+				{Name: "Rancher", Found: true, Status: "DISABLED", LicenseArn: "arn-1"},
+				{Name: "Rancher (EMEA)", Found: true, Active: true, LicenseArn: "arn-2", Dimensions: []sdk.ProductDimension{nodes}},
+			},
+			expectedArn: "arn-2",
+		},
+		{
+			name: "renamed dimension",
+			products: []sdk.Product{
+				{Name: "Rancher", Found: true, Active: true, Matched: true, LicenseArn: "arn-1", Dimensions: []sdk.ProductDimension{
+					{Name: "RANCHER_NODE", Unit: "Count", MaxCount: 10},
+					{Name: "SUPPORT_TIER", Unit: "None"},
+				}},
+			},
+			expectedArn:     "arn-1",
+			expectedAliases: []string{"RANCHER_NODE"},
+		},
+		{
+			name: "inactive license",
+			products: []sdk.Product{
+				{Name: "Rancher", Found: true, Status: "EXPIRED", LicenseArn: "arn-1"},
+			},
+			expectedErr: "can't be used: Rancher (EXPIRED)",
+		},
+		{
+			name:        "no license",
+			products:    []sdk.Product{{Name: "Rancher"}},
+			expectedErr: "received no rancher license",
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			proposal, err := Propose("111111111111", "rancher-csp-adapter", test.products)
+			if test.expectedErr != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), test.expectedErr)
+				}
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedArn, proposal.LicenseArn)
+			assert.Equal(t, test.expectedAliases, proposal.DimensionAliases)
+			assert.Equal(t, "rancher-csp-adapter", proposal.RoleName)
+			assert.Equal(t, 24, proposal.MaxTokenExtensions)
+		})
+	}
+}
+
+func TestReview(t *testing.T) {
+	proposal := Proposal{
+		AccountNumber:                  "111111111111",
+		RoleName:                       "rancher-csp-adapter",
+		DimensionAliases:               []string{"RANCHER_NODE"},
+		MaxTokenExtensions:             24,
+		ScaleDownConfirmationMinutes:   5,
+		UsageVerificationWindowSeconds: 120,
+	}
+	// keeps the role, clears the aliases, asks again for an invalid minimum and keeps the remaining values
+	in := strings.NewReader("\n-\nten\n2\n\n\n60\n")
+	var out bytes.Buffer
+	require.NoError(t, Review(in, &out, &proposal))
+	assert.Equal(t, "rancher-csp-adapter", proposal.RoleName)
+	assert.Empty(t, proposal.DimensionAliases)
+	assert.Equal(t, 2, proposal.MinimumLicenses)
+	assert.Equal(t, 24, proposal.MaxTokenExtensions)
+	assert.Equal(t, 5, proposal.ScaleDownConfirmationMinutes)
+	assert.Equal(t, 60, proposal.UsageVerificationWindowSeconds)
+	assert.Contains(t, out.String(), `"ten" isn't a number`)
+
+	// input ending before every value was reviewed fails instead of writing a partial review
+	assert.Error(t, Review(strings.NewReader("my-role\n"), &out, &proposal))
+}
+
+func TestValues(t *testing.T) {
+	proposal := Proposal{
+		AccountNumber:                  "111111111111",
+		Product:                        "Rancher",
+		LicenseArn:                     "arn-1",
+		RoleName:                       "rancher-csp-adapter",
+		DimensionAliases:               []string{"RANCHER_NODE"},
+		MinimumLicenses:                2,
+		MaxTokenExtensions:             24,
+		ScaleDownConfirmationMinutes:   5,
+		UsageVerificationWindowSeconds: 120,
+	}
+	values, err := proposal.Values()
+	require.NoError(t, err)
+	assert.Equal(t, `# generated by csp-adapter init for the Rancher license arn-1
+aws:
+  accountNumber: "111111111111"
+  dimensionAliases:
+  - RANCHER_NODE
+  enabled: true
+  roleName: rancher-csp-adapter
+maxTokenExtensions: 24
+minimumLicenses: 2
+scaleDown:
+  confirmationMinutes: 5
+usageVerification:
+  windowSeconds: 120
+`, string(values))
+}
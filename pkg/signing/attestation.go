@@ -0,0 +1,44 @@
+package signing
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// Attestation is a signed statement of the digest of an artifact, i.e. a true-up report, written next to it so that the
+// report can be shown to be produced by the adapter and unmodified
+type Attestation struct {
+	// Artifact is the file name of the artifact
+	Artifact string    `json:"artifact"`
+	SHA256   string    `json:"sha256"`
+	SignedAt time.Time `json:"signedAt"`
+	Signature
+}
+
+// Attest returns the attestation of content, the artifact called name, signed with the active key of k
+func Attest(k *Keyring, name string, content []byte, now time.Time) Attestation {
+	sum := sha256.Sum256(content)
+	attestation := Attestation{
+		Artifact: name,
+		SHA256:   hex.EncodeToString(sum[:]),
+		SignedAt: now.UTC(),
+	}
+	attestation.Signature = k.Sign(attestation.payload())
+	return attestation
+}
+
+// VerifyAttestation checks that a is a valid attestation of content by any key of k
+func VerifyAttestation(k *Keyring, a Attestation, content []byte) error {
+	sum := sha256.Sum256(content)
+	if hex.EncodeToString(sum[:]) != a.SHA256 {
+		return fmt.Errorf("%s doesn't match its attestation, it was modified", a.Artifact)
+	}
+	return k.Verify(a.payload(), a.Signature)
+}
+
+// payload is what's signed for the attestation
+func (a Attestation) payload() []byte {
+	return []byte(fmt.Sprintf("%s\n%s\n%s", a.Artifact, a.SHA256, a.SignedAt.Format(time.RFC3339Nano)))
+}
@@ -0,0 +1,77 @@
+package signing
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Source provides the current keyring. Keys may change between calls when they are rotated, so callers should get
+// the keyring each time they sign or verify rather than keeping it
+type Source interface {
+	Keyring() (*Keyring, error)
+}
+
+// Directory reads the keyring from a directory holding a file for each key, named by its id, i.e. a mounted
+// Kubernetes Secret. The directory is read for every keyring, so that keys added or removed by a rotation are used as
+// soon as the kubelet updates the mount
+type Directory struct {
+	path string
+
+	lock    sync.Mutex
+	keyring *Keyring
+}
+
+func NewDirectory(path string) *Directory {
+	return &Directory{path: path}
+}
+
+func (d *Directory) Keyring() (*Keyring, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	keyring, err := d.read()
+	if err != nil {
+		if d.keyring == nil {
+			return nil, err
+		}
+		// keep signing with the last good keys, the mount may be in the middle of an update
+		logrus.Warnf("[signing] unable to read signing keys, using the previous keys: %v", err)
+		return d.keyring, nil
+	}
+	if d.keyring == nil || d.keyring.ActiveKeyID() != keyring.ActiveKeyID() {
+		logrus.Infof("[signing] signing with key %s, verifying with keys %s", keyring.ActiveKeyID(), strings.Join(keyring.KeyIDs(), ", "))
+	}
+	d.keyring = keyring
+	return keyring, nil
+}
+
+func (d *Directory) read() (*Keyring, error) {
+	entries, err := ioutil.ReadDir(d.path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read signing keys: %v", err)
+	}
+	keys := map[string][]byte{}
+	for _, entry := range entries {
+		// the kubelet keeps the secret's data in hidden directories (..data) the key files link to
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(d.path, entry.Name())
+		// stat rather than the entry, which describes the link
+		if info, err := os.Stat(path); err != nil || info.IsDir() {
+			continue
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read signing key %s: %v", entry.Name(), err)
+		}
+		// keys written with editors or echo usually end with a newline, which is never part of the key
+		keys[entry.Name()] = []byte(strings.TrimRight(string(data), "\r\n"))
+	}
+	return NewKeyring(keys)
+}
@@ -0,0 +1,149 @@
+// Package signing signs the artifacts produced by the adapter (attestations of true-up reports and the requests of the
+// audit webhook) with versioned keys. Keys are named v1, v2, ... and artifacts are always signed with the newest one,
+// while every key which is still configured verifies them. To rotate, a new key is added, and the previous one is
+// removed once nothing signed with it needs to be verified anymore
+package signing
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// keyPrefix precedes the version in the id of each key
+const keyPrefix = "v"
+
+var (
+	// ErrUnknownKey is returned when an artifact was signed with a key which isn't configured, i.e. because it was
+	// removed after a rotation
+	ErrUnknownKey = errors.New("artifact was signed with an unknown key")
+	// ErrInvalidSignature is returned when the signature of an artifact doesn't match its content
+	ErrInvalidSignature = errors.New("signature doesn't match the artifact, it was modified or signed with another key")
+)
+
+// Keyring holds the versioned keys artifacts are signed and verified with
+type Keyring struct {
+	keys   map[string][]byte
+	active string
+}
+
+// NewKeyring returns the keyring of keys, by id. Ids must be a version, like v1, and keys must not be empty. The key
+// with the highest version is the one artifacts are signed with
+func NewKeyring(keys map[string][]byte) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no signing keys are configured")
+	}
+	k := &Keyring{keys: map[string][]byte{}}
+	activeVersion := 0
+	for id, key := range keys {
+		version, ok := parseVersion(id)
+		if !ok {
+			return nil, fmt.Errorf("invalid signing key id %q, ids must be a version like v1", id)
+		}
+		if len(key) == 0 {
+			return nil, fmt.Errorf("signing key %s is empty", id)
+		}
+		k.keys[id] = key
+		if version > activeVersion {
+			activeVersion = version
+			k.active = id
+		}
+	}
+	return k, nil
+}
+
+// parseVersion returns the version of key id, false if it isn't a positive version
+func parseVersion(id string) (int, bool) {
+	if !strings.HasPrefix(id, keyPrefix) {
+		return 0, false
+	}
+	version, err := strconv.Atoi(strings.TrimPrefix(id, keyPrefix))
+	if err != nil || version <= 0 {
+		return 0, false
+	}
+	return version, true
+}
+
+// ActiveKeyID returns the id of the key artifacts are signed with
+func (k *Keyring) ActiveKeyID() string {
+	return k.active
+}
+
+// KeyIDs returns the ids of all keys which verify artifacts, in order
+func (k *Keyring) KeyIDs() []string {
+	ids := make([]string, 0, len(k.keys))
+	for id := range k.keys {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, _ := parseVersion(ids[i])
+		b, _ := parseVersion(ids[j])
+		return a < b
+	})
+	return ids
+}
+
+// Sign signs payload with the active key
+func (k *Keyring) Sign(payload []byte) Signature {
+	return Signature{
+		KeyID: k.active,
+		Value: hex.EncodeToString(mac(k.keys[k.active], payload)),
+	}
+}
+
+// Verify checks that sig is a signature of payload by any configured key
+func (k *Keyring) Verify(payload []byte, sig Signature) error {
+	key, ok := k.keys[sig.KeyID]
+	if !ok {
+		return fmt.Errorf("%w %q", ErrUnknownKey, sig.KeyID)
+	}
+	value, err := hex.DecodeString(sig.Value)
+	if err != nil || !hmac.Equal(value, mac(key, payload)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func mac(key, payload []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(payload)
+	return h.Sum(nil)
+}
+
+// Signature is the hmac-sha256 of an artifact and the id of the key which produced it
+type Signature struct {
+	KeyID string `json:"keyID"`
+	// Value is the hex encoded hmac
+	Value string `json:"signature"`
+}
+
+// String formats the signature as sent in http headers, i.e. keyId=v2,sha256=<hmac>
+func (s Signature) String() string {
+	return fmt.Sprintf("keyId=%s,sha256=%s", s.KeyID, s.Value)
+}
+
+// ParseSignature parses a signature formatted by Signature.String
+func ParseSignature(value string) (Signature, error) {
+	var sig Signature
+	for _, field := range strings.Split(value, ",") {
+		parts := strings.SplitN(strings.TrimSpace(field), "=", 2)
+		if len(parts) != 2 {
+			return Signature{}, fmt.Errorf("invalid signature %q", value)
+		}
+		switch parts[0] {
+		case "keyId":
+			sig.KeyID = parts[1]
+		case "sha256":
+			sig.Value = parts[1]
+		}
+	}
+	if sig.KeyID == "" || sig.Value == "" {
+		return Signature{}, fmt.Errorf("invalid signature %q, expected keyId=<id>,sha256=<hmac>", value)
+	}
+	return sig, nil
+}
@@ -0,0 +1,110 @@
+package signing
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewKeyring(t *testing.T) {
+	tests := []struct {
+		name     string
+		keys     map[string][]byte
+		expected string
+		wantErr  bool
+	}{
+		{name: "single key", keys: map[string][]byte{"v1": []byte("a")}, expected: "v1"},
+		{name: "newest key is active", keys: map[string][]byte{"v2": []byte("b"), "v10": []byte("c"), "v1": []byte("a")}, expected: "v10"},
+		{name: "no keys", wantErr: true},
+		{name: "unversioned id", keys: map[string][]byte{"current": []byte("a")}, wantErr: true},
+		{name: "zero version", keys: map[string][]byte{"v0": []byte("a")}, wantErr: true},
+		{name: "empty key", keys: map[string][]byte{"v1": nil}, wantErr: true},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			keyring, err := NewKeyring(test.keys)
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, keyring.ActiveKeyID())
+		})
+	}
+}
+
+func TestRotation(t *testing.T) {
+	before, err := NewKeyring(map[string][]byte{"v1": []byte("old")})
+	require.NoError(t, err)
+	during, err := NewKeyring(map[string][]byte{"v1": []byte("old"), "v2": []byte("new")})
+	require.NoError(t, err)
+	after, err := NewKeyring(map[string][]byte{"v2": []byte("new")})
+	require.NoError(t, err)
+
+	payload := []byte("report")
+	old := before.Sign(payload)
+	assert.Equal(t, "v1", old.KeyID)
+	assert.NoError(t, during.Verify(payload, old), "expected artifacts signed before the rotation to verify during it")
+	assert.True(t, errors.Is(after.Verify(payload, old), ErrUnknownKey), "expected the removed key not to verify")
+
+	signed := during.Sign(payload)
+	assert.Equal(t, "v2", signed.KeyID)
+	assert.NoError(t, after.Verify(payload, signed))
+	assert.Equal(t, ErrInvalidSignature, after.Verify([]byte("modified"), signed))
+
+	parsed, err := ParseSignature(signed.String())
+	assert.NoError(t, err)
+	assert.Equal(t, signed, parsed)
+	_, err = ParseSignature("sha256=abc")
+	assert.Error(t, err)
+}
+
+func TestAttestation(t *testing.T) {
+	keyring, err := NewKeyring(map[string][]byte{"v3": []byte("key")})
+	require.NoError(t, err)
+	content := []byte("hour,nodes\n")
+	attestation := Attest(keyring, "q1.csv", content, time.Date(2022, 4, 1, 0, 0, 0, 5, time.UTC))
+	assert.Equal(t, "v3", attestation.KeyID)
+	assert.NoError(t, VerifyAttestation(keyring, attestation, content))
+	assert.Error(t, VerifyAttestation(keyring, attestation, []byte("hour,nodes\n1,2\n")))
+	attestation.SignedAt = attestation.SignedAt.Add(time.Hour)
+	assert.Equal(t, ErrInvalidSignature, VerifyAttestation(keyring, attestation, content))
+}
+
+func TestDirectory(t *testing.T) {
+	dir := t.TempDir()
+	// laid out like a mounted secret, with the key files linking into the kubelet's data directory
+	data := filepath.Join(dir, "..data")
+	require.NoError(t, os.Mkdir(data, 0700))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(data, "v1"), []byte("old\n"), 0600))
+	require.NoError(t, os.Symlink(filepath.Join(data, "v1"), filepath.Join(dir, "v1")))
+
+	keys := NewDirectory(dir)
+	keyring, err := keys.Keyring()
+	require.NoError(t, err)
+	assert.Equal(t, "v1", keyring.ActiveKeyID())
+	signed := keyring.Sign([]byte("report"))
+	expected, _ := NewKeyring(map[string][]byte{"v1": []byte("old")})
+	assert.NoError(t, expected.Verify([]byte("report"), signed), "expected the trailing newline to be trimmed")
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "v2"), []byte("new"), 0600))
+	keyring, err = keys.Keyring()
+	require.NoError(t, err)
+	assert.Equal(t, "v2", keyring.ActiveKeyID(), "expected the added key to be used")
+
+	// an invalid update keeps the previous keys
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "latest"), []byte("x"), 0600))
+	keyring, err = keys.Keyring()
+	require.NoError(t, err)
+	assert.Equal(t, "v2", keyring.ActiveKeyID())
+
+	_, err = NewDirectory(filepath.Join(dir, "missing")).Keyring()
+	assert.Error(t, err)
+}
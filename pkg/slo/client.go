@@ -0,0 +1,91 @@
+package slo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	lm "github.com/aws/aws-sdk-go-v2/service/licensemanager"
+	"github.com/aws/aws-sdk-go-v2/service/licensemanager/types"
+	"github.com/rancher/csp-adapter/pkg/clients/aws"
+	"github.com/rancher/csp-adapter/pkg/metrics"
+)
+
+// Operations tracked against the objective
+const (
+	OperationGetLicense       = "GetLicense"
+	OperationCheckout         = "Checkout"
+	OperationCheckIn          = "CheckIn"
+	OperationExtend           = "Extend"
+	OperationAvailable        = "GetAvailableEntitlements"
+	OperationEntitlementUsage = "GetEntitlementUsage"
+)
+
+// client wraps an aws.Client, recording the outcome of its license operations with a tracker
+type client struct {
+	aws.Client
+	tracker *Tracker
+}
+
+// NewClient returns an aws.Client which records the outcome of the license operations of c with tracker. Health
+// probes and catalog reads aren't tracked, since they don't affect compliance
+func NewClient(c aws.Client, tracker *Tracker) aws.Client {
+	return &client{
+		Client:  c,
+		tracker: tracker,
+	}
+}
+
+// record tracks the outcome of operation. Operations cancelled because the adapter is stopping aren't counted
+func (c *client) record(operation string, err error) {
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	outcome := "success"
+	if err != nil {
+		outcome = "failure"
+	}
+	metrics.LicenseOperations.WithLabelValues(operation, outcome).Inc()
+	c.tracker.Record(err == nil, time.Now())
+}
+
+func (c *client) GetRancherLicense(ctx context.Context) (*types.GrantedLicense, error) {
+	res, err := c.Client.GetRancherLicense(ctx)
+	c.record(OperationGetLicense, err)
+	return res, err
+}
+
+func (c *client) CheckoutRancherLicense(ctx context.Context, l types.GrantedLicense, entitlementAmt int) (*lm.CheckoutLicenseOutput, error) {
+	res, err := c.Client.CheckoutRancherLicense(ctx, l, entitlementAmt)
+	c.record(OperationCheckout, err)
+	return res, err
+}
+
+func (c *client) CheckInRancherLicense(ctx context.Context, consumptionToken string) (*lm.CheckInLicenseOutput, error) {
+	res, err := c.Client.CheckInRancherLicense(ctx, consumptionToken)
+	c.record(OperationCheckIn, err)
+	return res, err
+}
+
+// CheckInRancherLicenses checks in each token with CheckInRancherLicense, so that the outcome of each is recorded
+func (c *client) CheckInRancherLicenses(ctx context.Context, tokens []string) map[string]error {
+	return aws.CheckInConcurrently(ctx, c, tokens, aws.DefaultCheckInParallelism)
+}
+
+func (c *client) ExtendRancherLicenseConsumptionToken(ctx context.Context, consumptionToken string) (*lm.ExtendLicenseConsumptionOutput, error) {
+	res, err := c.Client.ExtendRancherLicenseConsumptionToken(ctx, consumptionToken)
+	c.record(OperationExtend, err)
+	return res, err
+}
+
+func (c *client) GetNumberOfAvailableEntitlements(ctx context.Context, license types.GrantedLicense) (int, error) {
+	res, err := c.Client.GetNumberOfAvailableEntitlements(ctx, license)
+	c.record(OperationAvailable, err)
+	return res, err
+}
+
+func (c *client) GetEntitlementUsage(ctx context.Context, license types.GrantedLicense) (aws.EntitlementUsage, error) {
+	res, err := c.Client.GetEntitlementUsage(ctx, license)
+	c.record(OperationEntitlementUsage, err)
+	return res, err
+}
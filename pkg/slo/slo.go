@@ -0,0 +1,193 @@
+// Package slo tracks the success ratio of the adapter's License Manager operations against a service level objective,
+// so that the adapter can be alerted on by error budget burn rate like any other service
+package slo
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rancher/csp-adapter/pkg/metrics"
+	"github.com/rancher/csp-adapter/pkg/sdk"
+	"github.com/sirupsen/logrus"
+)
+
+// Options configures the objective which operations are tracked against
+type Options struct {
+	// Target is the ratio of operations which should succeed, i.e. 0.99
+	Target float64
+	// BudgetWindow is the rolling window the error budget is spent over
+	BudgetWindow time.Duration
+	// BurnWindows are the rolling windows burn rates are reported for, typically a short and a long window for
+	// multi-window burn rate alerts
+	BurnWindows []time.Duration
+}
+
+// DefaultOptions are the options used by the adapter
+var DefaultOptions = Options{
+	Target:       0.99,
+	BudgetWindow: 7 * 24 * time.Hour,
+	BurnWindows:  []time.Duration{5 * time.Minute, time.Hour, 6 * time.Hour},
+}
+
+// bucketSize is the resolution of the rolling windows
+const bucketSize = time.Minute
+
+type bucket struct {
+	start     time.Time
+	successes int
+	failures  int
+}
+
+// Tracker records the outcome of operations in per minute buckets covering the budget window
+type Tracker struct {
+	opts Options
+
+	lock      sync.Mutex
+	buckets   []bucket
+	exhausted bool
+}
+
+func NewTracker(opts Options) (*Tracker, error) {
+	if opts.Target <= 0 || opts.Target >= 1 {
+		return nil, fmt.Errorf("slo target must be between 0 and 1, got %v", opts.Target)
+	}
+	if opts.BudgetWindow < bucketSize {
+		return nil, fmt.Errorf("slo budget window must be at least %s, got %s", bucketSize, opts.BudgetWindow)
+	}
+	return &Tracker{opts: opts}, nil
+}
+
+// Record adds the outcome of an operation which finished at
+func (t *Tracker) Record(success bool, at time.Time) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	start := at.Truncate(bucketSize)
+	if n := len(t.buckets); n == 0 || t.buckets[n-1].start.Before(start) {
+		t.buckets = append(t.buckets, bucket{start: start})
+	}
+	// outcomes arrive in order, apart from concurrent operations finishing within the same minute
+	current := &t.buckets[len(t.buckets)-1]
+	if success {
+		current.successes++
+	} else {
+		current.failures++
+	}
+	t.prune(at)
+	exhausted := t.budgetRemaining(at) <= 0
+	if exhausted && !t.exhausted {
+		logrus.Warnf("[slo] error budget of License Manager operations is exhausted, less than %.2f%% succeeded within %s",
+			t.opts.Target*100, t.opts.BudgetWindow)
+	} else if !exhausted && t.exhausted {
+		logrus.Infof("[slo] error budget of License Manager operations is no longer exhausted")
+	}
+	t.exhausted = exhausted
+}
+
+// Status returns the success ratio, remaining error budget and burn rates at now
+func (t *Tracker) Status(now time.Time) sdk.SLOStatus {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	successes, failures := t.sum(now, t.opts.BudgetWindow)
+	status := sdk.SLOStatus{
+		Target:               t.opts.Target,
+		Window:               t.opts.BudgetWindow.String(),
+		Operations:           successes + failures,
+		SuccessRatio:         1,
+		ErrorBudgetRemaining: t.budgetRemaining(now),
+		BurnRates:            map[string]float64{},
+	}
+	if status.Operations > 0 {
+		status.SuccessRatio = float64(successes) / float64(status.Operations)
+	}
+	status.BudgetExhausted = status.ErrorBudgetRemaining <= 0
+	for _, window := range t.opts.BurnWindows {
+		status.BurnRates[window.String()] = t.burnRate(now, window)
+	}
+	return status
+}
+
+// burnRate is the rate the error budget is spent at within window, relative to spending it evenly over the budget
+// window. Must be called while holding the lock
+func (t *Tracker) burnRate(now time.Time, window time.Duration) float64 {
+	successes, failures := t.sum(now, window)
+	if successes+failures == 0 {
+		return 0
+	}
+	return float64(failures) / float64(successes+failures) / (1 - t.opts.Target)
+}
+
+// budgetRemaining is the ratio of the error budget of the budget window which isn't spent yet, negative once
+// overspent. Must be called while holding the lock
+func (t *Tracker) budgetRemaining(now time.Time) float64 {
+	successes, failures := t.sum(now, t.opts.BudgetWindow)
+	if successes+failures == 0 {
+		return 1
+	}
+	return 1 - float64(failures)/float64(successes+failures)/(1-t.opts.Target)
+}
+
+// sum returns the outcomes recorded within window before now. Must be called while holding the lock
+func (t *Tracker) sum(now time.Time, window time.Duration) (successes, failures int) {
+	from := now.Add(-window)
+	first := sort.Search(len(t.buckets), func(i int) bool {
+		return !t.buckets[i].start.Add(bucketSize).Before(from)
+	})
+	for _, b := range t.buckets[first:] {
+		if b.start.After(now) {
+			break
+		}
+		successes += b.successes
+		failures += b.failures
+	}
+	return successes, failures
+}
+
+// prune drops buckets which are older than the budget window. Must be called while holding the lock
+func (t *Tracker) prune(now time.Time) {
+	from := now.Add(-t.opts.BudgetWindow)
+	drop := 0
+	for drop < len(t.buckets) && t.buckets[drop].start.Add(bucketSize).Before(from) {
+		drop++
+	}
+	t.buckets = t.buckets[drop:]
+	// the budget window bounds the buckets, which age out rather than being dropped
+	metrics.RetainedItems.WithLabelValues("slo_buckets").Set(float64(len(t.buckets)))
+}
+
+var (
+	burnRateDesc = prometheus.NewDesc(
+		"csp_adapter_slo_burn_rate",
+		"Rate the error budget of License Manager operations is spent at within the window, 1 spends it exactly over the budget window",
+		[]string{"window"}, nil,
+	)
+	budgetRemainingDesc = prometheus.NewDesc(
+		"csp_adapter_slo_error_budget_remaining",
+		"Ratio of the error budget of License Manager operations which isn't spent, negative once overspent",
+		nil, nil,
+	)
+	successRatioDesc = prometheus.NewDesc(
+		"csp_adapter_slo_success_ratio",
+		"Ratio of License Manager operations which succeeded within the budget window",
+		nil, nil,
+	)
+)
+
+// Describe implements prometheus.Collector, so that burn rates are computed when metrics are scraped
+func (t *Tracker) Describe(ch chan<- *prometheus.Desc) {
+	ch <- burnRateDesc
+	ch <- budgetRemainingDesc
+	ch <- successRatioDesc
+}
+
+// Collect implements prometheus.Collector
+func (t *Tracker) Collect(ch chan<- prometheus.Metric) {
+	status := t.Status(time.Now())
+	for window, rate := range status.BurnRates {
+		ch <- prometheus.MustNewConstMetric(burnRateDesc, prometheus.GaugeValue, rate, window)
+	}
+	ch <- prometheus.MustNewConstMetric(budgetRemainingDesc, prometheus.GaugeValue, status.ErrorBudgetRemaining)
+	ch <- prometheus.MustNewConstMetric(successRatioDesc, prometheus.GaugeValue, status.SuccessRatio)
+}
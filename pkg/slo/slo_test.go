@@ -0,0 +1,77 @@
+package slo
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTracker(t *testing.T) {
+	tracker, err := NewTracker(Options{
+		Target:       0.9,
+		BudgetWindow: 24 * time.Hour,
+		BurnWindows:  []time.Duration{time.Hour},
+	})
+	require.NoError(t, err)
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, 1.0, tracker.Status(start).ErrorBudgetRemaining, "no operations spend no budget")
+
+	// 2 failures in 40 operations over the first 20 hours is half the budget
+	for i := 0; i < 40; i++ {
+		tracker.Record(i%20 != 0, start.Add(time.Duration(i)*30*time.Minute))
+	}
+	now := start.Add(20 * time.Hour)
+	status := tracker.Status(now)
+	assert.Equal(t, 40, status.Operations)
+	assert.InDelta(t, 0.95, status.SuccessRatio, 0.001)
+	assert.InDelta(t, 0.5, status.ErrorBudgetRemaining, 0.001)
+	assert.False(t, status.BudgetExhausted)
+	assert.Equal(t, 0.0, status.BurnRates["1h0m0s"])
+
+	// an outage burns through the rest of the budget
+	for i := 0; i < 10; i++ {
+		tracker.Record(false, now.Add(time.Duration(i)*time.Minute))
+	}
+	status = tracker.Status(now.Add(40 * time.Minute))
+	assert.True(t, status.BudgetExhausted)
+	assert.True(t, status.ErrorBudgetRemaining < 0)
+	assert.InDelta(t, 10.0, status.BurnRates["1h0m0s"], 0.001, "every operation within the last hour failed")
+
+	// the outage leaves the budget window after a day
+	status = tracker.Status(now.Add(25 * time.Hour))
+	assert.False(t, status.BudgetExhausted)
+	assert.Equal(t, 0, status.Operations)
+}
+
+func TestNewTrackerValidation(t *testing.T) {
+	_, err := NewTracker(Options{Target: 1, BudgetWindow: time.Hour})
+	assert.Error(t, err)
+	_, err = NewTracker(Options{Target: 0.99, BudgetWindow: time.Second})
+	assert.Error(t, err)
+}
+
+func TestClient(t *testing.T) {
+	tracker, err := NewTracker(DefaultOptions)
+	require.NoError(t, err)
+	c := NewClient(mocks.NewMockAWSClient(5), tracker)
+	ctx := context.Background()
+	license, err := c.GetRancherLicense(ctx)
+	require.NoError(t, err)
+	_, err = c.CheckoutRancherLicense(ctx, *license, 3)
+	require.NoError(t, err)
+	_, err = c.CheckInRancherLicense(ctx, "unknown-token")
+	require.Error(t, err)
+
+	// operations cancelled because the adapter is stopping aren't counted
+	c.(*client).record(OperationGetLicense, fmt.Errorf("unable to get license: %w", context.Canceled))
+
+	status := tracker.Status(time.Now())
+	assert.Equal(t, 3, status.Operations)
+	assert.InDelta(t, 2.0/3, status.SuccessRatio, 0.001)
+}
@@ -0,0 +1,109 @@
+package state
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	fileFormat = "csp-adapter-state"
+	kdfScrypt  = "scrypt"
+	saltSize   = 16
+	keySize    = 32
+	// scrypt parameters recommended for interactive use, so that exports and imports take well under a second
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+// envelope is the exported file. The state is serialized as json with sorted keys, so that exports of the same state
+// only differ in their salt and nonce
+type envelope struct {
+	Format     string `json:"format"`
+	KDF        string `json:"kdf"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// ErrDecrypt is returned by Read if the passphrase doesn't match the one the file was written with, or the file was
+// modified
+var ErrDecrypt = errors.New("unable to decrypt state, the passphrase is wrong or the file was modified")
+
+// Write encrypts state with a key derived from passphrase and writes it to w
+func Write(w io.Writer, state State, passphrase string) error {
+	if passphrase == "" {
+		return errors.New("a passphrase is required to encrypt the state")
+	}
+	plaintext, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	aead, err := newAEAD(passphrase, salt)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(envelope{
+		Format:     fileFormat,
+		KDF:        kdfScrypt,
+		Salt:       salt,
+		Nonce:      nonce,
+		Ciphertext: aead.Seal(nil, nonce, plaintext, []byte(fileFormat)),
+	})
+}
+
+// Read decrypts the state written by Write from r
+func Read(r io.Reader, passphrase string) (State, error) {
+	var file envelope
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return State{}, fmt.Errorf("unable to read state file: %w", err)
+	}
+	if file.Format != fileFormat || file.KDF != kdfScrypt {
+		return State{}, fmt.Errorf("not a state file exported by the adapter")
+	}
+	aead, err := newAEAD(passphrase, file.Salt)
+	if err != nil {
+		return State{}, err
+	}
+	if len(file.Nonce) != aead.NonceSize() {
+		return State{}, ErrDecrypt
+	}
+	plaintext, err := aead.Open(nil, file.Nonce, file.Ciphertext, []byte(fileFormat))
+	if err != nil {
+		return State{}, ErrDecrypt
+	}
+	var state State
+	if err := json.Unmarshal(plaintext, &state); err != nil {
+		return State{}, fmt.Errorf("unable to parse state: %w", err)
+	}
+	return state, nil
+}
+
+func newAEAD(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, keySize)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
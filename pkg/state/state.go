@@ -0,0 +1,110 @@
+// Package state exports and imports the state persisted by the adapter, so that an install can be migrated to another
+// cluster or its state captured for a bug report. Exports are encrypted with a passphrase since the state holds the
+// consumption tokens of the checkout
+package state
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	apierror "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// Version is the version of the exported state, incremented whenever State changes incompatibly
+const Version = 1
+
+// State is all state persisted by the adapter. Outputs which are rewritten by every compliance check, like the output
+// configmap and notification, aren't part of it
+type State struct {
+	Version int `json:"version"`
+	// Cache is the data of the cache secret: the consumption tokens and checkout of the adapter
+	Cache map[string]string `json:"cache"`
+	// UsageHistory maps months to the usage history recorded in them
+	UsageHistory map[string]string `json:"usageHistory"`
+}
+
+// clusterKeys are the keys of the cache which identify the cluster and the adapter instance which wrote it. They're
+// cleared on import, so that the adapter of the target cluster adopts the checkout instead of discarding it as state
+// restored from a backup of another cluster, or backing off from the source's instance
+var clusterKeys = []string{"clusterUID", "instanceID", "instanceHeartbeat"}
+
+// Store is where the adapter persists its state. k8s.Clients implements it
+type Store interface {
+	GetConsumptionTokenSecret() (*corev1.Secret, error)
+	UpdateConsumptionTokenSecret(data map[string]string) error
+	ListUsageHistory() ([]string, error)
+	GetUsageHistory(month string) ([]byte, error)
+	UpdateUsageHistory(month string, data []byte) error
+}
+
+// Export reads the state persisted in store
+func Export(store Store) (State, error) {
+	state := State{Version: Version, Cache: map[string]string{}, UsageHistory: map[string]string{}}
+	secret, err := store.GetConsumptionTokenSecret()
+	if err != nil && !apierror.IsNotFound(err) {
+		return State{}, fmt.Errorf("unable to read cache secret: %w", err)
+	}
+	if secret != nil {
+		for key, value := range secret.Data {
+			state.Cache[key] = string(value)
+		}
+	}
+	months, err := store.ListUsageHistory()
+	if err != nil {
+		return State{}, fmt.Errorf("unable to list usage history: %w", err)
+	}
+	for _, month := range months {
+		data, err := store.GetUsageHistory(month)
+		if err != nil {
+			return State{}, fmt.Errorf("unable to read usage history of %s: %w", month, err)
+		}
+		state.UsageHistory[month] = string(data)
+	}
+	return state, nil
+}
+
+// ErrStateExists is returned by Import if store already holds a checkout, which the import would replace
+var ErrStateExists = errors.New("the cluster already holds adapter state")
+
+// Import writes state to store. Unless force is set, it refuses to replace a checkout already held in store, which would
+// leave the licenses of that checkout consumed until their tokens expire. Usage history of the same months is replaced,
+// other months are kept. The checkout is adopted by the cluster it's imported into
+func Import(store Store, state State, force bool) error {
+	if state.Version != Version {
+		return fmt.Errorf("state was exported by an adapter with state version %d, this adapter reads version %d", state.Version, Version)
+	}
+	if !force {
+		secret, err := store.GetConsumptionTokenSecret()
+		if err != nil && !apierror.IsNotFound(err) {
+			return fmt.Errorf("unable to read cache secret: %w", err)
+		}
+		if err == nil && len(secret.Data) > 0 {
+			return ErrStateExists
+		}
+	}
+	months := make([]string, 0, len(state.UsageHistory))
+	for month := range state.UsageHistory {
+		months = append(months, month)
+	}
+	sort.Strings(months)
+	for _, month := range months {
+		if err := store.UpdateUsageHistory(month, []byte(state.UsageHistory[month])); err != nil {
+			return fmt.Errorf("unable to write usage history of %s: %w", month, err)
+		}
+	}
+	cache := make(map[string]string, len(state.Cache)+len(clusterKeys))
+	for key, value := range state.Cache {
+		cache[key] = value
+	}
+	for _, key := range clusterKeys {
+		// written empty rather than left out, since the cache secret keeps the keys of the target which aren't written
+		cache[key] = ""
+	}
+	// written last, so that the adapter doesn't pick up the checkout before its history is in place
+	if err := store.UpdateConsumptionTokenSecret(cache); err != nil {
+		return fmt.Errorf("unable to write cache secret: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package state
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierror "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// memoryStore holds state like the adapter's cluster would
+type memoryStore struct {
+	cache   map[string]string
+	history map[string]string
+}
+
+func (s *memoryStore) GetConsumptionTokenSecret() (*corev1.Secret, error) {
+	if s.cache == nil {
+		return nil, apierror.NewNotFound(schema.GroupResource{Resource: "secrets"}, "csp-adapter-cache")
+	}
+	secret := &corev1.Secret{Data: map[string][]byte{}}
+	for key, value := range s.cache {
+		secret.Data[key] = []byte(value)
+	}
+	return secret, nil
+}
+
+func (s *memoryStore) UpdateConsumptionTokenSecret(data map[string]string) error {
+	s.cache = data
+	return nil
+}
+
+func (s *memoryStore) ListUsageHistory() ([]string, error) {
+	var months []string
+	for month := range s.history {
+		months = append(months, month)
+	}
+	return months, nil
+}
+
+func (s *memoryStore) GetUsageHistory(month string) ([]byte, error) {
+	return []byte(s.history[month]), nil
+}
+
+func (s *memoryStore) UpdateUsageHistory(month string, data []byte) error {
+	if s.history == nil {
+		s.history = map[string]string{}
+	}
+	s.history[month] = string(data)
+	return nil
+}
+
+func TestExportImport(t *testing.T) {
+	source := &memoryStore{
+		cache:   map[string]string{"consumptionToken": "token", "entitlementCount": "3", "clusterUID": "source"},
+		history: map[string]string{"2026-09": "september", "2026-10": "october"},
+	}
+	exported, err := Export(source)
+	require.NoError(t, err)
+
+	var file bytes.Buffer
+	require.NoError(t, Write(&file, exported, "passphrase"))
+	assert.NotContains(t, file.String(), "token", "the state should be encrypted")
+
+	_, err = Read(bytes.NewReader(file.Bytes()), "wrong")
+	assert.ErrorIs(t, err, ErrDecrypt)
+	read, err := Read(bytes.NewReader(file.Bytes()), "passphrase")
+	require.NoError(t, err)
+
+	target := &memoryStore{history: map[string]string{"2026-08": "august"}}
+	require.NoError(t, Import(target, read, false))
+	assert.Equal(t, map[string]string{
+		"consumptionToken":  "token",
+		"entitlementCount":  "3",
+		"clusterUID":        "",
+		"instanceID":        "",
+		"instanceHeartbeat": "",
+	}, target.cache, "the checkout should be adopted by the target cluster")
+	assert.Equal(t, map[string]string{"2026-08": "august", "2026-09": "september", "2026-10": "october"}, target.history,
+		"history of other months should be kept")
+
+	assert.ErrorIs(t, Import(target, read, false), ErrStateExists, "a held checkout shouldn't be replaced")
+	assert.NoError(t, Import(target, read, true))
+}
+
+func TestExportEmpty(t *testing.T) {
+	exported, err := Export(&memoryStore{})
+	require.NoError(t, err)
+	assert.Equal(t, State{Version: Version, Cache: map[string]string{}, UsageHistory: map[string]string{}}, exported)
+
+	exported.Version = Version + 1
+	assert.Error(t, Import(&memoryStore{}, exported, false), "state of another version should be refused")
+}
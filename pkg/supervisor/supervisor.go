@@ -0,0 +1,71 @@
+// Package supervisor runs the adapter's subsystems in isolated goroutines, restarting a subsystem which panics so that a
+// failure in one of them (i.e. an exporter) can't take down the others, above all the renewal of checked out licenses
+package supervisor
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/metrics"
+	"github.com/sirupsen/logrus"
+)
+
+// Backoff is how long a subsystem which panicked waits before it's restarted
+type Backoff struct {
+	// Initial is the wait after the first panic, doubled by every consecutive one
+	Initial time.Duration
+	// Max caps the wait. A subsystem which ran for longer than Max before panicking waits Initial again
+	Max time.Duration
+}
+
+// DefaultBackoff is the backoff used by the adapter
+var DefaultBackoff = Backoff{
+	Initial: time.Second,
+	Max:     time.Minute,
+}
+
+// Go runs fn in a new goroutine supervised with DefaultBackoff
+func Go(ctx context.Context, name string, fn func(ctx context.Context)) {
+	go Run(ctx, name, fn, DefaultBackoff)
+}
+
+// Run runs fn until it returns or ctx is cancelled. If fn panics, the panic is logged with its stack and fn is restarted
+// after backoff, so that the panic is contained to the subsystem name
+func Run(ctx context.Context, name string, fn func(ctx context.Context), backoff Backoff) {
+	wait := backoff.Initial
+	for {
+		started := time.Now()
+		err := runRecovered(ctx, fn)
+		if err == nil || ctx.Err() != nil {
+			return
+		}
+		metrics.SubsystemPanics.WithLabelValues(name).Inc()
+		if time.Since(started) > backoff.Max {
+			// the subsystem was healthy for a while, this isn't a crash loop
+			wait = backoff.Initial
+		}
+		logrus.Errorf("[supervisor] %s panicked, restarting in %s: %v", name, wait, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		wait *= 2
+		if wait > backoff.Max {
+			wait = backoff.Max
+		}
+	}
+}
+
+// runRecovered runs fn, returning the panic it recovered from as an error with its stack. Nil if fn returned
+func runRecovered(ctx context.Context, fn func(ctx context.Context)) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v\n%s", r, debug.Stack())
+		}
+	}()
+	fn(ctx)
+	return nil
+}
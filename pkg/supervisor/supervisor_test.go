@@ -0,0 +1,57 @@
+package supervisor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rancher/csp-adapter/pkg/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name string
+		// panics is how many times the subsystem panics before it returns
+		panics int
+	}{
+		{name: "returns", panics: 0},
+		{name: "restarted after panics", panics: 3},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			subsystem := "test " + test.name
+			runs := 0
+			Run(context.Background(), subsystem, func(ctx context.Context) {
+				runs++
+				if runs <= test.panics {
+					panic("boom")
+				}
+			}, Backoff{Initial: time.Millisecond, Max: 4 * time.Millisecond})
+			assert.Equal(t, test.panics+1, runs)
+			assert.Equal(t, float64(test.panics), testutil.ToFloat64(metrics.SubsystemPanics.WithLabelValues(subsystem)))
+		})
+	}
+}
+
+func TestRunStopsWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	runs := 0
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		Run(ctx, "test cancelled", func(ctx context.Context) {
+			runs++
+			cancel()
+			panic("boom")
+		}, Backoff{Initial: time.Hour, Max: time.Hour})
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("supervisor didn't stop with its context")
+	}
+	assert.Equal(t, 1, runs)
+}
@@ -0,0 +1,104 @@
+package usage
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+	"time"
+)
+
+// MaxBackfill is the longest gap in the history which is backfilled. Older history is left as is, since estimates
+// over longer periods say little about actual usage
+const MaxBackfill = 31 * 24 * time.Hour
+
+// Backfill estimates the usage of the hours between the last recorded hour and the hour of now, which weren't
+// observed because the adapter wasn't running. Estimated records are flagged as Estimated, so that reports can tell
+// them apart from observed usage. nodeCreations are the registration times of the nodes which currently exist:
+// nodes are counted from the hour they were registered in, while nodes which existed in the last recorded hour but
+// have since been removed are assumed to have been removed evenly over the gap. requiredLicenses converts a node
+// count to the licenses it requires. Returns the number of backfilled hours. Must be called before the first Observe
+// of the recorder
+func (r *Recorder) Backfill(nodeCreations []time.Time, requiredLicenses func(nodes int) int, now time.Time) (int, error) {
+	current := now.UTC().Truncate(time.Hour)
+	records, err := Load(r.store, current.Add(-MaxBackfill), current)
+	if err != nil || len(records) == 0 {
+		// nothing was recorded before, i.e. a fresh install, so there is no gap
+		return 0, err
+	}
+	last := records[len(records)-1]
+	if last.Hours > 0 {
+		// the last recorded hours were already compacted, so the gap is longer than the hourly retention
+		return 0, nil
+	}
+	gap := int(current.Sub(last.Hour) / time.Hour)
+	if gap <= 1 {
+		return 0, nil
+	}
+
+	sort.Slice(nodeCreations, func(i, j int) bool {
+		return nodeCreations[i].Before(nodeCreations[j])
+	})
+	// nodes registered by the end of hour, among those which still exist
+	existingBy := func(hour time.Time) int {
+		return sort.Search(len(nodeCreations), func(i int) bool {
+			return !nodeCreations[i].Before(hour.Add(time.Hour))
+		})
+	}
+	removed := math.Max(0, last.AverageNodes()-float64(existingBy(last.Hour)))
+	// estimated hours weigh as much as the last observed hour in averages, which are weighted by samples
+	samples := last.Samples
+	if samples < 1 {
+		samples = 1
+	}
+
+	var estimated []HourlyUsage
+	for i := 1; i < gap; i++ {
+		hour := last.Hour.Add(time.Duration(i) * time.Hour)
+		remaining := removed * (1 - float64(i)/float64(gap))
+		nodes := existingBy(hour) + int(math.Round(remaining))
+		estimated = append(estimated, HourlyUsage{
+			Hour:                 hour,
+			Samples:              samples,
+			PeakNodes:            nodes,
+			NodeSum:              nodes * samples,
+			PeakRequiredLicenses: requiredLicenses(nodes),
+			Estimated:            true,
+		})
+	}
+	return len(estimated), r.add(estimated)
+}
+
+// add adds records to the stored history, keeping observed records of the same hour
+func (r *Recorder) add(records []HourlyUsage) error {
+	byMonth := map[string][]HourlyUsage{}
+	for _, record := range records {
+		month := monthOf(record.Hour)
+		byMonth[month] = append(byMonth[month], record)
+	}
+	for month, added := range byMonth {
+		existing, err := readMonth(r.store, month)
+		if err != nil {
+			return err
+		}
+		observed := map[time.Time]bool{}
+		for _, record := range existing {
+			observed[record.Hour] = true
+		}
+		for _, record := range added {
+			if !observed[record.Hour] {
+				existing = append(existing, record)
+			}
+		}
+		sort.Slice(existing, func(i, j int) bool {
+			return existing[i].Hour.Before(existing[j].Hour)
+		})
+		data, err := json.Marshal(existing)
+		if err != nil {
+			return err
+		}
+		if err := r.store.UpdateUsageHistory(month, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package usage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackfill(t *testing.T) {
+	store := mocks.NewMockK8sClient(nil)
+	lastHour := time.Date(2022, 1, 31, 20, 0, 0, 0, time.UTC)
+	require.NoError(t, NewRecorder(store, Retention{}).Observe(10, 0, 1, true, lastHour.Add(30*time.Minute)))
+
+	// 6 of the 10 nodes still exist, 4 were removed while the adapter was down. 2 nodes were added 2 hours later
+	var created []time.Time
+	for i := 0; i < 6; i++ {
+		created = append(created, lastHour.Add(-24*time.Hour))
+	}
+	created = append(created, lastHour.Add(2*time.Hour+10*time.Minute), lastHour.Add(2*time.Hour+20*time.Minute))
+
+	// back 5 hours later, in the next month
+	now := lastHour.Add(5*time.Hour + 15*time.Minute)
+	recorder := NewRecorder(store, Retention{})
+	backfilled, err := recorder.Backfill(created, func(nodes int) int { return nodes/5 + 1 }, now)
+	require.NoError(t, err)
+	assert.Equal(t, 4, backfilled)
+	require.NoError(t, recorder.Observe(8, 0, 2, true, now))
+
+	records, err := Load(store, lastHour, now)
+	require.NoError(t, err)
+	require.Len(t, records, 6)
+	assert.False(t, records[0].Estimated)
+	var nodes []int
+	for _, record := range records[1:5] {
+		assert.True(t, record.Estimated)
+		nodes = append(nodes, record.PeakNodes)
+	}
+	assert.Equal(t, []int{9, 10, 10, 9}, nodes, "removed nodes should be spread over the gap, added nodes counted from their hour")
+	assert.Equal(t, 3, records[2].PeakRequiredLicenses)
+	assert.False(t, records[5].Estimated)
+
+	report := NewReport(lastHour, now, records)
+	assert.Equal(t, 4, report.HoursEstimated)
+
+	backfilled, err = NewRecorder(store, Retention{}).Backfill(created, func(int) int { return 1 }, now)
+	require.NoError(t, err)
+	assert.Zero(t, backfilled, "a recorder restarted within the hour has no gap to backfill")
+}
+
+func TestBackfillFreshInstall(t *testing.T) {
+	backfilled, err := NewRecorder(mocks.NewMockK8sClient(nil), Retention{}).Backfill(nil, func(int) int { return 1 }, time.Now())
+	require.NoError(t, err)
+	assert.Zero(t, backfilled)
+}
@@ -0,0 +1,124 @@
+package usage
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Retention configures how long the usage history is kept at each resolution. Observations are aggregated into hourly
+// records as they are made, hourly records are compacted into daily records once they are older than Hourly, and
+// daily records are deleted once they are older than Daily
+type Retention struct {
+	Hourly time.Duration
+	Daily  time.Duration
+}
+
+// DefaultRetention keeps hourly records for the true-up reviews of the last quarter, and daily records for long-term
+// trends
+var DefaultRetention = Retention{
+	Hourly: 90 * 24 * time.Hour,
+	Daily:  2 * 365 * 24 * time.Hour,
+}
+
+// compactionInterval is how often the history is compacted, since records are only compacted a day at a time
+const compactionInterval = 24 * time.Hour
+
+// Compact compacts the hourly records older than the hourly retention into daily records and deletes the records
+// older than the daily retention, so that the history stays small enough for etcd while long-term trends are kept.
+// Does nothing if the history was compacted within the last day
+func (r *Recorder) Compact(now time.Time) error {
+	if !r.lastCompaction.IsZero() && now.Sub(r.lastCompaction) < compactionInterval {
+		return nil
+	}
+	months, err := r.store.ListUsageHistory()
+	if err != nil {
+		return fmt.Errorf("unable to list usage history: %w", err)
+	}
+	hourlyCutoff := now.UTC().Add(-r.retention.Hourly).Truncate(24 * time.Hour)
+	dailyCutoff := now.UTC().Add(-r.retention.Daily).Truncate(24 * time.Hour)
+	for _, month := range months {
+		start, err := time.Parse("2006-01", month)
+		if err != nil {
+			continue
+		}
+		if !start.AddDate(0, 1, 0).After(dailyCutoff) {
+			if err := r.store.DeleteUsageHistory(month); err != nil {
+				return fmt.Errorf("unable to delete usage history for %s: %w", month, err)
+			}
+			continue
+		}
+		if !start.Before(hourlyCutoff) {
+			continue
+		}
+		records, err := readMonth(r.store, month)
+		if err != nil {
+			return err
+		}
+		compacted, changed := compact(records, hourlyCutoff, dailyCutoff)
+		if !changed {
+			continue
+		}
+		data, err := json.Marshal(compacted)
+		if err != nil {
+			return err
+		}
+		if err := r.store.UpdateUsageHistory(month, data); err != nil {
+			return err
+		}
+	}
+	r.lastCompaction = now
+	return nil
+}
+
+// compact rolls the hourly records before hourlyCutoff up into daily records, and drops the records before
+// dailyCutoff. records must be sorted. Returns whether any records were compacted or dropped
+func compact(records []HourlyUsage, hourlyCutoff, dailyCutoff time.Time) ([]HourlyUsage, bool) {
+	var compacted []HourlyUsage
+	// days holds the index of each day's record in compacted
+	days := map[time.Time]int{}
+	changed := false
+	for _, record := range records {
+		if record.Hour.Before(dailyCutoff) {
+			changed = true
+			continue
+		}
+		if record.Hours > 0 {
+			days[record.Hour] = len(compacted)
+			compacted = append(compacted, record)
+			continue
+		}
+		if !record.Hour.Before(hourlyCutoff) {
+			compacted = append(compacted, record)
+			continue
+		}
+		day := record.Hour.Truncate(24 * time.Hour)
+		i, ok := days[day]
+		if !ok {
+			i = len(compacted)
+			days[day] = i
+			compacted = append(compacted, HourlyUsage{Hour: day})
+		}
+		compacted[i].add(record)
+		changed = true
+	}
+	return compacted, changed
+}
+
+// add aggregates record into h
+func (h *HourlyUsage) add(record HourlyUsage) {
+	h.Hours += record.HoursCovered()
+	h.EstimatedHours += record.HoursEstimated()
+	h.Samples += record.Samples
+	h.NodeSum += record.NodeSum
+	h.NonCompliantSamples += record.NonCompliantSamples
+	if record.PeakNodes > h.PeakNodes {
+		h.PeakNodes = record.PeakNodes
+	}
+	if record.PeakRequiredLicenses > h.PeakRequiredLicenses {
+		h.PeakRequiredLicenses = record.PeakRequiredLicenses
+	}
+	if record.PeakNonProductionNodes > h.PeakNonProductionNodes {
+		h.PeakNonProductionNodes = record.PeakNonProductionNodes
+	}
+}
@@ -0,0 +1,73 @@
+package usage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompact(t *testing.T) {
+	store := mocks.NewMockK8sClient(nil)
+	recorder := NewRecorder(store, Retention{Hourly: 30 * 24 * time.Hour, Daily: 90 * 24 * time.Hour})
+	hour := func(month time.Month, day, hour int) time.Time {
+		return time.Date(2022, month, day, hour, 0, 0, 0, time.UTC)
+	}
+	records := []HourlyUsage{
+		// older than the daily retention
+		{Hour: hour(2, 20, 10), Samples: 120, PeakNodes: 10, NodeSum: 1200, PeakRequiredLicenses: 1},
+		{Hour: hour(3, 10, 10), Samples: 120, PeakNodes: 10, NodeSum: 1200, PeakRequiredLicenses: 1},
+		// older than the hourly retention
+		{Hour: hour(3, 20, 10), Samples: 120, PeakNodes: 30, NodeSum: 2400, PeakRequiredLicenses: 2, NonCompliantSamples: 60},
+		{Hour: hour(3, 20, 11), Samples: 120, PeakNodes: 50, NodeSum: 4800, PeakRequiredLicenses: 3, Estimated: true},
+		{Hour: hour(5, 15, 23), Samples: 120, PeakNodes: 20, NodeSum: 2400, PeakRequiredLicenses: 1},
+		// within the hourly retention
+		{Hour: hour(5, 20, 10), Samples: 120, PeakNodes: 20, NodeSum: 2400, PeakRequiredLicenses: 1},
+		{Hour: hour(6, 14, 10), Samples: 120, PeakNodes: 20, NodeSum: 2400, PeakRequiredLicenses: 1},
+	}
+	require.NoError(t, recorder.add(records))
+	now := hour(6, 15, 12)
+	before, err := Load(store, hour(3, 17, 0), now)
+	require.NoError(t, err)
+
+	require.NoError(t, recorder.Compact(now))
+	assert.NotContains(t, store.UsageHistory, "2022-02")
+	compacted, err := Load(store, hour(1, 1, 0), now)
+	require.NoError(t, err)
+	require.Len(t, compacted, 4)
+	assert.Equal(t, HourlyUsage{
+		Hour:                 hour(3, 20, 0),
+		Hours:                2,
+		Samples:              240,
+		PeakNodes:            50,
+		NodeSum:              7200,
+		PeakRequiredLicenses: 3,
+		NonCompliantSamples:  60,
+		EstimatedHours:       1,
+	}, compacted[0])
+	assert.Equal(t, hour(5, 15, 0), compacted[1].Hour)
+	assert.Equal(t, records[5:], compacted[2:])
+
+	// compaction keeps the summary of the period it retains
+	expected, actual := NewReport(hour(3, 17, 0), now, before), NewReport(hour(3, 17, 0), now, compacted)
+	assert.Equal(t, expected.HoursObserved, actual.HoursObserved)
+	assert.Equal(t, expected.HoursEstimated, actual.HoursEstimated)
+	assert.Equal(t, expected.PeakNodes, actual.PeakNodes)
+	assert.Equal(t, expected.AverageNodes, actual.AverageNodes)
+	assert.Equal(t, expected.HoursOutOfCompliance, actual.HoursOutOfCompliance)
+
+	// hours added to a compacted day are compacted into its record a day later
+	require.NoError(t, recorder.add([]HourlyUsage{{Hour: hour(3, 20, 12), Samples: 120, PeakNodes: 60, NodeSum: 7200, PeakRequiredLicenses: 3}}))
+	require.NoError(t, recorder.Compact(now.Add(time.Hour)))
+	compacted, err = Load(store, hour(3, 20, 0), hour(3, 21, 0))
+	require.NoError(t, err)
+	assert.Len(t, compacted, 2, "the history should be compacted at most once a day")
+	require.NoError(t, recorder.Compact(now.Add(24*time.Hour)))
+	compacted, err = Load(store, hour(3, 20, 0), hour(3, 21, 0))
+	require.NoError(t, err)
+	require.Len(t, compacted, 1)
+	assert.Equal(t, 3, compacted[0].Hours)
+	assert.Equal(t, 60, compacted[0].PeakNodes)
+}
@@ -0,0 +1,227 @@
+// Package usage persists the usage observed by compliance checks as hourly records, which are compacted into daily
+// records as they age, and produces true-up reports from them for license reviews
+package usage
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Store persists the records of a month, keyed by the month in the form 2006-01. Get returns nil data if no records
+// were stored for the month
+type Store interface {
+	GetUsageHistory(month string) ([]byte, error)
+	UpdateUsageHistory(month string, data []byte) error
+	// ListUsageHistory returns the months records are stored for
+	ListUsageHistory() ([]string, error)
+	DeleteUsageHistory(month string) error
+}
+
+// HourlyUsage aggregates the observations made within an hour, or within a day once the history was compacted
+type HourlyUsage struct {
+	// Hour is the start of the hour, or of the day for compacted records
+	Hour time.Time `json:"hour"`
+	// Hours is the number of hourly records compacted into this one, 0 for an hourly record
+	Hours int `json:"hours,omitempty"`
+	// Samples is the number of observations in the hour
+	Samples   int `json:"samples"`
+	PeakNodes int `json:"peakNodes"`
+	// NodeSum is the sum of the node counts of all samples, used to average them
+	NodeSum              int `json:"nodeSum"`
+	PeakRequiredLicenses int `json:"peakRequiredLicenses"`
+	// PeakNonProductionNodes is the largest number of nodes of clusters classified as non-production, which may be
+	// licensed at a lower ratio than PeakNodes suggests
+	PeakNonProductionNodes int `json:"peakNonProductionNodes,omitempty"`
+	// NonCompliantSamples is the number of samples in which fewer licenses were held than required
+	NonCompliantSamples int `json:"nonCompliantSamples"`
+	// Estimated is true if the hour wasn't observed but backfilled after the adapter was down
+	Estimated bool `json:"estimated,omitempty"`
+	// EstimatedHours is the number of estimated hourly records compacted into this one
+	EstimatedHours int `json:"estimatedHours,omitempty"`
+}
+
+// HoursCovered returns the number of hours the record aggregates
+func (h HourlyUsage) HoursCovered() int {
+	if h.Hours == 0 {
+		return 1
+	}
+	return h.Hours
+}
+
+// HoursEstimated returns the number of hours aggregated by the record which were backfilled rather than observed
+func (h HourlyUsage) HoursEstimated() int {
+	if h.Hours > 0 {
+		return h.EstimatedHours
+	}
+	if h.Estimated {
+		return 1
+	}
+	return 0
+}
+
+// AverageNodes returns the average node count of the samples in the hour
+func (h HourlyUsage) AverageNodes() float64 {
+	if h.Samples == 0 {
+		return 0
+	}
+	return float64(h.NodeSum) / float64(h.Samples)
+}
+
+// HoursOutOfCompliance returns the time of the hours aggregated by the record in which rancher was out of compliance
+func (h HourlyUsage) HoursOutOfCompliance() float64 {
+	if h.Samples == 0 {
+		return 0
+	}
+	return float64(h.NonCompliantSamples) / float64(h.Samples) * float64(h.HoursCovered())
+}
+
+// flushInterval limits how often the current hour is persisted, so that a restart loses at most this much history
+const flushInterval = 5 * time.Minute
+
+// Recorder aggregates observations into hourly records which are persisted to a Store, and compacts them according
+// to its retention. It isn't safe for concurrent use
+type Recorder struct {
+	store          Store
+	retention      Retention
+	current        *HourlyUsage
+	lastFlush      time.Time
+	lastCompaction time.Time
+}
+
+// NewRecorder returns a recorder persisting to store. Zero durations in retention use those of DefaultRetention
+func NewRecorder(store Store, retention Retention) *Recorder {
+	if retention.Hourly == 0 {
+		retention.Hourly = DefaultRetention.Hourly
+	}
+	if retention.Daily == 0 {
+		retention.Daily = DefaultRetention.Daily
+	}
+	return &Recorder{store: store, retention: retention}
+}
+
+// Observe adds the usage observed at a point in time to the history. nonProductionNodes are the nodes among nodes of
+// clusters classified as non-production
+func (r *Recorder) Observe(nodes, nonProductionNodes, requiredLicenses int, compliant bool, at time.Time) error {
+	hour := at.UTC().Truncate(time.Hour)
+	if r.current != nil && !r.current.Hour.Equal(hour) {
+		if err := r.flush(); err != nil {
+			return err
+		}
+		r.current = nil
+	}
+	if r.current == nil {
+		// continue the record of this hour if the adapter restarted during it
+		current, err := r.load(hour)
+		if err != nil {
+			return err
+		}
+		r.current = current
+	}
+	r.current.Samples++
+	r.current.NodeSum += nodes
+	if nodes > r.current.PeakNodes {
+		r.current.PeakNodes = nodes
+	}
+	if requiredLicenses > r.current.PeakRequiredLicenses {
+		r.current.PeakRequiredLicenses = requiredLicenses
+	}
+	if nonProductionNodes > r.current.PeakNonProductionNodes {
+		r.current.PeakNonProductionNodes = nonProductionNodes
+	}
+	if !compliant {
+		r.current.NonCompliantSamples++
+	}
+	if at.Sub(r.lastFlush) < flushInterval {
+		return nil
+	}
+	if err := r.flush(); err != nil {
+		return err
+	}
+	r.lastFlush = at
+	return nil
+}
+
+// load returns the stored record for hour, or an empty record if there is none
+func (r *Recorder) load(hour time.Time) (*HourlyUsage, error) {
+	records, err := readMonth(r.store, monthOf(hour))
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		if record.Hour.Equal(hour) {
+			return &record, nil
+		}
+	}
+	return &HourlyUsage{Hour: hour}, nil
+}
+
+// flush stores the current record, replacing the stored record of the same hour
+func (r *Recorder) flush() error {
+	month := monthOf(r.current.Hour)
+	records, err := readMonth(r.store, month)
+	if err != nil {
+		return err
+	}
+	replaced := false
+	for i := range records {
+		if records[i].Hour.Equal(r.current.Hour) {
+			records[i] = *r.current
+			replaced = true
+		}
+	}
+	if !replaced {
+		records = append(records, *r.current)
+		sort.Slice(records, func(i, j int) bool {
+			return records[i].Hour.Before(records[j].Hour)
+		})
+	}
+	data, err := json.Marshal(records)
+	if err != nil {
+		return err
+	}
+	return r.store.UpdateUsageHistory(month, data)
+}
+
+// Load returns the stored records for the hours from (inclusive) to to (exclusive). Compacted records are returned if
+// their day starts within the period
+func Load(store Store, from, to time.Time) ([]HourlyUsage, error) {
+	var records []HourlyUsage
+	for month := firstOfMonth(from); month.Before(to); month = month.AddDate(0, 1, 0) {
+		monthRecords, err := readMonth(store, monthOf(month))
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range monthRecords {
+			if !record.Hour.Before(from) && record.Hour.Before(to) {
+				records = append(records, record)
+			}
+		}
+	}
+	return records, nil
+}
+
+func readMonth(store Store, month string) ([]HourlyUsage, error) {
+	data, err := store.GetUsageHistory(month)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read usage history for %s: %w", month, err)
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var records []HourlyUsage
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("unable to parse usage history for %s: %w", month, err)
+	}
+	return records, nil
+}
+
+func monthOf(t time.Time) string {
+	return t.UTC().Format("2006-01")
+}
+
+func firstOfMonth(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
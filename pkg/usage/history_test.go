@@ -0,0 +1,54 @@
+package usage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rancher/csp-adapter/pkg/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecorder(t *testing.T) {
+	store := mocks.NewMockK8sClient(nil)
+	recorder := NewRecorder(store, Retention{})
+	start := time.Date(2022, 1, 31, 23, 0, 0, 0, time.UTC)
+	observations := []struct {
+		after     time.Duration
+		nodes     int
+		compliant bool
+	}{
+		{after: 0, nodes: 10, compliant: true},
+		{after: 20 * time.Minute, nodes: 30, compliant: false},
+		{after: 40 * time.Minute, nodes: 20, compliant: true},
+		// next hour, in the next month
+		{after: 70 * time.Minute, nodes: 40, compliant: true},
+	}
+	for _, o := range observations {
+		assert.NoError(t, recorder.Observe(o.nodes, 0, o.nodes/20+1, o.compliant, start.Add(o.after)))
+	}
+	assert.Contains(t, store.UsageHistory, "2022-01")
+	assert.Contains(t, store.UsageHistory, "2022-02")
+
+	records, err := Load(store, start, start.Add(2*time.Hour))
+	assert.NoError(t, err)
+	assert.Len(t, records, 2)
+	assert.Equal(t, HourlyUsage{
+		Hour:                 start,
+		Samples:              3,
+		PeakNodes:            30,
+		NodeSum:              60,
+		PeakRequiredLicenses: 2,
+		NonCompliantSamples:  1,
+	}, records[0])
+	assert.Equal(t, 20.0, records[0].AverageNodes())
+	assert.InDelta(t, 1.0/3, records[0].HoursOutOfCompliance(), 0.001)
+
+	// a restarted adapter continues the record of the current hour
+	restarted := NewRecorder(store, Retention{})
+	assert.NoError(t, restarted.Observe(50, 0, 3, true, start.Add(80*time.Minute)))
+	records, err = Load(store, start.Add(time.Hour), start.Add(2*time.Hour))
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, 2, records[0].Samples)
+	assert.Equal(t, 50, records[0].PeakNodes)
+}
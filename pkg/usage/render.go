@@ -0,0 +1,172 @@
+package usage
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Report formats
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+	FormatCSV  = "csv"
+	FormatHTML = "html"
+)
+
+// Renderer renders reports in one format, so that each consumer of a report gets a representation which fits it, i.e.
+// json for configmaps, csv for spreadsheets or html for email
+type Renderer interface {
+	// ContentType is the media type of rendered reports, for uploads and email attachments
+	ContentType() string
+	Render(w io.Writer, r Report) error
+}
+
+var renderers = map[string]Renderer{
+	FormatJSON: jsonRenderer{},
+	FormatYAML: yamlRenderer{},
+	FormatCSV:  csvRenderer{},
+	FormatHTML: htmlRenderer{},
+}
+
+// RendererFor returns the renderer for format, one of Formats
+func RendererFor(format string) (Renderer, error) {
+	renderer, ok := renderers[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown report format %q, must be one of %s", format, strings.Join(Formats(), ", "))
+	}
+	return renderer, nil
+}
+
+// Formats returns the formats reports can be rendered in
+func Formats() []string {
+	var formats []string
+	for format := range renderers {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+type jsonRenderer struct{}
+
+func (jsonRenderer) ContentType() string {
+	return "application/json"
+}
+
+func (jsonRenderer) Render(w io.Writer, r Report) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// yamlRenderer renders the same fields as jsonRenderer, for consumers which are edited or reviewed by hand
+type yamlRenderer struct{}
+
+func (yamlRenderer) ContentType() string {
+	return "application/yaml"
+}
+
+func (yamlRenderer) Render(w io.Writer, r Report) error {
+	data, err := yaml.Marshal(r)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// csvRenderer writes one row per record, for further analysis in a spreadsheet
+type csvRenderer struct{}
+
+func (csvRenderer) ContentType() string {
+	return "text/csv"
+}
+
+func (csvRenderer) Render(w io.Writer, r Report) error {
+	writer := csv.NewWriter(w)
+	header := []string{"hour", "peak_nodes", "average_nodes", "peak_required_licenses", "hours_out_of_compliance", "estimated", "hours"}
+	// the column is only added if clusters were classified during the period
+	environments := r.PeakNonProductionNodes > 0
+	if environments {
+		header = append(header, "peak_non_production_nodes")
+	}
+	rows := [][]string{header}
+	for _, record := range r.Hourly {
+		row := []string{
+			record.Hour.Format(time.RFC3339),
+			strconv.Itoa(record.PeakNodes),
+			strconv.FormatFloat(record.AverageNodes(), 'f', 2, 64),
+			strconv.Itoa(record.PeakRequiredLicenses),
+			strconv.FormatFloat(record.HoursOutOfCompliance(), 'f', 2, 64),
+			strconv.FormatBool(record.Estimated),
+			strconv.Itoa(record.HoursCovered()),
+		}
+		if environments {
+			row = append(row, strconv.Itoa(record.PeakNonProductionNodes))
+		}
+		rows = append(rows, row)
+	}
+	return writer.WriteAll(rows)
+}
+
+// htmlRenderer renders a self-contained page which prints well, so that it can be saved as a pdf from a browser or
+// sent as an email
+type htmlRenderer struct{}
+
+func (htmlRenderer) ContentType() string {
+	return "text/html; charset=utf-8"
+}
+
+func (htmlRenderer) Render(w io.Writer, r Report) error {
+	return reportTemplate.Execute(w, r)
+}
+
+// reportTemplate is the page rendered by htmlRenderer
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"date":  func(t time.Time) string { return t.Format("2006-01-02 15:04 MST") },
+	"fixed": func(f float64) string { return strconv.FormatFloat(f, 'f', 2, 64) },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Rancher usage report {{ date .From }} - {{ date .To }}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #999; padding: 0.3em 0.8em; text-align: right; }
+th { background: #eee; }
+tr { page-break-inside: avoid; }
+</style>
+</head>
+<body>
+<h1>Rancher usage report</h1>
+<p>{{ date .From }} - {{ date .To }}</p>
+<table>
+<tr><th>Hours observed</th><td>{{ .HoursObserved }}{{ if .HoursEstimated }} ({{ .HoursEstimated }} estimated){{ end }}</td></tr>
+<tr><th>Peak nodes</th><td>{{ .PeakNodes }}{{ if .HoursObserved }} ({{ date .PeakAt }}){{ end }}</td></tr>
+<tr><th>Average nodes</th><td>{{ fixed .AverageNodes }}</td></tr>
+<tr><th>Peak required licenses</th><td>{{ .PeakRequiredLicenses }}</td></tr>
+{{- if .PeakNonProductionNodes }}
+<tr><th>Peak non-production nodes</th><td>{{ .PeakNonProductionNodes }}</td></tr>
+{{- end }}
+<tr><th>Hours out of compliance</th><td>{{ fixed .HoursOutOfCompliance }}</td></tr>
+</table>
+<table>
+<tr><th>Hour</th><th>Peak nodes</th><th>Average nodes</th><th>Peak required licenses</th><th>Hours out of compliance</th>{{ if .PeakNonProductionNodes }}<th>Peak non-production nodes</th>{{ end }}</tr>
+{{- $environments := .PeakNonProductionNodes }}
+{{- range .Hourly }}
+<tr><td>{{ date .Hour }}{{ if .Hours }} ({{ .Hours }} hours){{ end }}{{ if .Estimated }} (estimated){{ end }}</td><td>{{ .PeakNodes }}</td><td>{{ fixed .AverageNodes }}</td><td>{{ .PeakRequiredLicenses }}</td><td>{{ fixed .HoursOutOfCompliance }}</td>{{ if $environments }}<td>{{ .PeakNonProductionNodes }}</td>{{ end }}</tr>
+{{- end }}
+</table>
+</body>
+</html>
+`))
@@ -0,0 +1,85 @@
+package usage
+
+import (
+	"io"
+	"time"
+)
+
+// Report summarizes the usage history of a period for true-up reviews
+type Report struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	// HoursObserved is the number of hours in the period for which usage was recorded
+	HoursObserved int `json:"hoursObserved"`
+	// HoursEstimated is the number of recorded hours which were backfilled rather than observed
+	HoursEstimated       int       `json:"hoursEstimated,omitempty"`
+	PeakNodes            int       `json:"peakNodes"`
+	PeakAt               time.Time `json:"peakAt,omitempty"`
+	AverageNodes         float64   `json:"averageNodes"`
+	PeakRequiredLicenses int       `json:"peakRequiredLicenses"`
+	// PeakNonProductionNodes is the largest number of nodes of clusters classified as non-production
+	PeakNonProductionNodes int `json:"peakNonProductionNodes,omitempty"`
+	// HoursOutOfCompliance is the time in which fewer licenses were held than required, in hours
+	HoursOutOfCompliance float64       `json:"hoursOutOfCompliance"`
+	Hourly               []HourlyUsage `json:"hourly"`
+	// Timezone is the location the report's times are rendered in, UTC if empty
+	Timezone string `json:"timezone,omitempty"`
+}
+
+// NewReport summarizes the hourly records of the period from (inclusive) to to (exclusive)
+func NewReport(from, to time.Time, records []HourlyUsage) Report {
+	report := Report{
+		From:   from,
+		To:     to,
+		Hourly: records,
+	}
+	samples, nodeSum := 0, 0
+	for _, record := range records {
+		report.HoursObserved += record.HoursCovered()
+		report.HoursEstimated += record.HoursEstimated()
+		samples += record.Samples
+		nodeSum += record.NodeSum
+		if record.PeakNodes > report.PeakNodes {
+			report.PeakNodes = record.PeakNodes
+			report.PeakAt = record.Hour
+		}
+		if record.PeakRequiredLicenses > report.PeakRequiredLicenses {
+			report.PeakRequiredLicenses = record.PeakRequiredLicenses
+		}
+		if record.PeakNonProductionNodes > report.PeakNonProductionNodes {
+			report.PeakNonProductionNodes = record.PeakNonProductionNodes
+		}
+		report.HoursOutOfCompliance += record.HoursOutOfCompliance()
+	}
+	if samples > 0 {
+		report.AverageNodes = float64(nodeSum) / float64(samples)
+	}
+	return report
+}
+
+// In returns the report with its times in loc, so that every format renders them in the customer's business day. The
+// records themselves are kept in UTC
+func (r Report) In(loc *time.Location) Report {
+	r.From = r.From.In(loc)
+	r.To = r.To.In(loc)
+	if !r.PeakAt.IsZero() {
+		r.PeakAt = r.PeakAt.In(loc)
+	}
+	hourly := make([]HourlyUsage, len(r.Hourly))
+	for i, record := range r.Hourly {
+		record.Hour = record.Hour.In(loc)
+		hourly[i] = record
+	}
+	r.Hourly = hourly
+	r.Timezone = loc.String()
+	return r
+}
+
+// Write writes the report to w in format, one of Formats
+func (r Report) Write(w io.Writer, format string) error {
+	renderer, err := RendererFor(format)
+	if err != nil {
+		return err
+	}
+	return renderer.Render(w, r)
+}
@@ -0,0 +1,113 @@
+package usage
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReport(t *testing.T) {
+	from := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	records := []HourlyUsage{
+		{Hour: from, Samples: 120, PeakNodes: 30, NodeSum: 2400, PeakRequiredLicenses: 2},
+		{Hour: from.Add(time.Hour), Samples: 120, PeakNodes: 50, NodeSum: 4800, PeakRequiredLicenses: 3, NonCompliantSamples: 60},
+	}
+	report := NewReport(from, from.AddDate(0, 0, 1), records)
+	assert.Equal(t, 2, report.HoursObserved)
+	assert.Equal(t, 50, report.PeakNodes)
+	assert.Equal(t, from.Add(time.Hour), report.PeakAt)
+	assert.Equal(t, 30.0, report.AverageNodes)
+	assert.Equal(t, 3, report.PeakRequiredLicenses)
+	assert.Equal(t, 0.5, report.HoursOutOfCompliance)
+
+	var buf bytes.Buffer
+	assert.NoError(t, report.Write(&buf, FormatJSON))
+	var decoded Report
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Equal(t, report.PeakNodes, decoded.PeakNodes)
+
+	buf.Reset()
+	assert.NoError(t, report.Write(&buf, FormatCSV))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 3, "expected a header and one row per hour")
+	assert.Equal(t, "2022-01-01T01:00:00Z,50,40.00,3,0.50,false,1", lines[2])
+
+	buf.Reset()
+	assert.NoError(t, report.Write(&buf, FormatHTML))
+	assert.Contains(t, buf.String(), "<td>0.50</td>")
+
+	assert.Error(t, report.Write(&buf, "pdf"))
+}
+
+func TestRenderers(t *testing.T) {
+	from := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	report := NewReport(from, from.Add(time.Hour), []HourlyUsage{
+		{Hour: from, Samples: 120, PeakNodes: 30, NodeSum: 2400, PeakRequiredLicenses: 2},
+	})
+	tests := []struct {
+		format              string
+		expectedContentType string
+		expectedContent     string
+	}{
+		{format: FormatJSON, expectedContentType: "application/json", expectedContent: `"peakNodes": 30`},
+		{format: FormatYAML, expectedContentType: "application/yaml", expectedContent: "peakNodes: 30"},
+		{format: FormatCSV, expectedContentType: "text/csv", expectedContent: "2022-01-01T00:00:00Z,30,20.00,2,0.00,false,1"},
+		{format: FormatHTML, expectedContentType: "text/html; charset=utf-8", expectedContent: "<td>30</td>"},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.format, func(t *testing.T) {
+			renderer, err := RendererFor(test.format)
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedContentType, renderer.ContentType())
+			var buf bytes.Buffer
+			assert.NoError(t, renderer.Render(&buf, report))
+			assert.Contains(t, buf.String(), test.expectedContent)
+		})
+	}
+	assert.Equal(t, []string{FormatCSV, FormatHTML, FormatJSON, FormatYAML}, Formats())
+}
+
+func TestReportEnvironments(t *testing.T) {
+	from := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	report := NewReport(from, from.Add(2*time.Hour), []HourlyUsage{
+		{Hour: from, Samples: 120, PeakNodes: 30, NodeSum: 2400, PeakRequiredLicenses: 1, PeakNonProductionNodes: 10},
+		{Hour: from.Add(time.Hour), Samples: 120, PeakNodes: 30, NodeSum: 2400, PeakRequiredLicenses: 1, PeakNonProductionNodes: 15},
+	})
+	assert.Equal(t, 15, report.PeakNonProductionNodes)
+
+	var buf bytes.Buffer
+	assert.NoError(t, report.Write(&buf, FormatCSV))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.True(t, strings.HasSuffix(lines[0], ",peak_non_production_nodes"), "non-production nodes should be reported once clusters are classified")
+	assert.Equal(t, "2022-01-01T01:00:00Z,30,20.00,1,0.00,false,1,15", lines[2])
+
+	buf.Reset()
+	assert.NoError(t, report.Write(&buf, FormatHTML))
+	assert.Contains(t, buf.String(), "<th>Peak non-production nodes</th><td>15</td>")
+}
+
+func TestReportIn(t *testing.T) {
+	loc := time.FixedZone("EST", -5*60*60)
+	// midnight of the customer's business day
+	from := time.Date(2022, 1, 1, 0, 0, 0, 0, loc)
+	records := []HourlyUsage{
+		{Hour: from.UTC(), Samples: 120, PeakNodes: 30, NodeSum: 2400, PeakRequiredLicenses: 2},
+	}
+	report := NewReport(from, from.AddDate(0, 0, 1), records).In(loc)
+	assert.Equal(t, "EST", report.Timezone)
+	assert.Equal(t, loc, report.PeakAt.Location())
+	assert.Equal(t, time.UTC, records[0].Hour.Location(), "the records passed in must not be changed")
+
+	var buf bytes.Buffer
+	assert.NoError(t, report.Write(&buf, FormatCSV))
+	assert.Contains(t, buf.String(), "2022-01-01T00:00:00-05:00,30,")
+
+	buf.Reset()
+	assert.NoError(t, report.Write(&buf, FormatHTML))
+	assert.Contains(t, buf.String(), "2022-01-01 00:00 EST - 2022-01-02 00:00 EST")
+}